@@ -0,0 +1,266 @@
+/*
+	Package ads flags suspicious alternate data streams (named $DATA attributes), which are a well known place to hide
+	executables and other data from users and tools that only look at the unnamed stream of a file. Each stream is
+	scored using a number of heuristics, resulting in a list of findings to review, most suspicious first.
+
+	Basic usage
+
+	Create an Analyzer and add it to a pipeline, after the Paths stage to report full paths and to recognize system
+	binaries by their location. Set a Volume to also inspect the contents of non-resident streams.
+			// Error handling left out for brevity
+			a := ads.NewAnalyzer(ads.Options{Volume: volume, BytesPerCluster: 4096})
+			stats, err := pipeline.Run(in, pipeline.Options{},
+				pipeline.Paths(pipeline.NewPathResolver(in, 1024, 0)),
+				a)
+			for _, f := range a.Findings() {
+				fmt.Printf("%3d %s:%s %v\n", f.Score, f.Path, f.Stream, f.Reasons)
+			}
+
+	Implementation notes
+
+	The score of a stream is the sum of the Weights of the Reasons found for it:
+		- ReasonExecutableContent: the stream starts with the signature of a PE (MZ) or ELF executable;
+		- ReasonExecutableName: the name of the stream has the extension of an executable or script, such as ".exe" or
+		  ".ps1";
+		- ReasonLarge: the stream is at least LargeSize bytes;
+		- ReasonSystemBinary: the stream is attached to an executable, driver or library, or to any file in the Windows
+		  directory, where legitimate streams are rare;
+		- ReasonDirectory: the stream is attached to a directory.
+
+	Some streams are created by Windows and common applications, such as Zone.Identifier (the "mark of the web") and
+	the property streams of Office documents. For these well known names only ReasonExecutableContent is considered.
+
+	Only the first bytes of a stream are read to recognize executables. Resident streams are read from the record;
+	non-resident streams are read from the Volume (see volume.NewAttributeReader), so without a Volume their contents
+	are not inspected. Like the scan package, only the attributes in the record itself are considered, not those in
+	extension records.
+*/
+package ads
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/pipeline"
+	"github.com/t9t/gomft/volume"
+)
+
+// DefaultLargeSize is the size from which a stream is considered large when the Options do not specify a LargeSize.
+const DefaultLargeSize = 1024 * 1024
+
+// Reason is a heuristic that makes an alternate data stream suspicious.
+type Reason int
+
+// Reasons found by an Analyzer.
+const (
+	ReasonExecutableContent Reason = iota
+	ReasonExecutableName
+	ReasonLarge
+	ReasonSystemBinary
+	ReasonDirectory
+)
+
+var reasonNames = []string{"executable content", "executable name", "large", "on system binary", "on directory"}
+
+var reasonWeights = []int{60, 30, 20, 30, 10}
+
+func (r Reason) String() string {
+	if r < 0 || int(r) >= len(reasonNames) {
+		return fmt.Sprintf("Reason(%d)", int(r))
+	}
+	return reasonNames[r]
+}
+
+// Weight returns the number of points the Reason adds to the score of a stream.
+func (r Reason) Weight() int {
+	if r < 0 || int(r) >= len(reasonWeights) {
+		return 0
+	}
+	return reasonWeights[r]
+}
+
+// Finding is an alternate data stream for which at least one Reason was found.
+type Finding struct {
+	RecordNumber   uint64
+	SequenceNumber uint16
+	Path           string // the Path of the pipeline.Item, empty unless set by a previous stage
+	Stream         string // the name of the $DATA attribute
+	Size           int64  // the size of the stream in bytes
+	Score          int    // the sum of the Weights of the Reasons
+	Reasons        []Reason
+}
+
+// Options configure an Analyzer.
+type Options struct {
+	// Volume, when not nil, is the volume which the MFT belongs to. It is needed to inspect non-resident streams.
+	Volume io.ReaderAt
+	// BytesPerCluster is the cluster size of the Volume.
+	BytesPerCluster int
+	// LargeSize is the size in bytes from which a stream is considered large. When zero, DefaultLargeSize is used.
+	LargeSize int64
+	// MinScore is the lowest score of the Findings that are kept. When zero, all streams with any Reason are kept.
+	MinScore int
+	// ErrorHandler, when not nil, is called for each stream that could not be read. The stream is still scored using
+	// the heuristics that do not need its contents.
+	ErrorHandler func(item *pipeline.Item, stream string, err error)
+}
+
+// executableSignatures are the first bytes of executable files.
+var executableSignatures = [][]byte{[]byte("MZ"), []byte("\x7fELF")}
+
+// executableExtensions are the extensions of files which Windows runs or loads as code.
+var executableExtensions = map[string]bool{
+	"exe": true, "dll": true, "sys": true, "scr": true, "com": true, "pif": true, "cpl": true, "ocx": true, "msi": true,
+	"bat": true, "cmd": true, "ps1": true, "vbs": true, "vbe": true, "js": true, "jse": true, "wsf": true, "hta": true,
+	"jar": true, "lnk": true,
+}
+
+// systemBinaryExtensions are the extensions of files considered system binaries.
+var systemBinaryExtensions = map[string]bool{"exe": true, "dll": true, "sys": true, "com": true, "scr": true, "cpl": true, "ocx": true}
+
+// wellKnownStreams are the (upper case) names of streams created by Windows and common applications.
+var wellKnownStreams = map[string]bool{
+	"ZONE.IDENTIFIER":                        true,
+	"SMARTSCREEN":                            true,
+	"ENCRYPTABLE":                            true,
+	"AFP_AFPINFO":                            true,
+	"AFP_RESOURCE":                           true,
+	"COM.DROPBOX.ATTRIBUTES":                 true,
+	"COM.DROPBOX.ATTRS":                      true,
+	"OECUSTOMPROPERTY":                       true,
+	"MS-PROPERTIES":                          true,
+	"WOFCOMPRESSEDDATA":                      true,
+	"{4C8CC155-6C1E-11D1-8E41-00C04FB9386D}": true,
+	"\x05SUMMARYINFORMATION":                 true,
+	"\x05DOCUMENTSUMMARYINFORMATION":         true,
+}
+
+// Analyzer scores the alternate data streams of records and keeps the Findings. An Analyzer is a pipeline.Stage which
+// passes all Items. It is not safe for concurrent use.
+type Analyzer struct {
+	opts     Options
+	findings []Finding
+}
+
+// NewAnalyzer creates an Analyzer.
+func NewAnalyzer(opts Options) *Analyzer {
+	if opts.LargeSize <= 0 {
+		opts.LargeSize = DefaultLargeSize
+	}
+	return &Analyzer{opts: opts}
+}
+
+// Process scores the alternate data streams of the Item and passes it on.
+func (a *Analyzer) Process(item *pipeline.Item) (bool, error) {
+	a.Add(item)
+	return true, nil
+}
+
+// Add scores the alternate data streams of the Item.
+func (a *Analyzer) Add(item *pipeline.Item) {
+	for _, attr := range item.Record.FindAttributes(mft.AttributeTypeData) {
+		if attr.Name == "" {
+			continue
+		}
+		f := a.score(item, attr)
+		if len(f.Reasons) > 0 && f.Score >= a.opts.MinScore {
+			a.findings = append(a.findings, f)
+		}
+	}
+}
+
+func (a *Analyzer) score(item *pipeline.Item, attr mft.Attribute) Finding {
+	size := int64(len(attr.Data))
+	if !attr.Resident {
+		size = int64(attr.ActualSize)
+	}
+	f := Finding{
+		RecordNumber:   item.Record.FileReference.RecordNumber,
+		SequenceNumber: item.Record.FileReference.SequenceNumber,
+		Path:           item.Path,
+		Stream:         attr.Name,
+		Size:           size,
+	}
+	reason := func(r Reason) {
+		f.Reasons = append(f.Reasons, r)
+		f.Score += r.Weight()
+	}
+
+	executable, err := a.isExecutable(attr, size)
+	if err != nil && a.opts.ErrorHandler != nil {
+		a.opts.ErrorHandler(item, attr.Name, err)
+	}
+	if executable {
+		reason(ReasonExecutableContent)
+	}
+	if wellKnownStreams[strings.ToUpper(attr.Name)] {
+		return f
+	}
+	if executableExtensions[extension(attr.Name)] {
+		reason(ReasonExecutableName)
+	}
+	if size >= a.opts.LargeSize {
+		reason(ReasonLarge)
+	}
+	if item.Entry.Directory {
+		reason(ReasonDirectory)
+	} else if systemBinaryExtensions[extension(item.Entry.Name)] || strings.HasPrefix(strings.ToUpper(item.Path), "/WINDOWS/") {
+		reason(ReasonSystemBinary)
+	}
+	return f
+}
+
+// isExecutable checks whether the stream starts with the signature of an executable.
+func (a *Analyzer) isExecutable(attr mft.Attribute, size int64) (bool, error) {
+	r, err := volume.NewAttributeReader(a.opts.Volume, a.opts.BytesPerCluster, attr)
+	if err == volume.ErrNoVolume {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	// Do not read beyond the end of the stream, into the slack space of its last cluster
+	headerSize := int64(4)
+	if size < headerSize {
+		headerSize = size
+	}
+	header := make([]byte, headerSize)
+	n, err := r.ReadAt(header, 0)
+	if n < len(header) && err != nil && err != io.EOF {
+		return false, fmt.Errorf("unable to read stream: %v", err)
+	}
+	for _, signature := range executableSignatures {
+		if bytes.HasPrefix(header[:n], signature) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Findings returns the Findings, ordered by descending score, then by record number and stream name.
+func (a *Analyzer) Findings() []Finding {
+	findings := append([]Finding(nil), a.findings...)
+	sort.SliceStable(findings, func(i, j int) bool {
+		fi, fj := findings[i], findings[j]
+		if fi.Score != fj.Score {
+			return fi.Score > fj.Score
+		}
+		if fi.RecordNumber != fj.RecordNumber {
+			return fi.RecordNumber < fj.RecordNumber
+		}
+		return fi.Stream < fj.Stream
+	})
+	return findings
+}
+
+// extension returns the lower case extension of the name without the dot, or an empty string if it has none.
+func extension(name string) string {
+	i := strings.LastIndexByte(name, '.')
+	if i < 0 {
+		return ""
+	}
+	return strings.ToLower(name[i+1:])
+}
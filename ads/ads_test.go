@@ -0,0 +1,95 @@
+package ads_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/ads"
+	"github.com/t9t/gomft/export"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/mfttest"
+	"github.com/t9t/gomft/pipeline"
+)
+
+func item(number uint64, path string, b *mfttest.RecordBuilder) *pipeline.Item {
+	r := b.WithRecordNumber(number).Record()
+	return &pipeline.Item{Record: r, Entry: export.FromRecord(r), Path: path}
+}
+
+// nonResident returns a non-resident $DATA attribute with the name of size bytes, stored from the cluster.
+func nonResident(name string, size uint64, cluster int64) mft.Attribute {
+	return mft.Attribute{Type: mft.AttributeTypeData, Name: name, AllocatedSize: 4096, ActualSize: size,
+		Data: mfttest.EncodeDataRuns([]mft.DataRun{{OffsetCluster: cluster, LengthInClusters: 1}})}
+}
+
+func TestAnalyzer(t *testing.T) {
+	volume := make([]byte, 4*4096)
+	copy(volume[2*4096:], "MZ\x90\x00")
+	copy(volume[3*4096:], "\x7fEL")
+
+	var errors []string
+	a := ads.NewAnalyzer(ads.Options{
+		Volume:          bytes.NewReader(volume),
+		BytesPerCluster: 4096,
+		LargeSize:       100,
+		ErrorHandler: func(item *pipeline.Item, stream string, err error) {
+			errors = append(errors, stream+": "+err.Error())
+		},
+	})
+	items := []*pipeline.Item{
+		item(40, "/Users/bob/report.docx", mfttest.NewRecord().WithFileName("report.docx").
+			WithStream("Zone.Identifier", []byte("[ZoneTransfer]\r\nZoneId=3")).
+			WithStream("payload.exe", []byte("MZ\x90\x00"))),
+		item(41, "/Windows/notepad.exe", mfttest.NewRecord().WithFileName("notepad.exe").
+			WithStream("Zone.Identifier", []byte("[ZoneTransfer]")).
+			WithStream("hidden", []byte("data"))),
+		item(42, "/Users/bob/photo.jpg", mfttest.NewRecord().WithFileName("photo.jpg").
+			WithAttribute(nonResident("thumb", 2000, 2))),
+		item(43, "/Users", mfttest.NewRecord().WithDirectory().WithFileName("Users").WithStream("x", []byte("MZ"))),
+		// Only 3 bytes, so the partial ELF signature is not a match; slack must not be read
+		item(44, "/a.txt", mfttest.NewRecord().WithFileName("a.txt").WithAttribute(nonResident("elf", 3, 3))),
+		// Beyond the end of the volume
+		item(45, "/b.txt", mfttest.NewRecord().WithFileName("b.txt").WithAttribute(nonResident("gone", 10, 100))),
+		item(46, "/c.txt", mfttest.NewRecord().WithFileName("c.txt").WithResidentData([]byte("MZ"))),
+	}
+	for _, i := range items {
+		ok, err := a.Process(i)
+		require.Nil(t, err)
+		assert.True(t, ok)
+	}
+
+	assert.Equal(t, []ads.Finding{
+		{RecordNumber: 40, SequenceNumber: 1, Path: "/Users/bob/report.docx", Stream: "payload.exe", Size: 4, Score: 90,
+			Reasons: []ads.Reason{ads.ReasonExecutableContent, ads.ReasonExecutableName}},
+		{RecordNumber: 42, SequenceNumber: 1, Path: "/Users/bob/photo.jpg", Stream: "thumb", Size: 2000, Score: 80,
+			Reasons: []ads.Reason{ads.ReasonExecutableContent, ads.ReasonLarge}},
+		{RecordNumber: 43, SequenceNumber: 1, Path: "/Users", Stream: "x", Size: 2, Score: 70,
+			Reasons: []ads.Reason{ads.ReasonExecutableContent, ads.ReasonDirectory}},
+		{RecordNumber: 41, SequenceNumber: 1, Path: "/Windows/notepad.exe", Stream: "hidden", Size: 4, Score: 30,
+			Reasons: []ads.Reason{ads.ReasonSystemBinary}},
+	}, a.Findings())
+	assert.Equal(t, 1, len(errors))
+	assert.Contains(t, errors[0], "gone: unable to read stream")
+}
+
+func TestAnalyzer_Options(t *testing.T) {
+	i := item(42, "", mfttest.NewRecord().WithFileName("photo.jpg").WithAttribute(nonResident("thumb", 2<<20, 2)))
+
+	// Without a volume, the contents of non-resident streams are not inspected
+	a := ads.NewAnalyzer(ads.Options{})
+	a.Add(i)
+	assert.Equal(t, []ads.Reason{ads.ReasonLarge}, a.Findings()[0].Reasons)
+
+	a = ads.NewAnalyzer(ads.Options{MinScore: 21})
+	a.Add(i)
+	assert.Empty(t, a.Findings())
+}
+
+func TestReason(t *testing.T) {
+	assert.Equal(t, "on system binary", ads.ReasonSystemBinary.String())
+	assert.Equal(t, 60, ads.ReasonExecutableContent.Weight())
+	assert.Equal(t, "Reason(9)", ads.Reason(9).String())
+	assert.Equal(t, 0, ads.Reason(9).Weight())
+}
@@ -0,0 +1,311 @@
+/*
+	Package archive writes the contents of files into a single zip or tar archive while the records of an MFT stream
+	through a pipeline, preserving their paths and timestamps. This lets collection tooling built on gomft produce one
+	evidence container instead of a directory tree of extracted files.
+
+	Basic usage
+
+	Create a Writer for the archive format and add the Stage to a pipeline, after the Paths stage and usually after a
+	Filter stage selecting the files to collect.
+			// Error handling left out for brevity
+			out, err := os.Create("evidence.zip")
+			w := archive.NewZipWriter(out)
+			opts := archive.Options{Volume: volume, BytesPerCluster: 4096, Streams: true}
+			stats, err := pipeline.Run(in, pipeline.Options{},
+				pipeline.Paths(pipeline.NewPathResolver(in, 1024, 0)),
+				pipeline.Filter(func(item *pipeline.Item) bool { return f.Match(item.Entry) }),
+				archive.Stage(w, opts))
+			err = w.Close()
+			err = out.Close()
+
+	Implementation notes
+
+	Each file is stored under its path without the leading slash, so orphaned files end up in "$Orphan". Alternate data
+	streams are only stored when Streams is set, as separate entries named like on NTFS: the path of the file, a colon
+	and the name of the stream (such as "Users/bob/setup.exe:Zone.Identifier"). Such names cannot be extracted as-is by
+	all tools on Windows. When a Manifest is set, the size and hashes of each entry are written to it while the entry is
+	stored, so the extracted files can be verified against the manifest using hashdeep. Likewise, when Entropy is set,
+	the entropy of each stream is computed over all of its data while it is stored, and set in the Entry of the Item.
+
+	The $STANDARD_INFORMATION times of the file are stored with each entry. Zip entries get an NTFS extra field with the
+	modification, access and creation times in full precision, besides the regular modification time. Tar archives are
+	written in the PAX format, with the MFT modification time as change time and the creation time in the
+	LIBARCHIVE.creationtime record, which is understood by libarchive (bsdtar).
+
+	Resident data is read straight from the record; non-resident data is read from the Volume using the data runs of the
+	attribute (see volume.NewAttributeReader), with sparse runs read as zeroes and compressed streams decompressed. Only
+	the attributes in the record itself are considered, not those in extension records. The unnamed stream of files
+	deduplicated by Data Deduplication (see the dedup package) is read from the ChunkStore; without one, it is reported
+	as dedup.ErrChunkStore. Streams which cannot be read are passed to the ErrorHandler and skipped, before anything is
+	written. An error writing the archive, or reading the Volume once an entry has been started, leaves the archive in
+	an unusable state and stops the pipeline.
+
+	Records which are not in use are stored too when they pass the previous stages. Be aware that the clusters of
+	deleted files may have been reused, so use a pipeline.Filter stage to skip such records unless that is intended.
+*/
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/t9t/gomft/dedup"
+	"github.com/t9t/gomft/entropy"
+	"github.com/t9t/gomft/hashdeep"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/pipeline"
+	"github.com/t9t/gomft/volume"
+)
+
+// File describes an entry to add to an archive.
+type File struct {
+	Name             string // the name of the entry, using forward slashes and without a leading slash
+	Size             int64
+	Creation         time.Time
+	FileLastModified time.Time
+	MftLastModified  time.Time
+	LastAccess       time.Time
+}
+
+// A Writer writes files into a zip or tar archive. It is not safe for concurrent use.
+type Writer struct {
+	zip *zip.Writer
+	tar *tar.Writer
+}
+
+// NewZipWriter creates a Writer writing a zip archive to w.
+func NewZipWriter(w io.Writer) *Writer {
+	return &Writer{zip: zip.NewWriter(w)}
+}
+
+// NewTarWriter creates a Writer writing a tar archive in the PAX format to w.
+func NewTarWriter(w io.Writer) *Writer {
+	return &Writer{tar: tar.NewWriter(w)}
+}
+
+// Add adds a file to the archive, reading f.Size bytes of contents from r.
+func (w *Writer) Add(f File, r io.Reader) error {
+	var dst io.Writer
+	var err error
+	if w.zip != nil {
+		dst, err = w.zip.CreateHeader(zipHeader(f))
+	} else {
+		dst, err = w.tar, w.tar.WriteHeader(tarHeader(f))
+	}
+	if err != nil {
+		return fmt.Errorf("unable to write header of %s: %v", f.Name, err)
+	}
+	n, err := io.CopyN(dst, r, f.Size)
+	if err != nil {
+		return fmt.Errorf("unable to write contents of %s (wrote %d of %d bytes): %v", f.Name, n, f.Size, err)
+	}
+	return nil
+}
+
+// Close finishes the archive by writing its trailer. It does not close the underlying writer.
+func (w *Writer) Close() error {
+	if w.zip != nil {
+		return w.zip.Close()
+	}
+	return w.tar.Close()
+}
+
+func zipHeader(f File) *zip.FileHeader {
+	h := &zip.FileHeader{Name: f.Name, Method: zip.Deflate, Modified: f.FileLastModified}
+	h.UncompressedSize64 = uint64(f.Size)
+	// NTFS extra field (0x000a): 4 reserved bytes, followed by attribute 0x0001 with the modification, access and
+	// creation times as FILETIMEs.
+	extra := make([]byte, 36)
+	binary.LittleEndian.PutUint16(extra[0:], 0x000a)
+	binary.LittleEndian.PutUint16(extra[2:], 32)
+	binary.LittleEndian.PutUint16(extra[8:], 0x0001)
+	binary.LittleEndian.PutUint16(extra[10:], 24)
+	binary.LittleEndian.PutUint64(extra[12:], fileTime(f.FileLastModified))
+	binary.LittleEndian.PutUint64(extra[20:], fileTime(f.LastAccess))
+	binary.LittleEndian.PutUint64(extra[28:], fileTime(f.Creation))
+	h.Extra = extra
+	return h
+}
+
+func tarHeader(f File) *tar.Header {
+	h := &tar.Header{
+		Typeflag:   tar.TypeReg,
+		Name:       f.Name,
+		Size:       f.Size,
+		Mode:       0644,
+		ModTime:    f.FileLastModified,
+		AccessTime: f.LastAccess,
+		ChangeTime: f.MftLastModified,
+		Format:     tar.FormatPAX,
+	}
+	if !f.Creation.IsZero() {
+		h.PAXRecords = map[string]string{"LIBARCHIVE.creationtime": paxTime(f.Creation)}
+	}
+	return h
+}
+
+// fileTime converts t to a "file time", or 0 when t is the zero time.
+func fileTime(t time.Time) uint64 {
+	if t.IsZero() {
+		return 0
+	}
+	return mft.ConvertToFileTime(t)
+}
+
+// paxTime formats t as seconds since the Unix epoch with a fraction, like the time records of PAX headers.
+func paxTime(t time.Time) string {
+	s := strconv.FormatInt(t.Unix(), 10)
+	if ns := t.Nanosecond(); ns != 0 {
+		s += strings.TrimRight(fmt.Sprintf(".%09d", ns), "0")
+	}
+	return s
+}
+
+// Options configures the streams stored by a Stage.
+type Options struct {
+	// Volume, when not nil, is the volume which the MFT belongs to. It is needed to store non-resident data.
+	Volume io.ReaderAt
+	// BytesPerCluster is the cluster size of the Volume.
+	BytesPerCluster int
+	// ChunkStore, when not nil, is used to read the contents of files deduplicated by Data Deduplication; without it,
+	// their unnamed stream is reported to the ErrorHandler as dedup.ErrChunkStore.
+	ChunkStore *dedup.ChunkStore
+	// Streams stores the alternate data streams of files as separate entries, besides the unnamed stream.
+	Streams bool
+	// Manifest, when not nil, receives the size and hashes of each entry stored, under the name of the entry.
+	Manifest *hashdeep.Writer
+	// Entropy computes the entropy of each stream stored, which is set in the Entry of the Item (see
+	// export.Entry.SetEntropy) and in the pipeline.FileExtracted Event.
+	Entropy bool
+	// ErrorHandler, when not nil, is called for each stream that could not be read and was therefore skipped, including
+	// streams that are non-resident while there is no Volume and files without a path.
+	ErrorHandler func(item *pipeline.Item, stream string, err error)
+	// Observer, when not nil, receives a pipeline.FileExtracted Event for each stream stored. Wrap the Volume using
+	// pipeline.ObserveReaderAt to receive pipeline.BadSector Events as well.
+	Observer *pipeline.Observer
+}
+
+// Stage creates a pipeline.Stage that adds the $DATA attributes of each Item to the archive written by w. It never
+// drops Items; an error writing the archive stops the pipeline. The Writer is not closed by the Stage.
+func Stage(w *Writer, opts Options) pipeline.Stage {
+	return StageContext(context.Background(), w, opts)
+}
+
+// StageContext creates a Stage like Stage, which also fails when ctx is done, even while the contents of a large file
+// are being stored. Like any error storing an entry, this leaves the archive in an unusable state. Use it with
+// pipeline.RunContext and the same ctx, which then returns ctx.Err().
+func StageContext(ctx context.Context, w *Writer, opts Options) pipeline.Stage {
+	return pipeline.StageFunc(func(item *pipeline.Item) (bool, error) {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		// An invalid reparse point does not affect the data, so it is not reported here
+		deduplicated, _ := dedup.Detect(item.Record)
+		for _, a := range item.Record.FindAttributes(mft.AttributeTypeData) {
+			if a.Name != "" && !opts.Streams {
+				continue
+			}
+			if item.Path == "" || item.Path == "/" {
+				if opts.ErrorHandler != nil {
+					opts.ErrorHandler(item, a.Name, fmt.Errorf("the file has no path"))
+				}
+				continue
+			}
+			var r *io.SectionReader
+			var err error
+			if a.Name == "" && deduplicated {
+				r, err = openDeduplicated(opts.ChunkStore, item.Record)
+			} else {
+				r, err = volume.NewAttributeReader(opts.Volume, opts.BytesPerCluster, a)
+			}
+			if err != nil {
+				if opts.ErrorHandler != nil {
+					opts.ErrorHandler(item, a.Name, err)
+				}
+				continue
+			}
+			f := File{
+				Name:             strings.TrimPrefix(item.Path, "/"),
+				Size:             r.Size(),
+				Creation:         item.Entry.Creation,
+				FileLastModified: item.Entry.FileLastModified,
+				MftLastModified:  item.Entry.MftLastModified,
+				LastAccess:       item.Entry.LastAccess,
+			}
+			if a.Name != "" {
+				f.Name += ":" + a.Name
+			}
+			var src io.Reader = contextReader{ctx: ctx, r: r}
+			var h *hashdeep.Hasher
+			if opts.Manifest != nil {
+				h = opts.Manifest.NewHasher()
+				src = io.TeeReader(src, h)
+			}
+			var c *entropy.Counter
+			if opts.Entropy {
+				c = &entropy.Counter{}
+				src = io.TeeReader(src, c)
+			}
+			if err := w.Add(f, src); err != nil {
+				return false, err
+			}
+			if h != nil {
+				if err := opts.Manifest.WriteHashes(f.Name, h); err != nil {
+					return false, fmt.Errorf("unable to write manifest: %v", err)
+				}
+			}
+			extracted := pipeline.FileExtracted{
+				RecordNumber: item.Entry.RecordNumber,
+				Path:         item.Path,
+				Stream:       a.Name,
+				Size:         r.Size(),
+			}
+			if c != nil {
+				extracted.Entropy, extracted.HasEntropy = c.Entropy(), true
+				item.Entry.SetEntropy(a.Name, extracted.Entropy)
+			}
+			opts.Observer.Publish(extracted)
+		}
+		return true, nil
+	})
+}
+
+// openDeduplicated opens the contents of a file deduplicated by Data Deduplication in the chunk store.
+func openDeduplicated(store *dedup.ChunkStore, record mft.Record) (*io.SectionReader, error) {
+	if store == nil {
+		return nil, dedup.ErrChunkStore
+	}
+	data, _, err := dedup.ReparseData(record)
+	if err != nil {
+		return nil, err
+	}
+	ref, err := dedup.ParseReparseData(data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse reparse data: %v", err)
+	}
+	r, err := store.NewReader(ref)
+	if err != nil {
+		return nil, err
+	}
+	return io.NewSectionReader(r, 0, r.Size()), nil
+}
+
+// contextReader is an io.Reader that fails with ctx.Err() once ctx is done.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
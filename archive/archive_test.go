@@ -0,0 +1,328 @@
+package archive_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/archive"
+	"github.com/t9t/gomft/dedup"
+	"github.com/t9t/gomft/entropy"
+	"github.com/t9t/gomft/export"
+	"github.com/t9t/gomft/hashdeep"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/pipeline"
+)
+
+var (
+	created  = time.Date(2020, time.February, 5, 14, 59, 38, 116886200, time.UTC)
+	modified = time.Date(2020, time.March, 1, 8, 0, 0, 500000000, time.UTC)
+	changed  = time.Date(2020, time.March, 2, 9, 30, 0, 0, time.UTC)
+	accessed = time.Date(2020, time.April, 3, 10, 45, 0, 0, time.UTC)
+)
+
+func testItem() (*pipeline.Item, []byte) {
+	volume := make([]byte, 4*512)
+	copy(volume[2*512:], "non-resident data")
+	item := &pipeline.Item{
+		Path: "/Users/bob/setup.exe",
+		Entry: export.Entry{
+			Creation:         created,
+			FileLastModified: modified,
+			MftLastModified:  changed,
+			LastAccess:       accessed,
+		},
+		Record: mft.Record{Attributes: []mft.Attribute{
+			{Type: mft.AttributeTypeFileName, Resident: true, Data: []byte("name")},
+			{Type: mft.AttributeTypeData, Resident: false, Data: []byte{0x11, 0x01, 0x02, 0x00}, ActualSize: 17},
+			{Type: mft.AttributeTypeData, Name: "Zone.Identifier", Resident: true, Data: []byte("[ZoneTransfer]")},
+		}},
+	}
+	return item, volume
+}
+
+func TestStage_Zip(t *testing.T) {
+	item, volume := testItem()
+	buf := &bytes.Buffer{}
+	w := archive.NewZipWriter(buf)
+	opts := archive.Options{Volume: bytes.NewReader(volume), BytesPerCluster: 512, Streams: true}
+	keep, err := archive.Stage(w, opts).Process(item)
+	require.Nilf(t, err, "unable to process item: %v", err)
+	assert.True(t, keep)
+	require.Nil(t, w.Close())
+
+	r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.Nilf(t, err, "unable to read zip: %v", err)
+	require.Len(t, r.File, 2)
+	assert.Equal(t, "Users/bob/setup.exe", r.File[0].Name)
+	assert.Equal(t, "non-resident data", readZipFile(t, r.File[0]))
+	assert.Equal(t, modified.Truncate(time.Second), r.File[0].Modified.UTC()) // from the extended timestamp field
+	assert.Equal(t, "Users/bob/setup.exe:Zone.Identifier", r.File[1].Name)
+	assert.Equal(t, "[ZoneTransfer]", readZipFile(t, r.File[1]))
+
+	extra := r.File[0].Extra
+	i := bytes.Index(extra, []byte{0x0a, 0x00, 0x20, 0x00})
+	require.True(t, i >= 0, "NTFS extra field not found")
+	ntfs := extra[i+4 : i+36]
+	assert.Equal(t, mft.ConvertToFileTime(modified), binary.LittleEndian.Uint64(ntfs[8:]))
+	assert.Equal(t, mft.ConvertToFileTime(accessed), binary.LittleEndian.Uint64(ntfs[16:]))
+	assert.Equal(t, mft.ConvertToFileTime(created), binary.LittleEndian.Uint64(ntfs[24:]))
+}
+
+func readZipFile(t *testing.T, f *zip.File) string {
+	rc, err := f.Open()
+	require.Nil(t, err)
+	defer rc.Close()
+	b, err := ioutil.ReadAll(rc)
+	require.Nil(t, err)
+	return string(b)
+}
+
+func TestStage_Tar(t *testing.T) {
+	item, volume := testItem()
+	buf := &bytes.Buffer{}
+	w := archive.NewTarWriter(buf)
+	keep, err := archive.Stage(w, archive.Options{Volume: bytes.NewReader(volume), BytesPerCluster: 512}).Process(item)
+	require.Nilf(t, err, "unable to process item: %v", err)
+	assert.True(t, keep)
+	require.Nil(t, w.Close())
+
+	r := tar.NewReader(buf)
+	h, err := r.Next()
+	require.Nilf(t, err, "unable to read tar: %v", err)
+	assert.Equal(t, "Users/bob/setup.exe", h.Name)
+	assert.True(t, modified.Equal(h.ModTime))
+	assert.True(t, accessed.Equal(h.AccessTime))
+	assert.True(t, changed.Equal(h.ChangeTime))
+	assert.Equal(t, "1580914778.1168862", h.PAXRecords["LIBARCHIVE.creationtime"])
+	b, err := ioutil.ReadAll(r)
+	require.Nil(t, err)
+	assert.Equal(t, "non-resident data", string(b))
+
+	// Alternate data streams are not stored without Options.Streams
+	_, err = r.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestStage_Sparse(t *testing.T) {
+	item, volume := testItem()
+	// A sparse cluster followed by cluster 2
+	item.Record.Attributes[1].Flags = mft.AttributeFlagsSparse
+	item.Record.Attributes[1].Data = []byte{0x01, 0x01, 0x11, 0x01, 0x02, 0x00}
+	item.Record.Attributes[1].ActualSize = 529
+	buf := &bytes.Buffer{}
+	w := archive.NewTarWriter(buf)
+	keep, err := archive.Stage(w, archive.Options{Volume: bytes.NewReader(volume), BytesPerCluster: 512}).Process(item)
+	require.Nilf(t, err, "unable to process item: %v", err)
+	assert.True(t, keep)
+	require.Nil(t, w.Close())
+
+	r := tar.NewReader(buf)
+	_, err = r.Next()
+	require.Nilf(t, err, "unable to read tar: %v", err)
+	b, err := ioutil.ReadAll(r)
+	require.Nil(t, err)
+	assert.Equal(t, append(make([]byte, 512), "non-resident data"...), b)
+}
+
+func TestStage_Errors(t *testing.T) {
+	item := &pipeline.Item{Path: "/file", Record: mft.Record{Attributes: []mft.Attribute{
+		{Type: mft.AttributeTypeData, Resident: false, Data: []byte{0x11, 0x01, 0x02, 0x00}, ActualSize: 8},
+		{Type: mft.AttributeTypeData, Name: "ok", Resident: true, Data: []byte("ok")},
+	}}}
+	var errs []string
+	opts := archive.Options{Streams: true, ErrorHandler: func(item *pipeline.Item, stream string, err error) {
+		errs = append(errs, stream+": "+err.Error())
+	}}
+	buf := &bytes.Buffer{}
+	w := archive.NewTarWriter(buf)
+	keep, err := archive.Stage(w, opts).Process(item)
+	require.Nil(t, err)
+	assert.True(t, keep)
+
+	item.Path = ""
+	keep, err = archive.Stage(w, opts).Process(item)
+	require.Nil(t, err)
+	assert.True(t, keep)
+	assert.Equal(t, []string{
+		": the attribute is non-resident, but no volume is available",
+		": the file has no path",
+		"ok: the file has no path",
+	}, errs)
+
+	require.Nil(t, w.Close())
+	h, err := tar.NewReader(buf).Next()
+	require.Nil(t, err)
+	assert.Equal(t, "file:ok", h.Name)
+}
+
+func TestStage_Deduplicated(t *testing.T) {
+	reparse := make([]byte, 8)
+	binary.LittleEndian.PutUint32(reparse, uint32(mft.ReparseTagDedup))
+	item := &pipeline.Item{Path: "/file", Record: mft.Record{Attributes: []mft.Attribute{
+		{Type: mft.AttributeTypeReparsePoint, Resident: true, Data: reparse},
+		{Type: mft.AttributeTypeData, Resident: false, Flags: mft.AttributeFlagsSparse, ActualSize: 8},
+		{Type: mft.AttributeTypeData, Name: "ok", Resident: true, Data: []byte("ok")},
+	}}}
+	var errs []error
+	opts := archive.Options{Streams: true, ErrorHandler: func(item *pipeline.Item, stream string, err error) {
+		errs = append(errs, err)
+	}}
+	w := archive.NewTarWriter(&bytes.Buffer{})
+	_, err := archive.Stage(w, opts).Process(item)
+	require.Nil(t, err)
+	assert.Equal(t, []error{dedup.ErrChunkStore}, errs)
+}
+
+func TestStage_DeduplicatedChunkStore(t *testing.T) {
+	contents := []byte("deduplicated contents")
+	// A container with the data of the file as one chunk at offset 16, followed by its stream map at offset 125
+	c := append([]byte("Cthr"), make([]byte, 12)...)
+	c = append(c, dedupChunk(contents)...)
+	streamMap := make([]byte, 0x50)
+	copy(streamMap, "Smap")
+	binary.LittleEndian.PutUint32(streamMap[0x08:], 1)
+	binary.LittleEndian.PutUint32(streamMap[0x10:], 1)
+	binary.LittleEndian.PutUint32(streamMap[0x14:], 1)
+	binary.LittleEndian.PutUint64(streamMap[0x18:], 16)
+	binary.LittleEndian.PutUint32(streamMap[0x28:], uint32(len(contents)))
+	c = append(c, dedupChunk(streamMap)...)
+	store := dedup.NewChunkStore(func(name string) (*io.SectionReader, error) {
+		return io.NewSectionReader(bytes.NewReader(c), 0, int64(len(c))), nil
+	})
+
+	reparse := make([]byte, 8+0x38)
+	binary.LittleEndian.PutUint32(reparse, uint32(mft.ReparseTagDedup))
+	binary.LittleEndian.PutUint16(reparse[4:], 0x38)
+	binary.LittleEndian.PutUint64(reparse[8+0x08:], uint64(len(contents)))
+	binary.LittleEndian.PutUint32(reparse[8+0x28:], 1)
+	binary.LittleEndian.PutUint32(reparse[8+0x2C:], 1)
+	binary.LittleEndian.PutUint64(reparse[8+0x30:], 125)
+	item := &pipeline.Item{Path: "/file", Record: mft.Record{Attributes: []mft.Attribute{
+		{Type: mft.AttributeTypeReparsePoint, Resident: true, Data: reparse},
+		{Type: mft.AttributeTypeData, Resident: false, Flags: mft.AttributeFlagsSparse, ActualSize: uint64(len(contents))},
+	}}}
+
+	buf := &bytes.Buffer{}
+	w := archive.NewTarWriter(buf)
+	_, err := archive.Stage(w, archive.Options{ChunkStore: store}).Process(item)
+	require.Nilf(t, err, "unable to process item: %v", err)
+	require.Nil(t, w.Close())
+	r := tar.NewReader(buf)
+	h, err := r.Next()
+	require.Nil(t, err)
+	assert.Equal(t, "file", h.Name)
+	b, err := ioutil.ReadAll(r)
+	require.Nil(t, err)
+	assert.Equal(t, contents, b)
+}
+
+// dedupChunk creates an uncompressed chunk of a Data Deduplication container.
+func dedupChunk(data []byte) []byte {
+	h := make([]byte, 0x58)
+	copy(h, "Ckhr")
+	binary.LittleEndian.PutUint32(h[0x0C:], uint32(len(data)))
+	binary.LittleEndian.PutUint32(h[0x10:], uint32(len(data)))
+	return append(h, data...)
+}
+
+func TestStage_WriteError(t *testing.T) {
+	item := &pipeline.Item{Path: "/file", Record: mft.Record{Attributes: []mft.Attribute{
+		{Type: mft.AttributeTypeData, Resident: true, Data: []byte(strings.Repeat("data", 1024))},
+	}}}
+	w := archive.NewTarWriter(failingWriter{})
+	_, err := archive.Stage(w, archive.Options{}).Process(item)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "disk full")
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestStage_Manifest(t *testing.T) {
+	item, volume := testItem()
+	manifest := &bytes.Buffer{}
+	m, err := hashdeep.NewWriter(manifest, hashdeep.FormatHashdeep, "md5")
+	require.Nil(t, err)
+	w := archive.NewTarWriter(&bytes.Buffer{})
+	opts := archive.Options{Volume: bytes.NewReader(volume), BytesPerCluster: 512, Streams: true, Manifest: m}
+	_, err = archive.Stage(w, opts).Process(item)
+	require.Nilf(t, err, "unable to process item: %v", err)
+	require.Nil(t, w.Close())
+	require.Nil(t, m.Flush())
+	assert.Equal(t, "%%%% HASHDEEP-1.0\n%%%% size,md5,filename\n## \n"+
+		"17,33576b839820dbc596c29cc817fd032a,Users/bob/setup.exe\n"+
+		"14,725027fcb8bf9d7d80edae0e9e9139a4,Users/bob/setup.exe:Zone.Identifier\n", manifest.String())
+}
+
+func TestStage_Observer(t *testing.T) {
+	item, volume := testItem()
+	item.Entry.RecordNumber = 42
+	observer := &pipeline.Observer{}
+	var events []pipeline.Event
+	observer.Subscribe(func(e pipeline.Event) { events = append(events, e) })
+	w := archive.NewZipWriter(&bytes.Buffer{})
+	opts := archive.Options{Volume: bytes.NewReader(volume), BytesPerCluster: 512, Streams: true, Observer: observer}
+	_, err := archive.Stage(w, opts).Process(item)
+	require.Nilf(t, err, "unable to process item: %v", err)
+	require.Nil(t, w.Close())
+	assert.Equal(t, []pipeline.Event{
+		pipeline.FileExtracted{RecordNumber: 42, Path: "/Users/bob/setup.exe", Size: 17},
+		pipeline.FileExtracted{RecordNumber: 42, Path: "/Users/bob/setup.exe", Stream: "Zone.Identifier", Size: 14},
+	}, events)
+}
+
+func TestStage_Entropy(t *testing.T) {
+	item, volume := testItem()
+	item.Entry.AlternateDataStreams = []export.Stream{{Name: "Zone.Identifier", Size: 14}}
+	observer := &pipeline.Observer{}
+	var events []pipeline.Event
+	observer.Subscribe(func(e pipeline.Event) { events = append(events, e) })
+	w := archive.NewZipWriter(&bytes.Buffer{})
+	opts := archive.Options{Volume: bytes.NewReader(volume), BytesPerCluster: 512, Streams: true, Entropy: true,
+		Observer: observer}
+	_, err := archive.Stage(w, opts).Process(item)
+	require.Nilf(t, err, "unable to process item: %v", err)
+	require.Nil(t, w.Close())
+
+	data := entropy.Shannon([]byte("non-resident data"))
+	zone := entropy.Shannon([]byte("[ZoneTransfer]"))
+	assert.True(t, item.Entry.HasEntropy)
+	assert.Equal(t, data, item.Entry.Entropy)
+	assert.Equal(t, []export.Stream{{Name: "Zone.Identifier", Size: 14, Entropy: zone, HasEntropy: true}},
+		item.Entry.AlternateDataStreams)
+	assert.Equal(t, []pipeline.Event{
+		pipeline.FileExtracted{Path: "/Users/bob/setup.exe", Size: 17, Entropy: data, HasEntropy: true},
+		pipeline.FileExtracted{Path: "/Users/bob/setup.exe", Stream: "Zone.Identifier", Size: 14, Entropy: zone,
+			HasEntropy: true},
+	}, events)
+}
+
+func TestStageContext(t *testing.T) {
+	item := &pipeline.Item{Path: "/file", Record: mft.Record{Attributes: []mft.Attribute{
+		{Type: mft.AttributeTypeData, Resident: true, Data: []byte("data")},
+	}}}
+	ctx, cancel := context.WithCancel(context.Background())
+	w := archive.NewZipWriter(&bytes.Buffer{})
+	stage := archive.StageContext(ctx, w, archive.Options{})
+	keep, err := stage.Process(item)
+	require.Nilf(t, err, "unable to process item: %v", err)
+	assert.True(t, keep)
+
+	cancel()
+	_, err = stage.Process(item)
+	assert.Equal(t, context.Canceled, err)
+}
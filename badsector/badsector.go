@@ -0,0 +1,359 @@
+/*
+	Package badsector reports which files are affected by unreadable areas of a volume, and where in those files the
+	lost data lies. Given the bad sectors found while imaging or reading a volume, this tells which files can be
+	recovered intact and which need attention (or another copy), so recovery can be prioritized.
+
+	Basic usage
+
+	Collect the unreadable byte ranges, either from a list written by an external tool (ParseList, or ParseMapfile for
+	a GNU ddrescue mapfile) or from the pipeline.BadSector Events published while reading the volume (RangeOf). Then
+	add all records of the MFT to a Mapper and ask for the Impacts of the ranges.
+			// Error handling left out for brevity
+			f, err := os.Open("sdb1.map")
+			ranges, err := badsector.ParseMapfile(f)
+			m := badsector.NewMapper(4096, badsector.Options{})
+			for result := range mft.ParseAll(in, mft.ParseAllOptions{SkipEmpty: true}) {
+				if result.Err == nil {
+					err = m.Add(uint64(result.Index), result.Record)
+				}
+			}
+			for _, i := range m.Impacts(ranges) {
+				fmt.Printf("record %d stream %q: %d bytes at offset %d\n", i.Reference.RecordNumber, i.Name,
+					i.Length, i.Offset)
+			}
+
+	Implementation notes
+
+	Like in the crosslink package, each non-resident attribute is converted into extents (ranges of clusters) using
+	mft.DataRunExtents, which leaves out sparse runs. Each extent also keeps its position within the attribute (its
+	virtual cluster number), so an unreadable range on the volume translates into a logical Offset in the stream.
+	Resident attributes are stored in the MFT itself, so they are not affected by bad sectors elsewhere; a bad sector
+	in the MFT shows up as records which cannot be read instead.
+
+	Attributes in extension records are attributed to their base record. The header of such a part of an attribute
+	contains the virtual cluster number it starts at, which is not kept by the mft package, so the Offset of an Impact
+	on such a part is relative to the start of that part; these Impacts are marked as Extension. Likewise, the size of
+	the stream is only known from the first part, so only Impacts on that part can be marked as Slack.
+
+	By default, only records which are in use are considered, since the clusters of deleted files may have been
+	reused. Set IncludeDeleted to also report the deleted files whose data is affected.
+*/
+package badsector
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/pipeline"
+)
+
+// Range is a range of unreadable bytes on a volume.
+type Range struct {
+	Offset int64
+	Length int64
+}
+
+// End returns the offset following the last byte of the Range.
+func (r Range) End() int64 {
+	return r.Offset + r.Length
+}
+
+// RangeOf returns the Range of a BadSector Event. Since the Length of such an Event may be unknown (zero), the Range
+// then covers only the byte at its Offset.
+func RangeOf(e pipeline.BadSector) Range {
+	if e.Length <= 0 {
+		return Range{Offset: e.Offset, Length: 1}
+	}
+	return Range{Offset: e.Offset, Length: e.Length}
+}
+
+// Merge returns the ranges ordered by their offset, with overlapping and adjacent ranges merged into one. Ranges
+// which are empty are left out.
+func Merge(ranges []Range) []Range {
+	sorted := make([]Range, 0, len(ranges))
+	for _, r := range ranges {
+		if r.Length > 0 {
+			sorted = append(sorted, r)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Offset < sorted[j].Offset
+	})
+
+	merged := make([]Range, 0, len(sorted))
+	for _, r := range sorted {
+		if n := len(merged); n > 0 && r.Offset <= merged[n-1].End() {
+			if r.End() > merged[n-1].End() {
+				merged[n-1].Length = r.End() - merged[n-1].Offset
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// ParseList parses a list of ranges, one per line, each consisting of an offset and a length in bytes separated by
+// whitespace. Numbers are decimal, or hexadecimal when prefixed with 0x. Empty lines and lines starting with # are
+// ignored.
+func ParseList(r io.Reader) ([]Range, error) {
+	ranges := make([]Range, 0)
+	err := parseLines(r, func(line int, fields []string) error {
+		if len(fields) != 2 {
+			return fmt.Errorf("expected an offset and a length on line %d but got %d fields", line, len(fields))
+		}
+		rng, err := parseRange(line, fields[0], fields[1])
+		if err != nil {
+			return err
+		}
+		ranges = append(ranges, rng)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ranges, nil
+}
+
+// ParseMapfile parses a mapfile written by GNU ddrescue, returning the ranges which were not read successfully: those
+// with any status other than "+" (finished), which includes bad sectors ("-") as well as areas not tried or not
+// completely read yet.
+func ParseMapfile(r io.Reader) ([]Range, error) {
+	ranges := make([]Range, 0)
+	statusLine := true
+	err := parseLines(r, func(line int, fields []string) error {
+		if statusLine {
+			// The first line holds the current position and status of ddrescue
+			statusLine = false
+			return nil
+		}
+		if len(fields) != 3 {
+			return fmt.Errorf("expected a position, size and status on line %d but got %d fields", line, len(fields))
+		}
+		if fields[2] == "+" {
+			return nil
+		}
+		rng, err := parseRange(line, fields[0], fields[1])
+		if err != nil {
+			return err
+		}
+		ranges = append(ranges, rng)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ranges, nil
+}
+
+// parseLines calls f with the (1-based) line number and the whitespace separated fields of each line, skipping empty
+// lines and comments.
+func parseLines(r io.Reader, f func(line int, fields []string) error) error {
+	s := bufio.NewScanner(r)
+	line := 0
+	for s.Scan() {
+		line++
+		text := strings.TrimSpace(s.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if err := f(line, strings.Fields(text)); err != nil {
+			return err
+		}
+	}
+	if err := s.Err(); err != nil {
+		return fmt.Errorf("unable to read ranges: %v", err)
+	}
+	return nil
+}
+
+func parseRange(line int, offset string, length string) (Range, error) {
+	o, err := strconv.ParseInt(offset, 0, 64)
+	if err != nil || o < 0 {
+		return Range{}, fmt.Errorf("invalid offset %q on line %d", offset, line)
+	}
+	l, err := strconv.ParseInt(length, 0, 64)
+	if err != nil || l < 0 {
+		return Range{}, fmt.Errorf("invalid length %q on line %d", length, line)
+	}
+	return Range{Offset: o, Length: l}, nil
+}
+
+// Options control which records a Mapper considers.
+type Options struct {
+	// IncludeDeleted also considers the data runs of records which are not in use.
+	IncludeDeleted bool
+}
+
+// Impact describes the part of a stream (a non-resident attribute) that lies in an unreadable Range. The Reference is
+// that of the base record, also for attributes found in extension records.
+type Impact struct {
+	Reference mft.FileReference
+	Type      mft.AttributeType
+	Name      string
+	// Offset is the logical offset in bytes of the unreadable part within the stream, and Length its size.
+	Offset int64
+	Length int64
+	// VolumeOffset is the offset in bytes of the unreadable part on the volume.
+	VolumeOffset int64
+	// Slack indicates that the unreadable part lies beyond the end of the data of the stream (in the remainder of its
+	// last cluster), so no data of the file is lost.
+	Slack bool
+	// Extension indicates that the part of the attribute was found in an extension record, so the Offset is
+	// relative to the start of that part rather than to the start of the stream (see the package documentation).
+	Extension bool
+}
+
+func (i Impact) String() string {
+	s := fmt.Sprintf("record %d %s %q: %d bytes at offset %d (volume offset %d)", i.Reference.RecordNumber,
+		i.Type.Name(), i.Name, i.Length, i.Offset, i.VolumeOffset)
+	if i.Slack {
+		s += ", in slack space"
+	}
+	return s
+}
+
+// extent is a contiguous range of clusters occupied by an attribute, starting at virtual cluster vcn.
+type extent struct {
+	reference mft.FileReference
+	typ       mft.AttributeType
+	name      string
+	vcn       uint64
+	cluster   uint64
+	length    uint64
+	size      uint64 // the actual size of the stream, unknown for extensions
+	extension bool
+}
+
+// Mapper collects the extents of records to map unreadable ranges of a volume to the streams occupying them. A
+// Mapper is not safe for concurrent use.
+type Mapper struct {
+	bytesPerCluster int64
+	opts            Options
+	extents         []extent
+	sorted          bool
+}
+
+// NewMapper creates an empty Mapper for a volume with the cluster size in bytes.
+func NewMapper(bytesPerCluster int, opts Options) *Mapper {
+	return &Mapper{bytesPerCluster: int64(bytesPerCluster), opts: opts}
+}
+
+// Add adds the locations of the non-resident attributes of a record to the Mapper, so unreadable ranges can be mapped
+// to them. The number is the record number, the position of the record in the MFT. Records which are not in use are
+// ignored unless IncludeDeleted is set. When the data runs of an attribute cannot be parsed, an error is returned after
+// adding the other attributes.
+func (m *Mapper) Add(number uint64, r mft.Record) error {
+	if !r.IsInUse() && !m.opts.IncludeDeleted {
+		return nil
+	}
+	reference := mft.FileReference{RecordNumber: number, SequenceNumber: r.FileReference.SequenceNumber}
+	extension := r.IsExtension()
+	if extension {
+		reference = r.BaseRecordReference
+	}
+
+	var firstErr error
+	for _, a := range r.Attributes {
+		if a.Resident {
+			continue
+		}
+		runs, err := mft.ParseDataRuns(a.Data)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("unable to parse data runs of %s attribute %q of record %d: %v", a.Type.Name(), a.Name, number, err)
+			}
+			continue
+		}
+		e := extent{reference: reference, typ: a.Type, name: a.Name, extension: extension}
+		if !extension {
+			e.size = a.ActualSize
+		}
+		for _, x := range mft.DataRunExtents(runs) {
+			e.vcn, e.cluster, e.length = x.Vcn, x.Cluster, x.Length
+			m.extents = append(m.extents, e)
+		}
+		m.sorted = false
+	}
+	return firstErr
+}
+
+// Impacts returns the parts of streams lying in the unreadable ranges, ordered by their offset on the volume. A part
+// that lies partly in the slack space of a stream is split into an Impact on its data and one on its slack.
+func (m *Mapper) Impacts(ranges []Range) []Impact {
+	if !m.sorted {
+		sort.SliceStable(m.extents, func(i, j int) bool {
+			return m.extents[i].cluster < m.extents[j].cluster
+		})
+		m.sorted = true
+	}
+
+	impacts := make([]Impact, 0)
+	active := make([]extent, 0)
+	next := 0
+	for _, r := range Merge(ranges) {
+		// Keep the extents which have started before the end of the range and have not ended before its start; since
+		// the ranges are ordered and do not overlap, dropped extents are not needed for later ranges either
+		for next < len(m.extents) && int64(m.extents[next].cluster)*m.bytesPerCluster < r.End() {
+			active = append(active, m.extents[next])
+			next++
+		}
+		n := 0
+		for _, e := range active {
+			if int64(e.cluster+e.length)*m.bytesPerCluster > r.Offset {
+				active[n] = e
+				n++
+			}
+		}
+		active = active[:n]
+
+		for _, e := range active {
+			start := int64(e.cluster) * m.bytesPerCluster
+			end := start + int64(e.length)*m.bytesPerCluster
+			if start < r.Offset {
+				start = r.Offset
+			}
+			if end > r.End() {
+				end = r.End()
+			}
+			impacts = append(impacts, e.impacts(start, end, m.bytesPerCluster)...)
+		}
+	}
+	sort.SliceStable(impacts, func(i, j int) bool {
+		return impacts[i].VolumeOffset < impacts[j].VolumeOffset
+	})
+	return impacts
+}
+
+// impacts returns the Impacts of the unreadable bytes from start to end on the volume, which lie within the extent.
+func (e extent) impacts(start int64, end int64, bytesPerCluster int64) []Impact {
+	i := Impact{
+		Reference:    e.reference,
+		Type:         e.typ,
+		Name:         e.name,
+		Offset:       int64(e.vcn)*bytesPerCluster + start - int64(e.cluster)*bytesPerCluster,
+		Length:       end - start,
+		VolumeOffset: start,
+		Extension:    e.extension,
+	}
+	size := int64(e.size)
+	if e.extension || i.Offset+i.Length <= size {
+		return []Impact{i}
+	}
+	if i.Offset >= size {
+		i.Slack = true
+		return []Impact{i}
+	}
+	slack := i
+	slack.Slack = true
+	slack.Offset = size
+	slack.Length = i.Offset + i.Length - size
+	slack.VolumeOffset = i.VolumeOffset + size - i.Offset
+	i.Length = size - i.Offset
+	return []Impact{i, slack}
+}
@@ -0,0 +1,113 @@
+package badsector_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/badsector"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/mfttest"
+	"github.com/t9t/gomft/pipeline"
+)
+
+func TestMapper(t *testing.T) {
+	m := badsector.NewMapper(4096, badsector.Options{})
+	add := func(number uint64, b *mfttest.RecordBuilder) {
+		require.Nil(t, m.Add(number, b.Record()))
+	}
+	// 100-109 and 110-119 (merged), sparse, 200-204 (the last 1000 bytes of which are slack)
+	add(40, mfttest.File(75*4096-1000, mfttest.Run(100, 10), mfttest.Run(10, 10), mfttest.SparseRun(50), mfttest.Run(90, 5)))
+	// Deleted file claiming 105-107
+	add(41, mfttest.File(3*4096, mfttest.Run(105, 3)).WithFlags(0))
+	// 300-309, in an extension record of 43
+	add(44, mfttest.File(0, mfttest.Run(300, 10)).WithBaseRecord(mfttest.Reference(43)))
+	// A resident stream is never affected
+	add(45, mfttest.NewRecord().WithResidentData([]byte("data")))
+
+	impacts := m.Impacts([]badsector.Range{
+		{Offset: 301 * 4096, Length: 4096},
+		{Offset: 105*4096 + 10, Length: 20},
+		{Offset: 204*4096 + 3000, Length: 2000},
+		{Offset: 50 * 4096, Length: 10},
+	})
+	assert.Equal(t, []badsector.Impact{
+		{Reference: mfttest.Reference(40), Type: mft.AttributeTypeData, Offset: 5*4096 + 10, Length: 20, VolumeOffset: 105*4096 + 10},
+		{Reference: mfttest.Reference(40), Type: mft.AttributeTypeData, Offset: 74*4096 + 3000, Length: 96, VolumeOffset: 204*4096 + 3000},
+		{Reference: mfttest.Reference(40), Type: mft.AttributeTypeData, Offset: 75*4096 - 1000, Length: 1000, VolumeOffset: 204*4096 + 3096,
+			Slack: true},
+		{Reference: mfttest.Reference(43), Type: mft.AttributeTypeData, Offset: 4096, Length: 4096, VolumeOffset: 301 * 4096,
+			Extension: true},
+	}, impacts)
+	assert.Equal(t, `record 40 $DATA "": 1000 bytes at offset 306200 (volume offset 838680), in slack space`, impacts[2].String())
+}
+
+func TestMapper_IncludeDeleted(t *testing.T) {
+	m := badsector.NewMapper(512, badsector.Options{IncludeDeleted: true})
+	require.Nil(t, m.Add(41, mfttest.File(3*512, mfttest.Run(105, 3)).WithFlags(0).Record()))
+	assert.Equal(t, []badsector.Impact{
+		{Reference: mfttest.Reference(41), Type: mft.AttributeTypeData, Offset: 512, Length: 512, VolumeOffset: 106 * 512},
+	}, m.Impacts([]badsector.Range{{Offset: 106 * 512, Length: 512}}))
+}
+
+func TestMapper_LeadingSparse(t *testing.T) {
+	m := badsector.NewMapper(4096, badsector.Options{})
+	// A hole of 16 clusters, then 300-303; a bad boot sector does not affect the hole
+	require.Nil(t, m.Add(40, mfttest.File(20*4096, mfttest.SparseRun(16), mfttest.Run(300, 4)).Record()))
+	assert.Empty(t, m.Impacts([]badsector.Range{{Offset: 0, Length: 512}}))
+	assert.Equal(t, []badsector.Impact{
+		{Reference: mfttest.Reference(40), Type: mft.AttributeTypeData, Offset: 16 * 4096, Length: 512, VolumeOffset: 300 * 4096},
+	}, m.Impacts([]badsector.Range{{Offset: 300 * 4096, Length: 512}}))
+}
+
+func TestMapper_InvalidDataRuns(t *testing.T) {
+	m := badsector.NewMapper(4096, badsector.Options{})
+	r := mfttest.NewRecord().WithAttribute(mft.Attribute{Type: mft.AttributeTypeData, Data: []byte{0x88, 0x01}}).Record()
+	assert.EqualError(t, m.Add(7, r), `unable to parse data runs of $DATA attribute "" of record 7: expected at least 17 bytes of datarun data but is 8`)
+}
+
+func TestMerge(t *testing.T) {
+	assert.Equal(t, []badsector.Range{{Offset: 0, Length: 30}, {Offset: 40, Length: 5}}, badsector.Merge([]badsector.Range{
+		{Offset: 40, Length: 5}, {Offset: 10, Length: 20}, {Offset: 0, Length: 10}, {Offset: 15, Length: 5}, {Offset: 100},
+	}))
+}
+
+func TestRangeOf(t *testing.T) {
+	err := errors.New("I/O error")
+	assert.Equal(t, badsector.Range{Offset: 512, Length: 1024}, badsector.RangeOf(pipeline.BadSector{Offset: 512, Length: 1024, Err: err}))
+	assert.Equal(t, badsector.Range{Offset: 512, Length: 1}, badsector.RangeOf(pipeline.BadSector{Offset: 512, Err: err}))
+}
+
+func TestParseList(t *testing.T) {
+	ranges, err := badsector.ParseList(strings.NewReader("# offset length\n\n1024 512\n0x1000\t0x200\n"))
+	require.Nil(t, err)
+	assert.Equal(t, []badsector.Range{{Offset: 1024, Length: 512}, {Offset: 4096, Length: 512}}, ranges)
+
+	_, err = badsector.ParseList(strings.NewReader("1024\n"))
+	assert.EqualError(t, err, "expected an offset and a length on line 1 but got 1 fields")
+	_, err = badsector.ParseList(strings.NewReader("1024 512\n-1 512\n"))
+	assert.EqualError(t, err, `invalid offset "-1" on line 2`)
+	_, err = badsector.ParseList(strings.NewReader("1024 x\n"))
+	assert.EqualError(t, err, `invalid length "x" on line 1`)
+}
+
+func TestParseMapfile(t *testing.T) {
+	mapfile := `# Mapfile. Created by GNU ddrescue version 1.25
+# Command line: ddrescue /dev/sdb1 sdb1.img sdb1.map
+# current_pos  current_status  current_pass
+0x00120000     +               1
+#      pos        size  status
+0x00000000  0x00100000  +
+0x00100000  0x00000200  -
+0x00100200  0x0001FE00  +
+0x00120000  0x00010000  ?
+`
+	ranges, err := badsector.ParseMapfile(strings.NewReader(mapfile))
+	require.Nil(t, err)
+	assert.Equal(t, []badsector.Range{{Offset: 0x100000, Length: 0x200}, {Offset: 0x120000, Length: 0x10000}}, ranges)
+
+	_, err = badsector.ParseMapfile(strings.NewReader("0x0 +\n0x0 0x200\n"))
+	assert.EqualError(t, err, "expected a position, size and status on line 2 but got 2 fields")
+}
@@ -0,0 +1,42 @@
+package binutil
+
+import "sync"
+
+// BufferPool is a pool of byte slices of a fixed size, backed by a sync.Pool. Reusing buffers instead of allocating a
+// new one for every read reduces the pressure on the garbage collector when processing large amounts of data, such as
+// millions of MFT records.
+type BufferPool struct {
+	size int
+	pool sync.Pool
+}
+
+// NewBufferPool creates a BufferPool which hands out byte slices of the specified size.
+func NewBufferPool(size int) *BufferPool {
+	p := &BufferPool{size: size}
+	p.pool.New = func() interface{} {
+		b := make([]byte, size)
+		return &b
+	}
+	return p
+}
+
+// Size returns the length of the byte slices handed out by the BufferPool.
+func (p *BufferPool) Size() int {
+	return p.size
+}
+
+// Get returns a byte slice of the pool's size. The contents of the slice are undefined; it may contain data from a
+// previous use.
+func (p *BufferPool) Get() []byte {
+	return *(p.pool.Get().(*[]byte))
+}
+
+// Put returns a byte slice obtained from Get to the pool, so it can be reused. The slice must not be used anymore
+// afterwards. Slices with a capacity different from the pool's size are ignored.
+func (p *BufferPool) Put(b []byte) {
+	if cap(b) != p.size {
+		return
+	}
+	b = b[:p.size]
+	p.pool.Put(&b)
+}
@@ -0,0 +1,32 @@
+package binutil_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/t9t/gomft/binutil"
+)
+
+func TestBufferPool(t *testing.T) {
+	p := binutil.NewBufferPool(16)
+	assert.Equal(t, 16, p.Size())
+
+	b := p.Get()
+	assert.Len(t, b, 16)
+	p.Put(b[:4])
+	assert.Len(t, p.Get(), 16)
+
+	// Slices of another size are not pooled
+	p.Put(make([]byte, 8))
+	assert.Len(t, p.Get(), 16)
+}
+
+func BenchmarkBufferPool(b *testing.B) {
+	p := binutil.NewBufferPool(1024)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf := p.Get()
+		buf[0] = byte(i)
+		p.Put(buf)
+	}
+}
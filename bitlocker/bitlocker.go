@@ -0,0 +1,500 @@
+/*
+	Package bitlocker decrypts BitLocker protected volumes on the fly. A Reader reads the plaintext of an encrypted
+	volume (or an image of it), so the NTFS volume inside can be read using the other packages of gomft as if it was
+	not encrypted.
+
+	Basic usage
+
+	First read the BitLocker metadata of the volume, then obtain the full volume encryption key (FVEK) using one of
+	the key protectors of the volume, such as the 48 digit recovery password or the startup key from a .BEK file. When
+	the FVEK itself is known (for example from a memory image), it can be used directly.
+			// Error handling left out for brevity
+			f, err := os.Open("bitlocker.img")
+			m, err := bitlocker.ReadMetadata(f)
+			fvek, err := m.UnlockWithRecoveryPassword("123456-123456-123456-123456-123456-123456-123456-123456")
+			r, err := bitlocker.NewReader(f, m, fvek)
+
+			boot := make([]byte, 512)
+			_, err = r.ReadAt(boot, 0)
+			bootSector, err := bootsect.Parse(boot)
+
+	Implementation notes
+
+	Only the metadata format of Windows 7 and later is supported, not that of Windows Vista. Volumes encrypted with
+	AES-CBC (Windows 8 and later) or AES-XTS (Windows 10 and later) are supported; volumes encrypted with the Elephant
+	diffuser (the default before Windows 8) are not.
+
+	The first sectors of the encrypted volume are replaced by the BitLocker boot sector; the encrypted originals are
+	stored elsewhere, at the HeaderOffset, and a Reader reads them from there. The areas of the FVE metadata blocks and
+	of the relocated header read as zeroes. Sectors beyond EncryptedSize (when encryption or decryption of the volume is
+	in progress) are read as they are.
+
+	The keys of the key protectors and the FVEK are encrypted using AES-CCM, of which the message authentication code
+	is checked, so a wrong recovery password or startup key is reported as an error. Deriving the key from a recovery
+	password takes 1048576 iterations of SHA-256, which takes a noticeable amount of time by design.
+*/
+package bitlocker
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/utf16"
+)
+
+// Signature is the OEM ID in the boot sector of a BitLocker protected volume, and the signature of the FVE metadata
+// blocks.
+const Signature = "-FVE-FS-"
+
+// metadataBlockSize is the size of the area reserved for each FVE metadata block.
+const metadataBlockSize = 64 * 1024
+
+// EncryptionMethod is the cipher and mode with which the sectors of a volume are encrypted.
+type EncryptionMethod uint16
+
+// Encryption methods.
+const (
+	AES128Diffuser EncryptionMethod = 0x8000 // AES-CBC 128-bit with Elephant diffuser
+	AES256Diffuser EncryptionMethod = 0x8001 // AES-CBC 256-bit with Elephant diffuser
+	AES128CBC      EncryptionMethod = 0x8002
+	AES256CBC      EncryptionMethod = 0x8003
+	AES128XTS      EncryptionMethod = 0x8004
+	AES256XTS      EncryptionMethod = 0x8005
+)
+
+func (m EncryptionMethod) String() string {
+	switch m {
+	case AES128Diffuser:
+		return "AES-CBC 128-bit with diffuser"
+	case AES256Diffuser:
+		return "AES-CBC 256-bit with diffuser"
+	case AES128CBC:
+		return "AES-CBC 128-bit"
+	case AES256CBC:
+		return "AES-CBC 256-bit"
+	case AES128XTS:
+		return "AES-XTS 128-bit"
+	case AES256XTS:
+		return "AES-XTS 256-bit"
+	}
+	return fmt.Sprintf("unknown (0x%04x)", uint16(m))
+}
+
+// keyLength returns the length of the FVEK for the method, or 0 when the method is not supported.
+func (m EncryptionMethod) keyLength() int {
+	switch m {
+	case AES128CBC:
+		return 16
+	case AES256CBC, AES128XTS:
+		return 32
+	case AES256XTS:
+		return 64
+	}
+	return 0
+}
+
+// Protection is the way the volume master key (VMK) is protected by a KeyProtector.
+type Protection uint16
+
+// Protections of key protectors.
+const (
+	ProtectionClearKey         Protection = 0x0000 // the protection is suspended; the key is stored unencrypted
+	ProtectionTPM              Protection = 0x0100
+	ProtectionStartupKey       Protection = 0x0200 // the key is in a .BEK file, usually on a USB drive
+	ProtectionTPMAndPIN        Protection = 0x0500
+	ProtectionRecoveryPassword Protection = 0x0800
+	ProtectionPassword         Protection = 0x2000
+)
+
+func (p Protection) String() string {
+	switch p {
+	case ProtectionClearKey:
+		return "clear key"
+	case ProtectionTPM:
+		return "TPM"
+	case ProtectionStartupKey:
+		return "startup key"
+	case ProtectionTPMAndPIN:
+		return "TPM and PIN"
+	case ProtectionRecoveryPassword:
+		return "recovery password"
+	case ProtectionPassword:
+		return "password"
+	}
+	return fmt.Sprintf("unknown (0x%04x)", uint16(p))
+}
+
+// Types of metadata entries.
+const (
+	entryTypeVMK  = 0x0002
+	entryTypeFVEK = 0x0003
+)
+
+// Value types of metadata entries.
+const (
+	valueTypeKey         = 0x0001
+	valueTypeString      = 0x0002
+	valueTypeStretchKey  = 0x0003
+	valueTypeAESCCM      = 0x0005
+	valueTypeVMK         = 0x0008
+	valueTypeExternalKey = 0x0009
+)
+
+// Entry is an entry of the FVE metadata. Some values, such as those of key protectors, contain nested entries.
+type Entry struct {
+	Type      uint16
+	ValueType uint16
+	Version   uint16
+	Value     []byte
+}
+
+// Metadata is the FVE metadata of a BitLocker protected volume. All offsets and sizes are in bytes.
+type Metadata struct {
+	Version          uint16
+	SectorSize       int
+	VolumeID         string
+	EncryptionMethod EncryptionMethod
+	Created          time.Time
+	EncryptedSize    uint64    // the size of the part of the volume which is encrypted
+	HeaderOffset     uint64    // the location of the encrypted original first sectors of the volume
+	HeaderSectors    uint64    // the number of sectors at HeaderOffset
+	BlockOffsets     [3]uint64 // the locations of the three copies of the metadata
+	Entries          []Entry
+}
+
+// IsBitLocker indicates whether the boot sector is that of a BitLocker protected volume.
+func IsBitLocker(bootSector []byte) bool {
+	return len(bootSector) >= 0x0B && string(bootSector[0x03:0x0B]) == Signature
+}
+
+// ReadMetadata reads the boot sector and FVE metadata of a BitLocker protected volume. When the first metadata block
+// cannot be parsed, the other copies are tried.
+func ReadMetadata(volume io.ReaderAt) (Metadata, error) {
+	boot := make([]byte, 512)
+	if _, err := volume.ReadAt(boot, 0); err != nil {
+		return Metadata{}, fmt.Errorf("unable to read boot sector: %v", err)
+	}
+	if !IsBitLocker(boot) {
+		return Metadata{}, fmt.Errorf("boot sector does not have signature %q", Signature)
+	}
+	sectorSize := int(binary.LittleEndian.Uint16(boot[0x0B:]))
+	if sectorSize < 512 || sectorSize%16 != 0 {
+		return Metadata{}, fmt.Errorf("invalid sector size %d", sectorSize)
+	}
+	offsets := []uint64{binary.LittleEndian.Uint64(boot[0xB0:]), binary.LittleEndian.Uint64(boot[0xB8:]), binary.LittleEndian.Uint64(boot[0xC0:])}
+
+	var err error
+	for _, offset := range offsets {
+		if offset == 0 {
+			continue
+		}
+		var m Metadata
+		if m, err = readMetadataBlock(volume, offset); err == nil {
+			m.SectorSize = sectorSize
+			return m, nil
+		}
+		err = fmt.Errorf("unable to read metadata block at offset %d: %v", offset, err)
+	}
+	if err == nil {
+		err = fmt.Errorf("no metadata block offsets in boot sector (Windows Vista volumes are not supported)")
+	}
+	return Metadata{}, err
+}
+
+func readMetadataBlock(volume io.ReaderAt, offset uint64) (Metadata, error) {
+	const blockHeaderSize, headerSize = 64, 48
+	b := make([]byte, blockHeaderSize+headerSize)
+	if _, err := volume.ReadAt(b, int64(offset)); err != nil {
+		return Metadata{}, err
+	}
+	if string(b[:8]) != Signature {
+		return Metadata{}, fmt.Errorf("invalid signature %q", b[:8])
+	}
+	le := binary.LittleEndian
+	m := Metadata{
+		Version:       le.Uint16(b[0x0A:]),
+		EncryptedSize: le.Uint64(b[0x10:]),
+		HeaderSectors: uint64(le.Uint32(b[0x1C:])),
+		BlockOffsets:  [3]uint64{le.Uint64(b[0x20:]), le.Uint64(b[0x28:]), le.Uint64(b[0x30:])},
+		HeaderOffset:  le.Uint64(b[0x38:]),
+	}
+	if m.Version != 2 {
+		return Metadata{}, fmt.Errorf("unsupported version %d", m.Version)
+	}
+
+	h := b[blockHeaderSize:]
+	size := le.Uint32(h[0x00:])
+	if size < headerSize || size > metadataBlockSize-blockHeaderSize {
+		return Metadata{}, fmt.Errorf("invalid metadata size %d", size)
+	}
+	m.VolumeID = formatGUID(h[0x10:0x20])
+	m.EncryptionMethod = EncryptionMethod(le.Uint16(h[0x24:]))
+	m.Created = mft.ConvertFileTime(le.Uint64(h[0x28:]))
+
+	data := make([]byte, size-headerSize)
+	if _, err := volume.ReadAt(data, int64(offset)+blockHeaderSize+headerSize); err != nil {
+		return Metadata{}, fmt.Errorf("unable to read %d bytes of metadata entries: %v", len(data), err)
+	}
+	entries, err := ParseEntries(data)
+	if err != nil {
+		return Metadata{}, err
+	}
+	m.Entries = entries
+	return m, nil
+}
+
+// ParseEntries parses consecutive metadata entries. An entry with size 0 ends the list.
+func ParseEntries(b []byte) ([]Entry, error) {
+	entries := make([]Entry, 0)
+	for len(b) >= 2 {
+		size := int(binary.LittleEndian.Uint16(b))
+		if size == 0 {
+			break
+		}
+		if size < 8 || size > len(b) {
+			return nil, fmt.Errorf("invalid entry size %d with %d bytes remaining", size, len(b))
+		}
+		entries = append(entries, Entry{
+			Type:      binary.LittleEndian.Uint16(b[0x02:]),
+			ValueType: binary.LittleEndian.Uint16(b[0x04:]),
+			Version:   binary.LittleEndian.Uint16(b[0x06:]),
+			Value:     b[0x08:size],
+		})
+		b = b[size:]
+	}
+	return entries, nil
+}
+
+// findValue returns the first entry with the value type.
+func findValue(entries []Entry, valueType uint16) (Entry, bool) {
+	for _, e := range entries {
+		if e.ValueType == valueType {
+			return e, true
+		}
+	}
+	return Entry{}, false
+}
+
+// KeyProtector is a protector of the volume master key (VMK), such as a recovery password or TPM.
+type KeyProtector struct {
+	ID          string
+	Protection  Protection
+	Modified    time.Time
+	Description string
+	entries     []Entry
+}
+
+// KeyProtectors returns the key protectors of the volume.
+func (m Metadata) KeyProtectors() ([]KeyProtector, error) {
+	protectors := make([]KeyProtector, 0)
+	for _, e := range m.Entries {
+		if e.Type != entryTypeVMK || e.ValueType != valueTypeVMK {
+			continue
+		}
+		if len(e.Value) < 0x1C {
+			return nil, fmt.Errorf("VMK entry of %d bytes is too short", len(e.Value))
+		}
+		entries, err := ParseEntries(e.Value[0x1C:])
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse entries of VMK: %v", err)
+		}
+		p := KeyProtector{
+			ID:         formatGUID(e.Value[0x00:0x10]),
+			Modified:   mft.ConvertFileTime(binary.LittleEndian.Uint64(e.Value[0x10:])),
+			Protection: Protection(binary.LittleEndian.Uint16(e.Value[0x1A:])),
+			entries:    entries,
+		}
+		if d, ok := findValue(entries, valueTypeString); ok {
+			p.Description = decodeString(d.Value)
+		}
+		protectors = append(protectors, p)
+	}
+	return protectors, nil
+}
+
+// unlock decrypts the VMK of the first key protector with the protection for which the key (derived from the
+// protector's entries) decrypts it, and then decrypts the FVEK with the VMK.
+func (m Metadata) unlock(protection Protection, key func(p KeyProtector) ([]byte, error)) ([]byte, error) {
+	protectors, err := m.KeyProtectors()
+	if err != nil {
+		return nil, err
+	}
+	err = fmt.Errorf("no %v key protector found", protection)
+	for _, p := range protectors {
+		if p.Protection != protection {
+			continue
+		}
+		var k, vmk []byte
+		if k, err = key(p); err != nil {
+			continue
+		}
+		encrypted, ok := findValue(p.entries, valueTypeAESCCM)
+		if !ok {
+			err = fmt.Errorf("no encrypted VMK in key protector %s", p.ID)
+			continue
+		}
+		if vmk, err = decryptKey(k, encrypted.Value); err != nil {
+			err = fmt.Errorf("unable to decrypt VMK of key protector %s: %v", p.ID, err)
+			continue
+		}
+		return m.UnlockWithVMK(vmk)
+	}
+	return nil, err
+}
+
+// UnlockWithVMK decrypts the FVEK using the volume master key.
+func (m Metadata) UnlockWithVMK(vmk []byte) ([]byte, error) {
+	for _, e := range m.Entries {
+		if e.Type != entryTypeFVEK || e.ValueType != valueTypeAESCCM {
+			continue
+		}
+		fvek, err := decryptKey(vmk, e.Value)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decrypt FVEK: %v", err)
+		}
+		return fvek, nil
+	}
+	return nil, fmt.Errorf("no encrypted FVEK found")
+}
+
+// UnlockWithRecoveryPassword returns the FVEK using the 48 digit recovery password of the volume.
+func (m Metadata) UnlockWithRecoveryPassword(password string) ([]byte, error) {
+	key, err := ParseRecoveryPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(key)
+	return m.unlock(ProtectionRecoveryPassword, func(p KeyProtector) ([]byte, error) {
+		stretch, ok := findValue(p.entries, valueTypeStretchKey)
+		if !ok || len(stretch.Value) < 0x14 {
+			return nil, fmt.Errorf("no stretch key in key protector %s", p.ID)
+		}
+		return stretchKey(hash, stretch.Value[0x04:0x14]), nil
+	})
+}
+
+// UnlockWithStartupKey returns the FVEK using the contents of the .BEK file of a startup key protector. A .BEK file
+// contains FVE metadata (without block header) with the external key.
+func (m Metadata) UnlockWithStartupKey(bek []byte) ([]byte, error) {
+	if len(bek) < 48 {
+		return nil, fmt.Errorf("expected at least 48 bytes in startup key but got %d", len(bek))
+	}
+	size := int(binary.LittleEndian.Uint32(bek))
+	if size < 48 || size > len(bek) {
+		return nil, fmt.Errorf("invalid startup key size %d", size)
+	}
+	entries, err := ParseEntries(bek[48:size])
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse startup key: %v", err)
+	}
+	external, ok := findValue(entries, valueTypeExternalKey)
+	if !ok || len(external.Value) < 0x18 {
+		return nil, fmt.Errorf("no external key found in startup key")
+	}
+	id := formatGUID(external.Value[0x00:0x10])
+	nested, err := ParseEntries(external.Value[0x18:])
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse external key: %v", err)
+	}
+	key, ok := findValue(nested, valueTypeKey)
+	if !ok || len(key.Value) < 4 {
+		return nil, fmt.Errorf("no key found in external key")
+	}
+	return m.unlock(ProtectionStartupKey, func(p KeyProtector) ([]byte, error) {
+		if p.ID != id {
+			return nil, fmt.Errorf("startup key %s does not belong to key protector %s", id, p.ID)
+		}
+		return key.Value[4:], nil
+	})
+}
+
+// UnlockWithClearKey returns the FVEK of a volume of which the protection is suspended: its VMK is encrypted with a key
+// which is stored unencrypted.
+func (m Metadata) UnlockWithClearKey() ([]byte, error) {
+	return m.unlock(ProtectionClearKey, func(p KeyProtector) ([]byte, error) {
+		key, ok := findValue(p.entries, valueTypeKey)
+		if !ok || len(key.Value) < 4 {
+			return nil, fmt.Errorf("no clear key in key protector %s", p.ID)
+		}
+		return key.Value[4:], nil
+	})
+}
+
+// decryptKey decrypts an AES-CCM encrypted key entry value, which consists of a 12 byte nonce, a 16 byte MAC and the
+// encrypted data. The data is a key entry, of which the key is returned.
+func decryptKey(key []byte, value []byte) ([]byte, error) {
+	if len(value) < 0x1C {
+		return nil, fmt.Errorf("encrypted key of %d bytes is too short", len(value))
+	}
+	plain, err := ccmDecrypt(key, value[0x00:0x0C], value[0x0C:0x1C], value[0x1C:])
+	if err != nil {
+		return nil, err
+	}
+	entries, err := ParseEntries(plain)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse decrypted key: %v", err)
+	}
+	if len(entries) == 0 || entries[0].ValueType != valueTypeKey || len(entries[0].Value) < 4 {
+		return nil, fmt.Errorf("decrypted data is not a key")
+	}
+	return entries[0].Value[4:], nil
+}
+
+// ParseRecoveryPassword converts a recovery password of 8 groups of 6 digits (optionally separated by dashes) into the
+// 16 byte recovery key. Each group is a multiple of 11, encoding 2 bytes of the key.
+func ParseRecoveryPassword(password string) ([]byte, error) {
+	digits := strings.Replace(strings.TrimSpace(password), "-", "", -1)
+	if len(digits) != 48 {
+		return nil, fmt.Errorf("expected 48 digits in recovery password but got %d characters", len(digits))
+	}
+	key := make([]byte, 16)
+	for i := 0; i < 8; i++ {
+		group := digits[i*6 : i*6+6]
+		n, err := strconv.ParseUint(group, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid group %d of recovery password: %q", i+1, group)
+		}
+		if n%11 != 0 || n/11 > 0xFFFF {
+			return nil, fmt.Errorf("group %d of recovery password is invalid: %s", i+1, group)
+		}
+		binary.LittleEndian.PutUint16(key[i*2:], uint16(n/11))
+	}
+	return key, nil
+}
+
+// stretchKey derives a key from a hash and salt, using 1048576 iterations of SHA-256.
+func stretchKey(hash [sha256.Size]byte, salt []byte) []byte {
+	// The hashed structure: the last hash, the initial hash, the salt and the iteration count
+	b := make([]byte, 32+32+16+8)
+	copy(b[32:], hash[:])
+	copy(b[64:], salt)
+	for i := uint64(0); i < 0x100000; i++ {
+		binary.LittleEndian.PutUint64(b[80:], i)
+		last := sha256.Sum256(b)
+		copy(b, last[:])
+	}
+	return b[:32]
+}
+
+// decodeString decodes a NUL terminated UTF-16 string value.
+func decodeString(b []byte) string {
+	for i := 0; i+1 < len(b); i += 2 {
+		if b[i] == 0 && b[i+1] == 0 {
+			b = b[:i]
+			break
+		}
+	}
+	return utf16.DecodeString(b, binary.LittleEndian)
+}
+
+// formatGUID formats a GUID in its mixed endian binary form as a string.
+func formatGUID(b []byte) string {
+	return fmt.Sprintf("%08x-%04x-%04x-%x-%x", binary.LittleEndian.Uint32(b), binary.LittleEndian.Uint16(b[4:]),
+		binary.LittleEndian.Uint16(b[6:]), b[8:10], b[10:16])
+}
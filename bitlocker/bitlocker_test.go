@@ -0,0 +1,374 @@
+package bitlocker_test
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/bitlocker"
+)
+
+const (
+	sectorSize    = 512
+	volumeSize    = 0x50000
+	headerOffset  = 0x40000
+	headerSectors = 16
+)
+
+var blockOffsets = []uint64{0x10000, 0x20000, 0x30000}
+
+func xor(dst []byte, b []byte) {
+	for i := range b {
+		dst[i] ^= b[i]
+	}
+}
+
+// ccmEncrypt encrypts data with AES-CCM using a 12 byte nonce and 16 byte MAC, returning the MAC and ciphertext.
+func ccmEncrypt(key []byte, nonce []byte, data []byte) ([]byte, []byte) {
+	block, _ := aes.NewCipher(key)
+	x := make([]byte, 16)
+	x[0] = 0x3A
+	copy(x[1:], nonce)
+	x[15] = byte(len(data))
+	x[14] = byte(len(data) >> 8)
+	block.Encrypt(x, x)
+	for i := 0; i < len(data); i += 16 {
+		end := i + 16
+		if end > len(data) {
+			end = len(data)
+		}
+		xor(x, data[i:end])
+		block.Encrypt(x, x)
+	}
+	ctr := make([]byte, 16)
+	ctr[0] = 2
+	copy(ctr[1:], nonce)
+	s0 := make([]byte, 16)
+	block.Encrypt(s0, ctr)
+	xor(x, s0)
+	ctr[15] = 1
+	out := make([]byte, len(data))
+	cipher.NewCTR(block, ctr).XORKeyStream(out, data)
+	return x, out
+}
+
+func xtsEncrypt(key []byte, sector []byte, unit uint64) {
+	k1, _ := aes.NewCipher(key[:len(key)/2])
+	k2, _ := aes.NewCipher(key[len(key)/2:])
+	tweak := make([]byte, 16)
+	binary.LittleEndian.PutUint64(tweak, unit)
+	k2.Encrypt(tweak, tweak)
+	for i := 0; i < len(sector); i += 16 {
+		b := sector[i : i+16]
+		xor(b, tweak)
+		k1.Encrypt(b, b)
+		xor(b, tweak)
+		carry := tweak[15] >> 7
+		for j := 15; j > 0; j-- {
+			tweak[j] = tweak[j]<<1 | tweak[j-1]>>7
+		}
+		tweak[0] <<= 1
+		if carry != 0 {
+			tweak[0] ^= 0x87
+		}
+	}
+}
+
+func cbcEncrypt(key []byte, sector []byte, offset uint64) {
+	block, _ := aes.NewCipher(key)
+	iv := make([]byte, 16)
+	binary.LittleEndian.PutUint64(iv, offset)
+	block.Encrypt(iv, iv)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(sector, sector)
+}
+
+func entry(typ uint16, valueType uint16, value []byte) []byte {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint16(b[0x00:], uint16(8+len(value)))
+	binary.LittleEndian.PutUint16(b[0x02:], typ)
+	binary.LittleEndian.PutUint16(b[0x04:], valueType)
+	binary.LittleEndian.PutUint16(b[0x06:], 1)
+	return append(b, value...)
+}
+
+func keyEntry(method uint32, key []byte) []byte {
+	v := make([]byte, 4)
+	binary.LittleEndian.PutUint32(v, method)
+	return entry(0, 1, append(v, key...))
+}
+
+func encryptedKey(typ uint16, key []byte, counter byte, plainKey []byte) []byte {
+	nonce := make([]byte, 12)
+	nonce[8] = counter
+	mac, data := ccmEncrypt(key, nonce, keyEntry(0x2000, plainKey))
+	return entry(typ, 5, bytes.Join([][]byte{nonce, mac, data}, nil))
+}
+
+func vmkEntry(id []byte, protection uint16, nested ...[]byte) []byte {
+	v := make([]byte, 0x1C)
+	copy(v, id)
+	binary.LittleEndian.PutUint16(v[0x1A:], protection)
+	return entry(2, 8, append(v, bytes.Join(nested, nil)...))
+}
+
+func metadata(method bitlocker.EncryptionMethod, entries ...[]byte) []byte {
+	data := bytes.Join(entries, nil)
+	b := make([]byte, 64+48)
+	copy(b, bitlocker.Signature)
+	binary.LittleEndian.PutUint16(b[0x0A:], 2)
+	binary.LittleEndian.PutUint64(b[0x10:], volumeSize)
+	binary.LittleEndian.PutUint32(b[0x1C:], headerSectors)
+	for i, o := range blockOffsets {
+		binary.LittleEndian.PutUint64(b[0x20+i*8:], o)
+	}
+	binary.LittleEndian.PutUint64(b[0x38:], headerOffset)
+	h := b[64:]
+	binary.LittleEndian.PutUint32(h[0x00:], uint32(48+len(data)))
+	binary.LittleEndian.PutUint32(h[0x04:], 1)
+	binary.LittleEndian.PutUint32(h[0x08:], 48)
+	copy(h[0x10:], "0123456789abcdef")
+	binary.LittleEndian.PutUint16(h[0x24:], uint16(method))
+	binary.LittleEndian.PutUint64(h[0x28:], 132223104000000000)
+	return append(b, data...)
+}
+
+// plaintext returns the plaintext of the test volume, starting with an NTFS boot sector.
+func plaintext() []byte {
+	b := make([]byte, volumeSize)
+	for i := range b {
+		b[i] = byte(i*7 + i/sectorSize)
+	}
+	copy(b[3:], "NTFS    ")
+	return b
+}
+
+// expected returns the plaintext as read from the test volume, with the metadata blocks and relocated header zeroed.
+func expected() []byte {
+	b := plaintext()
+	for _, o := range append(blockOffsets, headerOffset) {
+		size := uint64(64 * 1024)
+		if o == headerOffset {
+			size = headerSectors * sectorSize
+		}
+		copy(b[o:o+size], make([]byte, size))
+	}
+	return b
+}
+
+// encryptedVolume builds an image of a BitLocker protected volume with the metadata.
+func encryptedVolume(encrypt func(sector []byte, offset uint64), metadata []byte) []byte {
+	plain := plaintext()
+	img := make([]byte, volumeSize)
+	for o := uint64(headerSectors * sectorSize); o < volumeSize; o += sectorSize {
+		copy(img[o:], plain[o:o+sectorSize])
+		encrypt(img[o:o+sectorSize], o)
+	}
+	for o := uint64(0); o < headerSectors*sectorSize; o += sectorSize {
+		physical := headerOffset + o
+		copy(img[physical:], plain[o:o+sectorSize])
+		encrypt(img[physical:physical+sectorSize], physical)
+	}
+
+	boot := make([]byte, sectorSize)
+	copy(boot[3:], bitlocker.Signature)
+	binary.LittleEndian.PutUint16(boot[0x0B:], sectorSize)
+	for i, o := range blockOffsets {
+		binary.LittleEndian.PutUint64(boot[0xB0+i*8:], o)
+		copy(img[o:o+64*1024], make([]byte, 64*1024))
+		copy(img[o:], metadata)
+	}
+	copy(img, boot)
+	return img
+}
+
+func recoveryPassword(key []byte) string {
+	groups := make([]string, 8)
+	for i := range groups {
+		groups[i] = fmt.Sprintf("%06d", 11*int(binary.LittleEndian.Uint16(key[i*2:])))
+	}
+	return strings.Join(groups, "-")
+}
+
+func stretch(key []byte, salt []byte) []byte {
+	initial := sha256.Sum256(key)
+	b := make([]byte, 88)
+	copy(b[32:], initial[:])
+	copy(b[64:], salt)
+	for i := uint64(0); i < 0x100000; i++ {
+		binary.LittleEndian.PutUint64(b[80:], i)
+		last := sha256.Sum256(b)
+		copy(b, last[:])
+	}
+	return b[:32]
+}
+
+func readAll(t *testing.T, r io.ReaderAt) []byte {
+	b := make([]byte, volumeSize)
+	n, err := r.ReadAt(b, 0)
+	require.Nilf(t, err, "error reading volume: %v", err)
+	require.Equal(t, len(b), n)
+	return b
+}
+
+func TestRecoveryPasswordXTS(t *testing.T) {
+	fvek := bytes.Repeat([]byte{0x5A, 0xC3}, 32)
+	vmk := bytes.Repeat([]byte{0x11}, 32)
+	recoveryKey := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 0xFF}
+	salt := []byte("saltsaltsaltsalt")
+	id := bytes.Repeat([]byte{0xAB}, 16)
+
+	salted := make([]byte, 4)
+	binary.LittleEndian.PutUint32(salted, 0x1000)
+	m := metadata(bitlocker.AES256XTS,
+		vmkEntry(id, 0x0800,
+			entry(0, 2, []byte("D\x00e\x00s\x00k\x00\x00\x00")),
+			entry(0, 3, append(salted, salt...)),
+			encryptedKey(0, stretch(recoveryKey, salt), 1, vmk)),
+		encryptedKey(3, vmk, 2, fvek),
+	)
+	img := encryptedVolume(func(sector []byte, offset uint64) { xtsEncrypt(fvek, sector, offset/sectorSize) }, m)
+
+	md, err := bitlocker.ReadMetadata(bytes.NewReader(img))
+	require.Nilf(t, err, "error reading metadata: %v", err)
+	assert.Equal(t, uint16(2), md.Version)
+	assert.Equal(t, sectorSize, md.SectorSize)
+	assert.Equal(t, bitlocker.AES256XTS, md.EncryptionMethod)
+	assert.Equal(t, uint64(headerOffset), md.HeaderOffset)
+	assert.Equal(t, uint64(headerSectors), md.HeaderSectors)
+	assert.Equal(t, uint64(volumeSize), md.EncryptedSize)
+	assert.Equal(t, 2020, md.Created.Year())
+
+	protectors, err := md.KeyProtectors()
+	require.Nilf(t, err, "error reading key protectors: %v", err)
+	require.Len(t, protectors, 1)
+	assert.Equal(t, "abababab-abab-abab-abab-abababababab", protectors[0].ID)
+	assert.Equal(t, bitlocker.ProtectionRecoveryPassword, protectors[0].Protection)
+	assert.Equal(t, "Desk", protectors[0].Description)
+
+	key, err := md.UnlockWithRecoveryPassword(recoveryPassword(recoveryKey))
+	require.Nilf(t, err, "error unlocking: %v", err)
+	assert.Equal(t, fvek, key)
+
+	r, err := bitlocker.NewReader(bytes.NewReader(img), md, key)
+	require.Nilf(t, err, "error creating reader: %v", err)
+	assert.Equal(t, expected(), readAll(t, r))
+
+	// An unaligned read spanning the end of the header and a read beyond the end
+	b := make([]byte, 1000)
+	n, err := r.ReadAt(b, headerSectors*sectorSize-500)
+	require.Nilf(t, err, "error reading volume: %v", err)
+	assert.Equal(t, len(b), n)
+	assert.Equal(t, expected()[headerSectors*sectorSize-500:headerSectors*sectorSize+500], b)
+	n, err = r.ReadAt(b, volumeSize-100)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, 100, n)
+
+	recoveryKey[0]++
+	_, err = md.UnlockWithRecoveryPassword(recoveryPassword(recoveryKey))
+	assert.NotNil(t, err, "expected an error for a wrong recovery password")
+}
+
+func TestStartupKeyCBC(t *testing.T) {
+	fvek := bytes.Repeat([]byte{0x42}, 16)
+	vmk := bytes.Repeat([]byte{0x22}, 32)
+	external := bytes.Repeat([]byte{0x33}, 32)
+	id := bytes.Repeat([]byte{0x01}, 16)
+
+	m := metadata(bitlocker.AES128CBC,
+		vmkEntry(bytes.Repeat([]byte{0x02}, 16), 0x0800),
+		vmkEntry(id, 0x0200, encryptedKey(0, external, 1, vmk)),
+		encryptedKey(3, vmk, 2, fvek),
+	)
+	img := encryptedVolume(func(sector []byte, offset uint64) { cbcEncrypt(fvek, sector, offset) }, m)
+
+	externalKey := append(append([]byte(nil), id...), make([]byte, 8)...)
+	externalKey = append(externalKey, keyEntry(0x2000, external)...)
+	bekEntries := entry(6, 9, externalKey)
+	bek := make([]byte, 48)
+	binary.LittleEndian.PutUint32(bek, uint32(48+len(bekEntries)))
+	bek = append(bek, bekEntries...)
+
+	md, err := bitlocker.ReadMetadata(bytes.NewReader(img))
+	require.Nilf(t, err, "error reading metadata: %v", err)
+	key, err := md.UnlockWithStartupKey(bek)
+	require.Nilf(t, err, "error unlocking: %v", err)
+	assert.Equal(t, fvek, key)
+
+	r, err := bitlocker.NewReader(bytes.NewReader(img), md, key)
+	require.Nilf(t, err, "error creating reader: %v", err)
+	assert.Equal(t, expected(), readAll(t, r))
+
+	_, err = md.UnlockWithClearKey()
+	assert.NotNil(t, err, "expected an error without clear key protector")
+}
+
+func TestClearKeyAndFallbackToSecondMetadataBlock(t *testing.T) {
+	fvek := bytes.Repeat([]byte{0x77}, 32)
+	vmk := bytes.Repeat([]byte{0x66}, 32)
+	clear := bytes.Repeat([]byte{0x55}, 32)
+	m := metadata(bitlocker.AES128XTS,
+		vmkEntry(make([]byte, 16), 0x0000, keyEntry(0x2000, clear), encryptedKey(0, clear, 1, vmk)),
+		encryptedKey(3, vmk, 2, fvek),
+	)
+	img := encryptedVolume(func(sector []byte, offset uint64) { xtsEncrypt(fvek, sector, offset/sectorSize) }, m)
+	copy(img[blockOffsets[0]:], "garbage!")
+
+	md, err := bitlocker.ReadMetadata(bytes.NewReader(img))
+	require.Nilf(t, err, "error reading metadata: %v", err)
+	key, err := md.UnlockWithClearKey()
+	require.Nilf(t, err, "error unlocking: %v", err)
+	r, err := bitlocker.NewReader(bytes.NewReader(img), md, key)
+	require.Nilf(t, err, "error creating reader: %v", err)
+	assert.Equal(t, expected(), readAll(t, r))
+}
+
+func TestXTSTestVector(t *testing.T) {
+	// Vector 1 of IEEE 1619: both keys and the data unit number are zero
+	ciphertext, _ := hex.DecodeString("917cf69ebd68b2ec9b9fe9a3eadda692cd43d2f59598ed858c02c2652fbf922e")
+	volume := make([]byte, sectorSize)
+	copy(volume, ciphertext)
+	m := bitlocker.Metadata{SectorSize: sectorSize, EncryptionMethod: bitlocker.AES128XTS, HeaderSectors: 1}
+
+	r, err := bitlocker.NewReader(bytes.NewReader(volume), m, make([]byte, 32))
+	require.Nilf(t, err, "error creating reader: %v", err)
+	b := make([]byte, 32)
+	_, err = r.ReadAt(b, 0)
+	require.Nilf(t, err, "error reading volume: %v", err)
+	assert.Equal(t, make([]byte, 32), b)
+}
+
+func TestNewReaderUnsupportedMethod(t *testing.T) {
+	m := bitlocker.Metadata{SectorSize: sectorSize, EncryptionMethod: bitlocker.AES128Diffuser}
+	_, err := bitlocker.NewReader(bytes.NewReader(nil), m, make([]byte, 64))
+	assert.NotNil(t, err)
+}
+
+func TestReadMetadataNotBitLocker(t *testing.T) {
+	_, err := bitlocker.ReadMetadata(bytes.NewReader(plaintext()))
+	assert.NotNil(t, err)
+}
+
+func TestParseRecoveryPassword(t *testing.T) {
+	key, err := bitlocker.ParseRecoveryPassword("000011-000022-000033-000044-000055-000066-000077-720885")
+	require.Nilf(t, err, "error parsing: %v", err)
+	assert.Equal(t, []byte{1, 0, 2, 0, 3, 0, 4, 0, 5, 0, 6, 0, 7, 0, 0xFF, 0xFF}, key)
+
+	for _, password := range []string{
+		"000011-000022",
+		"000012-000022-000033-000044-000055-000066-000077-000088", // not a multiple of 11
+		"720896-000022-000033-000044-000055-000066-000077-000088", // exceeds 16 bits
+		"00001a-000022-000033-000044-000055-000066-000077-000088",
+	} {
+		_, err := bitlocker.ParseRecoveryPassword(password)
+		assert.NotNilf(t, err, "expected an error for %s", password)
+	}
+}
@@ -0,0 +1,133 @@
+package bitlocker
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+	"fmt"
+)
+
+// ccmDecrypt decrypts data encrypted with AES-CCM without associated data, and checks the 16 byte MAC.
+func ccmDecrypt(key []byte, nonce []byte, mac []byte, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	l := 15 - len(nonce) // the size of the length and counter fields
+	if l < 2 || l > 8 || len(mac) != aes.BlockSize {
+		return nil, fmt.Errorf("invalid nonce of %d bytes or MAC of %d bytes", len(nonce), len(mac))
+	}
+
+	// Counter block 0 encrypts the MAC, the following counter blocks the data
+	ctr := make([]byte, aes.BlockSize)
+	ctr[0] = byte(l - 1)
+	copy(ctr[1:], nonce)
+	tag := make([]byte, aes.BlockSize)
+	block.Encrypt(tag, ctr)
+	xorBytes(tag, tag, mac)
+	ctr[aes.BlockSize-1] = 1
+	plain := make([]byte, len(data))
+	cipher.NewCTR(block, ctr).XORKeyStream(plain, data)
+
+	// The CBC-MAC over the first block (flags, nonce and length) and the plaintext
+	x := make([]byte, aes.BlockSize)
+	x[0] = byte((len(mac)-2)/2)<<3 | byte(l-1)
+	copy(x[1:], nonce)
+	length := make([]byte, 8)
+	binary.BigEndian.PutUint64(length, uint64(len(plain)))
+	copy(x[aes.BlockSize-l:], length[8-l:])
+	block.Encrypt(x, x)
+	for i := 0; i < len(plain); i += aes.BlockSize {
+		end := i + aes.BlockSize
+		if end > len(plain) {
+			end = len(plain)
+		}
+		xorBytes(x, x, plain[i:end])
+		block.Encrypt(x, x)
+	}
+	if subtle.ConstantTimeCompare(x, tag) != 1 {
+		return nil, fmt.Errorf("MAC mismatch; the key is incorrect or the data is corrupt")
+	}
+	return plain, nil
+}
+
+// sectorCipher decrypts sectors of a volume.
+type sectorCipher interface {
+	// decrypt decrypts the sector at the offset (in bytes) of the volume in place.
+	decrypt(sector []byte, offset uint64)
+}
+
+// newSectorCipher creates a sectorCipher for the encryption method and FVEK.
+func newSectorCipher(method EncryptionMethod, fvek []byte, sectorSize int) (sectorCipher, error) {
+	length := method.keyLength()
+	if length == 0 {
+		return nil, fmt.Errorf("encryption method %v is not supported", method)
+	}
+	if len(fvek) < length {
+		return nil, fmt.Errorf("expected FVEK of %d bytes for %v but got %d", length, method, len(fvek))
+	}
+	if method == AES128XTS || method == AES256XTS {
+		k1, err := aes.NewCipher(fvek[:length/2])
+		if err != nil {
+			return nil, err
+		}
+		k2, err := aes.NewCipher(fvek[length/2 : length])
+		if err != nil {
+			return nil, err
+		}
+		return xts{k1: k1, k2: k2, sectorSize: uint64(sectorSize)}, nil
+	}
+	block, err := aes.NewCipher(fvek[:length])
+	if err != nil {
+		return nil, err
+	}
+	return cbc{block: block}, nil
+}
+
+// xts decrypts sectors using AES-XTS, with the sector number as tweak.
+type xts struct {
+	k1, k2     cipher.Block
+	sectorSize uint64
+}
+
+func (c xts) decrypt(sector []byte, offset uint64) {
+	tweak := make([]byte, aes.BlockSize)
+	binary.LittleEndian.PutUint64(tweak, offset/c.sectorSize)
+	c.k2.Encrypt(tweak, tweak)
+	for i := 0; i+aes.BlockSize <= len(sector); i += aes.BlockSize {
+		b := sector[i : i+aes.BlockSize]
+		xorBytes(b, b, tweak)
+		c.k1.Decrypt(b, b)
+		xorBytes(b, b, tweak)
+
+		// Multiply the tweak by x in GF(2^128)
+		carry := tweak[aes.BlockSize-1] >> 7
+		for j := aes.BlockSize - 1; j > 0; j-- {
+			tweak[j] = tweak[j]<<1 | tweak[j-1]>>7
+		}
+		tweak[0] <<= 1
+		if carry != 0 {
+			tweak[0] ^= 0x87
+		}
+	}
+}
+
+// cbc decrypts sectors using AES-CBC, with the encrypted offset of the sector as IV.
+type cbc struct {
+	block cipher.Block
+}
+
+func (c cbc) decrypt(sector []byte, offset uint64) {
+	iv := make([]byte, aes.BlockSize)
+	binary.LittleEndian.PutUint64(iv, offset)
+	c.block.Encrypt(iv, iv)
+	cipher.NewCBCDecrypter(c.block, iv).CryptBlocks(sector, sector)
+}
+
+// xorBytes sets dst to the exclusive or of a and b, for the length of b.
+func xorBytes(dst []byte, a []byte, b []byte) {
+	for i := range b {
+		dst[i] = a[i] ^ b[i]
+	}
+}
@@ -0,0 +1,136 @@
+package bitlocker
+
+import (
+	"fmt"
+	"io"
+)
+
+// Reader reads the plaintext of a BitLocker protected volume. It is safe for concurrent use when the underlying
+// io.ReaderAt is.
+type Reader struct {
+	volume     io.ReaderAt
+	metadata   Metadata
+	cipher     sectorCipher
+	sectorSize int64
+}
+
+// NewReader creates a Reader decrypting the volume with the FVEK, as obtained using one of the Unlock methods of the
+// Metadata.
+func NewReader(volume io.ReaderAt, m Metadata, fvek []byte) (*Reader, error) {
+	if m.SectorSize <= 0 || m.SectorSize%16 != 0 {
+		return nil, fmt.Errorf("invalid sector size %d", m.SectorSize)
+	}
+	c, err := newSectorCipher(m.EncryptionMethod, fvek, m.SectorSize)
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{volume: volume, metadata: m, cipher: c, sectorSize: int64(m.SectorSize)}, nil
+}
+
+// How a range of sectors is read.
+const (
+	readDecrypted = iota // read from the same offset and decrypt
+	readHeader           // read from the relocated header and decrypt
+	readZeroes           // read as zeroes
+	readPlain            // read unencrypted
+)
+
+// mapping returns how the sectors starting at the offset are read, and the end of the range of sectors which are read
+// in the same way.
+func (r *Reader) mapping(off int64) (int, int64) {
+	m := r.metadata
+	headerSize := int64(m.HeaderSectors) * r.sectorSize
+	if off < headerSize {
+		return readHeader, headerSize
+	}
+
+	end := int64(-1)
+	limit := func(start int64, size int64) bool {
+		if off >= start && off < start+size {
+			end = start + size
+			return true
+		}
+		if start > off && (end < 0 || start < end) {
+			end = start
+		}
+		return false
+	}
+	for _, b := range m.BlockOffsets {
+		if b != 0 && limit(int64(b), metadataBlockSize) {
+			return readZeroes, end
+		}
+	}
+	if headerSize > 0 && limit(int64(m.HeaderOffset), headerSize) {
+		return readZeroes, end
+	}
+	if encrypted := int64(m.EncryptedSize); encrypted > 0 {
+		if off >= encrypted {
+			return readPlain, end
+		}
+		if end < 0 || encrypted < end {
+			end = encrypted
+		}
+	}
+	return readDecrypted, end
+}
+
+// ReadAt reads len(p) bytes of plaintext starting at position off of the volume. When fewer than len(p) bytes are
+// available, it returns the number of bytes read and io.EOF.
+func (r *Reader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset %d", off)
+	}
+	total := 0
+	for total < len(p) {
+		how, end := r.mapping(off)
+
+		// Read whole sectors, up to the end of the range
+		start := off - off%r.sectorSize
+		stop := off + int64(len(p)-total)
+		if rem := stop % r.sectorSize; rem != 0 {
+			stop += r.sectorSize - rem
+		}
+		if end >= 0 && stop > end {
+			stop = end
+		}
+		buf := make([]byte, stop-start)
+
+		n := len(buf)
+		var err error
+		switch how {
+		case readZeroes:
+			// buf is already zeroed
+		case readHeader:
+			n, err = r.volume.ReadAt(buf, int64(r.metadata.HeaderOffset)+start)
+		default:
+			n, err = r.volume.ReadAt(buf, start)
+		}
+		if how == readHeader || how == readDecrypted {
+			n -= n % int(r.sectorSize)
+			physical := start
+			if how == readHeader {
+				physical += int64(r.metadata.HeaderOffset)
+			}
+			for i := 0; i < n; i += int(r.sectorSize) {
+				r.cipher.decrypt(buf[i:i+int(r.sectorSize)], uint64(physical)+uint64(i))
+			}
+		}
+
+		copied := 0
+		if within := int(off - start); n > within {
+			copied = copy(p[total:], buf[within:n])
+		}
+		total += copied
+		off += int64(copied)
+		if n < len(buf) {
+			if err == nil || err == io.EOF {
+				if total < len(p) {
+					return total, io.EOF
+				}
+				return total, nil
+			}
+			return total, fmt.Errorf("unable to read volume at offset %d: %v", start+int64(n), err)
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,18 @@
+//go:build go1.18
+// +build go1.18
+
+package bootsect_test
+
+import (
+	"testing"
+
+	"github.com/t9t/gomft/bootsect"
+)
+
+func FuzzParse(f *testing.F) {
+	f.Add([]byte{})
+	f.Add(make([]byte, 512))
+	f.Fuzz(func(t *testing.T, b []byte) {
+		bootsect.Parse(b)
+	})
+}
@@ -0,0 +1,171 @@
+/*
+	Package cache stores the details of all records of a parsed MFT in a compact cache file, so that subsequent runs
+	against the same MFT can load them in a fraction of the time it takes to read and parse all records again.
+
+	Basic usage
+
+	Determine the Key of the MFT data, then try to load the cache. When the cache does not exist or was created for
+	other data, parse the MFT and save the result.
+			// Error handling left out for brevity
+			key, _ := cache.NewKey(mftData, 1024)
+			t, err := cache.Load("c.mftcache", key)
+			if err != nil {
+				t = columnar.NewTable(columnar.FieldAll)
+				// ... parse the MFT and append all records to t ...
+				cache.Save("c.mftcache", key, t)
+			}
+
+	Implementation notes
+
+	A cache file starts with a header containing a magic number, the format version and the Key: the record size and a
+	SHA-256 hash of the MFT data the cache was created from. The header is followed by a columnar.Table, compressed
+	with gzip. When either the format version or the Key does not match, Load returns ErrMismatch and the cache should
+	be rebuilt.
+
+	Computing the hash means reading all MFT data once, but that is much faster than parsing it.
+*/
+package cache
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/t9t/gomft/columnar"
+)
+
+// FormatVersion is the version of the cache file format written by this package. It is increased whenever the format
+// or the contents of the cached details change.
+const FormatVersion = 1
+
+const magic = "GOMFTCCH"
+
+const headerSize = len(magic) + 4 + 4 + sha256.Size
+
+// ErrMismatch is returned when a cache was created by another version of the format or from other MFT data.
+var ErrMismatch = errors.New("cache does not match the MFT data")
+
+// Key identifies the MFT data a cache was created from.
+type Key struct {
+	RecordSize int
+	SourceHash [sha256.Size]byte
+}
+
+// NewKey creates a Key by reading all MFT data from r.
+func NewKey(r io.Reader, recordSize int) (Key, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return Key{}, fmt.Errorf("unable to read MFT data: %v", err)
+	}
+	key := Key{RecordSize: recordSize}
+	copy(key.SourceHash[:], h.Sum(nil))
+	return key, nil
+}
+
+// Write writes a cache containing t, created from the MFT data identified by key, to w.
+func Write(w io.Writer, key Key, t *columnar.Table) error {
+	header := make([]byte, 0, headerSize)
+	header = append(header, magic...)
+	header = appendUint32(header, FormatVersion)
+	header = appendUint32(header, uint32(key.RecordSize))
+	header = append(header, key.SourceHash[:]...)
+	if _, err := w.Write(header); err != nil {
+		return fmt.Errorf("unable to write header: %v", err)
+	}
+
+	zw, err := gzip.NewWriterLevel(w, gzip.BestSpeed)
+	if err != nil {
+		return fmt.Errorf("unable to create gzip writer: %v", err)
+	}
+	if _, err := t.WriteTo(zw); err != nil {
+		return fmt.Errorf("unable to write table: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("unable to write table: %v", err)
+	}
+	return nil
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+// Read reads a cache from r. It returns ErrMismatch when the cache was written by another format version or for MFT
+// data with another key.
+func Read(r io.Reader, key Key) (*columnar.Table, error) {
+	br := bufio.NewReader(r)
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, fmt.Errorf("unable to read header: %v", err)
+	}
+	if string(header[:len(magic)]) != magic {
+		return nil, fmt.Errorf("not a cache file")
+	}
+	header = header[len(magic):]
+	if binary.LittleEndian.Uint32(header) != FormatVersion {
+		return nil, ErrMismatch
+	}
+	var actual Key
+	actual.RecordSize = int(binary.LittleEndian.Uint32(header[4:]))
+	copy(actual.SourceHash[:], header[8:])
+	if actual != key {
+		return nil, ErrMismatch
+	}
+
+	zr, err := gzip.NewReader(br)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read table: %v", err)
+	}
+	t, err := columnar.ReadTable(zr)
+	if err != nil {
+		return nil, err
+	}
+	// Read until the end, so that the gzip checksum is verified
+	if _, err := io.Copy(ioutil.Discard, zr); err != nil {
+		return nil, fmt.Errorf("unable to read table: %v", err)
+	}
+	return t, nil
+}
+
+// Load reads the cache file at path. See Read for details.
+func Load(path string, key Key) (*columnar.Table, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f, key)
+}
+
+// Save writes a cache file at path. The cache is first written to a temporary file in the same directory, which is
+// renamed to path when complete, so an existing cache file is never left half written.
+func Save(path string, key Key, t *columnar.Table) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(f)
+	err = Write(bw, key, t)
+	if err == nil {
+		err = bw.Flush()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
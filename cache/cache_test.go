@@ -0,0 +1,94 @@
+package cache_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/cache"
+	"github.com/t9t/gomft/columnar"
+	"github.com/t9t/gomft/export"
+)
+
+func TestWriteRead(t *testing.T) {
+	key, err := cache.NewKey(bytes.NewReader([]byte("mft data")), 1024)
+	require.Nil(t, err)
+	table := testTable()
+
+	buf := &bytes.Buffer{}
+	require.Nil(t, cache.Write(buf, key, table))
+	assert.Equal(t, "GOMFTCCH", string(buf.Bytes()[:8]))
+
+	read, err := cache.Read(bytes.NewReader(buf.Bytes()), key)
+	require.Nil(t, err)
+	require.Equal(t, 2, read.Len())
+	assert.Equal(t, table.Entry(0), read.Entry(0))
+	assert.Equal(t, table.Entry(1), read.Entry(1))
+}
+
+func TestRead_Mismatch(t *testing.T) {
+	key, err := cache.NewKey(bytes.NewReader([]byte("mft data")), 1024)
+	require.Nil(t, err)
+	buf := &bytes.Buffer{}
+	require.Nil(t, cache.Write(buf, key, testTable()))
+
+	otherData, err := cache.NewKey(bytes.NewReader([]byte("other mft data")), 1024)
+	require.Nil(t, err)
+	_, err = cache.Read(bytes.NewReader(buf.Bytes()), otherData)
+	assert.Equal(t, cache.ErrMismatch, err)
+
+	otherSize := key
+	otherSize.RecordSize = 4096
+	_, err = cache.Read(bytes.NewReader(buf.Bytes()), otherSize)
+	assert.Equal(t, cache.ErrMismatch, err)
+
+	otherVersion := append([]byte{}, buf.Bytes()...)
+	otherVersion[8] = 99
+	_, err = cache.Read(bytes.NewReader(otherVersion), key)
+	assert.Equal(t, cache.ErrMismatch, err)
+}
+
+func TestRead_Invalid(t *testing.T) {
+	_, err := cache.Read(bytes.NewReader([]byte("GOMFT")), cache.Key{})
+	assert.EqualError(t, err, "unable to read header: unexpected EOF")
+
+	_, err = cache.Read(bytes.NewReader(make([]byte, 100)), cache.Key{})
+	assert.EqualError(t, err, "not a cache file")
+
+	buf := &bytes.Buffer{}
+	require.Nil(t, cache.Write(buf, cache.Key{}, testTable()))
+	corrupt := buf.Bytes()[:buf.Len()-4]
+	_, err = cache.Read(bytes.NewReader(corrupt), cache.Key{})
+	assert.NotNil(t, err)
+}
+
+func TestSaveLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gomft-cache")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "test.mftcache")
+	key := cache.Key{RecordSize: 1024}
+
+	_, err = cache.Load(path, key)
+	assert.True(t, os.IsNotExist(err))
+
+	require.Nil(t, cache.Save(path, key, testTable()))
+	table, err := cache.Load(path, key)
+	require.Nil(t, err)
+	assert.Equal(t, "$MFT", table.Name(0))
+
+	files, err := ioutil.ReadDir(dir)
+	require.Nil(t, err)
+	assert.Len(t, files, 1)
+}
+
+func testTable() *columnar.Table {
+	table := columnar.NewTable(columnar.FieldAll)
+	table.Append(export.Entry{Source: export.SourceRecord, RecordNumber: 0, InUse: true, Name: "$MFT", Size: 1024})
+	table.Append(export.Entry{Source: export.SourceRecord, Offset: 5120, RecordNumber: 5, Directory: true, Name: "."})
+	return table
+}
@@ -0,0 +1,367 @@
+/*
+	Package carve provides a Scanner to recover MFT records and index entries from raw data, such as a disk image or
+	the unallocated space of a volume, without relying on any file system structures.
+
+	Basic usage
+
+	Create a Scanner over an io.Reader and call Scan() until it returns false, then check Err().
+			s := carve.NewScanner(f, carve.DefaultOptions())
+			for s.Scan() {
+				item := s.Item()
+				log.Println("Found", item.Source, "at offset", item.Offset)
+			}
+			if err := s.Err(); err != nil {
+				log.Fatalln("Unable to carve", err)
+			}
+
+	Implementation notes
+
+	The Scanner checks for a "FILE" or "INDX" signature at every multiple of Options.Alignment bytes. When a FILE
+	signature is found, Options.RecordSize bytes are parsed as an MFT record. When an INDX signature is found,
+	Options.IndexBlockSize bytes are parsed as an index block; both its allocated entries and any entries that still
+	remain in the slack space of the block are returned. Data that cannot be parsed is skipped silently, since it's
+	expected that a lot of data matching a signature is not actually a record at all.
+*/
+package carve
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/t9t/gomft/binutil"
+	"github.com/t9t/gomft/mft"
+)
+
+var (
+	fileSignature  = []byte{'F', 'I', 'L', 'E'}
+	indexSignature = []byte{'I', 'N', 'D', 'X'}
+
+	minPlausibleTime = time.Date(1980, time.January, 1, 0, 0, 0, 0, time.UTC)
+	maxPlausibleTime = time.Date(2100, time.January, 1, 0, 0, 0, 0, time.UTC)
+)
+
+const (
+	readSize        = 1024 * 1024
+	indexHeaderBase = 0x18
+	minEntryLength  = 0x10 + 66
+)
+
+// Source indicates where a carved Item was found.
+type Source int
+
+// Known values for Source.
+const (
+	SourceRecord     Source = iota // an MFT (FILE) record
+	SourceIndexEntry               // an allocated entry in an index (INDX) block
+	SourceIndexSlack               // an entry in the slack space of an index (INDX) block
+)
+
+// String returns a short, lower case description of the Source, such as "record" or "index-slack".
+func (s Source) String() string {
+	switch s {
+	case SourceRecord:
+		return "record"
+	case SourceIndexEntry:
+		return "index"
+	case SourceIndexSlack:
+		return "index-slack"
+	}
+	return "unknown"
+}
+
+// Item represents a single carved record or index entry. Offset is the absolute position of the record or entry in
+// the scanned data. Depending on the Source, either the Record or the IndexEntry is set.
+type Item struct {
+	Source     Source
+	Offset     int64
+	Record     mft.Record
+	IndexEntry mft.IndexEntry
+}
+
+// Options define how a Scanner searches for records and index entries. All sizes are in bytes.
+type Options struct {
+	RecordSize     int  // size of an MFT record, typically 1024
+	IndexBlockSize int  // size of an index block, typically 4096
+	Alignment      int  // signatures are only checked at multiples of this value; typically the sector size
+	Records        bool // whether to carve MFT records
+	IndexEntries   bool // whether to carve index entries, including those in index block slack space
+}
+
+// DefaultOptions returns Options for the most common NTFS layout: 1024 byte records, 4096 byte index blocks and 512
+// byte sectors, carving both records and index entries.
+func DefaultOptions() Options {
+	return Options{RecordSize: 1024, IndexBlockSize: 4096, Alignment: 512, Records: true, IndexEntries: true}
+}
+
+// Scanner reads data from an io.Reader and finds MFT records and index entries in it. The data is read sequentially,
+// so the io.Reader does not need to support seeking.
+type Scanner struct {
+	ctx     context.Context
+	src     io.Reader
+	opts    Options
+	buf     []byte
+	base    int64 // absolute offset of buf[0]
+	pos     int   // position in buf of the next offset to check
+	eof     bool
+	err     error
+	pending []Item
+	item    Item
+}
+
+// NewScanner creates a Scanner which reads from src using the specified Options.
+func NewScanner(src io.Reader, opts Options) *Scanner {
+	return NewScannerContext(context.Background(), src, opts)
+}
+
+// NewScannerContext creates a Scanner like NewScanner, which stops scanning when ctx is done. Err() then returns
+// ctx.Err().
+func NewScannerContext(ctx context.Context, src io.Reader, opts Options) *Scanner {
+	return &Scanner{ctx: ctx, src: src, opts: opts}
+}
+
+// Scan advances the Scanner to the next carved Item, which will then be available through Item(). It returns false
+// when the scan stops, either by reaching the end of the input or an error. After Scan returns false, Err() returns
+// the error that occurred during scanning, or nil if the end of the input was reached.
+func (s *Scanner) Scan() bool {
+	if s.err == nil {
+		s.err = s.validateOptions()
+	}
+	for {
+		if len(s.pending) > 0 {
+			s.item = s.pending[0]
+			s.pending = s.pending[1:]
+			return true
+		}
+		if s.err != nil {
+			return false
+		}
+
+		s.fill(s.pos + alignUp(s.maxBlockSize(), s.opts.Alignment))
+		if s.err != nil {
+			return false
+		}
+		remaining := len(s.buf) - s.pos
+		if remaining < len(fileSignature) {
+			return false
+		}
+
+		s.pos += s.check(s.buf[s.pos:], s.base+int64(s.pos))
+	}
+}
+
+// Item returns the Item found by the most recent call to Scan().
+func (s *Scanner) Item() Item {
+	return s.item
+}
+
+// Err returns the first error that was encountered by the Scanner, or nil if there was none.
+func (s *Scanner) Err() error {
+	return s.err
+}
+
+func (s *Scanner) validateOptions() error {
+	if s.opts.Alignment <= 0 {
+		return fmt.Errorf("alignment should be positive but is %d", s.opts.Alignment)
+	}
+	if s.opts.Records && s.opts.RecordSize <= 0 {
+		return fmt.Errorf("record size should be positive but is %d", s.opts.RecordSize)
+	}
+	if s.opts.IndexEntries && s.opts.IndexBlockSize <= indexHeaderBase+0x10 {
+		return fmt.Errorf("index block size should be larger than %d but is %d", indexHeaderBase+0x10, s.opts.IndexBlockSize)
+	}
+	return nil
+}
+
+func (s *Scanner) maxBlockSize() int {
+	if s.opts.RecordSize > s.opts.IndexBlockSize {
+		return s.opts.RecordSize
+	}
+	return s.opts.IndexBlockSize
+}
+
+// fill makes sure at least want bytes are buffered (unless the end of the input is reached), discarding data before
+// pos. Since want always includes the largest possible advance after a check, pos never exceeds the buffered data
+// before the end of the input is reached.
+func (s *Scanner) fill(want int) {
+	if len(s.buf) >= want || s.eof {
+		return
+	}
+	if s.pos > 0 {
+		n := copy(s.buf, s.buf[s.pos:])
+		s.buf = s.buf[:n]
+		s.base += int64(s.pos)
+		want -= s.pos
+		s.pos = 0
+	}
+	if want < readSize {
+		want = readSize
+	}
+	if cap(s.buf) < want {
+		grown := make([]byte, len(s.buf), want)
+		copy(grown, s.buf)
+		s.buf = grown
+	}
+	for len(s.buf) < want {
+		if err := s.ctx.Err(); err != nil {
+			s.err = err
+			return
+		}
+		n, err := s.src.Read(s.buf[len(s.buf):want])
+		s.buf = s.buf[:len(s.buf)+n]
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				s.eof = true
+			} else {
+				s.err = fmt.Errorf("unable to read data at offset %d: %v", s.base+int64(len(s.buf)), err)
+			}
+			return
+		}
+	}
+}
+
+// check tries to carve an item from the start of b, which is located at the absolute offset. It returns the amount
+// of bytes to advance.
+func (s *Scanner) check(b []byte, offset int64) int {
+	if s.opts.Records && len(b) >= s.opts.RecordSize && bytes.HasPrefix(b, fileSignature) {
+		var record mft.Record
+		err := safely(func() error {
+			var err error
+			record, err = mft.ParseRecord(b[:s.opts.RecordSize])
+			return err
+		})
+		if err == nil {
+			s.pending = append(s.pending, Item{Source: SourceRecord, Offset: offset, Record: record})
+			return alignUp(s.opts.RecordSize, s.opts.Alignment)
+		}
+	}
+
+	if s.opts.IndexEntries && len(b) >= s.opts.IndexBlockSize && bytes.HasPrefix(b, indexSignature) {
+		items := carveIndexBlock(binutil.Duplicate(b[:s.opts.IndexBlockSize]), offset)
+		if len(items) > 0 {
+			s.pending = append(s.pending, items...)
+			return alignUp(s.opts.IndexBlockSize, s.opts.Alignment)
+		}
+	}
+
+	return s.opts.Alignment
+}
+
+// carveIndexBlock returns the entries of an index block, as well as any entries found in its slack space. The data in
+// b is modified by applying fixup.
+func carveIndexBlock(b []byte, offset int64) []Item {
+	var block mft.IndexBlock
+	err := safely(func() error {
+		var err error
+		if _, err = mft.ApplyFixup(b); err != nil {
+			return err
+		}
+		block, err = mft.ParseIndexBlock(b)
+		return err
+	})
+	if err != nil {
+		return nil
+	}
+
+	start := indexHeaderBase + int(block.EntryOffset)
+	end := indexHeaderBase + int(block.TotalEntrySize)
+	allocEnd := indexHeaderBase + int(block.AllocEntrySize)
+	if allocEnd > len(b) {
+		allocEnd = len(b)
+	}
+	if end > allocEnd {
+		end = allocEnd
+	}
+	if start < indexHeaderBase || start > end {
+		return nil
+	}
+
+	items := make([]Item, 0)
+	pos := start
+	for pos+0x10 <= end {
+		r := binutil.NewLittleEndianReader(b[pos:end])
+		entryLength := int(r.Uint16(0x08))
+		flags := r.Uint32(0x0C)
+		if entryLength < 0x10 || pos+entryLength > end {
+			break
+		}
+		if flags&0b10 == 0 {
+			if entry, ok := parseEntry(b[pos:pos+entryLength], false); ok {
+				items = append(items, Item{Source: SourceIndexEntry, Offset: offset + int64(pos), IndexEntry: entry})
+			}
+		}
+		pos += entryLength
+		if flags&0b10 != 0 {
+			break
+		}
+	}
+
+	// Entries in the slack space are 8-byte aligned, just like allocated entries
+	pos = alignUp(pos, 8)
+	for pos+minEntryLength <= allocEnd {
+		entryLength := int(binutil.NewLittleEndianReader(b[pos:]).Uint16(0x08))
+		if entryLength >= minEntryLength && entryLength%8 == 0 && pos+entryLength <= allocEnd {
+			if entry, ok := parseEntry(b[pos:pos+entryLength], true); ok {
+				items = append(items, Item{Source: SourceIndexSlack, Offset: offset + int64(pos), IndexEntry: entry})
+				pos += entryLength
+				continue
+			}
+		}
+		pos += 8
+	}
+	return items
+}
+
+// parseEntry parses a single index entry containing a $FILE_NAME. When strict is true, additional plausibility checks
+// are done to reject data which is probably not an index entry at all.
+func parseEntry(b []byte, strict bool) (mft.IndexEntry, bool) {
+	r := binutil.NewLittleEndianReader(b)
+	contentLength := int(r.Uint16(0x0A))
+	if contentLength < 66 || 0x10+contentLength > len(b) {
+		return mft.IndexEntry{}, false
+	}
+	if strict && (r.Uint32(0x0C)&^0b11 != 0 || r.Byte(0x10+0x41) > byte(mft.FileNameNamespaceWin32Dos)) {
+		return mft.IndexEntry{}, false
+	}
+
+	var entries []mft.IndexEntry
+	err := safely(func() error {
+		var err error
+		entries, err = mft.ParseIndexEntries(b)
+		return err
+	})
+	if err != nil || len(entries) == 0 {
+		return mft.IndexEntry{}, false
+	}
+	entry := entries[0]
+	if strict && (entry.FileName.Name == "" || !isPlausibleTime(entry.FileName.Creation) ||
+		!isPlausibleTime(entry.FileName.MftLastModified)) {
+		return mft.IndexEntry{}, false
+	}
+	return entry, true
+}
+
+func isPlausibleTime(t time.Time) bool {
+	return !t.Before(minPlausibleTime) && t.Before(maxPlausibleTime)
+}
+
+// safely calls fn, converting any panic into an error. The parsers in the mft package assume their input is at least
+// somewhat sane, while carving by definition feeds them arbitrary data.
+func safely(fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic during parsing: %v", r)
+		}
+	}()
+	return fn()
+}
+
+func alignUp(n int, alignment int) int {
+	if rem := n % alignment; rem != 0 {
+		return n + alignment - rem
+	}
+	return n
+}
@@ -0,0 +1,124 @@
+package carve_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/carve"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/mfttest"
+)
+
+// index entry for "test.txt", followed by the final (empty) entry of a node
+const indexEntryHex = "5fac0600000006006800520000000000398c060000003b00de3ef1e234dcd501de3ef1e234dcd50118dbd2e334dcd501de3ef1e234dcd501000000000000000000000000000000002000000000000000080374006500730074002e00740078007400000028000000"
+const lastIndexEntryHex = "00000000000000001000000002000000"
+
+func TestScanner(t *testing.T) {
+	record := mfttest.SampleMftRecord()
+	entry := mfttest.DecodeHex(t, indexEntryHex)
+	block := indexBlock(entry, mfttest.DecodeHex(t, lastIndexEntryHex), entry)
+
+	data := make([]byte, 0)
+	data = append(data, make([]byte, 512)...)
+	data = append(data, record...)
+	data = append(data, make([]byte, 512)...)
+	data = append(data, block...)
+	data = append(data, make([]byte, 1000)...)
+
+	s := carve.NewScanner(bytes.NewReader(data), carve.DefaultOptions())
+	items := make([]carve.Item, 0)
+	for s.Scan() {
+		items = append(items, s.Item())
+	}
+	require.Nilf(t, s.Err(), "error scanning: %v", s.Err())
+	require.Len(t, items, 3)
+
+	assert.Equal(t, carve.SourceRecord, items[0].Source)
+	assert.Equal(t, int64(512), items[0].Offset)
+	assert.Equal(t, mft.FileReference{RecordNumber: 0, SequenceNumber: 145}, items[0].Record.FileReference)
+
+	expectedEntry := mft.IndexEntry{
+		FileReference: mft.FileReference{RecordNumber: 437343, SequenceNumber: 6},
+		FileName: mft.FileName{
+			ParentFileReference: mft.FileReference{RecordNumber: 429113, SequenceNumber: 59},
+			Creation:            time.Date(2020, time.February, 5, 14, 59, 38, 116886200, time.UTC),
+			FileLastModified:    time.Date(2020, time.February, 5, 14, 59, 38, 116886200, time.UTC),
+			MftLastModified:     time.Date(2020, time.February, 5, 14, 59, 39, 595445600, time.UTC),
+			LastAccess:          time.Date(2020, time.February, 5, 14, 59, 38, 116886200, time.UTC),
+			Flags:               32,
+			Namespace:           3,
+			Name:                "test.txt",
+			RawTimes:            mft.RawTimes{Creation: 132253883781168862, FileLastModified: 132253883781168862, MftLastModified: 132253883795954456, LastAccess: 132253883781168862},
+		},
+	}
+	assert.Equal(t, carve.Item{Source: carve.SourceIndexEntry, Offset: 2048 + 0x40, IndexEntry: expectedEntry}, items[1])
+	assert.Equal(t, carve.Item{Source: carve.SourceIndexSlack, Offset: 2048 + 0x40 + 0x68 + 0x10, IndexEntry: expectedEntry}, items[2])
+}
+
+func TestScanner_OnlyRecords(t *testing.T) {
+	record := mfttest.SampleMftRecord()
+	block := indexBlock(mfttest.DecodeHex(t, indexEntryHex), mfttest.DecodeHex(t, lastIndexEntryHex), nil)
+	data := append(append(make([]byte, 0), block...), record...)
+
+	opts := carve.DefaultOptions()
+	opts.IndexEntries = false
+	s := carve.NewScanner(bytes.NewReader(data), opts)
+	items := make([]carve.Item, 0)
+	for s.Scan() {
+		items = append(items, s.Item())
+	}
+	require.Nilf(t, s.Err(), "error scanning: %v", s.Err())
+	require.Len(t, items, 1)
+	assert.Equal(t, carve.SourceRecord, items[0].Source)
+	assert.Equal(t, int64(4096), items[0].Offset)
+}
+
+func TestScanner_Garbage(t *testing.T) {
+	data := make([]byte, 8192)
+	for i := 0; i < len(data); i += 512 {
+		copy(data[i:], "FILE")
+		data[i+5] = byte(i)
+	}
+	copy(data[4096:], "INDX")
+
+	s := carve.NewScanner(bytes.NewReader(data), carve.DefaultOptions())
+	for s.Scan() {
+		t.Errorf("unexpected item: %+v", s.Item())
+	}
+	require.Nilf(t, s.Err(), "error scanning: %v", s.Err())
+}
+
+// indexBlock creates a 4096 byte INDX block containing the entries, with the slack data placed directly after the
+// entries.
+func indexBlock(entry []byte, last []byte, slack []byte) []byte {
+	b := make([]byte, 4096)
+	copy(b, "INDX")
+	binary.LittleEndian.PutUint16(b[0x04:], 0x28) // update sequence offset
+	binary.LittleEndian.PutUint16(b[0x06:], 9)    // update sequence size: 1 + 8 sectors
+	binary.LittleEndian.PutUint16(b[0x28:], 1)    // update sequence number
+	for i := 1; i <= 8; i++ {
+		binary.LittleEndian.PutUint16(b[i*512-2:], 1)
+	}
+
+	entries := append(append(make([]byte, 0), entry...), last...)
+	binary.LittleEndian.PutUint32(b[0x18:], 0x40-0x18)                      // entry offset
+	binary.LittleEndian.PutUint32(b[0x1C:], uint32(0x40+len(entries)-0x18)) // total entry size
+	binary.LittleEndian.PutUint32(b[0x20:], 4096-0x18)                      // allocated entry size
+	copy(b[0x40:], entries)
+	copy(b[0x40+len(entries):], slack)
+	return b
+}
+
+func TestNewScannerContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	data := append(make([]byte, 512), mfttest.SampleMftRecord()...)
+	s := carve.NewScannerContext(ctx, bytes.NewReader(data), carve.DefaultOptions())
+	cancel()
+	assert.False(t, s.Scan())
+	assert.Equal(t, context.Canceled, s.Err())
+}
@@ -0,0 +1,13 @@
+// Command mftcarve is the standalone version of "gomft carve".
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/t9t/gomft/internal/cli"
+)
+
+func main() {
+	os.Exit(cli.RunCommand(filepath.Base(os.Args[0]), "carve", os.Args[1:]))
+}
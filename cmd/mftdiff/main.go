@@ -0,0 +1,13 @@
+// Command mftdiff is the standalone version of "gomft diff".
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/t9t/gomft/internal/cli"
+)
+
+func main() {
+	os.Exit(cli.RunCommand(filepath.Base(os.Args[0]), "diff", os.Args[1:]))
+}
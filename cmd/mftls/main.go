@@ -0,0 +1,13 @@
+// Command mftls is the standalone version of "gomft ls".
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/t9t/gomft/internal/cli"
+)
+
+func main() {
+	os.Exit(cli.RunCommand(filepath.Base(os.Args[0]), "ls", os.Args[1:]))
+}
@@ -0,0 +1,13 @@
+// Command mftstat is the standalone version of "gomft stat".
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/t9t/gomft/internal/cli"
+)
+
+func main() {
+	os.Exit(cli.RunCommand(filepath.Base(os.Args[0]), "stat", os.Args[1:]))
+}
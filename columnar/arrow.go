@@ -0,0 +1,299 @@
+package columnar
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"math"
+	"time"
+
+	"github.com/t9t/gomft/mft"
+)
+
+// Values of the Arrow flatbuffers schema (Schema.fbs and Message.fbs).
+const (
+	arrowMetadataVersionV5 = 4
+
+	arrowHeaderSchema      = 1
+	arrowHeaderRecordBatch = 3
+
+	arrowTypeInt       = 2
+	arrowTypeUtf8      = 5
+	arrowTypeBool      = 6
+	arrowTypeTimestamp = 10
+
+	arrowTimeUnitNanosecond = 3
+)
+
+// arrowContinuation starts each message of an Arrow IPC stream.
+const arrowContinuation = 0xFFFFFFFF
+
+// DefaultArrowBatchSize is the number of rows per record batch written by WriteArrow when no batch size is specified.
+const DefaultArrowBatchSize = 65536
+
+// arrowColumn describes a column of an Arrow record batch and how to produce its buffers for a range of rows.
+type arrowColumn struct {
+	name     string
+	typeType byte
+	bitWidth int  // for integers
+	signed   bool // for integers
+	// buffers appends the buffers of rows [start, end) to body, returning the null count
+	buffers func(body *arrowBody, start, end int) int
+}
+
+// arrowBody collects the buffers of a record batch.
+type arrowBody struct {
+	data    []byte
+	nodes   [][2]int64
+	buffers [][2]int64
+}
+
+// add appends a buffer, padded to a multiple of 8 bytes as required by the Arrow format.
+func (b *arrowBody) add(buf []byte) {
+	b.buffers = append(b.buffers, [2]int64{int64(len(b.data)), int64(len(buf))})
+	b.data = append(b.data, buf...)
+	for len(b.data)%8 != 0 {
+		b.data = append(b.data, 0)
+	}
+}
+
+// WriteArrow writes the Table as an Arrow IPC stream (the format read by pyarrow.ipc.open_stream and the Arrow
+// libraries of other languages, and used by Arrow Flight), in record batches of at most batchSize rows. When batchSize
+// is zero, DefaultArrowBatchSize is used.
+//
+// The columns are named like the columns of export.CSVWriter and only included when the corresponding fields are kept
+// by the Table. Numbers keep their width and signedness (for example, record_number is a uint64 and sequence_number a
+// uint16), the source and name are UTF-8 strings and times are nanosecond timestamps in UTC, which are null when not
+// set or outside of the range of Arrow timestamps (the years 1677 to 2262).
+func (t *Table) WriteArrow(w io.Writer, batchSize int) error {
+	if batchSize <= 0 {
+		batchSize = DefaultArrowBatchSize
+	}
+	bw := bufio.NewWriter(w)
+	columns := t.arrowColumns()
+	if err := writeArrowMessage(bw, arrowSchema(columns), nil); err != nil {
+		return err
+	}
+	for start := 0; start < t.Len(); start += batchSize {
+		end := start + batchSize
+		if end > t.Len() {
+			end = t.Len()
+		}
+		body := &arrowBody{}
+		for _, c := range columns {
+			nullCount := c.buffers(body, start, end)
+			body.nodes = append(body.nodes, [2]int64{int64(end - start), int64(nullCount)})
+		}
+		if err := writeArrowMessage(bw, arrowRecordBatch(int64(end-start), body), body.data); err != nil {
+			return err
+		}
+	}
+	// End of stream marker
+	var eos [8]byte
+	binary.LittleEndian.PutUint32(eos[:], arrowContinuation)
+	if _, err := bw.Write(eos[:]); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func (t *Table) arrowColumns() []arrowColumn {
+	columns := []arrowColumn{
+		stringColumn("source", func(i int) string {
+			if int(t.sources[i]) < len(sources) {
+				return sources[t.sources[i]]
+			}
+			return ""
+		}),
+		intColumn("offset", 64, true, func(b []byte, i int) { binary.LittleEndian.PutUint64(b, uint64(t.offsets[i])) }),
+		uint64Column("record_number", t.recordNumbers),
+		uint16Column("sequence_number", t.sequenceNumbers),
+	}
+	if t.fields&FieldFlags != 0 {
+		columns = append(columns,
+			boolColumn("in_use", func(i int) bool { return t.flags[i]&flagInUse != 0 }),
+			boolColumn("directory", func(i int) bool { return t.flags[i]&flagDirectory != 0 }),
+			intColumn("attributes", 32, false, func(b []byte, i int) { binary.LittleEndian.PutUint32(b, t.fileAttributes[i]) }),
+		)
+	}
+	if t.fields&FieldNames != 0 {
+		columns = append(columns, stringColumn("name", t.Name))
+	}
+	if t.fields&FieldParents != 0 {
+		columns = append(columns, uint64Column("parent_record_number", t.parentRecordNumbers), uint16Column("parent_sequence_number", t.parentSequenceNumbers))
+	}
+	if t.fields&FieldSizes != 0 {
+		columns = append(columns, uint64Column("size", t.sizes), uint64Column("allocated_size", t.allocatedSizes))
+	}
+	if t.fields&FieldTimes != 0 {
+		for i, name := range []string{"si_created", "si_modified", "si_mft_modified", "si_accessed"} {
+			columns = append(columns, timestampColumn(name, t.times[i]))
+		}
+	}
+	if t.fields&FieldFileNameTimes != 0 {
+		for i, name := range []string{"fn_created", "fn_modified", "fn_mft_modified", "fn_accessed"} {
+			columns = append(columns, timestampColumn(name, t.fileNameTimes[i]))
+		}
+	}
+	return columns
+}
+
+func intColumn(name string, bitWidth int, signed bool, put func(b []byte, i int)) arrowColumn {
+	size := bitWidth / 8
+	return arrowColumn{name: name, typeType: arrowTypeInt, bitWidth: bitWidth, signed: signed, buffers: func(body *arrowBody, start, end int) int {
+		values := make([]byte, (end-start)*size)
+		for i := start; i < end; i++ {
+			put(values[(i-start)*size:], i)
+		}
+		body.add(nil) // no validity bitmap: there are no nulls
+		body.add(values)
+		return 0
+	}}
+}
+
+func uint64Column(name string, values []uint64) arrowColumn {
+	return intColumn(name, 64, false, func(b []byte, i int) { binary.LittleEndian.PutUint64(b, values[i]) })
+}
+
+func uint16Column(name string, values []uint16) arrowColumn {
+	return intColumn(name, 16, false, func(b []byte, i int) { binary.LittleEndian.PutUint16(b, values[i]) })
+}
+
+func boolColumn(name string, value func(i int) bool) arrowColumn {
+	return arrowColumn{name: name, typeType: arrowTypeBool, buffers: func(body *arrowBody, start, end int) int {
+		bits := make([]byte, (end-start+7)/8)
+		for i := start; i < end; i++ {
+			if value(i) {
+				bits[(i-start)/8] |= 1 << uint((i-start)%8)
+			}
+		}
+		body.add(nil)
+		body.add(bits)
+		return 0
+	}}
+}
+
+func stringColumn(name string, value func(i int) string) arrowColumn {
+	return arrowColumn{name: name, typeType: arrowTypeUtf8, buffers: func(body *arrowBody, start, end int) int {
+		offsets := make([]byte, (end-start+1)*4)
+		data := make([]byte, 0)
+		for i := start; i < end; i++ {
+			data = append(data, value(i)...)
+			binary.LittleEndian.PutUint32(offsets[(i-start+1)*4:], uint32(len(data)))
+		}
+		body.add(nil)
+		body.add(offsets)
+		body.add(data)
+		return 0
+	}}
+}
+
+// Limits of the file times that can be represented as nanoseconds since the Unix epoch in an int64.
+var (
+	minArrowFileTime = mft.ConvertToFileTime(time.Unix(0, math.MinInt64))
+	maxArrowFileTime = mft.ConvertToFileTime(time.Unix(0, math.MaxInt64))
+)
+
+func timestampColumn(name string, fileTimes []uint64) arrowColumn {
+	return arrowColumn{name: name, typeType: arrowTypeTimestamp, buffers: func(body *arrowBody, start, end int) int {
+		validity := make([]byte, (end-start+7)/8)
+		values := make([]byte, (end-start)*8)
+		nullCount := 0
+		for i := start; i < end; i++ {
+			ft := fileTimes[i]
+			if ft == 0 || ft <= minArrowFileTime || ft >= maxArrowFileTime {
+				nullCount++
+				continue
+			}
+			validity[(i-start)/8] |= 1 << uint((i-start)%8)
+			binary.LittleEndian.PutUint64(values[(i-start)*8:], uint64(mft.ConvertFileTime(ft).UnixNano()))
+		}
+		if nullCount == 0 {
+			validity = nil
+		}
+		body.add(validity)
+		body.add(values)
+		return nullCount
+	}}
+}
+
+func arrowSchema(columns []arrowColumn) []byte {
+	fb := newFlatBuilder()
+	fields := make([]int, len(columns))
+	for i, c := range columns {
+		var typeOffset int
+		switch c.typeType {
+		case arrowTypeInt:
+			fb.startTable(2)
+			fb.addUint32(0, uint32(c.bitWidth))
+			if c.signed {
+				fb.addUint8(1, 1)
+			}
+			typeOffset = fb.endTable()
+		case arrowTypeTimestamp:
+			timezone := fb.createString("UTC")
+			fb.startTable(2)
+			fb.addOffset(1, timezone)
+			fb.addUint16(0, arrowTimeUnitNanosecond)
+			typeOffset = fb.endTable()
+		default:
+			// Utf8 and Bool are empty tables
+			fb.startTable(0)
+			typeOffset = fb.endTable()
+		}
+		name := fb.createString(c.name)
+		children := fb.createOffsetVector(nil)
+
+		fb.startTable(7)
+		fb.addOffset(0, name)
+		fb.addOffset(3, typeOffset)
+		fb.addOffset(5, children)
+		fb.addUint8(1, 1) // nullable
+		fb.addUint8(2, c.typeType)
+		fields[i] = fb.endTable()
+	}
+	fieldVector := fb.createOffsetVector(fields)
+
+	fb.startTable(4)
+	fb.addOffset(1, fieldVector)
+	schema := fb.endTable()
+	return arrowMessage(fb, arrowHeaderSchema, schema, 0)
+}
+
+func arrowRecordBatch(length int64, body *arrowBody) []byte {
+	fb := newFlatBuilder()
+	buffers := fb.createStructVector(body.buffers)
+	nodes := fb.createStructVector(body.nodes)
+
+	fb.startTable(5)
+	fb.addUint64(0, uint64(length))
+	fb.addOffset(1, nodes)
+	fb.addOffset(2, buffers)
+	batch := fb.endTable()
+	return arrowMessage(fb, arrowHeaderRecordBatch, batch, int64(len(body.data)))
+}
+
+func arrowMessage(fb *flatBuilder, headerType byte, header int, bodyLength int64) []byte {
+	fb.startTable(5)
+	fb.addUint64(3, uint64(bodyLength))
+	fb.addOffset(2, header)
+	fb.addUint16(0, arrowMetadataVersionV5)
+	fb.addUint8(1, headerType)
+	return fb.finish(fb.endTable())
+}
+
+// writeArrowMessage writes an encapsulated message: the continuation marker, the size of the metadata, the metadata
+// (padded to a multiple of 8 bytes) and the body.
+func writeArrowMessage(w io.Writer, metadata []byte, body []byte) error {
+	padded := (len(metadata) + 7) &^ 7
+	prefix := make([]byte, 8, 8+padded)
+	binary.LittleEndian.PutUint32(prefix, arrowContinuation)
+	binary.LittleEndian.PutUint32(prefix[4:], uint32(padded))
+	prefix = append(prefix, metadata...)
+	prefix = append(prefix, make([]byte, padded-len(metadata))...)
+	if _, err := w.Write(prefix); err != nil {
+		return err
+	}
+	_, err := w.Write(body)
+	return err
+}
@@ -0,0 +1,215 @@
+package columnar_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/columnar"
+)
+
+func TestTable_WriteArrow(t *testing.T) {
+	table := columnar.NewTable(columnar.FieldAll)
+	for _, e := range testEntries() {
+		table.Append(e)
+	}
+	buf := &bytes.Buffer{}
+	require.Nil(t, table.WriteArrow(buf, 2))
+
+	messages := readArrowMessages(t, buf.Bytes())
+	require.Equal(t, 3, len(messages))
+
+	schema := messages[0]
+	assert.Equal(t, uint16(4), schema.version)
+	assert.Equal(t, byte(1), schema.headerType)
+	fields := schema.header.vector(1)
+	names := make([]string, len(fields))
+	for i, f := range fields {
+		names[i] = f.table().string(0)
+	}
+	assert.Equal(t, []string{"source", "offset", "record_number", "sequence_number", "in_use", "directory", "attributes",
+		"name", "parent_record_number", "parent_sequence_number", "size", "allocated_size", "si_created", "si_modified",
+		"si_mft_modified", "si_accessed", "fn_created", "fn_modified", "fn_mft_modified", "fn_accessed"}, names)
+
+	sequenceNumber := fields[3].table()
+	assert.Equal(t, byte(2), sequenceNumber.uint8(2))
+	assert.Equal(t, uint32(16), sequenceNumber.table(3).uint32(0))
+	assert.Equal(t, byte(0), sequenceNumber.table(3).uint8(1))
+	offset := fields[1].table()
+	assert.Equal(t, byte(1), offset.table(3).uint8(1))
+	assert.Equal(t, byte(5), fields[0].table().uint8(2))
+	assert.Equal(t, byte(6), fields[4].table().uint8(2))
+	siCreated := fields[12].table()
+	assert.Equal(t, byte(10), siCreated.uint8(2))
+	assert.Equal(t, uint16(3), siCreated.table(3).uint16(0))
+	assert.Equal(t, "UTC", siCreated.table(3).string(1))
+
+	first := messages[1]
+	assert.Equal(t, byte(3), first.headerType)
+	assert.Equal(t, uint64(2), first.header.uint64(0))
+	nodes := first.header.structs(1)
+	require.Equal(t, len(fields), len(nodes))
+	assert.Equal(t, [2]uint64{2, 0}, nodes[2])
+	assert.Equal(t, [2]uint64{2, 1}, nodes[12]) // the first entry has no $STANDARD_INFORMATION times
+
+	// source: validity, offsets, data
+	assert.Equal(t, "index-slackrecord", string(first.buffer(2)))
+	assert.Equal(t, []byte{0, 0, 0, 0, 11, 0, 0, 0, 17, 0, 0, 0}, first.buffer(1))
+	// record_number: validity, values
+	assert.Equal(t, 0, len(first.buffer(5)))
+	assert.Equal(t, []byte{0x5f, 0xac, 0x06, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, first.buffer(6))
+	// in_use bitmap
+	assert.Equal(t, []byte{2}, first.buffer(10))
+	// si_created: validity, values
+	siBuffer := 10 + 2 + 2 + 3 + 2*4 + 1
+	assert.Equal(t, []byte{2}, first.buffer(siBuffer))
+	values := first.buffer(siBuffer + 1)
+	assert.Equal(t, time.Date(2011, time.June, 20, 15, 6, 9, 679374800, time.UTC).UnixNano(), int64(binary.LittleEndian.Uint64(values[8:])))
+
+	second := messages[2]
+	assert.Equal(t, uint64(1), second.header.uint64(0))
+	assert.Equal(t, [2]uint64{1, 0}, second.header.structs(1)[4])
+	assert.Equal(t, []byte{1}, second.buffer(12)) // directory bitmap
+}
+
+func TestTable_WriteArrowEmpty(t *testing.T) {
+	buf := &bytes.Buffer{}
+	require.Nil(t, columnar.NewTable(0).WriteArrow(buf, 0))
+
+	messages := readArrowMessages(t, buf.Bytes())
+	require.Equal(t, 1, len(messages))
+	assert.Equal(t, 4, len(messages[0].header.vector(1)))
+}
+
+type arrowMessage struct {
+	version    uint16
+	headerType byte
+	header     flatTable
+	body       []byte
+}
+
+// buffer returns the body buffer at index i of a record batch message.
+func (m arrowMessage) buffer(i int) []byte {
+	b := m.header.structs(2)[i]
+	return m.body[b[0] : b[0]+b[1]]
+}
+
+// readArrowMessages reads the messages of an Arrow IPC stream, checking the framing and the end of stream marker.
+func readArrowMessages(t *testing.T, b []byte) []arrowMessage {
+	messages := make([]arrowMessage, 0)
+	for {
+		require.True(t, len(b) >= 8)
+		require.Equal(t, uint32(0xFFFFFFFF), binary.LittleEndian.Uint32(b))
+		length := int(binary.LittleEndian.Uint32(b[4:]))
+		if length == 0 {
+			assert.Equal(t, 8, len(b))
+			return messages
+		}
+		require.Equal(t, 0, length%8)
+		metadata := b[8 : 8+length]
+		root := flatTable{b: metadata, pos: int(binary.LittleEndian.Uint32(metadata))}
+		bodyLength := int(root.uint64(3))
+		require.Equal(t, 0, bodyLength%8)
+		messages = append(messages, arrowMessage{
+			version:    root.uint16(0),
+			headerType: root.uint8(1),
+			header:     root.table(2),
+			body:       b[8+length : 8+length+bodyLength],
+		})
+		b = b[8+length+bodyLength:]
+	}
+}
+
+// flatTable is a minimal reader of FlatBuffers tables.
+type flatTable struct {
+	b   []byte
+	pos int
+}
+
+// field returns the position of the field in the given slot, or 0 when it is not set.
+func (f flatTable) field(slot int) int {
+	vtable := f.pos - int(int32(binary.LittleEndian.Uint32(f.b[f.pos:])))
+	if 4+2*slot >= int(binary.LittleEndian.Uint16(f.b[vtable:])) {
+		return 0
+	}
+	offset := int(binary.LittleEndian.Uint16(f.b[vtable+4+2*slot:]))
+	if offset == 0 {
+		return 0
+	}
+	return f.pos + offset
+}
+
+func (f flatTable) uint8(slot int) byte {
+	if p := f.field(slot); p != 0 {
+		return f.b[p]
+	}
+	return 0
+}
+
+func (f flatTable) uint16(slot int) uint16 {
+	if p := f.field(slot); p != 0 {
+		return binary.LittleEndian.Uint16(f.b[p:])
+	}
+	return 0
+}
+
+func (f flatTable) uint32(slot int) uint32 {
+	if p := f.field(slot); p != 0 {
+		return binary.LittleEndian.Uint32(f.b[p:])
+	}
+	return 0
+}
+
+func (f flatTable) uint64(slot int) uint64 {
+	if p := f.field(slot); p != 0 {
+		return binary.LittleEndian.Uint64(f.b[p:])
+	}
+	return 0
+}
+
+func (f flatTable) deref(slot int) int {
+	p := f.field(slot)
+	return p + int(binary.LittleEndian.Uint32(f.b[p:]))
+}
+
+func (f flatTable) table(slot int) flatTable {
+	return flatTable{b: f.b, pos: f.deref(slot)}
+}
+
+func (f flatTable) string(slot int) string {
+	p := f.deref(slot)
+	return string(f.b[p+4 : p+4+int(binary.LittleEndian.Uint32(f.b[p:]))])
+}
+
+// vector returns the elements of a vector of tables, as references to be resolved using table.
+func (f flatTable) vector(slot int) []flatReference {
+	p := f.deref(slot)
+	elements := make([]flatReference, binary.LittleEndian.Uint32(f.b[p:]))
+	for i := range elements {
+		elements[i] = flatReference{b: f.b, pos: p + 4 + 4*i}
+	}
+	return elements
+}
+
+// structs returns the elements of a vector of structs consisting of two 64 bit integers.
+func (f flatTable) structs(slot int) [][2]uint64 {
+	p := f.deref(slot)
+	elements := make([][2]uint64, binary.LittleEndian.Uint32(f.b[p:]))
+	for i := range elements {
+		elements[i][0] = binary.LittleEndian.Uint64(f.b[p+4+16*i:])
+		elements[i][1] = binary.LittleEndian.Uint64(f.b[p+12+16*i:])
+	}
+	return elements
+}
+
+type flatReference struct {
+	b   []byte
+	pos int
+}
+
+func (r flatReference) table() flatTable {
+	return flatTable{b: r.b, pos: r.pos + int(binary.LittleEndian.Uint32(r.b[r.pos:]))}
+}
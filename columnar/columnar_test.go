@@ -0,0 +1,148 @@
+package columnar_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/columnar"
+	"github.com/t9t/gomft/export"
+	"github.com/t9t/gomft/mft"
+)
+
+func TestTable_All(t *testing.T) {
+	table := columnar.NewTable(columnar.FieldAll)
+	entries := testEntries()
+	for _, e := range entries {
+		table.Append(e)
+	}
+
+	assert.Equal(t, len(entries), table.Len())
+	for i, e := range entries {
+		assert.Equal(t, e, table.Entry(i))
+		assert.Equal(t, e.Name, table.Name(i))
+	}
+	assert.Equal(t, []uint64{437343, 0, 12}, table.RecordNumbers())
+	assert.Equal(t, []uint64{13, 1920466944, 0}, table.Sizes())
+}
+
+func TestTable_Selected(t *testing.T) {
+	table := columnar.NewTable(columnar.FieldNames | columnar.FieldFlags)
+	for _, e := range testEntries() {
+		table.Append(e)
+	}
+
+	assert.Equal(t, export.Entry{
+		Source:         export.SourceRecord,
+		Offset:         1024,
+		RecordNumber:   0,
+		SequenceNumber: 145,
+		InUse:          true,
+		Name:           "$MFT",
+		FileAttributes: mft.FileAttributeHidden | mft.FileAttributeSystem,
+	}, table.Entry(1))
+	assert.Equal(t, "", table.Entry(2).Name)
+	assert.Nil(t, table.Sizes())
+}
+
+func TestTable_NoNames(t *testing.T) {
+	table := columnar.NewTable(columnar.FieldSizes)
+	table.Append(testEntries()[0])
+	assert.Equal(t, "", table.Name(0))
+}
+
+func TestTable_TimePrecision(t *testing.T) {
+	table := columnar.NewTable(columnar.FieldTimes)
+	table.Append(export.Entry{Creation: time.Date(2020, time.February, 5, 14, 59, 38, 116886299, time.UTC)})
+	assert.Equal(t, time.Date(2020, time.February, 5, 14, 59, 38, 116886200, time.UTC), table.Entry(0).Creation)
+}
+
+func TestTable_WriteToReadTable(t *testing.T) {
+	for _, fields := range []columnar.Fields{columnar.FieldAll, columnar.FieldNames | columnar.FieldSizes, 0} {
+		table := columnar.NewTable(fields)
+		for _, e := range testEntries() {
+			table.Append(e)
+		}
+
+		buf := &bytes.Buffer{}
+		n, err := table.WriteTo(buf)
+		require.Nilf(t, err, "fields 0x%x: %v", fields, err)
+		assert.Equal(t, int64(buf.Len()), n)
+
+		read, err := columnar.ReadTable(buf)
+		require.Nilf(t, err, "fields 0x%x: %v", fields, err)
+		assert.Equal(t, fields, read.Fields())
+		require.Equal(t, table.Len(), read.Len())
+		for i := 0; i < table.Len(); i++ {
+			assert.Equal(t, table.Entry(i), read.Entry(i))
+		}
+	}
+}
+
+func TestReadTable_Invalid(t *testing.T) {
+	table := columnar.NewTable(columnar.FieldAll)
+	table.Append(testEntries()[0])
+	buf := &bytes.Buffer{}
+	_, err := table.WriteTo(buf)
+	require.Nil(t, err)
+
+	_, err = columnar.ReadTable(bytes.NewReader(buf.Bytes()[:buf.Len()-1]))
+	assert.EqualError(t, err, "unable to read table columns: unexpected EOF")
+
+	_, err = columnar.ReadTable(bytes.NewReader([]byte{0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0}))
+	assert.EqualError(t, err, "unknown fields 0x1000000")
+
+	_, err = columnar.ReadTable(bytes.NewReader([]byte{0x3f, 0, 0, 0, 0, 0, 0, 0, 2, 0, 0, 0}))
+	assert.EqualError(t, err, "table length 8589934592 exceeds the maximum of 4294967296")
+}
+
+func testEntries() []export.Entry {
+	fnTime := time.Date(2020, time.February, 5, 14, 59, 38, 116886200, time.UTC)
+	mftTime := time.Date(2011, time.June, 20, 15, 6, 9, 679374800, time.UTC)
+	return []export.Entry{
+		{
+			Source:                   export.SourceIndexSlack,
+			Offset:                   2112,
+			RecordNumber:             437343,
+			SequenceNumber:           6,
+			ParentRecordNumber:       429113,
+			ParentSequenceNumber:     59,
+			Name:                     "test.txt",
+			Size:                     13,
+			AllocatedSize:            16,
+			FileAttributes:           mft.FileAttributeArchive,
+			FileNameCreation:         fnTime,
+			FileNameFileLastModified: fnTime,
+			FileNameMftLastModified:  fnTime,
+			FileNameLastAccess:       fnTime,
+		},
+		{
+			Source:                   export.SourceRecord,
+			Offset:                   1024,
+			RecordNumber:             0,
+			SequenceNumber:           145,
+			InUse:                    true,
+			ParentRecordNumber:       5,
+			ParentSequenceNumber:     5,
+			Name:                     "$MFT",
+			Size:                     1920466944,
+			AllocatedSize:            1920466944,
+			FileAttributes:           mft.FileAttributeHidden | mft.FileAttributeSystem,
+			Creation:                 mftTime,
+			FileLastModified:         mftTime,
+			MftLastModified:          mftTime,
+			LastAccess:               mftTime,
+			FileNameCreation:         mftTime,
+			FileNameFileLastModified: mftTime,
+			FileNameMftLastModified:  mftTime,
+			FileNameLastAccess:       mftTime,
+		},
+		{
+			Source:       export.SourceRecord,
+			RecordNumber: 12,
+			Directory:    true,
+		},
+	}
+}
@@ -0,0 +1,155 @@
+package columnar
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// maxEncodedLen limits the number of entries accepted by ReadTable, to fail early on corrupt input instead of trying
+// to allocate huge columns.
+const maxEncodedLen = 1 << 32
+
+// WriteTo writes the Table in a compact binary format that can be read back using ReadTable. Each column is written as
+// a whole, in Little Endian order.
+func (t *Table) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	write := func(data interface{}) {
+		if cw.err == nil {
+			cw.err = binary.Write(cw, binary.LittleEndian, data)
+		}
+	}
+
+	write(uint32(t.fields))
+	write(uint64(t.Len()))
+	write(t.sources)
+	write(t.offsets)
+	write(t.recordNumbers)
+	write(t.sequenceNumbers)
+	if t.fields&FieldFlags != 0 {
+		write(t.flags)
+		write(t.fileAttributes)
+	}
+	if t.fields&FieldNames != 0 {
+		write(t.nameOffsets)
+		write([]byte(t.names.String()))
+	}
+	if t.fields&FieldParents != 0 {
+		write(t.parentRecordNumbers)
+		write(t.parentSequenceNumbers)
+	}
+	if t.fields&FieldSizes != 0 {
+		write(t.sizes)
+		write(t.allocatedSizes)
+	}
+	if t.fields&FieldTimes != 0 {
+		for _, column := range t.times {
+			write(column)
+		}
+	}
+	if t.fields&FieldFileNameTimes != 0 {
+		for _, column := range t.fileNameTimes {
+			write(column)
+		}
+	}
+	if cw.err == nil {
+		cw.err = cw.w.(*bufio.Writer).Flush()
+	}
+	return cw.n, cw.err
+}
+
+// ReadTable reads a Table written by Table.WriteTo.
+func ReadTable(r io.Reader) (*Table, error) {
+	br := bufio.NewReader(r)
+	var err error
+	read := func(data interface{}) {
+		if err == nil {
+			err = binary.Read(br, binary.LittleEndian, data)
+		}
+	}
+
+	var fields uint32
+	var n uint64
+	read(&fields)
+	read(&n)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read table header: %v", err)
+	}
+	if Fields(fields)&^FieldAll != 0 {
+		return nil, fmt.Errorf("unknown fields 0x%x", fields)
+	}
+	if n > maxEncodedLen {
+		return nil, fmt.Errorf("table length %d exceeds the maximum of %d", n, uint64(maxEncodedLen))
+	}
+
+	t := &Table{fields: Fields(fields)}
+	t.sources = make([]byte, n)
+	t.offsets = make([]int64, n)
+	t.recordNumbers = make([]uint64, n)
+	t.sequenceNumbers = make([]uint16, n)
+	read(t.sources)
+	read(t.offsets)
+	read(t.recordNumbers)
+	read(t.sequenceNumbers)
+	if t.fields&FieldFlags != 0 {
+		t.flags = make([]byte, n)
+		t.fileAttributes = make([]uint32, n)
+		read(t.flags)
+		read(t.fileAttributes)
+	}
+	if t.fields&FieldNames != 0 {
+		t.nameOffsets = make([]uint32, n)
+		read(t.nameOffsets)
+		for i := uint64(1); err == nil && i < n; i++ {
+			if t.nameOffsets[i] < t.nameOffsets[i-1] {
+				err = fmt.Errorf("name offset %d is smaller than the previous one", i)
+			}
+		}
+		if err == nil && n > 0 {
+			names := make([]byte, t.nameOffsets[n-1])
+			read(names)
+			t.names.Write(names)
+		}
+	}
+	if t.fields&FieldParents != 0 {
+		t.parentRecordNumbers = make([]uint64, n)
+		t.parentSequenceNumbers = make([]uint16, n)
+		read(t.parentRecordNumbers)
+		read(t.parentSequenceNumbers)
+	}
+	if t.fields&FieldSizes != 0 {
+		t.sizes = make([]uint64, n)
+		t.allocatedSizes = make([]uint64, n)
+		read(t.sizes)
+		read(t.allocatedSizes)
+	}
+	if t.fields&FieldTimes != 0 {
+		for i := range t.times {
+			t.times[i] = make([]uint64, n)
+			read(t.times[i])
+		}
+	}
+	if t.fields&FieldFileNameTimes != 0 {
+		for i := range t.fileNameTimes {
+			t.fileNameTimes[i] = make([]uint64, n)
+			read(t.fileNameTimes[i])
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read table columns: %v", err)
+	}
+	return t, nil
+}
+
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
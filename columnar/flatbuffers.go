@@ -0,0 +1,153 @@
+package columnar
+
+import "encoding/binary"
+
+// flatBuilder builds a FlatBuffer, as used for the metadata of Arrow IPC messages. Like the official builders, it
+// builds the buffer back to front: objects are prepended, so an object referring to another one is always located
+// before it, as required by the unsigned offsets of the format. Offsets returned by the methods are measured from the
+// end of the buffer. It only supports what is needed for Arrow metadata.
+type flatBuilder struct {
+	b        []byte // the buffer being built; b[0] is the first byte of the last prepended object
+	minAlign int
+	object   int   // offset of the start of the table being built
+	fields   []int // offsets of the fields of the table being built, 0 when not set
+}
+
+func newFlatBuilder() *flatBuilder {
+	return &flatBuilder{minAlign: 1}
+}
+
+func (fb *flatBuilder) offset() int {
+	return len(fb.b)
+}
+
+func (fb *flatBuilder) prepend(p ...byte) {
+	b := make([]byte, len(p)+len(fb.b))
+	copy(b, p)
+	copy(b[len(p):], fb.b)
+	fb.b = b
+}
+
+// prep adds padding so that, after prepending additional bytes, the start of the buffer is aligned to size.
+func (fb *flatBuilder) prep(size int, additional int) {
+	if size > fb.minAlign {
+		fb.minAlign = size
+	}
+	padding := (size - (len(fb.b)+additional)%size) % size
+	fb.prepend(make([]byte, padding)...)
+}
+
+func (fb *flatBuilder) prependUint16(v uint16) {
+	fb.prep(2, 0)
+	fb.prepend(byte(v), byte(v>>8))
+}
+
+func (fb *flatBuilder) prependUint32(v uint32) {
+	fb.prep(4, 0)
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], v)
+	fb.prepend(b[:]...)
+}
+
+func (fb *flatBuilder) prependUint64(v uint64) {
+	fb.prep(8, 0)
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], v)
+	fb.prepend(b[:]...)
+}
+
+// prependOffset prepends a reference to the object at off.
+func (fb *flatBuilder) prependOffset(off int) {
+	fb.prep(4, 0)
+	fb.prependUint32(uint32(len(fb.b) + 4 - off))
+}
+
+func (fb *flatBuilder) createString(s string) int {
+	fb.prep(4, len(s)+1)
+	fb.prepend(0)
+	fb.prepend([]byte(s)...)
+	fb.prependUint32(uint32(len(s)))
+	return fb.offset()
+}
+
+func (fb *flatBuilder) createOffsetVector(offsets []int) int {
+	fb.prep(4, 4*len(offsets))
+	for i := len(offsets) - 1; i >= 0; i-- {
+		fb.prependOffset(offsets[i])
+	}
+	fb.prependUint32(uint32(len(offsets)))
+	return fb.offset()
+}
+
+// createStructVector creates a vector of structs consisting of two 64 bit integers each, like the FieldNode and
+// Buffer structs of Arrow.
+func (fb *flatBuilder) createStructVector(values [][2]int64) int {
+	fb.prep(4, 16*len(values))
+	fb.prep(8, 16*len(values))
+	for i := len(values) - 1; i >= 0; i-- {
+		fb.prependUint64(uint64(values[i][1]))
+		fb.prependUint64(uint64(values[i][0]))
+	}
+	fb.prependUint32(uint32(len(values)))
+	return fb.offset()
+}
+
+func (fb *flatBuilder) startTable(numFields int) {
+	fb.fields = make([]int, numFields)
+	fb.object = fb.offset()
+}
+
+func (fb *flatBuilder) addUint8(slot int, v uint8) {
+	fb.prep(1, 0)
+	fb.prepend(v)
+	fb.fields[slot] = fb.offset()
+}
+
+func (fb *flatBuilder) addUint16(slot int, v uint16) {
+	fb.prependUint16(v)
+	fb.fields[slot] = fb.offset()
+}
+
+func (fb *flatBuilder) addUint32(slot int, v uint32) {
+	fb.prependUint32(v)
+	fb.fields[slot] = fb.offset()
+}
+
+func (fb *flatBuilder) addUint64(slot int, v uint64) {
+	fb.prependUint64(v)
+	fb.fields[slot] = fb.offset()
+}
+
+func (fb *flatBuilder) addOffset(slot int, off int) {
+	fb.prependOffset(off)
+	fb.fields[slot] = fb.offset()
+}
+
+// endTable writes the vtable of the table being built and returns the offset of the table.
+func (fb *flatBuilder) endTable() int {
+	fb.prependUint32(0) // placeholder for the offset to the vtable
+	table := fb.offset()
+
+	for i := len(fb.fields) - 1; i >= 0; i-- {
+		voffset := uint16(0)
+		if fb.fields[i] != 0 {
+			voffset = uint16(table - fb.fields[i])
+		}
+		fb.prependUint16(voffset)
+	}
+	fb.prependUint16(uint16(table - fb.object))
+	fb.prependUint16(uint16(4 + 2*len(fb.fields)))
+	vtable := fb.offset()
+
+	// The vtable is located before the table, at table position minus the (signed) offset
+	binary.LittleEndian.PutUint32(fb.b[len(fb.b)-table:], uint32(int32(vtable-table)))
+	fb.fields = nil
+	return table
+}
+
+// finish prepends the reference to the root table and returns the finished buffer.
+func (fb *flatBuilder) finish(root int) []byte {
+	fb.prep(fb.minAlign, 4)
+	fb.prependOffset(root)
+	return fb.b
+}
@@ -0,0 +1,164 @@
+/*
+	Package crosslink detects cross-linked files: distinct records whose data runs claim the same clusters. NTFS never
+	allocates a cluster to more than one file, so such overlaps indicate corruption of the MFT or the $Bitmap, or data
+	runs which were tampered with to hide data in (or read data from) another file.
+
+	Basic usage
+
+	Add all records of an MFT to a Detector, then call Overlaps() to obtain the conflicting records and the clusters
+	they share.
+			// Error handling left out for brevity
+			d := crosslink.NewDetector(crosslink.Options{})
+			for result := range mft.ParseAll(in, mft.ParseAllOptions{SkipEmpty: true}) {
+				if result.Err == nil {
+					err = d.Add(uint64(result.Index), result.Record)
+				}
+			}
+			for _, o := range d.Overlaps() {
+				fmt.Printf("records %d and %d share %d clusters from cluster %d\n",
+					o.First.Reference.RecordNumber, o.Second.Reference.RecordNumber, o.Length, o.Cluster)
+			}
+
+	Implementation notes
+
+	Each non-resident attribute is converted into extents (ranges of clusters) using mft.DataRunExtents, which merges
+	consecutive runs that directly follow each other on the volume and leaves out sparse runs, since they do not occupy
+	clusters. Attributes in extension records are attributed to their
+	base record, and overlaps between the attributes of a single record are not reported.
+
+	By default, only records which are in use are considered. The clusters of deleted files are released and may have
+	been allocated to other files since, so including deleted records (IncludeDeleted) mostly finds reused clusters
+	rather than corruption. That is useful too, for example to find which file overwrote the data of a deleted file.
+
+	Overlaps are found by sorting all extents by their first cluster and sweeping over them while keeping the extents
+	which have not ended yet, so memory use is proportional to the total number of extents.
+*/
+package crosslink
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/t9t/gomft/mft"
+)
+
+// Options control which records a Detector considers.
+type Options struct {
+	// IncludeDeleted also considers the data runs of records which are not in use.
+	IncludeDeleted bool
+}
+
+// Extent is a contiguous range of clusters occupied by an attribute of a record. The Reference is that of the base
+// record, also for attributes found in extension records.
+type Extent struct {
+	Reference mft.FileReference
+	Type      mft.AttributeType
+	Name      string
+	Cluster   uint64
+	Length    uint64
+}
+
+// End returns the cluster following the last cluster of the Extent.
+func (e Extent) End() uint64 {
+	return e.Cluster + e.Length
+}
+
+// Overlap describes the clusters claimed by extents of two distinct records. The First extent starts at or before the
+// Second extent. Cluster and Length give the range of clusters claimed by both.
+type Overlap struct {
+	First   Extent
+	Second  Extent
+	Cluster uint64
+	Length  uint64
+}
+
+// IsDuplicate indicates whether both extents span exactly the same clusters, which typically means the data runs of
+// one record were copied from the other.
+func (o Overlap) IsDuplicate() bool {
+	return o.First.Cluster == o.Second.Cluster && o.First.Length == o.Second.Length
+}
+
+func (o Overlap) String() string {
+	return fmt.Sprintf("clusters %d-%d claimed by record %d (%s %q) and record %d (%s %q)", o.Cluster,
+		o.Cluster+o.Length-1, o.First.Reference.RecordNumber, o.First.Type.Name(), o.First.Name,
+		o.Second.Reference.RecordNumber, o.Second.Type.Name(), o.Second.Name)
+}
+
+// Detector collects the extents of records to detect overlaps between them. A Detector is not safe for concurrent
+// use.
+type Detector struct {
+	opts    Options
+	extents []Extent
+}
+
+// NewDetector creates an empty Detector.
+func NewDetector(opts Options) *Detector {
+	return &Detector{opts: opts}
+}
+
+// Add adds the clusters claimed by the non-resident attributes of a record to the Detector. The number is the record
+// number, the position of the record in the MFT. Records which are not in use are ignored unless IncludeDeleted is
+// set. When the data runs of an attribute cannot be parsed, an error is returned after adding the other attributes.
+func (d *Detector) Add(number uint64, r mft.Record) error {
+	if !r.IsInUse() && !d.opts.IncludeDeleted {
+		return nil
+	}
+	reference := mft.FileReference{RecordNumber: number, SequenceNumber: r.FileReference.SequenceNumber}
+	if r.IsExtension() {
+		reference = r.BaseRecordReference
+	}
+
+	var firstErr error
+	for _, a := range r.Attributes {
+		if a.Resident {
+			continue
+		}
+		runs, err := mft.ParseDataRuns(a.Data)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("unable to parse data runs of %s attribute %q of record %d: %v", a.Type.Name(), a.Name, number, err)
+			}
+			continue
+		}
+		for _, x := range mft.DataRunExtents(runs) {
+			d.extents = append(d.extents, Extent{Reference: reference, Type: a.Type, Name: a.Name, Cluster: x.Cluster, Length: x.Length})
+		}
+	}
+	return firstErr
+}
+
+// Overlaps returns all overlaps between extents of distinct records, ordered by their first cluster. An extent
+// overlapping the extents of multiple other records is reported once for each of them.
+func (d *Detector) Overlaps() []Overlap {
+	extents := append([]Extent(nil), d.extents...)
+	sort.SliceStable(extents, func(i, j int) bool {
+		return extents[i].Cluster < extents[j].Cluster
+	})
+
+	overlaps := make([]Overlap, 0)
+	active := make([]Extent, 0)
+	for _, e := range extents {
+		// Drop the extents which ended before this one starts
+		n := 0
+		for _, a := range active {
+			if a.End() > e.Cluster {
+				active[n] = a
+				n++
+			}
+		}
+		active = active[:n]
+
+		for _, a := range active {
+			if a.Reference.RecordNumber == e.Reference.RecordNumber {
+				continue
+			}
+			end := a.End()
+			if e.End() < end {
+				end = e.End()
+			}
+			overlaps = append(overlaps, Overlap{First: a, Second: e, Cluster: e.Cluster, Length: end - e.Cluster})
+		}
+		active = append(active, e)
+	}
+	return overlaps
+}
@@ -0,0 +1,72 @@
+package crosslink_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/crosslink"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/mfttest"
+)
+
+func data(number uint64, cluster uint64, length uint64) crosslink.Extent {
+	return crosslink.Extent{Reference: mfttest.Reference(number), Type: mft.AttributeTypeData, Cluster: cluster, Length: length}
+}
+
+func TestDetector(t *testing.T) {
+	d := crosslink.NewDetector(crosslink.Options{})
+	add := func(number uint64, b *mfttest.RecordBuilder) {
+		require.Nil(t, d.Add(number, b.Record()))
+	}
+	// 100-109 and 110-119 (contiguous, merged), sparse, 200-204
+	add(40, mfttest.File(4096, mfttest.Run(100, 10), mfttest.Run(10, 10), mfttest.SparseRun(50), mfttest.Run(90, 5)))
+	// 105-107, overlapping 40
+	add(41, mfttest.File(4096, mfttest.Run(105, 3)))
+	// 300-309, not overlapping anything
+	add(42, mfttest.File(4096, mfttest.Run(300, 10)))
+	// 200-204, a duplicate of the last extent of 40, in an extension record of 43
+	add(44, mfttest.File(4096, mfttest.Run(200, 5)).WithBaseRecord(mfttest.Reference(43)))
+	// 118-125 in a named stream, overlapping 40; 1000-1009 claimed twice by itself
+	add(45, mfttest.NewRecord().WithAttribute(mft.Attribute{Type: mft.AttributeTypeData, Name: "ads", AllocatedSize: 4096 * 8,
+		Data: mfttest.EncodeDataRuns([]mft.DataRun{mfttest.Run(118, 8), mfttest.Run(882, 10)})}).WithNonResidentData(4096, mfttest.Run(1000, 10)))
+	// Deleted file claiming 100-199
+	add(46, mfttest.File(4096, mfttest.Run(100, 100)).WithFlags(0))
+
+	overlaps := d.Overlaps()
+	ads := crosslink.Extent{Reference: mfttest.Reference(45), Type: mft.AttributeTypeData, Name: "ads", Cluster: 118, Length: 8}
+	assert.Equal(t, []crosslink.Overlap{
+		{First: data(40, 100, 20), Second: data(41, 105, 3), Cluster: 105, Length: 3},
+		{First: data(40, 100, 20), Second: ads, Cluster: 118, Length: 2},
+		{First: data(40, 200, 5), Second: data(43, 200, 5), Cluster: 200, Length: 5},
+	}, overlaps)
+	assert.False(t, overlaps[0].IsDuplicate())
+	assert.True(t, overlaps[2].IsDuplicate())
+	assert.Equal(t, `clusters 118-119 claimed by record 40 ($DATA "") and record 45 ($DATA "ads")`, overlaps[1].String())
+}
+
+func TestDetector_LeadingSparse(t *testing.T) {
+	d := crosslink.NewDetector(crosslink.Options{})
+	// $Boot starts at cluster 0; a file starting with a hole (like $UsnJrnl:$J) does not claim it
+	require.Nil(t, d.Add(7, mfttest.File(4096, mfttest.Run(0, 2)).Record()))
+	require.Nil(t, d.Add(40, mfttest.File(4096, mfttest.SparseRun(16), mfttest.Run(300, 4)).Record()))
+	assert.Empty(t, d.Overlaps())
+}
+
+func TestDetector_IncludeDeleted(t *testing.T) {
+	d := crosslink.NewDetector(crosslink.Options{IncludeDeleted: true})
+	require.Nil(t, d.Add(40, mfttest.File(4096, mfttest.Run(100, 10)).Record()))
+	require.Nil(t, d.Add(46, mfttest.File(4096, mfttest.Run(95, 10)).WithFlags(0).Record()))
+	assert.Equal(t, []crosslink.Overlap{
+		{First: data(46, 95, 10), Second: data(40, 100, 10), Cluster: 100, Length: 5},
+	}, d.Overlaps())
+}
+
+func TestDetector_InvalidDataRuns(t *testing.T) {
+	d := crosslink.NewDetector(crosslink.Options{})
+	r := mfttest.File(4096, mfttest.Run(100, 10)).WithAttribute(mft.Attribute{Type: mft.AttributeTypeData, Name: "bad", Data: []byte{0x48}}).Record()
+	err := d.Add(40, r)
+	assert.NotNil(t, err)
+	require.Nil(t, d.Add(41, mfttest.File(4096, mfttest.Run(109, 1)).Record()))
+	assert.Equal(t, 1, len(d.Overlaps()), "valid attributes should still be added")
+}
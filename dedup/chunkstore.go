@@ -0,0 +1,253 @@
+package dedup
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/mftindex"
+	"github.com/t9t/gomft/volume"
+	"github.com/t9t/gomft/wof"
+)
+
+const (
+	containerSignature   = "Cthr"
+	chunkSignature       = "Ckhr"
+	chunkHeaderLength    = 0x58
+	chunkFlagCompressed  = 0x1
+	maxChunkSize         = 16 * 1024 * 1024
+	streamMapSignature   = "Smap"
+	streamMapHeaderSize  = 0x10
+	streamMapEntryLength = 0x40
+)
+
+// OpenFunc opens a file of the chunk store. The name is relative to ChunkStoreDirectory and uses backslashes, like
+// `{GUID}.ddp\Data\00000001.00000001.ccc`.
+type OpenFunc func(name string) (*io.SectionReader, error)
+
+// VolumeOpener creates an OpenFunc which opens the files of the chunk store on the volume, using the index to find
+// their records. Only the unnamed $DATA attribute in the base record of a file is read, so very fragmented containers
+// of which the data runs continue in extension records cannot be opened.
+func VolumeOpener(v *volume.Volume, idx *mftindex.Index) OpenFunc {
+	return func(name string) (*io.SectionReader, error) {
+		path := ChunkStoreDirectory + `\` + name
+		number, ok := idx.Lookup(path)
+		if !ok {
+			return nil, fmt.Errorf("%s not found", path)
+		}
+		r, err := v.ReadRecord(number)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read record %d of %s: %v", number, path, err)
+		}
+		for _, a := range r.FindAttributes(mft.AttributeTypeData) {
+			if a.Name == "" {
+				return v.OpenAttribute(a)
+			}
+		}
+		return nil, fmt.Errorf("%s has no $DATA attribute in its base record", path)
+	}
+}
+
+// Chunk is an entry of a stream map: a chunk in the Data directory of the chunk store, and the part of the file it
+// contains.
+type Chunk struct {
+	ChunkId
+	FileOffset uint64 // the offset in the file of the data of the chunk
+	Size       uint32 // the size of the (uncompressed) data of the chunk
+}
+
+// ChunkStore reads stream maps and chunks from the containers of a chunk store. Containers are opened once and kept
+// open. It is safe for concurrent use when the readers returned by its OpenFunc are.
+type ChunkStore struct {
+	open OpenFunc
+
+	mu         sync.Mutex
+	containers map[string]*io.SectionReader
+}
+
+// NewChunkStore creates a ChunkStore opening its containers using open.
+func NewChunkStore(open OpenFunc) *ChunkStore {
+	return &ChunkStore{open: open, containers: make(map[string]*io.SectionReader)}
+}
+
+// StreamMap reads the stream map of a deduplicated file, listing the chunks which make up its contents in order. The
+// chunks must be contiguous and cover exactly the size of the file.
+func (s *ChunkStore) StreamMap(ref Reference) ([]Chunk, error) {
+	b, err := s.readChunk(ref.ChunkStore, "Stream", ref.StreamMap)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read stream map: %v", err)
+	}
+	if len(b) < streamMapHeaderSize || string(b[:4]) != streamMapSignature {
+		return nil, fmt.Errorf("stream map does not start with signature %q", streamMapSignature)
+	}
+	count := int(binary.LittleEndian.Uint32(b[0x08:]))
+	if len(b) < streamMapHeaderSize+count*streamMapEntryLength {
+		return nil, fmt.Errorf("stream map of %d bytes is too short for %d entries", len(b), count)
+	}
+
+	chunks := make([]Chunk, 0, count)
+	next := uint64(0)
+	for i := 0; i < count; i++ {
+		e := b[streamMapHeaderSize+i*streamMapEntryLength:]
+		c := Chunk{
+			ChunkId: ChunkId{
+				Container:  binary.LittleEndian.Uint32(e[0x00:]),
+				Generation: binary.LittleEndian.Uint32(e[0x04:]),
+				Offset:     binary.LittleEndian.Uint64(e[0x08:]),
+			},
+			FileOffset: binary.LittleEndian.Uint64(e[0x10:]),
+			Size:       binary.LittleEndian.Uint32(e[0x18:]),
+		}
+		if c.FileOffset != next {
+			return nil, fmt.Errorf("chunk %d starts at offset %d, but expected %d", i, c.FileOffset, next)
+		}
+		next += uint64(c.Size)
+		chunks = append(chunks, c)
+	}
+	if next != ref.Size {
+		return nil, fmt.Errorf("chunks cover %d bytes, but the file is %d bytes", next, ref.Size)
+	}
+	return chunks, nil
+}
+
+// ReadChunk reads the data of a chunk from the Data directory of the chunk store, decompressing it when needed.
+func (s *ChunkStore) ReadChunk(chunkStore string, id ChunkId) ([]byte, error) {
+	return s.readChunk(chunkStore, "Data", id)
+}
+
+// NewReader creates a Reader over the contents of a deduplicated file. The stream map is read immediately.
+func (s *ChunkStore) NewReader(ref Reference) (*Reader, error) {
+	chunks, err := s.StreamMap(ref)
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{store: s, chunkStore: ref.ChunkStore, size: int64(ref.Size), chunks: chunks, chunkIndex: -1}, nil
+}
+
+func (s *ChunkStore) readChunk(chunkStore, directory string, id ChunkId) ([]byte, error) {
+	c, err := s.container(fmt.Sprintf(`%s.ddp\%s\%08X.%08X.ccc`, chunkStore, directory, id.Container, id.Generation))
+	if err != nil {
+		return nil, err
+	}
+	if id.Offset > uint64(c.Size()) || uint64(c.Size())-id.Offset < chunkHeaderLength {
+		return nil, fmt.Errorf("chunk at offset %d exceeds container of %d bytes", id.Offset, c.Size())
+	}
+	header := make([]byte, chunkHeaderLength)
+	if _, err := c.ReadAt(header, int64(id.Offset)); err != nil {
+		return nil, fmt.Errorf("unable to read chunk header at offset %d: %v", id.Offset, err)
+	}
+	if string(header[:4]) != chunkSignature {
+		return nil, fmt.Errorf("chunk at offset %d does not start with signature %q", id.Offset, chunkSignature)
+	}
+	storedSize := int64(binary.LittleEndian.Uint32(header[0x0C:]))
+	size := binary.LittleEndian.Uint32(header[0x10:])
+	flags := binary.LittleEndian.Uint32(header[0x14:])
+	if size > maxChunkSize {
+		return nil, fmt.Errorf("chunk at offset %d has invalid size %d", id.Offset, size)
+	}
+	start := int64(id.Offset) + chunkHeaderLength
+	if storedSize > c.Size()-start {
+		return nil, fmt.Errorf("data of %d bytes of chunk at offset %d exceeds container of %d bytes", storedSize, id.Offset, c.Size())
+	}
+
+	stored := make([]byte, storedSize)
+	if _, err := c.ReadAt(stored, start); err != nil && !(err == io.EOF && storedSize == 0) {
+		return nil, fmt.Errorf("unable to read data of chunk at offset %d: %v", id.Offset, err)
+	}
+	if flags&chunkFlagCompressed == 0 {
+		if storedSize != int64(size) {
+			return nil, fmt.Errorf("uncompressed chunk at offset %d stores %d bytes, but is %d bytes", id.Offset, storedSize, size)
+		}
+		return stored, nil
+	}
+	data := make([]byte, size)
+	if err := wof.Decompress(data, stored, wof.AlgorithmXpress4K); err != nil {
+		return nil, fmt.Errorf("unable to decompress chunk at offset %d: %v", id.Offset, err)
+	}
+	return data, nil
+}
+
+// container returns the opened container file with the name, opening it when needed.
+func (s *ChunkStore) container(name string) (*io.SectionReader, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, ok := s.containers[name]; ok {
+		return c, nil
+	}
+	c, err := s.open(name)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open container %s: %v", name, err)
+	}
+	signature := make([]byte, len(containerSignature))
+	if _, err := c.ReadAt(signature, 0); err != nil || !bytes.Equal(signature, []byte(containerSignature)) {
+		return nil, fmt.Errorf("container %s does not start with signature %q", name, containerSignature)
+	}
+	s.containers[name] = c
+	return c, nil
+}
+
+// Reader reads the contents of a deduplicated file from the chunk store. It is safe for concurrent use when its
+// ChunkStore is.
+type Reader struct {
+	store      *ChunkStore
+	chunkStore string
+	size       int64
+	chunks     []Chunk
+
+	mu         sync.Mutex
+	chunk      []byte
+	chunkIndex int
+}
+
+// Size returns the size of the contents of the file.
+func (r *Reader) Size() int64 {
+	return r.size
+}
+
+// ReadAt reads len(p) bytes of the contents starting at position off. When fewer than len(p) bytes are available, it
+// returns the number of bytes read and io.EOF.
+func (r *Reader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset %d", off)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := 0
+	for n < len(p) && off < r.size {
+		index := sort.Search(len(r.chunks), func(i int) bool {
+			return int64(r.chunks[i].FileOffset+uint64(r.chunks[i].Size)) > off
+		})
+		if err := r.loadChunk(index); err != nil {
+			return n, err
+		}
+		copied := copy(p[n:], r.chunk[off-int64(r.chunks[index].FileOffset):])
+		n += copied
+		off += int64(copied)
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// loadChunk reads the chunk with the specified index into r.chunk, unless it is already there.
+func (r *Reader) loadChunk(index int) error {
+	if index == r.chunkIndex {
+		return nil
+	}
+	c := r.chunks[index]
+	data, err := r.store.ReadChunk(r.chunkStore, c.ChunkId)
+	if err != nil {
+		return fmt.Errorf("unable to read chunk %d: %v", index, err)
+	}
+	if len(data) != int(c.Size) {
+		return fmt.Errorf("chunk %d is %d bytes, but the stream map lists %d bytes", index, len(data), c.Size)
+	}
+	r.chunk, r.chunkIndex = data, index
+	return nil
+}
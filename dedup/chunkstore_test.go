@@ -0,0 +1,221 @@
+package dedup_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/dedup"
+	"github.com/t9t/gomft/mftindex"
+	"github.com/t9t/gomft/mfttest"
+	"github.com/t9t/gomft/volume"
+)
+
+const testChunkStore = "{04030201-0605-0807-090A-0B0C0D0E0F10}"
+
+func TestParseReparseData(t *testing.T) {
+	ref, err := dedup.ParseReparseData(dedupReparseData(12345, dedup.ChunkId{Container: 1, Generation: 2, Offset: 0x1000}))
+	require.Nilf(t, err, "unable to parse reparse data: %v", err)
+	assert.Equal(t, dedup.Reference{
+		Size:       12345,
+		ChunkStore: testChunkStore,
+		StreamMap:  dedup.ChunkId{Container: 1, Generation: 2, Offset: 0x1000},
+	}, ref)
+
+	_, err = dedup.ParseReparseData(make([]byte, 0x37))
+	assert.EqualError(t, err, "expected at least 56 bytes of reparse data but got 55")
+}
+
+func TestChunkStore_NewReader(t *testing.T) {
+	first := bytes.Repeat([]byte("first chunk "), 100)
+	second := bytes.Repeat([]byte{0x00, 0x7F, 0xFF, 0x10}, 500)
+	third := []byte("third")
+	data1, offsets1 := container(chunk(first, false), chunk(third, false))
+	data2, offsets2 := container(chunk(second, true))
+	chunks := []dedup.Chunk{
+		{ChunkId: dedup.ChunkId{Container: 1, Generation: 1, Offset: offsets1[0]}, FileOffset: 0, Size: uint32(len(first))},
+		{ChunkId: dedup.ChunkId{Container: 2, Generation: 3, Offset: offsets2[0]}, FileOffset: 1200, Size: uint32(len(second))},
+		{ChunkId: dedup.ChunkId{Container: 1, Generation: 1, Offset: offsets1[1]}, FileOffset: 3200, Size: uint32(len(third))},
+	}
+	streams, streamOffsets := container(chunk(make([]byte, 100), false), chunk(streamMap(chunks), false))
+	files := map[string][]byte{
+		testChunkStore + `.ddp\Stream\00000007.00000001.ccc`: streams,
+		testChunkStore + `.ddp\Data\00000001.00000001.ccc`:   data1,
+		testChunkStore + `.ddp\Data\00000002.00000003.ccc`:   data2,
+	}
+	ref := dedup.Reference{Size: 3205, ChunkStore: testChunkStore, StreamMap: dedup.ChunkId{Container: 7, Generation: 1, Offset: streamOffsets[1]}}
+
+	store := dedup.NewChunkStore(opener(files))
+	m, err := store.StreamMap(ref)
+	require.Nilf(t, err, "unable to read stream map: %v", err)
+	assert.Equal(t, chunks, m)
+
+	r, err := store.NewReader(ref)
+	require.Nilf(t, err, "unable to create reader: %v", err)
+	assert.Equal(t, int64(3205), r.Size())
+	contents, err := ioutil.ReadAll(io.NewSectionReader(r, 0, r.Size()))
+	require.Nilf(t, err, "unable to read contents: %v", err)
+	assert.Equal(t, append(append(append([]byte{}, first...), second...), third...), contents)
+
+	p := make([]byte, 10)
+	n, err := r.ReadAt(p, 1195)
+	require.Nil(t, err)
+	assert.Equal(t, 10, n)
+	assert.Equal(t, []byte("hunk \x00\x7F\xFF\x10\x00"), p)
+	n, err = r.ReadAt(p, 3200)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, "third", string(p[:n]))
+}
+
+func TestChunkStore_Invalid(t *testing.T) {
+	data, offsets := container(chunk([]byte("data"), false))
+	streamMapOf := func(chunks ...dedup.Chunk) map[string][]byte {
+		streams, _ := container(chunk(streamMap(chunks), false))
+		return map[string][]byte{
+			testChunkStore + `.ddp\Stream\00000001.00000001.ccc`: streams,
+			testChunkStore + `.ddp\Data\00000001.00000001.ccc`:   data,
+		}
+	}
+	ref := dedup.Reference{Size: 4, ChunkStore: testChunkStore, StreamMap: dedup.ChunkId{Container: 1, Generation: 1, Offset: 16}}
+	valid := dedup.Chunk{ChunkId: dedup.ChunkId{Container: 1, Generation: 1, Offset: offsets[0]}, Size: 4}
+
+	tests := []struct {
+		name     string
+		files    map[string][]byte
+		ref      dedup.Reference
+		expected string
+	}{
+		{"no container", map[string][]byte{}, ref, "unable to read stream map: unable to open container " + testChunkStore +
+			`.ddp\Stream\00000001.00000001.ccc: file does not exist`},
+		{"offset beyond container", streamMapOf(valid), dedup.Reference{Size: 4, ChunkStore: testChunkStore, StreamMap: dedup.ChunkId{Container: 1, Generation: 1, Offset: 1000}},
+			"unable to read stream map: chunk at offset 1000 exceeds container of 184 bytes"},
+		{"no chunk", streamMapOf(valid), dedup.Reference{Size: 4, ChunkStore: testChunkStore, StreamMap: dedup.ChunkId{Container: 1, Generation: 1, Offset: 0}},
+			`unable to read stream map: chunk at offset 0 does not start with signature "Ckhr"`},
+		{"size mismatch", streamMapOf(valid), dedup.Reference{Size: 5, ChunkStore: testChunkStore, StreamMap: ref.StreamMap},
+			"chunks cover 4 bytes, but the file is 5 bytes"},
+		{"gap", streamMapOf(dedup.Chunk{ChunkId: valid.ChunkId, FileOffset: 2, Size: 2}), ref,
+			"chunk 0 starts at offset 2, but expected 0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := dedup.NewChunkStore(opener(tt.files)).NewReader(tt.ref)
+			assert.EqualError(t, err, tt.expected)
+		})
+	}
+
+	files := streamMapOf(valid)
+	files[testChunkStore+`.ddp\Data\00000001.00000001.ccc`] = []byte("Xthr")
+	r, err := dedup.NewChunkStore(opener(files)).NewReader(ref)
+	require.Nilf(t, err, "unable to create reader: %v", err)
+	_, err = r.ReadAt(make([]byte, 4), 0)
+	assert.EqualError(t, err, "unable to read chunk 0: container "+testChunkStore+`.ddp\Data\00000001.00000001.ccc does not start with signature "Cthr"`)
+}
+
+func TestVolumeOpener(t *testing.T) {
+	data, _ := container(chunk([]byte("data"), false))
+	name := testChunkStore + `.ddp\Data\00000001.00000001.ccc`
+	v := mfttest.NewVolume().WithFile("/System Volume Information/Dedup/ChunkStore/"+testChunkStore+".ddp/Data/00000001.00000001.ccc", data).Build()
+	vol, err := volume.Open(bytes.NewReader(v.Data), volume.Options{})
+	require.Nilf(t, err, "unable to open volume: %v", err)
+	b := mftindex.NewBuilder()
+	for number := uint64(0); number < vol.RecordCount(); number++ {
+		if r, err := vol.ReadRecord(number); err == nil {
+			b.Add(number, r)
+		}
+	}
+	open := dedup.VolumeOpener(vol, b.Build())
+
+	r, err := open(name)
+	require.Nilf(t, err, "unable to open %s: %v", name, err)
+	contents, err := ioutil.ReadAll(r)
+	require.Nil(t, err)
+	assert.Equal(t, data, contents)
+
+	_, err = open("missing.ccc")
+	assert.EqualError(t, err, `System Volume Information\Dedup\ChunkStore\missing.ccc not found`)
+}
+
+func dedupReparseData(size uint64, streamMap dedup.ChunkId) []byte {
+	b := make([]byte, 0x38)
+	binary.LittleEndian.PutUint64(b[0x08:], size)
+	for i := 0; i < 16; i++ {
+		b[0x18+i] = byte(i + 1)
+	}
+	binary.LittleEndian.PutUint32(b[0x28:], streamMap.Container)
+	binary.LittleEndian.PutUint32(b[0x2C:], streamMap.Generation)
+	binary.LittleEndian.PutUint64(b[0x30:], streamMap.Offset)
+	return b
+}
+
+// opener opens the files of a chunk store from memory.
+func opener(files map[string][]byte) dedup.OpenFunc {
+	return func(name string) (*io.SectionReader, error) {
+		b, ok := files[name]
+		if !ok {
+			return nil, fmt.Errorf("file does not exist")
+		}
+		return io.NewSectionReader(bytes.NewReader(b), 0, int64(len(b))), nil
+	}
+}
+
+// container creates a container file holding the chunks, and returns it with the offset of each chunk.
+func container(chunks ...[]byte) ([]byte, []uint64) {
+	b := append([]byte("Cthr"), make([]byte, 12)...)
+	offsets := make([]uint64, 0, len(chunks))
+	for _, c := range chunks {
+		offsets = append(offsets, uint64(len(b)))
+		b = append(b, c...)
+	}
+	return b, offsets
+}
+
+// chunk creates a chunk with a header, storing the data as is or compressed.
+func chunk(data []byte, compressed bool) []byte {
+	stored := data
+	flags := uint32(0)
+	if compressed {
+		stored, flags = xpressLiterals(data), 1
+	}
+	h := make([]byte, 0x58)
+	copy(h, "Ckhr")
+	binary.LittleEndian.PutUint32(h[0x0C:], uint32(len(stored)))
+	binary.LittleEndian.PutUint32(h[0x10:], uint32(len(data)))
+	binary.LittleEndian.PutUint32(h[0x14:], flags)
+	return append(h, stored...)
+}
+
+func streamMap(chunks []dedup.Chunk) []byte {
+	b := make([]byte, 0x10+len(chunks)*0x40)
+	copy(b, "Smap")
+	binary.LittleEndian.PutUint32(b[0x08:], uint32(len(chunks)))
+	for i, c := range chunks {
+		e := b[0x10+i*0x40:]
+		binary.LittleEndian.PutUint32(e[0x00:], c.Container)
+		binary.LittleEndian.PutUint32(e[0x04:], c.Generation)
+		binary.LittleEndian.PutUint64(e[0x08:], c.Offset)
+		binary.LittleEndian.PutUint64(e[0x10:], c.FileOffset)
+		binary.LittleEndian.PutUint32(e[0x18:], c.Size)
+	}
+	return b
+}
+
+// xpressLiterals encodes the data in the XPRESS (LZ77+Huffman) format using only literals, with a code in which the
+// 256 literal symbols have a code length of 8, so the code of each literal is its own value. The data must not be
+// longer than a single block of 64 KiB.
+func xpressLiterals(data []byte) []byte {
+	b := append(bytes.Repeat([]byte{0x88}, 128), make([]byte, 128)...)
+	for i := 0; i < len(data); i += 2 {
+		// Each 16-bit Little Endian word holds two codes, starting at the most significant bit
+		var second byte
+		if i+1 < len(data) {
+			second = data[i+1]
+		}
+		b = append(b, second, data[i])
+	}
+	return append(b, 0, 0, 0, 0)
+}
@@ -0,0 +1,112 @@
+/*
+	Package dedup reads files deduplicated by Windows Server Data Deduplication. The data of such a file is moved into
+	the chunk store in the System Volume Information\Dedup directory, after which the clusters of its unnamed $DATA
+	attribute are freed: the attribute keeps its size, but is sparse, so reading it yields only zeroes. The file gets a
+	reparse point with tag mft.ReparseTagDedup, which refers to the stream map in the chunk store listing the chunks of
+	the file.
+
+	Basic usage
+
+	Check if a record is deduplicated using Detect, then parse its reparse data and read the contents of the file from
+	the chunk store. VolumeOpener opens the files of the chunk store on a volume, using an mftindex.Index to find them.
+			// Error handling left out for brevity
+			data, deduplicated, err := dedup.ReparseData(record)
+			if deduplicated {
+				ref, err := dedup.ParseReparseData(data)
+				store := dedup.NewChunkStore(dedup.VolumeOpener(v, idx))
+				r, err := store.NewReader(ref)
+				_, err = io.Copy(os.Stdout, io.NewSectionReader(r, 0, r.Size()))
+			}
+
+	Implementation notes
+
+	Microsoft has not documented the formats of the reparse data and of the chunk store, so they are implemented as
+	described by public reverse engineering of Windows Server 2012 R2 and later. The chunk store of a volume is a
+	directory named after its GUID, like {GUID}.ddp, with a Stream and a Data directory of container files (.ccc) named
+	after the number and generation of the container in hexadecimal, like 00000001.00000002.ccc. A container starts with
+	the signature "Cthr" and holds chunks, each of which has a "Ckhr" header followed by its data, which is stored as is
+	or compressed in the XPRESS (LZ77+Huffman) format.
+
+	The reparse data holds the size of the file, the GUID of the chunk store and the location (container number,
+	generation and offset) of a chunk in the Stream directory. Its data is the stream map, starting with the signature
+	"Smap", which lists the chunks in the Data directory that make up the contents of the file, in order. The Reader
+	keeps the most recently read chunk, so reading a file sequentially using small reads decompresses each chunk once.
+*/
+package dedup
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/t9t/gomft/mft"
+)
+
+// ChunkStoreDirectory is the path of the directory containing the chunk store, relative to the root of the volume.
+const ChunkStoreDirectory = `System Volume Information\Dedup\ChunkStore`
+
+// ErrChunkStore is the error reported for the unnamed $DATA attribute of a deduplicated file when no chunk store is
+// available to read its contents from.
+var ErrChunkStore = errors.New("the file is deduplicated; its data is in the Data Deduplication chunk store, which is not available")
+
+// ChunkId identifies a chunk in a container of the chunk store.
+type ChunkId struct {
+	Container  uint32 // the number of the container
+	Generation uint32 // the generation of the container, which is incremented when the container is compacted
+	Offset     uint64 // the offset of the chunk header in the container file
+}
+
+// Reference is the parsed reparse data of a deduplicated file.
+type Reference struct {
+	Size       uint64  // the size of the contents of the file
+	ChunkStore string  // the GUID of the chunk store, like "{4A2C7B8E-...}"
+	StreamMap  ChunkId // the chunk in the Stream directory containing the stream map of the file
+}
+
+const reparseDataLength = 0x38
+
+// Detect checks if the record is a file deduplicated by Data Deduplication, by looking for a reparse point with tag
+// mft.ReparseTagDedup. An error is returned when the reparse point is invalid.
+func Detect(r mft.Record) (bool, error) {
+	_, ok, err := ReparseData(r)
+	return ok, err
+}
+
+// ReparseData returns the data of the Data Deduplication reparse point of the record, which refers to the stream map
+// of the file in the chunk store. The boolean is false when the record has no such reparse point.
+func ReparseData(r mft.Record) ([]byte, bool, error) {
+	a, ok := r.FindFirstAttribute(mft.AttributeTypeReparsePoint)
+	if !ok {
+		return nil, false, nil
+	}
+	rp, err := mft.ParseReparsePoint(a.Data)
+	if err != nil {
+		return nil, false, fmt.Errorf("unable to parse reparse point: %v", err)
+	}
+	if rp.Tag != mft.ReparseTagDedup {
+		return nil, false, nil
+	}
+	return rp.Data, true, nil
+}
+
+// ParseReparseData parses the data of a Data Deduplication reparse point, as returned by ReparseData.
+func ParseReparseData(b []byte) (Reference, error) {
+	if len(b) < reparseDataLength {
+		return Reference{}, fmt.Errorf("expected at least %d bytes of reparse data but got %d", reparseDataLength, len(b))
+	}
+	return Reference{
+		Size:       binary.LittleEndian.Uint64(b[0x08:]),
+		ChunkStore: "{" + formatGUID(b[0x18:0x28]) + "}",
+		StreamMap: ChunkId{
+			Container:  binary.LittleEndian.Uint32(b[0x28:]),
+			Generation: binary.LittleEndian.Uint32(b[0x2C:]),
+			Offset:     binary.LittleEndian.Uint64(b[0x30:]),
+		},
+	}, nil
+}
+
+// formatGUID formats a GUID in its mixed endian binary form as an upper case string.
+func formatGUID(b []byte) string {
+	return fmt.Sprintf("%08X-%04X-%04X-%X-%X", binary.LittleEndian.Uint32(b), binary.LittleEndian.Uint16(b[4:]),
+		binary.LittleEndian.Uint16(b[6:]), b[8:10], b[10:16])
+}
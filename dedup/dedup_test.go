@@ -0,0 +1,47 @@
+package dedup_test
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/dedup"
+	"github.com/t9t/gomft/mft"
+)
+
+func reparsePoint(tag mft.ReparseTag, data []byte) mft.Attribute {
+	b := make([]byte, 8, 8+len(data))
+	binary.LittleEndian.PutUint32(b, uint32(tag))
+	binary.LittleEndian.PutUint16(b[4:], uint16(len(data)))
+	return mft.Attribute{Type: mft.AttributeTypeReparsePoint, Resident: true, Data: append(b, data...)}
+}
+
+func TestDetect(t *testing.T) {
+	r := mft.Record{Attributes: []mft.Attribute{reparsePoint(mft.ReparseTagDedup, []byte{1, 2, 3, 4})}}
+	deduplicated, err := dedup.Detect(r)
+	require.Nilf(t, err, "error detecting: %v", err)
+	assert.True(t, deduplicated)
+
+	data, ok, err := dedup.ReparseData(r)
+	require.Nilf(t, err, "error reading reparse data: %v", err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte{1, 2, 3, 4}, data)
+}
+
+func TestDetectOtherFiles(t *testing.T) {
+	for _, r := range []mft.Record{
+		{},
+		{Attributes: []mft.Attribute{reparsePoint(mft.ReparseTagWof, make([]byte, 16))}},
+	} {
+		deduplicated, err := dedup.Detect(r)
+		require.Nilf(t, err, "error detecting: %v", err)
+		assert.False(t, deduplicated)
+	}
+}
+
+func TestDetectInvalidReparsePoint(t *testing.T) {
+	r := mft.Record{Attributes: []mft.Attribute{{Type: mft.AttributeTypeReparsePoint, Resident: true, Data: []byte{1, 2}}}}
+	_, err := dedup.Detect(r)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,142 @@
+/*
+	Package dumpfile provides random access to the records of an MFT dump file, for tools which need only a handful of
+	records and should not iterate over (or index) the whole dump to find them.
+
+	Basic usage
+
+	Open a dump, then read records by their record number.
+			// Error handling left out for brevity
+			f, err := dumpfile.Open("/path/to/mft.dump", dumpfile.Options{Mmap: true})
+			defer f.Close()
+			fmt.Println("records:", f.RecordCount())
+			r, err := f.ReadRecord(5)
+
+	Implementation notes
+
+	Records are read on demand using positional reads (pread), or from a read-only memory mapping of the file when
+	Options.Mmap is set (see package mmap). Either way, a File is safe for concurrent use. Since the record number of a
+	record is its position in the dump, this only works for complete dumps, not for the reduced dumps of package
+	subset.
+*/
+package dumpfile
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/mmap"
+)
+
+// Options configures Open and New.
+type Options struct {
+	// RecordSize is the size of a single MFT record in bytes. When zero, 1024 is used.
+	RecordSize int
+	// Mmap maps the file into memory instead of reading records using positional reads. It is ignored by New.
+	Mmap bool
+	// Parse are the options used by ReadRecord to parse records. ZeroCopy is ignored, since the data of the dump is
+	// never modified.
+	Parse mft.ParseOptions
+}
+
+// File is an MFT dump of which records can be read by their record number.
+type File struct {
+	r          io.ReaderAt
+	data       []byte // the mapped data, when memory mapped
+	close      func() error
+	size       int64
+	recordSize int
+	parse      mft.ParseOptions
+}
+
+// Open opens the dump file at path.
+func Open(path string, opts Options) (*File, error) {
+	if opts.Mmap {
+		m, err := mmap.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		f := New(m, int64(m.Len()), opts)
+		f.data = m.Bytes()
+		f.close = m.Close
+		return f, nil
+	}
+
+	osFile, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := osFile.Stat()
+	if err != nil {
+		osFile.Close()
+		return nil, fmt.Errorf("unable to determine size of %s: %v", path, err)
+	}
+	f := New(osFile, info.Size(), opts)
+	f.close = osFile.Close
+	return f, nil
+}
+
+// New creates a File reading the dump of size bytes from r. Closing the File does not close r.
+func New(r io.ReaderAt, size int64, opts Options) *File {
+	recordSize := opts.RecordSize
+	if recordSize == 0 {
+		recordSize = 1024
+	}
+	parse := opts.Parse
+	parse.ZeroCopy = false
+	return &File{r: r, close: func() error { return nil }, size: size, recordSize: recordSize, parse: parse}
+}
+
+// RecordSize returns the size of a single record in bytes.
+func (f *File) RecordSize() int {
+	return f.recordSize
+}
+
+// RecordCount returns the number of records in the dump. An incomplete record at the end of the dump is not counted.
+func (f *File) RecordCount() uint64 {
+	return uint64(f.size / int64(f.recordSize))
+}
+
+// ReadRecordData returns the raw data of the record with the number, as it is stored in the dump (ie. without the
+// fixup applied). When the File is memory mapped, the returned slice is read-only and must not be used after the File
+// is closed.
+func (f *File) ReadRecordData(number uint64) ([]byte, error) {
+	if count := f.RecordCount(); number >= count {
+		return nil, fmt.Errorf("record %d is beyond the end of the dump of %d records", number, count)
+	}
+	offset := int64(number) * int64(f.recordSize)
+	if f.data != nil {
+		return f.data[offset : offset+int64(f.recordSize)], nil
+	}
+	b := make([]byte, f.recordSize)
+	if _, err := f.r.ReadAt(b, offset); err != nil {
+		return nil, fmt.Errorf("unable to read record %d: %v", number, err)
+	}
+	return b, nil
+}
+
+// ReadRecord reads and parses the record with the number. For a record with a LegacyHeader, the record number of its
+// FileReference is set to the number.
+func (f *File) ReadRecord(number uint64) (mft.Record, error) {
+	b, err := f.ReadRecordData(number)
+	if err != nil {
+		return mft.Record{}, err
+	}
+	r, err := mft.ParseRecordWithOptions(b, f.parse)
+	if err != nil {
+		return mft.Record{}, fmt.Errorf("unable to parse record %d: %v", number, err)
+	}
+	if r.LegacyHeader {
+		r.FileReference.RecordNumber = number
+	}
+	return r, nil
+}
+
+// Close closes the dump file, or unmaps it when it is memory mapped.
+func (f *File) Close() error {
+	err := f.close()
+	f.close = func() error { return nil }
+	f.data = nil
+	return err
+}
@@ -0,0 +1,65 @@
+package dumpfile_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/dumpfile"
+	"github.com/t9t/gomft/mfttest"
+)
+
+func TestOpen(t *testing.T) {
+	v := mfttest.NewVolume().WithFile("/a.txt", []byte("hello")).Build()
+	data := append(v.Mft(), 1, 2, 3) // an incomplete record at the end
+	f, err := ioutil.TempFile("", "dumpfile")
+	require.Nil(t, err)
+	defer os.Remove(f.Name())
+	_, err = f.Write(data)
+	require.Nil(t, err)
+	require.Nil(t, f.Close())
+
+	for _, mapped := range []bool{false, true} {
+		d, err := dumpfile.Open(f.Name(), dumpfile.Options{Mmap: mapped})
+		require.Nilf(t, err, "unable to open (mapped: %v): %v", mapped, err)
+		assert.Equal(t, 1024, d.RecordSize())
+		assert.Equal(t, uint64(len(data)/1024), d.RecordCount())
+
+		number := v.Records["/a.txt"]
+		r, err := d.ReadRecord(number)
+		require.Nilf(t, err, "unable to read record (mapped: %v): %v", mapped, err)
+		assert.Equal(t, number, r.FileReference.RecordNumber)
+		name, ok := r.PreferredFileName()
+		require.True(t, ok)
+		assert.Equal(t, "a.txt", name.Name)
+
+		b, err := d.ReadRecordData(number)
+		require.Nil(t, err)
+		assert.Equal(t, data[number*1024:(number+1)*1024], b)
+
+		_, err = d.ReadRecord(d.RecordCount())
+		assert.EqualError(t, err, "record 68 is beyond the end of the dump of 68 records")
+		_, err = d.ReadRecord(20)
+		assert.EqualError(t, err, "unable to parse record 20: unknown record signature: 0x00 0x00 0x00 0x00")
+		assert.Nil(t, d.Close())
+	}
+
+	_, err = dumpfile.Open(f.Name()+".missing", dumpfile.Options{})
+	assert.NotNil(t, err)
+}
+
+func TestNew(t *testing.T) {
+	record := mfttest.NewRecord().WithRecordNumber(1).WithFileName("b.txt").Bytes()
+	data := append(make([]byte, 1024), record...)
+	d := dumpfile.New(bytes.NewReader(data), int64(len(data)), dumpfile.Options{})
+	assert.Equal(t, uint64(2), d.RecordCount())
+	r, err := d.ReadRecord(1)
+	require.Nil(t, err)
+	assert.Equal(t, "b.txt", r.AllNames()[0].Name)
+
+	d = dumpfile.New(bytes.NewReader(data), int64(len(data)), dumpfile.Options{RecordSize: 4096})
+	assert.Equal(t, uint64(0), d.RecordCount())
+}
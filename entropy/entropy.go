@@ -0,0 +1,127 @@
+/*
+	Package entropy computes the Shannon entropy of the contents of files, in bits per byte. Encrypted, compressed and
+	packed data have an entropy close to 8, while text, documents and most executables are considerably lower, so a
+	high entropy in an unexpected place (such as an executable or a document) is worth a closer look.
+
+	Basic usage
+
+	Compute the entropy of data in memory using Shannon, or write data to a Counter when it is being read anyway.
+			e := entropy.Shannon(data)
+
+			c := &entropy.Counter{}
+			_, err := io.Copy(io.MultiWriter(out, c), in)
+			e = c.Entropy()
+
+	For large files, Sample reads only a number of blocks spread over the file.
+			e, err := entropy.Sample(r, size, entropy.Options{})
+
+	Implementation notes
+
+	The entropy is computed over the frequencies of the byte values: H = -sum(p * log2(p)). The order of the bytes is
+	not taken into account, so it does not distinguish random data from, for example, a sequence containing every byte
+	value equally often. In practice this is rarely an issue for files.
+
+	Sample reads the whole stream when it is at most Blocks times BlockSize bytes. Otherwise it reads Blocks blocks at
+	evenly spaced offsets, including the first and the last block, and computes the entropy over all of them together.
+	Headers and trailers are thus always included, but a small encrypted part in an otherwise plain file may be missed.
+*/
+package entropy
+
+import (
+	"fmt"
+	"io"
+	"math"
+)
+
+// Default values of the Options.
+const (
+	DefaultBlockSize = 64 * 1024
+	DefaultBlocks    = 16
+)
+
+// Shannon returns the Shannon entropy of the data in bits per byte, between 0 and 8. It is 0 for empty data.
+func Shannon(data []byte) float64 {
+	c := Counter{}
+	c.Write(data)
+	return c.Entropy()
+}
+
+// Counter counts the frequencies of byte values written to it, to compute their entropy. The zero value is ready for
+// use. A Counter is not safe for concurrent use.
+type Counter struct {
+	counts [256]uint64
+	total  uint64
+}
+
+// Write counts the bytes in p. It never fails.
+func (c *Counter) Write(p []byte) (int, error) {
+	for _, b := range p {
+		c.counts[b]++
+	}
+	c.total += uint64(len(p))
+	return len(p), nil
+}
+
+// Len returns the number of bytes counted.
+func (c *Counter) Len() uint64 {
+	return c.total
+}
+
+// Entropy returns the Shannon entropy of the bytes counted so far, in bits per byte. It is 0 when no bytes have been
+// counted.
+func (c *Counter) Entropy() float64 {
+	if c.total == 0 {
+		return 0
+	}
+	total := float64(c.total)
+	h := 0.0
+	for _, n := range c.counts {
+		if n == 0 {
+			continue
+		}
+		p := float64(n) / total
+		h -= p * math.Log2(p)
+	}
+	return h
+}
+
+// Options configures the blocks read by Sample.
+type Options struct {
+	// BlockSize is the size in bytes of each block. When zero, DefaultBlockSize is used.
+	BlockSize int
+	// Blocks is the number of blocks read from large streams. When zero, DefaultBlocks is used.
+	Blocks int
+}
+
+// Sample computes the entropy of a stream of size bytes read from r, reading only some blocks of large streams (see
+// the package documentation).
+func Sample(r io.ReaderAt, size int64, opts Options) (float64, error) {
+	if opts.BlockSize <= 0 {
+		opts.BlockSize = DefaultBlockSize
+	}
+	if opts.Blocks <= 0 {
+		opts.Blocks = DefaultBlocks
+	}
+	blockSize := int64(opts.BlockSize)
+	c := &Counter{}
+	if size <= blockSize*int64(opts.Blocks) {
+		if _, err := io.Copy(c, io.NewSectionReader(r, 0, size)); err != nil {
+			return 0, fmt.Errorf("unable to read stream: %v", err)
+		}
+		return c.Entropy(), nil
+	}
+
+	buf := make([]byte, blockSize)
+	step := (size - blockSize) / int64(opts.Blocks-1)
+	for i := 0; i < opts.Blocks; i++ {
+		offset := int64(i) * step
+		if i == opts.Blocks-1 {
+			offset = size - blockSize
+		}
+		if n, err := r.ReadAt(buf, offset); n < len(buf) {
+			return 0, fmt.Errorf("unable to read block at offset %d: %v", offset, err)
+		}
+		c.Write(buf)
+	}
+	return c.Entropy(), nil
+}
@@ -0,0 +1,76 @@
+package entropy_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/entropy"
+)
+
+// allBytes returns data containing every byte value n times.
+func allBytes(n int) []byte {
+	b := make([]byte, 0, 256*n)
+	for i := 0; i < n; i++ {
+		for v := 0; v < 256; v++ {
+			b = append(b, byte(v))
+		}
+	}
+	return b
+}
+
+func TestShannon(t *testing.T) {
+	assert.Equal(t, 0.0, entropy.Shannon(nil))
+	assert.Equal(t, 0.0, entropy.Shannon([]byte("aaaa")))
+	assert.Equal(t, 1.0, entropy.Shannon([]byte("abab")))
+	assert.Equal(t, 2.0, entropy.Shannon([]byte("abcd")))
+	assert.Equal(t, 8.0, entropy.Shannon(allBytes(3)))
+}
+
+func TestCounter(t *testing.T) {
+	c := &entropy.Counter{}
+	assert.Equal(t, 0.0, c.Entropy())
+	c.Write([]byte("ab"))
+	n, err := c.Write([]byte("cd"))
+	require.Nil(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, uint64(4), c.Len())
+	assert.Equal(t, 2.0, c.Entropy())
+}
+
+type failingReaderAt struct{}
+
+func (failingReaderAt) ReadAt([]byte, int64) (int, error) {
+	return 0, errors.New("broken disk")
+}
+
+func TestSample(t *testing.T) {
+	opts := entropy.Options{BlockSize: 256, Blocks: 4}
+
+	// Small streams are read in full; the data beyond the size is not included
+	data := append(allBytes(2), bytes.Repeat([]byte{0}, 512)...)
+	e, err := entropy.Sample(bytes.NewReader(data), 512, opts)
+	require.Nil(t, err)
+	assert.Equal(t, 8.0, e)
+
+	// Of large streams, only the blocks at 0, 1000, 2000 and the last 256 bytes (at 3000) are read
+	data = bytes.Repeat([]byte{'x'}, 3256)
+	copy(data[0:], allBytes(1))
+	copy(data[1000:], allBytes(1))
+	copy(data[2000:], allBytes(1))
+	copy(data[3000:], allBytes(1))
+	e, err = entropy.Sample(bytes.NewReader(data), int64(len(data)), opts)
+	require.Nil(t, err)
+	assert.Equal(t, 8.0, e)
+
+	e, err = entropy.Sample(bytes.NewReader(nil), 0, entropy.Options{})
+	require.Nil(t, err)
+	assert.Equal(t, 0.0, e)
+
+	_, err = entropy.Sample(failingReaderAt{}, 100, opts)
+	assert.EqualError(t, err, "unable to read stream: broken disk")
+	_, err = entropy.Sample(failingReaderAt{}, 10000, opts)
+	assert.EqualError(t, err, "unable to read block at offset 0: broken disk")
+}
@@ -0,0 +1,232 @@
+package export
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// caseContext is the JSON-LD context of the documents written by CASEWriter.
+var caseContext = map[string]string{
+	"kb":             "http://example.org/kb/",
+	"uco-core":       "https://ontology.unifiedcyberontology.org/uco/core/",
+	"uco-observable": "https://ontology.unifiedcyberontology.org/uco/observable/",
+	"xsd":            "http://www.w3.org/2001/XMLSchema#",
+	"gomft":          "https://github.com/t9t/gomft/ns/",
+}
+
+// caseNamespace is the namespace of the name based UUIDs used as identifiers of the objects written by CASEWriter.
+var caseNamespace = [16]byte{0x5c, 0x2e, 0x0f, 0x6b, 0x61, 0x3a, 0x4b, 0x0e, 0x9a, 0x47, 0x1d, 0x7f, 0x3e, 0x52, 0x8c, 0x91}
+
+// CASEWriter writes Entries as a single JSON-LD document of Cyber-investigation Analysis Standard Expression (CASE) /
+// Unified Cyber Ontology (UCO) objects, for interchange with DFIR platforms supporting that standard. Each Entry is
+// written as a uco-observable:File with a FileFacet (name, path, size, $STANDARD_INFORMATION times and allocation
+// status) and an NTFSFileFacet (record number, security ID and alternate data streams). Details without a UCO property,
+// like the $FILE_NAME times and the Source of the Entry (to tell recovered index entries apart), are written using
+// properties in the gomft namespace.
+//
+// The objects are written to the @graph one per line as they are written, so large MFTs do not have to be kept in
+// memory. Flush finishes the document; no Entries can be written after that. Identifiers are UUIDs derived from the
+// Source, Offset, record number and sequence number of the Entry, so they are the same when an MFT is exported again.
+type CASEWriter struct {
+	w        *bufio.Writer
+	started  bool
+	finished bool
+}
+
+type caseObject struct {
+	ID     string        `json:"@id"`
+	Type   string        `json:"@type"`
+	Facets []interface{} `json:"uco-core:hasFacet"`
+}
+
+type caseValue struct {
+	Type  string `json:"@type"`
+	Value string `json:"@value"`
+}
+
+type caseFileFacet struct {
+	ID               string     `json:"@id"`
+	Type             string     `json:"@type"`
+	FileName         string     `json:"uco-observable:fileName,omitempty"`
+	FilePath         string     `json:"uco-observable:filePath,omitempty"`
+	Extension        string     `json:"uco-observable:extension,omitempty"`
+	FileSystemType   string     `json:"uco-observable:fileSystemType"`
+	IsDirectory      bool       `json:"uco-observable:isDirectory"`
+	SizeInBytes      caseValue  `json:"uco-observable:sizeInBytes"`
+	AllocationStatus string     `json:"uco-observable:allocationStatus"`
+	Created          *caseValue `json:"uco-observable:observableCreatedTime,omitempty"`
+	Modified         *caseValue `json:"uco-observable:modifiedTime,omitempty"`
+	Accessed         *caseValue `json:"uco-observable:accessedTime,omitempty"`
+	MetadataChanged  *caseValue `json:"uco-observable:metadataChangeTime,omitempty"`
+}
+
+type caseNTFSFileFacet struct {
+	ID                       string       `json:"@id"`
+	Type                     string       `json:"@type"`
+	EntryID                  caseValue    `json:"uco-observable:entryID"`
+	AlternateDataStreams     []caseStream `json:"uco-observable:alternateDataStreams,omitempty"`
+	Source                   string       `json:"gomft:source"`
+	Offset                   caseValue    `json:"gomft:offset"`
+	SequenceNumber           caseValue    `json:"gomft:sequenceNumber"`
+	ParentRecordNumber       caseValue    `json:"gomft:parentRecordNumber"`
+	ParentSequenceNumber     caseValue    `json:"gomft:parentSequenceNumber"`
+	SecurityId               caseValue    `json:"gomft:securityId"`
+	FileAttributes           string       `json:"gomft:attributes,omitempty"`
+	ReparseTarget            string       `json:"gomft:reparseTarget,omitempty"`
+	FileNameCreation         *caseValue   `json:"gomft:fileNameCreatedTime,omitempty"`
+	FileNameFileLastModified *caseValue   `json:"gomft:fileNameModifiedTime,omitempty"`
+	FileNameMftLastModified  *caseValue   `json:"gomft:fileNameMetadataChangeTime,omitempty"`
+	FileNameLastAccess       *caseValue   `json:"gomft:fileNameAccessedTime,omitempty"`
+}
+
+type caseStream struct {
+	ID   string    `json:"@id"`
+	Type string    `json:"@type"`
+	Name string    `json:"uco-observable:name"`
+	Size caseValue `json:"uco-observable:size"`
+}
+
+// NewCASEWriter creates a CASEWriter which writes to w.
+func NewCASEWriter(w io.Writer) *CASEWriter {
+	return &CASEWriter{w: bufio.NewWriter(w)}
+}
+
+// Write writes the Entry as an object in the @graph of the document, writing the start of the document first if that
+// has not been done yet.
+func (w *CASEWriter) Write(e Entry) error {
+	if w.finished {
+		return errors.New("the CASE document is already finished")
+	}
+	separator := ",\n"
+	if !w.started {
+		if err := w.writeStart(); err != nil {
+			return err
+		}
+		separator = "\n"
+	}
+	b, err := json.Marshal(caseFile(e))
+	if err != nil {
+		return err
+	}
+	if _, err := w.w.WriteString(separator); err != nil {
+		return err
+	}
+	_, err = w.w.Write(b)
+	return err
+}
+
+// Flush finishes the document and writes any buffered data to the underlying io.Writer.
+func (w *CASEWriter) Flush() error {
+	if !w.finished {
+		if !w.started {
+			if err := w.writeStart(); err != nil {
+				return err
+			}
+		}
+		w.finished = true
+		if _, err := w.w.WriteString("\n]}\n"); err != nil {
+			return err
+		}
+	}
+	return w.w.Flush()
+}
+
+func (w *CASEWriter) writeStart() error {
+	w.started = true
+	context, err := json.Marshal(caseContext)
+	if err != nil {
+		return err
+	}
+	_, err = w.w.WriteString(`{"@context":` + string(context) + `,"@graph":[`)
+	return err
+}
+
+func caseFile(e Entry) caseObject {
+	id := caseUUID(e)
+	allocation := "Allocated"
+	if !e.InUse || e.Source == SourceIndexSlack {
+		allocation = "Unallocated"
+	}
+	streams := make([]caseStream, len(e.AlternateDataStreams))
+	for i, s := range e.AlternateDataStreams {
+		streams[i] = caseStream{
+			ID:   fmt.Sprintf("kb:alternate-data-stream-%s-%d", id, i),
+			Type: "uco-observable:AlternateDataStream",
+			Name: s.Name,
+			Size: caseInteger(strconv.FormatUint(s.Size, 10)),
+		}
+	}
+	return caseObject{
+		ID:   "kb:file-" + id,
+		Type: "uco-observable:File",
+		Facets: []interface{}{
+			caseFileFacet{
+				ID:               "kb:file-facet-" + id,
+				Type:             "uco-observable:FileFacet",
+				FileName:         e.Name,
+				FilePath:         e.Path,
+				Extension:        ecsExtension(e),
+				FileSystemType:   "NTFS",
+				IsDirectory:      e.Directory,
+				SizeInBytes:      caseInteger(strconv.FormatUint(e.Size, 10)),
+				AllocationStatus: allocation,
+				Created:          caseTime(e.Creation),
+				Modified:         caseTime(e.FileLastModified),
+				Accessed:         caseTime(e.LastAccess),
+				MetadataChanged:  caseTime(e.MftLastModified),
+			},
+			caseNTFSFileFacet{
+				ID:                       "kb:ntfs-file-facet-" + id,
+				Type:                     "uco-observable:NTFSFileFacet",
+				EntryID:                  caseInteger(strconv.FormatUint(e.RecordNumber, 10)),
+				AlternateDataStreams:     streams,
+				Source:                   e.Source,
+				Offset:                   caseInteger(strconv.FormatInt(e.Offset, 10)),
+				SequenceNumber:           caseInteger(strconv.FormatUint(uint64(e.SequenceNumber), 10)),
+				ParentRecordNumber:       caseInteger(strconv.FormatUint(e.ParentRecordNumber, 10)),
+				ParentSequenceNumber:     caseInteger(strconv.FormatUint(uint64(e.ParentSequenceNumber), 10)),
+				SecurityId:               caseInteger(strconv.FormatUint(uint64(e.SecurityId), 10)),
+				FileAttributes:           formatFileAttributes(e.FileAttributes),
+				ReparseTarget:            e.ReparseTarget,
+				FileNameCreation:         caseTime(e.FileNameCreation),
+				FileNameFileLastModified: caseTime(e.FileNameFileLastModified),
+				FileNameMftLastModified:  caseTime(e.FileNameMftLastModified),
+				FileNameLastAccess:       caseTime(e.FileNameLastAccess),
+			},
+		},
+	}
+}
+
+func caseInteger(v string) caseValue {
+	return caseValue{Type: "xsd:integer", Value: v}
+}
+
+func caseTime(t time.Time) *caseValue {
+	if t.IsZero() {
+		return nil
+	}
+	return &caseValue{Type: "xsd:dateTime", Value: t.Format(time.RFC3339Nano)}
+}
+
+// caseUUID returns a name based (version 5) UUID identifying the Entry.
+func caseUUID(e Entry) string {
+	name := make([]byte, 8+8+2)
+	binary.LittleEndian.PutUint64(name[0:], uint64(e.Offset))
+	binary.LittleEndian.PutUint64(name[8:], e.RecordNumber)
+	binary.LittleEndian.PutUint16(name[16:], e.SequenceNumber)
+	h := sha1.New()
+	h.Write(caseNamespace[:])
+	h.Write([]byte(e.Source))
+	h.Write(name)
+	u := h.Sum(nil)[:16]
+	u[6] = u[6]&0x0f | 0x50
+	u[8] = u[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
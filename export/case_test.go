@@ -0,0 +1,93 @@
+package export_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/export"
+	"github.com/t9t/gomft/mft"
+)
+
+func TestCASEWriter(t *testing.T) {
+	created := time.Date(2020, time.February, 5, 14, 59, 38, 116886200, time.UTC)
+	entry := export.Entry{
+		Source:               export.SourceRecord,
+		Offset:               65536,
+		RecordNumber:         64,
+		SequenceNumber:       2,
+		InUse:                true,
+		ParentRecordNumber:   5,
+		ParentSequenceNumber: 5,
+		Name:                 "setup.exe",
+		Path:                 "/Users/bob/setup.exe",
+		Size:                 12345,
+		FileAttributes:       mft.FileAttributeArchive,
+		Creation:             created,
+		FileNameCreation:     created,
+		SecurityId:           256,
+		AlternateDataStreams: []export.Stream{{Name: "Zone.Identifier", Size: 26}},
+	}
+	buf := &bytes.Buffer{}
+	w := export.NewCASEWriter(buf)
+	require.Nil(t, w.Write(entry))
+	require.Nil(t, w.Write(export.Entry{Source: export.SourceIndexSlack, Name: "old.txt"}))
+	require.Nil(t, w.Flush())
+	assert.EqualError(t, w.Write(entry), "the CASE document is already finished")
+	assert.Equal(t, 4, strings.Count(buf.String(), "\n"), "expected a line per object and the start and end")
+
+	var doc map[string]interface{}
+	require.Nil(t, json.Unmarshal(buf.Bytes(), &doc))
+	assert.Equal(t, "https://ontology.unifiedcyberontology.org/uco/observable/", doc["@context"].(map[string]interface{})["uco-observable"])
+	graph := doc["@graph"].([]interface{})
+	require.Len(t, graph, 2)
+
+	file := graph[0].(map[string]interface{})
+	assert.Equal(t, "uco-observable:File", file["@type"])
+	id := strings.TrimPrefix(file["@id"].(string), "kb:file-")
+	assert.Regexp(t, "^[0-9a-f]{8}-[0-9a-f]{4}-5[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$", id)
+	facets := file["uco-core:hasFacet"].([]interface{})
+	require.Len(t, facets, 2)
+	fileFacet := facets[0].(map[string]interface{})
+	assert.Equal(t, "uco-observable:FileFacet", fileFacet["@type"])
+	assert.Equal(t, "setup.exe", fileFacet["uco-observable:fileName"])
+	assert.Equal(t, "/Users/bob/setup.exe", fileFacet["uco-observable:filePath"])
+	assert.Equal(t, "exe", fileFacet["uco-observable:extension"])
+	assert.Equal(t, "Allocated", fileFacet["uco-observable:allocationStatus"])
+	assert.Equal(t, map[string]interface{}{"@type": "xsd:integer", "@value": "12345"}, fileFacet["uco-observable:sizeInBytes"])
+	assert.Equal(t, map[string]interface{}{"@type": "xsd:dateTime", "@value": "2020-02-05T14:59:38.1168862Z"}, fileFacet["uco-observable:observableCreatedTime"])
+	assert.NotContains(t, fileFacet, "uco-observable:modifiedTime")
+
+	ntfsFacet := facets[1].(map[string]interface{})
+	assert.Equal(t, "uco-observable:NTFSFileFacet", ntfsFacet["@type"])
+	assert.Equal(t, map[string]interface{}{"@type": "xsd:integer", "@value": "64"}, ntfsFacet["uco-observable:entryID"])
+	assert.Equal(t, "record", ntfsFacet["gomft:source"])
+	assert.Equal(t, "Archive", ntfsFacet["gomft:attributes"])
+	streams := ntfsFacet["uco-observable:alternateDataStreams"].([]interface{})
+	require.Len(t, streams, 1)
+	assert.Equal(t, "Zone.Identifier", streams[0].(map[string]interface{})["uco-observable:name"])
+
+	slack := graph[1].(map[string]interface{})["uco-core:hasFacet"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "Unallocated", slack["uco-observable:allocationStatus"])
+
+	// Identifiers are the same when exporting again
+	again := &bytes.Buffer{}
+	w = export.NewCASEWriter(again)
+	require.Nil(t, w.Write(entry))
+	require.Nil(t, w.Flush())
+	assert.Contains(t, again.String(), `"@id":"kb:file-`+id+`"`)
+}
+
+func TestCASEWriter_Empty(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := export.NewCASEWriter(buf)
+	require.Nil(t, w.Flush())
+	require.Nil(t, w.Flush())
+	var doc map[string]interface{}
+	require.Nilf(t, json.Unmarshal(buf.Bytes(), &doc), "invalid JSON: %s", buf.String())
+	assert.Equal(t, []interface{}{}, doc["@graph"])
+}
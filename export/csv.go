@@ -0,0 +1,91 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+)
+
+var csvHeader = entrySchema.Names()
+
+// schemaVersion is the SchemaVersion as written in each line.
+var schemaVersion = strconv.Itoa(SchemaVersion)
+
+// CSVWriter writes Entries as CSV, one line per Entry, preceded by a header line. Times are formatted as RFC 3339
+// with nanosecond precision; zero times are written as an empty string. The columns are described by EntrySchema.
+type CSVWriter struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+// NewCSVWriter creates a CSVWriter which writes to w.
+func NewCSVWriter(w io.Writer) *CSVWriter {
+	return &CSVWriter{w: csv.NewWriter(w)}
+}
+
+// Write writes the Entry as a single CSV line, writing the header line first if that has not been done yet.
+func (w *CSVWriter) Write(e Entry) error {
+	if !w.headerWritten {
+		if err := w.w.Write(csvHeader); err != nil {
+			return err
+		}
+		w.headerWritten = true
+	}
+	return w.w.Write([]string{
+		e.Source,
+		strconv.FormatInt(e.Offset, 10),
+		strconv.FormatUint(e.RecordNumber, 10),
+		strconv.FormatUint(uint64(e.SequenceNumber), 10),
+		strconv.FormatBool(e.InUse),
+		strconv.FormatBool(e.Directory),
+		strconv.FormatUint(e.ParentRecordNumber, 10),
+		strconv.FormatUint(uint64(e.ParentSequenceNumber), 10),
+		e.Name,
+		strconv.FormatUint(e.Size, 10),
+		strconv.FormatUint(e.AllocatedSize, 10),
+		formatFileAttributes(e.FileAttributes),
+		formatTime(e.Creation),
+		formatTime(e.FileLastModified),
+		formatTime(e.MftLastModified),
+		formatTime(e.LastAccess),
+		formatTime(e.FileNameCreation),
+		formatTime(e.FileNameFileLastModified),
+		formatTime(e.FileNameMftLastModified),
+		formatTime(e.FileNameLastAccess),
+		strconv.FormatBool(e.PendingDelete),
+		e.ContentType,
+		formatEntropy(e),
+		schemaVersion,
+		formatSizeOnDisk(e),
+	})
+}
+
+// Flush writes any buffered data to the underlying io.Writer.
+func (w *CSVWriter) Flush() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+// formatSizeOnDisk formats the size on disk, or an empty string when it is not known.
+func formatSizeOnDisk(e Entry) string {
+	if !e.HasSizeOnDisk {
+		return ""
+	}
+	return strconv.FormatUint(e.SizeOnDisk, 10)
+}
+
+// formatEntropy formats the entropy with 4 decimals, or as an empty string when it was not computed.
+func formatEntropy(e Entry) string {
+	if !e.HasEntropy {
+		return ""
+	}
+	return strconv.FormatFloat(e.Entropy, 'f', 4, 64)
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
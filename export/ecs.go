@@ -0,0 +1,218 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/t9t/gomft/mft"
+)
+
+// ECSVersion is the version of the Elastic Common Schema which the output of ECSWriter conforms to.
+const ECSVersion = "8.11.0"
+
+// ECSWriter writes Entries as JSON Lines with the field names of the Elastic Common Schema (ECS), for direct ingestion
+// into Elasticsearch or OpenSearch. The fields that have an ECS equivalent are written in the file object (such as
+// file.path, file.size and file.mtime); the remaining NTFS specific fields, like the record number and the $FILE_NAME
+// times, are written in the ntfs object.
+//
+// The @timestamp is the time the MFT record was last changed, according to the $STANDARD_INFORMATION or otherwise the
+// $FILE_NAME attribute. The event.action indicates the Source of the Entry.
+type ECSWriter struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+type ecsDocument struct {
+	Timestamp *time.Time `json:"@timestamp,omitempty"`
+	ECS       ecsECS     `json:"ecs"`
+	Event     ecsEvent   `json:"event"`
+	File      ecsFile    `json:"file"`
+	NTFS      ecsNTFS    `json:"ntfs"`
+}
+
+type ecsECS struct {
+	Version string `json:"version"`
+}
+
+type ecsEvent struct {
+	Kind     string   `json:"kind"`
+	Category []string `json:"category"`
+	Type     []string `json:"type"`
+	Action   string   `json:"action"`
+	Module   string   `json:"module"`
+	Dataset  string   `json:"dataset"`
+}
+
+type ecsFile struct {
+	Path       string     `json:"path,omitempty"`
+	Name       string     `json:"name"`
+	Extension  string     `json:"extension,omitempty"`
+	Directory  string     `json:"directory,omitempty"`
+	Type       string     `json:"type"`
+	Size       uint64     `json:"size"`
+	Inode      string     `json:"inode"`
+	TargetPath string     `json:"target_path,omitempty"`
+	MimeType   string     `json:"mime_type,omitempty"`
+	Attributes []string   `json:"attributes,omitempty"`
+	Created    *time.Time `json:"created,omitempty"`
+	Mtime      *time.Time `json:"mtime,omitempty"`
+	Ctime      *time.Time `json:"ctime,omitempty"`
+	Accessed   *time.Time `json:"accessed,omitempty"`
+}
+
+type ecsNTFS struct {
+	Offset                   int64      `json:"offset"`
+	RecordNumber             uint64     `json:"record_number"`
+	SequenceNumber           uint16     `json:"sequence_number"`
+	InUse                    bool       `json:"in_use"`
+	ParentRecordNumber       uint64     `json:"parent_record_number"`
+	ParentSequenceNumber     uint16     `json:"parent_sequence_number"`
+	AllocatedSize            uint64     `json:"allocated_size"`
+	FileAttributes           string     `json:"attributes"`
+	HardLinkCount            int        `json:"hard_link_count"`
+	HasAlternateDataStreams  bool       `json:"has_ads"`
+	SecurityId               uint32     `json:"security_id"`
+	UpdateSequenceNumber     uint64     `json:"usn"`
+	LogFileSequenceNumber    uint64     `json:"lsn"`
+	FileNameCreation         *time.Time `json:"fn_created,omitempty"`
+	FileNameFileLastModified *time.Time `json:"fn_modified,omitempty"`
+	FileNameMftLastModified  *time.Time `json:"fn_mft_modified,omitempty"`
+	FileNameLastAccess       *time.Time `json:"fn_accessed,omitempty"`
+	Entropy                  *float64   `json:"entropy,omitempty"`
+}
+
+// ecsAttributes maps file attributes to the values of file.attributes defined by ECS.
+var ecsAttributes = []struct {
+	a    mft.FileAttribute
+	name string
+}{
+	{mft.FileAttributeArchive, "archive"},
+	{mft.FileAttributeCompressed, "compressed"},
+	{mft.FileAttributeEncrypted, "encrypted"},
+	{mft.FileAttributeHidden, "hidden"},
+	{mft.FileAttributeReadOnly, "readonly"},
+	{mft.FileAttributeSystem, "system"},
+	{mft.FileAttributeTemporary, "temporary"},
+}
+
+// NewECSWriter creates an ECSWriter which writes to w.
+func NewECSWriter(w io.Writer) *ECSWriter {
+	bw := bufio.NewWriter(w)
+	return &ECSWriter{w: bw, enc: json.NewEncoder(bw)}
+}
+
+// Write writes the Entry as a single line containing an ECS JSON document.
+func (w *ECSWriter) Write(e Entry) error {
+	timestamp := e.MftLastModified
+	if timestamp.IsZero() {
+		timestamp = e.FileNameMftLastModified
+	}
+	return w.enc.Encode(ecsDocument{
+		Timestamp: timeOrNil(timestamp),
+		ECS:       ecsECS{Version: ECSVersion},
+		Event: ecsEvent{
+			Kind:     "event",
+			Category: []string{"file"},
+			Type:     []string{"info"},
+			Action:   ecsAction(e.Source),
+			Module:   "gomft",
+			Dataset:  "gomft.mft",
+		},
+		File: ecsFile{
+			Path:       e.Path,
+			Name:       e.Name,
+			Extension:  ecsExtension(e),
+			Directory:  ecsDirectory(e.Path),
+			Type:       ecsFileType(e),
+			Size:       e.Size,
+			Inode:      strconv.FormatUint(e.RecordNumber, 10),
+			TargetPath: e.ReparseTarget,
+			MimeType:   e.ContentType,
+			Attributes: ecsFileAttributes(e),
+			Created:    timeOrNil(e.Creation),
+			Mtime:      timeOrNil(e.FileLastModified),
+			Ctime:      timeOrNil(e.MftLastModified),
+			Accessed:   timeOrNil(e.LastAccess),
+		},
+		NTFS: ecsNTFS{
+			Offset:                   e.Offset,
+			RecordNumber:             e.RecordNumber,
+			SequenceNumber:           e.SequenceNumber,
+			InUse:                    e.InUse,
+			ParentRecordNumber:       e.ParentRecordNumber,
+			ParentSequenceNumber:     e.ParentSequenceNumber,
+			AllocatedSize:            e.AllocatedSize,
+			FileAttributes:           formatFileAttributes(e.FileAttributes),
+			HardLinkCount:            e.HardLinkCount,
+			HasAlternateDataStreams:  e.HasAlternateDataStreams,
+			SecurityId:               e.SecurityId,
+			UpdateSequenceNumber:     e.UpdateSequenceNumber,
+			LogFileSequenceNumber:    e.LogFileSequenceNumber,
+			FileNameCreation:         timeOrNil(e.FileNameCreation),
+			FileNameFileLastModified: timeOrNil(e.FileNameFileLastModified),
+			FileNameMftLastModified:  timeOrNil(e.FileNameMftLastModified),
+			FileNameLastAccess:       timeOrNil(e.FileNameLastAccess),
+			Entropy:                  entropyOrNil(e),
+		},
+	})
+}
+
+// Flush writes any buffered data to the underlying io.Writer.
+func (w *ECSWriter) Flush() error {
+	return w.w.Flush()
+}
+
+func ecsAction(source string) string {
+	switch source {
+	case SourceIndexEntry:
+		return "index-entry"
+	case SourceIndexSlack:
+		return "index-slack-entry"
+	}
+	return "mft-record"
+}
+
+func ecsFileType(e Entry) string {
+	if e.ReparseTarget != "" {
+		return "symlink"
+	}
+	if e.Directory {
+		return "dir"
+	}
+	return "file"
+}
+
+// ecsExtension returns the extension of the name without the leading dot, as ECS defines it.
+func ecsExtension(e Entry) string {
+	if ext := mftecmdExtension(e.Name, e.Directory); ext != "" {
+		return ext[1:]
+	}
+	return ""
+}
+
+func ecsDirectory(p string) string {
+	if p == "" || p == "/" {
+		return ""
+	}
+	return path.Dir(p)
+}
+
+func ecsFileAttributes(e Entry) []string {
+	attributes := make([]string, 0)
+	for _, a := range ecsAttributes {
+		if e.FileAttributes&a.a != 0 {
+			attributes = append(attributes, a.name)
+		}
+	}
+	if e.Directory {
+		attributes = append(attributes, "directory")
+	}
+	if len(attributes) == 0 {
+		return nil
+	}
+	return attributes
+}
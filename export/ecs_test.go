@@ -0,0 +1,45 @@
+package export_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/export"
+	"github.com/t9t/gomft/mft"
+)
+
+func TestECSWriter(t *testing.T) {
+	e := testEntry()
+	e.Path = "/Users/test/test, 1.txt"
+	e.InUse = true
+	e.FileAttributes = mft.FileAttributeArchive | mft.FileAttributeHidden
+	e.MftLastModified = time.Date(2020, time.February, 6, 8, 0, 0, 0, time.UTC)
+	e.HardLinkCount = 1
+
+	out := &bytes.Buffer{}
+	w := export.NewECSWriter(out)
+	require.Nil(t, w.Write(e))
+	require.Nil(t, w.Write(export.Entry{Source: export.SourceRecord, Name: "dir", Directory: true, ReparseTarget: `C:\target`}))
+	require.Nil(t, w.Flush())
+
+	expected := `{"@timestamp":"2020-02-06T08:00:00Z","ecs":{"version":"8.11.0"},"event":{"kind":"event","category":["file"],"type":["info"],"action":"index-slack-entry","module":"gomft","dataset":"gomft.mft"},` +
+		`"file":{"path":"/Users/test/test, 1.txt","name":"test, 1.txt","extension":"txt","directory":"/Users/test","type":"file","size":13,"inode":"437343","attributes":["archive","hidden"],"ctime":"2020-02-06T08:00:00Z"},` +
+		`"ntfs":{"offset":2112,"record_number":437343,"sequence_number":6,"in_use":true,"parent_record_number":429113,"parent_sequence_number":59,"allocated_size":16,"attributes":"Hidden|Archive","hard_link_count":1,"has_ads":false,"security_id":0,"usn":0,"lsn":0,` +
+		`"fn_created":"2020-02-05T14:59:38.1168862Z","fn_modified":"2020-02-05T14:59:38.1168862Z","fn_mft_modified":"2020-02-05T14:59:39.5954456Z","fn_accessed":"2020-02-05T14:59:38.1168862Z"}}
+{"ecs":{"version":"8.11.0"},"event":{"kind":"event","category":["file"],"type":["info"],"action":"mft-record","module":"gomft","dataset":"gomft.mft"},` +
+		`"file":{"name":"dir","type":"symlink","size":0,"inode":"0","target_path":"C:\\target","attributes":["directory"]},` +
+		`"ntfs":{"offset":0,"record_number":0,"sequence_number":0,"in_use":false,"parent_record_number":0,"parent_sequence_number":0,"allocated_size":0,"attributes":"","hard_link_count":0,"has_ads":false,"security_id":0,"usn":0,"lsn":0}}
+`
+	assert.Equal(t, expected, out.String())
+}
+
+func TestECSWriterTimestampFallback(t *testing.T) {
+	out := &bytes.Buffer{}
+	w := export.NewECSWriter(out)
+	require.Nil(t, w.Write(testEntry()))
+	require.Nil(t, w.Flush())
+	assert.Contains(t, out.String(), `{"@timestamp":"2020-02-05T14:59:39.5954456Z",`)
+}
@@ -0,0 +1,264 @@
+/*
+Package export flattens parsed MFT records and index entries into Entry values and writes them as CSV or JSON. The
+same formats are used by all command line utilities, so their output can be processed by the same tooling.
+
+# Basic usage
+
+Create an Entry from a parsed record using FromRecord() and write it using a CSVWriter or JSONWriter.
+
+	// Error handling left out for brevity
+	w := export.NewCSVWriter(os.Stdout)
+	record, err := mft.ParseRecord(b)
+	err = w.Write(export.FromRecord(record))
+	err = w.Flush()
+*/
+package export
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/t9t/gomft/magic"
+	"github.com/t9t/gomft/mft"
+)
+
+// Source values indicate where the data of an Entry was obtained from.
+const (
+	SourceRecord     = "record"      // an MFT record (FILE record)
+	SourceIndexEntry = "index"       // an allocated entry in an index block (INDX record)
+	SourceIndexSlack = "index-slack" // an entry recovered from the slack space of an index block
+)
+
+// Entry is a flattened representation of an MFT record or index entry, containing the fields most commonly used in
+// listings and timelines. The FileAttributes are taken from the $STANDARD_INFORMATION attribute. Times for which no
+// data is available (such as $STANDARD_INFORMATION times for an Entry created from an index entry) are the zero
+// time.Time.
+//
+// The Path and PendingDelete are never set by FromRecord or FromIndexEntry, since they require the parent directories;
+// they are set by callers which resolve paths, for example using a pipeline.PathResolver. PendingDelete indicates a
+// file which was deleted while still open: since Windows 10, such files are moved to $Extend\$Deleted (POSIX delete
+// semantics) and their record is still in use until the last handle is closed.
+type Entry struct {
+	Source                   string
+	Offset                   int64
+	RecordNumber             uint64
+	SequenceNumber           uint16
+	InUse                    bool
+	Directory                bool
+	ParentRecordNumber       uint64
+	ParentSequenceNumber     uint16
+	Name                     string
+	Size                     uint64
+	AllocatedSize            uint64
+	SizeOnDisk               uint64
+	HasSizeOnDisk            bool
+	FileAttributes           mft.FileAttribute
+	Creation                 time.Time
+	FileLastModified         time.Time
+	MftLastModified          time.Time
+	LastAccess               time.Time
+	FileNameCreation         time.Time
+	FileNameFileLastModified time.Time
+	FileNameMftLastModified  time.Time
+	FileNameLastAccess       time.Time
+	Path                     string
+	PendingDelete            bool
+	Namespace                mft.FileNameNamespace
+	HardLinkCount            int
+	LogFileSequenceNumber    uint64
+	UpdateSequenceNumber     uint64
+	SecurityId               uint32
+	HasAlternateDataStreams  bool
+	AlternateDataStreams     []Stream
+	ReparseTarget            string
+	ContentType              string
+	Entropy                  float64
+	HasEntropy               bool
+}
+
+// Stream describes an alternate data stream (a named $DATA attribute) of a file.
+type Stream struct {
+	Name        string
+	Size        uint64
+	ContentType string
+	Entropy     float64
+	HasEntropy  bool
+}
+
+// SetEntropy sets the Entropy of the stream with the name, the Entry itself for the unnamed stream, and marks it as
+// computed using HasEntropy. A name not among the AlternateDataStreams is ignored.
+func (e *Entry) SetEntropy(stream string, entropy float64) {
+	if stream == "" {
+		e.Entropy, e.HasEntropy = entropy, true
+		return
+	}
+	for i := range e.AlternateDataStreams {
+		if e.AlternateDataStreams[i].Name == stream {
+			e.AlternateDataStreams[i].Entropy, e.AlternateDataStreams[i].HasEntropy = entropy, true
+		}
+	}
+}
+
+// A Writer writes Entries in a certain output format. Flush must be called after the last Entry was written to ensure
+// all data is written to the underlying io.Writer.
+type Writer interface {
+	Write(e Entry) error
+	Flush() error
+}
+
+// FromRecord creates an Entry from the header and attributes of a parsed record. The name and parent are taken from
+// the record's $FILE_NAME attribute, preferring the Win32 name over the DOS (8.3) name. The size is taken from the
+// unnamed $DATA attribute, or from the $FILE_NAME attribute if the record has no such $DATA attribute. Attributes that
+// cannot be parsed are ignored, leaving the corresponding fields empty.
+//
+// The ContentType of the Entry and its AlternateDataStreams is the MIME type of resident data, as detected by
+// magic.Detect. It is left empty for non-resident data, which is not stored in the record; see scan.ContentTypes to
+// detect the type of non-resident data as well.
+//
+// The SizeOnDisk is the number of bytes of the clusters allocated to the unnamed $DATA attribute, like the "size on
+// disk" shown by Windows: for compressed and sparse data, this is the TotalAllocatedSize of the attribute, which is
+// smaller than the AllocatedSize; for resident data, stored in the record itself, it is 0. HasSizeOnDisk is set for
+// all Entries created by FromRecord, and distinguishes a SizeOnDisk of 0 from one that is not known, as for Entries
+// created from index entries.
+//
+// The Entropy (in bits per byte, see the entropy package) is never set by FromRecord, since computing it for
+// non-resident data requires reading the volume; it is set by scan.Entropy or during extraction by the archive
+// package. HasEntropy distinguishes an Entropy of 0 from one that was not computed.
+func FromRecord(r mft.Record) Entry {
+	e := Entry{
+		Source:                SourceRecord,
+		RecordNumber:          r.FileReference.RecordNumber,
+		SequenceNumber:        r.FileReference.SequenceNumber,
+		InUse:                 r.IsInUse(),
+		Directory:             r.IsDirectory(),
+		HasSizeOnDisk:         true,
+		HardLinkCount:         r.HardLinkCount,
+		LogFileSequenceNumber: r.LogFileSequenceNumber,
+	}
+
+	if a, ok := r.FindFirstAttribute(mft.AttributeTypeStandardInformation); ok {
+		if si, err := mft.ParseStandardInformation(a.Data); err == nil {
+			e.Creation = si.Creation
+			e.FileLastModified = si.FileLastModified
+			e.MftLastModified = si.MftLastModified
+			e.LastAccess = si.LastAccess
+			e.FileAttributes = si.FileAttributes
+			e.UpdateSequenceNumber = si.UpdateSequenceNumber
+			e.SecurityId = si.SecurityId
+		}
+	}
+
+	fileName, haveFileName := r.PreferredFileName()
+	if haveFileName {
+		setFileName(&e, fileName)
+	}
+
+	haveData := false
+	for _, a := range r.Attributes {
+		if a.Type != mft.AttributeTypeData {
+			continue
+		}
+		if a.Name != "" {
+			e.HasAlternateDataStreams = true
+			size := a.ActualSize
+			if a.Resident {
+				size = uint64(len(a.Data))
+			}
+			e.AlternateDataStreams = append(e.AlternateDataStreams, Stream{Name: a.Name, Size: size, ContentType: contentType(a)})
+			continue
+		}
+		if haveData {
+			continue
+		}
+		haveData = true
+		if a.Resident {
+			e.Size = uint64(len(a.Data))
+			e.AllocatedSize = uint64(len(a.Data))
+			e.ContentType = contentType(a)
+		} else {
+			e.Size = a.ActualSize
+			e.AllocatedSize = a.AllocatedSize
+			e.SizeOnDisk = sizeOnDisk(a)
+		}
+	}
+
+	if a, ok := r.FindFirstAttribute(mft.AttributeTypeReparsePoint); ok {
+		if rp, err := mft.ParseReparsePoint(a.Data); err == nil {
+			if target, err := mft.ParseLinkTarget(rp); err == nil {
+				e.ReparseTarget = target.Target()
+			}
+		}
+	}
+	return e
+}
+
+// FromIndexEntry creates an Entry from an index entry, as found in $INDEX_ROOT and $INDEX_ALLOCATION attributes. Only
+// the fields available in the entry's $FILE_NAME are set; the FileAttributes are taken from the $FILE_NAME flags.
+func FromIndexEntry(ie mft.IndexEntry) Entry {
+	e := Entry{
+		Source:         SourceIndexEntry,
+		RecordNumber:   ie.FileReference.RecordNumber,
+		SequenceNumber: ie.FileReference.SequenceNumber,
+		Directory:      ie.FileName.Flags.Is(mft.FileAttributeDirectory),
+	}
+	setFileName(&e, ie.FileName)
+	e.FileAttributes = ie.FileName.Flags
+	return e
+}
+
+// sizeOnDisk returns the number of bytes allocated to a non-resident attribute. Only compressed and sparse attributes
+// have clusters which are not allocated, in which case the header contains the number of bytes that are.
+func sizeOnDisk(a mft.Attribute) uint64 {
+	if a.Flags&(mft.AttributeFlagsCompressed|mft.AttributeFlagsSparse) != 0 {
+		return a.TotalAllocatedSize
+	}
+	return a.AllocatedSize
+}
+
+// contentType detects the MIME type of resident data, returning an empty string for non-resident or unknown data.
+func contentType(a mft.Attribute) string {
+	if !a.Resident {
+		return ""
+	}
+	t, _ := magic.Detect(a.Data)
+	return t.MIME
+}
+
+// FromIndexRoot creates Entries from the index entries in the $INDEX_ROOT attribute of the $I30 (file name) index of a
+// directory record. These entries keep their own copies of the $FILE_NAME times, which are only updated when the
+// entry is, so they often differ from those in the records of the files. The entries in $INDEX_ALLOCATION blocks are
+// not included, since they are stored outside the MFT; use the carve package to find those. An error is returned
+// when the $INDEX_ROOT cannot be parsed.
+func FromIndexRoot(r mft.Record) ([]Entry, error) {
+	entries := make([]Entry, 0)
+	for _, a := range r.FindAttributes(mft.AttributeTypeIndexRoot) {
+		if a.Name != "$I30" || !a.Resident {
+			continue
+		}
+		root, err := mft.ParseIndexRoot(a.Data)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse $INDEX_ROOT: %v", err)
+		}
+		for _, ie := range root.Entries {
+			if ie.Flags&0x02 != 0 {
+				// The last entry of a node, which has no file name
+				continue
+			}
+			entries = append(entries, FromIndexEntry(ie))
+		}
+	}
+	return entries, nil
+}
+
+func setFileName(e *Entry, fn mft.FileName) {
+	e.Namespace = fn.Namespace
+	e.ParentRecordNumber = fn.ParentFileReference.RecordNumber
+	e.ParentSequenceNumber = fn.ParentFileReference.SequenceNumber
+	e.Name = fn.Name
+	e.Size = fn.ActualSize
+	e.AllocatedSize = fn.AllocatedSize
+	e.FileNameCreation = fn.Creation
+	e.FileNameFileLastModified = fn.FileLastModified
+	e.FileNameMftLastModified = fn.MftLastModified
+	e.FileNameLastAccess = fn.LastAccess
+}
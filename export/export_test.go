@@ -0,0 +1,181 @@
+package export_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/export"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/mfttest"
+)
+
+func TestFromRecord(t *testing.T) {
+	record, err := mft.ParseRecord(mfttest.SampleMftRecord())
+	require.Nilf(t, err, "could not parse record: %v", err)
+
+	mftTime := time.Date(2011, time.June, 20, 15, 6, 9, 679374800, time.UTC)
+	expected := export.Entry{
+		Source:                   export.SourceRecord,
+		RecordNumber:             0,
+		SequenceNumber:           145,
+		InUse:                    true,
+		Directory:                false,
+		ParentRecordNumber:       5,
+		ParentSequenceNumber:     5,
+		Name:                     "$MFT",
+		Size:                     1920466944,
+		AllocatedSize:            1920466944,
+		SizeOnDisk:               1920466944,
+		HasSizeOnDisk:            true,
+		FileAttributes:           mft.FileAttributeHidden | mft.FileAttributeSystem,
+		Creation:                 mftTime,
+		FileLastModified:         mftTime,
+		MftLastModified:          mftTime,
+		LastAccess:               mftTime,
+		FileNameCreation:         mftTime,
+		FileNameFileLastModified: mftTime,
+		FileNameMftLastModified:  mftTime,
+		FileNameLastAccess:       mftTime,
+		Namespace:                mft.FileNameNamespaceWin32Dos,
+		HardLinkCount:            1,
+		LogFileSequenceNumber:    25695988020,
+		SecurityId:               256,
+	}
+	assert.Equal(t, expected, export.FromRecord(record))
+}
+
+func TestFromRecordAlternateDataStreams(t *testing.T) {
+	record := mft.Record{Attributes: []mft.Attribute{
+		{Type: mft.AttributeTypeData, Resident: true, Data: []byte("data")},
+		{Type: mft.AttributeTypeData, Name: "Zone.Identifier", Resident: true, Data: []byte("[ZoneTransfer]")},
+		{Type: mft.AttributeTypeData, Name: "big", Resident: false, ActualSize: 12345},
+	}}
+	e := export.FromRecord(record)
+	assert.Equal(t, uint64(4), e.Size)
+	assert.True(t, e.HasAlternateDataStreams)
+	assert.Equal(t, []export.Stream{{Name: "Zone.Identifier", Size: 14}, {Name: "big", Size: 12345}}, e.AlternateDataStreams)
+}
+
+func TestFromRecordSizeOnDisk(t *testing.T) {
+	compressed := mft.Record{Attributes: []mft.Attribute{
+		{Type: mft.AttributeTypeData, Flags: mft.AttributeFlagsCompressed, AllocatedSize: 65536, ActualSize: 60000, TotalAllocatedSize: 49152},
+	}}
+	e := export.FromRecord(compressed)
+	assert.Equal(t, uint64(65536), e.AllocatedSize)
+	assert.Equal(t, uint64(49152), e.SizeOnDisk)
+	assert.True(t, e.HasSizeOnDisk)
+
+	resident := mft.Record{Attributes: []mft.Attribute{{Type: mft.AttributeTypeData, Resident: true, Data: []byte("data")}}}
+	e = export.FromRecord(resident)
+	assert.Equal(t, uint64(4), e.AllocatedSize)
+	assert.Equal(t, uint64(0), e.SizeOnDisk)
+	assert.True(t, e.HasSizeOnDisk)
+
+	out := &bytes.Buffer{}
+	w := export.NewJSONWriter(out)
+	require.Nil(t, w.Write(export.FromRecord(compressed)))
+	require.Nil(t, w.Write(testEntry()))
+	require.Nil(t, w.Flush())
+	lines := strings.Split(out.String(), "\n")
+	assert.Contains(t, lines[0], `"schema_version":2,"size_on_disk":49152}`)
+	assert.NotContains(t, lines[1], "size_on_disk")
+}
+
+func TestFromRecordContentType(t *testing.T) {
+	record := mft.Record{Attributes: []mft.Attribute{
+		{Type: mft.AttributeTypeData, Resident: true, Data: []byte("%PDF-1.7")},
+		{Type: mft.AttributeTypeData, Name: "payload", Resident: true, Data: []byte("MZ\x90\x00")},
+		{Type: mft.AttributeTypeData, Name: "big", Resident: false, ActualSize: 12345},
+	}}
+	e := export.FromRecord(record)
+	assert.Equal(t, "application/pdf", e.ContentType)
+	assert.Equal(t, []export.Stream{
+		{Name: "payload", Size: 4, ContentType: "application/vnd.microsoft.portable-executable"},
+		{Name: "big", Size: 12345},
+	}, e.AlternateDataStreams)
+}
+
+func TestFromIndexRoot(t *testing.T) {
+	created := time.Date(2021, time.June, 1, 12, 0, 0, 0, time.UTC)
+	v := mfttest.NewVolume().WithTime(created).WithFile("/Users/a.txt", []byte("a")).WithDirectory("/Users/b").Build()
+	number := v.Records["/Users"]
+	r, err := mft.ParseRecord(v.Mft()[number*mfttest.VolumeRecordSize : (number+1)*mfttest.VolumeRecordSize])
+	require.Nilf(t, err, "unable to parse record: %v", err)
+
+	entries, err := export.FromIndexRoot(r)
+	require.Nilf(t, err, "unable to read index root: %v", err)
+	require.Equal(t, 2, len(entries))
+	assert.Equal(t, export.SourceIndexEntry, entries[0].Source)
+	assert.Equal(t, "a.txt", entries[0].Name)
+	assert.Equal(t, v.Records["/Users/a.txt"], entries[0].RecordNumber)
+	assert.Equal(t, number, entries[0].ParentRecordNumber)
+	assert.Equal(t, created, entries[0].FileNameCreation)
+	assert.True(t, entries[1].Directory)
+
+	entries, err = export.FromIndexRoot(mft.Record{Attributes: []mft.Attribute{
+		{Type: mft.AttributeTypeIndexRoot, Resident: true, Name: "$I30", Data: make([]byte, 8)}}})
+	assert.Nil(t, entries)
+	assert.EqualError(t, err, "unable to parse $INDEX_ROOT: expected at least 32 bytes but got 8")
+}
+
+func TestCSVWriter(t *testing.T) {
+	out := &bytes.Buffer{}
+	w := export.NewCSVWriter(out)
+	require.Nil(t, w.Write(testEntry()))
+	require.Nil(t, w.Flush())
+
+	expected := "source,offset,record_number,sequence_number,in_use,directory,parent_record_number,parent_sequence_number,name,size,allocated_size,attributes,si_created,si_modified,si_mft_modified,si_accessed,fn_created,fn_modified,fn_mft_modified,fn_accessed,pending_delete,content_type,entropy,schema_version,size_on_disk\n" +
+		"index-slack,2112,437343,6,false,false,429113,59,\"test, 1.txt\",13,16,Archive|RecallOnOpen,,,,,2020-02-05T14:59:38.1168862Z,2020-02-05T14:59:38.1168862Z,2020-02-05T14:59:39.5954456Z,2020-02-05T14:59:38.1168862Z,false,,,2,\n"
+	assert.Equal(t, expected, out.String())
+}
+
+func TestWritersEntropy(t *testing.T) {
+	e := export.Entry{Source: export.SourceRecord, Name: "a", Entropy: 7.98765, HasEntropy: true}
+	out := &bytes.Buffer{}
+	w := export.NewCSVWriter(out)
+	require.Nil(t, w.Write(e))
+	require.Nil(t, w.Flush())
+	assert.Contains(t, out.String(), ",false,,7.9877,2,\n")
+
+	out.Reset()
+	w2 := export.NewJSONWriter(out)
+	require.Nil(t, w2.Write(e))
+	require.Nil(t, w2.Flush())
+	assert.Contains(t, out.String(), `"entropy":7.98765,"schema_version":2}`)
+}
+
+func TestJSONWriter(t *testing.T) {
+	out := &bytes.Buffer{}
+	w := export.NewJSONWriter(out)
+	require.Nil(t, w.Write(testEntry()))
+	require.Nil(t, w.Write(export.Entry{Source: export.SourceRecord, Name: "b"}))
+	require.Nil(t, w.Flush())
+
+	expected := `{"source":"index-slack","offset":2112,"record_number":437343,"sequence_number":6,"in_use":false,"directory":false,"parent_record_number":429113,"parent_sequence_number":59,"name":"test, 1.txt","size":13,"allocated_size":16,"attributes":"Archive|RecallOnOpen","fn_created":"2020-02-05T14:59:38.1168862Z","fn_modified":"2020-02-05T14:59:38.1168862Z","fn_mft_modified":"2020-02-05T14:59:39.5954456Z","fn_accessed":"2020-02-05T14:59:38.1168862Z","schema_version":2}
+{"source":"record","offset":0,"record_number":0,"sequence_number":0,"in_use":false,"directory":false,"parent_record_number":0,"parent_sequence_number":0,"name":"b","size":0,"allocated_size":0,"attributes":"","schema_version":2}
+`
+	assert.Equal(t, expected, out.String())
+}
+
+func testEntry() export.Entry {
+	return export.Entry{
+		Source:                   export.SourceIndexSlack,
+		Offset:                   2112,
+		RecordNumber:             437343,
+		SequenceNumber:           6,
+		ParentRecordNumber:       429113,
+		ParentSequenceNumber:     59,
+		Name:                     "test, 1.txt",
+		Size:                     13,
+		AllocatedSize:            16,
+		FileAttributes:           mft.FileAttributeArchive | 0x40000,
+		FileNameCreation:         time.Date(2020, time.February, 5, 14, 59, 38, 116886200, time.UTC),
+		FileNameFileLastModified: time.Date(2020, time.February, 5, 14, 59, 38, 116886200, time.UTC),
+		FileNameMftLastModified:  time.Date(2020, time.February, 5, 14, 59, 39, 595445600, time.UTC),
+		FileNameLastAccess:       time.Date(2020, time.February, 5, 14, 59, 38, 116886200, time.UTC),
+	}
+}
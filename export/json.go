@@ -0,0 +1,107 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// JSONWriter writes Entries as JSON Lines: one JSON object per line. This allows consumers to process the output one
+// Entry at a time, without having to read all of it first. Times are formatted as RFC 3339 with nanosecond precision;
+// zero times are omitted. The keys are described by EntrySchema.
+type JSONWriter struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+type jsonEntry struct {
+	Source                   string     `json:"source"`
+	Offset                   int64      `json:"offset"`
+	RecordNumber             uint64     `json:"record_number"`
+	SequenceNumber           uint16     `json:"sequence_number"`
+	InUse                    bool       `json:"in_use"`
+	Directory                bool       `json:"directory"`
+	ParentRecordNumber       uint64     `json:"parent_record_number"`
+	ParentSequenceNumber     uint16     `json:"parent_sequence_number"`
+	Name                     string     `json:"name"`
+	Size                     uint64     `json:"size"`
+	AllocatedSize            uint64     `json:"allocated_size"`
+	FileAttributes           string     `json:"attributes"`
+	Creation                 *time.Time `json:"si_created,omitempty"`
+	FileLastModified         *time.Time `json:"si_modified,omitempty"`
+	MftLastModified          *time.Time `json:"si_mft_modified,omitempty"`
+	LastAccess               *time.Time `json:"si_accessed,omitempty"`
+	FileNameCreation         *time.Time `json:"fn_created,omitempty"`
+	FileNameFileLastModified *time.Time `json:"fn_modified,omitempty"`
+	FileNameMftLastModified  *time.Time `json:"fn_mft_modified,omitempty"`
+	FileNameLastAccess       *time.Time `json:"fn_accessed,omitempty"`
+	PendingDelete            bool       `json:"pending_delete,omitempty"`
+	ContentType              string     `json:"content_type,omitempty"`
+	Entropy                  *float64   `json:"entropy,omitempty"`
+	SchemaVersion            int        `json:"schema_version"`
+	SizeOnDisk               *uint64    `json:"size_on_disk,omitempty"`
+}
+
+// NewJSONWriter creates a JSONWriter which writes to w.
+func NewJSONWriter(w io.Writer) *JSONWriter {
+	bw := bufio.NewWriter(w)
+	return &JSONWriter{w: bw, enc: json.NewEncoder(bw)}
+}
+
+// Write writes the Entry as a single line containing a JSON object.
+func (w *JSONWriter) Write(e Entry) error {
+	return w.enc.Encode(jsonEntry{
+		Source:                   e.Source,
+		Offset:                   e.Offset,
+		RecordNumber:             e.RecordNumber,
+		SequenceNumber:           e.SequenceNumber,
+		InUse:                    e.InUse,
+		Directory:                e.Directory,
+		ParentRecordNumber:       e.ParentRecordNumber,
+		ParentSequenceNumber:     e.ParentSequenceNumber,
+		Name:                     e.Name,
+		Size:                     e.Size,
+		AllocatedSize:            e.AllocatedSize,
+		FileAttributes:           formatFileAttributes(e.FileAttributes),
+		Creation:                 timeOrNil(e.Creation),
+		FileLastModified:         timeOrNil(e.FileLastModified),
+		MftLastModified:          timeOrNil(e.MftLastModified),
+		LastAccess:               timeOrNil(e.LastAccess),
+		FileNameCreation:         timeOrNil(e.FileNameCreation),
+		FileNameFileLastModified: timeOrNil(e.FileNameFileLastModified),
+		FileNameMftLastModified:  timeOrNil(e.FileNameMftLastModified),
+		FileNameLastAccess:       timeOrNil(e.FileNameLastAccess),
+		PendingDelete:            e.PendingDelete,
+		ContentType:              e.ContentType,
+		Entropy:                  entropyOrNil(e),
+		SchemaVersion:            SchemaVersion,
+		SizeOnDisk:               sizeOnDiskOrNil(e),
+	})
+}
+
+// Flush writes any buffered data to the underlying io.Writer.
+func (w *JSONWriter) Flush() error {
+	return w.w.Flush()
+}
+
+func sizeOnDiskOrNil(e Entry) *uint64 {
+	if !e.HasSizeOnDisk {
+		return nil
+	}
+	return &e.SizeOnDisk
+}
+
+func entropyOrNil(e Entry) *float64 {
+	if !e.HasEntropy {
+		return nil
+	}
+	return &e.Entropy
+}
+
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,160 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/t9t/gomft/mft"
+)
+
+var mftecmdHeader = []string{
+	"EntryNumber", "SequenceNumber", "InUse", "ParentEntryNumber", "ParentSequenceNumber", "ParentPath", "FileName",
+	"Extension", "FileSize", "ReferenceCount", "ReparseTarget", "IsDirectory", "HasAds", "IsAds", "SI<FN", "uSecZeros",
+	"Copied", "SiFlags", "NameType", "Created0x10", "Created0x30", "LastModified0x10", "LastModified0x30",
+	"LastRecordChange0x10", "LastRecordChange0x30", "LastAccess0x10", "LastAccess0x30", "UpdateSequenceNumber",
+	"LogfileSequenceNumber", "SecurityId", "ObjectIdFileDroid", "LoggedUtilStream", "ZoneIdContents", "SourceFile",
+}
+
+// mftecmdTimeFormat is the format of times in MFTECmd output, with the full 100 nanosecond precision of NTFS times.
+const mftecmdTimeFormat = "2006-01-02 15:04:05.0000000"
+
+// MFTECmdWriter writes Entries as CSV in the column layout of the $MFT output of Eric Zimmerman's MFTECmd, so tooling
+// built for that output can process gomft output as well. Booleans are written as True and False and times in UTC
+// like "2011-06-20 15:06:09.6793748". The ParentPath is derived from the Path of the Entry (for example
+// ".\Windows\System32"), so it is empty unless paths are resolved.
+//
+// Only the columns which can be derived from an Entry are filled; ObjectIdFileDroid, LoggedUtilStream and
+// ZoneIdContents are always empty. MFTECmd writes an additional line for each alternate data stream (with IsAds set),
+// MFTECmdWriter writes one line per Entry only.
+type MFTECmdWriter struct {
+	w             *csv.Writer
+	sourceFile    string
+	headerWritten bool
+}
+
+// NewMFTECmdWriter creates an MFTECmdWriter which writes to w. The sourceFile is written in the SourceFile column of
+// each line; MFTECmd uses the path of the input file.
+func NewMFTECmdWriter(w io.Writer, sourceFile string) *MFTECmdWriter {
+	return &MFTECmdWriter{w: csv.NewWriter(w), sourceFile: sourceFile}
+}
+
+// Write writes the Entry as a single CSV line, writing the header line first if that has not been done yet.
+func (w *MFTECmdWriter) Write(e Entry) error {
+	if !w.headerWritten {
+		if err := w.w.Write(mftecmdHeader); err != nil {
+			return err
+		}
+		w.headerWritten = true
+	}
+	return w.w.Write([]string{
+		strconv.FormatUint(e.RecordNumber, 10),
+		strconv.FormatUint(uint64(e.SequenceNumber), 10),
+		formatMFTECmdBool(e.InUse),
+		strconv.FormatUint(e.ParentRecordNumber, 10),
+		strconv.FormatUint(uint64(e.ParentSequenceNumber), 10),
+		mftecmdParentPath(e.Path),
+		e.Name,
+		mftecmdExtension(e.Name, e.Directory),
+		strconv.FormatUint(e.Size, 10),
+		strconv.Itoa(e.HardLinkCount),
+		e.ReparseTarget,
+		formatMFTECmdBool(e.Directory),
+		formatMFTECmdBool(e.HasAlternateDataStreams),
+		formatMFTECmdBool(false),
+		formatMFTECmdBool(timeBefore(e.Creation, e.FileNameCreation) || timeBefore(e.FileLastModified, e.FileNameFileLastModified)),
+		formatMFTECmdBool(hasZeroFraction(e.Creation) || hasZeroFraction(e.FileLastModified) || hasZeroFraction(e.MftLastModified)),
+		formatMFTECmdBool(timeBefore(e.FileLastModified, e.Creation)),
+		formatFileAttributes(e.FileAttributes),
+		mftecmdNameType(e),
+		formatMFTECmdTime(e.Creation),
+		formatMFTECmdTime(e.FileNameCreation),
+		formatMFTECmdTime(e.FileLastModified),
+		formatMFTECmdTime(e.FileNameFileLastModified),
+		formatMFTECmdTime(e.MftLastModified),
+		formatMFTECmdTime(e.FileNameMftLastModified),
+		formatMFTECmdTime(e.LastAccess),
+		formatMFTECmdTime(e.FileNameLastAccess),
+		strconv.FormatUint(e.UpdateSequenceNumber, 10),
+		strconv.FormatUint(e.LogFileSequenceNumber, 10),
+		strconv.FormatUint(uint64(e.SecurityId), 10),
+		"",
+		"",
+		"",
+		w.sourceFile,
+	})
+}
+
+// Flush writes any buffered data to the underlying io.Writer.
+func (w *MFTECmdWriter) Flush() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+func formatMFTECmdBool(b bool) string {
+	if b {
+		return "True"
+	}
+	return "False"
+}
+
+func formatMFTECmdTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(mftecmdTimeFormat)
+}
+
+// timeBefore checks if both times are known and a is before b.
+func timeBefore(a, b time.Time) bool {
+	return !a.IsZero() && !b.IsZero() && a.Before(b)
+}
+
+// hasZeroFraction checks if a known time has no sub-second part, which is unusual for times set by Windows and may
+// indicate the time was set by a tool.
+func hasZeroFraction(t time.Time) bool {
+	return !t.IsZero() && t.Nanosecond() == 0
+}
+
+// mftecmdParentPath converts the directory of a path as resolved by mftindex or pipeline (such as
+// "/Windows/notepad.exe") to the notation of MFTECmd (".\Windows").
+func mftecmdParentPath(p string) string {
+	if p == "" || p == "/" {
+		return ""
+	}
+	dir := path.Dir(p)
+	if dir == "/" {
+		return "."
+	}
+	return "." + strings.Replace(dir, "/", `\`, -1)
+}
+
+func mftecmdExtension(name string, directory bool) string {
+	if directory {
+		return ""
+	}
+	if i := strings.LastIndexByte(name, '.'); i > 0 {
+		return name[i:]
+	}
+	return ""
+}
+
+func mftecmdNameType(e Entry) string {
+	if e.Name == "" {
+		return ""
+	}
+	switch e.Namespace {
+	case mft.FileNameNamespacePosix:
+		return "Posix"
+	case mft.FileNameNamespaceWin32:
+		return "Windows"
+	case mft.FileNameNamespaceDos:
+		return "Dos"
+	case mft.FileNameNamespaceWin32Dos:
+		return "DosWindows"
+	}
+	return e.Namespace.String()
+}
@@ -0,0 +1,81 @@
+package export_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/export"
+	"github.com/t9t/gomft/mft"
+)
+
+func TestMFTECmdWriter(t *testing.T) {
+	created := time.Date(2020, time.February, 5, 14, 59, 38, 116886200, time.UTC)
+	modified := time.Date(2020, time.February, 5, 14, 59, 39, 0, time.UTC)
+	e := export.Entry{
+		RecordNumber:             437343,
+		SequenceNumber:           6,
+		InUse:                    true,
+		ParentRecordNumber:       429113,
+		ParentSequenceNumber:     59,
+		Path:                     "/Users/test/test, 1.txt",
+		Name:                     "test, 1.txt",
+		Namespace:                mft.FileNameNamespaceWin32,
+		Size:                     13,
+		FileAttributes:           mft.FileAttributeArchive,
+		HardLinkCount:            1,
+		HasAlternateDataStreams:  true,
+		Creation:                 created,
+		FileLastModified:         modified,
+		MftLastModified:          modified,
+		LastAccess:               modified,
+		FileNameCreation:         created,
+		FileNameFileLastModified: created,
+		FileNameMftLastModified:  created,
+		FileNameLastAccess:       created,
+		UpdateSequenceNumber:     1234,
+		LogFileSequenceNumber:    5678,
+		SecurityId:               256,
+	}
+
+	out := &bytes.Buffer{}
+	w := export.NewMFTECmdWriter(out, "C:\\$MFT")
+	require.Nil(t, w.Write(e))
+	require.Nil(t, w.Write(export.Entry{RecordNumber: 5, Directory: true, Name: ".", Namespace: mft.FileNameNamespaceWin32Dos, Path: "/"}))
+	require.Nil(t, w.Flush())
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	require.Len(t, lines, 3)
+	assert.Equal(t, "EntryNumber,SequenceNumber,InUse,ParentEntryNumber,ParentSequenceNumber,ParentPath,FileName,Extension,FileSize,ReferenceCount,ReparseTarget,IsDirectory,HasAds,IsAds,SI<FN,uSecZeros,Copied,SiFlags,NameType,Created0x10,Created0x30,LastModified0x10,LastModified0x30,LastRecordChange0x10,LastRecordChange0x30,LastAccess0x10,LastAccess0x30,UpdateSequenceNumber,LogfileSequenceNumber,SecurityId,ObjectIdFileDroid,LoggedUtilStream,ZoneIdContents,SourceFile", lines[0])
+	assert.Equal(t, `437343,6,True,429113,59,.\Users\test,"test, 1.txt",.txt,13,1,,False,True,False,False,True,False,Archive,Windows,`+
+		"2020-02-05 14:59:38.1168862,2020-02-05 14:59:38.1168862,2020-02-05 14:59:39.0000000,2020-02-05 14:59:38.1168862,"+
+		"2020-02-05 14:59:39.0000000,2020-02-05 14:59:38.1168862,2020-02-05 14:59:39.0000000,2020-02-05 14:59:38.1168862,"+
+		`1234,5678,256,,,,C:\$MFT`, lines[1])
+	assert.Equal(t, "5,0,False,0,0,,.,,0,0,,True,False,False,False,False,False,,DosWindows,,,,,,,,,0,0,0,,,,C:\\$MFT", lines[2])
+}
+
+func TestMFTECmdWriterFlags(t *testing.T) {
+	earlier := time.Date(2019, time.January, 1, 0, 0, 0, 100, time.UTC)
+	later := time.Date(2020, time.January, 1, 0, 0, 0, 100, time.UTC)
+	e := export.Entry{
+		Name:             "copied",
+		Creation:         later,
+		FileLastModified: earlier,
+		FileNameCreation: later.Add(time.Hour),
+	}
+
+	out := &bytes.Buffer{}
+	w := export.NewMFTECmdWriter(out, "")
+	require.Nil(t, w.Write(e))
+	require.Nil(t, w.Flush())
+
+	fields := strings.Split(strings.Split(out.String(), "\n")[1], ",")
+	assert.Equal(t, "", fields[7], "Extension")
+	assert.Equal(t, "True", fields[14], "SI<FN")
+	assert.Equal(t, "False", fields[15], "uSecZeros")
+	assert.Equal(t, "True", fields[16], "Copied")
+	assert.Equal(t, "Posix", fields[18], "NameType")
+}
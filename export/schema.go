@@ -0,0 +1,95 @@
+package export
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// SchemaVersion is the version of the CSV and JSON formats written by CSVWriter and JSONWriter. It is written along
+// with each Entry, as the schema_version column or key, so consumers can detect a format they do not know. The version
+// is incremented whenever fields are added, removed or change meaning; new fields are always added at the end of the
+// CSV columns, so consumers reading columns by position keep working.
+const SchemaVersion = 2
+
+// Types of the fields of a Schema.
+const (
+	FieldTypeString  = "string"
+	FieldTypeInteger = "integer"
+	FieldTypeNumber  = "number"
+	FieldTypeBoolean = "boolean"
+	FieldTypeTime    = "time" // RFC 3339 with nanosecond precision
+)
+
+// SchemaField describes a single column of the CSV format, which is a key with the same Name in the JSON format.
+type SchemaField struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Description string `json:"description"`
+	// Since is the SchemaVersion in which the field was added.
+	Since int `json:"since"`
+	// Optional fields are empty in CSV and omitted from JSON when there is no value.
+	Optional bool `json:"optional,omitempty"`
+}
+
+// Schema describes the fields of the CSV and JSON formats, in the order of the CSV columns.
+type Schema struct {
+	Version int           `json:"version"`
+	Fields  []SchemaField `json:"fields"`
+}
+
+var entrySchema = Schema{
+	Version: SchemaVersion,
+	Fields: []SchemaField{
+		{"source", FieldTypeString, "where the entry was found: record, index or index-slack", 1, false},
+		{"offset", FieldTypeInteger, "position in bytes of the record or index entry in the input", 1, false},
+		{"record_number", FieldTypeInteger, "MFT record number", 1, false},
+		{"sequence_number", FieldTypeInteger, "sequence number of the record", 1, false},
+		{"in_use", FieldTypeBoolean, "whether the record is in use (not deleted)", 1, false},
+		{"directory", FieldTypeBoolean, "whether the record is a directory", 1, false},
+		{"parent_record_number", FieldTypeInteger, "record number of the parent directory", 1, false},
+		{"parent_sequence_number", FieldTypeInteger, "sequence number of the parent directory", 1, false},
+		{"name", FieldTypeString, "file name, preferring the Win32 name over the DOS name", 1, false},
+		{"size", FieldTypeInteger, "size of the unnamed $DATA stream in bytes", 1, false},
+		{"allocated_size", FieldTypeInteger, "allocated size of the unnamed $DATA stream in bytes", 1, false},
+		{"attributes", FieldTypeString, "file attributes separated by |, such as Archive|Hidden", 1, false},
+		{"si_created", FieldTypeTime, "$STANDARD_INFORMATION creation time", 1, true},
+		{"si_modified", FieldTypeTime, "$STANDARD_INFORMATION file last modified time", 1, true},
+		{"si_mft_modified", FieldTypeTime, "$STANDARD_INFORMATION MFT record last modified time", 1, true},
+		{"si_accessed", FieldTypeTime, "$STANDARD_INFORMATION last access time", 1, true},
+		{"fn_created", FieldTypeTime, "$FILE_NAME creation time", 1, true},
+		{"fn_modified", FieldTypeTime, "$FILE_NAME file last modified time", 1, true},
+		{"fn_mft_modified", FieldTypeTime, "$FILE_NAME MFT record last modified time", 1, true},
+		{"fn_accessed", FieldTypeTime, "$FILE_NAME last access time", 1, true},
+		{"pending_delete", FieldTypeBoolean, "whether the file was deleted while still open (in $Extend\\$Deleted)", 1, true},
+		{"content_type", FieldTypeString, "MIME type of the data", 1, true},
+		{"entropy", FieldTypeNumber, "Shannon entropy of the data in bits per byte", 1, true},
+		{"schema_version", FieldTypeInteger, "version of this schema", 1, false},
+		{"size_on_disk", FieldTypeInteger, "bytes of the clusters allocated to the unnamed $DATA stream, which is less than the allocated size for compressed and sparse data and 0 for resident data", 2, true},
+	},
+}
+
+// EntrySchema returns the Schema of the CSV and JSON formats of Entries.
+func EntrySchema() Schema {
+	s := entrySchema
+	s.Fields = append([]SchemaField(nil), entrySchema.Fields...)
+	return s
+}
+
+// Names returns the names of the fields, which are the CSV header.
+func (s Schema) Names() []string {
+	names := make([]string, len(s.Fields))
+	for i, f := range s.Fields {
+		names[i] = f.Name
+	}
+	return names
+}
+
+// WriteJSON writes the Schema as an indented JSON document.
+func (s Schema) WriteJSON(w io.Writer) error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
@@ -0,0 +1,65 @@
+package export_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/export"
+)
+
+func TestEntrySchema(t *testing.T) {
+	schema := export.EntrySchema()
+	assert.Equal(t, export.SchemaVersion, schema.Version)
+	for _, f := range schema.Fields {
+		assert.NotEmptyf(t, f.Description, "description of %s", f.Name)
+		assert.Truef(t, f.Since >= 1 && f.Since <= export.SchemaVersion, "since of %s", f.Name)
+	}
+
+	// The CSV header and the JSON keys of an Entry with all fields set are the fields of the schema
+	e := testEntry()
+	e.Creation = time.Date(2020, time.February, 5, 14, 59, 38, 0, time.UTC)
+	e.FileLastModified, e.MftLastModified, e.LastAccess = e.Creation, e.Creation, e.Creation
+	e.PendingDelete, e.ContentType, e.Entropy, e.HasEntropy = true, "text/plain", 4.5, true
+	e.SizeOnDisk, e.HasSizeOnDisk = 4096, true
+
+	out := &bytes.Buffer{}
+	w := export.NewCSVWriter(out)
+	require.Nil(t, w.Write(e))
+	require.Nil(t, w.Flush())
+	rows, err := csv.NewReader(out).ReadAll()
+	require.Nil(t, err)
+	assert.Equal(t, schema.Names(), rows[0])
+
+	out.Reset()
+	jw := export.NewJSONWriter(out)
+	require.Nil(t, jw.Write(e))
+	require.Nil(t, jw.Flush())
+	var keys []string
+	dec := json.NewDecoder(out)
+	_, err = dec.Token()
+	require.Nil(t, err)
+	for dec.More() {
+		key, err := dec.Token()
+		require.Nil(t, err)
+		keys = append(keys, key.(string))
+		var value interface{}
+		require.Nil(t, dec.Decode(&value))
+	}
+	assert.Equal(t, schema.Names(), keys)
+}
+
+func TestSchema_WriteJSON(t *testing.T) {
+	out := &bytes.Buffer{}
+	require.Nil(t, export.EntrySchema().WriteJSON(out))
+	assert.True(t, strings.HasPrefix(out.String(), "{\n  \"version\": 2,\n  \"fields\": [\n    {\n      \"name\": \"source\",\n"))
+
+	var schema export.Schema
+	require.Nil(t, json.Unmarshal(out.Bytes(), &schema))
+	assert.Equal(t, export.EntrySchema(), schema)
+}
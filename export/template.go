@@ -0,0 +1,105 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"text/template"
+	"time"
+
+	"github.com/t9t/gomft/mft"
+)
+
+// TemplateWriter writes Entries using a text/template, executing the template once for each Entry and writing a
+// newline after each execution. Besides the standard template functions, the functions returned by TemplateFuncs()
+// are available.
+//
+// For example, the template `{{.RecordNumber}} {{.Name | quote}} {{time "2006-01-02" .Creation}} {{size .Size}}`
+// could yield a line such as: 42 "notes.txt" 2020-02-05 1.21KiB
+type TemplateWriter struct {
+	w    *bufio.Writer
+	tmpl *template.Template
+}
+
+// NewTemplateWriter parses the text as template and creates a TemplateWriter which writes to w. An error is returned
+// if the template cannot be parsed.
+func NewTemplateWriter(w io.Writer, text string) (*TemplateWriter, error) {
+	tmpl, err := template.New("entry").Funcs(TemplateFuncs()).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse template: %v", err)
+	}
+	return &TemplateWriter{w: bufio.NewWriter(w), tmpl: tmpl}, nil
+}
+
+// Write executes the template for the Entry and writes the result, followed by a newline.
+func (w *TemplateWriter) Write(e Entry) error {
+	if err := w.tmpl.Execute(w.w, e); err != nil {
+		return err
+	}
+	return w.w.WriteByte('\n')
+}
+
+// Flush writes any buffered data to the underlying io.Writer.
+func (w *TemplateWriter) Flush() error {
+	return w.w.Flush()
+}
+
+// TemplateFuncs returns the helper functions available in templates of a TemplateWriter:
+//
+//	time LAYOUT TIME     formats the time.Time using the layout (see time.Format); zero times yield ""
+//	rfc3339 TIME         formats the time.Time as RFC 3339 with nanoseconds; zero times yield ""
+//	unix TIME            returns the time.Time as seconds since the Unix epoch; zero times yield ""
+//	size BYTES           formats a number of bytes in human readable form, eg. 1.21KiB
+//	flags ATTRIBUTES     formats mft.FileAttribute bits as names separated by "|", eg. Hidden|System
+//	quote STRING         quotes the string using Go syntax, eg. "a\"b"
+//	json VALUE           returns the JSON representation of the value
+func TemplateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"time": func(layout string, t time.Time) string {
+			if t.IsZero() {
+				return ""
+			}
+			return t.Format(layout)
+		},
+		"rfc3339": formatTime,
+		"unix": func(t time.Time) string {
+			if t.IsZero() {
+				return ""
+			}
+			return strconv.FormatInt(t.Unix(), 10)
+		},
+		"size":  formatSize,
+		"flags": formatFileAttributes,
+		"quote": strconv.Quote,
+		"json": func(v interface{}) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}
+}
+
+func formatSize(b uint64) string {
+	if b < 1024 {
+		return fmt.Sprintf("%dB", b)
+	}
+	if b < 1048576 {
+		return fmt.Sprintf("%.2fKiB", float64(b)/float64(1024))
+	}
+	if b < 1073741824 {
+		return fmt.Sprintf("%.2fMiB", float64(b)/float64(1048576))
+	}
+	if b < 1099511627776 {
+		return fmt.Sprintf("%.2fGiB", float64(b)/float64(1073741824))
+	}
+	return fmt.Sprintf("%.2fTiB", float64(b)/float64(1099511627776))
+}
+
+// formatFileAttributes returns the names of the file attributes, or an empty string if none are set.
+func formatFileAttributes(a mft.FileAttribute) string {
+	if a == 0 {
+		return ""
+	}
+	return a.String()
+}
@@ -0,0 +1,34 @@
+package export_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/export"
+)
+
+func TestTemplateWriter(t *testing.T) {
+	out := &bytes.Buffer{}
+	w, err := export.NewTemplateWriter(out, `{{.RecordNumber}} {{quote .Name}} {{time "2006-01-02" .FileNameCreation}} {{unix .FileNameMftLastModified}} [{{rfc3339 .Creation}}] {{size .AllocatedSize}} {{flags .FileAttributes}} {{json .Name}}`)
+	require.Nilf(t, err, "unable to create template writer: %v", err)
+
+	e := testEntry()
+	require.Nil(t, w.Write(e))
+	e.Name = "big"
+	e.AllocatedSize = 5 * 1024 * 1024 * 1024
+	e.FileAttributes = 0
+	require.Nil(t, w.Write(e))
+	require.Nil(t, w.Flush())
+
+	expected := `437343 "test, 1.txt" 2020-02-05 1580914779 [] 16B Archive|RecallOnOpen "test, 1.txt"
+437343 "big" 2020-02-05 1580914779 [] 5.00GiB  "big"
+`
+	assert.Equal(t, expected, out.String())
+}
+
+func TestTemplateWriter_InvalidTemplate(t *testing.T) {
+	_, err := export.NewTemplateWriter(&bytes.Buffer{}, "{{.Name")
+	require.NotNil(t, err)
+}
@@ -0,0 +1,179 @@
+/*
+	Package extentmap lists where the data of each file is stored on a volume: the extents (ranges of bytes) of every
+	non-resident attribute, with markers for sparse and compressed parts. Exported as JSON, this is the input for disk
+	map visualizations and defragmentation analysis.
+
+	Basic usage
+
+	Add the Stage to a pipeline, after the Paths stage to include the path of each file, and write the Entries using a
+	JSONWriter.
+			// Error handling left out for brevity
+			w := extentmap.NewJSONWriter(os.Stdout)
+			stats, err := pipeline.Run(in, pipeline.Options{},
+				pipeline.Paths(pipeline.NewPathResolver(in, 1024, 0)),
+				extentmap.Stage(4096, w.Write))
+			err = w.Flush()
+
+	Implementation notes
+
+	Each data run of an attribute becomes an Extent, with its offset on the volume and its logical offset within the
+	attribute. Sparse runs are included as sparse Extents, which occupy no space on the volume. The data runs of a
+	large attribute may be spread over extension records; each part is listed in the Entry of the record containing
+	it, which has the BaseRecordNumber set, and its logical offsets are relative to the start of that part.
+
+	Compressed attributes are stored in compression units (of fragment.DefaultCompressionUnitClusters clusters). Their
+	Extents are split at the boundaries of the compression units, and the Extents of units which are stored compressed
+	(followed by a sparse part within the same unit) are marked as Compressed. Units stored as-is are not marked.
+*/
+package extentmap
+
+import (
+	"fmt"
+
+	"github.com/t9t/gomft/fragment"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/pipeline"
+)
+
+// Entry describes the extents of the non-resident attributes of a single record.
+type Entry struct {
+	RecordNumber     uint64
+	SequenceNumber   uint16
+	BaseRecordNumber uint64 // the record number of the base record when this is an extension record, otherwise 0
+	InUse            bool
+	Directory        bool
+	Path             string // the Path of the pipeline.Item, empty unless set by a previous stage
+	Streams          []Stream
+	Error            string // the reason the data runs of a stream could not be parsed; that stream is left out
+}
+
+// Stream describes a non-resident attribute and its extents.
+type Stream struct {
+	Type          mft.AttributeType
+	Name          string
+	Size          uint64
+	AllocatedSize uint64
+	Compressed    bool
+	Sparse        bool
+	Extents       []Extent
+}
+
+// Extent is a range of bytes of an attribute. The Offset is the position on the volume; it is 0 for Sparse extents,
+// which are not stored on the volume. The LogicalOffset is the position within the attribute.
+type Extent struct {
+	Offset        int64
+	LogicalOffset int64
+	Length        int64
+	Sparse        bool
+	Compressed    bool
+}
+
+// Stage creates a pipeline.Stage that calls report with the Entry of each Item that has non-resident attributes (or
+// data runs which could not be parsed). It never drops Items; an error returned by report stops the pipeline.
+func Stage(bytesPerCluster int, report func(Entry) error) pipeline.Stage {
+	return pipeline.StageFunc(func(item *pipeline.Item) (bool, error) {
+		e := NewEntry(item, bytesPerCluster)
+		if len(e.Streams) == 0 && e.Error == "" {
+			return true, nil
+		}
+		if err := report(e); err != nil {
+			return false, fmt.Errorf("unable to report extents: %v", err)
+		}
+		return true, nil
+	})
+}
+
+// NewEntry creates the Entry of the Item for a volume with the cluster size in bytes.
+func NewEntry(item *pipeline.Item, bytesPerCluster int) Entry {
+	r := item.Record
+	e := Entry{
+		RecordNumber:   item.Entry.RecordNumber,
+		SequenceNumber: item.Entry.SequenceNumber,
+		InUse:          item.Entry.InUse,
+		Directory:      item.Entry.Directory,
+		Path:           item.Path,
+		Streams:        make([]Stream, 0),
+	}
+	if r.IsExtension() {
+		e.BaseRecordNumber = r.BaseRecordReference.RecordNumber
+	}
+	for _, a := range r.Attributes {
+		if a.Resident {
+			continue
+		}
+		runs, err := mft.ParseDataRuns(a.Data)
+		if err != nil {
+			if e.Error == "" {
+				e.Error = fmt.Sprintf("unable to parse data runs of %s attribute %q: %v", a.Type.Name(), a.Name, err)
+			}
+			continue
+		}
+		s := Stream{
+			Type:          a.Type,
+			Name:          a.Name,
+			Size:          a.ActualSize,
+			AllocatedSize: a.AllocatedSize,
+			Compressed:    a.Flags&mft.AttributeFlagsCompressed != 0,
+			Sparse:        a.Flags&mft.AttributeFlagsSparse != 0,
+		}
+		fragments := mft.DataRunsToFragments(runs, bytesPerCluster)
+		if s.Compressed {
+			s.Extents = compressedExtents(fragments, int64(fragment.DefaultCompressionUnitClusters*bytesPerCluster))
+		} else {
+			s.Extents = extents(fragments)
+		}
+		e.Streams = append(e.Streams, s)
+	}
+	return e
+}
+
+// extents returns an Extent for each fragment.
+func extents(fragments []fragment.Fragment) []Extent {
+	extents := make([]Extent, 0, len(fragments))
+	logical := int64(0)
+	for _, f := range fragments {
+		x := Extent{Offset: f.Offset, LogicalOffset: logical, Length: f.Length, Sparse: f.Sparse}
+		if f.Sparse {
+			x.Offset = 0
+		}
+		extents = append(extents, x)
+		logical += f.Length
+	}
+	return extents
+}
+
+// compressedExtents returns the Extents of the fragments split at the compression unit boundaries, marking those in
+// compressed units.
+func compressedExtents(fragments []fragment.Fragment, unitSize int64) []Extent {
+	split := make([]Extent, 0, len(fragments))
+	for _, x := range extents(fragments) {
+		for x.Length > 0 {
+			part := x
+			if end := (x.LogicalOffset/unitSize + 1) * unitSize; x.LogicalOffset+x.Length > end {
+				part.Length = end - x.LogicalOffset
+			}
+			split = append(split, part)
+			x.LogicalOffset += part.Length
+			x.Length -= part.Length
+			if !x.Sparse {
+				x.Offset += part.Length
+			}
+		}
+	}
+
+	// A unit with both stored and sparse parts is compressed
+	stored := make(map[int64]bool)
+	sparse := make(map[int64]bool)
+	for _, x := range split {
+		if x.Sparse {
+			sparse[x.LogicalOffset/unitSize] = true
+		} else {
+			stored[x.LogicalOffset/unitSize] = true
+		}
+	}
+	for i, x := range split {
+		unit := x.LogicalOffset / unitSize
+		split[i].Compressed = !x.Sparse && stored[unit] && sparse[unit]
+	}
+	return split
+}
@@ -0,0 +1,98 @@
+package extentmap_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/export"
+	"github.com/t9t/gomft/extentmap"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/mfttest"
+	"github.com/t9t/gomft/pipeline"
+)
+
+func item(b *mfttest.RecordBuilder) *pipeline.Item {
+	r := b.Record()
+	return &pipeline.Item{Record: r, Entry: export.FromRecord(r), Path: "/file"}
+}
+
+func TestNewEntry(t *testing.T) {
+	// 100-101, 3 sparse clusters, 110
+	i := item(mfttest.NewRecord().WithRecordNumber(40).WithFileName("file").
+		WithNonResidentData(5*4096, mfttest.Run(100, 2), mfttest.SparseRun(3), mfttest.Run(10, 1)).
+		WithResidentData([]byte("ignored")))
+	e := extentmap.NewEntry(i, 4096)
+	assert.Equal(t, extentmap.Entry{
+		RecordNumber:   40,
+		SequenceNumber: i.Entry.SequenceNumber,
+		InUse:          true,
+		Path:           "/file",
+		Streams: []extentmap.Stream{{
+			Type:          mft.AttributeTypeData,
+			Size:          5 * 4096,
+			AllocatedSize: 6 * 4096,
+			Extents: []extentmap.Extent{
+				{Offset: 100 * 4096, LogicalOffset: 0, Length: 2 * 4096},
+				{LogicalOffset: 2 * 4096, Length: 3 * 4096, Sparse: true},
+				{Offset: 110 * 4096, LogicalOffset: 5 * 4096, Length: 4096},
+			},
+		}},
+	}, e)
+}
+
+func TestNewEntry_Compressed(t *testing.T) {
+	// Units of 16 clusters of 512 bytes: unit 0 stored as-is, unit 1 compressed into 4 clusters, unit 2 compressed into
+	// 3 clusters
+	runs := []mft.DataRun{mfttest.Run(100, 20), mfttest.SparseRun(12), mfttest.Run(50, 3), mfttest.SparseRun(13)}
+	i := item(mfttest.NewRecord().WithAttribute(mft.Attribute{Type: mft.AttributeTypeData, Flags: mft.AttributeFlagsCompressed,
+		ActualSize: 40 * 512, AllocatedSize: 48 * 512, Data: mfttest.EncodeDataRuns(runs)}))
+	e := extentmap.NewEntry(i, 512)
+	require.Equal(t, 1, len(e.Streams))
+	assert.True(t, e.Streams[0].Compressed)
+	assert.Equal(t, []extentmap.Extent{
+		{Offset: 100 * 512, LogicalOffset: 0, Length: 16 * 512},
+		{Offset: 116 * 512, LogicalOffset: 16 * 512, Length: 4 * 512, Compressed: true},
+		{LogicalOffset: 20 * 512, Length: 12 * 512, Sparse: true},
+		{Offset: 150 * 512, LogicalOffset: 32 * 512, Length: 3 * 512, Compressed: true},
+		{LogicalOffset: 35 * 512, Length: 13 * 512, Sparse: true},
+	}, e.Streams[0].Extents)
+}
+
+func TestNewEntry_Extension(t *testing.T) {
+	base := mft.FileReference{RecordNumber: 43, SequenceNumber: 1}
+	e := extentmap.NewEntry(item(mfttest.NewRecord().WithBaseRecord(base).WithNonResidentData(0, mfttest.Run(300, 1))), 4096)
+	assert.Equal(t, uint64(43), e.BaseRecordNumber)
+	assert.Equal(t, []extentmap.Extent{{Offset: 300 * 4096, Length: 4096}}, e.Streams[0].Extents)
+}
+
+func TestNewEntry_InvalidDataRuns(t *testing.T) {
+	e := extentmap.NewEntry(item(mfttest.NewRecord().WithAttribute(mft.Attribute{Type: mft.AttributeTypeData, Name: "ads",
+		Data: []byte{0x88, 0x01}}).WithNonResidentData(4096, mfttest.Run(10, 1))), 4096)
+	assert.Equal(t, `unable to parse data runs of $DATA attribute "ads": expected at least 17 bytes of datarun data but is 8`, e.Error)
+	require.Equal(t, 1, len(e.Streams))
+	assert.Equal(t, "", e.Streams[0].Name)
+}
+
+func TestStage(t *testing.T) {
+	var reported []extentmap.Entry
+	stage := extentmap.Stage(4096, func(e extentmap.Entry) error {
+		reported = append(reported, e)
+		return nil
+	})
+	keep, err := stage.Process(item(mfttest.NewRecord().WithResidentData([]byte("resident"))))
+	require.Nil(t, err)
+	assert.True(t, keep)
+	assert.Equal(t, 0, len(reported))
+
+	keep, err = stage.Process(item(mfttest.NewRecord().WithNonResidentData(4096, mfttest.Run(10, 1))))
+	require.Nil(t, err)
+	assert.True(t, keep)
+	assert.Equal(t, 1, len(reported))
+
+	_, err = extentmap.Stage(4096, func(e extentmap.Entry) error {
+		return errors.New("disk full")
+	}).Process(item(mfttest.NewRecord().WithNonResidentData(4096, mfttest.Run(10, 1))))
+	assert.EqualError(t, err, "unable to report extents: disk full")
+}
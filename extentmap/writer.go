@@ -0,0 +1,84 @@
+package extentmap
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+)
+
+// JSONWriter writes Entries as JSON Lines: one JSON object per line. The attribute type is written by its name (such
+// as "$DATA"); empty strings and false markers are omitted.
+type JSONWriter struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+type jsonEntry struct {
+	RecordNumber     uint64       `json:"record_number"`
+	SequenceNumber   uint16       `json:"sequence_number"`
+	BaseRecordNumber uint64       `json:"base_record_number,omitempty"`
+	InUse            bool         `json:"in_use"`
+	Directory        bool         `json:"directory"`
+	Path             string       `json:"path,omitempty"`
+	Streams          []jsonStream `json:"streams"`
+	Error            string       `json:"error,omitempty"`
+}
+
+type jsonStream struct {
+	Type          string       `json:"type"`
+	Name          string       `json:"name,omitempty"`
+	Size          uint64       `json:"size"`
+	AllocatedSize uint64       `json:"allocated_size"`
+	Compressed    bool         `json:"compressed,omitempty"`
+	Sparse        bool         `json:"sparse,omitempty"`
+	Extents       []jsonExtent `json:"extents"`
+}
+
+type jsonExtent struct {
+	Offset        int64 `json:"offset"`
+	LogicalOffset int64 `json:"logical_offset"`
+	Length        int64 `json:"length"`
+	Sparse        bool  `json:"sparse,omitempty"`
+	Compressed    bool  `json:"compressed,omitempty"`
+}
+
+// NewJSONWriter creates a JSONWriter which writes to w.
+func NewJSONWriter(w io.Writer) *JSONWriter {
+	bw := bufio.NewWriter(w)
+	return &JSONWriter{w: bw, enc: json.NewEncoder(bw)}
+}
+
+// Write writes the Entry as a single line containing a JSON object.
+func (w *JSONWriter) Write(e Entry) error {
+	streams := make([]jsonStream, len(e.Streams))
+	for i, s := range e.Streams {
+		extents := make([]jsonExtent, len(s.Extents))
+		for j, x := range s.Extents {
+			extents[j] = jsonExtent(x)
+		}
+		streams[i] = jsonStream{
+			Type:          s.Type.Name(),
+			Name:          s.Name,
+			Size:          s.Size,
+			AllocatedSize: s.AllocatedSize,
+			Compressed:    s.Compressed,
+			Sparse:        s.Sparse,
+			Extents:       extents,
+		}
+	}
+	return w.enc.Encode(jsonEntry{
+		RecordNumber:     e.RecordNumber,
+		SequenceNumber:   e.SequenceNumber,
+		BaseRecordNumber: e.BaseRecordNumber,
+		InUse:            e.InUse,
+		Directory:        e.Directory,
+		Path:             e.Path,
+		Streams:          streams,
+		Error:            e.Error,
+	})
+}
+
+// Flush writes any buffered data to the underlying io.Writer.
+func (w *JSONWriter) Flush() error {
+	return w.w.Flush()
+}
@@ -0,0 +1,38 @@
+package extentmap_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/extentmap"
+	"github.com/t9t/gomft/mft"
+)
+
+func TestJSONWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := extentmap.NewJSONWriter(buf)
+	require.Nil(t, w.Write(extentmap.Entry{
+		RecordNumber:   42,
+		SequenceNumber: 3,
+		InUse:          true,
+		Path:           "/Users/bob/file.txt",
+		Streams: []extentmap.Stream{{
+			Type:          mft.AttributeTypeData,
+			Size:          10000,
+			AllocatedSize: 16384,
+			Compressed:    true,
+			Extents: []extentmap.Extent{
+				{Offset: 409600, Length: 4096, Compressed: true},
+				{LogicalOffset: 4096, Length: 12288, Sparse: true},
+			},
+		}},
+	}))
+	require.Nil(t, w.Write(extentmap.Entry{RecordNumber: 44, BaseRecordNumber: 43, Streams: []extentmap.Stream{},
+		Error: "unable to parse data runs"}))
+	require.Nil(t, w.Flush())
+	assert.Equal(t, `{"record_number":42,"sequence_number":3,"in_use":true,"directory":false,"path":"/Users/bob/file.txt","streams":[{"type":"$DATA","size":10000,"allocated_size":16384,"compressed":true,"extents":[{"offset":409600,"logical_offset":0,"length":4096,"compressed":true},{"offset":0,"logical_offset":4096,"length":12288,"sparse":true}]}]}
+{"record_number":44,"sequence_number":0,"base_record_number":43,"in_use":false,"directory":false,"streams":[],"error":"unable to parse data runs"}
+`, buf.String())
+}
@@ -0,0 +1,308 @@
+/*
+	Package filter implements a small expression language to select records, for example:
+			name like '*.exe' and si.modified > 2023-01-01 and not deleted
+
+	An expression is compiled into a Filter using Compile(), after which the Filter's Match method can be used as a
+	predicate over export.Entry values. The same expressions are accepted by the -where flag of the command line
+	utilities.
+
+	Syntax
+
+	An expression consists of comparisons and boolean fields, combined using "and", "or", "not" and parentheses. "not"
+	binds stronger than "and", which binds stronger than "or". Keywords and field names are case insensitive.
+
+	A comparison has the form: field operator value. The following operators are supported:
+			= (or ==), !=, <, <=, >, >=    compare numbers, times, strings and booleans
+			like                           match a string against a pattern where * matches any sequence of
+			                               characters and ? matches a single character
+			matches                        match a string against a regular expression (see package regexp)
+			has                            check if file attributes contain all the specified attributes
+
+	String comparisons (=, !=, like and matches) are case insensitive, just like file names in NTFS. Values are either
+	strings in single or double quotes (in which a backslash escapes the next character), numbers (optionally with a
+	K, M, G or T suffix for multiples of 1024), dates (2006-01-02), times in RFC 3339 format (2006-01-02T15:04:05Z),
+	true or false. Times without a time zone are interpreted as UTC. File attributes are specified by their names
+	separated by "|", eg. 'Hidden|System'.
+
+	Fields
+
+	The following fields are available (see export.Entry for their meaning):
+			source                                  string
+			name                                    string
+			path                                    string (full path with forward slashes, eg. '/Users/*'; empty
+			                                        when paths are not resolved)
+			contenttype                             string (MIME type of the data, eg. 'application/pdf')
+			record, sequence, parent, parent.sequence  number
+			offset, size, allocated                 number
+			inuse, deleted, directory, file         boolean (deleted and file are the opposites of inuse and directory)
+			pendingdelete                           boolean (in $Extend\$Deleted; also matched by deleted)
+			attributes                              file attributes
+			si.created, si.modified                 time ($STANDARD_INFORMATION)
+			si.changed, si.accessed                 time ($STANDARD_INFORMATION; changed means MFT modified)
+			fn.created, fn.modified                 time ($FILE_NAME)
+			fn.changed, fn.accessed                 time ($FILE_NAME; changed means MFT modified)
+
+	Times which are unavailable (such as $STANDARD_INFORMATION times of carved index entries) are the zero time, which
+	is before any other time.
+*/
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/t9t/gomft/export"
+	"github.com/t9t/gomft/mft"
+)
+
+// Filter is a compiled filter expression.
+type Filter struct {
+	expr string
+	root node
+	used map[string]bool
+}
+
+// Compile parses the expression into a Filter. An error is returned when the expression is invalid, for example when
+// it contains unknown fields or values which do not match the type of the field they are compared to.
+func Compile(expr string) (*Filter, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, used: make(map[string]bool)}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, fmt.Errorf("unexpected %s at position %d", p.peek().text, p.peek().pos)
+	}
+	return &Filter{expr: expr, root: root, used: p.used}, nil
+}
+
+// Match returns true when the Entry matches the filter expression. Its method value can be used as a predicate, eg.
+// f.Match is a func(export.Entry) bool.
+func (f *Filter) Match(e export.Entry) bool {
+	return f.root.eval(&e)
+}
+
+// String returns the expression the Filter was compiled from.
+func (f *Filter) String() string {
+	return f.expr
+}
+
+// Uses returns true if the expression refers to the field with the (case insensitive) name, for example to only
+// resolve paths when the "path" field is used.
+func (f *Filter) Uses(name string) bool {
+	return f.used[strings.ToLower(name)]
+}
+
+type kind int
+
+const (
+	kindString kind = iota
+	kindNumber
+	kindBool
+	kindTime
+	kindAttributes
+)
+
+func (k kind) String() string {
+	switch k {
+	case kindString:
+		return "string"
+	case kindNumber:
+		return "number"
+	case kindBool:
+		return "boolean"
+	case kindTime:
+		return "time"
+	case kindAttributes:
+		return "attributes"
+	}
+	return "unknown"
+}
+
+// field describes a field of an export.Entry that can be used in expressions. Depending on the kind, one of the
+// getters is set.
+type field struct {
+	name       string
+	kind       kind
+	str        func(e *export.Entry) string
+	number     func(e *export.Entry) uint64
+	boolean    func(e *export.Entry) bool
+	time       func(e *export.Entry) time.Time
+	attributes func(e *export.Entry) mft.FileAttribute
+}
+
+var fields = map[string]field{}
+
+func init() {
+	add := func(f field) {
+		fields[f.name] = f
+	}
+	add(field{name: "source", kind: kindString, str: func(e *export.Entry) string { return e.Source }})
+	add(field{name: "name", kind: kindString, str: func(e *export.Entry) string { return e.Name }})
+	add(field{name: "path", kind: kindString, str: func(e *export.Entry) string { return e.Path }})
+	add(field{name: "contenttype", kind: kindString, str: func(e *export.Entry) string { return e.ContentType }})
+	add(field{name: "record", kind: kindNumber, number: func(e *export.Entry) uint64 { return e.RecordNumber }})
+	add(field{name: "sequence", kind: kindNumber, number: func(e *export.Entry) uint64 { return uint64(e.SequenceNumber) }})
+	add(field{name: "parent", kind: kindNumber, number: func(e *export.Entry) uint64 { return e.ParentRecordNumber }})
+	add(field{name: "parent.sequence", kind: kindNumber, number: func(e *export.Entry) uint64 { return uint64(e.ParentSequenceNumber) }})
+	add(field{name: "offset", kind: kindNumber, number: func(e *export.Entry) uint64 { return uint64(e.Offset) }})
+	add(field{name: "size", kind: kindNumber, number: func(e *export.Entry) uint64 { return e.Size }})
+	add(field{name: "allocated", kind: kindNumber, number: func(e *export.Entry) uint64 { return e.AllocatedSize }})
+	add(field{name: "inuse", kind: kindBool, boolean: func(e *export.Entry) bool { return e.InUse }})
+	add(field{name: "deleted", kind: kindBool, boolean: func(e *export.Entry) bool { return !e.InUse || e.PendingDelete }})
+	add(field{name: "pendingdelete", kind: kindBool, boolean: func(e *export.Entry) bool { return e.PendingDelete }})
+	add(field{name: "directory", kind: kindBool, boolean: func(e *export.Entry) bool { return e.Directory }})
+	add(field{name: "file", kind: kindBool, boolean: func(e *export.Entry) bool { return !e.Directory }})
+	add(field{name: "attributes", kind: kindAttributes, attributes: func(e *export.Entry) mft.FileAttribute { return e.FileAttributes }})
+	add(field{name: "si.created", kind: kindTime, time: func(e *export.Entry) time.Time { return e.Creation }})
+	add(field{name: "si.modified", kind: kindTime, time: func(e *export.Entry) time.Time { return e.FileLastModified }})
+	add(field{name: "si.changed", kind: kindTime, time: func(e *export.Entry) time.Time { return e.MftLastModified }})
+	add(field{name: "si.accessed", kind: kindTime, time: func(e *export.Entry) time.Time { return e.LastAccess }})
+	add(field{name: "fn.created", kind: kindTime, time: func(e *export.Entry) time.Time { return e.FileNameCreation }})
+	add(field{name: "fn.modified", kind: kindTime, time: func(e *export.Entry) time.Time { return e.FileNameFileLastModified }})
+	add(field{name: "fn.changed", kind: kindTime, time: func(e *export.Entry) time.Time { return e.FileNameMftLastModified }})
+	add(field{name: "fn.accessed", kind: kindTime, time: func(e *export.Entry) time.Time { return e.FileNameLastAccess }})
+}
+
+type node interface {
+	eval(e *export.Entry) bool
+}
+
+type andNode struct {
+	left, right node
+}
+
+func (n andNode) eval(e *export.Entry) bool {
+	return n.left.eval(e) && n.right.eval(e)
+}
+
+type orNode struct {
+	left, right node
+}
+
+func (n orNode) eval(e *export.Entry) bool {
+	return n.left.eval(e) || n.right.eval(e)
+}
+
+type notNode struct {
+	n node
+}
+
+func (n notNode) eval(e *export.Entry) bool {
+	return !n.n.eval(e)
+}
+
+type boolFieldNode struct {
+	f field
+}
+
+func (n boolFieldNode) eval(e *export.Entry) bool {
+	return n.f.boolean(e)
+}
+
+type stringNode struct {
+	f     field
+	op    string
+	value string
+	re    *regexp.Regexp
+}
+
+func (n stringNode) eval(e *export.Entry) bool {
+	v := n.f.str(e)
+	switch n.op {
+	case "=":
+		return strings.EqualFold(v, n.value)
+	case "!=":
+		return !strings.EqualFold(v, n.value)
+	case "like", "matches":
+		return n.re.MatchString(v)
+	}
+	return compareOrdered(strings.Compare(strings.ToLower(v), strings.ToLower(n.value)), n.op)
+}
+
+type numberNode struct {
+	f     field
+	op    string
+	value uint64
+}
+
+func (n numberNode) eval(e *export.Entry) bool {
+	v := n.f.number(e)
+	c := 0
+	if v < n.value {
+		c = -1
+	} else if v > n.value {
+		c = 1
+	}
+	return compareOrdered(c, n.op)
+}
+
+type boolNode struct {
+	f     field
+	op    string
+	value bool
+}
+
+func (n boolNode) eval(e *export.Entry) bool {
+	return (n.f.boolean(e) == n.value) == (n.op == "=")
+}
+
+type timeNode struct {
+	f     field
+	op    string
+	value time.Time
+}
+
+func (n timeNode) eval(e *export.Entry) bool {
+	v := n.f.time(e)
+	c := 0
+	if v.Before(n.value) {
+		c = -1
+	} else if v.After(n.value) {
+		c = 1
+	}
+	return compareOrdered(c, n.op)
+}
+
+type attributesNode struct {
+	f     field
+	op    string
+	value mft.FileAttribute
+}
+
+func (n attributesNode) eval(e *export.Entry) bool {
+	v := n.f.attributes(e)
+	switch n.op {
+	case "has":
+		return v.Is(n.value)
+	case "=":
+		return v == n.value
+	}
+	return v != n.value
+}
+
+// compareOrdered returns the result of an ordered comparison operator, given the result c of comparing two values (-1
+// for less than, 0 for equal, 1 for greater than).
+func compareOrdered(c int, op string) bool {
+	switch op {
+	case "=":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package filter_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/export"
+	"github.com/t9t/gomft/filter"
+	"github.com/t9t/gomft/mft"
+)
+
+func TestFilter(t *testing.T) {
+	e := export.Entry{
+		Source:           export.SourceRecord,
+		RecordNumber:     42,
+		SequenceNumber:   3,
+		InUse:            false,
+		Name:             "Setup.EXE",
+		Path:             "/Users/bob/Setup.EXE",
+		ContentType:      "application/vnd.microsoft.portable-executable",
+		Size:             3 * 1024 * 1024,
+		FileAttributes:   mft.FileAttributeHidden | mft.FileAttributeSystem,
+		Creation:         time.Date(2022, time.December, 31, 23, 59, 59, 0, time.UTC),
+		FileLastModified: time.Date(2023, time.March, 14, 15, 9, 26, 0, time.UTC),
+	}
+
+	tests := []struct {
+		expr     string
+		expected bool
+	}{
+		{"name like '*.exe' and si.modified > 2023-01-01 and deleted", true},
+		{"name like '*.exe' and si.modified > 2023-03-15", false},
+		{"name = 'setup.exe'", true},
+		{`name == "SETUP.exe"`, true},
+		{"name != 'setup.exe'", false},
+		{"name not like '*.exe'", false},
+		{"name like 's?tup.*'", true},
+		{"name like 'setup'", false},
+		{"name matches '^set.p\\.'", true},
+		{"not deleted", false},
+		{"inuse or directory", false},
+		{"file and (record = 41 or record=42)", true},
+		{"record >= 42 and record < 43 and sequence != 4", true},
+		{"size > 2M and size <= 3M", true},
+		{"size > 3M", false},
+		{"size = 0x300000", true},
+		{"si.created < 2023-01-01T00:00:00Z", true},
+		{"si.created >= '2022-12-31 23:59:59'", true},
+		{"si.created > 2022-12-31T23:59:59", false},
+		{"fn.created > 2000-01-01", false},
+		{"attributes has Hidden", true},
+		{"attributes has 'hidden|system'", true},
+		{"attributes has Hidden|ReadOnly", false},
+		{"attributes = 6", true},
+		{"inuse = false", true},
+		{"source = record", true},
+		{"NOT Deleted OR Name LIKE '*.EXE'", true},
+		{"contenttype = 'application/vnd.microsoft.portable-executable' and name not like '*.exe'", false},
+		{"contenttype like 'image/*'", false},
+		{"path like '/users/*'", true},
+		{"path like '/Windows/*'", false},
+	}
+
+	for _, test := range tests {
+		f, err := filter.Compile(test.expr)
+		require.Nilf(t, err, "unable to compile %q: %v", test.expr, err)
+		assert.Equalf(t, test.expected, f.Match(e), "expression: %s", test.expr)
+	}
+}
+
+func TestFilter_Uses(t *testing.T) {
+	f, err := filter.Compile("inuse and (Path like '/Users/*' or name = 'a')")
+	require.Nilf(t, err, "unable to compile: %v", err)
+	assert.True(t, f.Uses("path"))
+	assert.True(t, f.Uses("Name"))
+	assert.False(t, f.Uses("size"))
+}
+
+func TestFilter_Precedence(t *testing.T) {
+	f, err := filter.Compile("inuse or directory and record = 1")
+	require.Nilf(t, err, "unable to compile: %v", err)
+	assert.True(t, f.Match(export.Entry{InUse: true}))
+	assert.False(t, f.Match(export.Entry{Directory: true}))
+	assert.True(t, f.Match(export.Entry{Directory: true, RecordNumber: 1}))
+}
+
+func TestFilter_PendingDelete(t *testing.T) {
+	e := export.Entry{InUse: true, PendingDelete: true}
+	for expr, expected := range map[string]bool{"deleted": true, "inuse": true, "pendingdelete": true} {
+		f, err := filter.Compile(expr)
+		require.Nilf(t, err, "unable to compile %q: %v", expr, err)
+		assert.Equalf(t, expected, f.Match(e), "expression: %s", expr)
+	}
+	f, err := filter.Compile("pendingdelete")
+	require.Nilf(t, err, "unable to compile: %v", err)
+	assert.False(t, f.Match(export.Entry{InUse: false}))
+}
+
+func TestCompile_Errors(t *testing.T) {
+	invalid := []string{
+		"",
+		"unknown = 1",
+		"name",
+		"name =",
+		"size like '*'",
+		"size > 'abc'",
+		"deleted > true",
+		"si.created > yesterday",
+		"attributes has Unknown",
+		"name matches '('",
+		"(deleted",
+		"deleted)",
+		"name = 'unterminated",
+		"deleted and",
+		"name ! 'x'",
+	}
+	for _, expr := range invalid {
+		_, err := filter.Compile(expr)
+		assert.NotNilf(t, err, "expected error for %q", expr)
+	}
+}
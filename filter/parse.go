@@ -0,0 +1,377 @@
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/t9t/gomft/mft"
+)
+
+type tokenType int
+
+const (
+	tokenIdent    tokenType = iota // field names, keywords and bare words
+	tokenString                    // quoted strings
+	tokenLiteral                   // numbers, dates and times
+	tokenOperator                  // comparison operators such as = and <=
+	tokenOpen                      // (
+	tokenClose                     // )
+)
+
+type token struct {
+	typ  tokenType
+	text string
+	pos  int
+}
+
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+
+var fileAttributesByName = map[string]mft.FileAttribute{
+	"readonly":           mft.FileAttributeReadOnly,
+	"hidden":             mft.FileAttributeHidden,
+	"system":             mft.FileAttributeSystem,
+	"archive":            mft.FileAttributeArchive,
+	"device":             mft.FileAttributeDevice,
+	"normal":             mft.FileAttributeNormal,
+	"temporary":          mft.FileAttributeTemporary,
+	"sparsefile":         mft.FileAttributeSparseFile,
+	"reparsepoint":       mft.FileAttributeReparsePoint,
+	"compressed":         mft.FileAttributeCompressed,
+	"offline":            mft.FileAttributeOffline,
+	"notcontentindexed":  mft.FileAttributeNotContentIndexed,
+	"encrypted":          mft.FileAttributeEncrypted,
+	"integritystream":    mft.FileAttributeIntegrityStream,
+	"virtual":            mft.FileAttributeVirtual,
+	"noscrubdata":        mft.FileAttributeNoScrubData,
+	"recallonopen":       mft.FileAttributeRecallOnOpen,
+	"pinned":             mft.FileAttributePinned,
+	"unpinned":           mft.FileAttributeUnpinned,
+	"recallondataaccess": mft.FileAttributeRecallOnDataAccess,
+	"directory":          mft.FileAttributeDirectory,
+	"indexview":          mft.FileAttributeIndexView,
+}
+
+func tokenize(s string) ([]token, error) {
+	tokens := make([]token, 0)
+	runes := []rune(s)
+	for i := 0; i < len(runes); {
+		c := runes[i]
+		start := i
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '(':
+			tokens = append(tokens, token{typ: tokenOpen, text: "(", pos: start})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{typ: tokenClose, text: ")", pos: start})
+			i++
+		case c == '\'' || c == '"':
+			var sb strings.Builder
+			i++
+			for ; i < len(runes) && runes[i] != c; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				sb.WriteRune(runes[i])
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("unterminated string starting at position %d", start)
+			}
+			i++
+			tokens = append(tokens, token{typ: tokenString, text: sb.String(), pos: start})
+		case c == '=' || c == '!' || c == '<' || c == '>':
+			i++
+			if i < len(runes) && runes[i] == '=' {
+				i++
+			}
+			op := string(runes[start:i])
+			if op == "==" {
+				op = "="
+			}
+			if op == "!" {
+				return nil, fmt.Errorf("unexpected ! at position %d", start)
+			}
+			tokens = append(tokens, token{typ: tokenOperator, text: op, pos: start})
+		case unicode.IsDigit(c):
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || strings.ContainsRune(":.+-", runes[i])) {
+				i++
+			}
+			tokens = append(tokens, token{typ: tokenLiteral, text: string(runes[start:i]), pos: start})
+		case unicode.IsLetter(c) || c == '_':
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_' || runes[i] == '.' || runes[i] == '|') {
+				i++
+			}
+			tokens = append(tokens, token{typ: tokenIdent, text: string(runes[start:i]), pos: start})
+		default:
+			return nil, fmt.Errorf("unexpected character %q at position %d", c, start)
+		}
+	}
+	return tokens, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	used   map[string]bool
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *parser) peek() token {
+	if p.done() {
+		return token{typ: tokenIdent, text: "end of expression", pos: -1}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *parser) isKeyword(keyword string) bool {
+	t := p.peek()
+	return !p.done() && t.typ == tokenIdent && strings.EqualFold(t.text, keyword)
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("and") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (node, error) {
+	if p.isKeyword("not") {
+		p.next()
+		n, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{n: n}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.next()
+	if t.pos == -1 {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	if t.typ == tokenOpen {
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.typ != tokenClose {
+			return nil, fmt.Errorf("expected ) at position %d but got %s", closing.pos, closing.text)
+		}
+		return n, nil
+	}
+	if t.typ != tokenIdent {
+		return nil, fmt.Errorf("expected a field name at position %d but got %s", t.pos, t.text)
+	}
+
+	f, ok := fields[strings.ToLower(t.text)]
+	if !ok {
+		return nil, fmt.Errorf("unknown field %q at position %d", t.text, t.pos)
+	}
+	p.used[f.name] = true
+
+	negate := false
+	if p.isKeyword("not") && p.pos+1 < len(p.tokens) {
+		following := strings.ToLower(p.tokens[p.pos+1].text)
+		if following == "like" || following == "matches" {
+			p.next()
+			negate = true
+		}
+	}
+
+	op := p.peek()
+	isOp := op.typ == tokenOperator || p.isKeyword("like") || p.isKeyword("matches") || p.isKeyword("has")
+	if !isOp {
+		if f.kind != kindBool {
+			return nil, fmt.Errorf("expected an operator after %s field %q at position %d", f.kind, f.name, t.pos)
+		}
+		return boolFieldNode{f: f}, nil
+	}
+	p.next()
+
+	value := p.next()
+	if value.typ == tokenOpen || value.typ == tokenClose || value.typ == tokenOperator || value.pos == -1 {
+		return nil, fmt.Errorf("expected a value at position %d but got %s", value.pos, value.text)
+	}
+	n, err := comparison(f, strings.ToLower(op.text), value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid comparison at position %d: %v", t.pos, err)
+	}
+	if negate {
+		return notNode{n: n}, nil
+	}
+	return n, nil
+}
+
+func comparison(f field, op string, value token) (node, error) {
+	switch f.kind {
+	case kindString:
+		n := stringNode{f: f, op: op, value: value.text}
+		switch op {
+		case "like":
+			n.re = regexp.MustCompile(globToRegexp(value.text))
+		case "matches":
+			re, err := regexp.Compile("(?i)" + value.text)
+			if err != nil {
+				return nil, fmt.Errorf("invalid regular expression %q: %v", value.text, err)
+			}
+			n.re = re
+		case "has":
+			return nil, fmt.Errorf("operator %s cannot be used with %s field %q", op, f.kind, f.name)
+		}
+		return n, nil
+	case kindNumber:
+		if err := checkOrdered(f, op); err != nil {
+			return nil, err
+		}
+		v, err := parseNumber(value.text)
+		if err != nil {
+			return nil, err
+		}
+		return numberNode{f: f, op: op, value: v}, nil
+	case kindBool:
+		if op != "=" && op != "!=" {
+			return nil, fmt.Errorf("operator %s cannot be used with %s field %q", op, f.kind, f.name)
+		}
+		v, err := strconv.ParseBool(strings.ToLower(value.text))
+		if err != nil {
+			return nil, fmt.Errorf("invalid boolean value %q", value.text)
+		}
+		return boolNode{f: f, op: op, value: v}, nil
+	case kindTime:
+		if err := checkOrdered(f, op); err != nil {
+			return nil, err
+		}
+		v, err := parseTime(value.text)
+		if err != nil {
+			return nil, err
+		}
+		return timeNode{f: f, op: op, value: v}, nil
+	case kindAttributes:
+		if op != "has" && op != "=" && op != "!=" {
+			return nil, fmt.Errorf("operator %s cannot be used with %s field %q", op, f.kind, f.name)
+		}
+		v, err := parseFileAttributes(value.text)
+		if err != nil {
+			return nil, err
+		}
+		return attributesNode{f: f, op: op, value: v}, nil
+	}
+	return nil, fmt.Errorf("unsupported field %q", f.name)
+}
+
+func checkOrdered(f field, op string) error {
+	switch op {
+	case "=", "!=", "<", "<=", ">", ">=":
+		return nil
+	}
+	return fmt.Errorf("operator %s cannot be used with %s field %q", op, f.kind, f.name)
+}
+
+// globToRegexp converts a pattern where * matches any sequence of characters and ? matches any single character into
+// a case insensitive regular expression matching the whole string.
+func globToRegexp(pattern string) string {
+	var sb strings.Builder
+	sb.WriteString("(?is)^")
+	for _, c := range pattern {
+		switch c {
+		case '*':
+			sb.WriteString(".*")
+		case '?':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+	return sb.String()
+}
+
+func parseNumber(s string) (uint64, error) {
+	multiplier := uint64(1)
+	if len(s) > 1 && !strings.HasPrefix(strings.ToLower(s), "0x") {
+		switch unicode.ToUpper(rune(s[len(s)-1])) {
+		case 'K':
+			multiplier = 1 << 10
+		case 'M':
+			multiplier = 1 << 20
+		case 'G':
+			multiplier = 1 << 30
+		case 'T':
+			multiplier = 1 << 40
+		}
+		if multiplier != 1 {
+			s = s[:len(s)-1]
+		}
+	}
+	v, err := strconv.ParseUint(s, 0, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", s)
+	}
+	return v * multiplier, nil
+}
+
+func parseTime(s string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q (expected eg. 2006-01-02 or 2006-01-02T15:04:05Z)", s)
+}
+
+func parseFileAttributes(s string) (mft.FileAttribute, error) {
+	if v, err := parseNumber(s); err == nil {
+		return mft.FileAttribute(v), nil
+	}
+	a := mft.FileAttribute(0)
+	for _, name := range strings.Split(s, "|") {
+		v, ok := fileAttributesByName[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return 0, fmt.Errorf("unknown file attribute %q", name)
+		}
+		a |= v
+	}
+	return a, nil
+}
@@ -0,0 +1,160 @@
+package fragment
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/t9t/gomft/lznt1"
+)
+
+// DefaultCompressionUnitClusters is the size of a compression unit in clusters. NTFS always uses 16 clusters (the
+// compression unit exponent 4 in the attribute header) for compressed files.
+const DefaultCompressionUnitClusters = 16
+
+// defaultClusterSize is the cluster size assumed when no compression unit size is specified.
+const defaultClusterSize = 4096
+
+// unit describes the fragments of a compression unit.
+type unit struct {
+	fragments []Fragment // the fragments stored on the volume, without the sparse ones
+	stored    int64      // the total length of the stored fragments
+	sparse    int64      // the total length of the sparse fragments
+}
+
+// CompressedReaderAt reads the data of a stream compressed by NTFS from fragments on an underlying io.ReaderAt. It
+// groups the fragments per compression unit and determines how each unit is stored from the lengths of its
+// fragments: a unit without sparse fragments is stored as-is, a unit with only sparse fragments reads as zeroes, and
+// any other unit is compressed using LZNT1 into its stored fragments. Like a ReaderAt, it is safe for concurrent use
+// when the underlying io.ReaderAt is.
+//
+// The most recently decompressed unit is kept, so reading a stream sequentially in blocks smaller than a compression
+// unit decompresses each unit only once.
+type CompressedReaderAt struct {
+	src      io.ReaderAt
+	units    []unit
+	unitSize int64
+	size     int64
+
+	mu         sync.Mutex
+	cached     []byte
+	cachedUnit int
+}
+
+// NewCompressedReaderAt creates a CompressedReaderAt reading the fragments of a compressed stream of size bytes from
+// src. The unitSize is the size of a compression unit in bytes, which is DefaultCompressionUnitClusters times the
+// size of a cluster; when zero, a cluster size of 4096 bytes is assumed. The fragments must include the sparse ones,
+// since they tell which units are compressed (see mft.DataRunsToFragments).
+func NewCompressedReaderAt(src io.ReaderAt, fragments []Fragment, unitSize int64, size int64) *CompressedReaderAt {
+	if unitSize <= 0 {
+		unitSize = DefaultCompressionUnitClusters * defaultClusterSize
+	}
+	units := make([]unit, 0)
+	current := unit{}
+	for _, f := range fragments {
+		for f.Length > 0 {
+			part := f
+			if remaining := unitSize - current.stored - current.sparse; part.Length > remaining {
+				part.Length = remaining
+			}
+			if part.Sparse {
+				current.sparse += part.Length
+			} else {
+				current.fragments = append(current.fragments, part)
+				current.stored += part.Length
+			}
+			f.Length -= part.Length
+			f.Offset += part.Length
+			if current.stored+current.sparse == unitSize {
+				units = append(units, current)
+				current = unit{}
+			}
+		}
+	}
+	if current.stored+current.sparse > 0 {
+		units = append(units, current)
+	}
+	return &CompressedReaderAt{src: src, units: units, unitSize: unitSize, size: size, cachedUnit: -1}
+}
+
+// Size returns the size of the (decompressed) stream.
+func (r *CompressedReaderAt) Size() int64 {
+	return r.size
+}
+
+// ReadAt reads len(p) bytes of decompressed data starting at position off of the stream. When fewer than len(p) bytes
+// are available, it returns the number of bytes read and io.EOF. Data beyond the fragments, but within the size of
+// the stream, reads as zeroes.
+func (r *CompressedReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset %d", off)
+	}
+	if off >= r.size {
+		if len(p) == 0 {
+			return 0, nil
+		}
+		return 0, io.EOF
+	}
+
+	target := p
+	if int64(len(target)) > r.size-off {
+		target = target[:r.size-off]
+	}
+	total := 0
+	for total < len(target) {
+		idx := int(off / r.unitSize)
+		within := off - int64(idx)*r.unitSize
+		n := 0
+		if idx >= len(r.units) {
+			for n = 0; n < len(target)-total && int64(n) < r.unitSize-within; n++ {
+				target[total+n] = 0
+			}
+		} else {
+			data, err := r.unit(idx)
+			if err != nil {
+				return total, err
+			}
+			n = copy(target[total:], data[within:])
+		}
+		total += n
+		off += int64(n)
+	}
+	if total < len(p) {
+		return total, io.EOF
+	}
+	return total, nil
+}
+
+// unit returns the decompressed data of the unit with the index.
+func (r *CompressedReaderAt) unit(idx int) ([]byte, error) {
+	r.mu.Lock()
+	if r.cachedUnit == idx {
+		data := r.cached
+		r.mu.Unlock()
+		return data, nil
+	}
+	r.mu.Unlock()
+
+	u := r.units[idx]
+	data := make([]byte, r.unitSize)
+	if u.stored > 0 {
+		stored := data
+		if u.sparse > 0 {
+			stored = make([]byte, u.stored)
+		}
+		n, err := NewReaderAt(r.src, u.fragments).ReadAt(stored[:u.stored], 0)
+		if n < int(u.stored) {
+			return nil, fmt.Errorf("unable to read compression unit %d: %v", idx, err)
+		}
+		if u.sparse > 0 {
+			if _, err := lznt1.Decompress(data, stored); err != nil {
+				return nil, fmt.Errorf("unable to decompress compression unit %d: %v", idx, err)
+			}
+		}
+	}
+
+	r.mu.Lock()
+	r.cached, r.cachedUnit = data, idx
+	r.mu.Unlock()
+	return data, nil
+}
@@ -0,0 +1,96 @@
+package fragment_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/fragment"
+)
+
+func TestCompressedReaderAt(t *testing.T) {
+	// Compression units of 4 clusters of 512 bytes
+	const unitSize = 2048
+	volume := make([]byte, 50*512)
+	// Unit 0: "abc" followed by a back-reference of 2045 bytes, compressed into 1 cluster
+	copy(volume[10*512:], []byte{0x05, 0xb0, 0x08, 'a', 'b', 'c', 0xfa, 0x27, 0x00, 0x00})
+	// Unit 1: stored as-is in 2 fragments
+	copy(volume[20*512:], bytes.Repeat([]byte{'x'}, 1024))
+	copy(volume[30*512:], bytes.Repeat([]byte{'y'}, 1024))
+	// Unit 2: sparse; unit 3: stored as-is, the last part of the stream
+	copy(volume[40*512:], bytes.Repeat([]byte{'z'}, 512))
+
+	fragments := []fragment.Fragment{
+		{Offset: 10 * 512, Length: 512},
+		{Offset: 10 * 512, Length: 3 * 512, Sparse: true},
+		{Offset: 20 * 512, Length: 1024},
+		{Offset: 30 * 512, Length: 1024},
+		{Offset: 30 * 512, Length: unitSize, Sparse: true},
+		{Offset: 40 * 512, Length: 512},
+	}
+	expected := bytes.Repeat([]byte("abc"), 683)[:unitSize]
+	expected = append(expected, bytes.Repeat([]byte{'x'}, 1024)...)
+	expected = append(expected, bytes.Repeat([]byte{'y'}, 1024)...)
+	expected = append(expected, make([]byte, unitSize)...)
+	expected = append(expected, bytes.Repeat([]byte{'z'}, 300)...)
+
+	r := fragment.NewCompressedReaderAt(bytes.NewReader(volume), fragments, unitSize, int64(len(expected)))
+	assert.Equal(t, int64(len(expected)), r.Size())
+	data, err := ioutil.ReadAll(io.NewSectionReader(r, 0, r.Size()))
+	require.Nilf(t, err, "unable to read: %v", err)
+	assert.Equal(t, expected, data)
+
+	// Reads spanning units, and reads past the end
+	p := make([]byte, 100)
+	n, err := r.ReadAt(p, unitSize-50)
+	require.Nil(t, err)
+	assert.Equal(t, 100, n)
+	assert.Equal(t, expected[unitSize-50:unitSize+50], p)
+	n, err = r.ReadAt(p, int64(len(expected))-10)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, 10, n)
+	assert.Equal(t, expected[len(expected)-10:], p[:10])
+}
+
+func TestCompressedReaderAt_BeyondFragments(t *testing.T) {
+	// A stream which is larger than its fragments reads as zeroes after them
+	r := fragment.NewCompressedReaderAt(bytes.NewReader([]byte("data")), []fragment.Fragment{{Offset: 0, Length: 4}}, 4, 10)
+	p := make([]byte, 10)
+	n, err := r.ReadAt(p, 0)
+	require.Nil(t, err)
+	assert.Equal(t, 10, n)
+	assert.Equal(t, []byte("data\x00\x00\x00\x00\x00\x00"), p)
+}
+
+func TestCompressedReaderAt_Errors(t *testing.T) {
+	volume := []byte{0x05, 0xb0, 0x00, 'a'}
+	fragments := []fragment.Fragment{{Offset: 0, Length: 4}, {Length: 4, Sparse: true}}
+	r := fragment.NewCompressedReaderAt(bytes.NewReader(volume), fragments, 8, 8)
+	_, err := r.ReadAt(make([]byte, 8), 0)
+	assert.EqualError(t, err, "unable to decompress compression unit 0: chunk at output offset 0 needs 6 bytes but only 2 are left")
+
+	r = fragment.NewCompressedReaderAt(bytes.NewReader(volume), []fragment.Fragment{{Offset: 2, Length: 4}}, 4, 4)
+	_, err = r.ReadAt(make([]byte, 4), 0)
+	assert.EqualError(t, err, "unable to read compression unit 0: unable to read fragment at offset 2: unexpected EOF")
+
+	_, err = r.ReadAt(make([]byte, 4), -1)
+	assert.EqualError(t, err, "negative offset -1")
+}
+
+func TestReaderAt_Sparse(t *testing.T) {
+	r := fragment.NewReaderAt(bytes.NewReader([]byte("abcdef")), []fragment.Fragment{
+		{Offset: 0, Length: 2}, {Offset: 2, Length: 3, Sparse: true}, {Offset: 4, Length: 2}})
+	p := make([]byte, 7)
+	n, err := r.ReadAt(p, 0)
+	require.Nil(t, err)
+	assert.Equal(t, 7, n)
+	assert.Equal(t, []byte("ab\x00\x00\x00ef"), p)
+
+	data, err := ioutil.ReadAll(fragment.NewReader(bytes.NewReader([]byte("abcdef")), []fragment.Fragment{
+		{Offset: 0, Length: 2}, {Offset: 2, Length: 3, Sparse: true}, {Offset: 4, Length: 2}}))
+	require.Nil(t, err)
+	assert.Equal(t, []byte("ab\x00\x00\x00ef"), data)
+}
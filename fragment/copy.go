@@ -0,0 +1,86 @@
+package fragment
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+)
+
+// DefaultCopyChunkSize is the size of the chunks read by Copy when no other size is specified.
+const DefaultCopyChunkSize = 1024 * 1024
+
+// Copy copies length bytes from src to dst, using workers goroutines which each read and write chunks of chunkSize
+// bytes at the same time. When src is a ReaderAt over an *os.File, this keeps multiple reads in flight, which hides
+// the seek latency of disks and improves the throughput of SSDs and network storage. When chunkSize is zero,
+// DefaultCopyChunkSize is used; when workers is zero, runtime.GOMAXPROCS(0) is used.
+//
+// Copy stops at the first error, which is returned. Since chunks are written in no particular order, dst may contain
+// any of the chunks after an error.
+func Copy(dst io.WriterAt, src io.ReaderAt, length int64, chunkSize int, workers int) error {
+	return CopyContext(context.Background(), dst, src, length, chunkSize, workers)
+}
+
+// CopyContext works like Copy, but also stops when ctx is done, returning ctx.Err() once the chunks being copied at
+// that moment are finished.
+func CopyContext(ctx context.Context, dst io.WriterAt, src io.ReaderAt, length int64, chunkSize int, workers int) error {
+	if chunkSize <= 0 {
+		chunkSize = DefaultCopyChunkSize
+	}
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	offsets := make(chan int64)
+	done := make(chan struct{})
+	var once sync.Once
+	var firstErr error
+	fail := func(err error) {
+		once.Do(func() {
+			firstErr = err
+			close(done)
+		})
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buf := make([]byte, chunkSize)
+			for off := range offsets {
+				chunk := buf
+				if remaining := length - off; remaining < int64(len(chunk)) {
+					chunk = chunk[:remaining]
+				}
+				if n, err := src.ReadAt(chunk, off); n < len(chunk) {
+					if err == nil {
+						err = io.ErrUnexpectedEOF
+					}
+					fail(fmt.Errorf("unable to read %d bytes at offset %d: %v", len(chunk), off, err))
+					return
+				}
+				if _, err := dst.WriteAt(chunk, off); err != nil {
+					fail(fmt.Errorf("unable to write %d bytes at offset %d: %v", len(chunk), off, err))
+					return
+				}
+			}
+		}()
+	}
+
+loop:
+	for off := int64(0); off < length; off += int64(chunkSize) {
+		select {
+		case offsets <- off:
+		case <-done:
+			break loop
+		case <-ctx.Done():
+			fail(ctx.Err())
+			break loop
+		}
+	}
+	close(offsets)
+	wg.Wait()
+	return firstErr
+}
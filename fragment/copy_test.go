@@ -0,0 +1,71 @@
+package fragment_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/fragment"
+)
+
+func TestCopy(t *testing.T) {
+	testData := generateTestData()
+	fragments := []fragment.Fragment{{Offset: 6645, Length: 3423}, {Offset: 803, Length: 5000}}
+	src := fragment.NewReaderAt(bytes.NewReader(testData), fragments)
+
+	for _, workers := range []int{0, 1, 7} {
+		dst := &memoryWriterAt{}
+		err := fragment.Copy(dst, src, src.Size(), 1000, workers)
+		require.Nilf(t, err, "workers %d: %v", workers, err)
+		assert.Equal(t, append(append([]byte{}, testData[6645:6645+3423]...), testData[803:803+5000]...), dst.data)
+	}
+}
+
+func TestCopy_ReadError(t *testing.T) {
+	src := fragment.NewReaderAt(bytes.NewReader(make([]byte, 100)), []fragment.Fragment{{Offset: 0, Length: 200}})
+	err := fragment.Copy(&memoryWriterAt{}, src, src.Size(), 64, 1)
+	assert.EqualError(t, err, "unable to read 64 bytes at offset 64: unable to read fragment at offset 64: unexpected EOF")
+}
+
+func TestCopy_WriteError(t *testing.T) {
+	src := bytes.NewReader(generateTestData())
+	err := fragment.Copy(&memoryWriterAt{err: errors.New("disk full")}, src, 1000, 0, 0)
+	assert.EqualError(t, err, "unable to write 1000 bytes at offset 0: disk full")
+}
+
+type memoryWriterAt struct {
+	mu   sync.Mutex
+	data []byte
+	err  error
+}
+
+func (w *memoryWriterAt) WriteAt(p []byte, off int64) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if end := int(off) + len(p); end > len(w.data) {
+		w.data = append(w.data, make([]byte, end-len(w.data))...)
+	}
+	copy(w.data[off:], p)
+	return len(p), nil
+}
+
+func TestCopyContext(t *testing.T) {
+	testData := generateTestData()
+	src := bytes.NewReader(testData)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := fragment.CopyContext(ctx, &memoryWriterAt{}, src, src.Size(), 100, 1)
+	assert.Equal(t, context.Canceled, err)
+
+	dst := &memoryWriterAt{}
+	err = fragment.CopyContext(context.Background(), dst, src, src.Size(), 100, 2)
+	require.Nil(t, err)
+	assert.Equal(t, testData, dst.data)
+}
@@ -12,7 +12,16 @@
 	calls after that will return 0, io.EOF.
 
 	When accessing a new fragment, the Reader will seek using the absolute Length in the fragment from the start
-	of the contained io.ReadSeeker (using io.SeekStart).
+	of the contained io.ReadSeeker (using io.SeekStart). Sparse fragments are not read at all; they yield zeroes.
+
+	Since a Reader moves the position of its io.ReadSeeker, only one Reader can use it at a time. To read fragments
+	concurrently, for example when extracting many files from a volume, use a ReaderAt instead. It translates each
+	ReadAt() call to ReadAt() calls on an underlying io.ReaderAt, so it has no position and can be shared. Copy uses
+	it to keep multiple reads in flight.
+
+	NTFS compresses files in compression units (16 clusters), each of which is either stored as-is, compressed using
+	LZNT1 into fewer clusters followed by a sparse run, or entirely sparse. A CompressedReaderAt reads the fragments of
+	such a stream, including the sparse ones, and serves the decompressed data.
 */
 package fragment
 
@@ -22,9 +31,11 @@ import (
 )
 
 // Fragment contains an absolute Offset in bytes from the start of a volume and a Length of the fragment, also in bytes.
+// A Sparse fragment is not stored on the volume: it reads as Length zeroes, and its Offset is not used.
 type Fragment struct {
 	Offset int64
 	Length int64
+	Sparse bool
 }
 
 // A fragment Reader will read data from the fragments in order. When one fragment is depleted, it will seek to the
@@ -60,6 +71,9 @@ func (r *Reader) Read(p []byte) (n int, err error) {
 		}
 		next := r.fragments[r.idx]
 		r.remaining = next.Length
+		if next.Sparse {
+			return r.Read(p)
+		}
 		seeked, err := r.src.Seek(next.Offset, io.SeekStart)
 		if err != nil {
 			return 0, fmt.Errorf("unable to seek to next offset %d: %v", next.Offset, err)
@@ -74,6 +88,13 @@ func (r *Reader) Read(p []byte) (n int, err error) {
 		target = p[:r.remaining]
 	}
 
+	if r.fragments[r.idx].Sparse {
+		for i := range target {
+			target[i] = 0
+		}
+		r.remaining -= int64(len(target))
+		return len(target), nil
+	}
 	n, err = io.ReadFull(r.src, target)
 	r.remaining -= int64(n)
 	return n, err
@@ -0,0 +1,83 @@
+package fragment
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// ReaderAt reads data from fragments at arbitrary positions, using ReadAt() calls on an underlying io.ReaderAt (such
+// as an *os.File, which uses pread). Unlike Reader, it has no position of its own, so it is safe for concurrent use
+// when the underlying io.ReaderAt is. This allows reading different fragments, or the fragments of different files,
+// at the same time.
+type ReaderAt struct {
+	src       io.ReaderAt
+	fragments []Fragment
+	starts    []int64
+	size      int64
+}
+
+// NewReaderAt creates a ReaderAt reading the fragments from src. Positions passed to ReadAt are relative to the start
+// of the first fragment, just like the data returned by a Reader.
+func NewReaderAt(src io.ReaderAt, fragments []Fragment) *ReaderAt {
+	starts := make([]int64, len(fragments))
+	size := int64(0)
+	for i, f := range fragments {
+		starts[i] = size
+		size += f.Length
+	}
+	return &ReaderAt{src: src, fragments: fragments, starts: starts, size: size}
+}
+
+// Size returns the total length of all fragments.
+func (r *ReaderAt) Size() int64 {
+	return r.size
+}
+
+// ReadAt reads len(p) bytes starting at position off of the fragments. Reads spanning multiple fragments are split
+// into a ReadAt call for each fragment. When fewer than len(p) bytes are available, it returns the number of bytes
+// read and io.EOF.
+func (r *ReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset %d", off)
+	}
+	if off >= r.size {
+		if len(p) == 0 {
+			return 0, nil
+		}
+		return 0, io.EOF
+	}
+
+	// The fragment containing off is the last one starting at or before it
+	idx := sort.Search(len(r.starts), func(i int) bool { return r.starts[i] > off }) - 1
+	total := 0
+	for ; total < len(p) && idx < len(r.fragments); idx++ {
+		f := r.fragments[idx]
+		within := off - r.starts[idx]
+		target := p[total:]
+		if remaining := f.Length - within; int64(len(target)) > remaining {
+			target = target[:remaining]
+		}
+		if f.Sparse {
+			for i := range target {
+				target[i] = 0
+			}
+			total += len(target)
+			off += int64(len(target))
+			continue
+		}
+		n, err := r.src.ReadAt(target, f.Offset+within)
+		total += n
+		off += int64(n)
+		if n < len(target) {
+			if err == nil || err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return total, fmt.Errorf("unable to read fragment at offset %d: %v", f.Offset+within, err)
+		}
+	}
+	if total < len(p) {
+		return total, io.EOF
+	}
+	return total, nil
+}
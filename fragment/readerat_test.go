@@ -0,0 +1,91 @@
+package fragment_test
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/fragment"
+)
+
+func TestReaderAt(t *testing.T) {
+	testData := generateTestData()
+	fragments := []fragment.Fragment{
+		{Offset: 3756, Length: 1810},
+		{Offset: 6645, Length: 3423},
+		{Offset: 803, Length: 6154},
+	}
+	expected := make([]byte, 0)
+	expected = append(expected, testData[3756:3756+1810]...)
+	expected = append(expected, testData[6645:6645+3423]...)
+	expected = append(expected, testData[803:803+6154]...)
+
+	r := fragment.NewReaderAt(bytes.NewReader(testData), fragments)
+	assert.Equal(t, int64(len(expected)), r.Size())
+
+	tests := []struct {
+		off    int64
+		length int
+	}{
+		{0, 100},
+		{1800, 20},                      // spanning 2 fragments
+		{1000, 5000},                    // spanning 3 fragments
+		{1810, 3423},                    // exactly the second fragment
+		{int64(len(expected)) - 10, 10}, // the end
+	}
+	for _, test := range tests {
+		p := make([]byte, test.length)
+		n, err := r.ReadAt(p, test.off)
+		require.Nilf(t, err, "offset %d: %v", test.off, err)
+		assert.Equal(t, test.length, n)
+		assert.Equalf(t, expected[test.off:test.off+int64(test.length)], p, "offset %d", test.off)
+	}
+}
+
+func TestReaderAt_EOF(t *testing.T) {
+	testData := generateTestData()
+	r := fragment.NewReaderAt(bytes.NewReader(testData), []fragment.Fragment{{Offset: 100, Length: 50}})
+
+	p := make([]byte, 20)
+	n, err := r.ReadAt(p, 40)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, 10, n)
+	assert.Equal(t, testData[140:150], p[:10])
+
+	n, err = r.ReadAt(p, 50)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, 0, n)
+
+	_, err = r.ReadAt(p, -1)
+	assert.EqualError(t, err, "negative offset -1")
+}
+
+func TestReaderAt_SourceTooShort(t *testing.T) {
+	r := fragment.NewReaderAt(bytes.NewReader(make([]byte, 100)), []fragment.Fragment{{Offset: 50, Length: 100}})
+	n, err := r.ReadAt(make([]byte, 100), 0)
+	assert.Equal(t, 50, n)
+	assert.EqualError(t, err, "unable to read fragment at offset 50: unexpected EOF")
+}
+
+func TestReaderAt_Concurrent(t *testing.T) {
+	testData := generateTestData()
+	fragments := []fragment.Fragment{{Offset: 5120, Length: 5120}, {Offset: 0, Length: 5120}}
+	r := fragment.NewReaderAt(bytes.NewReader(testData), fragments)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			p := make([]byte, 1024)
+			off := int64(i * 1024)
+			_, err := r.ReadAt(p, off)
+			assert.Nil(t, err)
+			assert.Equal(t, testData[(off+5120)%10240:][:1024], p)
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,103 @@
+package fsctl
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/t9t/gomft/fragment"
+)
+
+// FsctlGetRetrievalPointers is the control code to get the extents of a file.
+const FsctlGetRetrievalPointers = 0x00090073
+
+// extentsPerCall is the number of extents requested by each FSCTL_GET_RETRIEVAL_POINTERS call.
+const extentsPerCall = 256
+
+// ErrMoreData is returned by a Device when out is too small to hold all data, like ERROR_MORE_DATA. The data that did
+// fit in out is valid.
+var ErrMoreData = errors.New("more data is available")
+
+// An Extent is a contiguous range of clusters of a file, as returned for FSCTL_GET_RETRIEVAL_POINTERS.
+type Extent struct {
+	Vcn      int64 // virtual cluster number: the position of the extent in the file, in clusters
+	Clusters int64 // length of the extent in clusters
+	Lcn      int64 // logical cluster number: the position of the extent on the volume, or -1 when it is not allocated
+}
+
+// RetrievalPointers returns the extents of the file opened by the device. A Device should return io.EOF when the file
+// has no extents (like ERROR_HANDLE_EOF), which is the case when it is empty or its data is resident in its MFT
+// record; RetrievalPointers then returns no extents.
+func RetrievalPointers(file Device) ([]Extent, error) {
+	extents := make([]Extent, 0)
+	in := make([]byte, 8)
+	out := make([]byte, 16+16*extentsPerCall)
+	vcn := int64(0)
+	for {
+		binary.LittleEndian.PutUint64(in, uint64(vcn))
+		n, err := file.DeviceIoControl(FsctlGetRetrievalPointers, in, out)
+		if err == io.EOF {
+			return extents, nil
+		}
+		if err != nil && err != ErrMoreData {
+			return nil, fmt.Errorf("unable to get retrieval pointers at VCN %d: %v", vcn, err)
+		}
+		if n < 16 {
+			return nil, fmt.Errorf("expected at least 16 bytes of retrieval pointers but got %d", n)
+		}
+		count := int(binary.LittleEndian.Uint32(out))
+		if count > (n-16)/16 {
+			return nil, fmt.Errorf("%d extents do not fit in %d bytes of retrieval pointers", count, n)
+		}
+		vcn = int64(binary.LittleEndian.Uint64(out[8:]))
+		for i := 0; i < count; i++ {
+			nextVcn := int64(binary.LittleEndian.Uint64(out[16+16*i:]))
+			lcn := int64(binary.LittleEndian.Uint64(out[24+16*i:]))
+			if nextVcn <= vcn {
+				return nil, fmt.Errorf("extent %d ends at VCN %d, before its start at VCN %d", len(extents), nextVcn, vcn)
+			}
+			extents = append(extents, Extent{Vcn: vcn, Clusters: nextVcn - vcn, Lcn: lcn})
+			vcn = nextVcn
+		}
+		if err != ErrMoreData {
+			return extents, nil
+		}
+		if count == 0 {
+			return nil, fmt.Errorf("no extents returned while more data is available")
+		}
+	}
+}
+
+// ExtentsToFragments converts the extents of a file into Fragments with absolute offsets on the volume, limited to
+// the size of the file, so they can be read using a fragment.Reader or fragment.ReaderAt over the volume. Since
+// fragments cannot represent unallocated ranges, it returns an error when the data of the file contains sparse or
+// compressed extents.
+func ExtentsToFragments(extents []Extent, bytesPerCluster int, size int64) ([]fragment.Fragment, error) {
+	fragments := make([]fragment.Fragment, 0, len(extents))
+	total := int64(0)
+	for _, e := range extents {
+		if total >= size {
+			break
+		}
+		if e.Vcn*int64(bytesPerCluster) != total {
+			return nil, fmt.Errorf("extent at VCN %d does not follow the previous extent", e.Vcn)
+		}
+		if e.Lcn < 0 {
+			return nil, fmt.Errorf("extent at VCN %d is not allocated (sparse or compressed)", e.Vcn)
+		}
+		length := e.Clusters * int64(bytesPerCluster)
+		if total+length > size {
+			length = size - total
+		}
+		fragments = append(fragments, fragment.Fragment{Offset: e.Lcn * int64(bytesPerCluster), Length: length})
+		total += length
+	}
+	if total < size && len(extents) == 0 {
+		return nil, fmt.Errorf("the file has no extents, its data is probably resident in its MFT record")
+	}
+	if total < size {
+		return nil, fmt.Errorf("extents cover %d bytes, but the file is %d bytes", total, size)
+	}
+	return fragments, nil
+}
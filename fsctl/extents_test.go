@@ -0,0 +1,94 @@
+package fsctl_test
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/fragment"
+	"github.com/t9t/gomft/fsctl"
+)
+
+// extentDevice returns the extents like FSCTL_GET_RETRIEVAL_POINTERS does, at most perCall at a time. Each extent is
+// a pair of the next VCN and the LCN.
+func extentDevice(extents [][2]int64, perCall int) fsctl.Device {
+	return deviceFunc(func(code uint32, in []byte, out []byte) (int, error) {
+		if code != fsctl.FsctlGetRetrievalPointers {
+			return 0, errors.New("unsupported control code")
+		}
+		if len(extents) == 0 {
+			return 0, io.EOF
+		}
+		vcn := int64(binary.LittleEndian.Uint64(in))
+		start, startVcn := 0, int64(0)
+		for start < len(extents) && extents[start][0] <= vcn {
+			startVcn = extents[start][0]
+			start++
+		}
+		end := start + perCall
+		if end > len(extents) {
+			end = len(extents)
+		}
+		binary.LittleEndian.PutUint32(out, uint32(end-start))
+		binary.LittleEndian.PutUint64(out[8:], uint64(startVcn))
+		for i, e := range extents[start:end] {
+			binary.LittleEndian.PutUint64(out[16+16*i:], uint64(e[0]))
+			binary.LittleEndian.PutUint64(out[24+16*i:], uint64(e[1]))
+		}
+		n := 16 + 16*(end-start)
+		if end < len(extents) {
+			return n, fsctl.ErrMoreData
+		}
+		return n, nil
+	})
+}
+
+func TestRetrievalPointers(t *testing.T) {
+	device := extentDevice([][2]int64{{4, 1000}, {6, -1}, {7, 20}}, 2)
+	extents, err := fsctl.RetrievalPointers(device)
+	require.Nilf(t, err, "unable to get retrieval pointers: %v", err)
+	assert.Equal(t, []fsctl.Extent{
+		{Vcn: 0, Clusters: 4, Lcn: 1000},
+		{Vcn: 4, Clusters: 2, Lcn: -1},
+		{Vcn: 6, Clusters: 1, Lcn: 20},
+	}, extents)
+
+	extents, err = fsctl.RetrievalPointers(extentDevice(nil, 2))
+	require.Nil(t, err)
+	assert.Equal(t, []fsctl.Extent{}, extents)
+
+	_, err = fsctl.RetrievalPointers(deviceFunc(func(code uint32, in []byte, out []byte) (int, error) {
+		return 0, errors.New("access is denied")
+	}))
+	assert.EqualError(t, err, "unable to get retrieval pointers at VCN 0: access is denied")
+}
+
+func TestExtentsToFragments(t *testing.T) {
+	extents := []fsctl.Extent{
+		{Vcn: 0, Clusters: 4, Lcn: 1000},
+		{Vcn: 4, Clusters: 2, Lcn: 20},
+		{Vcn: 6, Clusters: 10, Lcn: -1}, // preallocated beyond the end of the file
+	}
+	fragments, err := fsctl.ExtentsToFragments(extents, 4096, 5*4096+100)
+	require.Nilf(t, err, "unable to convert extents: %v", err)
+	assert.Equal(t, []fragment.Fragment{
+		{Offset: 1000 * 4096, Length: 4 * 4096},
+		{Offset: 20 * 4096, Length: 4096 + 100},
+	}, fragments)
+
+	fragments, err = fsctl.ExtentsToFragments(nil, 4096, 0)
+	require.Nil(t, err)
+	assert.Equal(t, []fragment.Fragment{}, fragments)
+
+	_, err = fsctl.ExtentsToFragments(extents, 4096, 7*4096)
+	assert.EqualError(t, err, "extent at VCN 6 is not allocated (sparse or compressed)")
+	_, err = fsctl.ExtentsToFragments(extents[:2], 4096, 7*4096)
+	assert.EqualError(t, err, "extents cover 24576 bytes, but the file is 28672 bytes")
+	_, err = fsctl.ExtentsToFragments(nil, 4096, 100)
+	assert.EqualError(t, err, "the file has no extents, its data is probably resident in its MFT record")
+	_, err = fsctl.ExtentsToFragments(extents[1:], 4096, 100)
+	assert.EqualError(t, err, "extent at VCN 4 does not follow the previous extent")
+}
@@ -0,0 +1,215 @@
+/*
+	Package fsctl reads MFT records of a live Windows volume through the NTFS file system driver, using
+	DeviceIoControl with FSCTL_GET_NTFS_FILE_RECORD, rather than reading raw sectors. The driver returns its current
+	view of each record, including changes which have not been written to disk yet, so records are consistent even
+	while the volume is being written to.
+
+	Basic usage
+
+	Open a volume by its drive letter and read records by number. A Volume also implements io.ReaderAt over the MFT, so
+	it can be used wherever MFT data is read by position, such as by pipeline.NewPathResolver or server.Config.
+			// Error handling left out for brevity
+			v, err := fsctl.Open("C:")
+			defer v.Close()
+			record, err := v.Record(mft.VolumeRecordNumber)
+
+	To read a file which is locked, such as a registry hive, get its fragments on the volume and read them from the
+	raw volume.
+			// Error handling left out for brevity
+			frags, err := fsctl.FileFragments(`C:\Windows\System32\config\SYSTEM`, int(v.Data().BytesPerCluster))
+			raw, err := os.Open(`\\.\C:`)
+			r := fragment.NewReader(raw, frags)
+
+	Implementation notes
+
+	Opening a volume requires administrator privileges. Open is only supported on Windows; on other platforms it returns
+	an error. NewVolume accepts any Device, for example to forward the calls to a remote agent.
+
+	The driver returns the nearest record in use at or before the requested number, so records which are not in use
+	cannot be read: Record returns ErrNotInUse for them, and ReadAt returns zeroes in their place (like an empty record
+	in an MFT dump). The driver removes the update sequence from the record; it is put back before the data is
+	returned, so the data is the same as on disk and can be parsed by mft.ParseRecord.
+
+	FileFragments gets the extents of a file using FSCTL_GET_RETRIEVAL_POINTERS, which only requires the file to be
+	opened for reading its attributes, so it also works for files which are locked for reading. The extents reflect the
+	allocation at the moment of the call; a file which is being written to may be moved or extended while it is read.
+	Files whose data is resident, sparse or compressed cannot be read this way.
+*/
+package fsctl
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/t9t/gomft/mft"
+)
+
+// Control codes of the DeviceIoControl calls used.
+const (
+	FsctlGetNtfsVolumeData = 0x00090064
+	FsctlGetNtfsFileRecord = 0x00090068
+)
+
+const (
+	volumeDataSize       = 0x60 // size of NTFS_VOLUME_DATA_BUFFER
+	fileRecordHeaderSize = 0x0C // size of NTFS_FILE_RECORD_OUTPUT_BUFFER without the record
+)
+
+// ErrNotInUse is returned by Record and ReadRecord for records which are not in use.
+var ErrNotInUse = errors.New("record is not in use")
+
+// A Device sends control codes to a volume, like DeviceIoControl does. It returns the number of bytes written to out.
+type Device interface {
+	DeviceIoControl(code uint32, in []byte, out []byte) (int, error)
+}
+
+// VolumeData contains the details of a volume returned for FSCTL_GET_NTFS_VOLUME_DATA which are needed to read its MFT.
+type VolumeData struct {
+	BytesPerSector     uint32
+	BytesPerCluster    uint32
+	RecordSize         uint32 // BytesPerFileRecordSegment
+	MftValidDataLength int64  // size of the MFT in bytes
+	MftStartLcn        int64  // cluster number of the first cluster of the MFT
+}
+
+// A Volume reads MFT records of a volume through the file system driver. It is safe for concurrent use when the
+// Device is.
+type Volume struct {
+	device Device
+	data   VolumeData
+}
+
+// NewVolume creates a Volume reading records using the device. It queries the volume data, which fails when the device
+// is not an NTFS volume.
+func NewVolume(device Device) (*Volume, error) {
+	out := make([]byte, volumeDataSize)
+	n, err := device.DeviceIoControl(FsctlGetNtfsVolumeData, nil, out)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get NTFS volume data: %v", err)
+	}
+	if n < volumeDataSize {
+		return nil, fmt.Errorf("expected %d bytes of NTFS volume data but got %d", volumeDataSize, n)
+	}
+	data := VolumeData{
+		BytesPerSector:     binary.LittleEndian.Uint32(out[0x28:]),
+		BytesPerCluster:    binary.LittleEndian.Uint32(out[0x2C:]),
+		RecordSize:         binary.LittleEndian.Uint32(out[0x30:]),
+		MftValidDataLength: int64(binary.LittleEndian.Uint64(out[0x38:])),
+		MftStartLcn:        int64(binary.LittleEndian.Uint64(out[0x40:])),
+	}
+	if data.RecordSize == 0 {
+		return nil, fmt.Errorf("invalid record size 0 in NTFS volume data")
+	}
+	return &Volume{device: device, data: data}, nil
+}
+
+// Data returns the details of the volume.
+func (v *Volume) Data() VolumeData {
+	return v.data
+}
+
+// RecordSize returns the size of a record in bytes.
+func (v *Volume) RecordSize() int {
+	return int(v.data.RecordSize)
+}
+
+// ReadRecord returns the data of a record, as it would be stored on disk. It returns ErrNotInUse when the record is
+// not in use.
+func (v *Volume) ReadRecord(number uint64) ([]byte, error) {
+	in := make([]byte, 8)
+	binary.LittleEndian.PutUint64(in, number)
+	out := make([]byte, fileRecordHeaderSize+v.data.RecordSize)
+	n, err := v.device.DeviceIoControl(FsctlGetNtfsFileRecord, in, out)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get record %d: %v", number, err)
+	}
+	if n < fileRecordHeaderSize {
+		return nil, fmt.Errorf("expected at least %d bytes of output for record %d but got %d", fileRecordHeaderSize, number, n)
+	}
+	returned := binary.LittleEndian.Uint64(out) & 0xFFFFFFFFFFFF
+	if returned != number {
+		return nil, ErrNotInUse
+	}
+	length := binary.LittleEndian.Uint32(out[0x08:])
+	if length != v.data.RecordSize || n < fileRecordHeaderSize+int(length) {
+		return nil, fmt.Errorf("expected %d bytes of data for record %d but got %d", v.data.RecordSize, number, length)
+	}
+	b := out[fileRecordHeaderSize : fileRecordHeaderSize+length]
+	restoreUpdateSequence(b)
+	return b, nil
+}
+
+// Record reads and parses a record. It returns ErrNotInUse when the record is not in use.
+func (v *Volume) Record(number uint64) (mft.Record, error) {
+	b, err := v.ReadRecord(number)
+	if err != nil {
+		return mft.Record{}, err
+	}
+	record, err := mft.ParseRecordWithOptions(b, mft.ParseOptions{ZeroCopy: true})
+	if err != nil {
+		return mft.Record{}, fmt.Errorf("unable to parse record %d: %v", number, err)
+	}
+	return record, nil
+}
+
+// ReadAt implements io.ReaderAt over the MFT, where off and len(p) must be multiples of the record size. Records
+// which are not in use are read as zeroes.
+func (v *Volume) ReadAt(p []byte, off int64) (int, error) {
+	size := int64(v.data.RecordSize)
+	if off < 0 || off%size != 0 || int64(len(p))%size != 0 {
+		return 0, fmt.Errorf("offset %d and length %d must be multiples of the record size %d", off, len(p), size)
+	}
+	n := 0
+	for n < len(p) {
+		if off+int64(n) >= v.data.MftValidDataLength {
+			return n, io.EOF
+		}
+		number := uint64(off+int64(n)) / uint64(size)
+		dst := p[n : n+int(size)]
+		b, err := v.ReadRecord(number)
+		if err == ErrNotInUse {
+			for i := range dst {
+				dst[i] = 0
+			}
+		} else if err != nil {
+			return n, err
+		} else {
+			copy(dst, b)
+		}
+		n += int(size)
+	}
+	return n, nil
+}
+
+// Close closes the device, when it implements io.Closer.
+func (v *Volume) Close() error {
+	if c, ok := v.device.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// restoreUpdateSequence puts the update sequence number back at the end of each sector of a record, where the
+// driver has replaced it with the original data (which is kept in the update sequence array). Sectors which do not
+// end with that original data are left alone.
+func restoreUpdateSequence(b []byte) {
+	if len(b) < 8 {
+		return
+	}
+	offset := int(binary.LittleEndian.Uint16(b[0x04:]))
+	count := int(binary.LittleEndian.Uint16(b[0x06:]))
+	if count < 2 || offset+2*count > len(b) {
+		return
+	}
+	usn := b[offset : offset+2]
+	sectorSize := len(b) / (count - 1)
+	for i := 1; i < count; i++ {
+		end := sectorSize*i - 2
+		original := b[offset+2*i : offset+2*i+2]
+		if b[end] == original[0] && b[end+1] == original[1] {
+			copy(b[end:end+2], usn)
+		}
+	}
+}
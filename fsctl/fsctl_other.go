@@ -0,0 +1,21 @@
+// +build !windows
+
+package fsctl
+
+import (
+	"errors"
+
+	"github.com/t9t/gomft/fragment"
+)
+
+// Open opens a volume, specified by its drive letter (such as "C:") or its device path (such as `\\.\C:`), for reading
+// records. It is only supported on Windows.
+func Open(volume string) (*Volume, error) {
+	return nil, errors.New("reading records through the file system driver is only supported on Windows")
+}
+
+// FileFragments returns the fragments of the (unnamed) data stream of the file at path on its volume. It is only
+// supported on Windows.
+func FileFragments(path string, bytesPerCluster int) ([]fragment.Fragment, error) {
+	return nil, errors.New("getting the extents of a file is only supported on Windows")
+}
@@ -0,0 +1,134 @@
+package fsctl_test
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/fsctl"
+)
+
+// fakeDevice answers FSCTL calls like the NTFS driver does for a volume with records 0 and 2 in use.
+type fakeDevice struct {
+	closed bool
+}
+
+func (d *fakeDevice) DeviceIoControl(code uint32, in []byte, out []byte) (int, error) {
+	switch code {
+	case fsctl.FsctlGetNtfsVolumeData:
+		binary.LittleEndian.PutUint32(out[0x28:], 512)
+		binary.LittleEndian.PutUint32(out[0x2C:], 4096)
+		binary.LittleEndian.PutUint32(out[0x30:], 1024)
+		binary.LittleEndian.PutUint64(out[0x38:], 4*1024)
+		binary.LittleEndian.PutUint64(out[0x40:], 786432)
+		return 0x60, nil
+	case fsctl.FsctlGetNtfsFileRecord:
+		number := binary.LittleEndian.Uint64(in)
+		if number >= 4 {
+			return 0, errors.New("the parameter is incorrect")
+		}
+		// The driver returns the nearest record in use at or before the requested one
+		if number == 1 || number == 3 {
+			number--
+		}
+		binary.LittleEndian.PutUint64(out, number)
+		binary.LittleEndian.PutUint32(out[0x08:], 1024)
+		copy(out[0x0C:], driverRecord(number))
+		return 0x0C + 1024, nil
+	}
+	return 0, errors.New("unsupported control code")
+}
+
+func (d *fakeDevice) Close() error {
+	d.closed = true
+	return nil
+}
+
+// driverRecord returns a minimal record as returned by the driver: the end of each sector contains the original data,
+// rather than the update sequence number (0x0001).
+func driverRecord(number uint64) []byte {
+	b := make([]byte, 1024)
+	copy(b, "FILE")
+	binary.LittleEndian.PutUint16(b[0x04:], 0x30) // update sequence offset
+	binary.LittleEndian.PutUint16(b[0x06:], 3)    // update sequence size
+	binary.LittleEndian.PutUint16(b[0x10:], 7)    // sequence number
+	binary.LittleEndian.PutUint16(b[0x14:], 0x38) // first attribute offset
+	binary.LittleEndian.PutUint16(b[0x16:], 1)    // in use
+	binary.LittleEndian.PutUint32(b[0x18:], 0x40) // actual size
+	binary.LittleEndian.PutUint32(b[0x1C:], 1024) // allocated size
+	binary.LittleEndian.PutUint32(b[0x2C:], uint32(number))
+	copy(b[0x30:], []byte{0x01, 0x00, 0xAB, 0xCD, 0x00, 0x00})
+	binary.LittleEndian.PutUint32(b[0x38:], 0xFFFFFFFF)
+	copy(b[510:], []byte{0xAB, 0xCD})
+	return b
+}
+
+func TestVolume(t *testing.T) {
+	device := &fakeDevice{}
+	v, err := fsctl.NewVolume(device)
+	require.Nilf(t, err, "unable to create volume: %v", err)
+	assert.Equal(t, 1024, v.RecordSize())
+	assert.Equal(t, fsctl.VolumeData{BytesPerSector: 512, BytesPerCluster: 4096, RecordSize: 1024, MftValidDataLength: 4096, MftStartLcn: 786432}, v.Data())
+
+	b, err := v.ReadRecord(2)
+	require.Nilf(t, err, "unable to read record: %v", err)
+	assert.Equal(t, []byte{0x01, 0x00}, b[510:512])
+	assert.Equal(t, []byte{0x01, 0x00}, b[1022:1024])
+
+	record, err := v.Record(2)
+	require.Nilf(t, err, "unable to parse record: %v", err)
+	assert.Equal(t, uint64(2), record.FileReference.RecordNumber)
+	assert.Equal(t, uint16(7), record.FileReference.SequenceNumber)
+
+	_, err = v.Record(3)
+	assert.Equal(t, fsctl.ErrNotInUse, err)
+	_, err = v.ReadRecord(4)
+	assert.EqualError(t, err, "unable to get record 4: the parameter is incorrect")
+
+	require.Nil(t, v.Close())
+	assert.True(t, device.closed)
+}
+
+func TestVolume_ReadAt(t *testing.T) {
+	v, err := fsctl.NewVolume(&fakeDevice{})
+	require.Nilf(t, err, "unable to create volume: %v", err)
+
+	p := make([]byte, 4*1024)
+	for i := range p {
+		p[i] = 0xFF
+	}
+	n, err := v.ReadAt(p[:3*1024], 1024)
+	require.Nil(t, err)
+	assert.Equal(t, 3*1024, n)
+	assert.Equal(t, make([]byte, 1024), p[:1024])
+	assert.Equal(t, "FILE", string(p[1024:1028]))
+	assert.Equal(t, make([]byte, 1024), p[2048:3072])
+
+	n, err = v.ReadAt(p, 2048)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, 2*1024, n)
+
+	_, err = v.ReadAt(p[:100], 0)
+	assert.EqualError(t, err, "offset 0 and length 100 must be multiples of the record size 1024")
+}
+
+func TestNewVolume_Invalid(t *testing.T) {
+	_, err := fsctl.NewVolume(deviceFunc(func(code uint32, in []byte, out []byte) (int, error) {
+		return 0, errors.New("incorrect function")
+	}))
+	assert.EqualError(t, err, "unable to get NTFS volume data: incorrect function")
+
+	_, err = fsctl.NewVolume(deviceFunc(func(code uint32, in []byte, out []byte) (int, error) {
+		return len(out), nil
+	}))
+	assert.EqualError(t, err, "invalid record size 0 in NTFS volume data")
+}
+
+type deviceFunc func(code uint32, in []byte, out []byte) (int, error)
+
+func (f deviceFunc) DeviceIoControl(code uint32, in []byte, out []byte) (int, error) {
+	return f(code, in, out)
+}
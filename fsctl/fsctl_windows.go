@@ -0,0 +1,95 @@
+package fsctl
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+
+	"github.com/t9t/gomft/fragment"
+)
+
+// fileReadAttributes is the FILE_READ_ATTRIBUTES access right, which is enough to get the extents of a file, even when
+// it is locked by another process.
+const fileReadAttributes = 0x80
+
+// Open opens a volume, specified by its drive letter (such as "C:") or its device path (such as `\\.\C:`), for reading
+// records.
+func Open(volume string) (*Volume, error) {
+	path := volume
+	if len(volume) == 2 && volume[1] == ':' {
+		path = `\\.\` + volume
+	}
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid volume %q: %v", volume, err)
+	}
+	h, err := syscall.CreateFile(p, syscall.GENERIC_READ, syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE, nil,
+		syscall.OPEN_EXISTING, 0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open %s: %v", path, os.NewSyscallError("CreateFile", err))
+	}
+	v, err := NewVolume(handle(h))
+	if err != nil {
+		syscall.CloseHandle(h)
+		return nil, err
+	}
+	return v, nil
+}
+
+// handle is a Device for a volume handle.
+type handle syscall.Handle
+
+func (h handle) DeviceIoControl(code uint32, in []byte, out []byte) (int, error) {
+	var inPtr, outPtr *byte
+	if len(in) > 0 {
+		inPtr = &in[0]
+	}
+	if len(out) > 0 {
+		outPtr = &out[0]
+	}
+	var returned uint32
+	err := syscall.DeviceIoControl(syscall.Handle(h), code, inPtr, uint32(len(in)), outPtr, uint32(len(out)), &returned, nil)
+	switch err {
+	case nil:
+		return int(returned), nil
+	case syscall.ERROR_MORE_DATA:
+		return int(returned), ErrMoreData
+	case syscall.ERROR_HANDLE_EOF:
+		return int(returned), io.EOF
+	}
+	return int(returned), os.NewSyscallError("DeviceIoControl", err)
+}
+
+func (h handle) Close() error {
+	return syscall.CloseHandle(syscall.Handle(h))
+}
+
+// FileFragments returns the fragments of the (unnamed) data stream of the file at path on its volume, limited to the
+// size of the file. Reading them from the volume gives the contents of the file, also when it is locked by another
+// process, such as registry hives, event logs and the $MFT itself. The bytesPerCluster must be that of the volume,
+// for example from the Data of a Volume.
+func FileFragments(path string, bytesPerCluster int) ([]fragment.Fragment, error) {
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path %q: %v", path, err)
+	}
+	h, err := syscall.CreateFile(p, fileReadAttributes,
+		syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE|syscall.FILE_SHARE_DELETE, nil, syscall.OPEN_EXISTING,
+		syscall.FILE_FLAG_BACKUP_SEMANTICS, 0)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open %s: %v", path, os.NewSyscallError("CreateFile", err))
+	}
+	defer syscall.CloseHandle(h)
+
+	var info syscall.ByHandleFileInformation
+	if err := syscall.GetFileInformationByHandle(h, &info); err != nil {
+		return nil, fmt.Errorf("unable to get size of %s: %v", path, os.NewSyscallError("GetFileInformationByHandle", err))
+	}
+	size := int64(info.FileSizeHigh)<<32 | int64(info.FileSizeLow)
+	extents, err := RetrievalPointers(handle(h))
+	if err != nil {
+		return nil, fmt.Errorf("unable to get extents of %s: %v", path, err)
+	}
+	return ExtentsToFragments(extents, bytesPerCluster, size)
+}
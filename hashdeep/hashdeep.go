@@ -0,0 +1,217 @@
+/*
+	Package hashdeep writes manifests with the size, hashes and path of files in the formats of hashdeep and md5deep, so
+	files walked or extracted using gomft can be verified using "hashdeep -a" or looked up in hash sets such as the
+	NSRL.
+
+	Basic usage
+
+	Create a Writer and add the Stage to a pipeline, after the Paths stage, to hash all files while walking the MFT. To
+	hash the files extracted into an archive instead, set the Manifest of archive.Options.
+			// Error handling left out for brevity
+			w, err := hashdeep.NewWriter(os.Stdout, hashdeep.FormatHashdeep)
+			opts := hashdeep.Options{Volume: volume, BytesPerCluster: 4096}
+			stats, err := pipeline.Run(in, pipeline.Options{},
+				pipeline.Paths(pipeline.NewPathResolver(in, 1024, 0)),
+				hashdeep.Stage(w, opts))
+			err = w.Flush()
+
+	Implementation notes
+
+	The hashdeep format starts with a header naming the columns, followed by one line per file with the size, the hashes
+	and the path, separated by commas (such as "5,<md5>,<sha256>,/Users/bob/file.txt"). By default, the MD5 and SHA-256
+	hashes are computed, like hashdeep does. The md5deep format (as written by md5deep, sha1deep and sha256deep) has a
+	single hash and the path on each line, separated by two spaces, without a header.
+
+	Like the archive package, the Stage reads resident data straight from the record and non-resident data from the
+	Volume using the data runs of the attribute, with sparse runs read as zeroes and compressed streams decompressed.
+	Only the attributes in the record itself are considered. Streams which cannot be read are passed to the
+	ErrorHandler and left out of the manifest.
+	Alternate data streams are only hashed when Streams is set, using the path of the file, a colon and the name of the
+	stream as path.
+*/
+package hashdeep
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/pipeline"
+	"github.com/t9t/gomft/volume"
+)
+
+// Format indicates the format of a manifest.
+type Format int
+
+const (
+	FormatHashdeep Format = iota // the format of hashdeep, with the size and one or more hashes of each file
+	FormatMD5Deep                // the format of md5deep, with a single hash of each file
+)
+
+// algorithms contains the hash algorithms which can be used, by their name in hashdeep.
+var algorithms = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+}
+
+// A Writer writes a manifest. It is not safe for concurrent use.
+type Writer struct {
+	w             *bufio.Writer
+	format        Format
+	algorithms    []string
+	headerWritten bool
+}
+
+// NewWriter creates a Writer writing a manifest in the format to w, with the hashes of the algorithms: md5, sha1 or
+// sha256. When no algorithms are specified, md5 and sha256 are used for FormatHashdeep and md5 for FormatMD5Deep.
+// FormatMD5Deep supports only a single algorithm.
+func NewWriter(w io.Writer, format Format, names ...string) (*Writer, error) {
+	if len(names) == 0 {
+		names = []string{"md5", "sha256"}
+		if format == FormatMD5Deep {
+			names = []string{"md5"}
+		}
+	}
+	if format == FormatMD5Deep && len(names) != 1 {
+		return nil, fmt.Errorf("the md5deep format supports a single hash algorithm, but got %d", len(names))
+	}
+	for _, a := range names {
+		if _, ok := algorithms[a]; !ok {
+			return nil, fmt.Errorf("unsupported hash algorithm %q", a)
+		}
+	}
+	return &Writer{w: bufio.NewWriter(w), format: format, algorithms: names}, nil
+}
+
+// A Hasher computes the hashes of a single file written to it, and counts its size.
+type Hasher struct {
+	hashes []hash.Hash
+	size   int64
+}
+
+// NewHasher creates a Hasher computing the hashes of the Writer.
+func (w *Writer) NewHasher() *Hasher {
+	h := &Hasher{hashes: make([]hash.Hash, len(w.algorithms))}
+	for i, a := range w.algorithms {
+		h.hashes[i] = algorithms[a]()
+	}
+	return h
+}
+
+// Write adds p to the hashes. It never returns an error.
+func (h *Hasher) Write(p []byte) (int, error) {
+	for _, hh := range h.hashes {
+		hh.Write(p)
+	}
+	h.size += int64(len(p))
+	return len(p), nil
+}
+
+// Size returns the number of bytes written.
+func (h *Hasher) Size() int64 {
+	return h.size
+}
+
+// Add reads r until EOF and writes the size and hashes of its contents to the manifest.
+func (w *Writer) Add(path string, r io.Reader) error {
+	h := w.NewHasher()
+	if _, err := io.Copy(h, r); err != nil {
+		return fmt.Errorf("unable to read contents of %s: %v", path, err)
+	}
+	return w.WriteHashes(path, h)
+}
+
+// WriteHashes writes the size and hashes of the data written to the Hasher to the manifest. The Hasher must have been
+// created by this Writer.
+func (w *Writer) WriteHashes(path string, h *Hasher) error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	if w.format == FormatMD5Deep {
+		_, err := w.w.WriteString(hex.EncodeToString(h.hashes[0].Sum(nil)) + "  " + path + "\n")
+		return err
+	}
+	fields := make([]string, 0, len(h.hashes)+2)
+	fields = append(fields, strconv.FormatInt(h.size, 10))
+	for _, hh := range h.hashes {
+		fields = append(fields, hex.EncodeToString(hh.Sum(nil)))
+	}
+	fields = append(fields, path)
+	_, err := w.w.WriteString(strings.Join(fields, ",") + "\n")
+	return err
+}
+
+// writeHeader writes the header of the hashdeep format, if that has not been done yet.
+func (w *Writer) writeHeader() error {
+	if w.headerWritten || w.format != FormatHashdeep {
+		return nil
+	}
+	w.headerWritten = true
+	_, err := w.w.WriteString("%%%% HASHDEEP-1.0\n%%%% size," + strings.Join(w.algorithms, ",") + ",filename\n## \n")
+	return err
+}
+
+// Flush writes any buffered data to the underlying io.Writer. The header of the hashdeep format is written even when
+// the manifest is empty.
+func (w *Writer) Flush() error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	return w.w.Flush()
+}
+
+// Options configures the streams hashed by a Stage.
+type Options struct {
+	// Volume, when not nil, is the volume which the MFT belongs to. It is needed to hash non-resident data.
+	Volume io.ReaderAt
+	// BytesPerCluster is the cluster size of the Volume.
+	BytesPerCluster int
+	// Streams hashes the alternate data streams of files as well, besides the unnamed stream.
+	Streams bool
+	// ErrorHandler, when not nil, is called for each stream that could not be read and was therefore left out of the
+	// manifest, including streams that are non-resident while there is no Volume.
+	ErrorHandler func(item *pipeline.Item, stream string, err error)
+}
+
+// Stage creates a pipeline.Stage that writes the size and hashes of the $DATA attributes of each Item to the manifest.
+// It never drops Items; an error writing the manifest stops the pipeline.
+func Stage(w *Writer, opts Options) pipeline.Stage {
+	return pipeline.StageFunc(func(item *pipeline.Item) (bool, error) {
+		for _, a := range item.Record.FindAttributes(mft.AttributeTypeData) {
+			if a.Name != "" && !opts.Streams {
+				continue
+			}
+			r, err := volume.NewAttributeReader(opts.Volume, opts.BytesPerCluster, a)
+			if err != nil {
+				if opts.ErrorHandler != nil {
+					opts.ErrorHandler(item, a.Name, err)
+				}
+				continue
+			}
+			h := w.NewHasher()
+			if _, err := io.Copy(h, r); err != nil {
+				if opts.ErrorHandler != nil {
+					opts.ErrorHandler(item, a.Name, fmt.Errorf("unable to read stream: %v", err))
+				}
+				continue
+			}
+			path := item.Path
+			if a.Name != "" {
+				path += ":" + a.Name
+			}
+			if err := w.WriteHashes(path, h); err != nil {
+				return false, fmt.Errorf("unable to write manifest: %v", err)
+			}
+		}
+		return true, nil
+	})
+}
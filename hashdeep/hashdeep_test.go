@@ -0,0 +1,112 @@
+package hashdeep_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/hashdeep"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/pipeline"
+)
+
+func TestWriter_Hashdeep(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w, err := hashdeep.NewWriter(buf, hashdeep.FormatHashdeep)
+	require.Nilf(t, err, "unable to create writer: %v", err)
+	require.Nil(t, w.Add("/Users/bob/hello, world.txt", strings.NewReader("hello")))
+	require.Nil(t, w.Flush())
+	assert.Equal(t, "%%%% HASHDEEP-1.0\n%%%% size,md5,sha256,filename\n## \n"+
+		"5,5d41402abc4b2a76b9719d911017c592,2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824,/Users/bob/hello, world.txt\n",
+		buf.String())
+}
+
+func TestWriter_HashdeepEmpty(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w, err := hashdeep.NewWriter(buf, hashdeep.FormatHashdeep, "sha1")
+	require.Nil(t, err)
+	require.Nil(t, w.Flush())
+	assert.Equal(t, "%%%% HASHDEEP-1.0\n%%%% size,sha1,filename\n## \n", buf.String())
+}
+
+func TestWriter_MD5Deep(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w, err := hashdeep.NewWriter(buf, hashdeep.FormatMD5Deep, "sha1")
+	require.Nil(t, err)
+	h := w.NewHasher()
+	h.Write([]byte("hel"))
+	h.Write([]byte("lo"))
+	assert.Equal(t, int64(5), h.Size())
+	require.Nil(t, w.WriteHashes("/hello.txt", h))
+	require.Nil(t, w.Flush())
+	assert.Equal(t, "aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d  /hello.txt\n", buf.String())
+}
+
+func TestNewWriter_Invalid(t *testing.T) {
+	_, err := hashdeep.NewWriter(&bytes.Buffer{}, hashdeep.FormatHashdeep, "md5", "crc32")
+	assert.EqualError(t, err, `unsupported hash algorithm "crc32"`)
+	_, err = hashdeep.NewWriter(&bytes.Buffer{}, hashdeep.FormatMD5Deep, "md5", "sha1")
+	assert.EqualError(t, err, "the md5deep format supports a single hash algorithm, but got 2")
+}
+
+func TestStage(t *testing.T) {
+	volume := make([]byte, 4*512)
+	copy(volume[2*512:], "non-resident data")
+	item := &pipeline.Item{
+		Path: "/Users/bob/setup.exe",
+		Record: mft.Record{Attributes: []mft.Attribute{
+			{Type: mft.AttributeTypeData, Resident: false, Data: []byte{0x11, 0x01, 0x02, 0x00}, ActualSize: 17},
+			{Type: mft.AttributeTypeData, Name: "Zone.Identifier", Resident: true, Data: []byte("[ZoneTransfer]")},
+			// A sparse cluster, which reads as zeroes
+			{Type: mft.AttributeTypeData, Name: "sparse", Resident: false, Flags: mft.AttributeFlagsSparse, Data: []byte{0x01, 0x01, 0x00}, ActualSize: 10},
+		}},
+	}
+	buf := &bytes.Buffer{}
+	w, err := hashdeep.NewWriter(buf, hashdeep.FormatMD5Deep)
+	require.Nil(t, err)
+	var errs []string
+	opts := hashdeep.Options{Volume: bytes.NewReader(volume), BytesPerCluster: 512, Streams: true,
+		ErrorHandler: func(item *pipeline.Item, stream string, err error) {
+			errs = append(errs, stream+": "+err.Error())
+		}}
+	keep, err := hashdeep.Stage(w, opts).Process(item)
+	require.Nilf(t, err, "unable to process item: %v", err)
+	assert.True(t, keep)
+	require.Nil(t, w.Flush())
+	assert.Equal(t, "33576b839820dbc596c29cc817fd032a  /Users/bob/setup.exe\n"+
+		"725027fcb8bf9d7d80edae0e9e9139a4  /Users/bob/setup.exe:Zone.Identifier\n"+
+		"a63c90cc3684ad8b0a2176a6a8fe9005  /Users/bob/setup.exe:sparse\n", buf.String())
+	assert.Empty(t, errs)
+
+	// Without Streams, only the unnamed stream is hashed, and without a Volume it cannot be read
+	errs = nil
+	buf.Reset()
+	keep, err = hashdeep.Stage(w, hashdeep.Options{ErrorHandler: opts.ErrorHandler}).Process(item)
+	require.Nil(t, err)
+	assert.True(t, keep)
+	require.Nil(t, w.Flush())
+	assert.Equal(t, "", buf.String())
+	assert.Equal(t, []string{": the attribute is non-resident, but no volume is available"}, errs)
+}
+
+func TestStage_WriteError(t *testing.T) {
+	item := &pipeline.Item{Path: "/file", Record: mft.Record{Attributes: []mft.Attribute{
+		{Type: mft.AttributeTypeData, Resident: true, Data: []byte("data")},
+	}}}
+	w, err := hashdeep.NewWriter(failingWriter{}, hashdeep.FormatMD5Deep)
+	require.Nil(t, err)
+	for i := 0; i < 1000 && err == nil; i++ {
+		// the output is buffered, so writing only fails once the buffer is full
+		_, err = hashdeep.Stage(w, hashdeep.Options{}).Process(item)
+	}
+	assert.EqualError(t, err, "unable to write manifest: disk full")
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
@@ -0,0 +1,305 @@
+/*
+	Package httprange reads a remote file, such as an image of a volume stored in S3 or Azure Blob Storage, over HTTP(S)
+	using Range requests, so it can be analysed without downloading it first.
+
+	Basic usage
+
+	Open the URL of the image, then use the ReaderAt like an image file.
+			// Error handling left out for brevity
+			r, err := httprange.Open("https://example.com/images/sdb1.img", httprange.Options{})
+			v, err := volume.Open(r, volume.Options{})
+			record, err := v.ReadRecord(mft.RootRecordNumber)
+
+	Use the Header of the Options for authentication, such as an Authorization header, or use a pre-signed URL.
+
+	Implementation notes
+
+	The file is read in blocks of BlockSize bytes, each fetched using a GET request with a Range header. The most
+	recently used CacheBlocks blocks are kept in memory, so reading records one by one does not cause a request for
+	each record. Concurrent reads of a block which is not cached yet wait for a single request.
+
+	Open requests the first byte of the file to learn its size (from the Content-Range header) and whether the server
+	supports Range requests at all. A GET request is used rather than a HEAD request, since pre-signed URLs are
+	typically only valid for a single method. When the server returns a strong ETag, each block is requested with an
+	If-Match header, so a file which is replaced while it is being read results in ErrChanged instead of a mix of old
+	and new data.
+
+	Failed requests are not retried; wrap the ReaderAt using the retry package for that. Blocks which could not be
+	fetched are not cached, so a retry makes a new request.
+*/
+package httprange
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/t9t/gomft/metrics"
+)
+
+// Defaults of the Options.
+const (
+	DefaultBlockSize   = 1024 * 1024
+	DefaultCacheBlocks = 64
+)
+
+// Errors returned when reading.
+var (
+	ErrRangesUnsupported = errors.New("server does not support range requests")
+	ErrChanged           = errors.New("remote file changed while reading")
+)
+
+// Options configures a ReaderAt. Zero values are replaced by their defaults.
+type Options struct {
+	// Client is used to make the requests; http.DefaultClient when nil.
+	Client *http.Client
+	// Header contains headers added to each request, for example for authentication.
+	Header http.Header
+	// BlockSize is the number of bytes fetched with a single request.
+	BlockSize int
+	// CacheBlocks is the number of blocks kept in memory.
+	CacheBlocks int
+	// Metrics, when not nil, receives the number of blocks read from the cache (metrics.CacheHits) and fetched from
+	// the server (metrics.CacheMisses), and the number of blocks in the cache (metrics.CachedBlocks).
+	Metrics metrics.Recorder
+}
+
+// ReaderAt reads a remote file using Range requests. It is safe for concurrent use.
+type ReaderAt struct {
+	url  string
+	opts Options
+	size int64
+	etag string
+
+	mu      sync.Mutex
+	cache   map[int64]*list.Element
+	lru     *list.List
+	pending map[int64]*fetch
+}
+
+type block struct {
+	index int64
+	data  []byte
+}
+
+// fetch is a request for a block which is in progress; done is closed when it is finished.
+type fetch struct {
+	done chan struct{}
+	data []byte
+	err  error
+}
+
+// Open determines the size of the remote file at the URL and returns a ReaderAt to read it.
+func Open(url string, opts Options) (*ReaderAt, error) {
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	if opts.BlockSize <= 0 {
+		opts.BlockSize = DefaultBlockSize
+	}
+	if opts.CacheBlocks <= 0 {
+		opts.CacheBlocks = DefaultCacheBlocks
+	}
+	r := &ReaderAt{
+		url:     url,
+		opts:    opts,
+		cache:   make(map[int64]*list.Element),
+		lru:     list.New(),
+		pending: make(map[int64]*fetch),
+	}
+
+	resp, err := r.get("bytes=0-0", "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusPartialContent, http.StatusRequestedRangeNotSatisfiable:
+		// An empty file has no first byte, but a Content-Range with its size nonetheless
+	case http.StatusOK:
+		if resp.ContentLength == 0 {
+			// Some servers ignore the range of an empty file
+			return r, nil
+		}
+		return nil, ErrRangesUnsupported
+	default:
+		return nil, fmt.Errorf("unexpected status %q for %s", resp.Status, url)
+	}
+	_, _, size, err := parseContentRange(resp.Header.Get("Content-Range"))
+	if err != nil {
+		return nil, err
+	}
+	r.size = size
+	if etag := resp.Header.Get("ETag"); !strings.HasPrefix(etag, "W/") {
+		r.etag = etag
+	}
+	return r, nil
+}
+
+// Size returns the size of the remote file in bytes.
+func (r *ReaderAt) Size() int64 {
+	return r.size
+}
+
+// ReadAt reads len(p) bytes starting at position off of the remote file. When fewer than len(p) bytes are available,
+// it returns the number of bytes read and io.EOF.
+func (r *ReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset %d", off)
+	}
+	total := 0
+	blockSize := int64(r.opts.BlockSize)
+	for total < len(p) && off < r.size {
+		data, err := r.block(off / blockSize)
+		if err != nil {
+			return total, err
+		}
+		n := copy(p[total:], data[off%blockSize:])
+		total += n
+		off += int64(n)
+	}
+	if total < len(p) {
+		return total, io.EOF
+	}
+	return total, nil
+}
+
+// block returns the data of the block with the index, from the cache or from the server.
+func (r *ReaderAt) block(index int64) ([]byte, error) {
+	r.mu.Lock()
+	if elem, ok := r.cache[index]; ok {
+		r.lru.MoveToFront(elem)
+		r.mu.Unlock()
+		r.record(metrics.CacheHits)
+		return elem.Value.(*block).data, nil
+	}
+	if f, ok := r.pending[index]; ok {
+		r.mu.Unlock()
+		r.record(metrics.CacheHits)
+		<-f.done
+		return f.data, f.err
+	}
+	f := &fetch{done: make(chan struct{})}
+	r.pending[index] = f
+	r.mu.Unlock()
+	r.record(metrics.CacheMisses)
+
+	f.data, f.err = r.fetch(index)
+
+	r.mu.Lock()
+	delete(r.pending, index)
+	if f.err == nil {
+		if r.lru.Len() >= r.opts.CacheBlocks {
+			oldest := r.lru.Back()
+			r.lru.Remove(oldest)
+			delete(r.cache, oldest.Value.(*block).index)
+		}
+		r.cache[index] = r.lru.PushFront(&block{index: index, data: f.data})
+	}
+	cached := r.lru.Len()
+	r.mu.Unlock()
+	if r.opts.Metrics != nil {
+		metrics.SetGauge(r.opts.Metrics, metrics.CachedBlocks, int64(cached))
+	}
+	close(f.done)
+	return f.data, f.err
+}
+
+// record adds one to the counter with the name when metrics are recorded.
+func (r *ReaderAt) record(name string) {
+	if r.opts.Metrics != nil {
+		r.opts.Metrics.Add(name, 1)
+	}
+}
+
+// fetch requests the data of the block with the index from the server.
+func (r *ReaderAt) fetch(index int64) ([]byte, error) {
+	start := index * int64(r.opts.BlockSize)
+	end := start + int64(r.opts.BlockSize) - 1
+	if end >= r.size {
+		end = r.size - 1
+	}
+	resp, err := r.get(fmt.Sprintf("bytes=%d-%d", start, end), r.etag)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusPreconditionFailed:
+		return nil, ErrChanged
+	case http.StatusOK:
+		return nil, ErrRangesUnsupported
+	default:
+		return nil, fmt.Errorf("unexpected status %q for range %d-%d", resp.Status, start, end)
+	}
+	first, last, size, err := parseContentRange(resp.Header.Get("Content-Range"))
+	if err != nil {
+		return nil, err
+	}
+	if size != r.size {
+		return nil, ErrChanged
+	}
+	if first != start || last != end {
+		return nil, fmt.Errorf("requested range %d-%d but got %d-%d", start, end, first, last)
+	}
+	data := make([]byte, end-start+1)
+	if _, err := io.ReadFull(resp.Body, data); err != nil {
+		return nil, fmt.Errorf("unable to read range %d-%d: %v", start, end, err)
+	}
+	return data, nil
+}
+
+func (r *ReaderAt) get(byteRange string, etag string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, r.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range r.opts.Header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Range", byteRange)
+	if etag != "" {
+		req.Header.Set("If-Match", etag)
+	}
+	return r.opts.Client.Do(req)
+}
+
+// parseContentRange parses a Content-Range header such as "bytes 0-1023/4096", or "bytes */4096" which has no range.
+func parseContentRange(s string) (int64, int64, int64, error) {
+	invalid := fmt.Errorf("invalid Content-Range %q", s)
+	if !strings.HasPrefix(s, "bytes ") {
+		return 0, 0, 0, invalid
+	}
+	slash := strings.LastIndexByte(s, '/')
+	if slash < 0 {
+		return 0, 0, 0, invalid
+	}
+	size, err := strconv.ParseInt(s[slash+1:], 10, 64)
+	if err != nil || size < 0 {
+		// The size is "*" when the server does not know it
+		return 0, 0, 0, invalid
+	}
+	byteRange := s[len("bytes "):slash]
+	if byteRange == "*" {
+		return 0, -1, size, nil
+	}
+	dash := strings.IndexByte(byteRange, '-')
+	if dash < 0 {
+		return 0, 0, 0, invalid
+	}
+	first, err := strconv.ParseInt(byteRange[:dash], 10, 64)
+	if err != nil {
+		return 0, 0, 0, invalid
+	}
+	last, err := strconv.ParseInt(byteRange[dash+1:], 10, 64)
+	if err != nil || last < first {
+		return 0, 0, 0, invalid
+	}
+	return first, last, size, nil
+}
@@ -0,0 +1,170 @@
+package httprange_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/httprange"
+	"github.com/t9t/gomft/metrics"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/mfttest"
+	"github.com/t9t/gomft/volume"
+)
+
+// server serves the data with support for Range requests, counting the requests.
+type server struct {
+	data     []byte
+	etag     string
+	requests int32
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	atomic.AddInt32(&s.requests, 1)
+	if s.etag != "" {
+		w.Header().Set("ETag", s.etag)
+	}
+	http.ServeContent(w, req, "image", time.Time{}, bytes.NewReader(s.data))
+}
+
+func TestReaderAt(t *testing.T) {
+	data := make([]byte, 10000)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	s := &server{data: data, etag: `"v1"`}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	c := &metrics.Counters{}
+	r, err := httprange.Open(ts.URL, httprange.Options{BlockSize: 1000, CacheBlocks: 2, Metrics: c})
+	require.Nil(t, err)
+	assert.Equal(t, int64(10000), r.Size())
+
+	buf := make([]byte, 1500)
+	n, err := r.ReadAt(buf, 900)
+	require.Nil(t, err)
+	assert.Equal(t, 1500, n)
+	assert.Equal(t, data[900:2400], buf)
+	assert.Equal(t, int32(1+3), atomic.LoadInt32(&s.requests))
+
+	// Blocks 1 and 2 are cached, block 0 was evicted
+	_, err = r.ReadAt(buf[:100], 1500)
+	require.Nil(t, err)
+	assert.Equal(t, int32(4), atomic.LoadInt32(&s.requests))
+	_, err = r.ReadAt(buf[:100], 0)
+	require.Nil(t, err)
+	assert.Equal(t, int32(5), atomic.LoadInt32(&s.requests))
+	assert.Equal(t, int64(1), c.Get(metrics.CacheHits))
+	assert.Equal(t, int64(4), c.Get(metrics.CacheMisses))
+	assert.Equal(t, int64(2), c.Get(metrics.CachedBlocks))
+
+	n, err = r.ReadAt(buf, 9000)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, 1000, n)
+	assert.Equal(t, data[9000:], buf[:n])
+
+	n, err = r.ReadAt(buf, 20000)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, 0, n)
+}
+
+func TestReaderAt_Concurrent(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+	s := &server{data: data}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	r, err := httprange.Open(ts.URL, httprange.Options{BlockSize: 4096})
+	require.Nil(t, err)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			buf := make([]byte, 10)
+			_, err := r.ReadAt(buf, int64(i*10))
+			assert.Nil(t, err)
+			assert.Equal(t, "0123456789", string(buf))
+		}(i)
+	}
+	wg.Wait()
+
+	// One request to open and one for the first block, which was fetched only once
+	assert.Equal(t, int32(2), atomic.LoadInt32(&s.requests))
+}
+
+func TestReaderAt_Volume(t *testing.T) {
+	v := mfttest.NewVolume().WithFile("/dir/a.txt", []byte("hello")).Build()
+	ts := httptest.NewServer(&server{data: v.Data})
+	defer ts.Close()
+
+	r, err := httprange.Open(ts.URL, httprange.Options{BlockSize: 4096})
+	require.Nil(t, err)
+	vol, err := volume.Open(r, volume.Options{})
+	require.Nil(t, err)
+	record, err := vol.ReadRecord(v.Records["/dir/a.txt"])
+	require.Nil(t, err)
+	data, ok := record.FindFirstAttribute(mft.AttributeTypeData)
+	require.True(t, ok)
+	assert.Equal(t, "hello", string(data.Data))
+}
+
+func TestReaderAt_Changed(t *testing.T) {
+	s := &server{data: make([]byte, 100), etag: `"v1"`}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	r, err := httprange.Open(ts.URL, httprange.Options{BlockSize: 10})
+	require.Nil(t, err)
+	s.etag = `"v2"`
+	_, err = r.ReadAt(make([]byte, 10), 0)
+	assert.Equal(t, httprange.ErrChanged, err)
+}
+
+func TestOpen_Errors(t *testing.T) {
+	noRanges := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("no ranges here"))
+	}))
+	defer noRanges.Close()
+	_, err := httprange.Open(noRanges.URL, httprange.Options{})
+	assert.Equal(t, httprange.ErrRangesUnsupported, err)
+
+	notFound := httptest.NewServer(http.NotFoundHandler())
+	defer notFound.Close()
+	_, err = httprange.Open(notFound.URL, httprange.Options{})
+	assert.EqualError(t, err, fmt.Sprintf("unexpected status %q for %s", "404 Not Found", notFound.URL))
+
+	auth := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		http.ServeContent(w, req, "image", time.Time{}, bytes.NewReader([]byte("data")))
+	}))
+	defer auth.Close()
+	_, err = httprange.Open(auth.URL, httprange.Options{})
+	assert.NotNil(t, err)
+	r, err := httprange.Open(auth.URL, httprange.Options{Header: http.Header{"Authorization": {"Bearer secret"}}})
+	require.Nil(t, err)
+	assert.Equal(t, int64(4), r.Size())
+}
+
+func TestOpen_Empty(t *testing.T) {
+	ts := httptest.NewServer(&server{data: []byte{}})
+	defer ts.Close()
+	r, err := httprange.Open(ts.URL, httprange.Options{})
+	require.Nil(t, err)
+	assert.Equal(t, int64(0), r.Size())
+	n, err := r.ReadAt(make([]byte, 1), 0)
+	assert.Equal(t, 0, n)
+	assert.Equal(t, io.EOF, err)
+}
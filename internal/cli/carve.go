@@ -0,0 +1,122 @@
+package cli
+
+import (
+	"flag"
+	"io"
+	"math"
+	"time"
+
+	"github.com/t9t/gomft/carve"
+	"github.com/t9t/gomft/export"
+)
+
+type carveFlags struct {
+	output     outputFlags
+	recordSize int
+	indexSize  int
+	alignment  int
+	noRecords  bool
+	noIndex    bool
+	mmap       bool
+}
+
+func init() {
+	flags := &carveFlags{}
+	register(&command{
+		name:    "carve",
+		args:    "<image>",
+		summary: "Carve orphaned MFT records and index entries from raw data",
+		description: "Scan a raw image, volume or file containing unallocated space for orphaned MFT records and index\n" +
+			"entries (including those in index slack space) and write the recovered metadata as CSV or JSON.",
+		example: func(exe string) string {
+			if isWin {
+				return exe + ` -v -o D:\carved.csv D:\unallocated.bin`
+			}
+			return exe + " -v -o ~/carved.csv ~/unallocated.bin"
+		},
+		flags: func(env *env, fs *flag.FlagSet) {
+			flags.output.register(fs)
+			fs.IntVar(&flags.recordSize, "r", 1024, "record size; size of an MFT record in bytes")
+			fs.IntVar(&flags.indexSize, "i", 4096, "index size; size of an index block (INDX record) in bytes")
+			fs.IntVar(&flags.alignment, "a", 512, "alignment; only look for records at multiples of this many bytes")
+			fs.BoolVar(&flags.noRecords, "no-records", false, "don't carve MFT records")
+			fs.BoolVar(&flags.noIndex, "no-index", false, "don't carve index entries")
+			fs.BoolVar(&flags.mmap, "mmap", false, "memory map; map the input into memory instead of reading it, which is faster for large images")
+		},
+		run: func(env *env, fs *flag.FlagSet) error {
+			return runCarve(env, flags, fs.Args())
+		},
+	})
+}
+
+func runCarve(env *env, flags *carveFlags, args []string) error {
+	start := time.Now()
+	if flags.output.schema {
+		return flags.output.writeSchema(env)
+	}
+	if len(args) != 1 {
+		return fail(exitCodeUserError, "Expected 1 argument but got %d", len(args))
+	}
+
+	var in io.Reader
+	if flags.mmap {
+		m, err := openMapped(env, args[0])
+		if err != nil {
+			return err
+		}
+		defer m.Close()
+		in = m.Reader()
+	} else {
+		f, err := openInput(env, args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = io.NewSectionReader(f, 0, math.MaxInt64)
+	}
+
+	w, finish, err := flags.output.open(env, args[0], nil)
+	if err != nil {
+		return err
+	}
+
+	opts := carve.Options{
+		RecordSize:     flags.recordSize,
+		IndexBlockSize: flags.indexSize,
+		Alignment:      flags.alignment,
+		Records:        !flags.noRecords,
+		IndexEntries:   !flags.noIndex,
+	}
+
+	env.printVerbose("Carving (record size: %d, index block size: %d, alignment: %d)\n", opts.RecordSize, opts.IndexBlockSize, opts.Alignment)
+	records := 0
+	entries := 0
+	s := carve.NewScanner(in, opts)
+	for s.Scan() {
+		item := s.Item()
+		var e export.Entry
+		if item.Source == carve.SourceRecord {
+			e = export.FromRecord(item.Record)
+			records++
+		} else {
+			e = export.FromIndexEntry(item.IndexEntry)
+			entries++
+		}
+		e.Source = item.Source.String()
+		e.Offset = item.Offset
+		if err := w.Write(e); err != nil {
+			finish()
+			return fail(exitCodeTechnicalError, "Unable to write output: %v", err)
+		}
+	}
+	if err := s.Err(); err != nil {
+		finish()
+		return fail(exitCodeTechnicalError, "Error carving: %v", err)
+	}
+	if err := finish(); err != nil {
+		return err
+	}
+
+	env.printVerbose("Found %d records and %d index entries in %v\n", records, entries, time.Since(start))
+	return nil
+}
@@ -0,0 +1,230 @@
+/*
+	Package cli implements the gomft command line utilities. All utilities are subcommands of the gomft binary (eg.
+	"gomft dump"), sharing the same flag handling, volume opening, output formatting and logging. The standalone
+	binaries (such as mftdump) run a single subcommand directly.
+*/
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/t9t/gomft/metrics"
+	"github.com/t9t/gomft/mft"
+)
+
+const (
+	exitCodeUserError int = iota + 2
+	exitCodeFunctionalError
+	exitCodeTechnicalError
+)
+
+const isWin = runtime.GOOS == "windows"
+
+// A command is a single subcommand of the gomft binary.
+type command struct {
+	name        string
+	args        string // positional arguments as shown in the usage, eg. "<volume> <output file>"
+	summary     string // a single line description, shown in the list of commands
+	description string
+	example     func(exe string) string
+	run         func(env *env, fs *flag.FlagSet) error
+	flags       func(env *env, fs *flag.FlagSet)
+}
+
+var commands = map[string]*command{}
+
+func register(c *command) {
+	commands[c.name] = c
+}
+
+// env holds the state shared by all commands, such as the output streams and the common flags.
+type env struct {
+	exe      string
+	stdout   io.Writer
+	stderr   io.Writer
+	verbose  bool
+	stats    bool
+	counters *metrics.Counters
+}
+
+// exitError is returned by commands to exit with a specific exit code after printing a message.
+type exitError struct {
+	code int
+	msg  string
+}
+
+func (e *exitError) Error() string {
+	return e.msg
+}
+
+func fail(code int, format string, v ...interface{}) error {
+	return &exitError{code: code, msg: fmt.Sprintf(format, v...)}
+}
+
+// Main runs the gomft binary with the specified arguments (excluding the program name), where the first argument is
+// the name of the subcommand. It returns the exit code.
+func Main(args []string) int {
+	exe := filepath.Base(os.Args[0])
+	if len(args) == 0 || args[0] == "-h" || args[0] == "-help" || args[0] == "help" {
+		printCommands(os.Stderr, exe)
+		if len(args) == 0 {
+			return exitCodeUserError
+		}
+		return 0
+	}
+
+	c, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n", args[0])
+		printCommands(os.Stderr, exe)
+		return exitCodeUserError
+	}
+	return run(c, exe+" "+c.name, args[1:])
+}
+
+// RunCommand runs a single subcommand as if it were a standalone binary named exe. It returns the exit code.
+func RunCommand(exe string, name string, args []string) int {
+	c, ok := commands[name]
+	if !ok {
+		panic("unknown command " + name)
+	}
+	return run(c, exe, args)
+}
+
+func run(c *command, exe string, args []string) int {
+	env := &env{exe: exe, stdout: os.Stdout, stderr: os.Stderr}
+	fs := flag.NewFlagSet(exe, flag.ContinueOnError)
+	fs.SetOutput(env.stderr)
+	fs.BoolVar(&env.verbose, "v", false, "verbose; print details about what's going on")
+	fs.BoolVar(&env.stats, "stats", false, "statistics; print counters such as the number of bytes read and records parsed when done")
+	if c.flags != nil {
+		c.flags(env, fs)
+	}
+	fs.Usage = func() { printUsage(env, c, fs) }
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return exitCodeUserError
+	}
+
+	err := c.run(env, fs)
+	env.printStats()
+	if err == nil {
+		return 0
+	}
+	var exitErr *exitError
+	if errors.As(err, &exitErr) {
+		fmt.Fprintln(env.stderr, exitErr.msg)
+		if exitErr.code == exitCodeUserError {
+			fs.Usage()
+		}
+		return exitErr.code
+	}
+	fmt.Fprintln(env.stderr, err)
+	return exitCodeTechnicalError
+}
+
+func printUsage(env *env, c *command, fs *flag.FlagSet) {
+	out := env.stderr
+	fmt.Fprintf(out, "\nusage: %s [flags] %s\n\n", env.exe, c.args)
+	fmt.Fprintln(out, c.description)
+	fmt.Fprintln(out, "\nFlags:")
+
+	fs.PrintDefaults()
+
+	if c.example != nil {
+		fmt.Fprintf(out, "\nFor example: %s\n", c.example(env.exe))
+	}
+}
+
+func printCommands(out io.Writer, exe string) {
+	fmt.Fprintf(out, "\nusage: %s <command> [flags] [arguments]\n\nCommands:\n", exe)
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(out, "  %-8s %s\n", name, commands[name].summary)
+	}
+	fmt.Fprintf(out, "\nUse \"%s <command> -h\" for more information about a command.\n", exe)
+}
+
+// printVerbose prints diagnostic output to stderr when the verbose flag is set.
+func (env *env) printVerbose(format string, v ...interface{}) {
+	if env.verbose {
+		fmt.Fprintf(env.stderr, format, v...)
+	}
+}
+
+// metrics returns the metrics.Recorder counting the work done when the stats flag is set, or nil otherwise.
+func (env *env) metrics() metrics.Recorder {
+	if !env.stats {
+		return nil
+	}
+	if env.counters == nil {
+		env.counters = &metrics.Counters{}
+	}
+	return env.counters
+}
+
+// printStats prints the counters to stderr when the stats flag is set.
+func (env *env) printStats() {
+	if !env.stats || env.counters == nil {
+		return
+	}
+	fmt.Fprintln(env.stderr, "Statistics:")
+	for _, name := range env.counters.Names() {
+		value := env.counters.Get(name)
+		if strings.HasPrefix(name, "bytes_") {
+			fmt.Fprintf(env.stderr, "  %-16s %d (%s)\n", name, value, formatBytes(value))
+		} else {
+			fmt.Fprintf(env.stderr, "  %-16s %d\n", name, value)
+		}
+	}
+}
+
+// stderrLogger is an mft.Logger printing diagnostic messages of the library to stderr.
+type stderrLogger struct {
+	mu  sync.Mutex
+	env *env
+}
+
+func (l *stderrLogger) Printf(format string, v ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fmt.Fprintf(l.env.stderr, "gomft: "+format+"\n", v...)
+}
+
+// logger returns an mft.Logger printing the diagnostic messages of the library to stderr when the verbose flag is set,
+// or nil otherwise.
+func (env *env) logger() mft.Logger {
+	if !env.verbose {
+		return nil
+	}
+	return &stderrLogger{env: env}
+}
+
+func formatBytes(b int64) string {
+	if b < 1024 {
+		return fmt.Sprintf("%dB", b)
+	}
+	if b < 1048576 {
+		return fmt.Sprintf("%.2fKiB", float32(b)/float32(1024))
+	}
+	if b < 1073741824 {
+		return fmt.Sprintf("%.2fMiB", float32(b)/float32(1048576))
+	}
+	return fmt.Sprintf("%.2fGiB", float32(b)/float32(1073741824))
+}
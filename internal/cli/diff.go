@@ -0,0 +1,129 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/t9t/gomft/export"
+	"github.com/t9t/gomft/mftdiff"
+	"github.com/t9t/gomft/pipeline"
+)
+
+type diffFlags struct {
+	recordSize   int
+	paths        bool
+	ignoreAccess bool
+	reused       bool
+}
+
+func init() {
+	flags := &diffFlags{}
+	register(&command{
+		name:    "diff",
+		args:    "<old volume or MFT dump> <new volume or MFT dump>",
+		summary: "Compare two MFTs and print the records which changed",
+		description: "Compare two dumps of the same MFT, for example taken before and after running a program, and print the records\n" +
+			"which were created, deleted, reused (in use by another file) or modified in between. For modified records, each\n" +
+			"changed field is printed as well. When an input is not an NTFS volume, it is assumed to be an MFT dump.",
+		example: func(exe string) string {
+			return exe + " before.mft after.mft"
+		},
+		flags: func(env *env, fs *flag.FlagSet) {
+			fs.IntVar(&flags.recordSize, "r", 1024, "record size; size of an MFT record in bytes when reading an MFT dump")
+			fs.BoolVar(&flags.paths, "paths", true, "paths; print full paths instead of only file names")
+			fs.BoolVar(&flags.ignoreAccess, "ignore-access", false, "ignore access; leave out changes of the last access times")
+			fs.BoolVar(&flags.reused, "reused", false, "reused; only print reused records, including those of deleted files, by their sequence numbers")
+		},
+		run: func(env *env, fs *flag.FlagSet) error {
+			return runDiff(env, flags, fs.Args())
+		},
+	})
+}
+
+func runDiff(env *env, flags *diffFlags, args []string) error {
+	if len(args) != 2 {
+		return fail(exitCodeUserError, "Expected 2 arguments but got %d", len(args))
+	}
+	if flags.recordSize <= 0 {
+		return fail(exitCodeUserError, "Record size should be positive but is %d", flags.recordSize)
+	}
+
+	opts := mftdiff.Options{}
+	if flags.ignoreAccess {
+		opts.Ignore = mftdiff.IgnoreAccessTimes
+	}
+	sources := make([]io.Reader, 2)
+	resolvers := make([]*pipeline.PathResolver, 2)
+	for i, name := range args {
+		in, err := openInput(env, name)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+
+		if flags.paths {
+			mftAt, size, err := openMftAt(env, in, flags.recordSize)
+			if err != nil {
+				return err
+			}
+			resolvers[i] = pipeline.NewPathResolver(mftAt, size, 0)
+		}
+		src, recordSize, err := openMft(env, in, flags.recordSize)
+		if err != nil {
+			return err
+		}
+		if i == 1 && recordSize != opts.RecordSize {
+			return fail(exitCodeFunctionalError, "Record sizes differ: %d and %d", opts.RecordSize, recordSize)
+		}
+		sources[i] = src
+		opts.RecordSize = recordSize
+	}
+	opts.OldPaths, opts.NewPaths = resolvers[0], resolvers[1]
+
+	out := env.stdout
+	if flags.reused {
+		stats, err := mftdiff.FindReused(sources[0], sources[1], opts, func(r mftdiff.Reuse) error {
+			_, err := fmt.Fprintf(out, "%-8v %d-%d %s (was %d-%d %s)\n", mftdiff.Reused, r.RecordNumber, r.New.SequenceNumber,
+				diffName(r.New), r.RecordNumber, r.Old.SequenceNumber, diffName(r.Old))
+			return err
+		})
+		if err != nil {
+			return fail(exitCodeTechnicalError, "Unable to compare: %v", err)
+		}
+		env.printVerbose("Compared %d records: %d reused, %d could not be parsed\n", stats.Records, stats.Reused, stats.Errors)
+		return nil
+	}
+	stats, err := mftdiff.Compare(sources[0], sources[1], opts, func(c mftdiff.Change) error {
+		var err error
+		switch c.Kind {
+		case mftdiff.Reused:
+			_, err = fmt.Fprintf(out, "%-8v %d-%d %s (was %d-%d %s)\n", c.Kind, c.RecordNumber, c.New.SequenceNumber,
+				diffName(c.New), c.RecordNumber, c.Old.SequenceNumber, diffName(c.Old))
+		case mftdiff.Deleted:
+			_, err = fmt.Fprintf(out, "%-8v %d-%d %s\n", c.Kind, c.RecordNumber, c.Old.SequenceNumber, diffName(c.Old))
+		default:
+			_, err = fmt.Fprintf(out, "%-8v %d-%d %s\n", c.Kind, c.RecordNumber, c.New.SequenceNumber, diffName(c.New))
+		}
+		for _, d := range c.Differences {
+			if err == nil {
+				_, err = fmt.Fprintf(out, "         %v\n", d)
+			}
+		}
+		return err
+	})
+	if err != nil {
+		return fail(exitCodeTechnicalError, "Unable to compare: %v", err)
+	}
+	env.printVerbose("Compared %d records: %d created, %d deleted, %d reused, %d modified, %d could not be parsed\n",
+		stats.Records, stats.Created, stats.Deleted, stats.Reused, stats.Modified, stats.Errors)
+	return nil
+}
+
+// diffName returns the path of the Entry, or its name when paths are not resolved.
+func diffName(e export.Entry) string {
+	if e.Path != "" {
+		return e.Path
+	}
+	return e.Name
+}
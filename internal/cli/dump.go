@@ -0,0 +1,454 @@
+package cli
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"flag"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/t9t/gomft/binutil"
+	"github.com/t9t/gomft/filter"
+	"github.com/t9t/gomft/fragment"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/outfile"
+	"github.com/t9t/gomft/pipeline"
+	"github.com/t9t/gomft/retry"
+	"github.com/t9t/gomft/subset"
+	"github.com/t9t/gomft/uring"
+)
+
+type dumpFlags struct {
+	overwriteOutputIfExists bool
+	showProgress            bool
+	uring                   bool
+	hash                    string
+	write                   string
+	records                 string
+	where                   string
+	mapping                 string
+	retries                 int
+	timeout                 time.Duration
+	estimate                bool
+}
+
+func init() {
+	flags := &dumpFlags{}
+	register(&command{
+		name:    "dump",
+		args:    "<volume> <output file>",
+		summary: "Dump the MFT of a volume to a file",
+		description: "Dump the MFT of a volume to a file. The volume should be NTFS formatted. Use -records and -where to dump only a\n" +
+			"selection of the records, along with a mapping of their original record numbers.",
+		example: func(exe string) string {
+			if isWin {
+				return exe + ` -v -f C: D:\c.mft`
+			}
+			return exe + " -v -f /dev/sdb1 ~/sdb1.mft"
+		},
+		flags: func(env *env, fs *flag.FlagSet) {
+			fs.BoolVar(&flags.overwriteOutputIfExists, "f", false, "force; overwrite the output file if it already exists")
+			fs.BoolVar(&flags.showProgress, "p", false, "progress; show progress during dumping")
+			fs.StringVar(&flags.hash, "hash", "", "hash; print a hash of the dumped data, using md5, sha1 or sha256")
+			fs.BoolVar(&flags.uring, "uring", false, "io_uring; queue many reads at once using io_uring on Linux, which is faster on NVMe devices")
+			fs.StringVar(&flags.records, "records", "", "records; only dump records with these record numbers, eg. \"0-15,64-\"")
+			fs.StringVar(&flags.where, "where", "", "where; only dump records matching the filter expression, eg. \"inuse and path like '/Users/*'\"")
+			fs.StringVar(&flags.mapping, "map", "", "map; file to write the mapping of original record numbers of a partial dump to (default <output file>.map.csv)")
+			fs.StringVar(&flags.write, "write", "buffered", "write mode; write the output file using buffered writes, mmap or direct (unbuffered) writes")
+			fs.BoolVar(&flags.estimate, "estimate", false, "estimate; only print the size and fragmentation of the MFT and an estimate of how long dumping takes, without dumping")
+			fs.IntVar(&flags.retries, "retries", 0, "retries; retry failed reads this many times, with exponential backoff, for devices with transient errors")
+			fs.DurationVar(&flags.timeout, "timeout", 0, "timeout; retry reads which take longer than this, eg. \"30s\" (implies -retries 4 when not set)")
+		},
+		run: func(env *env, fs *flag.FlagSet) error {
+			return runDump(env, flags, fs.Args())
+		},
+	})
+}
+
+func runDump(env *env, flags *dumpFlags, args []string) error {
+	start := time.Now()
+	if flags.estimate && len(args) == 1 {
+		// The output file is not needed to estimate
+		args = append(args, "")
+	}
+	if len(args) != 2 {
+		return fail(exitCodeUserError, "Expected 2 arguments but got %d", len(args))
+	}
+
+	outpath := args[1]
+	if flags.hash != "" && newHash(flags.hash) == nil {
+		return fail(exitCodeUserError, "Unknown hash algorithm %q (expected md5, sha1 or sha256)", flags.hash)
+	}
+	writeMode, err := outfile.ParseMode(flags.write)
+	if err != nil {
+		return fail(exitCodeUserError, "Unknown write mode %q (expected buffered, mmap or direct)", flags.write)
+	}
+
+	if flags.retries < 0 {
+		return fail(exitCodeUserError, "Number of retries should not be negative but is %d", flags.retries)
+	}
+	if flags.timeout > 0 && flags.retries == 0 {
+		flags.retries = retry.DefaultAttempts - 1
+	}
+	if flags.uring && flags.retries > 0 {
+		return fail(exitCodeUserError, "The -uring flag cannot be combined with -retries or -timeout")
+	}
+
+	opts, partial, err := subsetOptions(flags)
+	if err != nil {
+		return err
+	}
+
+	f, err := openInput(env, args[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var in io.ReaderAt = f
+	if flags.retries > 0 {
+		in = retry.NewReaderAt(f, retry.Options{
+			Attempts: flags.retries + 1,
+			Timeout:  flags.timeout,
+			OnRetry: func(off int64, attempt int, err error) {
+				env.printVerbose("Read at offset %d failed (attempt %d of %d), retrying: %v\n", off, attempt, flags.retries+1, err)
+			},
+		})
+	}
+
+	vm, err := locateMft(env, in)
+	if err != nil {
+		return err
+	}
+	if flags.estimate {
+		return estimateDump(env, vm)
+	}
+
+	// The output is preallocated, so the file system does not fragment the dump itself
+	out, err := outfile.Create(outpath, outfile.Options{Size: vm.totalLength, Overwrite: flags.overwriteOutputIfExists, Mode: writeMode})
+	if err != nil {
+		return fail(exitCodeFunctionalError, "Unable to open output file: %v", err)
+	}
+	defer out.Close()
+	if out.Mode() != writeMode {
+		env.printVerbose("%s writes are not available, using %s writes\n", writeMode, out.Mode())
+	}
+
+	var src io.Reader = vm.volume.MftReader()
+	if flags.uring {
+		file, ok := inputFile(f)
+		if !ok {
+			return fail(exitCodeUserError, "The -uring flag needs a local volume")
+		}
+		r, err := uring.NewReader(file, 0)
+		if err != nil {
+			return fail(exitCodeTechnicalError, "Unable to set up io_uring: %v", err)
+		}
+		defer r.Close()
+		if !r.UsesIoUring() {
+			env.printVerbose("io_uring is not available, using regular reads\n")
+		}
+		src = r.FragmentReader(vm.fragments, 0)
+	}
+
+	var h hash.Hash
+	if flags.hash != "" {
+		h = newHash(flags.hash)
+	}
+	if partial {
+		if err := dumpPartial(env, flags, in, vm, opts, out, src, h, outpath); err != nil {
+			return err
+		}
+		if h != nil {
+			fmt.Fprintf(env.stdout, "%x  %s\n", h.Sum(nil), outpath)
+		}
+		env.printVerbose("Finished in %v\n", time.Now().Sub(start))
+		return nil
+	}
+
+	env.printVerbose("Copying %d bytes (%s) of data to %s\n", vm.totalLength, formatBytes(vm.totalLength), outpath)
+	n, err := copyData(env, out, src, h, vm.totalLength, flags.showProgress)
+	if err != nil {
+		return fail(exitCodeTechnicalError, "Error copying data to output file: %v", err)
+	}
+
+	if n != vm.totalLength {
+		return fail(exitCodeTechnicalError, "Expected to copy %d bytes, but copied only %d", vm.totalLength, n)
+	}
+	if err := out.Close(); err != nil {
+		return fail(exitCodeTechnicalError, "Error writing output file: %v", err)
+	}
+	if h != nil {
+		fmt.Fprintf(env.stdout, "%x  %s\n", h.Sum(nil), outpath)
+	}
+	end := time.Now()
+	dur := end.Sub(start)
+	env.printVerbose("Finished in %v\n", dur)
+	return nil
+}
+
+// subsetOptions returns the subset.Options for the -records and -where flags, and whether any of them is set.
+func subsetOptions(flags *dumpFlags) (subset.Options, bool, error) {
+	opts := subset.Options{}
+	if flags.records != "" {
+		for _, s := range strings.Split(flags.records, ",") {
+			r, err := subset.ParseRange(strings.TrimSpace(s))
+			if err != nil {
+				return opts, false, fail(exitCodeUserError, "Invalid record range %q: %v", s, err)
+			}
+			opts.Ranges = append(opts.Ranges, r)
+		}
+	}
+	if flags.where != "" {
+		where, err := filter.Compile(flags.where)
+		if err != nil {
+			return opts, false, fail(exitCodeUserError, "Invalid filter expression: %v", err)
+		}
+		opts.Match = where.Match
+	}
+	return opts, flags.records != "" || flags.where != "", nil
+}
+
+// dumpPartial copies the records selected by the subset.Options from src to out, and writes the mapping of their
+// original record numbers to the mapping file.
+func dumpPartial(env *env, flags *dumpFlags, in io.ReaderAt, vm volumeMft, opts subset.Options, out *outfile.File, src io.Reader, h hash.Hash, outpath string) error {
+	mappingPath := flags.mapping
+	if mappingPath == "" {
+		mappingPath = outpath + ".map.csv"
+	}
+	mappingFile, err := openOutputFile(mappingPath, flags.overwriteOutputIfExists)
+	if err != nil {
+		return fail(exitCodeFunctionalError, "Unable to open mapping file: %v", err)
+	}
+	defer mappingFile.Close()
+
+	opts.RecordSize = vm.recordSize
+	if opts.Match != nil {
+		// Paths, and whether files are pending deletion, are resolved from the complete MFT on the volume
+		opts.Paths = pipeline.NewPathResolver(fragment.NewReaderAt(in, vm.fragments), vm.recordSize, 0)
+	}
+	var dst io.Writer = out
+	if h != nil {
+		dst = io.MultiWriter(out, h)
+	}
+	if flags.showProgress {
+		src = &progressReader{Reader: src, env: env, totalSize: formatBytes(vm.totalLength), onePercent: float64(vm.totalLength) / 100}
+	}
+	// Read in large chunks instead of record by record
+	src = bufio.NewReaderSize(src, 1024*1024)
+
+	env.printVerbose("Copying selected records of %d bytes (%s) of data to %s\n", vm.totalLength, formatBytes(vm.totalLength), outpath)
+	mw := subset.NewMappingWriter(mappingFile)
+	stats, err := subset.Copy(dst, src, opts, mw.Write)
+	if flags.showProgress {
+		fmt.Fprintln(env.stdout)
+	}
+	if err != nil {
+		return fail(exitCodeTechnicalError, "Error copying records to output file: %v", err)
+	}
+	// The output was preallocated to the size of the complete MFT, and is truncated to the records copied
+	if err := out.Close(); err != nil {
+		return fail(exitCodeTechnicalError, "Error writing output file: %v", err)
+	}
+	if err := mw.Flush(); err != nil {
+		return fail(exitCodeTechnicalError, "Error writing mapping file: %v", err)
+	}
+	if err := mappingFile.Close(); err != nil {
+		return fail(exitCodeTechnicalError, "Error writing mapping file: %v", err)
+	}
+	env.printVerbose("Copied %d of %d records, %d could not be parsed; wrote mapping to %s\n", stats.Copied, stats.Records, stats.Errors, mappingPath)
+	return nil
+}
+
+// estimateSampleSize is the maximum number of bytes of the MFT read by estimateDump to measure the read rate.
+const estimateSampleSize = 16 * 1024 * 1024
+
+// estimateDump prints the size and fragmentation of the MFT, and estimates how long dumping it takes by measuring how
+// fast a sample of the MFT can be read.
+func estimateDump(env *env, vm volumeMft) error {
+	out := env.stdout
+	fmt.Fprintf(out, "MFT size:              %d (%s)\n", vm.totalLength, formatBytes(vm.totalLength))
+	fmt.Fprintf(out, "MFT record slots:      %d\n", vm.totalLength/int64(vm.recordSize))
+	if data, ok := vm.record.FindFirstAttribute(mft.AttributeTypeData); ok {
+		if runs, err := mft.ParseDataRuns(data.Data); err == nil {
+			stats := mft.DataRunFragmentation(runs)
+			fmt.Fprintf(out, "MFT extents:           %d\n", stats.Extents)
+			fmt.Fprintf(out, "Out-of-order extents:  %d\n", stats.OutOfOrderExtents)
+			fmt.Fprintf(out, "Average extent size:   %s\n", formatBytes(int64(stats.AverageExtentClusters()*float64(vm.bytesPerCluster))))
+		}
+	}
+
+	sampleSize := vm.totalLength
+	if sampleSize > estimateSampleSize {
+		sampleSize = estimateSampleSize
+	}
+	env.printVerbose("Reading a sample of %d bytes (%s) of the MFT\n", sampleSize, formatBytes(sampleSize))
+	buf := copyBuffers.Get()
+	defer copyBuffers.Put(buf)
+	src := io.LimitReader(vm.volume.MftReader(), sampleSize)
+	sampleStart := time.Now()
+	read := int64(0)
+	for read < sampleSize {
+		n, err := io.ReadFull(src, buf)
+		read += int64(n)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return fail(exitCodeTechnicalError, "Unable to read sample of MFT data: %v", err)
+		}
+	}
+	elapsed := time.Since(sampleStart)
+	if elapsed <= 0 {
+		elapsed = time.Nanosecond
+	}
+	if read == 0 {
+		fmt.Fprintf(out, "Estimated dump time:   unknown (no MFT data could be read)\n")
+		return nil
+	}
+	rate := float64(read) / elapsed.Seconds()
+	eta := time.Duration(float64(vm.totalLength) / rate * float64(time.Second))
+	fmt.Fprintf(out, "Sample read rate:      %s/s (%s in %v)\n", formatBytes(int64(rate)), formatBytes(read), roundDuration(elapsed))
+	fmt.Fprintf(out, "Estimated dump time:   %v\n", roundDuration(eta))
+	return nil
+}
+
+// roundDuration rounds the duration to a precision suitable for printing: seconds for durations longer than a minute,
+// milliseconds for durations longer than a second and microseconds otherwise.
+func roundDuration(d time.Duration) time.Duration {
+	switch {
+	case d > time.Minute:
+		return d.Round(time.Second)
+	case d > time.Second:
+		return d.Round(time.Millisecond)
+	}
+	return d.Round(time.Microsecond)
+}
+
+// progressReader prints the progress of reading from the underlying io.Reader.
+type progressReader struct {
+	io.Reader
+	env        *env
+	read       int64
+	totalSize  string
+	onePercent float64
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if n > 0 {
+		r.read += int64(n)
+		printProgress(r.env, r.read, r.totalSize, r.onePercent)
+	}
+	return n, err
+}
+
+// copyBuffers are the buffers used to copy data, such as fragments of the MFT from a volume.
+var copyBuffers = binutil.NewBufferPool(1024 * 1024)
+
+// copyChunk is a buffer of data passed between the stages of copyData. The err is the error that occurred reading
+// the data after it; io.EOF at the end of the data.
+type copyChunk struct {
+	buf []byte
+	n   int
+	err error
+}
+
+// copyData copies all data from src to dst, updating h (when not nil) with the data along the way. Reading, hashing
+// and writing are done by separate goroutines connected by channels, so that reading the next buffers of data from a
+// slow source continues while the previous ones are hashed and written.
+func copyData(env *env, dst io.Writer, src io.Reader, h hash.Hash, totalLength int64, showProgress bool) (written int64, err error) {
+	const queued = 4
+	done := make(chan struct{})
+	defer close(done)
+
+	read := make(chan copyChunk, queued)
+	go func() {
+		defer close(read)
+		for {
+			buf := copyBuffers.Get()
+			n, err := io.ReadFull(src, buf)
+			if err == io.ErrUnexpectedEOF {
+				err = io.EOF
+			}
+			select {
+			case read <- copyChunk{buf: buf, n: n, err: err}:
+			case <-done:
+				copyBuffers.Put(buf)
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	hashed := read
+	if h != nil {
+		hashed = make(chan copyChunk, queued)
+		go func(out chan<- copyChunk) {
+			defer close(out)
+			for c := range read {
+				h.Write(c.buf[:c.n])
+				select {
+				case out <- c:
+				case <-done:
+					copyBuffers.Put(c.buf)
+					return
+				}
+			}
+		}(hashed)
+	}
+
+	onePercent := float64(totalLength) / float64(100.0)
+	totalSize := formatBytes(totalLength)
+	if showProgress {
+		printProgress(env, written, totalSize, onePercent)
+		defer fmt.Fprintln(env.stdout)
+	}
+	for c := range hashed {
+		nw, ew := dst.Write(c.buf[:c.n])
+		written += int64(nw)
+		copyBuffers.Put(c.buf)
+		if showProgress {
+			printProgress(env, written, totalSize, onePercent)
+		}
+		if ew != nil {
+			return written, ew
+		}
+		if nw != c.n {
+			return written, io.ErrShortWrite
+		}
+		if c.err == io.EOF {
+			return written, nil
+		}
+		if c.err != nil {
+			return written, c.err
+		}
+	}
+	return written, nil
+}
+
+// newHash creates a hash.Hash for the algorithm with the specified name, or returns nil when it is unknown.
+func newHash(name string) hash.Hash {
+	switch name {
+	case "md5":
+		return md5.New()
+	case "sha1":
+		return sha1.New()
+	case "sha256":
+		return sha256.New()
+	}
+	return nil
+}
+
+func printProgress(env *env, n int64, totalSize string, onePercent float64) {
+	percentage := float64(n) / onePercent
+	barCount := int(percentage / 2.0)
+	spaceCount := 50 - barCount
+	fmt.Fprintf(env.stdout, "\r[%s%s] %.2f%% (%s / %s)     ", strings.Repeat("|", barCount), strings.Repeat(" ", spaceCount), percentage, formatBytes(n), totalSize)
+}
@@ -0,0 +1,125 @@
+package cli
+
+import (
+	"flag"
+	"io"
+	"time"
+
+	"github.com/t9t/gomft/extentmap"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/pipeline"
+)
+
+type extentsFlags struct {
+	output      string
+	force       bool
+	recordSize  int
+	clusterSize int
+	inUseOnly   bool
+}
+
+func init() {
+	flags := &extentsFlags{}
+	register(&command{
+		name:    "extents",
+		args:    "<volume or MFT dump>",
+		summary: "List the extents of all files as JSON",
+		description: "List where the data of each file is stored: the extents (byte ranges on the volume) of every\n" +
+			"non-resident attribute, marking sparse and compressed extents, as JSON Lines. This is the input for disk map\n" +
+			"visualizations and defragmentation analysis. When the input is an MFT dump, specify the cluster size of the\n" +
+			"volume it was taken from using -c.",
+		example: func(exe string) string {
+			if isWin {
+				return exe + ` -u -o D:\extents.json C:`
+			}
+			return exe + " -u -o ~/extents.json /dev/sdb1"
+		},
+		flags: func(env *env, fs *flag.FlagSet) {
+			fs.StringVar(&flags.output, "o", "", "output; write output to this file instead of stdout")
+			fs.BoolVar(&flags.force, "f", false, "force; overwrite the output file if it already exists")
+			fs.IntVar(&flags.recordSize, "r", 1024, "record size; size of an MFT record in bytes when reading an MFT dump")
+			fs.IntVar(&flags.clusterSize, "c", 4096, "cluster size; size of a cluster in bytes when reading an MFT dump")
+			fs.BoolVar(&flags.inUseOnly, "u", false, "in use; only list records which are in use")
+		},
+		run: func(env *env, fs *flag.FlagSet) error {
+			return runExtents(env, flags, fs.Args())
+		},
+	})
+}
+
+func runExtents(env *env, flags *extentsFlags, args []string) error {
+	start := time.Now()
+	if len(args) != 1 {
+		return fail(exitCodeUserError, "Expected 1 argument but got %d", len(args))
+	}
+	if flags.recordSize <= 0 {
+		return fail(exitCodeUserError, "Record size should be positive but is %d", flags.recordSize)
+	}
+	if flags.clusterSize <= 0 {
+		return fail(exitCodeUserError, "Cluster size should be positive but is %d", flags.clusterSize)
+	}
+
+	in, err := openInput(env, args[0])
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	volume, err := isVolume(in)
+	if err != nil {
+		return fail(exitCodeTechnicalError, "Unable to read input: %v", err)
+	}
+	bytesPerCluster := flags.clusterSize
+	if volume {
+		vm, err := locateMft(env, in)
+		if err != nil {
+			return err
+		}
+		bytesPerCluster = vm.bytesPerCluster
+	}
+
+	mftAt, recordSize, err := openMftAt(env, in, flags.recordSize)
+	if err != nil {
+		return err
+	}
+	src, _, err := openMft(env, in, flags.recordSize)
+	if err != nil {
+		return err
+	}
+
+	var out io.Writer = env.stdout
+	closeOutput := func() error { return nil }
+	if flags.output != "" {
+		f, err := openOutputFile(flags.output, flags.force)
+		if err != nil {
+			return fail(exitCodeFunctionalError, "Unable to open output file: %v", err)
+		}
+		out = f
+		closeOutput = f.Close
+	}
+	w := extentmap.NewJSONWriter(out)
+
+	opts := pipeline.Options{
+		ParseAll: mft.ParseAllOptions{RecordSize: recordSize, SkipEmpty: true, Parse: mft.ParseOptions{Logger: env.logger()}, Metrics: env.metrics()},
+		ErrorHandler: func(index int, offset int64, err error) {
+			env.printVerbose("Unable to parse record at offset %d: %v\n", offset, err)
+		},
+	}
+	stats, err := pipeline.Run(src, opts,
+		pipeline.Filter(func(item *pipeline.Item) bool { return item.Entry.InUse || !flags.inUseOnly }),
+		pipeline.Paths(pipeline.NewPathResolver(mftAt, recordSize, 0)),
+		extentmap.Stage(bytesPerCluster, w.Write))
+	if err != nil {
+		closeOutput()
+		return fail(exitCodeTechnicalError, "Unable to list extents: %v", err)
+	}
+	if err := w.Flush(); err != nil {
+		closeOutput()
+		return fail(exitCodeTechnicalError, "Unable to write output: %v", err)
+	}
+	if err := closeOutput(); err != nil {
+		return fail(exitCodeTechnicalError, "Unable to close output: %v", err)
+	}
+
+	env.printVerbose("Listed the extents of %d records in %v\n", stats.Passed, time.Since(start))
+	return nil
+}
@@ -0,0 +1,135 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/t9t/gomft/fragment"
+	"github.com/t9t/gomft/mft"
+)
+
+// maxPrintedHoles is the maximum number of ranges of free MFT records printed by info.
+const maxPrintedHoles = 10
+
+func init() {
+	register(&command{
+		name:        "info",
+		args:        "<volume>",
+		summary:     "Print information about an NTFS volume",
+		description: "Print information about an NTFS volume, such as its boot sector, the size and location of its MFT, the usage of its record slots, and its label, NTFS version and flags.",
+		example: func(exe string) string {
+			if isWin {
+				return exe + " C:"
+			}
+			return exe + " /dev/sdb1"
+		},
+		run: func(env *env, fs *flag.FlagSet) error {
+			return runInfo(env, fs.Args())
+		},
+	})
+}
+
+func runInfo(env *env, args []string) error {
+	if len(args) != 1 {
+		return fail(exitCodeUserError, "Expected 1 argument but got %d", len(args))
+	}
+
+	in, err := openInput(env, args[0])
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	vm, err := locateMft(env, in)
+	if err != nil {
+		return err
+	}
+
+	bs := vm.bootSector
+	out := env.stdout
+	fmt.Fprintf(out, "OEM ID:                %q\n", bs.OemId)
+	fmt.Fprintf(out, "Volume serial number:  %X\n", reverse(bs.VolumeSerialNumber))
+	fmt.Fprintf(out, "Bytes per sector:      %d\n", bs.BytesPerSector)
+	fmt.Fprintf(out, "Sectors per cluster:   %d\n", bs.SectorsPerCluster)
+	fmt.Fprintf(out, "Bytes per cluster:     %d\n", vm.bytesPerCluster)
+	fmt.Fprintf(out, "Total sectors:         %d\n", bs.TotalSectors)
+	fmt.Fprintf(out, "Volume size:           %s\n", formatBytes(int64(bs.TotalSectors)*int64(bs.BytesPerSector)))
+	fmt.Fprintf(out, "MFT cluster:           %d\n", bs.MftClusterNumber)
+	fmt.Fprintf(out, "MFT mirror cluster:    %d\n", bs.MftMirrorClusterNumber)
+	fmt.Fprintf(out, "MFT record size:       %d\n", vm.recordSize)
+	fmt.Fprintf(out, "Index block size:      %d\n", bs.IndexBufferSizeInBytes)
+	fmt.Fprintf(out, "MFT size:              %d (%s)\n", vm.totalLength, formatBytes(vm.totalLength))
+	fmt.Fprintf(out, "MFT record slots:      %d\n", vm.totalLength/int64(vm.recordSize))
+	fmt.Fprintf(out, "MFT fragments:         %d\n", len(vm.fragments))
+	for i, f := range vm.fragments {
+		fmt.Fprintf(out, "  %4d: offset %d, length %d (%s)\n", i, f.Offset, f.Length, formatBytes(f.Length))
+	}
+	if err := printMftUsage(env, in, vm); err != nil {
+		fmt.Fprintf(env.stderr, "Unable to read $MFT $BITMAP: %v\n", err)
+	}
+
+	info, err := readVolumeInfo(env, in, vm)
+	if err != nil {
+		fmt.Fprintf(env.stderr, "Unable to read $Volume record: %v\n", err)
+		return nil
+	}
+	fmt.Fprintf(out, "Volume label:          %q\n", info.Label)
+	fmt.Fprintf(out, "NTFS version:          %s\n", info.Version())
+	fmt.Fprintf(out, "Volume flags:          %v\n", info.Flags)
+	fmt.Fprintf(out, "Dirty:                 %t\n", info.IsDirty())
+	return nil
+}
+
+// printMftUsage prints which record slots are allocated according to the $BITMAP attribute of the $MFT record.
+func printMftUsage(env *env, in io.ReaderAt, vm volumeMft) error {
+	a, err := mft.FindMftBitmap(vm.record)
+	if err != nil {
+		return err
+	}
+	data, err := readAttributeData(env, in, vm, a, "$MFT $BITMAP")
+	if err != nil {
+		return err
+	}
+	usage := mft.Bitmap(data).MftUsage(uint64(vm.totalLength / int64(vm.recordSize)))
+	out := env.stdout
+	fmt.Fprintf(out, "MFT records allocated: %d\n", usage.Allocated)
+	fmt.Fprintf(out, "MFT records free:      %d\n", usage.Free())
+	if usage.Allocated > 0 {
+		fmt.Fprintf(out, "Highest record number: %d\n", usage.HighestAllocated)
+	}
+	fmt.Fprintf(out, "MFT holes:             %d\n", len(usage.Holes))
+	for i, h := range usage.Holes {
+		if i == maxPrintedHoles {
+			fmt.Fprintf(out, "  ... and %d more\n", len(usage.Holes)-i)
+			break
+		}
+		fmt.Fprintf(out, "  records %d-%d (%d)\n", h.First, h.First+h.Count-1, h.Count)
+	}
+	return nil
+}
+
+// readVolumeInfo reads and parses the $Volume record of the volume.
+func readVolumeInfo(env *env, in io.ReaderAt, vm volumeMft) (mft.VolumeInfo, error) {
+	env.printVerbose("Reading $Volume record\n")
+	data := make([]byte, vm.recordSize)
+	r := fragment.NewReaderAt(in, vm.fragments)
+	if _, err := r.ReadAt(data, int64(mft.VolumeRecordNumber)*int64(vm.recordSize)); err != nil {
+		return mft.VolumeInfo{}, err
+	}
+	record, err := mft.ParseRecordWithOptions(data, mft.ParseOptions{Logger: env.logger()})
+	if err != nil {
+		return mft.VolumeInfo{}, err
+	}
+	return mft.ParseVolumeRecord(record)
+}
+
+// reverse returns a reversed copy of b; the volume serial number is stored in Little Endian order but usually
+// displayed in Big Endian order.
+func reverse(b []byte) []byte {
+	ret := make([]byte, len(b))
+	for i, v := range b {
+		ret[len(b)-1-i] = v
+	}
+	return ret
+}
@@ -0,0 +1,359 @@
+package cli
+
+import (
+	"flag"
+	"io"
+	"os"
+	"time"
+
+	"github.com/t9t/gomft/cache"
+	"github.com/t9t/gomft/columnar"
+	"github.com/t9t/gomft/entropy"
+	"github.com/t9t/gomft/export"
+	"github.com/t9t/gomft/filter"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/mmap"
+	"github.com/t9t/gomft/pipeline"
+	"github.com/t9t/gomft/scan"
+	"github.com/t9t/gomft/summary"
+)
+
+type lsFlags struct {
+	output     outputFlags
+	recordSize int
+	inUseOnly  bool
+	workers    int
+	mmap       bool
+	cache      string
+	top        int
+	by         string
+	index      bool
+	types      bool
+	entropy    bool
+}
+
+func init() {
+	flags := &lsFlags{}
+	register(&command{
+		name:    "ls",
+		args:    "<volume or MFT dump>",
+		summary: "List the records of an MFT as CSV or JSON",
+		description: "List the records in the MFT of a volume or in an MFT dump file as CSV or JSON. When the input is\n" +
+			"not an NTFS volume, it is assumed to be an MFT dump (for example as created by the dump command). Use -top\n" +
+			"to list only the records with the highest value of the metric set by -by, such as the largest files.",
+		example: func(exe string) string {
+			if isWin {
+				return exe + ` -format json -o D:\c.json D:\c.mft`
+			}
+			return exe + " -format json -o ~/sdb1.json ~/sdb1.mft"
+		},
+		flags: func(env *env, fs *flag.FlagSet) {
+			flags.output.register(fs)
+			fs.IntVar(&flags.recordSize, "r", 1024, "record size; size of an MFT record in bytes when reading an MFT dump")
+			fs.BoolVar(&flags.inUseOnly, "u", false, "in use; only list records which are in use")
+			fs.IntVar(&flags.workers, "w", 0, "workers; number of records to parse concurrently (default number of CPUs)")
+			fs.BoolVar(&flags.mmap, "mmap", false, "memory map; map the input into memory instead of reading it, which is faster for large dumps")
+			fs.StringVar(&flags.cache, "cache", "", "cache; load the records from this cache file if it matches the MFT, or create it after parsing")
+			fs.IntVar(&flags.top, "top", 0, "top; only list this many records with the highest value of the -by metric")
+			fs.BoolVar(&flags.index, "index", false, "index; also list the entries in the $I30 index root of each directory, with their own copies of the $FILE_NAME times")
+			fs.BoolVar(&flags.types, "types", false, "types; also detect the content type of non-resident data by reading its first bytes from the volume (not for MFT dumps)")
+			fs.BoolVar(&flags.entropy, "entropy", false, "entropy; also compute the entropy of the data, sampling blocks of large files, to highlight encrypted or packed content (not for MFT dumps)")
+			fs.StringVar(&flags.by, "by", "allocated", "by; metric to rank records by for -top: allocated (allocated size), extents (fragmentation), streams (alternate data streams) or depth (of the path)")
+		},
+		run: func(env *env, fs *flag.FlagSet) error {
+			return runLs(env, flags, fs.Args())
+		},
+	})
+}
+
+func runLs(env *env, flags *lsFlags, args []string) error {
+	start := time.Now()
+	if flags.output.schema {
+		return flags.output.writeSchema(env)
+	}
+	if len(args) != 1 {
+		return fail(exitCodeUserError, "Expected 1 argument but got %d", len(args))
+	}
+	if flags.recordSize <= 0 {
+		return fail(exitCodeUserError, "Record size should be positive but is %d", flags.recordSize)
+	}
+	var top *summary.Top
+	var where *filter.Filter
+	if flags.top < 0 {
+		return fail(exitCodeUserError, "Top should not be negative but is %d", flags.top)
+	}
+	if flags.index && (flags.top > 0 || flags.cache != "") {
+		return fail(exitCodeUserError, "The -index flag cannot be combined with -top or -cache")
+	}
+	if flags.types && flags.cache != "" {
+		return fail(exitCodeUserError, "The -types and -cache flags cannot be combined")
+	}
+	if flags.entropy && flags.cache != "" {
+		return fail(exitCodeUserError, "The -entropy and -cache flags cannot be combined")
+	}
+	if flags.top > 0 {
+		if flags.cache != "" {
+			return fail(exitCodeUserError, "The -top and -cache flags cannot be combined")
+		}
+		metric, err := summary.ParseMetric(flags.by)
+		if err != nil {
+			return fail(exitCodeUserError, "Invalid -by: %v", err)
+		}
+		top = summary.NewTop(metric, flags.top)
+		if where, err = flags.output.filter(); err != nil {
+			return err
+		}
+	}
+
+	var in io.ReaderAt
+	var mapped *mmap.File
+	if flags.mmap {
+		m, err := openMapped(env, args[0])
+		if err != nil {
+			return err
+		}
+		defer m.Close()
+		mapped = m
+		in = m.Reader()
+	} else {
+		f, err := openInput(env, args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	// The resolver reads parent directories, to find the files pending deletion and to resolve paths when needed
+	mftAt, size, err := openMftAt(env, in, flags.recordSize)
+	if err != nil {
+		return err
+	}
+	resolver := pipeline.NewPathResolver(mftAt, size, 0)
+
+	// Stages reading the data of files from the volume
+	var stages []pipeline.Stage
+	if flags.types || flags.entropy {
+		opts, err := volumeScanOptions(env, in, flags)
+		if err != nil {
+			return err
+		}
+		if flags.types {
+			stages = append(stages, scan.ContentTypes(opts))
+		}
+		if flags.entropy {
+			stages = append(stages, scan.Entropy(opts, entropy.Options{}))
+		}
+	}
+
+	src, recordSize, err := openMft(env, in, flags.recordSize)
+	if err != nil {
+		return err
+	}
+
+	w, finish, err := flags.output.open(env, args[0], resolver)
+	if err != nil {
+		return err
+	}
+
+	var table *columnar.Table
+	var key cache.Key
+	if flags.cache != "" {
+		env.printVerbose("Hashing MFT data\n")
+		key, err = cache.NewKey(src, recordSize)
+		if err != nil {
+			finish()
+			return fail(exitCodeTechnicalError, "Unable to hash MFT data: %v", err)
+		}
+		cached, err := cache.Load(flags.cache, key)
+		if err == nil {
+			env.printVerbose("Loaded %d records from cache %s\n", cached.Len(), flags.cache)
+			return writeCachedEntries(env, flags, cached, w, finish, start)
+		}
+		if !os.IsNotExist(err) {
+			env.printVerbose("Not using cache %s: %v\n", flags.cache, err)
+		}
+
+		// Start reading the MFT again, now to parse it
+		if src, recordSize, err = openMft(env, in, flags.recordSize); err != nil {
+			finish()
+			return err
+		}
+		table = columnar.NewTable(columnar.FieldAll)
+	}
+
+	read := 0
+	cancel := make(chan struct{})
+	defer close(cancel)
+	opts := mft.ParseAllOptions{
+		RecordSize: recordSize,
+		Workers:    flags.workers,
+		SkipEmpty:  true,
+		Parse:      mft.ParseOptions{Logger: env.logger()},
+		Cancel:     cancel,
+		Metrics:    env.metrics(),
+	}
+	var results <-chan mft.RecordResult
+	if mapped != nil && mftAt == in {
+		// A mapped dump file: parse the records directly from the mapped data
+		results = mft.ParseAllBytes(mapped.Bytes(), opts)
+	} else {
+		results = mft.ParseAll(src, opts)
+	}
+	for result := range results {
+		if readErr, ok := result.Err.(*mft.ReadError); ok {
+			finish()
+			return fail(exitCodeTechnicalError, "Unable to read record data at offset %d: %v", result.Offset, readErr.Err)
+		}
+		if result.Err != nil {
+			env.printVerbose("Unable to parse record at offset %d: %v\n", result.Offset, result.Err)
+			continue
+		}
+
+		e := export.FromRecord(result.Record)
+		e.Offset = result.Offset
+		if table != nil {
+			table.Append(e)
+		}
+		if flags.inUseOnly && !e.InUse {
+			continue
+		}
+		read++
+		if len(stages) > 0 {
+			item := &pipeline.Item{Index: result.Index, Offset: result.Offset, Record: result.Record, Entry: e}
+			for _, stage := range stages {
+				if _, err := stage.Process(item); err != nil {
+					finish()
+					return fail(exitCodeTechnicalError, "Unable to read data of record %d: %v", e.RecordNumber, err)
+				}
+			}
+			e = item.Entry
+		}
+		if top != nil {
+			if err := addTop(top, where, resolver, flags.by == summary.MetricDepth.String(), result, e); err != nil {
+				finish()
+				return fail(exitCodeTechnicalError, "Unable to resolve path of record %d: %v", e.RecordNumber, err)
+			}
+			continue
+		}
+		if err := w.Write(e); err != nil {
+			finish()
+			return fail(exitCodeTechnicalError, "Unable to write output: %v", err)
+		}
+		if flags.index && e.Directory {
+			if err := writeIndexRoot(env, w, result); err != nil {
+				finish()
+				return fail(exitCodeTechnicalError, "Unable to write output: %v", err)
+			}
+		}
+	}
+	if top != nil {
+		for _, r := range top.Results() {
+			if err := w.Write(r.Entry); err != nil {
+				finish()
+				return fail(exitCodeTechnicalError, "Unable to write output: %v", err)
+			}
+		}
+	}
+	if err := finish(); err != nil {
+		return err
+	}
+
+	if table != nil {
+		env.printVerbose("Writing %d records to cache %s\n", table.Len(), flags.cache)
+		if err := cache.Save(flags.cache, key, table); err != nil {
+			return fail(exitCodeTechnicalError, "Unable to write cache: %v", err)
+		}
+	}
+
+	env.printVerbose("Read %d records in %v\n", read, time.Since(start))
+	return nil
+}
+
+// addTop ranks a record matching the filter expression (if any), resolving its path when paths is set.
+func addTop(top *summary.Top, where *filter.Filter, resolver *pipeline.PathResolver, paths bool, result mft.RecordResult, e export.Entry) error {
+	pending, err := resolver.IsPendingDelete(e)
+	if err != nil {
+		return err
+	}
+	e.PendingDelete = pending
+	if where != nil && !where.Match(e) {
+		return nil
+	}
+	item := &pipeline.Item{Index: result.Index, Offset: result.Offset, Record: result.Record, Entry: e}
+	if paths {
+		path, err := resolver.Path(e)
+		if err != nil {
+			return err
+		}
+		item.Path = path
+	}
+	top.Add(item)
+	return nil
+}
+
+// volumeScanOptions creates the options for the stages reading the data of files (for -types and -entropy) from the
+// volume.
+func volumeScanOptions(env *env, in io.ReaderAt, flags *lsFlags) (scan.Options, error) {
+	volume, err := isVolume(in)
+	if err != nil {
+		return scan.Options{}, fail(exitCodeTechnicalError, "Unable to read input: %v", err)
+	}
+	if !volume {
+		name := "-types"
+		if !flags.types {
+			name = "-entropy"
+		}
+		return scan.Options{}, fail(exitCodeUserError, "The %s flag needs a volume (or an image of a volume) as input", name)
+	}
+	vm, err := locateMft(env, in)
+	if err != nil {
+		return scan.Options{}, err
+	}
+	return scan.Options{
+		Volume:          in,
+		BytesPerCluster: vm.bytesPerCluster,
+		ErrorHandler: func(item *pipeline.Item, stream string, err error) {
+			env.printVerbose("Unable to read stream %q of record %d: %v\n", stream, item.Entry.RecordNumber, err)
+		},
+	}, nil
+}
+
+// writeIndexRoot writes the entries in the $INDEX_ROOT of a directory record. An $INDEX_ROOT that cannot be parsed is
+// skipped, like records that cannot be parsed.
+func writeIndexRoot(env *env, w export.Writer, result mft.RecordResult) error {
+	entries, err := export.FromIndexRoot(result.Record)
+	if err != nil {
+		env.printVerbose("Unable to read index of record at offset %d: %v\n", result.Offset, err)
+		return nil
+	}
+	for _, e := range entries {
+		e.Offset = result.Offset
+		if err := w.Write(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeCachedEntries writes the entries loaded from a cache to w.
+func writeCachedEntries(env *env, flags *lsFlags, table *columnar.Table, w export.Writer, finish func() error, start time.Time) error {
+	read := 0
+	for i := 0; i < table.Len(); i++ {
+		e := table.Entry(i)
+		if flags.inUseOnly && !e.InUse {
+			continue
+		}
+		if err := w.Write(e); err != nil {
+			finish()
+			return fail(exitCodeTechnicalError, "Unable to write output: %v", err)
+		}
+		read++
+	}
+	if err := finish(); err != nil {
+		return err
+	}
+
+	env.printVerbose("Read %d records from cache in %v\n", read, time.Since(start))
+	return nil
+}
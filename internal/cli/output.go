@@ -0,0 +1,176 @@
+package cli
+
+import (
+	"flag"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/t9t/gomft/export"
+	"github.com/t9t/gomft/filter"
+	"github.com/t9t/gomft/pipeline"
+	"github.com/t9t/gomft/timeline"
+)
+
+// outputFlags are the flags shared by all commands that write records or entries in an export format.
+type outputFlags struct {
+	output string
+	force  bool
+	format string
+	where  string
+	schema bool
+}
+
+// filteredWriter only writes Entries matching the filter to the underlying export.Writer.
+type filteredWriter struct {
+	export.Writer
+	f *filter.Filter
+}
+
+func (w filteredWriter) Write(e export.Entry) error {
+	if !w.f.Match(e) {
+		return nil
+	}
+	return w.Writer.Write(e)
+}
+
+// pathWriter resolves whether Entries are pending deletion and, when paths is set, their Path before writing them to
+// the underlying export.Writer.
+type pathWriter struct {
+	export.Writer
+	r     *pipeline.PathResolver
+	paths bool
+}
+
+func (w pathWriter) Write(e export.Entry) error {
+	pending, err := w.r.IsPendingDelete(e)
+	if err != nil {
+		return err
+	}
+	e.PendingDelete = pending
+	if w.paths {
+		path, err := w.r.Path(e)
+		if err != nil {
+			return err
+		}
+		e.Path = path
+	}
+	return w.Writer.Write(e)
+}
+
+func (o *outputFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.output, "o", "", "output; write output to this file instead of stdout")
+	fs.BoolVar(&o.force, "f", false, "force; overwrite the output file if it already exists")
+	fs.StringVar(&o.format, "format", "csv", "format; output format: csv, json, mftecmd (CSV like MFTECmd), ecs (JSON with Elastic Common Schema fields), case (CASE/UCO JSON-LD), l2tcsv (timeline like log2timeline), or a Go text/template such as '{{.RecordNumber}} {{.Name}}'")
+	fs.StringVar(&o.where, "where", "", "where; only output entries matching the filter expression, eg. \"name like '*.exe' and not deleted\"")
+	fs.BoolVar(&o.schema, "schema", false, "schema; print the version and fields of the csv and json formats as JSON, and exit")
+}
+
+// writeSchema writes the schema of the csv and json formats to stdout, for the schema flag.
+func (o *outputFlags) writeSchema(env *env) error {
+	if o.format != "csv" && o.format != "json" {
+		return fail(exitCodeUserError, "The -schema flag describes the csv and json formats only, not %q", o.format)
+	}
+	if err := export.EntrySchema().WriteJSON(env.stdout); err != nil {
+		return fail(exitCodeTechnicalError, "Unable to write schema: %v", err)
+	}
+	return nil
+}
+
+// needsPaths indicates whether the output format includes paths, which are then resolved using the
+// pipeline.PathResolver passed to open.
+func (o *outputFlags) needsPaths() bool {
+	return o.format == "mftecmd" || o.format == "ecs" || o.format == "case" || o.format == "l2tcsv"
+}
+
+// filter compiles the filter expression, or returns nil when no filter expression is specified.
+func (o *outputFlags) filter() (*filter.Filter, error) {
+	if o.where == "" {
+		return nil, nil
+	}
+	f, err := filter.Compile(o.where)
+	if err != nil {
+		return nil, fail(exitCodeUserError, "Invalid filter expression: %v", err)
+	}
+	return f, nil
+}
+
+// open validates the flags and opens the output, returning an export.Writer and a function that flushes the writer
+// and closes the output. When a filter expression is specified, the returned export.Writer only writes matching
+// entries. The source is the name of the input, which some formats include. When resolver is not nil, it is used to
+// find the entries pending deletion and, for formats which need them, the paths of entries, before they are filtered
+// and written.
+func (o *outputFlags) open(env *env, source string, resolver *pipeline.PathResolver) (export.Writer, func() error, error) {
+	where, err := o.filter()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if o.format != "csv" && o.format != "json" && o.format != "mftecmd" && o.format != "ecs" && o.format != "case" && o.format != "l2tcsv" {
+		// validate the template before creating any output file
+		if _, err := export.NewTemplateWriter(ioutil.Discard, o.format); err != nil {
+			return nil, nil, fail(exitCodeUserError, "Invalid output format: %v", err)
+		}
+	}
+
+	var out io.Writer = env.stdout
+	closeOutput := func() error { return nil }
+	if o.output != "" {
+		f, err := openOutputFile(o.output, o.force)
+		if err != nil {
+			return nil, nil, fail(exitCodeFunctionalError, "Unable to open output file: %v", err)
+		}
+		out = f
+		closeOutput = f.Close
+	}
+
+	var w export.Writer
+	switch o.format {
+	case "csv":
+		w = export.NewCSVWriter(out)
+	case "json":
+		w = export.NewJSONWriter(out)
+	case "mftecmd":
+		w = export.NewMFTECmdWriter(out, source)
+	case "ecs":
+		w = export.NewECSWriter(out)
+	case "case":
+		w = export.NewCASEWriter(out)
+	case "l2tcsv":
+		w = timeline.NewEntryWriter(timeline.NewL2TCSVWriter(out, ""))
+	default:
+		tw, err := export.NewTemplateWriter(out, o.format)
+		if err != nil {
+			closeOutput()
+			return nil, nil, fail(exitCodeUserError, "Invalid output format: %v", err)
+		}
+		w = tw
+	}
+
+	if where != nil {
+		w = filteredWriter{Writer: w, f: where}
+	}
+	if resolver != nil {
+		w = pathWriter{Writer: w, r: resolver, paths: o.needsPaths() || (where != nil && where.Uses("path"))}
+	}
+
+	finish := func() error {
+		if err := w.Flush(); err != nil {
+			closeOutput()
+			return fail(exitCodeTechnicalError, "Unable to write output: %v", err)
+		}
+		if err := closeOutput(); err != nil {
+			return fail(exitCodeTechnicalError, "Unable to close output: %v", err)
+		}
+		return nil
+	}
+	return w, finish, nil
+}
+
+func openOutputFile(outfile string, overwrite bool) (*os.File, error) {
+	if overwrite {
+		return os.Create(outfile)
+	} else {
+		return os.OpenFile(outfile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+	}
+}
@@ -0,0 +1,182 @@
+package cli
+
+import (
+	"flag"
+	"io"
+	"time"
+
+	"github.com/t9t/gomft/fragment"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/permissions"
+	"github.com/t9t/gomft/pipeline"
+)
+
+type permsFlags struct {
+	output    string
+	force     bool
+	format    string
+	inUseOnly bool
+}
+
+// permissionsWriter is implemented by permissions.CSVWriter and permissions.JSONWriter.
+type permissionsWriter interface {
+	Write(e permissions.Entry) error
+	Flush() error
+}
+
+func init() {
+	flags := &permsFlags{}
+	register(&command{
+		name:    "perms",
+		args:    "<volume>",
+		summary: "List the owner and permissions of all files as CSV or JSON",
+		description: "List the owner, a summary of the access control list and the complete security descriptor (as SDDL)\n" +
+			"of each file on an NTFS volume, as CSV or JSON. The security descriptors are read from $Secure, so the\n" +
+			"input must be a volume rather than an MFT dump.",
+		example: func(exe string) string {
+			if isWin {
+				return exe + ` -u -o D:\perms.csv C:`
+			}
+			return exe + " -u -o ~/perms.csv /dev/sdb1"
+		},
+		flags: func(env *env, fs *flag.FlagSet) {
+			fs.StringVar(&flags.output, "o", "", "output; write output to this file instead of stdout")
+			fs.BoolVar(&flags.force, "f", false, "force; overwrite the output file if it already exists")
+			fs.StringVar(&flags.format, "format", "csv", "format; output format: csv or json")
+			fs.BoolVar(&flags.inUseOnly, "u", false, "in use; only list records which are in use")
+		},
+		run: func(env *env, fs *flag.FlagSet) error {
+			return runPerms(env, flags, fs.Args())
+		},
+	})
+}
+
+func runPerms(env *env, flags *permsFlags, args []string) error {
+	start := time.Now()
+	if len(args) != 1 {
+		return fail(exitCodeUserError, "Expected 1 argument but got %d", len(args))
+	}
+	if flags.format != "csv" && flags.format != "json" {
+		return fail(exitCodeUserError, "Invalid output format %q, expected csv or json", flags.format)
+	}
+
+	in, err := openInput(env, args[0])
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	volume, err := isVolume(in)
+	if err != nil {
+		return fail(exitCodeTechnicalError, "Unable to read input: %v", err)
+	}
+	if !volume {
+		return fail(exitCodeUserError, "%s is not an NTFS volume; an MFT dump does not contain the security descriptors in $Secure", args[0])
+	}
+	vm, err := locateMft(env, in)
+	if err != nil {
+		return err
+	}
+	descriptors, err := readSecureDescriptors(env, in, vm)
+	if err != nil {
+		return err
+	}
+
+	mftAt, recordSize, err := openMftAt(env, in, 0)
+	if err != nil {
+		return err
+	}
+	src, _, err := openMft(env, in, 0)
+	if err != nil {
+		return err
+	}
+
+	var out io.Writer = env.stdout
+	closeOutput := func() error { return nil }
+	if flags.output != "" {
+		f, err := openOutputFile(flags.output, flags.force)
+		if err != nil {
+			return fail(exitCodeFunctionalError, "Unable to open output file: %v", err)
+		}
+		out = f
+		closeOutput = f.Close
+	}
+	var w permissionsWriter = permissions.NewCSVWriter(out)
+	if flags.format == "json" {
+		w = permissions.NewJSONWriter(out)
+	}
+
+	opts := pipeline.Options{
+		ParseAll: mft.ParseAllOptions{RecordSize: recordSize, Parse: mft.ParseOptions{Logger: env.logger()}, Metrics: env.metrics()},
+		ErrorHandler: func(index int, offset int64, err error) {
+			env.printVerbose("Unable to parse record at offset %d: %v\n", offset, err)
+		},
+	}
+	stats, err := pipeline.Run(src, opts,
+		pipeline.Filter(func(item *pipeline.Item) bool { return item.Entry.InUse || !flags.inUseOnly }),
+		pipeline.Paths(pipeline.NewPathResolver(mftAt, recordSize, 0)),
+		permissions.Stage(descriptors, w.Write))
+	if err != nil {
+		closeOutput()
+		return fail(exitCodeTechnicalError, "Unable to list permissions: %v", err)
+	}
+	if err := w.Flush(); err != nil {
+		closeOutput()
+		return fail(exitCodeTechnicalError, "Unable to write output: %v", err)
+	}
+	if err := closeOutput(); err != nil {
+		return fail(exitCodeTechnicalError, "Unable to close output: %v", err)
+	}
+
+	env.printVerbose("Listed the permissions of %d records in %v\n", stats.Passed, time.Since(start))
+	return nil
+}
+
+// readSecureDescriptors reads the $SDS stream of the $Secure record of the volume and loads its security descriptors.
+func readSecureDescriptors(env *env, in io.ReaderAt, vm volumeMft) (*permissions.Descriptors, error) {
+	env.printVerbose("Reading $Secure record\n")
+	data := make([]byte, vm.recordSize)
+	if _, err := fragment.NewReaderAt(in, vm.fragments).ReadAt(data, int64(mft.SecureRecordNumber)*int64(vm.recordSize)); err != nil {
+		return nil, fail(exitCodeTechnicalError, "Unable to read $Secure record: %v", err)
+	}
+	record, err := mft.ParseRecordWithOptions(data, mft.ParseOptions{Logger: env.logger()})
+	if err != nil {
+		return nil, fail(exitCodeFunctionalError, "Unable to parse $Secure record: %v", err)
+	}
+
+	var sds *mft.Attribute
+	for _, a := range record.FindAttributes(mft.AttributeTypeData) {
+		if a.Name == mft.SecureDescriptorStreamName {
+			sds = &a
+			break
+		}
+	}
+	if sds == nil {
+		return nil, fail(exitCodeFunctionalError, "No %s stream found in $Secure record", mft.SecureDescriptorStreamName)
+	}
+
+	var stream []byte
+	if sds.Resident {
+		stream = sds.Data
+	} else {
+		runs, err := mft.ParseDataRuns(sds.Data)
+		if err != nil {
+			return nil, fail(exitCodeFunctionalError, "Unable to parse dataruns of %s: %v", mft.SecureDescriptorStreamName, err)
+		}
+		r := fragment.NewReaderAt(in, mft.DataRunsToFragments(runs, vm.bytesPerCluster))
+		if uint64(r.Size()) < sds.ActualSize {
+			return nil, fail(exitCodeFunctionalError, "Dataruns of %s cover %d bytes, but the stream is %d bytes", mft.SecureDescriptorStreamName, r.Size(), sds.ActualSize)
+		}
+		env.printVerbose("Reading %d bytes of %s\n", sds.ActualSize, mft.SecureDescriptorStreamName)
+		stream = make([]byte, sds.ActualSize)
+		if n, err := r.ReadAt(stream, 0); n < len(stream) {
+			return nil, fail(exitCodeTechnicalError, "Unable to read %s: %v", mft.SecureDescriptorStreamName, err)
+		}
+	}
+
+	descriptors, err := permissions.LoadDescriptors(stream)
+	if err != nil {
+		return nil, fail(exitCodeFunctionalError, "Unable to parse %s: %v", mft.SecureDescriptorStreamName, err)
+	}
+	env.printVerbose("Loaded %d security descriptors\n", descriptors.Len())
+	return descriptors, nil
+}
@@ -0,0 +1,133 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/t9t/gomft/fsctl"
+	"github.com/t9t/gomft/istat"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/pretty"
+	"github.com/t9t/gomft/recordfmt"
+)
+
+type statFlags struct {
+	recordSize int
+	runList    bool
+	timeZone   string
+	fsctl      bool
+	format     string
+}
+
+func init() {
+	flags := &statFlags{}
+	register(&command{
+		name:    "stat",
+		args:    "<volume or MFT dump> <record number>",
+		summary: "Print the details of a record like The Sleuth Kit's istat",
+		description: "Print the details of a single MFT record, such as its attributes, times and clusters, in the format of\n" +
+			"istat of The Sleuth Kit. When the input is not an NTFS volume, it is assumed to be an MFT dump. Use -format text\n" +
+			"to print all attributes decoded as an indented block of text instead, or -format with a Go text/template to\n" +
+			"print only what you need.",
+		example: func(exe string) string {
+			if isWin {
+				return exe + ` C: 5`
+			}
+			return exe + " /dev/sdb1 5"
+		},
+		flags: func(env *env, fs *flag.FlagSet) {
+			fs.IntVar(&flags.recordSize, "r", 1024, "record size; size of an MFT record in bytes when reading an MFT dump")
+			fs.BoolVar(&flags.runList, "run-list", false, "run list; print the runs of non-resident attributes instead of every cluster")
+			fs.StringVar(&flags.timeZone, "z", "UTC", "time zone; print times in this time zone, eg. Europe/Amsterdam or Local")
+			fs.StringVar(&flags.format, "format", "istat", "format; the output format: istat, text (all attributes decoded), or a Go text/template such as '{{name .}} {{flags .Flags}}'")
+			fs.BoolVar(&flags.fsctl, "fsctl", false, "fsctl; read the record of a live volume through the file system driver (Windows only)")
+		},
+		run: func(env *env, fs *flag.FlagSet) error {
+			return runStat(env, flags, fs.Args())
+		},
+	})
+}
+
+func runStat(env *env, flags *statFlags, args []string) error {
+	if len(args) != 2 {
+		return fail(exitCodeUserError, "Expected 2 arguments but got %d", len(args))
+	}
+	if flags.recordSize <= 0 {
+		return fail(exitCodeUserError, "Record size should be positive but is %d", flags.recordSize)
+	}
+	number, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil {
+		return fail(exitCodeUserError, "Invalid record number %q", args[1])
+	}
+	loc, err := time.LoadLocation(flags.timeZone)
+	if err != nil {
+		return fail(exitCodeUserError, "Unknown time zone %q: %v", flags.timeZone, err)
+	}
+	var tmpl *recordfmt.Template
+	if flags.format != "istat" && flags.format != "text" {
+		tmpl, err = recordfmt.New(flags.format, recordfmt.Options{Location: loc})
+		if err != nil {
+			return fail(exitCodeUserError, "Invalid output format: %v", err)
+		}
+	}
+
+	record, err := readStatRecord(env, flags, args[0], number)
+	if err != nil {
+		return err
+	}
+	switch flags.format {
+	case "istat":
+		err = istat.Write(env.stdout, record, istat.Options{RunList: flags.runList, Location: loc})
+	case "text":
+		err = pretty.Write(env.stdout, record, pretty.Options{Location: loc})
+	default:
+		if err = tmpl.Execute(env.stdout, record); err == nil {
+			_, err = fmt.Fprintln(env.stdout)
+		}
+	}
+	if err != nil {
+		return fail(exitCodeTechnicalError, "Unable to write output: %v", err)
+	}
+	return nil
+}
+
+// readStatRecord reads and parses a record, either from the volume or dump, or through the file system driver.
+func readStatRecord(env *env, flags *statFlags, name string, number uint64) (mft.Record, error) {
+	if flags.fsctl {
+		env.printVerbose("Opening %s\n", name)
+		v, err := fsctl.Open(name)
+		if err != nil {
+			return mft.Record{}, fail(exitCodeTechnicalError, "Unable to open %s: %v", name, err)
+		}
+		defer v.Close()
+		env.printVerbose("Reading record %d through the file system driver\n", number)
+		record, err := v.Record(number)
+		if err != nil {
+			return mft.Record{}, fail(exitCodeFunctionalError, "Unable to read record %d: %v", number, err)
+		}
+		return record, nil
+	}
+
+	in, err := openInput(env, name)
+	if err != nil {
+		return mft.Record{}, err
+	}
+	defer in.Close()
+	mftAt, recordSize, err := openMftAt(env, in, flags.recordSize)
+	if err != nil {
+		return mft.Record{}, err
+	}
+
+	env.printVerbose("Reading record %d\n", number)
+	data := make([]byte, recordSize)
+	if _, err := mftAt.ReadAt(data, int64(number)*int64(recordSize)); err != nil {
+		return mft.Record{}, fail(exitCodeFunctionalError, "Unable to read record %d: %v", number, err)
+	}
+	record, err := mft.ParseRecordWithOptions(data, mft.ParseOptions{Logger: env.logger()})
+	if err != nil {
+		return mft.Record{}, fail(exitCodeFunctionalError, "Unable to parse record %d: %v", number, err)
+	}
+	return record, nil
+}
@@ -0,0 +1,164 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/t9t/gomft/fragment"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/pipeline"
+	"github.com/t9t/gomft/recovery"
+)
+
+type undeleteFlags struct {
+	recordSize int
+	paths      bool
+}
+
+func init() {
+	flags := &undeleteFlags{}
+	register(&command{
+		name:    "undelete",
+		args:    "<volume or MFT dump>",
+		summary: "List deleted files and how much of their data can be recovered",
+		description: "List the deleted files, like ntfsundelete, with an estimate of how much of their data is still present:\n" +
+			"the clusters of each deleted file are checked against the $Bitmap of the volume and the clusters of the files\n" +
+			"in use. Each file is marked as intact, partially overwritten or reallocated, with the percentage of its\n" +
+			"clusters which have not been reused. An MFT dump does not contain the $Bitmap, so its deleted files are only\n" +
+			"checked against the files in use.",
+		example: func(exe string) string {
+			if isWin {
+				return exe + " C:"
+			}
+			return exe + " /dev/sdb1"
+		},
+		flags: func(env *env, fs *flag.FlagSet) {
+			fs.IntVar(&flags.recordSize, "r", 1024, "record size; size of an MFT record in bytes when reading an MFT dump")
+			fs.BoolVar(&flags.paths, "paths", true, "paths; print full paths instead of only file names")
+		},
+		run: func(env *env, fs *flag.FlagSet) error {
+			return runUndelete(env, flags, fs.Args())
+		},
+	})
+}
+
+func runUndelete(env *env, flags *undeleteFlags, args []string) error {
+	start := time.Now()
+	if len(args) != 1 {
+		return fail(exitCodeUserError, "Expected 1 argument but got %d", len(args))
+	}
+	if flags.recordSize <= 0 {
+		return fail(exitCodeUserError, "Record size should be positive but is %d", flags.recordSize)
+	}
+
+	in, err := openInput(env, args[0])
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	volume, err := isVolume(in)
+	if err != nil {
+		return fail(exitCodeTechnicalError, "Unable to read input: %v", err)
+	}
+	var bitmap mft.Bitmap
+	if volume {
+		vm, err := locateMft(env, in)
+		if err != nil {
+			return err
+		}
+		if bitmap, err = readClusterBitmap(env, in, vm); err != nil {
+			return err
+		}
+	} else {
+		env.printVerbose("Input is an MFT dump; only checking against the files in use\n")
+	}
+
+	mftAt, recordSize, err := openMftAt(env, in, flags.recordSize)
+	if err != nil {
+		return err
+	}
+	src, _, err := openMft(env, in, flags.recordSize)
+	if err != nil {
+		return err
+	}
+
+	assessor := recovery.NewAssessor(bitmap)
+	names := make(map[uint64]string)
+	stages := []pipeline.Stage{pipeline.StageFunc(func(item *pipeline.Item) (bool, error) {
+		if err := assessor.Add(uint64(item.Index), item.Record); err != nil {
+			env.printVerbose("%v\n", err)
+		}
+		return !item.Entry.InUse && !item.Record.IsExtension(), nil
+	})}
+	if flags.paths {
+		stages = append(stages, pipeline.Paths(pipeline.NewPathResolver(mftAt, recordSize, 0)))
+	}
+	stages = append(stages, pipeline.StageFunc(func(item *pipeline.Item) (bool, error) {
+		e := item.Entry
+		e.Path = item.Path
+		names[uint64(item.Index)] = diffName(e)
+		return true, nil
+	}))
+
+	opts := pipeline.Options{
+		ParseAll: mft.ParseAllOptions{RecordSize: recordSize, SkipEmpty: true, Parse: mft.ParseOptions{Logger: env.logger()}, Metrics: env.metrics()},
+		ErrorHandler: func(index int, offset int64, err error) {
+			env.printVerbose("Unable to parse record at offset %d: %v\n", offset, err)
+		},
+	}
+	stats, err := pipeline.Run(src, opts, stages...)
+	if err != nil {
+		return fail(exitCodeTechnicalError, "Unable to list deleted files: %v", err)
+	}
+
+	assessments := assessor.Assess()
+	for _, a := range assessments {
+		name, ok := names[a.Reference.RecordNumber]
+		if !ok {
+			// Only an extension record of the file was found
+			name = "?"
+		}
+		if _, err := fmt.Fprintf(env.stdout, "%d-%d\t%-21v %3.0f%%  %s\n", a.Reference.RecordNumber,
+			a.Reference.SequenceNumber, a.Confidence, a.Recoverable()*100, name); err != nil {
+			return fail(exitCodeTechnicalError, "Unable to write output: %v", err)
+		}
+	}
+
+	env.printVerbose("Assessed %d deleted files of %d records in %v\n", len(assessments), stats.Records, time.Since(start))
+	return nil
+}
+
+// readClusterBitmap reads the $DATA attribute of the $Bitmap record of the volume.
+func readClusterBitmap(env *env, in io.ReaderAt, vm volumeMft) (mft.Bitmap, error) {
+	env.printVerbose("Reading $Bitmap record\n")
+	data := make([]byte, vm.recordSize)
+	if _, err := fragment.NewReaderAt(in, vm.fragments).ReadAt(data, int64(mft.BitmapRecordNumber)*int64(vm.recordSize)); err != nil {
+		return nil, fail(exitCodeTechnicalError, "Unable to read $Bitmap record: %v", err)
+	}
+	record, err := mft.ParseRecordWithOptions(data, mft.ParseOptions{Logger: env.logger()})
+	if err != nil {
+		return nil, fail(exitCodeFunctionalError, "Unable to parse $Bitmap record: %v", err)
+	}
+
+	attributes := record.FindAttributes(mft.AttributeTypeData)
+	if len(attributes) == 0 {
+		return nil, fail(exitCodeFunctionalError, "No $DATA attribute found in $Bitmap record")
+	}
+	bitmap, err := readAttributeData(env, in, vm, attributes[0], "$Bitmap")
+	return mft.Bitmap(bitmap), err
+}
+
+// readAttributeData returns the data of a resident attribute, or reads the data of a non-resident attribute from the
+// volume. The name is used in messages.
+func readAttributeData(env *env, in io.ReaderAt, vm volumeMft, a mft.Attribute, name string) ([]byte, error) {
+	if !a.Resident {
+		env.printVerbose("Reading %d bytes of %s\n", a.ActualSize, name)
+	}
+	data, err := mft.ReadAttributeData(a, in, vm.bytesPerCluster)
+	if err != nil {
+		return nil, fail(exitCodeFunctionalError, "Unable to read %s: %v", name, err)
+	}
+	return data, nil
+}
@@ -0,0 +1,180 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/t9t/gomft/bootsect"
+	"github.com/t9t/gomft/fragment"
+	"github.com/t9t/gomft/httprange"
+	"github.com/t9t/gomft/metrics"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/mmap"
+	"github.com/t9t/gomft/volume"
+)
+
+// volumeMft contains the location of the MFT on a volume, as obtained from the boot sector and the $MFT record.
+type volumeMft struct {
+	volume          *volume.Volume
+	bootSector      bootsect.BootSector
+	bytesPerCluster int
+	recordSize      int
+	record          mft.Record
+	fragments       []fragment.Fragment
+	totalLength     int64
+}
+
+// volumePath translates a volume name as given on the command line to a path which can be opened. On Windows, a drive
+// letter such as C: is translated to the UNC path \\.\C: of the raw volume.
+func volumePath(volume string) string {
+	if isWin && len(volume) == 2 && volume[1] == ':' {
+		return `\\.\` + volume
+	}
+	return volume
+}
+
+// input is a volume, image or dump file opened for reading.
+type input interface {
+	io.ReaderAt
+	io.Closer
+}
+
+// remoteInput is an input read over HTTP(S), which needs no closing.
+type remoteInput struct {
+	*httprange.ReaderAt
+}
+
+func (remoteInput) Close() error {
+	return nil
+}
+
+// meteredInput is an input reporting the reads to the metrics of the env.
+type meteredInput struct {
+	r  io.ReaderAt
+	in input
+}
+
+func (m meteredInput) ReadAt(p []byte, off int64) (int, error) {
+	return m.r.ReadAt(p, off)
+}
+
+func (m meteredInput) Close() error {
+	return m.in.Close()
+}
+
+// inputFile returns the *os.File of the input, if it is a local file.
+func inputFile(in input) (*os.File, bool) {
+	if m, ok := in.(meteredInput); ok {
+		in = m.in
+	}
+	f, ok := in.(*os.File)
+	return f, ok
+}
+
+// openInput opens a volume, image or dump file for reading. Names starting with http:// or https:// are read from a
+// web server using Range requests. When the stats flag is set, the reads are counted.
+func openInput(env *env, name string) (input, error) {
+	var in input
+	if strings.HasPrefix(name, "http://") || strings.HasPrefix(name, "https://") {
+		env.printVerbose("Opening %s using range requests\n", name)
+		r, err := httprange.Open(name, httprange.Options{Metrics: env.metrics()})
+		if err != nil {
+			return nil, fail(exitCodeTechnicalError, "Unable to open %s: %v", name, err)
+		}
+		in = remoteInput{r}
+	} else {
+		path := volumePath(name)
+		env.printVerbose("Opening %s\n", path)
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fail(exitCodeTechnicalError, "Unable to open %s: %v", path, err)
+		}
+		in = f
+	}
+	if rec := env.metrics(); rec != nil {
+		return meteredInput{r: metrics.ReaderAt(in, rec), in: in}, nil
+	}
+	return in, nil
+}
+
+// openMapped maps a volume, image or dump file into memory for reading.
+func openMapped(env *env, name string) (*mmap.File, error) {
+	path := volumePath(name)
+	env.printVerbose("Mapping %s into memory\n", path)
+	f, err := mmap.Open(path)
+	if err != nil {
+		return nil, fail(exitCodeTechnicalError, "Unable to open %s: %v", path, err)
+	}
+	return f, nil
+}
+
+// isVolume checks if in starts with an NTFS boot sector.
+func isVolume(in io.ReaderAt) (bool, error) {
+	return volume.IsNTFS(in)
+}
+
+// locateMft reads the boot sector and $MFT record of the volume and returns where the MFT data is located.
+func locateMft(env *env, in io.ReaderAt) (volumeMft, error) {
+	env.printVerbose("Reading boot sector and $MFT file record\n")
+	v, err := volume.Open(in, volume.Options{Parse: mft.ParseOptions{Logger: env.logger()}})
+	if err != nil {
+		if err == volume.ErrNotNTFS {
+			return volumeMft{}, fail(exitCodeFunctionalError, "Unknown OemId (file system type) (expected %q)", volume.OemId)
+		}
+		if runsErr, ok := err.(*volume.InvalidDataRunsError); ok {
+			for _, w := range runsErr.Warnings {
+				fmt.Fprintf(env.stderr, "Invalid $MFT %v\n", w)
+			}
+			return volumeMft{}, fail(exitCodeFunctionalError, "Found %d invalid dataruns in $MFT $DATA record", len(runsErr.Warnings))
+		}
+		return volumeMft{}, fail(exitCodeTechnicalError, "Unable to locate MFT: %v", err)
+	}
+	env.printVerbose("MFT is %d bytes in %d fragments, record size is %d bytes\n", v.MftSize(), len(v.MftFragments()), v.RecordSize())
+
+	return volumeMft{
+		volume:          v,
+		bootSector:      v.BootSector(),
+		bytesPerCluster: v.BytesPerCluster(),
+		recordSize:      v.RecordSize(),
+		record:          v.MftRecord(),
+		fragments:       v.MftFragments(),
+		totalLength:     v.MftSize(),
+	}, nil
+}
+
+// openMft returns a reader over the MFT data in the input, which can either be a volume (or image of a volume) or an
+// MFT dump file. For a volume, the record size is taken from the boot sector; for a dump file, the specified
+// dumpRecordSize is returned. Each call returns a new reader, starting at the start of the MFT.
+func openMft(env *env, in io.ReaderAt, dumpRecordSize int) (io.Reader, int, error) {
+	mftAt, recordSize, err := openMftAt(env, in, dumpRecordSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	if sized, ok := mftAt.(interface{ Size() int64 }); ok {
+		return io.NewSectionReader(mftAt, 0, sized.Size()), recordSize, nil
+	}
+	// A dump file is read until its end
+	return io.NewSectionReader(mftAt, 0, math.MaxInt64), recordSize, nil
+}
+
+// openMftAt returns an io.ReaderAt over the MFT data in the input and the record size, like openMft does for reading
+// the MFT data sequentially.
+func openMftAt(env *env, in io.ReaderAt, dumpRecordSize int) (io.ReaderAt, int, error) {
+	isVol, err := isVolume(in)
+	if err != nil {
+		return nil, 0, fail(exitCodeTechnicalError, "Unable to read input: %v", err)
+	}
+	if !isVol {
+		env.printVerbose("Input is not an NTFS volume, reading it as MFT dump with record size %d\n", dumpRecordSize)
+		return in, dumpRecordSize, nil
+	}
+	env.printVerbose("Input is an NTFS volume, locating MFT\n")
+	vm, err := locateMft(env, in)
+	if err != nil {
+		return nil, 0, err
+	}
+	return vm.volume.MFT(), vm.recordSize, nil
+}
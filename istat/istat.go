@@ -0,0 +1,242 @@
+/*
+	Package istat formats an MFT record as text closely matching the output of "istat" of The Sleuth Kit (TSK) for NTFS,
+	so analysts familiar with TSK can read the output, and results of gomft and TSK can be compared using diff.
+
+	Basic usage
+
+	Pass a parsed record to Write.
+			// Error handling left out for brevity
+			record, err := mft.ParseRecord(b)
+			err = istat.Write(os.Stdout, record, istat.Options{})
+
+	Implementation notes
+
+	The output consists of the same sections as that of istat: the record header, the values of the
+	$STANDARD_INFORMATION and $FILE_NAME attributes, and a list of all attributes with the clusters of non-resident
+	attributes. Like istat, all times are printed with 100 nanosecond precision, in UTC unless another Location is set,
+	and the clusters of sparse runs are listed as cluster 0.
+
+	There are some differences, since only the record itself is available: the SID belonging to the security ID is not
+	looked up in $Secure (so it is printed as "()"), attributes in extension records are not included, and the
+	initialized size of non-resident attributes ("init_size") is not printed because it is not parsed by the mft
+	package.
+*/
+package istat
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/t9t/gomft/mft"
+)
+
+// Options control the output of Write.
+type Options struct {
+	// RunList prints the runs of non-resident attributes as a starting cluster and length, like istat -r, instead of
+	// listing every cluster.
+	RunList bool
+	// Location is the time zone in which times are printed, like istat -z. When nil, UTC is used.
+	Location *time.Location
+}
+
+// fileAttributeNames contains the names used by TSK for the file attribute flags, in the order TSK prints them.
+var fileAttributeNames = []struct {
+	flag mft.FileAttribute
+	name string
+}{
+	{mft.FileAttributeDirectory, "Directory"},
+	{mft.FileAttributeIndexView, "Index View"},
+	{mft.FileAttributeReadOnly, "Read Only"},
+	{mft.FileAttributeHidden, "Hidden"},
+	{mft.FileAttributeSystem, "System"},
+	{mft.FileAttributeArchive, "Archive"},
+	{mft.FileAttributeDevice, "Device"},
+	{mft.FileAttributeNormal, "Normal"},
+	{mft.FileAttributeTemporary, "Temporary"},
+	{mft.FileAttributeSparseFile, "Sparse"},
+	{mft.FileAttributeReparsePoint, "Reparse Point"},
+	{mft.FileAttributeCompressed, "Compressed"},
+	{mft.FileAttributeOffline, "Offline"},
+	{mft.FileAttributeNotContentIndexed, "Not Content Indexed"},
+	{mft.FileAttributeEncrypted, "Encrypted"},
+}
+
+// Write writes the details of the record to w, in the format of istat.
+func Write(w io.Writer, r mft.Record, opts Options) error {
+	loc := opts.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	bw := bufio.NewWriter(w)
+	writeHeader(bw, r)
+	for _, a := range r.FindAttributes(mft.AttributeTypeStandardInformation) {
+		writeStandardInformation(bw, a, loc)
+	}
+	for _, a := range r.FindAttributes(mft.AttributeTypeFileName) {
+		writeFileName(bw, a, loc)
+	}
+
+	bw.WriteString("\nAttributes: \n")
+	for _, a := range r.Attributes {
+		writeAttribute(bw, a, opts)
+	}
+	return bw.Flush()
+}
+
+func writeHeader(bw *bufio.Writer, r mft.Record) {
+	bw.WriteString("MFT Entry Header Values:\n")
+	fmt.Fprintf(bw, "Entry: %d        Sequence: %d\n", r.FileReference.RecordNumber, r.FileReference.SequenceNumber)
+	if r.BaseRecordReference.RecordNumber != 0 {
+		fmt.Fprintf(bw, "Base File Record: %d\n", r.BaseRecordReference.RecordNumber)
+	}
+	fmt.Fprintf(bw, "$LogFile Sequence Number: %d\n", r.LogFileSequenceNumber)
+	allocated, kind := "Not Allocated", "File"
+	if r.Flags.Is(mft.RecordFlagInUse) {
+		allocated = "Allocated"
+	}
+	if r.Flags.Is(mft.RecordFlagIsDirectory) {
+		kind = "Directory"
+	}
+	fmt.Fprintf(bw, "%s %s\n", allocated, kind)
+	fmt.Fprintf(bw, "Links: %d\n", r.HardLinkCount)
+}
+
+func writeStandardInformation(bw *bufio.Writer, a mft.Attribute, loc *time.Location) {
+	bw.WriteString("\n$STANDARD_INFORMATION Attribute Values:\n")
+	si, err := mft.ParseStandardInformation(a.Data)
+	if err != nil {
+		fmt.Fprintf(bw, "Error parsing attribute: %v\n", err)
+		return
+	}
+	fmt.Fprintf(bw, "Flags: %s\n", formatFlags(si.FileAttributes))
+	if len(a.Data) > 0x30 {
+		// Fields added in NTFS 3.0
+		fmt.Fprintf(bw, "Owner ID: %d\n", si.OwnerId)
+		fmt.Fprintf(bw, "Security ID: %d  ()\n", si.SecurityId)
+		if si.QuotaCharged != 0 {
+			fmt.Fprintf(bw, "Quota Charged: %d\n", si.QuotaCharged)
+		}
+		fmt.Fprintf(bw, "Last User Journal Update Sequence Number: %d\n", si.UpdateSequenceNumber)
+	}
+	writeTimes(bw, si.RawTimes, loc)
+}
+
+func writeFileName(bw *bufio.Writer, a mft.Attribute, loc *time.Location) {
+	bw.WriteString("\n$FILE_NAME Attribute Values:\n")
+	fn, err := mft.ParseFileName(a.Data)
+	if err != nil {
+		fmt.Fprintf(bw, "Error parsing attribute: %v\n", err)
+		return
+	}
+	fmt.Fprintf(bw, "Flags: %s\n", formatFlags(fn.Flags))
+	fmt.Fprintf(bw, "Name: %s\n", fn.Name)
+	fmt.Fprintf(bw, "Parent MFT Entry: %d \tSequence: %d\n", fn.ParentFileReference.RecordNumber, fn.ParentFileReference.SequenceNumber)
+	fmt.Fprintf(bw, "Allocated Size: %d   \tActual Size: %d\n", fn.AllocatedSize, fn.ActualSize)
+	writeTimes(bw, fn.RawTimes, loc)
+}
+
+func writeTimes(bw *bufio.Writer, times mft.RawTimes, loc *time.Location) {
+	fmt.Fprintf(bw, "Created:\t%s\n", formatTime(times.Creation, loc))
+	fmt.Fprintf(bw, "File Modified:\t%s\n", formatTime(times.FileLastModified, loc))
+	fmt.Fprintf(bw, "MFT Modified:\t%s\n", formatTime(times.MftLastModified, loc))
+	fmt.Fprintf(bw, "Accessed:\t%s\n", formatTime(times.LastAccess, loc))
+}
+
+// formatTime formats a "file time" like TSK does, which prints a value of 0 as a time of all zeroes.
+func formatTime(fileTime uint64, loc *time.Location) string {
+	if fileTime == 0 {
+		return "0000-00-00 00:00:00 (UTC)"
+	}
+	return mft.ConvertFileTime(fileTime).In(loc).Format("2006-01-02 15:04:05.000000000 (MST)")
+}
+
+func formatFlags(attributes mft.FileAttribute) string {
+	names := make([]string, 0)
+	for _, n := range fileAttributeNames {
+		if attributes.Is(n.flag) {
+			names = append(names, n.name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+func writeAttribute(bw *bufio.Writer, a mft.Attribute, opts Options) {
+	name := a.Name
+	if name == "" {
+		name = "N/A"
+	}
+	resident := "Resident"
+	size := uint64(len(a.Data))
+	if !a.Resident {
+		resident = "Non-Resident"
+		size = a.ActualSize
+	}
+	if a.Flags&mft.AttributeFlagsEncrypted != 0 {
+		resident += ", Encrypted"
+	}
+	if a.Flags&mft.AttributeFlagsCompressed != 0 {
+		resident += ", Compressed"
+	}
+	if a.Flags&mft.AttributeFlagsSparse != 0 {
+		resident += ", Sparse"
+	}
+	fmt.Fprintf(bw, "Type: %s (%d-%d)   Name: %s   %s   size: %d", a.Type.Name(), uint32(a.Type), a.AttributeId, name, resident, size)
+	if a.Resident {
+		bw.WriteString("\n")
+		return
+	}
+	fmt.Fprintf(bw, "  init_size: %d\n", a.InitializedSize)
+
+	runs, err := mft.ParseDataRuns(a.Data)
+	if err != nil {
+		fmt.Fprintf(bw, "Error parsing run list: %v\n", err)
+		return
+	}
+	if opts.RunList {
+		writeRunList(bw, runs)
+	} else {
+		writeClusters(bw, runs)
+	}
+}
+
+// writeRunList writes the starting cluster and length of each run; sparse runs are marked as such.
+func writeRunList(bw *bufio.Writer, runs []mft.DataRun) {
+	cluster := int64(0)
+	for _, run := range runs {
+		if run.Sparse {
+			fmt.Fprintf(bw, "Starting address: 0, length: %d  Sparse\n", run.LengthInClusters)
+			continue
+		}
+		cluster += run.OffsetCluster
+		fmt.Fprintf(bw, "Starting address: %d, length: %d\n", cluster, run.LengthInClusters)
+	}
+}
+
+// writeClusters writes the numbers of all clusters of the runs, 8 per line. Like Sleuth Kit, the clusters of sparse
+// runs are written as 0.
+func writeClusters(bw *bufio.Writer, runs []mft.DataRun) {
+	cluster := int64(0)
+	count := 0
+	for _, run := range runs {
+		if !run.Sparse {
+			cluster += run.OffsetCluster
+		}
+		for c := uint64(0); c < run.LengthInClusters; c++ {
+			if run.Sparse {
+				bw.WriteString("0 ")
+			} else {
+				fmt.Fprintf(bw, "%d ", cluster+int64(c))
+			}
+			count++
+			if count%8 == 0 {
+				bw.WriteString("\n")
+			}
+		}
+	}
+	if count%8 != 0 {
+		bw.WriteString("\n")
+	}
+}
@@ -0,0 +1,100 @@
+package istat_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/istat"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/mfttest"
+)
+
+func TestWrite(t *testing.T) {
+	buf := &bytes.Buffer{}
+	require.Nil(t, istat.Write(buf, mfttest.SampleFileRecord().Record(), istat.Options{}))
+	assert.Equal(t, `MFT Entry Header Values:
+Entry: 64        Sequence: 2
+$LogFile Sequence Number: 1086584
+Allocated File
+Links: 1
+
+$STANDARD_INFORMATION Attribute Values:
+Flags: Hidden, Archive
+Owner ID: 0
+Security ID: 265  ()
+Last User Journal Update Sequence Number: 4711
+Created:	2020-02-05 14:59:38.116886200 (UTC)
+File Modified:	2020-02-05 14:59:38.116886200 (UTC)
+MFT Modified:	2020-02-05 14:59:38.116886200 (UTC)
+Accessed:	0000-00-00 00:00:00 (UTC)
+
+$FILE_NAME Attribute Values:
+Flags: Archive
+Name: test.txt
+Parent MFT Entry: 5 	Sequence: 5
+Allocated Size: 16384   	Actual Size: 12345
+Created:	2020-02-05 14:59:38.116886200 (UTC)
+File Modified:	2020-02-05 14:59:38.116886200 (UTC)
+MFT Modified:	2020-02-05 14:59:38.116886200 (UTC)
+Accessed:	2020-02-05 14:59:38.116886200 (UTC)
+
+`+"Attributes: \n"+`Type: $STANDARD_INFORMATION (16-0)   Name: N/A   Resident   size: 72
+Type: $FILE_NAME (48-2)   Name: N/A   Resident   size: 82
+Type: $DATA (128-3)   Name: N/A   Non-Resident, Sparse   size: 12345  init_size: 10000
+`+"4158 4159 4160 4161 4162 4163 4164 4165 \n4166 0 0 4200 \n"+`Type: $DATA (128-4)   Name: Zone.Identifier   Resident   size: 24
+`, buf.String())
+}
+
+func TestWrite_RunListLocation(t *testing.T) {
+	loc := time.FixedZone("CET", 3600)
+	buf := &bytes.Buffer{}
+	require.Nil(t, istat.Write(buf, mfttest.SampleFileRecord().Record(), istat.Options{RunList: true, Location: loc}))
+	out := buf.String()
+	assert.Contains(t, out, "Created:\t2020-02-05 15:59:38.116886200 (CET)\n")
+	assert.Contains(t, out, "Type: $DATA (128-3)   Name: N/A   Non-Resident, Sparse   size: 12345  init_size: 10000\n"+
+		"Starting address: 4158, length: 9\n"+
+		"Starting address: 0, length: 2  Sparse\n"+
+		"Starting address: 4200, length: 1\n"+
+		"Type: $DATA (128-4)")
+}
+
+func TestWrite_LeadingSparse(t *testing.T) {
+	record := mfttest.SampleFileRecord().Record()
+	// 2 sparse clusters, 1 cluster at 4200
+	record.Attributes[2].Data = []byte{0x01, 0x02, 0x21, 0x01, 0x68, 0x10, 0x00}
+
+	buf := &bytes.Buffer{}
+	require.Nil(t, istat.Write(buf, record, istat.Options{}))
+	assert.Contains(t, buf.String(), "init_size: 10000\n0 0 4200 \n")
+
+	buf.Reset()
+	require.Nil(t, istat.Write(buf, record, istat.Options{RunList: true}))
+	assert.Contains(t, buf.String(), "init_size: 10000\n"+
+		"Starting address: 0, length: 2  Sparse\n"+
+		"Starting address: 4200, length: 1\n")
+}
+
+func TestWrite_Invalid(t *testing.T) {
+	record := mft.Record{Attributes: []mft.Attribute{
+		{Type: mft.AttributeTypeStandardInformation, Resident: true, Data: []byte{1, 2}},
+		{Type: mft.AttributeTypeData, Resident: false, Data: []byte{0x11, 0x01}},
+	}}
+	buf := &bytes.Buffer{}
+	require.Nil(t, istat.Write(buf, record, istat.Options{}))
+	assert.Equal(t, `MFT Entry Header Values:
+Entry: 0        Sequence: 0
+$LogFile Sequence Number: 0
+Not Allocated File
+Links: 0
+
+$STANDARD_INFORMATION Attribute Values:
+Error parsing attribute: expected at least 48 bytes but got 2
+
+`+"Attributes: \n"+`Type: $STANDARD_INFORMATION (16-0)   Name: N/A   Resident   size: 2
+Type: $DATA (128-0)   Name: N/A   Non-Resident   size: 0  init_size: 0
+Error parsing run list: expected at least 3 bytes of datarun data but is 2
+`, buf.String())
+}
@@ -0,0 +1,478 @@
+/*
+	Package ldm reads the Logical Disk Manager (LDM) database of Windows dynamic disks, to locate the volumes on them.
+	A dynamic disk has no partition table entries for its volumes, so the NTFS volumes in an image of a complete disk
+	cannot be found using the MBR alone. Instead, the disk has a private header and a database describing the volumes
+	of the disk group, the components (plexes) of each volume, and the partitions (subdisks) of each component on each
+	disk.
+
+	Basic usage
+
+	First read the private header of each disk of the disk group, then read the database from one of them (each disk
+	has a copy of the database of the group). A VolumeReader reads a volume from the disks it is on, and can be used
+	like an image of the volume; NTFS volumes have PartitionType 0x07.
+			// Error handling left out for brevity
+			f, err := os.Open("disk.img")
+			disk, err := ldm.OpenDisk(f, ldm.PrivateHeaderSector)
+			db, err := ldm.ReadDatabase(disk)
+			for _, v := range db.Volumes {
+				r, err := db.Open(v, disk)
+				boot := make([]byte, 512)
+				_, err = r.ReadAt(boot, 0)
+				fmt.Printf("%s (%s, %d bytes): %q\n", v.Name, v.Layout(), v.Size, boot[0x03:0x0B])
+			}
+
+	Implementation notes
+
+	The private header of an MBR disk (partition type 0x42) is in sector 6. On a GPT disk, it is in the last sector of
+	the LDM metadata partition, which should be located using the GPT. All values in the LDM structures are big endian,
+	and sectors are always 512 bytes.
+
+	The database is a list of fixed size VBLK records of which the (variable length) fields are parsed like the Linux
+	kernel does; records which do not fit into one VBLK are reassembled from their fragments. Only disk, volume,
+	component and partition records are used.
+
+	Simple, spanned and striped volumes are supported. Of a mirrored volume, the first component of which all disks are
+	available is read. RAID-5 volumes are not supported.
+
+	Storage Spaces are not supported, as their on-disk metadata is not documented.
+*/
+package ldm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// SectorSize is the size of the sectors in which the LDM structures are addressed.
+const SectorSize = 512
+
+// PrivateHeaderSector is the sector of the private header on a dynamic MBR disk.
+const PrivateHeaderSector = 6
+
+// vmdbSector is the sector of the VMDB (the start of the database) relative to the start of the configuration area.
+const vmdbSector = 17
+
+// Types of VBLK records.
+const (
+	vblkComponent = 0x32
+	vblkPartition = 0x33
+	vblkDisk3     = 0x34
+	vblkDisk4     = 0x44
+	vblkVolume    = 0x51
+)
+
+// Flags of VBLK records, indicating which optional fields are present.
+const (
+	flagPartitionIndex  = 0x08
+	flagComponentStripe = 0x10
+)
+
+// PrivateHeader is the PRIVHEAD structure of a dynamic disk. All locations and sizes are in sectors from the start of
+// the disk.
+type PrivateHeader struct {
+	VersionMajor     uint16
+	VersionMinor     uint16
+	DiskID           string // GUID of the disk, as referred to by Disk.DiskID
+	LogicalDiskStart uint64 // the start of the area containing the partitions
+	LogicalDiskSize  uint64
+	ConfigStart      uint64 // the start of the configuration area containing the database
+	ConfigSize       uint64
+}
+
+// ParsePrivateHeader parses the sector containing the private header.
+func ParsePrivateHeader(b []byte) (PrivateHeader, error) {
+	if len(b) < SectorSize {
+		return PrivateHeader{}, fmt.Errorf("expected at least %d bytes but got %d", SectorSize, len(b))
+	}
+	if !bytes.Equal(b[:8], []byte("PRIVHEAD")) {
+		return PrivateHeader{}, fmt.Errorf("invalid private header signature %q", b[:8])
+	}
+	be := binary.BigEndian
+	h := PrivateHeader{
+		VersionMajor:     be.Uint16(b[0x0C:]),
+		VersionMinor:     be.Uint16(b[0x0E:]),
+		DiskID:           strings.ToLower(string(bytes.TrimRight(b[0x30:0x70], "\x00"))),
+		LogicalDiskStart: be.Uint64(b[0x11B:]),
+		LogicalDiskSize:  be.Uint64(b[0x123:]),
+		ConfigStart:      be.Uint64(b[0x12B:]),
+		ConfigSize:       be.Uint64(b[0x133:]),
+	}
+	// Version 2.11 is Windows 2000 and XP, 2.12 is Vista and later
+	if h.VersionMajor != 2 || (h.VersionMinor != 11 && h.VersionMinor != 12) {
+		return PrivateHeader{}, fmt.Errorf("unsupported private header version %d.%d", h.VersionMajor, h.VersionMinor)
+	}
+	return h, nil
+}
+
+// A DiskReader is a dynamic disk: a reader over the complete disk (or an image of it) and its private header.
+type DiskReader struct {
+	io.ReaderAt
+	Header PrivateHeader
+}
+
+// OpenDisk reads the private header of the disk from the sector, such as PrivateHeaderSector.
+func OpenDisk(r io.ReaderAt, sector int64) (DiskReader, error) {
+	b := make([]byte, SectorSize)
+	if _, err := r.ReadAt(b, sector*SectorSize); err != nil {
+		return DiskReader{}, fmt.Errorf("unable to read private header: %v", err)
+	}
+	h, err := ParsePrivateHeader(b)
+	if err != nil {
+		return DiskReader{}, err
+	}
+	return DiskReader{ReaderAt: r, Header: h}, nil
+}
+
+// Database is the contents of the LDM database of a disk group.
+type Database struct {
+	Disks   []Disk
+	Volumes []Volume // in order of ObjectID
+}
+
+// Disk is a disk of the disk group.
+type Disk struct {
+	ObjectID uint64
+	Name     string // such as Disk1
+	DiskID   string // GUID of the disk, as in the PrivateHeader
+}
+
+// Volume is a volume of the disk group.
+type Volume struct {
+	ObjectID      uint64
+	Name          string // such as Volume1
+	Type          string // such as gen (for generic) or raid5
+	Size          uint64 // in bytes
+	PartitionType byte   // such as 0x07 for NTFS
+	GUID          string
+	Components    []Component // in order of ObjectID
+}
+
+// Layout returns the layout of the first component of the Volume, or LayoutUnknown when it has none.
+func (v Volume) Layout() Layout {
+	if len(v.Components) == 0 {
+		return LayoutUnknown
+	}
+	return v.Components[0].Layout
+}
+
+// Layout indicates how a component is laid out on its partitions.
+type Layout byte
+
+// Layouts of components.
+const (
+	LayoutUnknown Layout = 0
+	LayoutStriped Layout = 1 // data is spread over the partitions in stripes
+	LayoutSpanned Layout = 2 // the partitions are concatenated; a simple volume is a spanned volume of one partition
+	LayoutRAID5   Layout = 3 // striped with parity
+)
+
+func (l Layout) String() string {
+	switch l {
+	case LayoutStriped:
+		return "striped"
+	case LayoutSpanned:
+		return "spanned"
+	case LayoutRAID5:
+		return "RAID-5"
+	}
+	return fmt.Sprintf("unknown (%d)", byte(l))
+}
+
+// Component is a component (also known as plex) of a Volume. A mirrored volume has multiple components, each
+// containing a copy of the data.
+type Component struct {
+	ObjectID   uint64
+	Name       string
+	Layout     Layout
+	StripeSize uint64      // in bytes; only for striped and RAID-5 components
+	Columns    uint64      // the number of stripe columns; only for striped and RAID-5 components
+	Partitions []Partition // in order of Index and VolumeOffset
+}
+
+// Partition is a partition (also known as subdisk) of a Component: a range of a disk.
+type Partition struct {
+	ObjectID     uint64
+	Name         string
+	DiskObjectID uint64 // the ObjectID of the Disk the partition is on
+	Start        uint64 // in bytes, relative to the LogicalDiskStart of the disk
+	VolumeOffset uint64 // in bytes, the offset of the partition in the component, or within its column when striped
+	Size         uint64 // in bytes
+	Index        uint64 // the column of a striped component
+}
+
+// ReadDatabase reads the database from the configuration area of the disk.
+func ReadDatabase(disk DiskReader) (Database, error) {
+	vmdbOffset := int64(disk.Header.ConfigStart+vmdbSector) * SectorSize
+	vmdb := make([]byte, SectorSize)
+	if _, err := disk.ReadAt(vmdb, vmdbOffset); err != nil {
+		return Database{}, fmt.Errorf("unable to read VMDB: %v", err)
+	}
+	if !bytes.Equal(vmdb[:4], []byte("VMDB")) {
+		return Database{}, fmt.Errorf("invalid VMDB signature %q", vmdb[:4])
+	}
+	be := binary.BigEndian
+	lastSeq, vblkSize, vblkOffset := be.Uint32(vmdb[0x04:]), be.Uint32(vmdb[0x08:]), be.Uint32(vmdb[0x0C:])
+	if vblkSize < minVblkSize || vblkSize > SectorSize {
+		return Database{}, fmt.Errorf("invalid VBLK size %d", vblkSize)
+	}
+	end := int64(lastSeq) * int64(vblkSize)
+	if limit := int64(disk.Header.ConfigSize) * SectorSize; end > limit {
+		return Database{}, fmt.Errorf("VBLKs up to %d exceed configuration area of %d bytes", end, limit)
+	}
+	if end < int64(vblkOffset) {
+		end = int64(vblkOffset)
+	}
+	data := make([]byte, end-int64(vblkOffset))
+	if _, err := disk.ReadAt(data, vmdbOffset+int64(vblkOffset)); err != nil {
+		return Database{}, fmt.Errorf("unable to read VBLKs: %v", err)
+	}
+	return ParseDatabase(data, int(vblkSize))
+}
+
+// vblkHeaderSize is the size of the header of each VBLK, which is not repeated in the reassembled data of fragmented
+// records.
+const vblkHeaderSize = 0x10
+
+// minVblkSize is the size of the header and the fixed fields of each record.
+const minVblkSize = 0x18
+
+// ParseDatabase parses the consecutive VBLKs of a database, each of vblkSize bytes.
+func ParseDatabase(data []byte, vblkSize int) (Database, error) {
+	if vblkSize < minVblkSize {
+		return Database{}, fmt.Errorf("invalid VBLK size %d", vblkSize)
+	}
+	be := binary.BigEndian
+	records := make([][]byte, 0)
+	fragments := make(map[uint32][][]byte)
+	groups := make([]uint32, 0)
+	for offset := 0; offset+vblkSize <= len(data); offset += vblkSize {
+		b := data[offset : offset+vblkSize]
+		if !bytes.Equal(b[:4], []byte("VBLK")) {
+			return Database{}, fmt.Errorf("invalid VBLK signature %q at offset %d", b[:4], offset)
+		}
+		group, rec, num := be.Uint32(b[0x08:]), int(be.Uint16(b[0x0C:])), int(be.Uint16(b[0x0E:]))
+		switch {
+		case num == 1:
+			records = append(records, b)
+		case num > 1:
+			if rec >= num {
+				return Database{}, fmt.Errorf("VBLK fragment %d of %d in group %d", rec, num, group)
+			}
+			parts, ok := fragments[group]
+			if !ok {
+				parts = make([][]byte, num)
+				fragments[group] = parts
+				groups = append(groups, group)
+			}
+			if len(parts) != num {
+				return Database{}, fmt.Errorf("VBLK group %d has fragments of %d and %d parts", group, len(parts), num)
+			}
+			parts[rec] = b
+		}
+		// Records with 0 parts are not in use
+	}
+	for _, group := range groups {
+		parts := fragments[group]
+		record := append([]byte(nil), parts[0]...)
+		for i, part := range parts {
+			if part == nil {
+				return Database{}, fmt.Errorf("VBLK fragment %d of %d in group %d is missing", i, len(parts), group)
+			}
+			if i > 0 {
+				record = append(record, part[vblkHeaderSize:]...)
+			}
+		}
+		records = append(records, record)
+	}
+
+	db := Database{Disks: make([]Disk, 0), Volumes: make([]Volume, 0)}
+	components := make([]component, 0)
+	partitions := make([]partition, 0)
+	for _, b := range records {
+		switch b[0x13] {
+		case vblkDisk3, vblkDisk4:
+			d, err := parseDisk(b)
+			if err != nil {
+				return Database{}, fmt.Errorf("unable to parse disk record: %v", err)
+			}
+			db.Disks = append(db.Disks, d)
+		case vblkVolume:
+			v, err := parseVolume(b)
+			if err != nil {
+				return Database{}, fmt.Errorf("unable to parse volume record: %v", err)
+			}
+			db.Volumes = append(db.Volumes, v)
+		case vblkComponent:
+			c, err := parseComponent(b)
+			if err != nil {
+				return Database{}, fmt.Errorf("unable to parse component record: %v", err)
+			}
+			components = append(components, c)
+		case vblkPartition:
+			p, err := parsePartition(b)
+			if err != nil {
+				return Database{}, fmt.Errorf("unable to parse partition record: %v", err)
+			}
+			partitions = append(partitions, p)
+		}
+	}
+
+	sort.Slice(db.Disks, func(i, j int) bool { return db.Disks[i].ObjectID < db.Disks[j].ObjectID })
+	sort.Slice(db.Volumes, func(i, j int) bool { return db.Volumes[i].ObjectID < db.Volumes[j].ObjectID })
+	sort.Slice(components, func(i, j int) bool { return components[i].ObjectID < components[j].ObjectID })
+	sort.Slice(partitions, func(i, j int) bool {
+		if partitions[i].Index != partitions[j].Index {
+			return partitions[i].Index < partitions[j].Index
+		}
+		return partitions[i].VolumeOffset < partitions[j].VolumeOffset
+	})
+	for i := range components {
+		for _, p := range partitions {
+			if p.parent == components[i].ObjectID {
+				components[i].Partitions = append(components[i].Partitions, p.Partition)
+			}
+		}
+	}
+	for i := range db.Volumes {
+		for _, c := range components {
+			if c.parent == db.Volumes[i].ObjectID {
+				db.Volumes[i].Components = append(db.Volumes[i].Components, c.Component)
+			}
+		}
+	}
+	return db, nil
+}
+
+// component is a Component with the ObjectID of its Volume.
+type component struct {
+	Component
+	parent uint64
+}
+
+// partition is a Partition with the ObjectID of its Component.
+type partition struct {
+	Partition
+	parent uint64
+}
+
+// fields reads the variable length fields of a VBLK record. Fields are located relative to the end of the previous
+// variable length field: the position of a field is its fixed offset (as used by the Linux kernel) plus the length of
+// all previous variable length fields.
+type fields struct {
+	b   []byte
+	rel int // the combined length of the variable length fields read so far
+	err error
+}
+
+func newFields(b []byte) *fields {
+	f := &fields{b: b}
+	if end := 0x18 + int(binary.BigEndian.Uint32(b[0x14:])); end < len(f.b) {
+		f.b = b[:end]
+	}
+	return f
+}
+
+// field returns the variable length field at the offset, and advances past it.
+func (f *fields) field(offset int) []byte {
+	pos := offset + f.rel
+	if f.err != nil {
+		return nil
+	}
+	if pos >= len(f.b) || pos+1+int(f.b[pos]) > len(f.b) {
+		f.err = fmt.Errorf("field at offset %d exceeds record of %d bytes", pos, len(f.b))
+		return nil
+	}
+	length := int(f.b[pos])
+	f.rel += length + 1
+	return f.b[pos+1 : pos+1+length]
+}
+
+// number returns the variable length big endian number at the offset, and advances past it.
+func (f *fields) number(offset int) uint64 {
+	b := f.field(offset)
+	if len(b) > 8 && f.err == nil {
+		f.err = fmt.Errorf("number of %d bytes at offset %d", len(b), offset+f.rel-len(b)-1)
+	}
+	n := uint64(0)
+	for _, c := range b {
+		n = n<<8 | uint64(c)
+	}
+	return n
+}
+
+// string returns the variable length string at the offset, and advances past it.
+func (f *fields) string(offset int) string {
+	return string(f.field(offset))
+}
+
+// fixed returns length bytes at the offset, without advancing.
+func (f *fields) fixed(offset int, length int) []byte {
+	pos := offset + f.rel
+	if f.err != nil {
+		return make([]byte, length)
+	}
+	if pos+length > len(f.b) {
+		f.err = fmt.Errorf("%d bytes at offset %d exceed record of %d bytes", length, pos, len(f.b))
+		return make([]byte, length)
+	}
+	return f.b[pos : pos+length]
+}
+
+func parseDisk(b []byte) (Disk, error) {
+	f := newFields(b)
+	d := Disk{ObjectID: f.number(0x18), Name: f.string(0x18)}
+	if b[0x13] == vblkDisk3 {
+		d.DiskID = strings.ToLower(f.string(0x18))
+	} else {
+		d.DiskID = formatGUID(f.fixed(0x18, 16))
+	}
+	return d, f.err
+}
+
+func parseVolume(b []byte) (Volume, error) {
+	f := newFields(b)
+	v := Volume{ObjectID: f.number(0x18), Name: f.string(0x18), Type: f.string(0x18)}
+	f.string(0x18) // disable drive letter assignment
+	f.number(0x2D) // number of components
+	v.Size = f.number(0x3D) * SectorSize
+	v.PartitionType = f.fixed(0x41, 1)[0]
+	v.GUID = formatGUID(f.fixed(0x42, 16))
+	return v, f.err
+}
+
+func parseComponent(b []byte) (component, error) {
+	f := newFields(b)
+	c := component{Component: Component{ObjectID: f.number(0x18), Name: f.string(0x18), Partitions: make([]Partition, 0)}}
+	f.string(0x18) // state
+	c.Layout = Layout(f.fixed(0x18, 1)[0])
+	f.number(0x1D) // number of partitions
+	c.parent = f.number(0x2D)
+	if b[0x12]&flagComponentStripe != 0 {
+		c.StripeSize = f.number(0x2E) * SectorSize
+		c.Columns = f.number(0x2E)
+	}
+	return c, f.err
+}
+
+func parsePartition(b []byte) (partition, error) {
+	f := newFields(b)
+	p := partition{Partition: Partition{ObjectID: f.number(0x18), Name: f.string(0x18)}}
+	p.Start = binary.BigEndian.Uint64(f.fixed(0x24, 8)) * SectorSize
+	p.VolumeOffset = binary.BigEndian.Uint64(f.fixed(0x2C, 8)) * SectorSize
+	p.Size = f.number(0x34) * SectorSize
+	p.parent = f.number(0x34)
+	p.DiskObjectID = f.number(0x34)
+	if b[0x12]&flagPartitionIndex != 0 {
+		p.Index = f.number(0x34)
+	}
+	return p, f.err
+}
+
+// formatGUID formats a binary GUID which is stored in the order it is written, as LDM does.
+func formatGUID(b []byte) string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,319 @@
+package ldm_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/ldm"
+)
+
+const (
+	vblkSize         = 128
+	configStart      = 200
+	logicalDiskStart = 300
+)
+
+func vnum(n uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, n)
+	b = bytes.TrimLeft(b, "\x00")
+	if len(b) == 0 {
+		b = []byte{0}
+	}
+	return append([]byte{byte(len(b))}, b...)
+}
+
+func vstr(s string) []byte {
+	return append([]byte{byte(len(s))}, s...)
+}
+
+func u64(n uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, n)
+	return b
+}
+
+func join(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func record(typ byte, flags byte, body []byte) []byte {
+	b := make([]byte, 0x18)
+	copy(b, "VBLK")
+	binary.BigEndian.PutUint16(b[0x0E:], 1)
+	b[0x12] = flags
+	b[0x13] = typ
+	binary.BigEndian.PutUint32(b[0x14:], uint32(len(body)))
+	return append(b, body...)
+}
+
+func diskRecord(id uint64, name string, guid string) []byte {
+	return record(0x34, 0, join(vnum(id), vstr(name), vstr(guid), vstr(""), make([]byte, 12)))
+}
+
+func volumeRecord(id uint64, name string, sectors uint64) []byte {
+	guid := []byte{0x0b, 0x8e, 0x5a, 0x3c, 0x1a, 0x2b, 0x4c, 0x5d, 0x8e, 0x9f, 0x00, 0x11, 0x22, 0x33, 0x44, 0x55}
+	return record(0x51, 0, join(vnum(id), vstr(name), vstr("gen"), vstr(""), make([]byte, 21), vnum(1),
+		make([]byte, 16), vnum(sectors), make([]byte, 4), []byte{0x07}, guid))
+}
+
+func componentRecord(id uint64, layout ldm.Layout, children uint64, parent uint64, stripeSectors uint64, columns uint64) []byte {
+	body := join(vnum(id), vstr("Volume1-01"), vstr("ACTIVE"), []byte{byte(layout)}, make([]byte, 4), vnum(children),
+		make([]byte, 16), vnum(parent), make([]byte, 1))
+	flags := byte(0)
+	if stripeSectors > 0 {
+		flags = 0x10
+		body = join(body, vnum(stripeSectors), vnum(columns))
+	}
+	return record(0x32, flags, body)
+}
+
+func partitionRecord(id uint64, parent uint64, disk uint64, start uint64, volumeOffset uint64, sectors uint64, index uint64) []byte {
+	return record(0x33, 0x08, join(vnum(id), vstr("Disk1-01"), make([]byte, 12), u64(start), u64(volumeOffset),
+		vnum(sectors), vnum(parent), vnum(disk), vnum(index)))
+}
+
+// vblks lays out the records in VBLKs, splitting records which do not fit into fragments.
+func vblks(records ...[]byte) []byte {
+	out := make([]byte, 0)
+	for group, r := range records {
+		binary.BigEndian.PutUint32(r[0x08:], uint32(group+1))
+		if len(r) <= vblkSize {
+			out = append(out, r...)
+			out = append(out, make([]byte, vblkSize-len(r))...)
+			continue
+		}
+		payload := r[0x10:]
+		chunk := vblkSize - 0x10
+		num := (len(payload) + chunk - 1) / chunk
+		for i := 0; i < num; i++ {
+			header := append([]byte(nil), r[:0x10]...)
+			binary.BigEndian.PutUint16(header[0x0C:], uint16(i))
+			binary.BigEndian.PutUint16(header[0x0E:], uint16(num))
+			part := payload[i*chunk:]
+			if len(part) > chunk {
+				part = part[:chunk]
+			}
+			out = append(out, header...)
+			out = append(out, part...)
+			out = append(out, make([]byte, chunk-len(part))...)
+		}
+	}
+	// An unused VBLK
+	unused := make([]byte, vblkSize)
+	copy(unused, "VBLK")
+	return append(out, unused...)
+}
+
+// disk builds an image of a dynamic disk with the database and logical disk area.
+func disk(guid string, database []byte, data []byte) []byte {
+	const vblkOffset = 512
+	img := make([]byte, logicalDiskStart*ldm.SectorSize+len(data))
+	copy(img[logicalDiskStart*ldm.SectorSize:], data)
+
+	ph := img[ldm.PrivateHeaderSector*ldm.SectorSize:]
+	copy(ph, "PRIVHEAD")
+	binary.BigEndian.PutUint16(ph[0x0C:], 2)
+	binary.BigEndian.PutUint16(ph[0x0E:], 12)
+	copy(ph[0x30:], guid)
+	binary.BigEndian.PutUint64(ph[0x11B:], logicalDiskStart)
+	binary.BigEndian.PutUint64(ph[0x123:], uint64(len(data)/ldm.SectorSize))
+	binary.BigEndian.PutUint64(ph[0x12B:], configStart)
+	binary.BigEndian.PutUint64(ph[0x133:], logicalDiskStart-configStart)
+
+	vmdb := img[(configStart+17)*ldm.SectorSize:]
+	copy(vmdb, "VMDB")
+	binary.BigEndian.PutUint32(vmdb[0x04:], uint32((vblkOffset+len(database))/vblkSize))
+	binary.BigEndian.PutUint32(vmdb[0x08:], vblkSize)
+	binary.BigEndian.PutUint32(vmdb[0x0C:], vblkOffset)
+	copy(vmdb[vblkOffset:], database)
+	return img
+}
+
+// pattern returns sectors of data in which each byte is the number of the sector, plus base.
+func pattern(sectors int, base byte) []byte {
+	b := make([]byte, sectors*ldm.SectorSize)
+	for i := range b {
+		b[i] = base + byte(i/ldm.SectorSize)
+	}
+	return b
+}
+
+func readAll(t *testing.T, r *ldm.VolumeReader) []byte {
+	b := make([]byte, r.Size())
+	n, err := r.ReadAt(b, 0)
+	require.Nilf(t, err, "error reading volume: %v", err)
+	require.Equal(t, len(b), n)
+	return b
+}
+
+func TestSimpleVolume(t *testing.T) {
+	database := vblks(
+		diskRecord(1, "Disk1", "0f1e2d3c-4b5a-6978-8796-a5b4c3d2e1f0"),
+		volumeRecord(2, "Volume1", 4),
+		componentRecord(3, ldm.LayoutSpanned, 1, 2, 0, 0),
+		partitionRecord(4, 3, 1, 2, 0, 4, 0),
+	)
+	img := disk("0F1E2D3C-4B5A-6978-8796-A5B4C3D2E1F0", database, pattern(8, 0))
+
+	d, err := ldm.OpenDisk(bytes.NewReader(img), ldm.PrivateHeaderSector)
+	require.Nilf(t, err, "error opening disk: %v", err)
+	assert.Equal(t, "0f1e2d3c-4b5a-6978-8796-a5b4c3d2e1f0", d.Header.DiskID)
+	assert.Equal(t, uint64(logicalDiskStart), d.Header.LogicalDiskStart)
+
+	db, err := ldm.ReadDatabase(d)
+	require.Nilf(t, err, "error reading database: %v", err)
+	expected := ldm.Database{
+		Disks: []ldm.Disk{{ObjectID: 1, Name: "Disk1", DiskID: "0f1e2d3c-4b5a-6978-8796-a5b4c3d2e1f0"}},
+		Volumes: []ldm.Volume{{
+			ObjectID:      2,
+			Name:          "Volume1",
+			Type:          "gen",
+			Size:          4 * ldm.SectorSize,
+			PartitionType: 0x07,
+			GUID:          "0b8e5a3c-1a2b-4c5d-8e9f-001122334455",
+			Components: []ldm.Component{{
+				ObjectID: 3,
+				Name:     "Volume1-01",
+				Layout:   ldm.LayoutSpanned,
+				Partitions: []ldm.Partition{
+					{ObjectID: 4, Name: "Disk1-01", DiskObjectID: 1, Start: 2 * ldm.SectorSize, Size: 4 * ldm.SectorSize},
+				},
+			}},
+		}},
+	}
+	assert.Equal(t, expected, db)
+	assert.Equal(t, ldm.LayoutSpanned, db.Volumes[0].Layout())
+
+	r, err := db.Open(db.Volumes[0], d)
+	require.Nilf(t, err, "error opening volume: %v", err)
+	assert.Equal(t, int64(4*ldm.SectorSize), r.Size())
+	assert.Equal(t, pattern(4, 2), readAll(t, r))
+
+	b := make([]byte, 10)
+	n, err := r.ReadAt(b, r.Size()-4)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, 4, n)
+}
+
+func TestSpannedVolumeWithFragmentedRecord(t *testing.T) {
+	// The partitions are out of order on the disk, and the long name makes the volume record span multiple VBLKs
+	name := string(bytes.Repeat([]byte("v"), 200))
+	database := vblks(
+		diskRecord(1, "Disk1", "0f1e2d3c-4b5a-6978-8796-a5b4c3d2e1f0"),
+		volumeRecord(2, name, 6),
+		componentRecord(3, ldm.LayoutSpanned, 2, 2, 0, 0),
+		partitionRecord(4, 3, 1, 10, 2, 4, 0),
+		partitionRecord(5, 3, 1, 1, 0, 2, 0),
+	)
+	img := disk("0f1e2d3c-4b5a-6978-8796-a5b4c3d2e1f0", database, pattern(16, 0))
+	d, err := ldm.OpenDisk(bytes.NewReader(img), ldm.PrivateHeaderSector)
+	require.Nilf(t, err, "error opening disk: %v", err)
+	db, err := ldm.ReadDatabase(d)
+	require.Nilf(t, err, "error reading database: %v", err)
+	require.Len(t, db.Volumes, 1)
+	assert.Equal(t, name, db.Volumes[0].Name)
+
+	r, err := db.Open(db.Volumes[0], d)
+	require.Nilf(t, err, "error opening volume: %v", err)
+	assert.Equal(t, join(pattern(2, 1), pattern(4, 10)), readAll(t, r))
+}
+
+func TestStripedVolumeOverTwoDisks(t *testing.T) {
+	database := vblks(
+		diskRecord(1, "Disk1", "11111111-1111-1111-1111-111111111111"),
+		diskRecord(2, "Disk2", "22222222-2222-2222-2222-222222222222"),
+		volumeRecord(3, "Volume1", 8),
+		componentRecord(4, ldm.LayoutStriped, 2, 3, 2, 2),
+		partitionRecord(5, 4, 2, 0, 0, 4, 1),
+		partitionRecord(6, 4, 1, 0, 0, 4, 0),
+	)
+	d1, err := ldm.OpenDisk(bytes.NewReader(disk("11111111-1111-1111-1111-111111111111", database, pattern(4, 0))), ldm.PrivateHeaderSector)
+	require.Nilf(t, err, "error opening disk: %v", err)
+	d2, err := ldm.OpenDisk(bytes.NewReader(disk("22222222-2222-2222-2222-222222222222", database, pattern(4, 100))), ldm.PrivateHeaderSector)
+	require.Nilf(t, err, "error opening disk: %v", err)
+	db, err := ldm.ReadDatabase(d2)
+	require.Nilf(t, err, "error reading database: %v", err)
+	assert.Equal(t, uint64(2*ldm.SectorSize), db.Volumes[0].Components[0].StripeSize)
+	assert.Equal(t, uint64(2), db.Volumes[0].Components[0].Columns)
+
+	r, err := db.Open(db.Volumes[0], d2, d1)
+	require.Nilf(t, err, "error opening volume: %v", err)
+	expected := join(pattern(2, 0), pattern(2, 100), pattern(2, 2), pattern(2, 102))
+	assert.Equal(t, expected, readAll(t, r))
+
+	// A read spanning stripes
+	b := make([]byte, 2*ldm.SectorSize)
+	n, err := r.ReadAt(b, 3*ldm.SectorSize)
+	require.Nilf(t, err, "error reading volume: %v", err)
+	assert.Equal(t, len(b), n)
+	assert.Equal(t, expected[3*ldm.SectorSize:5*ldm.SectorSize], b)
+
+	_, err = db.Open(db.Volumes[0], d1)
+	assert.NotNil(t, err, "expected an error when a disk is missing")
+}
+
+func TestMirroredVolumeUsesAvailableComponent(t *testing.T) {
+	database := vblks(
+		diskRecord(1, "Disk1", "11111111-1111-1111-1111-111111111111"),
+		diskRecord(2, "Disk2", "22222222-2222-2222-2222-222222222222"),
+		volumeRecord(3, "Volume1", 2),
+		componentRecord(4, ldm.LayoutSpanned, 1, 3, 0, 0),
+		componentRecord(5, ldm.LayoutSpanned, 1, 3, 0, 0),
+		partitionRecord(6, 4, 1, 0, 0, 2, 0),
+		partitionRecord(7, 5, 2, 0, 0, 2, 0),
+	)
+	d2, err := ldm.OpenDisk(bytes.NewReader(disk("22222222-2222-2222-2222-222222222222", database, pattern(2, 50))), ldm.PrivateHeaderSector)
+	require.Nilf(t, err, "error opening disk: %v", err)
+	db, err := ldm.ReadDatabase(d2)
+	require.Nilf(t, err, "error reading database: %v", err)
+	require.Len(t, db.Volumes[0].Components, 2)
+
+	r, err := db.Open(db.Volumes[0], d2)
+	require.Nilf(t, err, "error opening volume: %v", err)
+	assert.Equal(t, pattern(2, 50), readAll(t, r))
+}
+
+func TestRAID5IsNotSupported(t *testing.T) {
+	database := vblks(
+		diskRecord(1, "Disk1", "11111111-1111-1111-1111-111111111111"),
+		volumeRecord(2, "Volume1", 2),
+		componentRecord(3, ldm.LayoutRAID5, 1, 2, 2, 1),
+		partitionRecord(4, 3, 1, 0, 0, 2, 0),
+	)
+	d, err := ldm.OpenDisk(bytes.NewReader(disk("11111111-1111-1111-1111-111111111111", database, pattern(2, 0))), ldm.PrivateHeaderSector)
+	require.Nilf(t, err, "error opening disk: %v", err)
+	db, err := ldm.ReadDatabase(d)
+	require.Nilf(t, err, "error reading database: %v", err)
+	_, err = db.Open(db.Volumes[0], d)
+	assert.NotNil(t, err)
+}
+
+func TestParsePrivateHeaderErrors(t *testing.T) {
+	_, err := ldm.ParsePrivateHeader(make([]byte, 100))
+	assert.NotNil(t, err, "expected an error for short data")
+	_, err = ldm.ParsePrivateHeader(make([]byte, ldm.SectorSize))
+	assert.NotNil(t, err, "expected an error for invalid signature")
+	b := make([]byte, ldm.SectorSize)
+	copy(b, "PRIVHEAD")
+	_, err = ldm.ParsePrivateHeader(b)
+	assert.NotNil(t, err, "expected an error for unsupported version")
+}
+
+func TestParseDatabaseErrors(t *testing.T) {
+	_, err := ldm.ParseDatabase(make([]byte, vblkSize), vblkSize)
+	assert.NotNil(t, err, "expected an error for invalid signature")
+
+	// A fragment of a record spanning 2 VBLKs
+	r := vblks(volumeRecord(1, string(bytes.Repeat([]byte("v"), 200)), 1))
+	_, err = ldm.ParseDatabase(r[:vblkSize], vblkSize)
+	assert.NotNil(t, err, "expected an error for a missing fragment")
+
+	// A field exceeding the record
+	_, err = ldm.ParseDatabase(vblks(record(0x33, 0, join(vnum(1), []byte{50}))), vblkSize)
+	assert.NotNil(t, err, "expected an error for a field exceeding the record")
+}
@@ -0,0 +1,155 @@
+package ldm
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// VolumeReader reads the data of a Volume from its partitions on one or more disks. It is safe for concurrent use when
+// the readers of the disks are.
+type VolumeReader struct {
+	columns    [][]extent
+	stripeSize int64 // 0 when not striped
+	size       int64
+}
+
+// extent is a Partition on an available disk.
+type extent struct {
+	disk   io.ReaderAt
+	offset int64 // the offset of the partition on the disk
+	start  int64 // the offset of the partition in its column
+	length int64
+}
+
+// Open returns a VolumeReader for the volume, reading its partitions from the disks. The disks are matched to the
+// partitions by their DiskID; disks which are not part of the volume are ignored. Mirrored volumes are read from the
+// first component of which all disks are available.
+func (db Database) Open(v Volume, disks ...DiskReader) (*VolumeReader, error) {
+	byID := make(map[string]DiskReader)
+	for _, d := range disks {
+		byID[d.Header.DiskID] = d
+	}
+	byObjectID := make(map[uint64]DiskReader)
+	for _, d := range db.Disks {
+		if r, ok := byID[d.DiskID]; ok {
+			byObjectID[d.ObjectID] = r
+		}
+	}
+
+	if len(v.Components) == 0 {
+		return nil, fmt.Errorf("volume %s has no components", v.Name)
+	}
+	var err error
+	for _, c := range v.Components {
+		var r *VolumeReader
+		if r, err = newVolumeReader(v, c, byObjectID); err == nil {
+			return r, nil
+		}
+	}
+	return nil, err
+}
+
+func newVolumeReader(v Volume, c Component, disks map[uint64]DiskReader) (*VolumeReader, error) {
+	r := &VolumeReader{size: int64(v.Size)}
+	switch c.Layout {
+	case LayoutSpanned:
+		r.columns = make([][]extent, 1)
+	case LayoutStriped:
+		if c.StripeSize == 0 || c.Columns == 0 {
+			return nil, fmt.Errorf("striped component %s has stripe size %d and %d columns", c.Name, c.StripeSize, c.Columns)
+		}
+		r.columns = make([][]extent, c.Columns)
+		r.stripeSize = int64(c.StripeSize)
+	default:
+		return nil, fmt.Errorf("component %s has unsupported layout %v", c.Name, c.Layout)
+	}
+
+	for _, p := range c.Partitions {
+		d, ok := disks[p.DiskObjectID]
+		if !ok {
+			return nil, fmt.Errorf("disk %d of partition %s of component %s is not available", p.DiskObjectID, p.Name, c.Name)
+		}
+		column := uint64(0)
+		if c.Layout == LayoutStriped {
+			column = p.Index
+		}
+		if column >= uint64(len(r.columns)) {
+			return nil, fmt.Errorf("partition %s has column %d of %d", p.Name, column, len(r.columns))
+		}
+		e := extent{
+			disk:   d.ReaderAt,
+			offset: int64(d.Header.LogicalDiskStart)*SectorSize + int64(p.Start),
+			start:  int64(p.VolumeOffset),
+			length: int64(p.Size),
+		}
+		r.columns[column] = append(r.columns[column], e)
+	}
+	for _, column := range r.columns {
+		sort.Slice(column, func(i, j int) bool { return column[i].start < column[j].start })
+	}
+	return r, nil
+}
+
+// Size returns the size of the volume.
+func (r *VolumeReader) Size() int64 {
+	return r.size
+}
+
+// ReadAt reads len(p) bytes starting at position off of the volume. When fewer than len(p) bytes are available, it
+// returns the number of bytes read and io.EOF.
+func (r *VolumeReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset %d", off)
+	}
+	total := 0
+	for total < len(p) && off < r.size {
+		target := p[total:]
+		if remaining := r.size - off; int64(len(target)) > remaining {
+			target = target[:remaining]
+		}
+
+		// Map the offset to a column, and limit the read to the stripe containing it
+		column, columnOffset := 0, off
+		if r.stripeSize > 0 {
+			stripe, within := off/r.stripeSize, off%r.stripeSize
+			column = int(stripe % int64(len(r.columns)))
+			columnOffset = stripe/int64(len(r.columns))*r.stripeSize + within
+			if remaining := r.stripeSize - within; int64(len(target)) > remaining {
+				target = target[:remaining]
+			}
+		}
+
+		n, err := r.readColumn(r.columns[column], target, columnOffset)
+		total += n
+		off += int64(n)
+		if err != nil {
+			return total, err
+		}
+	}
+	if total < len(p) {
+		return total, io.EOF
+	}
+	return total, nil
+}
+
+// readColumn reads from the extent of the column containing off, limited to the end of that extent.
+func (r *VolumeReader) readColumn(column []extent, p []byte, off int64) (int, error) {
+	idx := sort.Search(len(column), func(i int) bool { return column[i].start > off }) - 1
+	if idx < 0 || off >= column[idx].start+column[idx].length {
+		return 0, fmt.Errorf("no partition contains offset %d", off)
+	}
+	e := column[idx]
+	within := off - e.start
+	if remaining := e.length - within; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := e.disk.ReadAt(p, e.offset+within)
+	if n < len(p) {
+		if err == nil || err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return n, fmt.Errorf("unable to read partition at offset %d: %v", e.offset+within, err)
+	}
+	return n, nil
+}
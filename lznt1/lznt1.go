@@ -0,0 +1,124 @@
+/*
+	Package lznt1 decompresses data compressed with LZNT1, the compression algorithm NTFS uses for files and directories
+	with the compressed attribute (and which Windows exposes as COMPRESSION_FORMAT_LZNT1 through RtlDecompressBuffer).
+
+	Basic usage
+
+	Decompress the data of a compression unit into a buffer of the size of the unit. Since NTFS only stores the data up
+	to the last non-zero byte, the remainder of the buffer is filled with zeroes.
+			out := make([]byte, 16*4096)
+			n, err := lznt1.Decompress(out, compressed)
+
+	Use fragment.CompressedReaderAt to read a compressed stream from a volume, which takes care of the compression
+	units and calls Decompress where needed.
+
+	Implementation notes
+
+	LZNT1 data is a sequence of chunks, each holding up to 4096 bytes of uncompressed data. A chunk starts with a 16 bit
+	header containing the length of the chunk and whether it is compressed; a header of zero (or the end of the input)
+	ends the data. Uncompressed chunks are copied as-is. Compressed chunks consist of groups of a flag byte followed by
+	8 tokens: a literal byte for each zero bit, or a 16 bit back-reference for each set bit. The number of bits used
+	for the offset of a back-reference grows with the position in the chunk, from 4 bits at the start up to 12 bits at
+	the end, leaving the remaining bits for the length.
+
+	A compressed chunk which yields less than 4096 bytes is followed by zeroes up to 4096 bytes when another chunk
+	follows, since every chunk but the last represents 4096 bytes of data.
+*/
+package lznt1
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// ChunkSize is the number of bytes of uncompressed data represented by each chunk.
+const ChunkSize = 4096
+
+const (
+	headerLengthMask = 0x0fff
+	headerCompressed = 0x8000
+)
+
+// Decompress decompresses src into dst and returns the number of bytes decompressed. Decompression stops at the end
+// of the data or when dst is full; the rest of dst is then filled with zeroes. An error is returned for data that is
+// not valid LZNT1.
+func Decompress(dst []byte, src []byte) (int, error) {
+	out := 0
+	for len(src) >= 2 && out < len(dst) {
+		header := binary.LittleEndian.Uint16(src)
+		if header == 0 {
+			break
+		}
+		length := int(header&headerLengthMask) + 1
+		if len(src) < 2+length {
+			return out, fmt.Errorf("chunk at output offset %d needs %d bytes but only %d are left", out, length, len(src)-2)
+		}
+		chunk := src[2 : 2+length]
+		src = src[2+length:]
+
+		// Every chunk but the last represents a full ChunkSize bytes
+		end := out + ChunkSize
+		if end > len(dst) {
+			end = len(dst)
+		}
+		if header&headerCompressed == 0 {
+			n := copy(dst[out:end], chunk)
+			out += n
+		} else {
+			n, err := decompressChunk(dst[out:end], chunk)
+			if err != nil {
+				return out, fmt.Errorf("unable to decompress chunk at output offset %d: %v", out, err)
+			}
+			out += n
+		}
+		if len(src) >= 2 && binary.LittleEndian.Uint16(src) != 0 {
+			for ; out < end; out++ {
+				dst[out] = 0
+			}
+		}
+	}
+	for i := out; i < len(dst); i++ {
+		dst[i] = 0
+	}
+	return out, nil
+}
+
+// decompressChunk decompresses the data of a compressed chunk (without its header) into dst, which holds at most
+// ChunkSize bytes, and returns the number of bytes written.
+func decompressChunk(dst []byte, chunk []byte) (int, error) {
+	out := 0
+	for len(chunk) > 0 && out < len(dst) {
+		flags := chunk[0]
+		chunk = chunk[1:]
+		for bit := 0; bit < 8 && len(chunk) > 0 && out < len(dst); bit++ {
+			if flags&(1<<bit) == 0 {
+				dst[out] = chunk[0]
+				out++
+				chunk = chunk[1:]
+				continue
+			}
+			if len(chunk) < 2 {
+				return out, fmt.Errorf("back-reference at offset %d is truncated", out)
+			}
+			token := binary.LittleEndian.Uint16(chunk)
+			chunk = chunk[2:]
+
+			// The offset takes at least 4 bits, and as many as needed to refer to the start of the chunk
+			offsetBits := uint(4)
+			for p := out - 1; p >= 0x10; p >>= 1 {
+				offsetBits++
+			}
+			lengthBits := 16 - offsetBits
+			length := int(token&(1<<lengthBits-1)) + 3
+			offset := int(token>>lengthBits) + 1
+			if offset > out {
+				return out, fmt.Errorf("back-reference at offset %d refers to %d bytes back", out, offset)
+			}
+			for i := 0; i < length && out < len(dst); i++ {
+				dst[out] = dst[out-offset]
+				out++
+			}
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,128 @@
+package lznt1_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/lznt1"
+)
+
+func TestDecompress(t *testing.T) {
+	// "abc" followed by a back-reference of 6 bytes at offset 3
+	compressed := []byte{0x05, 0xb0, 0x08, 'a', 'b', 'c', 0x03, 0x20, 0x00, 0x00}
+	out := bytes.Repeat([]byte{0xff}, 12)
+	n, err := lznt1.Decompress(out, compressed)
+	require.Nilf(t, err, "unable to decompress: %v", err)
+	assert.Equal(t, 9, n)
+	assert.Equal(t, []byte("abcabcabc\x00\x00\x00"), out)
+}
+
+func TestDecompress_RoundTrip(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	data := make([]byte, 0, 3*lznt1.ChunkSize+100)
+	for len(data) < cap(data) {
+		// Alternate random bytes and repetitions of earlier data, at various distances
+		if rnd.Intn(2) == 0 || len(data) < 10 {
+			data = append(data, byte(rnd.Intn(4)+'a'))
+			continue
+		}
+		from := rnd.Intn(len(data))
+		for i := 0; i < rnd.Intn(40) && len(data) < cap(data); i++ {
+			data = append(data, data[from+i])
+		}
+	}
+
+	compressed := compress(data)
+	assert.True(t, len(compressed) < len(data))
+	out := make([]byte, len(data))
+	n, err := lznt1.Decompress(out, compressed)
+	require.Nilf(t, err, "unable to decompress: %v", err)
+	assert.Equal(t, len(data), n)
+	assert.Equal(t, data, out)
+}
+
+func TestDecompress_UncompressedChunk(t *testing.T) {
+	compressed := append([]byte{0xff, 0x3f}, bytes.Repeat([]byte{'x'}, lznt1.ChunkSize)...)
+	compressed = append(compressed, 0x01, 0x30, 'y', 'z')
+	out := make([]byte, lznt1.ChunkSize+4)
+	n, err := lznt1.Decompress(out, compressed)
+	require.Nilf(t, err, "unable to decompress: %v", err)
+	assert.Equal(t, lznt1.ChunkSize+2, n)
+	assert.Equal(t, append(bytes.Repeat([]byte{'x'}, lznt1.ChunkSize), 'y', 'z', 0, 0), out)
+}
+
+func TestDecompress_ShortChunkPadded(t *testing.T) {
+	// A compressed chunk with just "ab", followed by an uncompressed chunk with "c"
+	compressed := []byte{0x02, 0xb0, 0x00, 'a', 'b', 0x00, 0x30, 'c'}
+	out := bytes.Repeat([]byte{0xff}, lznt1.ChunkSize+1)
+	n, err := lznt1.Decompress(out, compressed)
+	require.Nilf(t, err, "unable to decompress: %v", err)
+	assert.Equal(t, lznt1.ChunkSize+1, n)
+	expected := make([]byte, lznt1.ChunkSize+1)
+	copy(expected, "ab")
+	expected[lznt1.ChunkSize] = 'c'
+	assert.Equal(t, expected, out)
+}
+
+func TestDecompress_Errors(t *testing.T) {
+	out := make([]byte, 16)
+	_, err := lznt1.Decompress(out, []byte{0x05, 0xb0, 0x00, 'a'})
+	assert.EqualError(t, err, "chunk at output offset 0 needs 6 bytes but only 2 are left")
+	_, err = lznt1.Decompress(out, []byte{0x02, 0xb0, 0x01, 0x00, 0x00})
+	assert.EqualError(t, err, "unable to decompress chunk at output offset 0: back-reference at offset 0 refers to 1 bytes back")
+	_, err = lznt1.Decompress(out, []byte{0x02, 0xb0, 0x02, 'a', 0x00})
+	assert.EqualError(t, err, "unable to decompress chunk at output offset 0: back-reference at offset 1 is truncated")
+}
+
+// compress compresses data using LZNT1 with a simple greedy search for the longest match.
+func compress(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	for start := 0; start < len(data); start += lznt1.ChunkSize {
+		end := start + lznt1.ChunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+		chunk := data[start:end]
+		body := make([]byte, 0, len(chunk))
+		for pos := 0; pos < len(chunk); {
+			flagsAt := len(body)
+			body = append(body, 0)
+			for bit := uint(0); bit < 8 && pos < len(chunk); bit++ {
+				offsetBits := uint(4)
+				for p := pos - 1; p >= 0x10; p >>= 1 {
+					offsetBits++
+				}
+				maxLength := 1<<(16-offsetBits) - 1 + 3
+				maxOffset := 1 << offsetBits
+				bestLength, bestOffset := 0, 0
+				for offset := 1; offset <= pos && offset <= maxOffset; offset++ {
+					length := 0
+					for pos+length < len(chunk) && length < maxLength && chunk[pos+length] == chunk[pos+length-offset] {
+						length++
+					}
+					if length > bestLength {
+						bestLength, bestOffset = length, offset
+					}
+				}
+				if bestLength < 3 {
+					body = append(body, chunk[pos])
+					pos++
+					continue
+				}
+				body[flagsAt] |= 1 << bit
+				token := uint16(bestOffset-1)<<(16-offsetBits) | uint16(bestLength-3)
+				body = append(body, byte(token), byte(token>>8))
+				pos += bestLength
+			}
+		}
+		header := make([]byte, 2)
+		binary.LittleEndian.PutUint16(header, 0xb000|uint16(len(body)-1))
+		out = append(out, header...)
+		out = append(out, body...)
+	}
+	return out
+}
@@ -0,0 +1,171 @@
+/*
+	Package magic identifies the type of the contents of a file by its first bytes (its "magic number"), like the file
+	command does, so the contents of a file can be compared with its name. An executable stored under the name of a
+	document or picture is a common way for malware to masquerade as a benign file.
+
+	Basic usage
+
+	Detect the Type of the first bytes of a stream (at most HeaderSize bytes are needed), and check whether the name of
+	the file matches it.
+			t, ok := magic.Detect(data)
+			if ok && t.Executable && !t.MatchesName(name) {
+				fmt.Println(name, "is actually a", t.Name)
+			}
+
+	To read the first bytes of a stream which is not in memory, use ReadHeader.
+			header, err := magic.ReadHeader(r, size)
+			t, ok := magic.Detect(header)
+
+	Implementation notes
+
+	Only formats with a distinctive signature at a fixed offset are recognized, which excludes plain text formats (such
+	as scripts) that can only be told apart by heuristics. Signatures are checked in a fixed order, so when a format is
+	a specialization of another (like the RIFF based formats), the most specific Type is returned. Formats based on
+	containers, such as Office Open XML documents (zip) or Office 97-2003 documents (OLE compound files), are reported
+	as the container; the Extensions of the container include those of the formats based on it.
+
+	The MIME types are those registered with IANA where available, otherwise the ones commonly used by other tools
+	(such as libmagic).
+*/
+package magic
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// HeaderSize is the number of bytes at the start of a stream used by Detect.
+const HeaderSize = 512
+
+// Type is a file format.
+type Type struct {
+	Name string // a short description, such as "PDF document"
+	MIME string
+	// Extensions are the (lower case) extensions of the files of this Type, without the dot.
+	Extensions []string
+	// Executable indicates whether files of this Type contain native code, which is run or loaded by an
+	// operating system.
+	Executable bool
+}
+
+// MatchesName checks whether the extension of the name is one of the Extensions of the Type. Extensions are compared
+// case insensitively.
+func (t Type) MatchesName(name string) bool {
+	i := strings.LastIndexByte(name, '.')
+	if i < 0 {
+		return false
+	}
+	ext := strings.ToLower(name[i+1:])
+	for _, e := range t.Extensions {
+		if e == ext {
+			return true
+		}
+	}
+	return false
+}
+
+func (t Type) String() string {
+	return t.Name
+}
+
+// part is a sequence of bytes expected at an offset.
+type part struct {
+	offset int
+	data   []byte
+}
+
+type signature struct {
+	parts []part
+	t     Type
+}
+
+func at(offset int, data string) part {
+	return part{offset: offset, data: []byte(data)}
+}
+
+var riff = at(0, "RIFF")
+
+// signatures are checked in order, so more specific signatures must come first.
+var signatures = []signature{
+	{[]part{at(0, "MZ")}, Type{Name: "PE executable", MIME: "application/vnd.microsoft.portable-executable",
+		Extensions: []string{"exe", "dll", "sys", "scr", "com", "cpl", "ocx", "drv", "efi", "mui", "ax", "tsp"}, Executable: true}},
+	{[]part{at(0, "\x7fELF")}, Type{Name: "ELF executable", MIME: "application/x-elf",
+		Extensions: []string{"so", "o", "elf", "bin", "ko"}, Executable: true}},
+	{[]part{at(0, "\xfe\xed\xfa\xce")}, machO},
+	{[]part{at(0, "\xfe\xed\xfa\xcf")}, machO},
+	{[]part{at(0, "\xce\xfa\xed\xfe")}, machO},
+	{[]part{at(0, "\xcf\xfa\xed\xfe")}, machO},
+	{[]part{at(0, "%PDF-")}, Type{Name: "PDF document", MIME: "application/pdf", Extensions: []string{"pdf"}}},
+	{[]part{at(0, "PK\x03\x04")}, zip},
+	{[]part{at(0, "PK\x05\x06")}, zip},
+	{[]part{at(0, "\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1")}, Type{Name: "OLE compound file", MIME: "application/x-ole-storage",
+		Extensions: []string{"doc", "xls", "ppt", "msi", "msg", "vsd", "pub", "dot", "xlt", "pot", "msp", "mst"}}},
+	{[]part{at(0, "{\\rtf")}, Type{Name: "RTF document", MIME: "application/rtf", Extensions: []string{"rtf", "doc"}}},
+	{[]part{at(0, "\x89PNG\r\n\x1a\n")}, Type{Name: "PNG image", MIME: "image/png", Extensions: []string{"png"}}},
+	{[]part{at(0, "\xff\xd8\xff")}, Type{Name: "JPEG image", MIME: "image/jpeg", Extensions: []string{"jpg", "jpeg", "jpe", "jfif"}}},
+	{[]part{at(0, "GIF87a")}, gif},
+	{[]part{at(0, "GIF89a")}, gif},
+	{[]part{riff, at(8, "WEBP")}, Type{Name: "WebP image", MIME: "image/webp", Extensions: []string{"webp"}}},
+	{[]part{riff, at(8, "WAVE")}, Type{Name: "WAVE audio", MIME: "audio/wav", Extensions: []string{"wav"}}},
+	{[]part{riff, at(8, "AVI ")}, Type{Name: "AVI video", MIME: "video/x-msvideo", Extensions: []string{"avi"}}},
+	{[]part{at(4, "ftyp")}, Type{Name: "ISO media", MIME: "video/mp4",
+		Extensions: []string{"mp4", "m4a", "m4v", "mov", "3gp", "heic", "heif", "avif"}}},
+	{[]part{at(0, "\x1f\x8b")}, Type{Name: "gzip archive", MIME: "application/gzip", Extensions: []string{"gz", "tgz"}}},
+	{[]part{at(0, "7z\xbc\xaf\x27\x1c")}, Type{Name: "7-Zip archive", MIME: "application/x-7z-compressed", Extensions: []string{"7z"}}},
+	{[]part{at(0, "Rar!\x1a\x07")}, Type{Name: "RAR archive", MIME: "application/vnd.rar", Extensions: []string{"rar"}}},
+	{[]part{at(0, "\xfd7zXZ\x00")}, Type{Name: "xz archive", MIME: "application/x-xz", Extensions: []string{"xz", "txz"}}},
+	{[]part{at(0, "BZh")}, Type{Name: "bzip2 archive", MIME: "application/x-bzip2", Extensions: []string{"bz2", "tbz2"}}},
+	{[]part{at(0, "MSCF")}, Type{Name: "Cabinet archive", MIME: "application/vnd.ms-cab-compressed", Extensions: []string{"cab"}}},
+	{[]part{at(257, "ustar")}, Type{Name: "tar archive", MIME: "application/x-tar", Extensions: []string{"tar"}}},
+	{[]part{at(0, "L\x00\x00\x00\x01\x14\x02\x00")}, Type{Name: "Windows shortcut", MIME: "application/x-ms-shortcut", Extensions: []string{"lnk"}}},
+	{[]part{at(0, "SQLite format 3\x00")}, Type{Name: "SQLite database", MIME: "application/vnd.sqlite3",
+		Extensions: []string{"sqlite", "sqlite3", "db", "db3"}}},
+	{[]part{at(0, "ElfFile\x00")}, Type{Name: "Windows event log", MIME: "application/x-ms-evtx", Extensions: []string{"evtx"}}},
+	{[]part{at(0, "regf")}, Type{Name: "Windows registry hive", MIME: "application/x-ms-registry",
+		Extensions: []string{"dat", "hve", "hiv", "sav"}}},
+}
+
+var machO = Type{Name: "Mach-O executable", MIME: "application/x-mach-binary", Extensions: []string{"dylib", "bundle", "o"}, Executable: true}
+
+var zip = Type{Name: "zip archive", MIME: "application/zip", Extensions: []string{"zip", "docx", "docm", "xlsx", "xlsm",
+	"pptx", "pptm", "odt", "ods", "odp", "jar", "apk", "epub", "xpi", "nupkg", "vsix", "appx", "msix", "whl"}}
+
+var gif = Type{Name: "GIF image", MIME: "image/gif", Extensions: []string{"gif"}}
+
+// Detect identifies the Type of data by its first bytes. It returns false when the data matches no known signature.
+func Detect(header []byte) (Type, bool) {
+	for _, s := range signatures {
+		if s.matches(header) {
+			return s.t, true
+		}
+	}
+	return Type{}, false
+}
+
+func (s signature) matches(header []byte) bool {
+	for _, p := range s.parts {
+		if len(header) < p.offset+len(p.data) || !bytes.Equal(header[p.offset:p.offset+len(p.data)], p.data) {
+			return false
+		}
+	}
+	return true
+}
+
+// ReadHeader reads the first bytes of a stream of size bytes from r, as many as Detect uses. Less than HeaderSize
+// bytes are read from smaller streams, so slack space after the end of the stream is not taken for its data.
+func ReadHeader(r io.ReaderAt, size int64) ([]byte, error) {
+	if size > HeaderSize {
+		size = HeaderSize
+	}
+	if size <= 0 {
+		return []byte{}, nil
+	}
+	header := make([]byte, size)
+	n, err := r.ReadAt(header, 0)
+	if n < len(header) {
+		return nil, fmt.Errorf("unable to read header: %v", err)
+	}
+	return header, nil
+}
@@ -0,0 +1,82 @@
+package magic_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/magic"
+)
+
+func TestDetect(t *testing.T) {
+	tar := make([]byte, magic.HeaderSize)
+	copy(tar[257:], "ustar\x0000")
+
+	tests := []struct {
+		header []byte
+		mime   string
+	}{
+		{[]byte("MZ\x90\x00\x03"), "application/vnd.microsoft.portable-executable"},
+		{[]byte("\x7fELF\x02\x01"), "application/x-elf"},
+		{[]byte("\xcf\xfa\xed\xfe"), "application/x-mach-binary"},
+		{[]byte("%PDF-1.7\n"), "application/pdf"},
+		{[]byte("PK\x03\x04\x14\x00"), "application/zip"},
+		{[]byte("\x89PNG\r\n\x1a\n"), "image/png"},
+		{[]byte("RIFF\x00\x00\x00\x00WEBPVP8 "), "image/webp"},
+		{[]byte("RIFF\x00\x00\x00\x00WAVEfmt "), "audio/wav"},
+		{[]byte("\x00\x00\x00\x18ftypmp42"), "video/mp4"},
+		{tar, "application/x-tar"},
+		{[]byte("L\x00\x00\x00\x01\x14\x02\x00\x00\x00"), "application/x-ms-shortcut"},
+	}
+	for _, tt := range tests {
+		typ, ok := magic.Detect(tt.header)
+		assert.Truef(t, ok, "no type detected for %q", tt.header)
+		assert.Equalf(t, tt.mime, typ.MIME, "header: %q", tt.header)
+	}
+
+	for _, header := range [][]byte{nil, []byte("M"), []byte("hello world"), []byte("RIFF\x00\x00\x00\x00XXXX"), make([]byte, 300)} {
+		_, ok := magic.Detect(header)
+		assert.Falsef(t, ok, "type detected for %q", header)
+	}
+}
+
+func TestType_MatchesName(t *testing.T) {
+	pe, _ := magic.Detect([]byte("MZ"))
+	assert.True(t, pe.Executable)
+	assert.Equal(t, "PE executable", pe.String())
+	assert.True(t, pe.MatchesName("setup.EXE"))
+	assert.True(t, pe.MatchesName("kernel32.dll"))
+	assert.False(t, pe.MatchesName("invoice.pdf.scr.txt"))
+	assert.False(t, pe.MatchesName("README"))
+
+	pdf, _ := magic.Detect([]byte("%PDF-"))
+	assert.False(t, pdf.Executable)
+	assert.True(t, pdf.MatchesName("invoice.pdf"))
+}
+
+type failingReaderAt struct{}
+
+func (failingReaderAt) ReadAt([]byte, int64) (int, error) {
+	return 0, errors.New("broken disk")
+}
+
+func TestReadHeader(t *testing.T) {
+	data := bytes.Repeat([]byte{'x'}, 1000)
+	header, err := magic.ReadHeader(bytes.NewReader(data), int64(len(data)))
+	require.Nil(t, err)
+	assert.Equal(t, magic.HeaderSize, len(header))
+
+	// Only the size of the stream is read, not any slack after it
+	header, err = magic.ReadHeader(bytes.NewReader(data), 10)
+	require.Nil(t, err)
+	assert.Equal(t, 10, len(header))
+
+	header, err = magic.ReadHeader(bytes.NewReader(data), 0)
+	require.Nil(t, err)
+	assert.Empty(t, header)
+
+	_, err = magic.ReadHeader(failingReaderAt{}, 10)
+	assert.EqualError(t, err, "unable to read header: broken disk")
+}
@@ -0,0 +1,148 @@
+/*
+	Package metrics defines a small interface through which gomft reports counters, such as the number of bytes read
+	and records parsed, so embedders can feed them into their monitoring system of choice (Prometheus, OpenTelemetry,
+	expvar, ...) without gomft depending on any of them.
+
+	Basic usage
+
+	Implement a Recorder (and optionally a GaugeRecorder), or use Counters to keep the values in memory, and pass it in the options of the packages
+	supporting metrics: mft.ParseAllOptions, httprange.Options and pipeline.RecordMetrics for the Events of a pipeline.
+	Wrap a volume using ReaderAt to count the bytes read from it.
+			// Error handling left out for brevity
+			c := &metrics.Counters{}
+			f, err := os.Open("sdb1.mft")
+			in := io.NewSectionReader(metrics.ReaderAt(f, c), 0, math.MaxInt64)
+			for result := range mft.ParseAll(in, mft.ParseAllOptions{Metrics: c}) {
+				// ...
+			}
+			fmt.Println(c.Get(metrics.BytesRead), c.Get(metrics.RecordsParsed))
+
+	A Recorder for Prometheus could look like this, using a CounterVec with a "name" label.
+			type promRecorder struct{ counters *prometheus.CounterVec }
+
+			func (r promRecorder) Add(name string, delta int64) {
+				r.counters.WithLabelValues(name).Add(float64(delta))
+			}
+
+	Implementation notes
+
+	Recorders are called from the goroutines doing the work, often from many at the same time, so they must be safe for
+	concurrent use and return quickly. To keep the overhead low, values are reported in batches where possible, for
+	example once per batch of parsed records rather than for each record.
+*/
+package metrics
+
+import (
+	"io"
+	"sort"
+	"sync"
+)
+
+// Names of the counters reported by gomft.
+const (
+	BytesRead      = "bytes_read"      // bytes read from a volume, image or dump
+	Reads          = "reads"           // read calls on a volume, image or dump
+	ReadErrors     = "read_errors"     // read calls which failed (other than with io.EOF)
+	RecordsParsed  = "records_parsed"  // records parsed successfully
+	ParseErrors    = "parse_errors"    // records which could not be parsed
+	CacheHits      = "cache_hits"      // blocks read from a cache
+	CacheMisses    = "cache_misses"    // blocks which were not cached and had to be fetched
+	FilesExtracted = "files_extracted" // streams extracted by a pipeline stage
+	BytesExtracted = "bytes_extracted" // bytes of the streams extracted by a pipeline stage
+)
+
+// Names of the gauges reported by gomft.
+const (
+	CachedBlocks = "cached_blocks" // blocks currently in a cache
+)
+
+// A Recorder receives the counters reported by gomft. It must be safe for concurrent use.
+type Recorder interface {
+	// Add adds delta to the counter with the name.
+	Add(name string, delta int64)
+}
+
+// A GaugeRecorder is a Recorder which also receives gauges: values which go up and down, rather than only up.
+type GaugeRecorder interface {
+	Recorder
+	// Set sets the gauge with the name to the value.
+	Set(name string, value int64)
+}
+
+// SetGauge sets the gauge with the name to the value when the Recorder is a GaugeRecorder, and does nothing otherwise.
+func SetGauge(rec Recorder, name string, value int64) {
+	if g, ok := rec.(GaugeRecorder); ok {
+		g.Set(name, value)
+	}
+}
+
+// Counters is a GaugeRecorder keeping the counters and gauges in memory. Its zero value is ready to use.
+type Counters struct {
+	mu     sync.Mutex
+	values map[string]int64
+}
+
+// Add adds delta to the counter with the name.
+func (c *Counters) Add(name string, delta int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.values == nil {
+		c.values = make(map[string]int64)
+	}
+	c.values[name] += delta
+}
+
+// Set sets the gauge with the name to the value.
+func (c *Counters) Set(name string, value int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.values == nil {
+		c.values = make(map[string]int64)
+	}
+	c.values[name] = value
+}
+
+// Get returns the value of the counter or gauge with the name, which is zero when it was never reported.
+func (c *Counters) Get(name string) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[name]
+}
+
+// Names returns the names of the counters and gauges which were reported, sorted.
+func (c *Counters) Names() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	names := make([]string, 0, len(c.values))
+	for name := range c.values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type readerAt struct {
+	r   io.ReaderAt
+	rec Recorder
+}
+
+// ReaderAt returns an io.ReaderAt reading from r which reports BytesRead, Reads and ReadErrors to the Recorder. When
+// the Recorder is nil, r is returned as is.
+func ReaderAt(r io.ReaderAt, rec Recorder) io.ReaderAt {
+	if rec == nil {
+		return r
+	}
+	return &readerAt{r: r, rec: rec}
+}
+
+func (r *readerAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := r.r.ReadAt(p, off)
+	r.rec.Add(Reads, 1)
+	if n > 0 {
+		r.rec.Add(BytesRead, int64(n))
+	}
+	if err != nil && err != io.EOF {
+		r.rec.Add(ReadErrors, 1)
+	}
+	return n, err
+}
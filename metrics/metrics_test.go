@@ -0,0 +1,67 @@
+package metrics_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/t9t/gomft/metrics"
+)
+
+func TestCounters(t *testing.T) {
+	c := &metrics.Counters{}
+	assert.Equal(t, int64(0), c.Get(metrics.BytesRead))
+	assert.Empty(t, c.Names())
+
+	c.Add(metrics.BytesRead, 100)
+	c.Add(metrics.BytesRead, 23)
+	c.Add(metrics.Reads, 2)
+	metrics.SetGauge(c, metrics.CachedBlocks, 5)
+	metrics.SetGauge(c, metrics.CachedBlocks, 3)
+	assert.Equal(t, int64(123), c.Get(metrics.BytesRead))
+	assert.Equal(t, int64(2), c.Get(metrics.Reads))
+	assert.Equal(t, int64(3), c.Get(metrics.CachedBlocks))
+	assert.Equal(t, []string{metrics.BytesRead, metrics.CachedBlocks, metrics.Reads}, c.Names())
+}
+
+// countOnly is a Recorder which does not support gauges.
+type countOnly map[string]int64
+
+func (c countOnly) Add(name string, delta int64) {
+	c[name] += delta
+}
+
+func TestSetGauge_NotSupported(t *testing.T) {
+	c := countOnly{}
+	metrics.SetGauge(c, metrics.CachedBlocks, 5)
+	assert.Empty(t, c)
+}
+
+type failingReaderAt struct{}
+
+func (failingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	return 0, errors.New("bad sector")
+}
+
+func TestReaderAt(t *testing.T) {
+	c := &metrics.Counters{}
+	r := metrics.ReaderAt(bytes.NewReader([]byte("0123456789")), c)
+	buf := make([]byte, 4)
+	_, err := r.ReadAt(buf, 0)
+	assert.Nil(t, err)
+	n, err := r.ReadAt(buf, 8)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, int64(6), c.Get(metrics.BytesRead))
+	assert.Equal(t, int64(2), c.Get(metrics.Reads))
+	assert.Equal(t, int64(0), c.Get(metrics.ReadErrors))
+
+	_, err = metrics.ReaderAt(failingReaderAt{}, c).ReadAt(buf, 0)
+	assert.NotNil(t, err)
+	assert.Equal(t, int64(1), c.Get(metrics.ReadErrors))
+
+	src := bytes.NewReader(nil)
+	assert.Equal(t, src, metrics.ReaderAt(src, nil))
+}
@@ -0,0 +1,185 @@
+package mft
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/t9t/gomft/fragment"
+)
+
+// ReadAttributeData returns the data of an attribute. For a resident attribute, this is its Data. For a non-resident
+// attribute, its data runs are parsed and ActualSize bytes are read from the volume; the volume must be a reader over
+// the complete volume (or an image of it) with the given cluster size.
+func ReadAttributeData(a Attribute, volume io.ReaderAt, bytesPerCluster int) ([]byte, error) {
+	if a.Resident {
+		return a.Data, nil
+	}
+	if volume == nil {
+		return nil, fmt.Errorf("attribute is non-resident but no volume is available")
+	}
+	runs, err := ParseDataRuns(a.Data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse data runs: %v", err)
+	}
+	r := fragment.NewReaderAt(volume, DataRunsToFragments(runs, bytesPerCluster))
+	if uint64(r.Size()) < a.ActualSize {
+		return nil, fmt.Errorf("data runs cover %d bytes, but the attribute is %d bytes", r.Size(), a.ActualSize)
+	}
+	data := make([]byte, a.ActualSize)
+	if n, err := r.ReadAt(data, 0); n < len(data) {
+		return nil, fmt.Errorf("unable to read %d bytes of attribute data: %v", len(data), err)
+	}
+	return data, nil
+}
+
+// ReadAttributeList parses the $ATTRIBUTE_LIST attribute of a record. The list is resident in most records, but very
+// fragmented files (with many extension records) store it non-resident, in which case it is read from the volume using
+// ReadAttributeData; the volume may be nil when only resident lists are to be supported. A record without an
+// $ATTRIBUTE_LIST returns no entries.
+func (opts ParseOptions) ReadAttributeList(r Record, volume io.ReaderAt, bytesPerCluster int) ([]AttributeListEntry, error) {
+	attributes := r.FindAttributes(AttributeTypeAttributeList)
+	if len(attributes) == 0 {
+		return []AttributeListEntry{}, nil
+	}
+	data, err := ReadAttributeData(attributes[0], volume, bytesPerCluster)
+	if err != nil {
+		return []AttributeListEntry{}, fmt.Errorf("unable to read $ATTRIBUTE_LIST: %v", err)
+	}
+	return opts.ParseAttributeList(data)
+}
+
+// AttributeResolver collects the attributes of a file which are spread over its base record and the extension records
+// listed in the $ATTRIBUTE_LIST of the base record.
+type AttributeResolver struct {
+	// Record returns the record with the number, for example by reading it from the MFT or looking it up in an index.
+	Record func(number uint64) (Record, error)
+	// Volume is used to read $ATTRIBUTE_LIST attributes which are non-resident; when nil, only resident lists are
+	// supported.
+	Volume io.ReaderAt
+	// BytesPerCluster is the cluster size of the Volume.
+	BytesPerCluster int
+	// Parse are the options used to parse the $ATTRIBUTE_LIST.
+	Parse ParseOptions
+}
+
+// Attributes returns all attributes of a base record: the attributes of the record itself, followed by the attributes
+// in its extension records in the order of its $ATTRIBUTE_LIST. Each extension record is read once, and must refer to
+// the base record. For a record without an $ATTRIBUTE_LIST, only its own attributes are returned.
+func (res AttributeResolver) Attributes(r Record) ([]Attribute, error) {
+	list, err := res.Parse.ReadAttributeList(r, res.Volume, res.BytesPerCluster)
+	if err != nil {
+		return nil, err
+	}
+	attributes := append([]Attribute(nil), r.Attributes...)
+	number := r.FileReference.RecordNumber
+	extensions := make(map[uint64]Record)
+	for _, e := range list {
+		n := e.BaseRecordReference.RecordNumber
+		if n == number {
+			continue
+		}
+		ext, ok := extensions[n]
+		if !ok {
+			if ext, err = res.Record(n); err != nil {
+				return nil, fmt.Errorf("unable to read extension record %d: %v", n, err)
+			}
+			if ext.BaseRecordReference.RecordNumber != number {
+				return nil, fmt.Errorf("extension record %d refers to base record %d instead of %d", n, ext.BaseRecordReference.RecordNumber, number)
+			}
+			extensions[n] = ext
+		}
+		found := false
+		for _, a := range ext.Attributes {
+			if a.Type == e.Type && a.Name == e.Name && a.AttributeId == int(e.AttributeId) {
+				attributes = append(attributes, a)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("%s attribute %q with id %d not found in extension record %d", e.Type.Name(), e.Name, e.AttributeId, n)
+		}
+	}
+	return attributes, nil
+}
+
+// ExtensionRecords returns the references of the extension records listed in the $ATTRIBUTE_LIST of a base record,
+// in the order in which they first appear in the list. The record itself is not included, and each extension record
+// is returned once. A record without an $ATTRIBUTE_LIST has no extension records.
+func (opts ParseOptions) ExtensionRecords(r Record, volume io.ReaderAt, bytesPerCluster int) ([]FileReference, error) {
+	list, err := opts.ReadAttributeList(r, volume, bytesPerCluster)
+	if err != nil {
+		return nil, err
+	}
+	refs := make([]FileReference, 0)
+	seen := make(map[uint64]bool)
+	for _, e := range list {
+		n := e.BaseRecordReference.RecordNumber
+		if n == r.FileReference.RecordNumber || seen[n] {
+			continue
+		}
+		seen[n] = true
+		refs = append(refs, e.BaseRecordReference)
+	}
+	return refs, nil
+}
+
+// ExtensionRecords reads the extension records listed in the $ATTRIBUTE_LIST of a base record, in the order returned
+// by ParseOptions.ExtensionRecords. Each extension record must refer to the base record.
+func (res AttributeResolver) ExtensionRecords(r Record) ([]Record, error) {
+	refs, err := res.Parse.ExtensionRecords(r, res.Volume, res.BytesPerCluster)
+	if err != nil {
+		return nil, err
+	}
+	number := r.FileReference.RecordNumber
+	extensions := make([]Record, 0, len(refs))
+	for _, ref := range refs {
+		ext, err := res.Record(ref.RecordNumber)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read extension record %d: %v", ref.RecordNumber, err)
+		}
+		if ext.BaseRecordReference.RecordNumber != number {
+			return nil, fmt.Errorf("extension record %d refers to base record %d instead of %d", ref.RecordNumber, ext.BaseRecordReference.RecordNumber, number)
+		}
+		extensions = append(extensions, ext)
+	}
+	return extensions, nil
+}
+
+// BaseRecord returns the base record an extension record belongs to. A record which is not an extension record is its
+// own base record and is returned as is. The base record must be the one referred to, ie. the sequence numbers must
+// match, so that an extension record left behind by a deleted file is not attributed to a later file which reused the
+// base record.
+func (res AttributeResolver) BaseRecord(r Record) (Record, error) {
+	if !r.IsExtension() {
+		return r, nil
+	}
+	ref := r.BaseRecordReference
+	base, err := res.Record(ref.RecordNumber)
+	if err != nil {
+		return Record{}, fmt.Errorf("unable to read base record %d: %v", ref.RecordNumber, err)
+	}
+	if base.FileReference.SequenceNumber != ref.SequenceNumber {
+		return Record{}, fmt.Errorf("base record %d has sequence number %d but extension record %d refers to %d", ref.RecordNumber, base.FileReference.SequenceNumber, r.FileReference.RecordNumber, ref.SequenceNumber)
+	}
+	if base.IsExtension() {
+		return Record{}, fmt.Errorf("base record %d of extension record %d is itself an extension record", ref.RecordNumber, r.FileReference.RecordNumber)
+	}
+	return base, nil
+}
+
+// GroupExtensionRecords groups the extension records among the records, for example all records of a raw MFT dump,
+// by the record number of their base record. The record numbers of the extension records of each base record are
+// returned in the order of the records. Records which are not extension records are not included; their base record
+// does not have to be among the records.
+func GroupExtensionRecords(records []Record) map[uint64][]uint64 {
+	groups := make(map[uint64][]uint64)
+	for _, r := range records {
+		if !r.IsExtension() {
+			continue
+		}
+		base := r.BaseRecordReference.RecordNumber
+		groups[base] = append(groups[base], r.FileReference.RecordNumber)
+	}
+	return groups
+}
@@ -0,0 +1,164 @@
+package mft_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/mfttest"
+)
+
+func TestReadAttributeData(t *testing.T) {
+	volume := bytes.Repeat([]byte{0}, 8*512)
+	copy(volume[5*512:], "hello, world")
+	data, err := mft.ReadAttributeData(mft.Attribute{ActualSize: 12,
+		Data: mfttest.EncodeDataRuns([]mft.DataRun{{OffsetCluster: 5, LengthInClusters: 1}})}, bytes.NewReader(volume), 512)
+	require.Nil(t, err)
+	assert.Equal(t, []byte("hello, world"), data)
+
+	data, err = mft.ReadAttributeData(mft.Attribute{Resident: true, Data: []byte("resident")}, nil, 512)
+	require.Nil(t, err)
+	assert.Equal(t, []byte("resident"), data)
+
+	_, err = mft.ReadAttributeData(mft.Attribute{ActualSize: 12}, nil, 512)
+	assert.EqualError(t, err, "attribute is non-resident but no volume is available")
+	_, err = mft.ReadAttributeData(mft.Attribute{ActualSize: 1024,
+		Data: mfttest.EncodeDataRuns([]mft.DataRun{{OffsetCluster: 5, LengthInClusters: 1}})}, bytes.NewReader(volume), 512)
+	assert.EqualError(t, err, "data runs cover 512 bytes, but the attribute is 1024 bytes")
+}
+
+func TestAttributeResolver(t *testing.T) {
+	base := mft.FileReference{RecordNumber: 40, SequenceNumber: 2}
+	list := mfttest.EncodeAttributeList([]mft.AttributeListEntry{
+		{Type: mft.AttributeTypeStandardInformation, BaseRecordReference: base},
+		{Type: mft.AttributeTypeData, BaseRecordReference: mft.FileReference{RecordNumber: 41, SequenceNumber: 1}, AttributeId: 2},
+		{Type: mft.AttributeTypeData, Name: "ads", BaseRecordReference: mft.FileReference{RecordNumber: 42, SequenceNumber: 1}, AttributeId: 5},
+	})
+
+	// The list is stored non-resident in cluster 3 of the volume
+	volume := make([]byte, 8*512)
+	copy(volume[3*512:], list)
+	record := mfttest.NewRecord().WithRecordNumber(40).WithSequenceNumber(2).WithStandardInformation(0).
+		WithAttribute(mft.Attribute{Type: mft.AttributeTypeAttributeList, ActualSize: uint64(len(list)), AllocatedSize: 512,
+			Data: mfttest.EncodeDataRuns([]mft.DataRun{{OffsetCluster: 3, LengthInClusters: 1}})}).Record()
+	records := map[uint64]mft.Record{
+		41: mfttest.NewRecord().WithRecordNumber(41).WithBaseRecord(base).
+			WithAttribute(mft.Attribute{Type: mft.AttributeTypeData, Resident: true, AttributeId: 2, Data: []byte("data")}).Record(),
+		42: mfttest.NewRecord().WithRecordNumber(42).WithBaseRecord(base).
+			WithAttribute(mft.Attribute{Type: mft.AttributeTypeData, Resident: true, AttributeId: 4, Name: "other"}).
+			WithAttribute(mft.Attribute{Type: mft.AttributeTypeData, Resident: true, AttributeId: 5, Name: "ads", Data: []byte("ads")}).Record(),
+	}
+	reads := 0
+	res := mft.AttributeResolver{
+		Record: func(number uint64) (mft.Record, error) {
+			reads++
+			r, ok := records[number]
+			if !ok {
+				return mft.Record{}, fmt.Errorf("no record %d", number)
+			}
+			return r, nil
+		},
+		Volume:          bytes.NewReader(volume),
+		BytesPerCluster: 512,
+	}
+
+	attributes, err := res.Attributes(record)
+	require.Nil(t, err)
+	require.Equal(t, 4, len(attributes))
+	assert.Equal(t, mft.AttributeTypeStandardInformation, attributes[0].Type)
+	assert.Equal(t, mft.AttributeTypeAttributeList, attributes[1].Type)
+	assert.Equal(t, []byte("data"), attributes[2].Data)
+	assert.Equal(t, []byte("ads"), attributes[3].Data)
+	assert.Equal(t, 2, reads)
+
+	// Without a volume, the non-resident list cannot be read
+	res.Volume = nil
+	_, err = res.Attributes(record)
+	assert.EqualError(t, err, "unable to read $ATTRIBUTE_LIST: attribute is non-resident but no volume is available")
+
+	// A record without a list has only its own attributes
+	attributes, err = res.Attributes(records[41])
+	require.Nil(t, err)
+	assert.Equal(t, records[41].Attributes, attributes)
+
+	// Extension records must exist, refer to the base record and contain the listed attributes
+	delete(records, 42)
+	res.Volume = bytes.NewReader(volume)
+	_, err = res.Attributes(record)
+	assert.EqualError(t, err, "unable to read extension record 42: no record 42")
+	records[42] = mfttest.NewRecord().WithRecordNumber(42).WithBaseRecord(mft.FileReference{RecordNumber: 39, SequenceNumber: 1}).Record()
+	_, err = res.Attributes(record)
+	assert.EqualError(t, err, "extension record 42 refers to base record 39 instead of 40")
+	records[42] = mfttest.NewRecord().WithRecordNumber(42).WithBaseRecord(base).Record()
+	_, err = res.Attributes(record)
+	assert.EqualError(t, err, `$DATA attribute "ads" with id 5 not found in extension record 42`)
+}
+
+func TestExtensionRecords(t *testing.T) {
+	base := mft.FileReference{RecordNumber: 40, SequenceNumber: 2}
+	ext41 := mft.FileReference{RecordNumber: 41, SequenceNumber: 1}
+	ext42 := mft.FileReference{RecordNumber: 42, SequenceNumber: 3}
+	list := mfttest.EncodeAttributeList([]mft.AttributeListEntry{
+		{Type: mft.AttributeTypeStandardInformation, BaseRecordReference: base},
+		{Type: mft.AttributeTypeData, BaseRecordReference: ext42, AttributeId: 2},
+		{Type: mft.AttributeTypeData, BaseRecordReference: ext41, AttributeId: 3},
+		{Type: mft.AttributeTypeData, Name: "ads", BaseRecordReference: ext42, AttributeId: 5},
+	})
+	record := mfttest.NewRecord().WithRecordNumber(40).WithSequenceNumber(2).WithStandardInformation(0).
+		WithAttribute(mft.Attribute{Type: mft.AttributeTypeAttributeList, Resident: true, Data: list}).Record()
+
+	refs, err := mft.ParseOptions{}.ExtensionRecords(record, nil, 512)
+	require.Nil(t, err)
+	assert.Equal(t, []mft.FileReference{ext42, ext41}, refs)
+
+	records := map[uint64]mft.Record{
+		40: record,
+		41: mfttest.NewRecord().WithRecordNumber(41).WithBaseRecord(base).Record(),
+		42: mfttest.NewRecord().WithRecordNumber(42).WithBaseRecord(base).Record(),
+	}
+	res := mft.AttributeResolver{Record: func(number uint64) (mft.Record, error) {
+		r, ok := records[number]
+		if !ok {
+			return mft.Record{}, fmt.Errorf("no record %d", number)
+		}
+		return r, nil
+	}}
+	extensions, err := res.ExtensionRecords(record)
+	require.Nil(t, err)
+	assert.Equal(t, []mft.Record{records[42], records[41]}, extensions)
+
+	// Extension records without a list
+	refs, err = mft.ParseOptions{}.ExtensionRecords(records[41], nil, 512)
+	require.Nil(t, err)
+	assert.Equal(t, []mft.FileReference{}, refs)
+
+	// Resolving the base record
+	found, err := res.BaseRecord(records[41])
+	require.Nil(t, err)
+	assert.Equal(t, record, found)
+	found, err = res.BaseRecord(record)
+	require.Nil(t, err)
+	assert.Equal(t, record, found)
+
+	assert.Equal(t, map[uint64][]uint64{40: {41, 42}},
+		mft.GroupExtensionRecords([]mft.Record{records[40], records[41], records[42]}))
+
+	// The base record was reused by a later file, or is an extension record itself
+	records[40] = mfttest.NewRecord().WithRecordNumber(40).WithSequenceNumber(3).Record()
+	_, err = res.BaseRecord(records[41])
+	assert.EqualError(t, err, "base record 40 has sequence number 3 but extension record 41 refers to 2")
+	records[40] = mfttest.NewRecord().WithRecordNumber(40).WithSequenceNumber(2).WithBaseRecord(ext41).Record()
+	_, err = res.BaseRecord(records[41])
+	assert.EqualError(t, err, "base record 40 of extension record 41 is itself an extension record")
+	delete(records, 40)
+	_, err = res.BaseRecord(records[41])
+	assert.EqualError(t, err, "unable to read base record 40: no record 40")
+
+	// Extension records must refer to the base record
+	records[41] = mfttest.NewRecord().WithRecordNumber(41).WithBaseRecord(ext42).Record()
+	_, err = res.ExtensionRecords(record)
+	assert.EqualError(t, err, "extension record 41 refers to base record 42 instead of 40")
+}
@@ -3,16 +3,40 @@ package mft
 import (
 	"encoding/binary"
 	"fmt"
+	"math"
 	"time"
 
 	"github.com/t9t/gomft/binutil"
 	"github.com/t9t/gomft/utf16"
 )
 
-var (
-	reallyStrangeEpoch = time.Date(1601, time.January, 1, 0, 0, 0, 0, time.UTC)
+const (
+	// fileTimeEpochOffset is the number of seconds between the Windows "file time" epoch (January 1, 1601) and the Unix
+	// epoch (January 1, 1970).
+	fileTimeEpochOffset    = 11644473600
+	fileTimeTicksPerSecond = 10000000
+	nanosecondsPerTick     = 100
 )
 
+// RawTimes contains the unconverted "file time" values of the four timestamps in a $STANDARD_INFORMATION or
+// $FILE_NAME attribute. They retain the exact value as stored on disk, which is useful when values cannot be
+// represented as a time.Time faithfully, or to detect unusual values such as timestamps without sub-second precision.
+type RawTimes struct {
+	Creation         uint64
+	FileLastModified uint64
+	MftLastModified  uint64
+	LastAccess       uint64
+}
+
+func parseRawTimes(r *binutil.BinReader, offset int) RawTimes {
+	return RawTimes{
+		Creation:         r.Uint64(offset),
+		FileLastModified: r.Uint64(offset + 0x08),
+		MftLastModified:  r.Uint64(offset + 0x10),
+		LastAccess:       r.Uint64(offset + 0x18),
+	}
+}
+
 // StandardInformation represents the data contained in a $STANDARD_INFORMATION attribute.
 type StandardInformation struct {
 	Creation                time.Time
@@ -27,12 +51,19 @@ type StandardInformation struct {
 	SecurityId              uint32
 	QuotaCharged            uint64
 	UpdateSequenceNumber    uint64
+	RawTimes                RawTimes
 }
 
 // ParseStandardInformation parses the data of a $STANDARD_INFORMATION attribute's data (type
 // AttributeTypeStandardInformation) into StandardInformation. Note that no additional correctness checks are done, so
 // it's up to the caller to ensure the passed data actually represents a $STANDARD_INFORMATION attribute's data.
 func ParseStandardInformation(b []byte) (StandardInformation, error) {
+	return ParseOptions{}.ParseStandardInformation(b)
+}
+
+// ParseStandardInformation parses $STANDARD_INFORMATION attribute data like the ParseStandardInformation function
+// does, converting the times to the Location of the options.
+func (opts ParseOptions) ParseStandardInformation(b []byte) (StandardInformation, error) {
 	if len(b) < 48 {
 		return StandardInformation{}, fmt.Errorf("expected at least %d bytes but got %d", 48, len(b))
 	}
@@ -54,11 +85,12 @@ func ParseStandardInformation(b []byte) (StandardInformation, error) {
 	if len(b) >= 0x40+8 {
 		updateSequenceNumber = r.Uint64(0x40)
 	}
+	raw := parseRawTimes(r, 0x00)
 	return StandardInformation{
-		Creation:                ConvertFileTime(r.Uint64(0x00)),
-		FileLastModified:        ConvertFileTime(r.Uint64(0x08)),
-		MftLastModified:         ConvertFileTime(r.Uint64(0x10)),
-		LastAccess:              ConvertFileTime(r.Uint64(0x18)),
+		Creation:                opts.convertFileTime(raw.Creation),
+		FileLastModified:        opts.convertFileTime(raw.FileLastModified),
+		MftLastModified:         opts.convertFileTime(raw.MftLastModified),
+		LastAccess:              opts.convertFileTime(raw.LastAccess),
 		FileAttributes:          FileAttribute(r.Uint32(0x20)),
 		MaximumNumberOfVersions: r.Uint32(0x24),
 		VersionNumber:           r.Uint32(0x28),
@@ -67,6 +99,7 @@ func ParseStandardInformation(b []byte) (StandardInformation, error) {
 		SecurityId:              securityId,
 		QuotaCharged:            quotaCharged,
 		UpdateSequenceNumber:    updateSequenceNumber,
+		RawTimes:                raw,
 	}, nil
 }
 
@@ -75,26 +108,71 @@ type FileAttribute uint32
 
 // Bit values for FileAttribute. For example, a normal, hidden file has value 0x0082.
 const (
-	FileAttributeReadOnly          FileAttribute = 0x0001
-	FileAttributeHidden            FileAttribute = 0x0002
-	FileAttributeSystem            FileAttribute = 0x0004
-	FileAttributeArchive           FileAttribute = 0x0020
-	FileAttributeDevice            FileAttribute = 0x0040
-	FileAttributeNormal            FileAttribute = 0x0080
-	FileAttributeTemporary         FileAttribute = 0x0100
-	FileAttributeSparseFile        FileAttribute = 0x0200
-	FileAttributeReparsePoint      FileAttribute = 0x0400
-	FileAttributeCompressed        FileAttribute = 0x1000
-	FileAttributeOffline           FileAttribute = 0x1000
-	FileAttributeNotContentIndexed FileAttribute = 0x2000
-	FileAttributeEncrypted         FileAttribute = 0x4000
+	FileAttributeReadOnly           FileAttribute = 0x00000001
+	FileAttributeHidden             FileAttribute = 0x00000002
+	FileAttributeSystem             FileAttribute = 0x00000004
+	FileAttributeArchive            FileAttribute = 0x00000020
+	FileAttributeDevice             FileAttribute = 0x00000040
+	FileAttributeNormal             FileAttribute = 0x00000080
+	FileAttributeTemporary          FileAttribute = 0x00000100
+	FileAttributeSparseFile         FileAttribute = 0x00000200
+	FileAttributeReparsePoint       FileAttribute = 0x00000400
+	FileAttributeCompressed         FileAttribute = 0x00000800
+	FileAttributeOffline            FileAttribute = 0x00001000
+	FileAttributeNotContentIndexed  FileAttribute = 0x00002000
+	FileAttributeEncrypted          FileAttribute = 0x00004000
+	FileAttributeIntegrityStream    FileAttribute = 0x00008000
+	FileAttributeVirtual            FileAttribute = 0x00010000
+	FileAttributeNoScrubData        FileAttribute = 0x00020000
+	FileAttributeRecallOnOpen       FileAttribute = 0x00040000
+	FileAttributePinned             FileAttribute = 0x00080000
+	FileAttributeUnpinned           FileAttribute = 0x00100000
+	FileAttributeRecallOnDataAccess FileAttribute = 0x00400000
+
+	// FileAttributeDirectory and FileAttributeIndexView are only used in the flags of a $FILE_NAME attribute (and
+	// index entries), where they indicate that the file is a directory or has a view index respectively.
+	FileAttributeDirectory FileAttribute = 0x10000000
+	FileAttributeIndexView FileAttribute = 0x20000000
 )
 
+// fileAttributeNames contains the names of the FileAttribute bits, in order of their value.
+var fileAttributeNames = []bitName{
+	{uint64(FileAttributeReadOnly), "ReadOnly"},
+	{uint64(FileAttributeHidden), "Hidden"},
+	{uint64(FileAttributeSystem), "System"},
+	{uint64(FileAttributeArchive), "Archive"},
+	{uint64(FileAttributeDevice), "Device"},
+	{uint64(FileAttributeNormal), "Normal"},
+	{uint64(FileAttributeTemporary), "Temporary"},
+	{uint64(FileAttributeSparseFile), "SparseFile"},
+	{uint64(FileAttributeReparsePoint), "ReparsePoint"},
+	{uint64(FileAttributeCompressed), "Compressed"},
+	{uint64(FileAttributeOffline), "Offline"},
+	{uint64(FileAttributeNotContentIndexed), "NotContentIndexed"},
+	{uint64(FileAttributeEncrypted), "Encrypted"},
+	{uint64(FileAttributeIntegrityStream), "IntegrityStream"},
+	{uint64(FileAttributeVirtual), "Virtual"},
+	{uint64(FileAttributeNoScrubData), "NoScrubData"},
+	{uint64(FileAttributeRecallOnOpen), "RecallOnOpen"},
+	{uint64(FileAttributePinned), "Pinned"},
+	{uint64(FileAttributeUnpinned), "Unpinned"},
+	{uint64(FileAttributeRecallOnDataAccess), "RecallOnDataAccess"},
+	{uint64(FileAttributeDirectory), "Directory"},
+	{uint64(FileAttributeIndexView), "IndexView"},
+}
+
 // Is checks if this FileAttribute's bit mask contains the specified attribute value.
 func (a *FileAttribute) Is(c FileAttribute) bool {
 	return *a&c == c
 }
 
+// String returns the names of the attributes set in the bit mask, separated by a pipe, for example
+// "Hidden|System|Archive". Unknown bits are included as a single hexadecimal value; a bit mask without any bits set
+// returns "0".
+func (a FileAttribute) String() string {
+	return formatBits(uint64(a), fileAttributeNames)
+}
+
 // FileNameNamespace indicates the namespace of a $FILE_NAME attribute's file name.
 type FileNameNamespace byte
 
@@ -105,6 +183,22 @@ const (
 	FileNameNamespaceWin32Dos FileNameNamespace = 3
 )
 
+// String returns the name of the namespace, for example "Win32" or "Win32Dos". An unknown namespace is returned as a
+// hexadecimal value.
+func (n FileNameNamespace) String() string {
+	switch n {
+	case FileNameNamespacePosix:
+		return "Posix"
+	case FileNameNamespaceWin32:
+		return "Win32"
+	case FileNameNamespaceDos:
+		return "Dos"
+	case FileNameNamespaceWin32Dos:
+		return "Win32Dos"
+	}
+	return fmt.Sprintf("0x%x", byte(n))
+}
+
 // FileName represents the data of a $FILE_NAME attribute. ParentFileReference points to the MFT record that is the
 // parent (ie. containing directory of this file). The AllocatedSize and ActualSize may be zero, in which case the file
 // size may be found in a $DATA attribute instead (it could also be the ActualSize is zero, while the AllocatedSize does
@@ -121,12 +215,26 @@ type FileName struct {
 	ExtendedData        uint32
 	Namespace           FileNameNamespace
 	Name                string
+	RawTimes            RawTimes
 }
 
 // ParseFileName parses the data of a $FILE_NAME attribute's data (type AttributeTypeFileName) into FileName. Note that
 // no additional correctness checks are done, so it's up to the caller to ensure the passed data actually represents a
 // $FILE_NAME attribute's data.
 func ParseFileName(b []byte) (FileName, error) {
+	return ParseFileNameWithOptions(b, utf16.DecodeOptions{})
+}
+
+// ParseFileNameWithOptions parses $FILE_NAME attribute data like ParseFileName does, decoding the name using the
+// specified options. This allows names containing invalid UTF-16, NUL characters or characters which are not allowed
+// in Windows file names to be escaped or rejected, so they can be exported unambiguously.
+func ParseFileNameWithOptions(b []byte, opts utf16.DecodeOptions) (FileName, error) {
+	return ParseOptions{Names: opts}.ParseFileName(b)
+}
+
+// ParseFileName parses $FILE_NAME attribute data like the ParseFileName function does, decoding the name using the
+// Names of the options and converting the times to their Location.
+func (opts ParseOptions) ParseFileName(b []byte) (FileName, error) {
 	if len(b) < 66 {
 		return FileName{}, fmt.Errorf("expected at least %d bytes but got %d", 66, len(b))
 	}
@@ -142,18 +250,24 @@ func ParseFileName(b []byte) (FileName, error) {
 	if err != nil {
 		return FileName{}, fmt.Errorf("unable to parse file reference: %v", err)
 	}
+	name, err := utf16.DecodeStringWithOptions(r.Read(0x42, fileNameLength), binary.LittleEndian, opts.Names)
+	if err != nil {
+		return FileName{}, fmt.Errorf("unable to decode file name: %v", err)
+	}
+	raw := parseRawTimes(r, 0x08)
 	return FileName{
 		ParentFileReference: parentRef,
-		Creation:            ConvertFileTime(r.Uint64(0x08)),
-		FileLastModified:    ConvertFileTime(r.Uint64(0x10)),
-		MftLastModified:     ConvertFileTime(r.Uint64(0x18)),
-		LastAccess:          ConvertFileTime(r.Uint64(0x20)),
+		Creation:            opts.convertFileTime(raw.Creation),
+		FileLastModified:    opts.convertFileTime(raw.FileLastModified),
+		MftLastModified:     opts.convertFileTime(raw.MftLastModified),
+		LastAccess:          opts.convertFileTime(raw.LastAccess),
 		AllocatedSize:       r.Uint64(0x28),
 		ActualSize:          r.Uint64(0x30),
 		Flags:               FileAttribute(r.Uint32(0x38)),
 		ExtendedData:        r.Uint32(0x3c),
 		Namespace:           FileNameNamespace(r.Byte(0x41)),
-		Name:                utf16.DecodeString(r.Read(0x42, fileNameLength), binary.LittleEndian),
+		Name:                name,
+		RawTimes:            raw,
 	}, nil
 }
 
@@ -172,6 +286,12 @@ type AttributeListEntry struct {
 // list of AttributeListEntry. Note that no additional correctness checks are done, so it's up to the caller to ensure
 // the passed data actually represents a $ATTRIBUTE_LIST attribute's data.
 func ParseAttributeList(b []byte) ([]AttributeListEntry, error) {
+	return ParseOptions{}.ParseAttributeList(b)
+}
+
+// ParseAttributeList parses $ATTRIBUTE_LIST attribute data like the ParseAttributeList function does, decoding the
+// attribute names using the Names of the options.
+func (opts ParseOptions) ParseAttributeList(b []byte) ([]AttributeListEntry, error) {
 	if len(b) < 26 {
 		return []AttributeListEntry{}, fmt.Errorf("expected at least %d bytes but got %d", 26, len(b))
 	}
@@ -179,8 +299,14 @@ func ParseAttributeList(b []byte) ([]AttributeListEntry, error) {
 	entries := make([]AttributeListEntry, 0)
 
 	for len(b) > 0 {
+		if len(b) < 26 {
+			return entries, fmt.Errorf("expected at least %d bytes remaining for AttributeList entry but is %d", 26, len(b))
+		}
 		r := binutil.NewLittleEndianReader(b)
 		entryLength := int(r.Uint16(0x04))
+		if entryLength < 26 {
+			return entries, fmt.Errorf("AttributeList entry length %d is less than the minimum of %d", entryLength, 26)
+		}
 		if len(b) < entryLength {
 			return entries, fmt.Errorf("expected at least %d bytes remaining for AttributeList entry but is %d", entryLength, len(b))
 		}
@@ -188,7 +314,14 @@ func ParseAttributeList(b []byte) ([]AttributeListEntry, error) {
 		name := ""
 		if nameLength != 0 {
 			nameOffset := int(r.Byte(0x07))
-			name = utf16.DecodeString(r.Read(nameOffset, nameLength*2), binary.LittleEndian)
+			if err := checkBounds("attribute name", b[:entryLength], int64(nameOffset), int64(nameLength)*2); err != nil {
+				return entries, err
+			}
+			decoded, err := utf16.DecodeStringWithOptions(r.Read(nameOffset, nameLength*2), binary.LittleEndian, opts.Names)
+			if err != nil {
+				return entries, fmt.Errorf("unable to decode attribute name: %v", err)
+			}
+			name = decoded
 		}
 		baseRef, err := ParseFileReference(r.Read(0x10, 8))
 		if err != nil {
@@ -220,6 +353,28 @@ const (
 	CollationTypeNtofsUlongs       CollationType = 0x00000013
 )
 
+// String returns the name of the collation type, for example "FileName". An unknown collation type is returned as a
+// hexadecimal value.
+func (c CollationType) String() string {
+	switch c {
+	case CollationTypeBinary:
+		return "Binary"
+	case CollationTypeFileName:
+		return "FileName"
+	case CollationTypeUnicodeString:
+		return "UnicodeString"
+	case CollationTypeNtofsULong:
+		return "NtofsULong"
+	case CollationTypeNtofsSid:
+		return "NtofsSid"
+	case CollationTypeNtofsSecurityHash:
+		return "NtofsSecurityHash"
+	case CollationTypeNtofsUlongs:
+		return "NtofsUlongs"
+	}
+	return fmt.Sprintf("0x%x", uint32(c))
+}
+
 // IndexRoot represents the data (header and entries) of an $INDEX_ROOT attribute, which typically is the root of a
 // directory's B+tree index containing file names of the directory (but could be use for other types of indices, too).
 // The AttributeType is the type of attributes that are contained in the entries (currently only $FILE_NAME attributes
@@ -261,6 +416,12 @@ type IndexBlock struct {
 // IndexRoot. Note that no additional correctness checks are done, so it's up to the caller to ensure the passed data
 // actually represents a $INDEX_ROOT attribute's data.
 func ParseIndexRoot(b []byte) (IndexRoot, error) {
+	return ParseOptions{}.ParseIndexRoot(b)
+}
+
+// ParseIndexRoot parses $INDEX_ROOT attribute data like the ParseIndexRoot function does, parsing the $FILE_NAME of
+// each entry using the options.
+func (opts ParseOptions) ParseIndexRoot(b []byte) (IndexRoot, error) {
 	if len(b) < 32 {
 		return IndexRoot{}, fmt.Errorf("expected at least %d bytes but got %d", 32, len(b))
 	}
@@ -281,7 +442,7 @@ func ParseIndexRoot(b []byte) (IndexRoot, error) {
 	}
 	entries := []IndexEntry{}
 	if totalSize >= 16 {
-		parsed, err := ParseIndexEntries(r.Read(0x20, totalSize-16))
+		parsed, err := opts.ParseIndexEntries(r.Read(0x20, totalSize-16))
 		if err != nil {
 			return IndexRoot{}, fmt.Errorf("error parsing index entries: %v", err)
 		}
@@ -310,6 +471,9 @@ func ParseIndexBlock(b []byte) (IndexBlock, error) {
 	signature := string(r.Read(0x00, 0x04))
 	sequenceNumberOffset := r.Uint16(0x04)
 	sequenceNumberSize := r.Uint16(0x06)
+	if err := checkBounds("update sequence number", b, int64(sequenceNumberOffset), 2); err != nil {
+		return IndexBlock{}, err
+	}
 	updateSequenceNumber := r.Uint16(int(sequenceNumberOffset))
 	lsn := r.Uint64(0x08)
 
@@ -331,13 +495,25 @@ func ParseIndexBlock(b []byte) (IndexBlock, error) {
 
 // ParseIndexEntries parses the given raw bytes into a list of IndexEntry objects.
 func ParseIndexEntries(b []byte) ([]IndexEntry, error) {
+	return ParseOptions{}.ParseIndexEntries(b)
+}
+
+// ParseIndexEntries parses index entries like the ParseIndexEntries function does, parsing the $FILE_NAME of each
+// entry using the options.
+func (opts ParseOptions) ParseIndexEntries(b []byte) ([]IndexEntry, error) {
 	if len(b) < 13 {
 		return []IndexEntry{}, fmt.Errorf("expected at least %d bytes but got %d", 13, len(b))
 	}
 	entries := make([]IndexEntry, 0)
 	for len(b) > 0 {
+		if len(b) < 0x10 {
+			return entries, fmt.Errorf("expected at least %d bytes remaining for index entry but is %d", 0x10, len(b))
+		}
 		r := binutil.NewLittleEndianReader(b)
 		entryLength := int(r.Uint16(0x08))
+		if entryLength < 0x10 {
+			return entries, fmt.Errorf("index entry length %d is less than the minimum of %d", entryLength, 0x10)
+		}
 
 		if len(b) < entryLength {
 			return entries, fmt.Errorf("index entry length indicates %d bytes but got %d", entryLength, len(b))
@@ -350,7 +526,10 @@ func ParseIndexEntries(b []byte) ([]IndexEntry, error) {
 
 		fileName := FileName{}
 		if contentLength != 0 && !isLastEntryInNode {
-			parsedFileName, err := ParseFileName(r.Read(0x10, contentLength))
+			if err := checkBounds("index entry content", b[:entryLength], 0x10, int64(contentLength)); err != nil {
+				return entries, err
+			}
+			parsedFileName, err := opts.ParseFileName(r.Read(0x10, contentLength))
 			if err != nil {
 				return entries, fmt.Errorf("error parsing $FILE_NAME record in index entry: %v", err)
 			}
@@ -382,12 +561,35 @@ func ParseIndexEntries(b []byte) ([]IndexEntry, error) {
 
 // ConvertFileTime converts a Windows "file time" to a time.Time. A "file time" is a 64-bit value that represents the
 // number of 100-nanosecond intervals that have elapsed since 12:00 A.M. January 1, 1601 Coordinated Universal Time
-// (UTC). See also: https://docs.microsoft.com/en-us/windows/win32/sysinfo/file-times
+// (UTC). The full range of values is supported, including those beyond the range of a time.Duration. The returned time
+// is in UTC. See also: https://docs.microsoft.com/en-us/windows/win32/sysinfo/file-times
 func ConvertFileTime(timeValue uint64) time.Time {
-	dur := time.Duration(int64(timeValue))
-	r := time.Date(1601, time.January, 1, 0, 0, 0, 0, time.UTC)
-	for i := 0; i < 100; i++ {
-		r = r.Add(dur)
+	seconds := int64(timeValue/fileTimeTicksPerSecond) - fileTimeEpochOffset
+	nanoseconds := int64(timeValue%fileTimeTicksPerSecond) * nanosecondsPerTick
+	return time.Unix(seconds, nanoseconds).UTC()
+}
+
+// convertFileTime converts a Windows "file time" like ConvertFileTime, to the Location of the options.
+func (opts ParseOptions) convertFileTime(timeValue uint64) time.Time {
+	t := ConvertFileTime(timeValue)
+	if opts.Location != nil {
+		t = t.In(opts.Location)
+	}
+	return t
+}
+
+// ConvertToFileTime converts a time.Time to a Windows "file time"; it is the inverse of ConvertFileTime. Since a
+// "file time" has a precision of 100 nanoseconds, any remaining nanoseconds are truncated. Times before January 1,
+// 1601 are converted to 0 and times beyond the maximum "file time" (in the year 60056) to the maximum value.
+func ConvertToFileTime(t time.Time) uint64 {
+	seconds := t.Unix() + fileTimeEpochOffset
+	if seconds < 0 {
+		return 0
+	}
+	ticks := uint64(t.Nanosecond() / nanosecondsPerTick)
+	maxSeconds := uint64(math.MaxUint64 / fileTimeTicksPerSecond)
+	if uint64(seconds) > maxSeconds || (uint64(seconds) == maxSeconds && ticks > math.MaxUint64%fileTimeTicksPerSecond) {
+		return math.MaxUint64
 	}
-	return r
+	return uint64(seconds)*fileTimeTicksPerSecond + ticks
 }
@@ -1,12 +1,14 @@
 package mft_test
 
 import (
+	"encoding/binary"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/utf16"
 )
 
 func TestFileAttribute(t *testing.T) {
@@ -20,6 +22,36 @@ func TestFileAttribute(t *testing.T) {
 	assert.False(t, a.Is(mft.FileAttributeCompressed))
 }
 
+func TestFileAttribute_Values(t *testing.T) {
+	assert.Equal(t, mft.FileAttribute(0x0800), mft.FileAttributeCompressed)
+	assert.Equal(t, mft.FileAttribute(0x1000), mft.FileAttributeOffline)
+	assert.Equal(t, mft.FileAttribute(0x10000000), mft.FileAttributeDirectory)
+	assert.Equal(t, mft.FileAttribute(0x20000000), mft.FileAttributeIndexView)
+}
+
+func TestFileAttribute_String(t *testing.T) {
+	assert.Equal(t, "0", mft.FileAttribute(0).String())
+	assert.Equal(t, "ReadOnly|Hidden|Normal", mft.FileAttribute(0x83).String())
+	assert.Equal(t, "Compressed|Offline", (mft.FileAttributeCompressed | mft.FileAttributeOffline).String())
+	assert.Equal(t, "Archive|RecallOnDataAccess|Directory", mft.FileAttribute(0x10400020).String())
+	assert.Equal(t, "System|0x80200000", mft.FileAttribute(0x80200004).String())
+}
+
+func TestFileNameNamespace_String(t *testing.T) {
+	assert.Equal(t, "Posix", mft.FileNameNamespacePosix.String())
+	assert.Equal(t, "Win32", mft.FileNameNamespaceWin32.String())
+	assert.Equal(t, "Dos", mft.FileNameNamespaceDos.String())
+	assert.Equal(t, "Win32Dos", mft.FileNameNamespaceWin32Dos.String())
+	assert.Equal(t, "0x4", mft.FileNameNamespace(4).String())
+}
+
+func TestCollationType_String(t *testing.T) {
+	assert.Equal(t, "Binary", mft.CollationTypeBinary.String())
+	assert.Equal(t, "FileName", mft.CollationTypeFileName.String())
+	assert.Equal(t, "NtofsSecurityHash", mft.CollationTypeNtofsSecurityHash.String())
+	assert.Equal(t, "0x20", mft.CollationType(0x20).String())
+}
+
 func TestParseStandardInformation(t *testing.T) {
 	input := decodeHex(t, "8d07703c89d7d5018d07703c89d6d5018d07703c89d6d5018d07703c89d6d501200000000000A30005000000010000000070000001100000000010000000000028820f4b05000000")
 	out, err := mft.ParseStandardInformation(input)
@@ -37,6 +69,7 @@ func TestParseStandardInformation(t *testing.T) {
 		SecurityId:              4097,
 		QuotaCharged:            1048576,
 		UpdateSequenceNumber:    22734144040,
+		RawTimes:                mft.RawTimes{Creation: 132248748501763981, FileLastModified: 132247648990136205, MftLastModified: 132247648990136205, LastAccess: 132247648990136205},
 	}
 	assert.Equal(t, expected, out)
 }
@@ -57,10 +90,51 @@ func TestParseFileName(t *testing.T) {
 		ExtendedData:        0,
 		Namespace:           3,
 		Name:                "logo-250.png",
+		RawTimes:            mft.RawTimes{Creation: 132207901491750000, FileLastModified: 130535632220000000, MftLastModified: 132207901491760000, LastAccess: 132207901491750000},
 	}
 	assert.Equal(t, expected, out)
 }
 
+func TestParseFileNameWithOptions(t *testing.T) {
+	input := fileNameData("a:b", mft.FileNameNamespacePosix)
+	out, err := mft.ParseFileNameWithOptions(input, utf16.DecodeOptions{Reserved: utf16.HandleEscape})
+	require.Nilf(t, err, "could not parse attribute: %v", err)
+	assert.Equal(t, `a\u003Ab`, out.Name)
+
+	_, err = mft.ParseFileNameWithOptions(input, utf16.DecodeOptions{Reserved: utf16.HandleError})
+	assert.EqualError(t, err, "unable to decode file name: reserved character 0x003a at offset 2")
+
+	out, err = mft.ParseFileName(input)
+	require.Nilf(t, err, "could not parse attribute: %v", err)
+	assert.Equal(t, "a:b", out.Name)
+}
+
+func TestParseOptions_ParseFileName(t *testing.T) {
+	input := decodeHex(t, "e2680900000004007064eacc62b2d501000f014577c1cf01808beacc62b2d5017064eacc62b2d50100a00100000000002a9801000000000020000000000000000c036c006f0067006f002d003200350030002e0070006e006700")
+	amsterdam := time.FixedZone("CET", 3600)
+	out, err := mft.ParseOptions{Location: amsterdam}.ParseFileName(input)
+	require.Nilf(t, err, "could not parse attribute: %v", err)
+	assert.Equal(t, "logo-250.png", out.Name)
+	assert.Equal(t, amsterdam, out.Creation.Location())
+	assert.True(t, time.Date(2019, time.December, 14, 9, 42, 29, 175000000, time.UTC).Equal(out.Creation))
+
+	_, err = mft.ParseOptions{Names: utf16.DecodeOptions{Reserved: utf16.HandleError}}.ParseFileName(fileNameData("a:b", mft.FileNameNamespacePosix))
+	assert.EqualError(t, err, "unable to decode file name: reserved character 0x003a at offset 2")
+}
+
+func TestParseOptions_ParseStandardInformation(t *testing.T) {
+	input := make([]byte, 72)
+	binary.LittleEndian.PutUint64(input, mft.ConvertToFileTime(time.Date(2020, time.March, 1, 12, 0, 0, 0, time.UTC)))
+	loc := time.FixedZone("UTC+2", 2*3600)
+	out, err := mft.ParseOptions{Location: loc}.ParseStandardInformation(input)
+	require.Nilf(t, err, "could not parse attribute: %v", err)
+	assert.Equal(t, "2020-03-01T14:00:00+02:00", out.Creation.Format(time.RFC3339))
+
+	out, err = mft.ParseStandardInformation(input)
+	require.Nilf(t, err, "could not parse attribute: %v", err)
+	assert.Equal(t, time.UTC, out.Creation.Location())
+}
+
 func TestParseAttributeList(t *testing.T) {
 	input := decodeHex(t, "100000002000001a00000000000000003b410500000009000000444300000000300000002000001a00000000000000003b410500000009000500000000000000800000002000001a00000000000000004e1905000000a9000000000000000000800000002000001abaec01000000000052400500000049000000000000000000800000002000001ab7180300000000000241050000000f000000000000000000800000002000001a103e0400000000000941050000001d000000000000000000")
 	out, err := mft.ParseAttributeList(input)
@@ -104,6 +178,7 @@ func TestParseIndexRoot(t *testing.T) {
 					ExtendedData:        0,
 					Namespace:           3,
 					Name:                "test.txt",
+					RawTimes:            mft.RawTimes{Creation: 132253883781168862, FileLastModified: 132253883781168862, MftLastModified: 132253883795954456, LastAccess: 132253883781168862},
 				},
 				SubNodeVCN: 0x0,
 			},
@@ -112,3 +187,37 @@ func TestParseIndexRoot(t *testing.T) {
 	}
 	assert.Equal(t, expected, out)
 }
+
+func TestParseIndexEntriesZeroLength(t *testing.T) {
+	input := decodeHex(t, "0000000000000000000000000000000000000000")
+	_, err := mft.ParseIndexEntries(input)
+	require.NotNil(t, err, "expected an error for an index entry of length 0")
+}
+
+func TestConvertFileTime(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    uint64
+		expected time.Time
+	}{
+		{"epoch", 0, time.Date(1601, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{"unix epoch", 116444736000000000, time.Date(1970, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{"100ns precision", 132248748501763981, time.Date(2020, time.January, 30, 16, 20, 50, 176398100, time.UTC)},
+		{"beyond time.Duration range", 0x7FFFFFFFFFFFFFFF, time.Date(30828, time.September, 14, 2, 48, 5, 477580700, time.UTC)},
+		{"maximum", 0xFFFFFFFFFFFFFFFF, time.Date(60056, time.May, 28, 5, 36, 10, 955161500, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, mft.ConvertFileTime(tt.input))
+			assert.Equal(t, tt.input, mft.ConvertToFileTime(tt.expected))
+		})
+	}
+}
+
+func TestConvertToFileTime(t *testing.T) {
+	assert.Equal(t, uint64(0), mft.ConvertToFileTime(time.Date(1600, time.December, 31, 23, 59, 59, 0, time.UTC)))
+	assert.Equal(t, uint64(0xFFFFFFFFFFFFFFFF), mft.ConvertToFileTime(time.Date(60056, time.May, 28, 5, 36, 10, 955161600, time.UTC)))
+	assert.Equal(t, uint64(0xFFFFFFFFFFFFFFFF), mft.ConvertToFileTime(time.Date(60057, time.January, 1, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, uint64(116444736000000001), mft.ConvertToFileTime(time.Date(1970, time.January, 1, 0, 0, 0, 199, time.UTC)))
+	assert.Equal(t, uint64(116444736000000000), mft.ConvertToFileTime(time.Date(1970, time.January, 1, 1, 0, 0, 0, time.FixedZone("CET", 3600))))
+}
@@ -0,0 +1,83 @@
+package mft
+
+import "fmt"
+
+// BitmapRecordNumber is the number of the MFT record of the $Bitmap metafile, whose $DATA attribute contains the
+// allocation status of each cluster of the volume.
+const BitmapRecordNumber = 6
+
+// Bitmap is the data of a $Bitmap metafile or $BITMAP attribute: one bit per cluster or record, where a set bit means
+// it is allocated. The bits are stored from the least significant bit of each byte on.
+type Bitmap []byte
+
+// Len returns the number of bits in the Bitmap.
+func (b Bitmap) Len() uint64 {
+	return uint64(len(b)) * 8
+}
+
+// IsSet indicates whether bit n is set. Bits beyond the end of the Bitmap are not set.
+func (b Bitmap) IsSet(n uint64) bool {
+	if n >= b.Len() {
+		return false
+	}
+	return b[n/8]&(1<<(n%8)) != 0
+}
+
+// RecordRange is a range of consecutive record numbers.
+type RecordRange struct {
+	First uint64
+	Count uint64
+}
+
+// MftUsage describes which record slots of an MFT are allocated, according to the $BITMAP attribute of its $MFT
+// record.
+type MftUsage struct {
+	Slots            uint64        // the number of record slots
+	Allocated        uint64        // the number of allocated record slots
+	HighestAllocated uint64        // the highest allocated record number; only valid when Allocated is not 0
+	Holes            []RecordRange // the ranges of free record slots below HighestAllocated, in order
+}
+
+// Free returns the number of free record slots.
+func (u MftUsage) Free() uint64 {
+	return u.Slots - u.Allocated
+}
+
+// MftUsage returns the usage of the record slots of an MFT, when the Bitmap is the $BITMAP attribute of its $MFT
+// record. The bitmap usually has more bits than the MFT has slots, so the number of slots (the size of the $DATA
+// attribute of $MFT divided by the record size) should be given; when it is 0 or exceeds the number of bits, all bits
+// of the Bitmap are used.
+func (b Bitmap) MftUsage(slots uint64) MftUsage {
+	if slots == 0 || slots > b.Len() {
+		slots = b.Len()
+	}
+	u := MftUsage{Slots: slots, Holes: make([]RecordRange, 0)}
+	free := RecordRange{}
+	for n := uint64(0); n < slots; n++ {
+		if !b.IsSet(n) {
+			if free.Count == 0 {
+				free.First = n
+			}
+			free.Count++
+			continue
+		}
+		if free.Count > 0 {
+			u.Holes = append(u.Holes, free)
+		}
+		free.Count = 0
+		u.Allocated++
+		u.HighestAllocated = n
+	}
+	return u
+}
+
+// FindMftBitmap returns the $BITMAP attribute of a $MFT record. Its data is resident in small MFTs, but usually
+// non-resident, in which case the data runs should be read from the volume before converting it into a Bitmap.
+func FindMftBitmap(r Record) (Attribute, error) {
+	for _, a := range r.FindAttributes(AttributeTypeBitmap) {
+		if a.Name == "" {
+			return a, nil
+		}
+	}
+	return Attribute{}, fmt.Errorf("no $BITMAP attribute found")
+}
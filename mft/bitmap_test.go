@@ -0,0 +1,45 @@
+package mft_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/mft"
+)
+
+func TestBitmap(t *testing.T) {
+	b := mft.Bitmap{0x05, 0x80}
+	assert.Equal(t, uint64(16), b.Len())
+	assert.True(t, b.IsSet(0))
+	assert.False(t, b.IsSet(1))
+	assert.True(t, b.IsSet(2))
+	assert.True(t, b.IsSet(15))
+	assert.False(t, b.IsSet(16))
+}
+
+func TestBitmap_MftUsage(t *testing.T) {
+	// Records 0-3, 6 and 9 allocated; 12-15 beyond the end of the MFT
+	b := mft.Bitmap{0x4f, 0xf2}
+	u := b.MftUsage(12)
+	assert.Equal(t, mft.MftUsage{Slots: 12, Allocated: 6, HighestAllocated: 9,
+		Holes: []mft.RecordRange{{First: 4, Count: 2}, {First: 7, Count: 2}}}, u)
+	assert.Equal(t, uint64(6), u.Free())
+
+	assert.Equal(t, uint64(16), b.MftUsage(0).Slots)
+	assert.Equal(t, uint64(15), b.MftUsage(100).HighestAllocated)
+	assert.Equal(t, mft.MftUsage{Slots: 8, Holes: []mft.RecordRange{}}, mft.Bitmap{0}.MftUsage(0))
+}
+
+func TestFindMftBitmap(t *testing.T) {
+	r := mft.Record{Attributes: []mft.Attribute{
+		{Type: mft.AttributeTypeBitmap, Name: "$I30", Data: []byte{2}},
+		{Type: mft.AttributeTypeBitmap, Data: []byte{1}},
+	}}
+	a, err := mft.FindMftBitmap(r)
+	require.Nil(t, err)
+	assert.Equal(t, []byte{1}, a.Data)
+
+	_, err = mft.FindMftBitmap(mft.Record{})
+	assert.EqualError(t, err, "no $BITMAP attribute found")
+}
@@ -0,0 +1,31 @@
+package mft
+
+import (
+	"fmt"
+	"strings"
+)
+
+// bitName associates a name with a bit (or combination of bits) of a bit mask type.
+type bitName struct {
+	bit  uint64
+	name string
+}
+
+// formatBits returns the names of the bits set in v, separated by a pipe. Bits that have no name are appended as a
+// single hexadecimal value, and a value without any bits set is returned as "0".
+func formatBits(v uint64, names []bitName) string {
+	if v == 0 {
+		return "0"
+	}
+	parts := make([]string, 0)
+	for _, n := range names {
+		if v&n.bit == n.bit {
+			parts = append(parts, n.name)
+			v &^= n.bit
+		}
+	}
+	if v != 0 {
+		parts = append(parts, fmt.Sprintf("0x%x", v))
+	}
+	return strings.Join(parts, "|")
+}
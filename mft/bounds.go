@@ -0,0 +1,26 @@
+package mft
+
+import "fmt"
+
+// OutOfBoundsError is returned by the parsers when an offset or length found in the data refers to a part beyond the
+// end of the data, which is the case for truncated or corrupt data. The parsers check such offsets and lengths before
+// using them, so malformed data results in an error instead of a panic.
+type OutOfBoundsError struct {
+	What     string // what was to be read, for example "attribute name"
+	Offset   int64
+	Length   int64
+	DataSize int
+}
+
+func (e *OutOfBoundsError) Error() string {
+	return fmt.Sprintf("%s at offset %d with length %d exceeds data length %d", e.What, e.Offset, e.Length, e.DataSize)
+}
+
+// checkBounds returns an *OutOfBoundsError when length bytes at offset are not within b. The offset and length are
+// int64 so values read from the data cannot overflow when added.
+func checkBounds(what string, b []byte, offset int64, length int64) error {
+	if offset < 0 || length < 0 || offset+length > int64(len(b)) {
+		return &OutOfBoundsError{What: what, Offset: offset, Length: length, DataSize: len(b)}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package mft_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/mfttest"
+)
+
+func TestOutOfBounds_Regressions(t *testing.T) {
+	var outOfBounds *mft.OutOfBoundsError
+
+	_, err := mft.ApplyFixup(nil)
+	require.IsType(t, outOfBounds, err)
+	assert.Equal(t, "fixup header at offset 0 with length 8 exceeds data length 0", err.Error())
+
+	// Update sequence number offset beyond the end of the index block
+	indx := []byte{'I', 'N', 'D', 'X', 0xFF, 0xFF, 0x03, 0x00, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	_, err = mft.ParseIndexBlock(append(indx, make([]byte, 0x20)...))
+	assert.IsType(t, outOfBounds, err)
+
+	// Attribute name beyond the end of the attribute
+	attribute := mfttest.EncodeAttribute(mft.Attribute{Type: mft.AttributeTypeData, Resident: true, Name: "stream"})
+	attribute[0x0A] = 0xF0
+	_, err = mft.ParseAttribute(attribute)
+	assert.IsType(t, outOfBounds, err)
+
+	// Non-resident attribute header cut short
+	attribute = mfttest.EncodeAttribute(mft.Attribute{Type: mft.AttributeTypeData, Data: []byte{0x00}})
+	attribute[0x04] = 0x20
+	_, err = mft.ParseAttribute(attribute[:0x20])
+	assert.IsType(t, outOfBounds, err)
+
+	// Attribute list entries with a length of zero used to make the parser loop forever
+	_, err = mft.ParseAttributeList(make([]byte, 32))
+	assert.NotNil(t, err)
+	_, err = mft.ParseAttributeList(make([]byte, 8))
+	assert.NotNil(t, err)
+
+	// Index entries shorter than the entry header
+	_, err = mft.ParseIndexEntries([]byte{0x05, 0, 0, 0})
+	assert.NotNil(t, err)
+	entry := make([]byte, 0x10)
+	entry[0x08], entry[0x0A] = 0x10, 0x40
+	_, err = mft.ParseIndexEntries(entry)
+	assert.IsType(t, outOfBounds, err)
+}
+
+func TestOutOfBounds_Mutations(t *testing.T) {
+	v := mfttest.NewVolume().
+		WithFile("/a/b.txt", make([]byte, 5000)).
+		WithFile("/c.txt", []byte("hi")).
+		WithDirectory("/d").
+		Build()
+	inputs := make([][]byte, 0)
+	for _, n := range []uint64{0, mft.VolumeRecordNumber, 5, v.Records["/a"], v.Records["/a/b.txt"], v.Records["/c.txt"]} {
+		inputs = append(inputs, v.Mft()[n*1024:(n+1)*1024])
+	}
+
+	random := rand.New(rand.NewSource(1))
+	for _, input := range inputs {
+		for i := 0; i < 500; i++ {
+			b := append([]byte(nil), input...)
+			for j := random.Intn(8); j >= 0; j-- {
+				b[random.Intn(len(b))] = byte(random.Intn(256))
+			}
+			assertNoPanic(t, b[:random.Intn(len(b)+1)])
+			assertNoPanic(t, b)
+		}
+	}
+}
+
+func assertNoPanic(t *testing.T, b []byte) {
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("panic parsing %x: %v", b, r)
+		}
+	}()
+	parseEverything(b)
+}
+
+// parseEverything runs all parsers on b, including on the attributes if b is parsed as a record.
+func parseEverything(b []byte) {
+	options := []mft.ParseOptions{{}, {Relaxed: true}, {Strict: true}, {ZeroCopy: true, RestoreFixup: true}, {Relaxed: true, SectorSize: 512}}
+	for _, opts := range options {
+		record, err := mft.ParseRecordWithOptions(append([]byte(nil), b...), opts)
+		if err == nil {
+			for _, a := range record.Attributes {
+				parseAttributeData(a.Data)
+			}
+		}
+	}
+	mft.ParseAttributes(b)
+	mft.ApplyFixup(append([]byte(nil), b...))
+	mft.ParseFileReference(b)
+	parseAttributeData(b)
+}
+
+func parseAttributeData(b []byte) {
+	mft.ParseDataRuns(b)
+	mft.ParseStandardInformation(b)
+	mft.ParseFileName(b)
+	mft.ParseAttributeList(b)
+	mft.ParseIndexRoot(b)
+	mft.ParseIndexBlock(b)
+	mft.ParseIndexEntries(b)
+	mft.ParseVolumeInformation(b)
+	mft.ParseEFS(b)
+	mft.ParseTxfData(b)
+	mft.ParseSecurityDescriptorStream(b)
+	mft.ParseSecurityDescriptor(b)
+	mft.ParseSID(b)
+	if rp, err := mft.ParseReparsePoint(b); err == nil {
+		mft.ParseLinkTarget(rp)
+	}
+	if ea, err := mft.ParseExtendedAttributes(b); err == nil {
+		mft.ParseWSLMetadata(ea)
+	}
+}
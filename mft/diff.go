@@ -0,0 +1,229 @@
+package mft
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// DifferenceKind is the kind of a Difference found by DiffRecords.
+type DifferenceKind int
+
+// Kinds of differences found by DiffRecords.
+const (
+	DifferenceChanged          DifferenceKind = iota // a field of the record header or of an attribute has changed
+	DifferenceAttributeAdded                         // an attribute is only present in the new record
+	DifferenceAttributeRemoved                       // an attribute is only present in the old record
+)
+
+func (k DifferenceKind) String() string {
+	switch k {
+	case DifferenceChanged:
+		return "changed"
+	case DifferenceAttributeAdded:
+		return "added"
+	case DifferenceAttributeRemoved:
+		return "removed"
+	}
+	return "unknown"
+}
+
+// Difference describes a single difference between two parses of a record. Field names the record header field (such
+// as "LogFileSequenceNumber"), the attribute (such as "$DATA:Zone.Identifier") or the field of an attribute (such as
+// "$STANDARD_INFORMATION.Creation" or "$DATA.DataRuns"). When there are several attributes with the same type and name,
+// such as multiple $FILE_NAME attributes, the second and further ones have their position appended, for example
+// "$FILE_NAME[1].Name".
+//
+// For DifferenceChanged, Old and New contain the values of the field, for example a time.Time for timestamps, a
+// []DataRun for data runs and a []byte for the data of other resident attributes. For DifferenceAttributeAdded New
+// contains the Attribute and Old is nil, for DifferenceAttributeRemoved Old contains the Attribute and New is nil.
+type Difference struct {
+	Kind  DifferenceKind
+	Field string
+	Old   interface{}
+	New   interface{}
+}
+
+func (d Difference) String() string {
+	switch d.Kind {
+	case DifferenceChanged:
+		return fmt.Sprintf("%s: %s -> %s", d.Field, formatDifferenceValue(d.Old), formatDifferenceValue(d.New))
+	default:
+		return fmt.Sprintf("%s %v", d.Field, d.Kind)
+	}
+}
+
+func formatDifferenceValue(v interface{}) string {
+	switch v := v.(type) {
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case []byte:
+		return fmt.Sprintf("%x", v)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// DiffRecords compares two parses of the same record, for example from MFT dumps taken at different times, and
+// returns the differences between the old record a and the new record b. The record headers are compared field by
+// field, except for the Signature. Attributes are matched by type and name (and position, when there are several with
+// the same type and name); attributes which are only present in one of the records are reported as added or removed.
+//
+// Of matched attributes, the header fields are compared, as well as the data: the fields of $STANDARD_INFORMATION and
+// $FILE_NAME, the data runs of non-resident attributes and the raw data of all other resident attributes. The
+// returned slice is empty when the records are the same.
+func DiffRecords(a, b Record) []Difference {
+	d := &differ{}
+	d.compare("FileReference", a.FileReference, b.FileReference)
+	d.compare("BaseRecordReference", a.BaseRecordReference, b.BaseRecordReference)
+	d.compare("LogFileSequenceNumber", a.LogFileSequenceNumber, b.LogFileSequenceNumber)
+	d.compare("HardLinkCount", a.HardLinkCount, b.HardLinkCount)
+	d.compare("Flags", a.Flags, b.Flags)
+	d.compare("ActualSize", a.ActualSize, b.ActualSize)
+	d.compare("AllocatedSize", a.AllocatedSize, b.AllocatedSize)
+	d.compare("NextAttributeId", a.NextAttributeId, b.NextAttributeId)
+	d.compare("LegacyHeader", a.LegacyHeader, b.LegacyHeader)
+
+	oldKeys := attributeKeys(a.Attributes)
+	newKeys := attributeKeys(b.Attributes)
+	newByKey := make(map[string]Attribute, len(b.Attributes))
+	for i, attr := range b.Attributes {
+		newByKey[newKeys[i]] = attr
+	}
+	oldPresent := make(map[string]bool, len(a.Attributes))
+	for i, attr := range a.Attributes {
+		key := oldKeys[i]
+		oldPresent[key] = true
+		if newAttr, ok := newByKey[key]; ok {
+			d.compareAttributes(key, attr, newAttr)
+		} else {
+			d.add(Difference{Kind: DifferenceAttributeRemoved, Field: key, Old: attr})
+		}
+	}
+	for i, attr := range b.Attributes {
+		if !oldPresent[newKeys[i]] {
+			d.add(Difference{Kind: DifferenceAttributeAdded, Field: newKeys[i], New: attr})
+		}
+	}
+	return d.differences
+}
+
+// attributeKeys returns the names used to match attributes and to report their differences.
+func attributeKeys(attributes []Attribute) []string {
+	keys := make([]string, len(attributes))
+	counts := make(map[string]int)
+	for i, a := range attributes {
+		key := a.Type.Name()
+		if a.Name != "" {
+			key += ":" + a.Name
+		}
+		if n := counts[key]; n > 0 {
+			keys[i] = fmt.Sprintf("%s[%d]", key, n)
+		} else {
+			keys[i] = key
+		}
+		counts[key]++
+	}
+	return keys
+}
+
+type differ struct {
+	differences []Difference
+}
+
+func (d *differ) add(difference Difference) {
+	d.differences = append(d.differences, difference)
+}
+
+func (d *differ) compare(field string, before interface{}, after interface{}) {
+	if !reflect.DeepEqual(before, after) {
+		d.add(Difference{Kind: DifferenceChanged, Field: field, Old: before, New: after})
+	}
+}
+
+func (d *differ) compareTime(field string, before time.Time, after time.Time) {
+	if !before.Equal(after) {
+		d.add(Difference{Kind: DifferenceChanged, Field: field, Old: before, New: after})
+	}
+}
+
+func (d *differ) compareAttributes(key string, a Attribute, b Attribute) {
+	d.compare(key+".Resident", a.Resident, b.Resident)
+	d.compare(key+".Flags", a.Flags, b.Flags)
+	d.compare(key+".AttributeId", a.AttributeId, b.AttributeId)
+	if a.Resident != b.Resident {
+		// The data cannot be compared meaningfully
+		return
+	}
+	if !a.Resident {
+		d.compare(key+".AllocatedSize", a.AllocatedSize, b.AllocatedSize)
+		d.compare(key+".ActualSize", a.ActualSize, b.ActualSize)
+		d.compare(key+".InitializedSize", a.InitializedSize, b.InitializedSize)
+		d.compare(key+".CompressionUnit", a.CompressionUnit, b.CompressionUnit)
+		d.compare(key+".TotalAllocatedSize", a.TotalAllocatedSize, b.TotalAllocatedSize)
+		oldRuns, oldErr := ParseDataRuns(a.Data)
+		newRuns, newErr := ParseDataRuns(b.Data)
+		if oldErr == nil && newErr == nil {
+			d.compare(key+".DataRuns", oldRuns, newRuns)
+			return
+		}
+	} else if a.Type == AttributeTypeStandardInformation && d.compareStandardInformation(key, a.Data, b.Data) {
+		return
+	} else if a.Type == AttributeTypeFileName && d.compareFileName(key, a.Data, b.Data) {
+		return
+	}
+	if !bytes.Equal(a.Data, b.Data) {
+		d.add(Difference{Kind: DifferenceChanged, Field: key + ".Data", Old: a.Data, New: b.Data})
+	}
+}
+
+// compareStandardInformation compares the fields of $STANDARD_INFORMATION data. It returns false if either cannot be
+// parsed, in which case the raw data has to be compared instead.
+func (d *differ) compareStandardInformation(key string, a []byte, b []byte) bool {
+	before, err := ParseStandardInformation(a)
+	if err != nil {
+		return false
+	}
+	after, err := ParseStandardInformation(b)
+	if err != nil {
+		return false
+	}
+	d.compareTime(key+".Creation", before.Creation, after.Creation)
+	d.compareTime(key+".FileLastModified", before.FileLastModified, after.FileLastModified)
+	d.compareTime(key+".MftLastModified", before.MftLastModified, after.MftLastModified)
+	d.compareTime(key+".LastAccess", before.LastAccess, after.LastAccess)
+	d.compare(key+".FileAttributes", before.FileAttributes, after.FileAttributes)
+	d.compare(key+".MaximumNumberOfVersions", before.MaximumNumberOfVersions, after.MaximumNumberOfVersions)
+	d.compare(key+".VersionNumber", before.VersionNumber, after.VersionNumber)
+	d.compare(key+".ClassId", before.ClassId, after.ClassId)
+	d.compare(key+".OwnerId", before.OwnerId, after.OwnerId)
+	d.compare(key+".SecurityId", before.SecurityId, after.SecurityId)
+	d.compare(key+".QuotaCharged", before.QuotaCharged, after.QuotaCharged)
+	d.compare(key+".UpdateSequenceNumber", before.UpdateSequenceNumber, after.UpdateSequenceNumber)
+	return true
+}
+
+// compareFileName compares the fields of $FILE_NAME data. It returns false if either cannot be parsed, in which case
+// the raw data has to be compared instead.
+func (d *differ) compareFileName(key string, a []byte, b []byte) bool {
+	before, err := ParseFileName(a)
+	if err != nil {
+		return false
+	}
+	after, err := ParseFileName(b)
+	if err != nil {
+		return false
+	}
+	d.compare(key+".Name", before.Name, after.Name)
+	d.compare(key+".Namespace", before.Namespace, after.Namespace)
+	d.compare(key+".ParentFileReference", before.ParentFileReference, after.ParentFileReference)
+	d.compareTime(key+".Creation", before.Creation, after.Creation)
+	d.compareTime(key+".FileLastModified", before.FileLastModified, after.FileLastModified)
+	d.compareTime(key+".MftLastModified", before.MftLastModified, after.MftLastModified)
+	d.compareTime(key+".LastAccess", before.LastAccess, after.LastAccess)
+	d.compare(key+".AllocatedSize", before.AllocatedSize, after.AllocatedSize)
+	d.compare(key+".ActualSize", before.ActualSize, after.ActualSize)
+	d.compare(key+".Flags", before.Flags, after.Flags)
+	d.compare(key+".ExtendedData", before.ExtendedData, after.ExtendedData)
+	return true
+}
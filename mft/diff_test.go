@@ -0,0 +1,71 @@
+package mft_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/mfttest"
+)
+
+var diffTime = time.Date(2020, time.February, 5, 14, 59, 38, 116886200, time.UTC)
+
+func diffRecord() *mfttest.RecordBuilder {
+	return mfttest.NewRecord().
+		WithTime(diffTime).
+		WithStandardInformation(mft.FileAttributeArchive).
+		WithFileName("a.txt")
+}
+
+func TestDiffRecords_Same(t *testing.T) {
+	a := diffRecord().WithNonResidentData(5000, mft.DataRun{OffsetCluster: 100, LengthInClusters: 2}).Record()
+	b := diffRecord().WithNonResidentData(5000, mft.DataRun{OffsetCluster: 100, LengthInClusters: 2}).Record()
+	assert.Empty(t, mft.DiffRecords(a, b))
+}
+
+func TestDiffRecords_Changed(t *testing.T) {
+	later := diffTime.Add(time.Hour)
+	a := diffRecord().WithLogFileSequenceNumber(1).WithNonResidentData(5000, mft.DataRun{OffsetCluster: 100, LengthInClusters: 2}).Record()
+	b := mfttest.NewRecord().
+		WithLogFileSequenceNumber(2).
+		WithStandardInformationData(mft.StandardInformation{
+			Creation: diffTime, FileLastModified: later, MftLastModified: later, LastAccess: diffTime,
+			FileAttributes: mft.FileAttributeArchive,
+		}).
+		WithTime(diffTime).
+		WithFileName("a.txt").
+		WithNonResidentData(9000, mft.DataRun{OffsetCluster: 100, LengthInClusters: 2}, mft.DataRun{OffsetCluster: 50, LengthInClusters: 1}).
+		Record()
+
+	expected := []mft.Difference{
+		{Kind: mft.DifferenceChanged, Field: "LogFileSequenceNumber", Old: uint64(1), New: uint64(2)},
+		{Kind: mft.DifferenceChanged, Field: "$STANDARD_INFORMATION.FileLastModified", Old: diffTime, New: later},
+		{Kind: mft.DifferenceChanged, Field: "$STANDARD_INFORMATION.MftLastModified", Old: diffTime, New: later},
+		{Kind: mft.DifferenceChanged, Field: "$DATA.AllocatedSize", Old: uint64(8192), New: uint64(12288)},
+		{Kind: mft.DifferenceChanged, Field: "$DATA.ActualSize", Old: uint64(5000), New: uint64(9000)},
+		{Kind: mft.DifferenceChanged, Field: "$DATA.InitializedSize", Old: uint64(5000), New: uint64(9000)},
+		{Kind: mft.DifferenceChanged, Field: "$DATA.DataRuns",
+			Old: []mft.DataRun{{OffsetCluster: 100, LengthInClusters: 2}},
+			New: []mft.DataRun{{OffsetCluster: 100, LengthInClusters: 2}, {OffsetCluster: 50, LengthInClusters: 1}}},
+	}
+	assert.Equal(t, expected, mft.DiffRecords(a, b))
+	assert.Equal(t, "$STANDARD_INFORMATION.MftLastModified: 2020-02-05T14:59:38.1168862Z -> 2020-02-05T15:59:38.1168862Z", expected[2].String())
+}
+
+func TestDiffRecords_Attributes(t *testing.T) {
+	a := diffRecord().WithResidentData([]byte("hello")).WithStream("Zone.Identifier", []byte("[ZoneTransfer]")).Record()
+	b := diffRecord().WithResidentData([]byte("hello, world")).WithFileName("A~1.TXT").Record()
+
+	differences := mft.DiffRecords(a, b)
+	fields := make([]string, 0)
+	for _, d := range differences {
+		fields = append(fields, d.Field)
+	}
+	assert.Equal(t, []string{"ActualSize", "$DATA.Data", "$DATA:Zone.Identifier", "$FILE_NAME[1]"}, fields)
+	assert.Equal(t, mft.Difference{Kind: mft.DifferenceChanged, Field: "$DATA.Data", Old: []byte("hello"), New: []byte("hello, world")}, differences[1])
+	assert.Equal(t, "$DATA:Zone.Identifier removed", differences[2].String())
+	assert.Equal(t, "[ZoneTransfer]", string(differences[2].Old.(mft.Attribute).Data))
+	assert.Equal(t, "$FILE_NAME[1] added", differences[3].String())
+	assert.Nil(t, differences[3].Old)
+}
@@ -0,0 +1,54 @@
+package mft
+
+import (
+	"fmt"
+
+	"github.com/t9t/gomft/binutil"
+)
+
+// ExtendedAttributeFlagNeedEA indicates that a file cannot be interpreted correctly without understanding its extended
+// attributes.
+const ExtendedAttributeFlagNeedEA = 0x80
+
+// ExtendedAttribute represents a single extended attribute (EA) stored in an $EA attribute. EAs are used by OS/2 and
+// WSL, among others; the names are upper case ASCII, for example "$LXMOD" or "$KERNEL.PURGE.ESBCACHE".
+type ExtendedAttribute struct {
+	Flags byte
+	Name  string
+	Value []byte
+}
+
+// ParseExtendedAttributes parses the data of an $EA attribute's data (type AttributeTypeEA) into a list of
+// ExtendedAttribute. The entries are laid out like the FILE_FULL_EA_INFORMATION structure: the offset of the next entry,
+// the flags, the length of the name and value, followed by the NUL terminated name and the value. Values are copies,
+// they do not alias the input.
+func ParseExtendedAttributes(b []byte) ([]ExtendedAttribute, error) {
+	attributes := make([]ExtendedAttribute, 0)
+	offset := 0
+	for offset < len(b) {
+		if len(b)-offset < 8 {
+			return attributes, fmt.Errorf("expected at least %d bytes for extended attribute at offset %d but got %d", 8, offset, len(b)-offset)
+		}
+		r := binutil.NewLittleEndianReader(b[offset:])
+		nextOffset := int(r.Uint32(0x00))
+		nameLength := int(r.Byte(0x05))
+		valueLength := int(r.Uint16(0x06))
+		length := 8 + nameLength + 1 + valueLength
+		if r.Length() < length {
+			return attributes, fmt.Errorf("expected at least %d bytes for extended attribute at offset %d but got %d", length, offset, r.Length())
+		}
+		attributes = append(attributes, ExtendedAttribute{
+			Flags: r.Byte(0x04),
+			Name:  string(r.Read(0x08, nameLength)),
+			Value: binutil.Duplicate(r.Read(0x08+nameLength+1, valueLength)),
+		})
+		if nextOffset == 0 {
+			break
+		}
+		if nextOffset < length {
+			return attributes, fmt.Errorf("invalid next entry offset %d of extended attribute at offset %d", nextOffset, offset)
+		}
+		offset += nextOffset
+	}
+	return attributes, nil
+}
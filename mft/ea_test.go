@@ -0,0 +1,36 @@
+package mft_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/mft"
+)
+
+func TestParseExtendedAttributes(t *testing.T) {
+	// $LXUID = 1000 (entry padded to 20 bytes), followed by $LXMOD = 0100644 as the last entry
+	input := decodeHex(t, "14000000"+"00"+"06"+"0400"+"244c5855494400"+"e8030000"+"00"+
+		"00000000"+"80"+"06"+"0400"+"244c584d4f4400"+"a4810000")
+	attributes, err := mft.ParseExtendedAttributes(input)
+	require.Nilf(t, err, "could not parse extended attributes: %v", err)
+	expected := []mft.ExtendedAttribute{
+		{Flags: 0, Name: "$LXUID", Value: []byte{0xe8, 0x03, 0x00, 0x00}},
+		{Flags: mft.ExtendedAttributeFlagNeedEA, Name: "$LXMOD", Value: []byte{0xa4, 0x81, 0x00, 0x00}},
+	}
+	assert.Equal(t, expected, attributes)
+}
+
+func TestParseExtendedAttributesTooShort(t *testing.T) {
+	_, err := mft.ParseExtendedAttributes(decodeHex(t, "00000000000604"))
+	assert.EqualError(t, err, "expected at least 8 bytes for extended attribute at offset 0 but got 7")
+
+	_, err = mft.ParseExtendedAttributes(decodeHex(t, "00000000"+"00"+"06"+"0400"+"244c5855494400"+"e803"))
+	assert.EqualError(t, err, "expected at least 19 bytes for extended attribute at offset 0 but got 17")
+}
+
+func TestParseExtendedAttributesInvalidNextOffset(t *testing.T) {
+	attributes, err := mft.ParseExtendedAttributes(decodeHex(t, "04000000"+"00"+"01"+"0000"+"4100"))
+	assert.EqualError(t, err, "invalid next entry offset 4 of extended attribute at offset 0")
+	assert.Equal(t, []mft.ExtendedAttribute{{Name: "A", Value: []byte{}}}, attributes)
+}
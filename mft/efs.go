@@ -0,0 +1,155 @@
+package mft
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/t9t/gomft/binutil"
+	"github.com/t9t/gomft/utf16"
+)
+
+// EFSStreamName is the name of the $LOGGED_UTILITY_STREAM attribute containing the EFS metadata of an encrypted file.
+const EFSStreamName = "$EFS"
+
+// EFSCredentialType indicates how the key of an EFSKeyEntry is identified.
+type EFSCredentialType uint32
+
+const (
+	EFSCredentialTypeCryptoAPIContainer    EFSCredentialType = 1
+	EFSCredentialTypeCertificateThumbprint EFSCredentialType = 3
+)
+
+// EFS represents the metadata of a file encrypted using the Encrypting File System, as stored in the $EFS logged utility
+// stream. The file encryption key (FEK) of the file is stored once for each user who can decrypt the file, in the
+// DataDecryptionFields, and once for each recovery agent, in the DataRecoveryFields.
+type EFS struct {
+	Length               uint32
+	Version              uint32
+	DataDecryptionFields []EFSKeyEntry
+	DataRecoveryFields   []EFSKeyEntry
+}
+
+// EFSKeyEntry identifies a user or recovery agent who can decrypt a file, along with the file encryption key, encrypted
+// with the public key of that user or agent. SID is nil when the entry does not contain a SID. For entries of type
+// EFSCredentialTypeCertificateThumbprint, the Thumbprint contains the SHA-1 thumbprint of the certificate.
+type EFSKeyEntry struct {
+	SID            *SID
+	CredentialType EFSCredentialType
+	Thumbprint     []byte
+	ContainerName  string
+	ProviderName   string
+	UserName       string
+	EncryptedFEK   []byte
+}
+
+// ParseEFS parses the data of the $EFS logged utility stream (an attribute with type AttributeTypeLoggedUtilityStream
+// and name EFSStreamName) into EFS. Only the metadata is parsed; the file encryption keys remain encrypted. Byte
+// slices in the result are copies, they do not alias the input.
+func ParseEFS(b []byte) (EFS, error) {
+	if len(b) < 0x4C {
+		return EFS{}, fmt.Errorf("expected at least %d bytes but got %d", 0x4C, len(b))
+	}
+	r := binutil.NewLittleEndianReader(b)
+	efs := EFS{Length: r.Uint32(0x00), Version: r.Uint32(0x08)}
+
+	var err error
+	if efs.DataDecryptionFields, err = parseEFSKeyEntries(b, int(r.Uint32(0x40))); err != nil {
+		return EFS{}, fmt.Errorf("unable to parse data decryption fields: %v", err)
+	}
+	if efs.DataRecoveryFields, err = parseEFSKeyEntries(b, int(r.Uint32(0x44))); err != nil {
+		return EFS{}, fmt.Errorf("unable to parse data recovery fields: %v", err)
+	}
+	return efs, nil
+}
+
+// parseEFSKeyEntries parses the array of key entries at the specified offset. An offset of zero means the array is not
+// present.
+func parseEFSKeyEntries(b []byte, offset int) ([]EFSKeyEntry, error) {
+	entries := make([]EFSKeyEntry, 0)
+	if offset == 0 {
+		return entries, nil
+	}
+	if offset < 0 || offset+4 > len(b) {
+		return nil, fmt.Errorf("array offset %d exceeds data length %d", offset, len(b))
+	}
+	count := int(binary.LittleEndian.Uint32(b[offset:]))
+	position := offset + 4
+	for i := 0; i < count; i++ {
+		if position+20 > len(b) {
+			return nil, fmt.Errorf("entry %d at offset %d exceeds data length %d", i, position, len(b))
+		}
+		length := int(binary.LittleEndian.Uint32(b[position:]))
+		if length < 20 || position+length > len(b) {
+			return nil, fmt.Errorf("invalid length %d of entry %d at offset %d", length, i, position)
+		}
+		entry, err := parseEFSKeyEntry(b[position : position+length])
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse entry %d at offset %d: %v", i, position, err)
+		}
+		entries = append(entries, entry)
+		position += length
+	}
+	return entries, nil
+}
+
+func parseEFSKeyEntry(b []byte) (EFSKeyEntry, error) {
+	r := binutil.NewLittleEndianReader(b)
+	fekSize, fekOffset := int(r.Uint32(0x08)), int(r.Uint32(0x0C))
+	if fekOffset+fekSize > len(b) {
+		return EFSKeyEntry{}, fmt.Errorf("encrypted FEK at offset %d with size %d exceeds entry length %d", fekOffset, fekSize, len(b))
+	}
+	entry := EFSKeyEntry{EncryptedFEK: binutil.Duplicate(b[fekOffset : fekOffset+fekSize])}
+
+	credentialOffset := int(r.Uint32(0x04))
+	if credentialOffset+20 > len(b) {
+		return EFSKeyEntry{}, fmt.Errorf("credential at offset %d exceeds entry length %d", credentialOffset, len(b))
+	}
+	c := b[credentialOffset:]
+	cr := binutil.NewLittleEndianReader(c)
+	if sidOffset := int(cr.Uint32(0x04)); sidOffset != 0 {
+		if sidOffset > len(c) {
+			return EFSKeyEntry{}, fmt.Errorf("SID offset %d exceeds credential length %d", sidOffset, len(c))
+		}
+		sid, _, err := ParseSID(c[sidOffset:])
+		if err != nil {
+			return EFSKeyEntry{}, fmt.Errorf("unable to parse SID: %v", err)
+		}
+		entry.SID = &sid
+	}
+
+	entry.CredentialType = EFSCredentialType(cr.Uint32(0x08))
+	switch entry.CredentialType {
+	case EFSCredentialTypeCryptoAPIContainer:
+		entry.ContainerName = efsString(c, int(cr.Uint32(0x0C)))
+		entry.ProviderName = efsString(c, int(cr.Uint32(0x10)))
+	case EFSCredentialTypeCertificateThumbprint:
+		headerSize, headerOffset := int(cr.Uint32(0x0C)), int(cr.Uint32(0x10))
+		if headerSize < 20 || headerOffset+headerSize > len(c) {
+			return EFSKeyEntry{}, fmt.Errorf("thumbprint header at offset %d with size %d exceeds credential length %d", headerOffset, headerSize, len(c))
+		}
+		h := c[headerOffset : headerOffset+headerSize]
+		hr := binutil.NewLittleEndianReader(h)
+		thumbprintOffset, thumbprintSize := int(hr.Uint32(0x00)), int(hr.Uint32(0x04))
+		if thumbprintOffset+thumbprintSize > len(h) {
+			return EFSKeyEntry{}, fmt.Errorf("thumbprint at offset %d with size %d exceeds header size %d", thumbprintOffset, thumbprintSize, len(h))
+		}
+		entry.Thumbprint = binutil.Duplicate(h[thumbprintOffset : thumbprintOffset+thumbprintSize])
+		entry.ContainerName = efsString(h, int(hr.Uint32(0x08)))
+		entry.ProviderName = efsString(h, int(hr.Uint32(0x0C)))
+		entry.UserName = efsString(h, int(hr.Uint32(0x10)))
+	}
+	return entry, nil
+}
+
+// efsString decodes the NUL terminated UTF-16 string at the specified offset. An offset of 0 (no string) or an offset
+// outside of b gives an empty string.
+func efsString(b []byte, offset int) string {
+	if offset <= 0 || offset >= len(b) {
+		return ""
+	}
+	end := offset
+	for end+2 <= len(b) && (b[end] != 0 || b[end+1] != 0) {
+		end += 2
+	}
+	return utf16.DecodeString(b[offset:end], binary.LittleEndian)
+}
@@ -0,0 +1,134 @@
+package mft_test
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/mft"
+)
+
+func TestParseEFS(t *testing.T) {
+	sid := decodeHex(t, "010500000000000515000000dc04de3b8348ab4682d3a628e9030000")
+	thumbprint := decodeHex(t, "00112233445566778899aabbccddeeff00112233")
+	ddf := thumbprintEFSKeyEntry(sid, thumbprint, "container", "Microsoft Enhanced Cryptographic Provider v1.0", "user@example", []byte{1, 2, 3, 4})
+	drf := containerEFSKeyEntry(nil, "recovery", "provider", []byte{5, 6})
+	input := efsStream([][]byte{ddf}, [][]byte{drf})
+
+	efs, err := mft.ParseEFS(input)
+	require.Nilf(t, err, "could not parse $EFS: %v", err)
+	assert.Equal(t, uint32(len(input)), efs.Length)
+	assert.Equal(t, uint32(2), efs.Version)
+
+	require.Len(t, efs.DataDecryptionFields, 1)
+	entry := efs.DataDecryptionFields[0]
+	require.NotNil(t, entry.SID)
+	assert.Equal(t, "S-1-5-21-1004405980-1185630339-682021762-1001", entry.SID.String())
+	assert.Equal(t, mft.EFSCredentialTypeCertificateThumbprint, entry.CredentialType)
+	assert.Equal(t, thumbprint, entry.Thumbprint)
+	assert.Equal(t, "container", entry.ContainerName)
+	assert.Equal(t, "Microsoft Enhanced Cryptographic Provider v1.0", entry.ProviderName)
+	assert.Equal(t, "user@example", entry.UserName)
+	assert.Equal(t, []byte{1, 2, 3, 4}, entry.EncryptedFEK)
+
+	expectedRecovery := mft.EFSKeyEntry{
+		CredentialType: mft.EFSCredentialTypeCryptoAPIContainer,
+		ContainerName:  "recovery",
+		ProviderName:   "provider",
+		EncryptedFEK:   []byte{5, 6},
+	}
+	assert.Equal(t, []mft.EFSKeyEntry{expectedRecovery}, efs.DataRecoveryFields)
+}
+
+func TestParseEFSWithoutRecoveryFields(t *testing.T) {
+	input := efsStream([][]byte{containerEFSKeyEntry(nil, "a", "b", nil)}, nil)
+	binary.LittleEndian.PutUint32(input[0x44:], 0)
+	efs, err := mft.ParseEFS(input)
+	require.Nilf(t, err, "could not parse $EFS: %v", err)
+	assert.Len(t, efs.DataDecryptionFields, 1)
+	assert.Equal(t, []mft.EFSKeyEntry{}, efs.DataRecoveryFields)
+}
+
+func TestParseEFSInvalid(t *testing.T) {
+	_, err := mft.ParseEFS(make([]byte, 0x4B))
+	assert.EqualError(t, err, "expected at least 76 bytes but got 75")
+
+	input := efsStream([][]byte{containerEFSKeyEntry(nil, "a", "b", nil)}, nil)
+	binary.LittleEndian.PutUint32(input[0x50:], 0xFFFF)
+	_, err = mft.ParseEFS(input)
+	assert.EqualError(t, err, "unable to parse data decryption fields: invalid length 65535 of entry 0 at offset 80")
+
+	input = efsStream([][]byte{containerEFSKeyEntry(nil, "a", "b", []byte{1})}, nil)
+	binary.LittleEndian.PutUint32(input[0x50+0x08:], 100)
+	_, err = mft.ParseEFS(input)
+	assert.EqualError(t, err, "unable to parse data decryption fields: unable to parse entry 0 at offset 80: encrypted FEK at offset 52 with size 100 exceeds entry length 53")
+}
+
+// efsStream creates the data of an $EFS stream with the specified DDF and DRF entries, which follow the header.
+func efsStream(ddf, drf [][]byte) []byte {
+	b := make([]byte, 0x4C)
+	binary.LittleEndian.PutUint32(b[0x08:], 2)
+	binary.LittleEndian.PutUint32(b[0x40:], uint32(len(b)))
+	b = appendEFSKeyEntries(b, ddf)
+	binary.LittleEndian.PutUint32(b[0x44:], uint32(len(b)))
+	b = appendEFSKeyEntries(b, drf)
+	binary.LittleEndian.PutUint32(b[0x00:], uint32(len(b)))
+	return b
+}
+
+func appendEFSKeyEntries(b []byte, entries [][]byte) []byte {
+	count := make([]byte, 4)
+	binary.LittleEndian.PutUint32(count, uint32(len(entries)))
+	b = append(b, count...)
+	for _, entry := range entries {
+		b = append(b, entry...)
+	}
+	return b
+}
+
+// efsKeyEntry creates a key entry with the specified credential (including SID) and encrypted FEK.
+func efsKeyEntry(credential, fek []byte) []byte {
+	b := make([]byte, 20, 20+len(credential)+len(fek))
+	binary.LittleEndian.PutUint32(b[0x04:], 20)
+	binary.LittleEndian.PutUint32(b[0x08:], uint32(len(fek)))
+	binary.LittleEndian.PutUint32(b[0x0C:], uint32(20+len(credential)))
+	b = append(append(b, credential...), fek...)
+	binary.LittleEndian.PutUint32(b[0x00:], uint32(len(b)))
+	return b
+}
+
+func containerEFSKeyEntry(sid []byte, container, provider string, fek []byte) []byte {
+	c := make([]byte, 24, 64)
+	binary.LittleEndian.PutUint32(c[0x08:], uint32(mft.EFSCredentialTypeCryptoAPIContainer))
+	if sid != nil {
+		binary.LittleEndian.PutUint32(c[0x04:], uint32(len(c)))
+		c = append(c, sid...)
+	}
+	binary.LittleEndian.PutUint32(c[0x0C:], uint32(len(c)))
+	c = append(append(c, encodeUtf16(container)...), 0, 0)
+	binary.LittleEndian.PutUint32(c[0x10:], uint32(len(c)))
+	c = append(append(c, encodeUtf16(provider)...), 0, 0)
+	binary.LittleEndian.PutUint32(c[0x00:], uint32(len(c)))
+	return efsKeyEntry(c, fek)
+}
+
+func thumbprintEFSKeyEntry(sid, thumbprint []byte, container, provider, user string, fek []byte) []byte {
+	h := make([]byte, 20, 256)
+	binary.LittleEndian.PutUint32(h[0x00:], uint32(len(h)))
+	binary.LittleEndian.PutUint32(h[0x04:], uint32(len(thumbprint)))
+	h = append(h, thumbprint...)
+	for i, s := range []string{container, provider, user} {
+		binary.LittleEndian.PutUint32(h[0x08+i*4:], uint32(len(h)))
+		h = append(append(h, encodeUtf16(s)...), 0, 0)
+	}
+
+	c := make([]byte, 20, 20+len(sid)+len(h))
+	binary.LittleEndian.PutUint32(c[0x04:], 20)
+	binary.LittleEndian.PutUint32(c[0x08:], uint32(mft.EFSCredentialTypeCertificateThumbprint))
+	binary.LittleEndian.PutUint32(c[0x0C:], uint32(len(h)))
+	binary.LittleEndian.PutUint32(c[0x10:], uint32(20+len(sid)))
+	c = append(append(c, sid...), h...)
+	binary.LittleEndian.PutUint32(c[0x00:], uint32(len(c)))
+	return efsKeyEntry(c, fek)
+}
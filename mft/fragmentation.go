@@ -0,0 +1,162 @@
+package mft
+
+import "fmt"
+
+// FragmentationStats describes how fragmented the data of a stream (non-resident attribute) is on the volume. An extent
+// is a contiguous range of clusters: consecutive data runs which directly follow each other on the volume count as a
+// single extent. Sparse runs (runs without an offset) are not allocated, so they are counted separately and do not
+// break up extents around them.
+type FragmentationStats struct {
+	Extents           int    // number of contiguous ranges of allocated clusters
+	Clusters          uint64 // number of allocated clusters
+	SparseClusters    uint64 // number of clusters in sparse runs
+	OutOfOrderExtents int    // number of extents starting before the extent preceding it
+}
+
+// IsFragmented indicates whether the stream consists of more than one extent.
+func (s FragmentationStats) IsFragmented() bool {
+	return s.Extents > 1
+}
+
+// AverageExtentClusters returns the average length of the extents in clusters, or 0 when there are no extents.
+func (s FragmentationStats) AverageExtentClusters() float64 {
+	if s.Extents == 0 {
+		return 0
+	}
+	return float64(s.Clusters) / float64(s.Extents)
+}
+
+// DataRunFragmentation computes the FragmentationStats of a list of DataRuns, as returned by ParseDataRuns.
+func DataRunFragmentation(runs []DataRun) FragmentationStats {
+	stats := FragmentationStats{}
+	cluster := int64(0)
+	extentStart, extentEnd := int64(0), int64(0)
+	for _, run := range runs {
+		if run.Sparse {
+			stats.SparseClusters += run.LengthInClusters
+			continue
+		}
+		cluster += run.OffsetCluster
+		stats.Clusters += run.LengthInClusters
+		if stats.Extents == 0 || cluster != extentEnd {
+			if stats.Extents > 0 && cluster < extentStart {
+				stats.OutOfOrderExtents++
+			}
+			stats.Extents++
+			extentStart = cluster
+		}
+		extentEnd = cluster + int64(run.LengthInClusters)
+	}
+	return stats
+}
+
+// ClusterExtent is a contiguous range of clusters on the volume occupied by a non-resident attribute. Vcn is the
+// virtual cluster number of its first cluster: its position in the attribute (or in the part of the attribute in the
+// record), in clusters.
+type ClusterExtent struct {
+	Vcn     uint64
+	Cluster uint64
+	Length  uint64
+}
+
+// End returns the cluster following the last cluster of the ClusterExtent.
+func (e ClusterExtent) End() uint64 {
+	return e.Cluster + e.Length
+}
+
+// DataRunExtents resolves the relative offsets of a list of DataRuns, as returned by ParseDataRuns, into the ranges of
+// clusters they occupy on the volume. Consecutive runs which directly follow each other on the volume are merged into
+// a single extent. Sparse runs occupy no clusters, so they are left out, as are runs starting before the volume and
+// runs with a zero length (see ValidateDataRuns).
+func DataRunExtents(runs []DataRun) []ClusterExtent {
+	extents := make([]ClusterExtent, 0, len(runs))
+	cluster := int64(0)
+	vcn := uint64(0)
+	merge := false
+	for _, run := range runs {
+		start := vcn
+		vcn += run.LengthInClusters
+		if run.Sparse {
+			merge = false
+			continue
+		}
+		cluster += run.OffsetCluster
+		if cluster < 0 || run.LengthInClusters == 0 {
+			merge = false
+			continue
+		}
+		if last := len(extents) - 1; merge && extents[last].End() == uint64(cluster) {
+			extents[last].Length += run.LengthInClusters
+			continue
+		}
+		extents = append(extents, ClusterExtent{Vcn: start, Cluster: uint64(cluster), Length: run.LengthInClusters})
+		merge = true
+	}
+	return extents
+}
+
+// StreamFragmentation contains the FragmentationStats of a single non-resident attribute of a record.
+type StreamFragmentation struct {
+	Type  AttributeType
+	Name  string
+	Stats FragmentationStats
+}
+
+// RecordFragmentation computes the FragmentationStats of each non-resident attribute of the record. Note that the data
+// runs of a large attribute may be spread over several records (see AttributeListEntry); each part is reported
+// separately.
+func RecordFragmentation(r Record) ([]StreamFragmentation, error) {
+	streams := make([]StreamFragmentation, 0)
+	for _, a := range r.Attributes {
+		if a.Resident {
+			continue
+		}
+		runs, err := ParseDataRuns(a.Data)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse data runs of %s attribute %q: %v", a.Type.Name(), a.Name, err)
+		}
+		streams = append(streams, StreamFragmentation{Type: a.Type, Name: a.Name, Stats: DataRunFragmentation(runs)})
+	}
+	return streams, nil
+}
+
+// FragmentationSummary aggregates the FragmentationStats of many streams, for example all $DATA attributes on a volume.
+type FragmentationSummary struct {
+	Streams           int
+	FragmentedStreams int
+	Extents           int
+	Clusters          uint64
+	OutOfOrderExtents int
+	MaxExtents        int // highest number of extents of a single stream
+}
+
+// Add adds the statistics of a single stream to the summary.
+func (s *FragmentationSummary) Add(stats FragmentationStats) {
+	s.Streams++
+	if stats.IsFragmented() {
+		s.FragmentedStreams++
+	}
+	s.Extents += stats.Extents
+	s.Clusters += stats.Clusters
+	s.OutOfOrderExtents += stats.OutOfOrderExtents
+	if stats.Extents > s.MaxExtents {
+		s.MaxExtents = stats.Extents
+	}
+}
+
+// AverageExtentsPerStream returns the average number of extents per stream, or 0 when no streams were added. A value
+// of 1 means there is no fragmentation at all.
+func (s FragmentationSummary) AverageExtentsPerStream() float64 {
+	if s.Streams == 0 {
+		return 0
+	}
+	return float64(s.Extents) / float64(s.Streams)
+}
+
+// FragmentedPercentage returns the percentage of streams consisting of more than one extent.
+func (s FragmentationSummary) FragmentedPercentage() float64 {
+	if s.Streams == 0 {
+		return 0
+	}
+	return float64(s.FragmentedStreams) * 100 / float64(s.Streams)
+}
@@ -0,0 +1,102 @@
+package mft_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/mft"
+)
+
+func TestDataRunFragmentation(t *testing.T) {
+	runs := []mft.DataRun{
+		{OffsetCluster: 100, LengthInClusters: 10}, // 100-110
+		{OffsetCluster: 10, LengthInClusters: 5},   // 110-115, contiguous with the previous run
+		{LengthInClusters: 20, Sparse: true},       // sparse
+		{OffsetCluster: 100, LengthInClusters: 4},  // 210-214
+		{OffsetCluster: -150, LengthInClusters: 1}, // 60-61, before the previous extent
+	}
+	stats := mft.DataRunFragmentation(runs)
+	assert.Equal(t, mft.FragmentationStats{Extents: 3, Clusters: 20, SparseClusters: 20, OutOfOrderExtents: 1}, stats)
+	assert.True(t, stats.IsFragmented())
+	assert.InDelta(t, 6.667, stats.AverageExtentClusters(), 0.001)
+}
+
+func TestDataRunFragmentationSingleExtent(t *testing.T) {
+	stats := mft.DataRunFragmentation([]mft.DataRun{{OffsetCluster: 4, LengthInClusters: 8}})
+	assert.Equal(t, mft.FragmentationStats{Extents: 1, Clusters: 8}, stats)
+	assert.False(t, stats.IsFragmented())
+	assert.Equal(t, 8.0, stats.AverageExtentClusters())
+}
+
+func TestDataRunFragmentationLeadingSparse(t *testing.T) {
+	runs := []mft.DataRun{
+		{LengthInClusters: 16, Sparse: true},      // sparse, not at cluster 0
+		{OffsetCluster: 0, LengthInClusters: 4},   // 0-4, like $Boot
+		{OffsetCluster: 100, LengthInClusters: 4}, // 100-104
+	}
+	stats := mft.DataRunFragmentation(runs)
+	assert.Equal(t, mft.FragmentationStats{Extents: 2, Clusters: 8, SparseClusters: 16}, stats)
+}
+
+func TestDataRunFragmentationEmpty(t *testing.T) {
+	stats := mft.DataRunFragmentation(nil)
+	assert.Equal(t, mft.FragmentationStats{}, stats)
+	assert.Equal(t, 0.0, stats.AverageExtentClusters())
+}
+
+func TestDataRunExtents(t *testing.T) {
+	runs := []mft.DataRun{
+		{OffsetCluster: 100, LengthInClusters: 10}, // 100-110
+		{OffsetCluster: 10, LengthInClusters: 5},   // 110-115, contiguous with the previous run
+		{LengthInClusters: 20, Sparse: true},       // sparse
+		{OffsetCluster: 5, LengthInClusters: 4},    // 115-119, contiguous on the volume but not in the attribute
+		{OffsetCluster: -150, LengthInClusters: 1}, // -35, before the volume
+		{OffsetCluster: 95, LengthInClusters: 0},   // 60, zero length
+		{OffsetCluster: 0, LengthInClusters: 2},    // 60-62
+	}
+	extents := mft.DataRunExtents(runs)
+	assert.Equal(t, []mft.ClusterExtent{
+		{Vcn: 0, Cluster: 100, Length: 15},
+		{Vcn: 35, Cluster: 115, Length: 4},
+		{Vcn: 40, Cluster: 60, Length: 2},
+	}, extents)
+	assert.Equal(t, uint64(62), extents[2].End())
+	assert.Equal(t, []mft.ClusterExtent{}, mft.DataRunExtents(nil))
+}
+
+func TestRecordFragmentation(t *testing.T) {
+	record := mft.Record{Attributes: []mft.Attribute{
+		{Type: mft.AttributeTypeFileName, Resident: true, Data: []byte{1, 2, 3}},
+		{Type: mft.AttributeTypeData, Data: decodeHex(t, "11030a"+"1102f6"+"00")},
+		{Type: mft.AttributeTypeData, Name: "ads", Data: decodeHex(t, "1101ff"+"00")},
+	}}
+	streams, err := mft.RecordFragmentation(record)
+	require.Nilf(t, err, "could not compute fragmentation: %v", err)
+	expected := []mft.StreamFragmentation{
+		{Type: mft.AttributeTypeData, Stats: mft.FragmentationStats{Extents: 2, Clusters: 5, OutOfOrderExtents: 1}},
+		{Type: mft.AttributeTypeData, Name: "ads", Stats: mft.FragmentationStats{Extents: 1, Clusters: 1}},
+	}
+	assert.Equal(t, expected, streams)
+}
+
+func TestRecordFragmentationInvalidDataRuns(t *testing.T) {
+	record := mft.Record{Attributes: []mft.Attribute{{Type: mft.AttributeTypeData, Name: "x", Data: decodeHex(t, "3301")}}}
+	_, err := mft.RecordFragmentation(record)
+	assert.EqualError(t, err, `unable to parse data runs of $DATA attribute "x": expected at least 7 bytes of datarun data but is 2`)
+}
+
+func TestFragmentationSummary(t *testing.T) {
+	summary := mft.FragmentationSummary{}
+	assert.Equal(t, 0.0, summary.AverageExtentsPerStream())
+	assert.Equal(t, 0.0, summary.FragmentedPercentage())
+
+	summary.Add(mft.FragmentationStats{Extents: 1, Clusters: 10})
+	summary.Add(mft.FragmentationStats{Extents: 5, Clusters: 20, OutOfOrderExtents: 2})
+	summary.Add(mft.FragmentationStats{Extents: 1, Clusters: 2})
+	summary.Add(mft.FragmentationStats{})
+	expected := mft.FragmentationSummary{Streams: 4, FragmentedStreams: 1, Extents: 7, Clusters: 32, OutOfOrderExtents: 2, MaxExtents: 5}
+	assert.Equal(t, expected, summary)
+	assert.Equal(t, 1.75, summary.AverageExtentsPerStream())
+	assert.Equal(t, 25.0, summary.FragmentedPercentage())
+}
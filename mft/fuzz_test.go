@@ -0,0 +1,42 @@
+//go:build go1.18
+// +build go1.18
+
+package mft_test
+
+import (
+	"testing"
+
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/mfttest"
+)
+
+// Run with for example: go test ./mft -run=^$ -fuzz=FuzzParseRecord
+
+func FuzzParseRecord(f *testing.F) {
+	f.Add([]byte{})
+	f.Add(make([]byte, 1024))
+	v := mfttest.NewVolume().WithFile("/a/b.txt", make([]byte, 5000)).WithFile("/c.txt", []byte("hi")).Build()
+	for _, n := range []uint64{0, mft.VolumeRecordNumber, 5, v.Records["/a"], v.Records["/a/b.txt"]} {
+		f.Add(v.Mft()[n*1024 : (n+1)*1024])
+	}
+	f.Fuzz(func(t *testing.T, b []byte) {
+		parseEverything(b)
+	})
+}
+
+func FuzzParseAttributeData(f *testing.F) {
+	f.Add([]byte{})
+	v := mfttest.NewVolume().WithFile("/a/b.txt", make([]byte, 5000)).WithFile("/c.txt", []byte("hi")).Build()
+	for _, n := range []uint64{0, mft.VolumeRecordNumber, 5, v.Records["/a"], v.Records["/a/b.txt"]} {
+		record, err := mft.ParseRecord(v.Mft()[n*1024 : (n+1)*1024])
+		if err != nil {
+			f.Fatalf("unable to parse record %d: %v", n, err)
+		}
+		for _, a := range record.Attributes {
+			f.Add(a.Data)
+		}
+	}
+	f.Fuzz(func(t *testing.T, b []byte) {
+		parseAttributeData(b)
+	})
+}
@@ -0,0 +1,28 @@
+package mft
+
+import "fmt"
+
+// A Logger receives diagnostic messages about the decisions made while parsing, such as a fixup mismatch that was
+// ignored by relaxed parsing or a record number that was derived from the position of a record. Messages are meant for
+// troubleshooting, not for reporting errors, which are still returned as usual. A *log.Logger can be used as Logger.
+// When used with ParseAll, the Logger is called from multiple goroutines, so it must be safe for concurrent use.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// logf passes the message to the Logger of the options, if any.
+func (o ParseOptions) logf(format string, v ...interface{}) {
+	if o.Logger != nil {
+		o.Logger.Printf(format, v...)
+	}
+}
+
+// prefixLogger is a Logger that prefixes all messages, for example to identify the record they are about.
+type prefixLogger struct {
+	l      Logger
+	prefix string
+}
+
+func (l prefixLogger) Printf(format string, v ...interface{}) {
+	l.l.Printf("%s%s", l.prefix, fmt.Sprintf(format, v...))
+}
@@ -0,0 +1,54 @@
+package mft_test
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/mft"
+)
+
+type testLogger struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (l *testLogger) Printf(format string, v ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.messages = append(l.messages, fmt.Sprintf(format, v...))
+}
+
+func TestParseRecordWithOptions_Logger(t *testing.T) {
+	logger := &testLogger{}
+	_, err := mft.ParseRecordWithOptions(residentDataRecord([]byte{1}), mft.ParseOptions{Logger: logger})
+	require.Nilf(t, err, "could not parse record: %v", err)
+	assert.Empty(t, logger.messages)
+
+	input := residentDataRecord([]byte{1})
+	input[1022] = 0x99
+	_, err = mft.ParseRecordWithOptions(input, mft.ParseOptions{Relaxed: true, Logger: logger})
+	require.Nilf(t, err, "could not parse record: %v", err)
+	assert.Equal(t, []string{"ignoring update sequence mismatch at pos 1022"}, logger.messages)
+
+	logger.messages = nil
+	_, err = mft.ParseRecordWithOptions(legacyRecord([]byte{1}), mft.ParseOptions{Logger: logger})
+	require.Nilf(t, err, "could not parse record: %v", err)
+	assert.Equal(t, []string{"update sequence at offset 42 indicates an NTFS 1.2 header without record number"}, logger.messages)
+}
+
+func TestParseAll_Logger(t *testing.T) {
+	logger := &testLogger{}
+	input := append(make([]byte, 1024), legacyRecord([]byte{1})...)
+	opts := mft.ParseAllOptions{SkipEmpty: true, Parse: mft.ParseOptions{Logger: logger}}
+	results := collect(mft.ParseAll(bytes.NewReader(input), opts))
+	require.Len(t, results, 1)
+	require.Nil(t, results[0].Err)
+	assert.Equal(t, []string{
+		"record 1: update sequence at offset 42 indicates an NTFS 1.2 header without record number",
+		"record 1: using index 1 as record number",
+	}, logger.messages)
+}
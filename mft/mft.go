@@ -16,6 +16,7 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"time"
 
 	"github.com/t9t/gomft/binutil"
 	"github.com/t9t/gomft/fragment"
@@ -28,10 +29,18 @@ var (
 
 const maxInt = int64(^uint(0) >> 1)
 
+// recordHeaderSize is the size of the record header of NTFS 3.1, which ends with the record number. The update sequence
+// directly follows the header, so when it starts earlier, the record has the smaller NTFS 1.2 header.
+const recordHeaderSize = 0x30
+
 // A Record represents an MFT entry, excluding all technical data (such as "offset to first attribute"). The Attributes
 // list only contains the attribute headers and raw data; the attribute data has to be parsed separately. When this is a
 // base record, the BaseRecordReference will be zero. When it is an extension record, the BaseRecordReference points to
 // the record's base record.
+//
+// Records written by NTFS 1.2 (Windows NT 4) have a shorter header which does not contain the record number; for such
+// records LegacyHeader is true and the RecordNumber of the FileReference is 0, since it can only be derived from the
+// position of the record in the MFT.
 type Record struct {
 	Signature             []byte
 	FileReference         FileReference
@@ -42,21 +51,84 @@ type Record struct {
 	ActualSize            uint32
 	AllocatedSize         uint32
 	NextAttributeId       int
+	LegacyHeader          bool
 	Attributes            []Attribute
 }
 
+// ParseOptions control how records and attributes are parsed. The zero value gives the behavior of ParseRecord and
+// ParseAttributes. Besides ParseRecordWithOptions, ParseAttributesWithOptions and ParseAttributeWithOptions, the
+// parsers of attribute data which contain names or times are available as methods of ParseOptions (such as
+// ParseOptions.ParseFileName), so all data of a record can be parsed using the same options.
+type ParseOptions struct {
+	// ZeroCopy prevents copying of the input data. Byte slices in the returned Record and Attributes (such as an
+	// Attribute's Data) alias the input instead of being copies of it, and the fixup is applied to the input in place.
+	// This avoids most allocations when parsing large amounts of records, but the caller must not modify or reuse the
+	// input for as long as the parsed values are used. Use Record.Clone() or Attribute.Clone() to obtain a copy which
+	// does not alias the input.
+	ZeroCopy bool
+	// RestoreFixup restores the input to its original state after parsing a record with ZeroCopy, by putting back the
+	// original last 2 bytes of each sector. Only the data of attributes which contain the end of a sector
+	// (and so depend on the fixup) is copied; the data of all other attributes still aliases the input. This is useful
+	// when the input is shared, for example with other readers of the same buffer, and must remain unmodified. It has
+	// no effect without ZeroCopy, since the input is not modified at all then.
+	RestoreFixup bool
+	// Relaxed skips validation which is not needed to parse data that is known to be valid, such as a dump that was
+	// already verified by an earlier analysis: the record signature is not checked and the fixup is applied without
+	// verifying that the end of each sector contains the update sequence number. Checks that prevent out of bounds
+	// access are still done. Relaxed parsing of corrupt or unused records gives garbage results instead of an error,
+	// so it should not be used on data of unknown quality.
+	Relaxed bool
+	// Strict adds validation of the record header which is not needed to parse a record, to detect records which are
+	// damaged or have been tampered with: the allocated size must equal the length of the data, the actual size must
+	// not exceed it, the update sequence must be located before the first attribute and must cover all sectors, and
+	// the attributes (including the end marker) must fit within the actual size. Strict cannot be combined with
+	// Relaxed.
+	Strict bool
+	// SectorSize is the size of the sectors protected by the fixup. When zero, it is derived from the length of the
+	// data and the size of the update sequence, which is correct for complete records. Set it to parse records of
+	// which only the first sectors are available, such as carved fragments; with Relaxed, the fixup is then applied to
+	// the sectors which are present.
+	SectorSize int
+	// Location, when not nil, is the time zone of the times parsed by the methods of ParseOptions. The zero value
+	// gives times in UTC, like ConvertFileTime.
+	Location *time.Location
+	// Names controls how invalid UTF-16, embedded NUL characters and reserved characters in attribute names (and file
+	// names, for ParseOptions.ParseFileName) are handled. An attribute whose name is rejected by HandleError results
+	// in an error.
+	Names utf16.DecodeOptions
+	// Logger, when not nil, receives diagnostic messages about the parsing, such as ignored fixup mismatches.
+	Logger Logger
+}
+
 // ParseRecord parses bytes into a Record after applying fixup. The data is assumed to be in Little Endian order. Only
-// the attribute headers are parsed, not the actual attribute data.
+// the attribute headers are parsed, not the actual attribute data. The input data is not modified and the returned
+// Record does not alias it.
 func ParseRecord(b []byte) (Record, error) {
+	return ParseRecordWithOptions(b, ParseOptions{})
+}
+
+// ParseRecordWithOptions parses bytes into a Record like ParseRecord does, using the specified ParseOptions.
+func ParseRecordWithOptions(b []byte, opts ParseOptions) (Record, error) {
+	if opts.Strict && opts.Relaxed {
+		return Record{}, fmt.Errorf("strict and relaxed parsing cannot be combined")
+	}
+	if opts.SectorSize < 0 || (opts.SectorSize > 0 && opts.SectorSize < 4) {
+		return Record{}, fmt.Errorf("invalid sector size %d", opts.SectorSize)
+	}
 	if len(b) < 42 {
 		return Record{}, fmt.Errorf("record data length should be at least 42 but is %d", len(b))
 	}
 	sig := b[:4]
-	if bytes.Compare(sig, fileSignature) != 0 {
+	if !opts.Relaxed && bytes.Compare(sig, fileSignature) != 0 {
 		return Record{}, fmt.Errorf("unknown record signature: %# x", sig)
 	}
 
-	b = binutil.Duplicate(b)
+	if opts.ZeroCopy {
+		sig = b[:4:4]
+	} else {
+		b = binutil.Duplicate(b)
+		sig = b[:4]
+	}
 	r := binutil.NewLittleEndianReader(b)
 	baseRecordRef, err := ParseFileReference(r.Read(0x20, 8))
 	if err != nil {
@@ -70,18 +142,48 @@ func ParseRecord(b []byte) (Record, error) {
 
 	updateSequenceOffset := int(r.Uint16(0x04))
 	updateSequenceSize := int(r.Uint16(0x06))
-	b, err = applyFixUp(b, updateSequenceOffset, updateSequenceSize)
+	sectorSize, sectorCount, err := fixupSectors(len(b), updateSequenceSize, opts.SectorSize, !opts.Relaxed, opts.Logger)
+	if err != nil {
+		return Record{}, fmt.Errorf("unable to apply fixup: %v", err)
+	}
+	var sectorEnds []byte
+	if opts.ZeroCopy && opts.RestoreFixup {
+		sectorEnds = saveSectorEnds(b, sectorSize, sectorCount)
+	}
+	if opts.Strict {
+		if err := validateHeader(r, len(b), firstAttributeOffset, updateSequenceOffset, updateSequenceSize); err != nil {
+			return Record{}, err
+		}
+	}
+	b, err = applyFixUp(b, updateSequenceOffset, sectorSize, sectorCount, !opts.Relaxed, opts.Logger)
 	if err != nil {
 		return Record{}, fmt.Errorf("unable to apply fixup: %v", err)
 	}
 
-	attributes, err := ParseAttributes(b[firstAttributeOffset:])
+	// At this point b is either owned by the caller (ZeroCopy) or a private copy, so there's no need for the attributes
+	// to copy their data again
+	attributeOpts := opts
+	attributeOpts.ZeroCopy = true
+	attributes, err := ParseAttributesWithOptions(b[firstAttributeOffset:], attributeOpts)
+	if opts.ZeroCopy && opts.RestoreFixup {
+		if err == nil {
+			copyFixedUpAttributes(b, firstAttributeOffset, attributes, sectorSize)
+		}
+		restoreSectorEnds(b, sectorSize, sectorCount, sectorEnds)
+	}
 	if err != nil {
 		return Record{}, err
 	}
+	legacyHeader := updateSequenceOffset < recordHeaderSize
+	recordNumber := uint64(0)
+	if !legacyHeader {
+		recordNumber = uint64(r.Uint32(0x2C))
+	} else {
+		opts.logf("update sequence at offset %d indicates an NTFS 1.2 header without record number", updateSequenceOffset)
+	}
 	return Record{
-		Signature:             binutil.Duplicate(sig),
-		FileReference:         FileReference{RecordNumber: uint64(r.Uint32(0x2C)), SequenceNumber: r.Uint16(0x10)},
+		Signature:             sig,
+		FileReference:         FileReference{RecordNumber: recordNumber, SequenceNumber: r.Uint16(0x10)},
 		BaseRecordReference:   baseRecordRef,
 		LogFileSequenceNumber: r.Uint64(0x08),
 		HardLinkCount:         int(r.Uint16(0x12)),
@@ -89,10 +191,23 @@ func ParseRecord(b []byte) (Record, error) {
 		ActualSize:            r.Uint32(0x18),
 		AllocatedSize:         r.Uint32(0x1C),
 		NextAttributeId:       int(r.Uint16(0x28)),
+		LegacyHeader:          legacyHeader,
 		Attributes:            attributes,
 	}, nil
 }
 
+// Clone returns a deep copy of the Record which does not share any data with the original. This is useful to take
+// ownership of a Record parsed with ParseOptions.ZeroCopy.
+func (r Record) Clone() Record {
+	c := r
+	c.Signature = binutil.Duplicate(r.Signature)
+	c.Attributes = make([]Attribute, len(r.Attributes))
+	for i, a := range r.Attributes {
+		c.Attributes[i] = a.Clone()
+	}
+	return c
+}
+
 // A FileReference represents a reference to an MFT record. Since the FileReference in a Record is only 4 bytes, the
 // RecordNumber will probably not exceed 32 bits.
 type FileReference struct {
@@ -124,25 +239,111 @@ const (
 	RecordFlagIsIndex     RecordFlag = 0x0008
 )
 
+// recordFlagNames contains the names of the RecordFlag bits, in order of their value.
+var recordFlagNames = []bitName{
+	{uint64(RecordFlagInUse), "InUse"},
+	{uint64(RecordFlagIsDirectory), "IsDirectory"},
+	{uint64(RecordFlagInExtend), "InExtend"},
+	{uint64(RecordFlagIsIndex), "IsIndex"},
+}
+
 // Is checks if this RecordFlag's bit mask contains the specified flag.
 func (f *RecordFlag) Is(c RecordFlag) bool {
 	return *f&c == c
 }
 
-func applyFixUp(b []byte, offset int, length int) ([]byte, error) {
+// String returns the names of the flags set in the bit mask, separated by a pipe, for example "InUse|IsDirectory".
+func (f RecordFlag) String() string {
+	return formatBits(uint64(f), recordFlagNames)
+}
+
+// validateHeader does the additional validation of the record header of ParseOptions.Strict, before the fixup is
+// applied.
+func validateHeader(r *binutil.BinReader, length int, firstAttributeOffset int, usOffset int, usSize int) error {
+	allocatedSize := int64(r.Uint32(0x1C))
+	actualSize := int64(r.Uint32(0x18))
+	if allocatedSize != int64(length) {
+		return fmt.Errorf("allocated size %d does not match record data length %d", allocatedSize, length)
+	}
+	if actualSize > allocatedSize {
+		return fmt.Errorf("actual size %d exceeds allocated size %d", actualSize, allocatedSize)
+	}
+	if usEnd := usOffset + usSize*2; usEnd > firstAttributeOffset {
+		return fmt.Errorf("update sequence (offset %d, %d bytes) overlaps first attribute at offset %d", usOffset, usSize*2, firstAttributeOffset)
+	}
+	if usSize < 2 || length%(usSize-1) != 0 {
+		return fmt.Errorf("update sequence size %d does not match record data length %d", usSize, length)
+	}
+
+	// Walk the attribute headers to find the end marker; the data of the attributes is not needed for that
+	position := firstAttributeOffset
+	for {
+		if int64(position)+4 > actualSize {
+			return fmt.Errorf("attributes exceed actual size %d", actualSize)
+		}
+		if r.Uint32(position) == uint32(AttributeTypeTerminator) {
+			return nil
+		}
+		if int64(position)+8 > actualSize {
+			return fmt.Errorf("attributes exceed actual size %d", actualSize)
+		}
+		attributeLength := int(r.Uint32(position + 4))
+		if attributeLength <= 0 {
+			// Reported by ParseAttributes
+			return nil
+		}
+		position += attributeLength
+	}
+}
+
+// fixupSectors determines the size and number of the sectors protected by an update sequence of length pairs (including
+// the update sequence number) in data of dataLength bytes. When sectorSize is zero, it is derived from the data length.
+// Otherwise the update sequence must cover exactly the sectors in the data; when verify is false, a mismatch is only
+// logged and the fixup is applied to the sectors which are both present and covered.
+func fixupSectors(dataLength int, length int, sectorSize int, verify bool, logger Logger) (int, int, error) {
+	count := length - 1
+	if count <= 0 {
+		return 0, 0, fmt.Errorf("update sequence size %d does not cover any sectors", length)
+	}
+	if sectorSize == 0 {
+		sectorSize = dataLength / count
+		if sectorSize < 2 {
+			return 0, 0, fmt.Errorf("update sequence size %d is too large for data length %d", length, dataLength)
+		}
+		return sectorSize, count, nil
+	}
+	present := dataLength / sectorSize
+	if present != count {
+		if verify {
+			return 0, 0, fmt.Errorf("update sequence covers %d sectors, but the data contains %d sectors of %d bytes", count, present, sectorSize)
+		}
+		if logger != nil {
+			logger.Printf("update sequence covers %d sectors, but the data contains %d sectors of %d bytes", count, present, sectorSize)
+		}
+		if present < count {
+			count = present
+		}
+	}
+	return sectorSize, count, nil
+}
+
+func applyFixUp(b []byte, offset int, sectorSize int, sectorCount int, verify bool, logger Logger) ([]byte, error) {
+	if err := checkBounds("update sequence", b, int64(offset), int64(sectorCount+1)*2); err != nil {
+		return nil, err
+	}
 	r := binutil.NewLittleEndianReader(b)
 
-	updateSequence := r.Read(offset, length*2) // length is in pairs, not bytes
+	updateSequence := r.Read(offset, (sectorCount+1)*2)
 	updateSequenceNumber := updateSequence[:2]
 	updateSequenceArray := updateSequence[2:]
 
-	sectorCount := len(updateSequenceArray) / 2
-	sectorSize := len(b) / sectorCount
-
-	for i := 1; i <= sectorCount; i++ {
+	for i := 1; (verify || logger != nil) && i <= sectorCount; i++ {
 		offset := sectorSize*i - 2
 		if bytes.Compare(updateSequenceNumber, b[offset:offset+2]) != 0 {
-			return nil, fmt.Errorf("update sequence mismatch at pos %d", offset)
+			if verify {
+				return nil, fmt.Errorf("update sequence mismatch at pos %d", offset)
+			}
+			logger.Printf("ignoring update sequence mismatch at pos %d", offset)
 		}
 	}
 
@@ -155,13 +356,98 @@ func applyFixUp(b []byte, offset int, length int) ([]byte, error) {
 	return b, nil
 }
 
+// saveSectorEnds returns a copy of the last 2 bytes of each sector, which are overwritten when applying the fixup.
+func saveSectorEnds(b []byte, sectorSize int, sectorCount int) []byte {
+	saved := make([]byte, 0, sectorCount*2)
+	for i := 1; i <= sectorCount; i++ {
+		saved = append(saved, b[sectorSize*i-2:sectorSize*i]...)
+	}
+	return saved
+}
+
+// restoreSectorEnds reverts applyFixUp by putting back the bytes returned by saveSectorEnds.
+func restoreSectorEnds(b []byte, sectorSize int, sectorCount int, saved []byte) {
+	for i := 1; i <= sectorCount && i*2 <= len(saved); i++ {
+		copy(b[sectorSize*i-2:], saved[(i-1)*2:i*2])
+	}
+}
+
+// copyFixedUpAttributes replaces the Data of the attributes containing the end of a sector, which was changed by
+// applying the fixup, by a copy. The attributes must have been parsed from b, starting at firstAttributeOffset.
+func copyFixedUpAttributes(b []byte, firstAttributeOffset int, attributes []Attribute, sectorSize int) {
+	position := firstAttributeOffset
+	for i, a := range attributes {
+		r := binutil.NewLittleEndianReader(b[position:])
+		var dataOffset int
+		if a.Resident {
+			dataOffset = int(r.Uint16(0x14))
+		} else {
+			dataOffset = int(r.Uint16(0x20))
+		}
+		start := position + dataOffset
+		end := start + len(a.Data)
+		// The data contains the end of a sector when the last 2 bytes of the sector of its start are before its end
+		if len(a.Data) > 0 && (start/sectorSize+1)*sectorSize-2 < end {
+			attributes[i].Data = binutil.Duplicate(a.Data)
+		}
+		position += int(r.Uint32(0x04))
+	}
+}
+
 // ApplyFixup applies the NTFS fixup to the data of a Data Run.
 // http://inform.pucp.edu.pe/~inf232/Ntfs/ntfs_doc_v0.5/concepts/fixup.html
 func ApplyFixup(b []byte) ([]byte, error) {
+	if err := checkBounds("fixup header", b, 0, 8); err != nil {
+		return nil, err
+	}
 	r := binutil.NewLittleEndianReader(b)
 	updateSequenceOffset := int(r.Uint16(0x04))
 	updateSequenceSize := int(r.Uint16(0x06))
-	return applyFixUp(b, updateSequenceOffset, updateSequenceSize)
+	sectorSize, sectorCount, err := fixupSectors(len(b), updateSequenceSize, 0, true, nil)
+	if err != nil {
+		return nil, err
+	}
+	return applyFixUp(b, updateSequenceOffset, sectorSize, sectorCount, true, nil)
+}
+
+// IsInUse returns true if the record is in use, ie. the file or directory it represents has not been deleted.
+func (r *Record) IsInUse() bool {
+	return r.Flags.Is(RecordFlagInUse)
+}
+
+// IsDirectory returns true if the record represents a directory.
+func (r *Record) IsDirectory() bool {
+	return r.Flags.Is(RecordFlagIsDirectory)
+}
+
+// IsExtension returns true if the record is an extension record, containing attributes of the base record its
+// BaseRecordReference points to.
+func (r *Record) IsExtension() bool {
+	return r.BaseRecordReference != FileReference{}
+}
+
+// IsReferencedBy checks if ref (for example the parent reference of a $FILE_NAME) points to this record, as opposed to
+// an earlier or later file which used the same MFT record. The record numbers must be equal (unless the record has a
+// LegacyHeader, in which case its record number is unknown) and so must the sequence numbers. Since NTFS increments
+// the sequence number of a record when the file it represents is deleted, a reference to a deleted (not in use)
+// record also matches when its sequence number is one lower than that of the record.
+func (r *Record) IsReferencedBy(ref FileReference) bool {
+	if !r.LegacyHeader && ref.RecordNumber != r.FileReference.RecordNumber {
+		return false
+	}
+	sequenceNumber := r.FileReference.SequenceNumber
+	if ref.SequenceNumber == sequenceNumber {
+		return true
+	}
+	if r.IsInUse() {
+		return false
+	}
+	previous := sequenceNumber - 1
+	if previous == 0 {
+		// Sequence number 0 is skipped when the sequence number wraps around
+		previous = 0xFFFF
+	}
+	return ref.SequenceNumber == previous
 }
 
 // FindAttributes returns all attributes of the specified type contained in this record. When no matches are found an
@@ -176,18 +462,88 @@ func (r *Record) FindAttributes(attrType AttributeType) []Attribute {
 	return ret
 }
 
+// FindFirstAttribute returns the first attribute of the specified type contained in this record. The boolean is false
+// when no such attribute exists. Unlike FindAttributes, it does not allocate.
+func (r *Record) FindFirstAttribute(attrType AttributeType) (Attribute, bool) {
+	for _, a := range r.Attributes {
+		if a.Type == attrType {
+			return a, true
+		}
+	}
+	return Attribute{}, false
+}
+
+// AllNames returns the parsed $FILE_NAME attributes of this record, in the order in which they appear. Attributes whose
+// data cannot be parsed are left out. When the record has no (valid) $FILE_NAME attributes, an empty slice is returned.
+func (r *Record) AllNames() []FileName {
+	ret := make([]FileName, 0)
+	for _, a := range r.Attributes {
+		if a.Type != AttributeTypeFileName {
+			continue
+		}
+		fn, err := ParseFileName(a.Data)
+		if err != nil {
+			continue
+		}
+		ret = append(ret, fn)
+	}
+	return ret
+}
+
+// PreferredFileName returns the $FILE_NAME of this record which best represents the name of the file, preferring the
+// namespaces in the order Win32Dos, Win32, POSIX and finally DOS. This avoids reporting a short 8.3 name such as
+// "PROGRA~1" when the long name is available as well. When multiple names of the same namespace exist (ie. hard
+// links), the first one is returned. The boolean is false when the record has no (valid) $FILE_NAME attributes.
+func (r *Record) PreferredFileName() (FileName, bool) {
+	var found FileName
+	ok := false
+	for _, fn := range r.AllNames() {
+		if !ok || namespacePreference(fn.Namespace) < namespacePreference(found.Namespace) {
+			found = fn
+			ok = true
+		}
+	}
+	return found, ok
+}
+
+// namespacePreference ranks a FileNameNamespace for PreferredFileName, where a lower value is preferred.
+func namespacePreference(n FileNameNamespace) int {
+	switch n {
+	case FileNameNamespaceWin32Dos:
+		return 0
+	case FileNameNamespaceWin32:
+		return 1
+	case FileNameNamespacePosix:
+		return 2
+	case FileNameNamespaceDos:
+		return 3
+	}
+	return 4
+}
+
 // Attribute represents an MFT record attribute header and its corresponding raw attribute Data (excluding header data).
 // When the attribute is Resident, the Data contains the actual attribute's data. When the attribute is non-resident,
 // the Data contains DataRuns pointing to the actual data. DataRun data can be parsed using ParseDataRuns().
+//
+// The sizes are only set for non-resident attributes. The ActualSize is the logical size of the data and the
+// AllocatedSize the size of the clusters covered by the data runs (including sparse runs). The InitializedSize is the
+// size of the data which was actually written; beyond it, the data reads as zeroes. For compressed attributes, the
+// CompressionUnit is the size of a compression unit as a power of 2 of the number of clusters (4, ie. 16 clusters, in
+// practice). Compressed and sparse attributes also have a TotalAllocatedSize: the size of the clusters actually
+// allocated on the volume, which Windows shows as "size on disk". It is zero for other attributes; use PhysicalSize
+// to calculate it from the data runs of any non-resident attribute.
 type Attribute struct {
-	Type          AttributeType
-	Resident      bool
-	Name          string
-	Flags         AttributeFlags
-	AttributeId   int
-	AllocatedSize uint64
-	ActualSize    uint64
-	Data          []byte
+	Type               AttributeType
+	Resident           bool
+	Name               string
+	Flags              AttributeFlags
+	AttributeId        int
+	AllocatedSize      uint64
+	ActualSize         uint64
+	InitializedSize    uint64
+	CompressionUnit    int
+	TotalAllocatedSize uint64
+	Data               []byte
 }
 
 // AttributeType represents the type of an Attribute. Use Name() to get the attribute type's name.
@@ -224,18 +580,44 @@ const (
 	AttributeFlagsSparse     AttributeFlags = 0x8000
 )
 
+// attributeFlagsNames contains the names of the AttributeFlags bits, in order of their value.
+var attributeFlagsNames = []bitName{
+	{uint64(AttributeFlagsCompressed), "Compressed"},
+	{uint64(AttributeFlagsEncrypted), "Encrypted"},
+	{uint64(AttributeFlagsSparse), "Sparse"},
+}
+
 // Is checks if this AttributeFlags's bit mask contains the specified flag.
 func (f *AttributeFlags) Is(c AttributeFlags) bool {
 	return *f&c == c
 }
 
+// String returns the names of the flags set in the bit mask, separated by a pipe, for example "Compressed|Sparse".
+func (f AttributeFlags) String() string {
+	return formatBits(uint64(f), attributeFlagsNames)
+}
+
+// Clone returns a copy of the Attribute with its own copy of the Data. This is useful to take ownership of an
+// Attribute parsed with ParseOptions.ZeroCopy.
+func (a Attribute) Clone() Attribute {
+	c := a
+	c.Data = binutil.Duplicate(a.Data)
+	return c
+}
+
 // ParseAttributes parses bytes into Attributes. The data is assumed to be in Little Endian order. Only the attribute
 // headers are parsed, not the actual attribute data.
 func ParseAttributes(b []byte) ([]Attribute, error) {
+	return ParseAttributesWithOptions(b, ParseOptions{})
+}
+
+// ParseAttributesWithOptions parses bytes into Attributes like ParseAttributes does, using the specified
+// ParseOptions.
+func ParseAttributesWithOptions(b []byte, opts ParseOptions) ([]Attribute, error) {
 	if len(b) == 0 {
 		return []Attribute{}, nil
 	}
-	attributes := make([]Attribute, 0)
+	attributes := make([]Attribute, 0, countAttributes(b))
 	for len(b) > 0 {
 		if len(b) < 4 {
 			return nil, fmt.Errorf("attribute header data should be at least 4 bytes but is %d", len(b))
@@ -265,7 +647,7 @@ func ParseAttributes(b []byte) ([]Attribute, error) {
 		}
 
 		recordData := r.Read(0, recordLength)
-		attribute, err := ParseAttribute(recordData)
+		attribute, err := ParseAttributeWithOptions(recordData, opts)
 		if err != nil {
 			return nil, err
 		}
@@ -275,9 +657,32 @@ func ParseAttributes(b []byte) ([]Attribute, error) {
 	return attributes, nil
 }
 
+// countAttributes quickly determines the number of attributes in b by only looking at their types and lengths, so the
+// slice of attributes can be allocated at once. Any invalid data simply ends the count; ParseAttributes will report it.
+func countAttributes(b []byte) int {
+	count := 0
+	for len(b) >= 8 {
+		if binary.LittleEndian.Uint32(b) == uint32(AttributeTypeTerminator) {
+			break
+		}
+		length := binary.LittleEndian.Uint32(b[4:])
+		if length == 0 || uint64(length) > uint64(len(b)) {
+			break
+		}
+		count++
+		b = b[length:]
+	}
+	return count
+}
+
 // ParseAttribute parses bytes into an Attribute. The data is assumed to be in Little Endian order. Only the attribute
 // headers are parsed, not the actual attribute data.
 func ParseAttribute(b []byte) (Attribute, error) {
+	return ParseAttributeWithOptions(b, ParseOptions{})
+}
+
+// ParseAttributeWithOptions parses bytes into an Attribute like ParseAttribute does, using the specified ParseOptions.
+func ParseAttributeWithOptions(b []byte, opts ParseOptions) (Attribute, error) {
 	if len(b) < 22 {
 		return Attribute{}, fmt.Errorf("attribute data should be at least 22 bytes but is %d", len(b))
 	}
@@ -289,14 +694,24 @@ func ParseAttribute(b []byte) (Attribute, error) {
 
 	name := ""
 	if nameLength != 0 {
+		if err := checkBounds("attribute name", b, int64(nameOffset), int64(nameLength)*2); err != nil {
+			return Attribute{}, err
+		}
 		nameBytes := r.Read(int(nameOffset), int(nameLength)*2)
-		name = utf16.DecodeString(nameBytes, binary.LittleEndian)
+		var err error
+		name, err = utf16.DecodeStringWithOptions(nameBytes, binary.LittleEndian, opts.Names)
+		if err != nil {
+			return Attribute{}, fmt.Errorf("unable to decode attribute name: %v", err)
+		}
 	}
 
 	resident := r.Byte(0x08) == 0x00
 	var attributeData []byte
 	actualSize := uint64(0)
 	allocatedSize := uint64(0)
+	initializedSize := uint64(0)
+	compressionUnit := 0
+	totalAllocatedSize := uint64(0)
 	if resident {
 		dataOffset := int(r.Uint16(0x14))
 		uDataLength := r.Uint32(0x10)
@@ -312,37 +727,65 @@ func ParseAttribute(b []byte) (Attribute, error) {
 
 		attributeData = r.Read(dataOffset, dataLength)
 	} else {
+		if err := checkBounds("non-resident attribute header", b, 0, 0x38); err != nil {
+			return Attribute{}, err
+		}
 		dataOffset := int(r.Uint16(0x20))
 		if len(b) < dataOffset {
 			return Attribute{}, fmt.Errorf("expected attribute data length to be at least %d but is %d", dataOffset, len(b))
 		}
 		allocatedSize = r.Uint64(0x28)
 		actualSize = r.Uint64(0x30)
+		if len(b) >= 0x40 {
+			initializedSize = r.Uint64(0x38)
+		}
+		compressionUnit = int(r.Byte(0x22))
+		// The total allocated size is only present in the (longer) header of compressed and sparse attributes
+		flags := AttributeFlags(r.Uint16(0x0C))
+		if flags&(AttributeFlagsCompressed|AttributeFlagsSparse) != 0 && dataOffset >= 0x48 && (nameLength == 0 || nameOffset >= 0x48) {
+			totalAllocatedSize = r.Uint64(0x40)
+		}
 		attributeData = r.ReadFrom(int(dataOffset))
 	}
 
+	if opts.ZeroCopy {
+		// Limit the capacity so appending to the Data cannot overwrite the input beyond it
+		attributeData = attributeData[:len(attributeData):len(attributeData)]
+	} else {
+		attributeData = binutil.Duplicate(attributeData)
+	}
 	return Attribute{
-		Type:          AttributeType(r.Uint32(0)),
-		Resident:      resident,
-		Name:          name,
-		Flags:         AttributeFlags(r.Uint16(0x0C)),
-		AttributeId:   int(r.Uint16(0x0E)),
-		AllocatedSize: allocatedSize,
-		ActualSize:    actualSize,
-		Data:          binutil.Duplicate(attributeData),
+		Type:               AttributeType(r.Uint32(0)),
+		Resident:           resident,
+		Name:               name,
+		Flags:              AttributeFlags(r.Uint16(0x0C)),
+		AttributeId:        int(r.Uint16(0x0E)),
+		AllocatedSize:      allocatedSize,
+		ActualSize:         actualSize,
+		InitializedSize:    initializedSize,
+		CompressionUnit:    compressionUnit,
+		TotalAllocatedSize: totalAllocatedSize,
+		Data:               attributeData,
 	}, nil
 }
 
 // A DataRun represents a fragment of data somewhere on a volume. The OffsetCluster, which can be negative, is relative
 // to a previous DataRun's offset. The OffsetCluster of the first DataRun in a list is relative to the beginning of the
 // volume.
+//
+// A Sparse run is not stored on the volume at all and reads as zeroes; it has no offset, so its OffsetCluster is 0
+// and the next run is relative to the run before it. The first run can be sparse too, for example in $UsnJrnl:$J,
+// which starts with a hole. This is different from a run which is not sparse but has an OffsetCluster of 0, such as
+// the first run of $Boot, which starts at cluster 0.
 type DataRun struct {
 	OffsetCluster    int64
 	LengthInClusters uint64
+	Sparse           bool
 }
 
 // ParseDataRuns parses bytes into a list of DataRuns. Each DataRun's OffsetCluster is relative to the DataRun before
-// it. The first element's OffsetCluster is relative to the beginning of the volume.
+// it. The first element's OffsetCluster is relative to the beginning of the volume. A run without offset bytes is
+// Sparse.
 func ParseDataRuns(b []byte) ([]DataRun, error) {
 	if len(b) == 0 {
 		return []DataRun{}, nil
@@ -374,7 +817,7 @@ func ParseDataRuns(b []byte) ([]DataRun, error) {
 		offsetBytes := dataRunData.Read(lengthLength, offsetLength)
 		dataOffset := int64(binary.LittleEndian.Uint64(padTo(offsetBytes, 8)))
 
-		runs = append(runs, DataRun{OffsetCluster: dataOffset, LengthInClusters: dataLength})
+		runs = append(runs, DataRun{OffsetCluster: dataOffset, LengthInClusters: dataLength, Sparse: offsetLength == 0})
 
 		b = r.ReadFrom(headerAndDataLength)
 	}
@@ -387,20 +830,43 @@ func ParseDataRuns(b []byte) ([]DataRun, error) {
 // fragment.Reader). Note that data will probably not align to a cluster exactly so there could be some padding at the
 // end. It is up to the user of the Fragments to limit reads to actual data size (eg. by using an io.LimitedReader or
 // modifying the last element in the list to limit its length).
+//
+// The Fragment of a Sparse run is marked as Sparse, so it reads as zeroes; its Offset is that of the run before it
+// (or 0). The compressed units of a compressed stream end in a sparse run, so pass the Fragments to a
+// fragment.CompressedReaderAt to read compressed streams.
 func DataRunsToFragments(runs []DataRun, bytesPerCluster int) []fragment.Fragment {
 	frags := make([]fragment.Fragment, len(runs))
 	previousOffsetCluster := int64(0)
 	for i, run := range runs {
-		exactClusterOffset := previousOffsetCluster + run.OffsetCluster
+		exactClusterOffset := previousOffsetCluster
+		if !run.Sparse {
+			exactClusterOffset += run.OffsetCluster
+		}
 		frags[i] = fragment.Fragment{
 			Offset: exactClusterOffset * int64(bytesPerCluster),
 			Length: int64(run.LengthInClusters) * int64(bytesPerCluster),
+			Sparse: run.Sparse,
 		}
 		previousOffsetCluster = exactClusterOffset
 	}
 	return frags
 }
 
+// PhysicalSize returns the number of bytes of the clusters the DataRuns occupy on the volume: the total length of
+// all runs except Sparse ones, which are not allocated. For compressed and sparse attributes, this is the same as the
+// TotalAllocatedSize in the header of the first extent, but it can be calculated for any attribute and for each
+// extent of an attribute separately.
+func PhysicalSize(runs []DataRun, bytesPerCluster int) uint64 {
+	clusters := uint64(0)
+	for _, run := range runs {
+		if run.Sparse {
+			continue
+		}
+		clusters += run.LengthInClusters
+	}
+	return clusters * uint64(bytesPerCluster)
+}
+
 func padTo(data []byte, length int) []byte {
 	if len(data) > length {
 		return data
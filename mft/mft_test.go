@@ -1,6 +1,7 @@
 package mft_test
 
 import (
+	"encoding/binary"
 	"encoding/hex"
 	"testing"
 
@@ -8,10 +9,12 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/t9t/gomft/fragment"
 	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/mfttest"
+	"github.com/t9t/gomft/utf16"
 )
 
 func TestParseRecord(t *testing.T) {
-	input := readTestMft(t)
+	input := mfttest.SampleMftRecord()
 	record, err := mft.ParseRecord(input)
 	require.Nilf(t, err, "could not parse record: %v", err)
 	expected := mft.Record{
@@ -34,7 +37,7 @@ func TestParseRecord(t *testing.T) {
 }
 
 func TestParseAttributes(t *testing.T) {
-	b := readTestMft(t)
+	b := mfttest.SampleMftRecord()
 	attributeData := b[56:]
 	attributes, err := mft.ParseAttributes(attributeData)
 	require.Nilf(t, err, "error parsing attributes: %v", err)
@@ -42,8 +45,8 @@ func TestParseAttributes(t *testing.T) {
 	expectedAttributes := []mft.Attribute{
 		mft.Attribute{Type: 16, Resident: true, Flags: 0, AttributeId: 0, Data: []byte{0x94, 0xF0, 0x48, 0x96, 0x5B, 0x2F, 0xCC, 0x1, 0x94, 0xF0, 0x48, 0x96, 0x5B, 0x2F, 0xCC, 0x1, 0x94, 0xF0, 0x48, 0x96, 0x5B, 0x2F, 0xCC, 0x1, 0x94, 0xF0, 0x48, 0x96, 0x5B, 0x2F, 0xCC, 0x1, 0x6, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x1, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0}},
 		mft.Attribute{Type: 48, Resident: true, Flags: 0, AttributeId: 3, Data: []byte{0x5, 0x0, 0x0, 0x0, 0x0, 0x0, 0x5, 0x0, 0x94, 0xF0, 0x48, 0x96, 0x5B, 0x2F, 0xCC, 0x1, 0x94, 0xF0, 0x48, 0x96, 0x5B, 0x2F, 0xCC, 0x1, 0x94, 0xF0, 0x48, 0x96, 0x5B, 0x2F, 0xCC, 0x1, 0x94, 0xF0, 0x48, 0x96, 0x5B, 0x2F, 0xCC, 0x1, 0x0, 0x0, 0xBC, 0x39, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0xBC, 0x39, 0x0, 0x0, 0x0, 0x0, 0x6, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x4, 0x3, 0x24, 0x0, 0x4D, 0x0, 0x46, 0x0, 0x54, 0x0}},
-		mft.Attribute{Type: 128, Resident: false, Flags: 0, AttributeId: 1, AllocatedSize: 1920466944, ActualSize: 1920466944, Data: []byte{0x33, 0x20, 0xC8, 0x0, 0x0, 0x0, 0xC, 0x43, 0x22, 0xB5, 0x0, 0xBA, 0x5, 0x5C, 0x3, 0x43, 0x81, 0xDE, 0x0, 0x65, 0xCF, 0x47, 0x4, 0x43, 0x84, 0xB3, 0x0, 0x5D, 0x8B, 0xEF, 0x9, 0x43, 0xB0, 0xE1, 0x0, 0x90, 0xB4, 0xB5, 0x18, 0x43, 0x0, 0xC8, 0x0, 0xF4, 0xEA, 0x13, 0x1, 0x43, 0x6, 0xC8, 0x0, 0x9A, 0x3A, 0x5A, 0xFE, 0x43, 0x12, 0xC8, 0x0, 0xF4, 0x7, 0x4D, 0xFE, 0x33, 0xF, 0xC8, 0x0, 0x23, 0xD4, 0xC0, 0x42, 0x62, 0x16, 0x54, 0x2, 0x95, 0x3, 0x0, 0x0, 0x0}},
-		mft.Attribute{Type: 176, Resident: false, Flags: 0, AttributeId: 7, AllocatedSize: 237568, ActualSize: 237024, Data: []byte{0x41, 0x3A, 0xBE, 0x84, 0x83, 0x0, 0x0, 0x0}},
+		mft.Attribute{Type: 128, Resident: false, Flags: 0, AttributeId: 1, AllocatedSize: 1920466944, ActualSize: 1920466944, InitializedSize: 1920466944, Data: []byte{0x33, 0x20, 0xC8, 0x0, 0x0, 0x0, 0xC, 0x43, 0x22, 0xB5, 0x0, 0xBA, 0x5, 0x5C, 0x3, 0x43, 0x81, 0xDE, 0x0, 0x65, 0xCF, 0x47, 0x4, 0x43, 0x84, 0xB3, 0x0, 0x5D, 0x8B, 0xEF, 0x9, 0x43, 0xB0, 0xE1, 0x0, 0x90, 0xB4, 0xB5, 0x18, 0x43, 0x0, 0xC8, 0x0, 0xF4, 0xEA, 0x13, 0x1, 0x43, 0x6, 0xC8, 0x0, 0x9A, 0x3A, 0x5A, 0xFE, 0x43, 0x12, 0xC8, 0x0, 0xF4, 0x7, 0x4D, 0xFE, 0x33, 0xF, 0xC8, 0x0, 0x23, 0xD4, 0xC0, 0x42, 0x62, 0x16, 0x54, 0x2, 0x95, 0x3, 0x0, 0x0, 0x0}},
+		mft.Attribute{Type: 176, Resident: false, Flags: 0, AttributeId: 7, AllocatedSize: 237568, ActualSize: 237024, InitializedSize: 237024, Data: []byte{0x41, 0x3A, 0xBE, 0x84, 0x83, 0x0, 0x0, 0x0}},
 	}
 
 	assert.Equal(t, expectedAttributes, attributes)
@@ -71,6 +74,7 @@ func TestDataRunsToFragments(t *testing.T) {
 		mft.DataRun{OffsetCluster: 5521, LengthInClusters: 1337},
 		mft.DataRun{OffsetCluster: -4408, LengthInClusters: 42},
 		mft.DataRun{OffsetCluster: 7708, LengthInClusters: 13},
+		mft.DataRun{LengthInClusters: 3, Sparse: true},
 	}
 
 	fragments := mft.DataRunsToFragments(runs, 512)
@@ -78,11 +82,63 @@ func TestDataRunsToFragments(t *testing.T) {
 		fragment.Fragment{Offset: 2826752, Length: 684544},
 		fragment.Fragment{Offset: 569856, Length: 21504},
 		fragment.Fragment{Offset: 4516352, Length: 6656},
+		fragment.Fragment{Offset: 4516352, Length: 1536, Sparse: true},
 	}
 
 	assert.Equal(t, expected, fragments)
 }
 
+func TestParseDataRuns_Sparse(t *testing.T) {
+	// Starts with a hole of 16 clusters (like $UsnJrnl:$J), then 4 clusters at 32, a hole of 4 clusters, and 2 clusters
+	// at cluster 0 relative to the previous run (so not sparse)
+	runs, err := mft.ParseDataRuns([]byte{0x01, 0x10, 0x11, 0x04, 0x20, 0x01, 0x04, 0x11, 0x02, 0x00, 0x00})
+	require.Nilf(t, err, "error parsing dataruns: %v", err)
+
+	expected := []mft.DataRun{
+		{LengthInClusters: 16, Sparse: true},
+		{OffsetCluster: 32, LengthInClusters: 4},
+		{LengthInClusters: 4, Sparse: true},
+		{OffsetCluster: 0, LengthInClusters: 2},
+	}
+	assert.Equal(t, expected, runs)
+
+	assert.Equal(t, []fragment.Fragment{
+		{Offset: 0, Length: 16 * 512, Sparse: true},
+		{Offset: 32 * 512, Length: 4 * 512},
+		{Offset: 32 * 512, Length: 4 * 512, Sparse: true},
+		{Offset: 32 * 512, Length: 2 * 512},
+	}, mft.DataRunsToFragments(runs, 512))
+	assert.Equal(t, uint64(6*512), mft.PhysicalSize(runs, 512))
+}
+
+func TestPhysicalSize(t *testing.T) {
+	runs := []mft.DataRun{
+		mft.DataRun{OffsetCluster: 5521, LengthInClusters: 12},
+		mft.DataRun{LengthInClusters: 4, Sparse: true},
+		mft.DataRun{OffsetCluster: -4408, LengthInClusters: 16},
+	}
+
+	assert.Equal(t, uint64(28*4096), mft.PhysicalSize(runs, 4096))
+	assert.Equal(t, uint64(0), mft.PhysicalSize(nil, 4096))
+}
+
+func TestParseAttributeCompressedSizes(t *testing.T) {
+	input := decodeHex(t, "8000000050000000010048000100030000000000000000000f000000000000004800040000000000000001000000000060ea00000000000050c300000000000000c0000000000000110c200104000000")
+
+	attribute, err := mft.ParseAttribute(input)
+	require.Nilf(t, err, "error parsing attribute: %v", err)
+
+	assert.Equal(t, uint64(65536), attribute.AllocatedSize)
+	assert.Equal(t, uint64(60000), attribute.ActualSize)
+	assert.Equal(t, uint64(50000), attribute.InitializedSize)
+	assert.Equal(t, 4, attribute.CompressionUnit)
+	assert.Equal(t, uint64(49152), attribute.TotalAllocatedSize)
+
+	runs, err := mft.ParseDataRuns(attribute.Data)
+	require.Nilf(t, err, "error parsing data runs: %v", err)
+	assert.Equal(t, attribute.TotalAllocatedSize, mft.PhysicalSize(runs, 4096))
+}
+
 func TestParseAttributeNamedResidentAttribute(t *testing.T) {
 	input := decodeHex(t, "8000000070000000000518000000050044000000280000002400530052004100540000000000000033ceb8f33800010310000c00040000000100000001000000000000000200000000000000000000000300000001000000000000000000000000000000f4c400000000000000000000")
 
@@ -93,18 +149,30 @@ func TestParseAttributeNamedResidentAttribute(t *testing.T) {
 	assert.Equal(t, expected, attribute)
 }
 
+func TestParseAttributeWithOptions_Names(t *testing.T) {
+	input := decodeHex(t, "8000000070000000000518000000050044000000280000002400530052004100540000000000000033ceb8f33800010310000c00040000000100000001000000000000000200000000000000000000000300000001000000000000000000000000000000f4c400000000000000000000")
+	input[0x18] = '/'
+
+	attribute, err := mft.ParseAttributeWithOptions(input, mft.ParseOptions{Names: utf16.DecodeOptions{Reserved: utf16.HandleReplace}})
+	require.Nilf(t, err, "error parsing attribute: %v", err)
+	assert.Equal(t, "\uFFFDSRAT", attribute.Name)
+
+	_, err = mft.ParseAttributeWithOptions(input, mft.ParseOptions{Names: utf16.DecodeOptions{Reserved: utf16.HandleError}})
+	assert.EqualError(t, err, "unable to decode attribute name: reserved character 0x002f at offset 0")
+}
+
 func TestParseAttributeNamedNonResidentAttribute(t *testing.T) {
 	input := decodeHex(t, "a000000050000000010440000000080000000000000000000200000000000000480000000000000000300000000000000030000000000000003000000000000024004900330030002103081200000000")
 
 	attribute, err := mft.ParseAttribute(input)
 	require.Nilf(t, err, "error parsing attribute: %v", err)
 
-	expected := mft.Attribute{Type: 0xA0, Resident: false, Name: "$I30", Flags: 0, AttributeId: 8, AllocatedSize: 12288, ActualSize: 12288, Data: []byte{0x21, 0x3, 0x8, 0x12, 0x0, 0x0, 0x0, 0x0}}
+	expected := mft.Attribute{Type: 0xA0, Resident: false, Name: "$I30", Flags: 0, AttributeId: 8, AllocatedSize: 12288, ActualSize: 12288, InitializedSize: 12288, Data: []byte{0x21, 0x3, 0x8, 0x12, 0x0, 0x0, 0x0, 0x0}}
 	assert.Equal(t, expected, attribute)
 }
 
 func TestParseRecordFixup(t *testing.T) {
-	input := decodeHex(t, "46494c4530000300755762ef19000000150002003800010098020000000400000000000000000000060000002a0000000c000000000000001000000060000000000000000000000048000000180000007e31192b21d6d50186468bb40eded4012e7d4e954dcbd5016c7f192b21d6d5012000040000000000000000000000000000000000161300000000000000000000a068d14a05000000300000007800000000000000000003005a000000180001003b000000000009007e31192b21d6d5017e31192b21d6d5017e31192b21d6d5017e31192b21d6d5010020040000000000000000000000000020000000000000000c0249004e0054004c00500052007e0031002e0044004c004c000000000000003000000080000000000000000000020062000000180001003b000000000009007e31192b21d6d5017e31192b21d6d5017e31192b21d6d5017e31192b21d6d501002004000000000000000000000000002000000000000000100149006e0074006c00500072006f00760069006400650072002e0064006c006c00000000000000800000004800000001000000000001000000000000000000410000000000000040000000000000000020040000000000381704000000000038170400000000004142f46ea0000000d00000002000000000000000000004000800000018000000780000007c000000e000000098000c0000000000000005007c000000180000007c000000000f64002443492e434154414c4f4748494e5400010060004d6963726f736f66742d57696e646f77732d436c69656e742d4465736b746f702d52657175697265642d5061636b616765303431367e333162663338353661643336346533357e616d6436347e7e31302e302e31383336322e3539322e63617400000000ffffffff82794711000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000c00")
+	input := fixupTestRecord(t)
 
 	_, err := mft.ParseRecord(input)
 	require.Nilf(t, err, "error parsing attribute: %v", err)
@@ -112,6 +180,293 @@ func TestParseRecordFixup(t *testing.T) {
 	// without fixup, this record returns an error parsing attributes; no further assertions necessary
 }
 
+func TestParseRecordWithOptions_ZeroCopy(t *testing.T) {
+	input := mfttest.SampleMftRecord()
+	record, err := mft.ParseRecordWithOptions(input, mft.ParseOptions{ZeroCopy: true})
+	require.Nilf(t, err, "could not parse record: %v", err)
+
+	expected, err := mft.ParseRecord(mfttest.SampleMftRecord())
+	require.Nilf(t, err, "could not parse record: %v", err)
+	assert.Equal(t, expected, record)
+
+	// Fixup was applied in place
+	assert.Equal(t, []byte{0x00, 0x00}, input[510:512])
+
+	clone := record.Clone()
+	input[0] = 'X'
+	input[0x50] = 0xFF // first byte of the $STANDARD_INFORMATION data
+	assert.Equal(t, byte('X'), record.Signature[0])
+	assert.Equal(t, byte(0xFF), record.Attributes[0].Data[0])
+	assert.Equal(t, expected, clone)
+
+	// Appending must not overwrite the data following the attribute
+	data := record.Attributes[0].Data
+	_ = append(data, 0x01)
+	assert.Equal(t, byte(0x30), input[0x50+len(data)])
+}
+
+func TestParseRecordWithOptions_RestoreFixup(t *testing.T) {
+	input := fixupTestRecord(t)
+	record, err := mft.ParseRecordWithOptions(input, mft.ParseOptions{ZeroCopy: true, RestoreFixup: true})
+	require.Nilf(t, err, "could not parse record: %v", err)
+
+	expected, err := mft.ParseRecord(fixupTestRecord(t))
+	require.Nilf(t, err, "could not parse record: %v", err)
+	assert.Equal(t, expected, record)
+	assert.Equal(t, fixupTestRecord(t), input)
+
+	// The attributes do not contain the end of a sector, so they still alias the input
+	input[0x50] = 0xFF
+	assert.Equal(t, byte(0xFF), record.Attributes[0].Data[0])
+}
+
+func TestParseRecordWithOptions_RestoreFixup_CopiesData(t *testing.T) {
+	data := make([]byte, 600)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	input := residentDataRecord(data)
+	original := append([]byte{}, input...)
+	record, err := mft.ParseRecordWithOptions(input, mft.ParseOptions{ZeroCopy: true, RestoreFixup: true})
+	require.Nilf(t, err, "could not parse record: %v", err)
+
+	assert.Equal(t, original, input)
+	require.Len(t, record.Attributes, 1)
+	assert.Equal(t, data, record.Attributes[0].Data)
+	input[0x50] = 0xFF
+	assert.Equal(t, byte(0), record.Attributes[0].Data[0], "data containing the end of a sector should be copied")
+}
+
+func TestParseRecordWithOptions_Relaxed(t *testing.T) {
+	input := residentDataRecord([]byte{1, 2, 3})
+	copy(input, "BAAD")
+	input[510] = 0x99
+
+	_, err := mft.ParseRecord(input)
+	assert.EqualError(t, err, "unknown record signature: 0x42 0x41 0x41 0x44")
+	copy(input, "FILE")
+	_, err = mft.ParseRecord(input)
+	assert.EqualError(t, err, "unable to apply fixup: update sequence mismatch at pos 510")
+
+	copy(input, "BAAD")
+	record, err := mft.ParseRecordWithOptions(input, mft.ParseOptions{Relaxed: true})
+	require.Nilf(t, err, "could not parse record: %v", err)
+	assert.Equal(t, []byte("BAAD"), record.Signature)
+	require.Len(t, record.Attributes, 1)
+	assert.Equal(t, []byte{1, 2, 3}, record.Attributes[0].Data)
+
+	// Restoring puts back the original sector ends, even when they did not match
+	original := append([]byte{}, input...)
+	_, err = mft.ParseRecordWithOptions(input, mft.ParseOptions{Relaxed: true, ZeroCopy: true, RestoreFixup: true})
+	require.Nilf(t, err, "could not parse record: %v", err)
+	assert.Equal(t, original, input)
+}
+
+func TestParseRecordWithOptions_Strict(t *testing.T) {
+	_, err := mft.ParseRecordWithOptions(mfttest.SampleMftRecord(), mft.ParseOptions{Strict: true})
+	require.Nilf(t, err, "could not parse record: %v", err)
+
+	tests := []struct {
+		name   string
+		modify func(b []byte)
+		err    string
+	}{
+		{"allocated size", func(b []byte) { binary.LittleEndian.PutUint32(b[0x1C:], 4096) }, "allocated size 4096 does not match record data length 1024"},
+		{"actual size", func(b []byte) { binary.LittleEndian.PutUint32(b[0x18:], 2048) }, "actual size 2048 exceeds allocated size 1024"},
+		{"update sequence", func(b []byte) { binary.LittleEndian.PutUint16(b[0x14:], 0x30) }, "update sequence (offset 48, 6 bytes) overlaps first attribute at offset 48"},
+		{"attributes", func(b []byte) { binary.LittleEndian.PutUint32(b[0x18:], 0x100) }, "attributes exceed actual size 256"},
+	}
+	for _, test := range tests {
+		input := mfttest.SampleMftRecord()
+		test.modify(input)
+		_, err := mft.ParseRecordWithOptions(input, mft.ParseOptions{Strict: true})
+		assert.EqualErrorf(t, err, test.err, test.name)
+		_, err = mft.ParseRecord(input)
+		if test.name != "update sequence" {
+			assert.Nilf(t, err, "%s: default parsing should not fail: %v", test.name, err)
+		}
+	}
+
+	_, err = mft.ParseRecordWithOptions(mfttest.SampleMftRecord(), mft.ParseOptions{Strict: true, Relaxed: true})
+	assert.EqualError(t, err, "strict and relaxed parsing cannot be combined")
+}
+
+func TestParseRecordWithOptions_SectorSize(t *testing.T) {
+	input := residentDataRecord([]byte{1, 2, 3})
+	record, err := mft.ParseRecordWithOptions(input, mft.ParseOptions{SectorSize: 512})
+	require.Nilf(t, err, "could not parse record: %v", err)
+	assert.Equal(t, []byte{1, 2, 3}, record.Attributes[0].Data)
+
+	// Only the first sector of the record is available
+	logger := &testLogger{}
+	_, err = mft.ParseRecordWithOptions(input[:512], mft.ParseOptions{SectorSize: 512})
+	assert.EqualError(t, err, "unable to apply fixup: update sequence covers 2 sectors, but the data contains 1 sectors of 512 bytes")
+	record, err = mft.ParseRecordWithOptions(input[:512], mft.ParseOptions{SectorSize: 512, Relaxed: true, Logger: logger})
+	require.Nilf(t, err, "could not parse record: %v", err)
+	assert.Equal(t, []byte{1, 2, 3}, record.Attributes[0].Data)
+	assert.Equal(t, []string{"update sequence covers 2 sectors, but the data contains 1 sectors of 512 bytes"}, logger.messages)
+
+	_, err = mft.ParseRecordWithOptions(input, mft.ParseOptions{SectorSize: 2})
+	assert.EqualError(t, err, "invalid sector size 2")
+}
+
+func TestParseRecord_LegacyHeader(t *testing.T) {
+	input := legacyRecord([]byte{1, 2, 3})
+	record, err := mft.ParseRecord(input)
+	require.Nilf(t, err, "could not parse record: %v", err)
+
+	assert.True(t, record.LegacyHeader)
+	assert.Equal(t, mft.FileReference{RecordNumber: 0, SequenceNumber: 4}, record.FileReference)
+	require.Len(t, record.Attributes, 1)
+	assert.Equal(t, []byte{1, 2, 3}, record.Attributes[0].Data)
+
+	current, err := mft.ParseRecord(residentDataRecord([]byte{1, 2, 3}))
+	require.Nilf(t, err, "could not parse record: %v", err)
+	assert.False(t, current.LegacyHeader)
+	assert.Equal(t, uint64(1234), current.FileReference.RecordNumber)
+}
+
+// legacyRecord creates a record like residentDataRecord, but with the NTFS 1.2 header layout: the update sequence
+// starts at 0x2A and there is no record number.
+func legacyRecord(data []byte) []byte {
+	b := residentDataRecord(data)
+	copy(b[0x2A:0x30], b[0x30:0x36])
+	copy(b[0x30:0x36], make([]byte, 6))
+	binary.LittleEndian.PutUint16(b[0x04:], 0x2A)
+	return b
+}
+
+// residentDataRecord creates a record of 1024 bytes with a resident $DATA attribute containing data, which starts at
+// offset 0x50.
+func residentDataRecord(data []byte) []byte {
+	b := make([]byte, 1024)
+	copy(b, "FILE")
+	binary.LittleEndian.PutUint16(b[0x04:], 0x30) // update sequence offset
+	binary.LittleEndian.PutUint16(b[0x06:], 3)    // update sequence size
+	binary.LittleEndian.PutUint16(b[0x14:], 0x38) // first attribute offset
+	binary.LittleEndian.PutUint16(b[0x10:], 4)    // sequence number
+	binary.LittleEndian.PutUint16(b[0x16:], 0x01) // in use
+	binary.LittleEndian.PutUint32(b[0x2C:], 1234) // record number
+
+	length := (0x18 + len(data) + 7) &^ 7
+	binary.LittleEndian.PutUint32(b[0x38:], uint32(mft.AttributeTypeData))
+	binary.LittleEndian.PutUint32(b[0x3C:], uint32(length))
+	binary.LittleEndian.PutUint32(b[0x48:], uint32(len(data)))
+	binary.LittleEndian.PutUint16(b[0x4C:], 0x18)
+	copy(b[0x50:], data)
+	binary.LittleEndian.PutUint32(b[0x38+length:], 0xFFFFFFFF)
+
+	// Save the actual values at the end of each sector in the update sequence array and replace them by the update
+	// sequence number
+	copy(b[0x32:], b[510:512])
+	copy(b[0x34:], b[1022:1024])
+	binary.LittleEndian.PutUint16(b[0x30:], 7)
+	binary.LittleEndian.PutUint16(b[510:], 7)
+	binary.LittleEndian.PutUint16(b[1022:], 7)
+	return b
+}
+
+func fixupTestRecord(t *testing.T) []byte {
+	return decodeHex(t, "46494c4530000300755762ef19000000150002003800010098020000000400000000000000000000060000002a0000000c000000000000001000000060000000000000000000000048000000180000007e31192b21d6d50186468bb40eded4012e7d4e954dcbd5016c7f192b21d6d5012000040000000000000000000000000000000000161300000000000000000000a068d14a05000000300000007800000000000000000003005a000000180001003b000000000009007e31192b21d6d5017e31192b21d6d5017e31192b21d6d5017e31192b21d6d5010020040000000000000000000000000020000000000000000c0249004e0054004c00500052007e0031002e0044004c004c000000000000003000000080000000000000000000020062000000180001003b000000000009007e31192b21d6d5017e31192b21d6d5017e31192b21d6d5017e31192b21d6d501002004000000000000000000000000002000000000000000100149006e0074006c00500072006f00760069006400650072002e0064006c006c00000000000000800000004800000001000000000001000000000000000000410000000000000040000000000000000020040000000000381704000000000038170400000000004142f46ea0000000d00000002000000000000000000004000800000018000000780000007c000000e000000098000c0000000000000005007c000000180000007c000000000f64002443492e434154414c4f4748494e5400010060004d6963726f736f66742d57696e646f77732d436c69656e742d4465736b746f702d52657175697265642d5061636b616765303431367e333162663338353661643336346533357e616d6436347e7e31302e302e31383336322e3539322e63617400000000ffffffff82794711000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000c00")
+}
+
+func TestParseRecord_DoesNotAliasInput(t *testing.T) {
+	input := mfttest.SampleMftRecord()
+	record, err := mft.ParseRecord(input)
+	require.Nilf(t, err, "could not parse record: %v", err)
+
+	assert.Equal(t, []byte{0x90, 0x06}, input[510:512])
+	input[0] = 'X'
+	input[0x50] = 0xFF
+	assert.Equal(t, byte('F'), record.Signature[0])
+	assert.Equal(t, byte(0x94), record.Attributes[0].Data[0])
+}
+
+func TestFindFirstAttribute(t *testing.T) {
+	record, err := mft.ParseRecord(mfttest.SampleMftRecord())
+	require.Nilf(t, err, "could not parse record: %v", err)
+
+	a, ok := record.FindFirstAttribute(mft.AttributeTypeData)
+	assert.True(t, ok)
+	assert.Equal(t, record.FindAttributes(mft.AttributeTypeData)[0], a)
+
+	_, ok = record.FindFirstAttribute(mft.AttributeTypeIndexRoot)
+	assert.False(t, ok)
+}
+
+func TestAllNames(t *testing.T) {
+	record := mft.Record{Attributes: []mft.Attribute{
+		{Type: mft.AttributeTypeFileName, Data: fileNameData("PROGRA~1", mft.FileNameNamespaceDos)},
+		{Type: mft.AttributeTypeStandardInformation, Data: make([]byte, 72)},
+		{Type: mft.AttributeTypeFileName, Data: []byte{1, 2, 3}},
+		{Type: mft.AttributeTypeFileName, Data: fileNameData("Program Files", mft.FileNameNamespaceWin32)},
+	}}
+
+	names := record.AllNames()
+	require.Equal(t, 2, len(names))
+	assert.Equal(t, "PROGRA~1", names[0].Name)
+	assert.Equal(t, "Program Files", names[1].Name)
+
+	assert.Equal(t, []mft.FileName{}, (&mft.Record{}).AllNames())
+}
+
+func TestPreferredFileName(t *testing.T) {
+	tests := []struct {
+		namespaces []mft.FileNameNamespace
+		expected   string
+	}{
+		{[]mft.FileNameNamespace{mft.FileNameNamespaceDos, mft.FileNameNamespaceWin32}, "Win32"},
+		{[]mft.FileNameNamespace{mft.FileNameNamespaceWin32, mft.FileNameNamespaceDos}, "Win32"},
+		{[]mft.FileNameNamespace{mft.FileNameNamespacePosix, mft.FileNameNamespaceWin32Dos}, "Win32Dos"},
+		{[]mft.FileNameNamespace{mft.FileNameNamespaceDos, mft.FileNameNamespacePosix}, "Posix"},
+		{[]mft.FileNameNamespace{mft.FileNameNamespaceDos}, "Dos"},
+		{[]mft.FileNameNamespace{mft.FileNameNamespace(7), mft.FileNameNamespaceDos}, "Dos"},
+	}
+	for _, test := range tests {
+		record := mft.Record{}
+		for _, ns := range test.namespaces {
+			record.Attributes = append(record.Attributes, mft.Attribute{Type: mft.AttributeTypeFileName, Data: fileNameData(ns.String(), ns)})
+		}
+		fn, ok := record.PreferredFileName()
+		assert.Truef(t, ok, "namespaces %v", test.namespaces)
+		assert.Equalf(t, test.expected, fn.Name, "namespaces %v", test.namespaces)
+	}
+
+	_, ok := (&mft.Record{}).PreferredFileName()
+	assert.False(t, ok)
+}
+
+func TestPreferredFileName_FirstOfSameNamespace(t *testing.T) {
+	record := mft.Record{Attributes: []mft.Attribute{
+		{Type: mft.AttributeTypeFileName, Data: fileNameData("first.txt", mft.FileNameNamespacePosix)},
+		{Type: mft.AttributeTypeFileName, Data: fileNameData("second.txt", mft.FileNameNamespacePosix)},
+	}}
+	fn, ok := record.PreferredFileName()
+	assert.True(t, ok)
+	assert.Equal(t, "first.txt", fn.Name)
+}
+
+// fileNameData returns the data of a $FILE_NAME attribute with the specified (ASCII) name and namespace.
+func fileNameData(name string, namespace mft.FileNameNamespace) []byte {
+	b := make([]byte, 0x42+len(name)*2)
+	b[0x40] = byte(len(name))
+	b[0x41] = byte(namespace)
+	for i, c := range name {
+		b[0x42+i*2] = byte(c)
+	}
+	return b
+}
+
+func BenchmarkParseRecord(b *testing.B) {
+	input := mfttest.SampleMftRecord()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := mft.ParseRecord(input); err != nil {
+			b.Fatalf("could not parse record: %v", err)
+		}
+	}
+}
+
 func TestParseFileReference(t *testing.T) {
 	ref, err := mft.ParseFileReference([]byte{26, 179, 6, 0, 0, 0, 45, 0})
 	require.Nilf(t, err, "error parsing reference: %v", err)
@@ -145,11 +500,51 @@ func TestRecordFlag(t *testing.T) {
 	assert.True(t, f.Is(mft.RecordFlagIsIndex))
 }
 
-func readTestMft(t *testing.T) []byte {
-	return decodeHex(t, "46494c453000030034a999fb050000009100010038000100e001000000040000a0b0c0d0e0f010900800000000000000900600000000000010000000600000000000180000000000480000001800000094f048965b2fcc0194f048965b2fcc0194f048965b2fcc0194f048965b2fcc0106000000000000000000000000000000000000000001000000000000000000000000000000000000300000006800000000001800000003004a00000018000100050000000000050094f048965b2fcc0194f048965b2fcc0194f048965b2fcc0194f048965b2fcc010000bc39000000000000bc39000000000600000000000000040324004d00460054000000000000008000000090000000010040000000010000000000000000007f2707000000000040000000000000000000787200000000000078720000000000007872000000003320c80000000c4322b500ba055c034381de0065cf47044384b3005d8bef0943b0e10090b4b5184300c800f4ea13014306c8009a3a5afe4312c800f4074dfe330fc80023d4c042621654029503000000b000000048000000010040000000070000000000000000003900000000000000400000000000000000a0030000000000e09d030000000000e09d030000000000413abe8483000000ffffffff00000000ffffffff00000000ffffffff00000000ffffffff00000000ffffffff00009006ffffffff00000000ffffffff00000000ffffffff00000000ffffffff00000000ffffffff0000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000009006")
+func TestRecordFlag_String(t *testing.T) {
+	assert.Equal(t, "0", mft.RecordFlag(0).String())
+	assert.Equal(t, "InUse|IsDirectory", mft.RecordFlag(3).String())
+	assert.Equal(t, "InUse|InExtend|IsIndex", mft.RecordFlag(13).String())
+	assert.Equal(t, "IsDirectory|0x30", mft.RecordFlag(0x32).String())
+}
+
+func TestRecordPredicates(t *testing.T) {
+	record := mft.Record{Flags: mft.RecordFlagInUse | mft.RecordFlagIsDirectory}
+	assert.True(t, record.IsInUse())
+	assert.True(t, record.IsDirectory())
+	assert.False(t, record.IsExtension())
+
+	record = mft.Record{BaseRecordReference: mft.FileReference{RecordNumber: 42, SequenceNumber: 3}}
+	assert.False(t, record.IsInUse())
+	assert.False(t, record.IsDirectory())
+	assert.True(t, record.IsExtension())
+}
+
+func TestRecord_IsReferencedBy(t *testing.T) {
+	inUse := mft.Record{FileReference: mft.FileReference{RecordNumber: 42, SequenceNumber: 7}, Flags: mft.RecordFlagInUse}
+	assert.True(t, inUse.IsReferencedBy(mft.FileReference{RecordNumber: 42, SequenceNumber: 7}))
+	assert.False(t, inUse.IsReferencedBy(mft.FileReference{RecordNumber: 42, SequenceNumber: 6}))
+	assert.False(t, inUse.IsReferencedBy(mft.FileReference{RecordNumber: 42, SequenceNumber: 8}))
+	assert.False(t, inUse.IsReferencedBy(mft.FileReference{RecordNumber: 43, SequenceNumber: 7}))
+
+	deleted := mft.Record{FileReference: mft.FileReference{RecordNumber: 42, SequenceNumber: 7}}
+	assert.True(t, deleted.IsReferencedBy(mft.FileReference{RecordNumber: 42, SequenceNumber: 7}))
+	assert.True(t, deleted.IsReferencedBy(mft.FileReference{RecordNumber: 42, SequenceNumber: 6}))
+	assert.False(t, deleted.IsReferencedBy(mft.FileReference{RecordNumber: 42, SequenceNumber: 5}))
+
+	wrapped := mft.Record{FileReference: mft.FileReference{RecordNumber: 42, SequenceNumber: 1}}
+	assert.True(t, wrapped.IsReferencedBy(mft.FileReference{RecordNumber: 42, SequenceNumber: 0xFFFF}))
+
+	legacy := mft.Record{FileReference: mft.FileReference{SequenceNumber: 7}, LegacyHeader: true, Flags: mft.RecordFlagInUse}
+	assert.True(t, legacy.IsReferencedBy(mft.FileReference{RecordNumber: 42, SequenceNumber: 7}))
+}
+
+func TestAttributeFlags_String(t *testing.T) {
+	assert.Equal(t, "0", mft.AttributeFlags(0).String())
+	assert.Equal(t, "Compressed|Encrypted", mft.AttributeFlags(0x4001).String())
+	assert.Equal(t, "Sparse|0x2", mft.AttributeFlags(0x8002).String())
 }
 
-func decodeHex(t *testing.T, s string) []byte {
+func decodeHex(t testing.TB, s string) []byte {
 	input, err := hex.DecodeString(s)
 	require.Nilf(t, err, "unable to convert input hex to []byte: %v", err)
 	return input
@@ -0,0 +1,326 @@
+package mft
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+
+	"github.com/t9t/gomft/binutil"
+	"github.com/t9t/gomft/metrics"
+)
+
+const (
+	defaultParseAllRecordSize = 1024
+	parseAllBatchSize         = 64
+)
+
+// ParseAllOptions configures ParseAll. The zero value is usable: records of 1024 bytes are parsed using one worker
+// per CPU.
+type ParseAllOptions struct {
+	// RecordSize is the size of a single MFT record in bytes. When zero, 1024 is used.
+	RecordSize int
+	// Workers is the number of goroutines parsing records concurrently. When zero, runtime.GOMAXPROCS(0) is used.
+	Workers int
+	// SkipEmpty skips records that do not start with the FILE signature (such as unused records consisting of only
+	// zeroes) instead of reporting an error for them.
+	SkipEmpty bool
+	// Parse are the options used to parse each record. ParseAll always parses with ParseOptions.ZeroCopy, because the
+	// records are read into buffers that are not used for anything else. For ParseAllBytes, ZeroCopy should only be set
+	// when the input may be modified (for example not for a read-only memory mapped file), since the fixup is applied in
+	// place.
+	Parse ParseOptions
+	// Cancel stops parsing when it is closed, after which the results channel is closed. It can be used to stop early
+	// without draining the results channel.
+	Cancel <-chan struct{}
+	// Metrics, when not nil, receives the number of records parsed (metrics.RecordsParsed) and the number of records
+	// which could not be parsed (metrics.ParseErrors), once for each batch of records.
+	Metrics metrics.Recorder
+}
+
+// RecordResult is the result of parsing a single record by ParseAll. The Index is the position of the record in the
+// input (which is the record number for a complete MFT) and the Offset its position in bytes. For records with a
+// LegacyHeader, the Index is used as the record number. When the record could not be parsed, the Err is set and the
+// Record is empty.
+type RecordResult struct {
+	Index  int
+	Offset int64
+	Record Record
+	Err    error
+}
+
+// ReadError is the error of the final RecordResult of ParseAll when reading from the input failed (other than an
+// incomplete record at the end of the input).
+type ReadError struct {
+	Err error
+}
+
+func (e *ReadError) Error() string {
+	return fmt.Sprintf("unable to read record data: %v", e.Err)
+}
+
+type parseAllJob struct {
+	index   int
+	offset  int64
+	data    []byte
+	results chan []RecordResult
+}
+
+// ParseAll reads consecutive records of opts.RecordSize bytes from r and parses them concurrently using a pool of
+// workers. Results are sent on the returned channel in the same order as the records appear in r; the channel is
+// closed when all records have been parsed.
+//
+// Records which cannot be parsed do not stop ParseAll, instead their result contains the error. An incomplete record
+// at the end of r and errors reading from r (a *ReadError) are reported as a final result with an error. Unless
+// opts.Cancel is used, the caller must drain the channel to release the workers.
+func ParseAll(r io.Reader, opts ParseAllOptions) <-chan RecordResult {
+	return parseAllReader(r, opts, nil)
+}
+
+// parseAllReader implements ParseAll, closing finished (when not nil) once all results have been sent or parsing was
+// cancelled.
+func parseAllReader(r io.Reader, opts ParseAllOptions, finished chan struct{}) <-chan RecordResult {
+	opts.Parse.ZeroCopy = true
+	pool := binutil.NewBufferPool(parseAllBatchSize * recordSizeOrDefault(opts.RecordSize))
+	next := func(size int) ([]byte, error) {
+		buf := pool.Get()
+		n, err := io.ReadFull(r, buf)
+		if n == 0 {
+			pool.Put(buf)
+			return nil, err
+		}
+		return buf[:n], err
+	}
+	return parseAll(opts, next, pool.Put, finished)
+}
+
+// ParseAllBytes works like ParseAll, but parses the records in b directly instead of reading them from an io.Reader.
+// Combined with a memory mapped file (see package mmap) this avoids any read system calls and copies of the input.
+func ParseAllBytes(b []byte, opts ParseAllOptions) <-chan RecordResult {
+	return parseAllBytes(b, opts, nil)
+}
+
+// parseAllBytes implements ParseAllBytes, closing finished like parseAllReader.
+func parseAllBytes(b []byte, opts ParseAllOptions, finished chan struct{}) <-chan RecordResult {
+	return parseAll(opts, func(size int) ([]byte, error) {
+		if len(b) == 0 {
+			return nil, io.EOF
+		}
+		if len(b) < size {
+			data := b
+			b = nil
+			return data, io.ErrUnexpectedEOF
+		}
+		data := b[:size]
+		b = b[size:]
+		return data, nil
+	}, nil, finished)
+}
+
+// ParseAllContext works like ParseAll, but also stops parsing when ctx is done, after which the results channel is
+// closed. The caller can use ctx.Err() to tell whether all records were parsed. When opts.Cancel is set too, parsing
+// stops when either is done.
+func ParseAllContext(ctx context.Context, r io.Reader, opts ParseAllOptions) <-chan RecordResult {
+	finished := make(chan struct{})
+	opts.Cancel = mergeDone(ctx.Done(), opts.Cancel, finished)
+	return parseAllReader(r, opts, finished)
+}
+
+// ParseAllBytesContext works like ParseAllBytes, but also stops parsing when ctx is done, like ParseAllContext.
+func ParseAllBytesContext(ctx context.Context, b []byte, opts ParseAllOptions) <-chan RecordResult {
+	finished := make(chan struct{})
+	opts.Cancel = mergeDone(ctx.Done(), opts.Cancel, finished)
+	return parseAllBytes(b, opts, finished)
+}
+
+// mergeDone returns a channel that is closed when either a or b is closed. Either may be nil, in which case the other
+// is returned as is. Otherwise a goroutine waits for either of them, which also stops when finished is closed so it
+// does not outlive parsing when neither a nor b is ever closed.
+func mergeDone(a, b <-chan struct{}, finished <-chan struct{}) <-chan struct{} {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	merged := make(chan struct{})
+	go func() {
+		defer close(merged)
+		select {
+		case <-a:
+		case <-b:
+		case <-finished:
+		}
+	}()
+	return merged
+}
+
+// parseAll parses the batches of records returned by next. When release is not nil, it is called with a batch's data
+// once no results refer to it anymore, so the buffer can be reused. When finished is not nil, it is closed after the
+// results channel is closed.
+func parseAll(opts ParseAllOptions, next func(size int) ([]byte, error), release func([]byte), finished chan struct{}) <-chan RecordResult {
+	recordSize := recordSizeOrDefault(opts.RecordSize)
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	jobs := make(chan parseAllJob)
+	queue := make(chan chan []RecordResult, workers*2)
+	out := make(chan RecordResult, parseAllBatchSize)
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			for job := range jobs {
+				results := parseBatch(job, recordSize, opts)
+				if release != nil && !referencesData(results, opts.Parse) {
+					release(job.data)
+				}
+				job.results <- results
+			}
+		}()
+	}
+
+	go readBatches(next, recordSize, jobs, queue, opts.Cancel)
+
+	go func() {
+		if finished != nil {
+			defer close(finished)
+		}
+		defer close(out)
+		for results := range queue {
+			var batch []RecordResult
+			select {
+			case batch = <-results:
+			case <-opts.Cancel:
+				return
+			}
+			for _, result := range batch {
+				select {
+				case out <- result:
+				case <-opts.Cancel:
+					return
+				}
+			}
+		}
+	}()
+
+	return out
+}
+
+// readBatches obtains batches of records using next and hands them to the workers. Each batch's results channel is
+// also put on the queue so the results can be emitted in the original order. The next function follows the semantics
+// of io.ReadFull: it returns io.EOF when no data is left and io.ErrUnexpectedEOF when less data than requested is left.
+func readBatches(next func(size int) ([]byte, error), recordSize int, jobs chan<- parseAllJob, queue chan<- chan []RecordResult, cancel <-chan struct{}) {
+	defer close(queue)
+	defer close(jobs)
+
+	enqueue := func(results chan []RecordResult) bool {
+		select {
+		case queue <- results:
+			return true
+		case <-cancel:
+			return false
+		}
+	}
+
+	index := 0
+	offset := int64(0)
+	for {
+		data, err := next(parseAllBatchSize * recordSize)
+		n := len(data)
+		complete := n / recordSize
+		if complete > 0 {
+			job := parseAllJob{index: index, offset: offset, data: data[:complete*recordSize], results: make(chan []RecordResult, 1)}
+			if !enqueue(job.results) {
+				return
+			}
+			select {
+			case jobs <- job:
+			case <-cancel:
+				return
+			}
+			index += complete
+			offset += int64(complete * recordSize)
+		}
+
+		if err == nil {
+			continue
+		}
+		if err == io.EOF || (err == io.ErrUnexpectedEOF && n%recordSize == 0) {
+			return
+		}
+		if err == io.ErrUnexpectedEOF {
+			err = fmt.Errorf("incomplete record of %d bytes (expected %d)", n%recordSize, recordSize)
+		} else {
+			err = &ReadError{Err: err}
+		}
+		results := make(chan []RecordResult, 1)
+		results <- []RecordResult{{Index: index, Offset: offset, Err: err}}
+		enqueue(results)
+		return
+	}
+}
+
+func recordSizeOrDefault(recordSize int) int {
+	if recordSize <= 0 {
+		return defaultParseAllRecordSize
+	}
+	return recordSize
+}
+
+// referencesData checks if any of the results may refer to the data they were parsed from, which is the case for
+// successfully parsed records when parsing without copying.
+func referencesData(results []RecordResult, opts ParseOptions) bool {
+	if !opts.ZeroCopy {
+		return false
+	}
+	for _, result := range results {
+		if result.Err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func parseBatch(job parseAllJob, recordSize int, opts ParseAllOptions) []RecordResult {
+	count := len(job.data) / recordSize
+	results := make([]RecordResult, 0, count)
+	for i := 0; i < count; i++ {
+		b := job.data[i*recordSize : (i+1)*recordSize]
+		if opts.SkipEmpty && !bytes.HasPrefix(b, fileSignature) {
+			continue
+		}
+		parseOpts := opts.Parse
+		if parseOpts.Logger != nil {
+			parseOpts.Logger = prefixLogger{l: parseOpts.Logger, prefix: fmt.Sprintf("record %d: ", job.index+i)}
+		}
+		record, err := ParseRecordWithOptions(b, parseOpts)
+		if err == nil && record.LegacyHeader {
+			record.FileReference.RecordNumber = uint64(job.index + i)
+			parseOpts.logf("using index %d as record number", job.index+i)
+		}
+		results = append(results, RecordResult{
+			Index:  job.index + i,
+			Offset: job.offset + int64(i*recordSize),
+			Record: record,
+			Err:    err,
+		})
+	}
+	if opts.Metrics != nil {
+		errors := 0
+		for _, result := range results {
+			if result.Err != nil {
+				errors++
+			}
+		}
+		if parsed := len(results) - errors; parsed > 0 {
+			opts.Metrics.Add(metrics.RecordsParsed, int64(parsed))
+		}
+		if errors > 0 {
+			opts.Metrics.Add(metrics.ParseErrors, int64(errors))
+		}
+	}
+	return results
+}
@@ -0,0 +1,178 @@
+package mft_test
+
+import (
+	"bytes"
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/metrics"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/mfttest"
+)
+
+func TestParseAll(t *testing.T) {
+	record := mfttest.SampleMftRecord()
+	input := make([]byte, 0)
+	for i := 0; i < 200; i++ {
+		if i%50 == 7 {
+			input = append(input, make([]byte, len(record))...)
+		} else {
+			input = append(input, record...)
+		}
+	}
+
+	results := collect(mft.ParseAll(bytes.NewReader(input), mft.ParseAllOptions{Workers: 4}))
+	require.Len(t, results, 200)
+	for i, result := range results {
+		assert.Equal(t, i, result.Index)
+		assert.Equal(t, int64(i*1024), result.Offset)
+		if i%50 == 7 {
+			assert.NotNil(t, result.Err, "record %d", i)
+		} else {
+			require.Nilf(t, result.Err, "record %d: %v", i, result.Err)
+			assert.Equal(t, uint32(480), result.Record.ActualSize)
+		}
+	}
+}
+
+func TestParseAll_Metrics(t *testing.T) {
+	record := mfttest.SampleMftRecord()
+	input := make([]byte, 0)
+	for i := 0; i < 100; i++ {
+		if i%10 == 3 {
+			input = append(input, make([]byte, len(record))...)
+		} else {
+			input = append(input, record...)
+		}
+	}
+
+	c := &metrics.Counters{}
+	collect(mft.ParseAll(bytes.NewReader(input), mft.ParseAllOptions{Workers: 4, Metrics: c}))
+	assert.Equal(t, int64(90), c.Get(metrics.RecordsParsed))
+	assert.Equal(t, int64(10), c.Get(metrics.ParseErrors))
+}
+
+func TestParseAll_SkipEmpty(t *testing.T) {
+	record := mfttest.SampleMftRecord()
+	input := append(append(append([]byte{}, record...), make([]byte, 1024)...), record...)
+
+	results := collect(mft.ParseAll(bytes.NewReader(input), mft.ParseAllOptions{SkipEmpty: true}))
+	require.Len(t, results, 2)
+	assert.Equal(t, 0, results[0].Index)
+	assert.Equal(t, 2, results[1].Index)
+	assert.Equal(t, int64(2048), results[1].Offset)
+}
+
+func TestParseAll_IncompleteRecord(t *testing.T) {
+	record := mfttest.SampleMftRecord()
+	input := append(append([]byte{}, record...), record[:100]...)
+
+	results := collect(mft.ParseAll(bytes.NewReader(input), mft.ParseAllOptions{}))
+	require.Len(t, results, 2)
+	assert.Nil(t, results[0].Err)
+	assert.Equal(t, 1, results[1].Index)
+	assert.EqualError(t, results[1].Err, "incomplete record of 100 bytes (expected 1024)")
+}
+
+func TestParseAll_Cancel(t *testing.T) {
+	input := bytes.Repeat(mfttest.SampleMftRecord(), 1000)
+	cancel := make(chan struct{})
+	results := mft.ParseAll(bytes.NewReader(input), mft.ParseAllOptions{Cancel: cancel})
+	<-results
+	close(cancel)
+	count := 1
+	for range results {
+		count++
+	}
+	assert.Less(t, count, 1000)
+}
+
+func TestParseAll_LegacyHeader(t *testing.T) {
+	input := append(make([]byte, 1024), legacyRecord([]byte{1})...)
+	results := collect(mft.ParseAll(bytes.NewReader(input), mft.ParseAllOptions{SkipEmpty: true}))
+	require.Len(t, results, 1)
+	require.Nil(t, results[0].Err)
+	assert.True(t, results[0].Record.LegacyHeader)
+	assert.Equal(t, uint64(1), results[0].Record.FileReference.RecordNumber)
+}
+
+func TestParseAllBytes(t *testing.T) {
+	record := mfttest.SampleMftRecord()
+	input := append(bytes.Repeat(record, 150), record[:10]...)
+
+	results := collect(mft.ParseAllBytes(input, mft.ParseAllOptions{Workers: 3}))
+	require.Len(t, results, 151)
+	for i, result := range results[:150] {
+		require.Nilf(t, result.Err, "record %d: %v", i, result.Err)
+		assert.Equal(t, i, result.Index)
+	}
+	assert.EqualError(t, results[150].Err, "incomplete record of 10 bytes (expected 1024)")
+}
+
+func collect(c <-chan mft.RecordResult) []mft.RecordResult {
+	results := make([]mft.RecordResult, 0)
+	for result := range c {
+		results = append(results, result)
+	}
+	return results
+}
+
+func BenchmarkParseAll_Sparse(b *testing.B) {
+	// An MFT with mostly unused records, where batches without any records can be reused
+	record := mfttest.SampleMftRecord()
+	input := make([]byte, 0)
+	for i := 0; i < 1024; i++ {
+		if i%256 == 0 {
+			input = append(input, record...)
+		} else {
+			input = append(input, make([]byte, len(record))...)
+		}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for result := range mft.ParseAll(bytes.NewReader(input), mft.ParseAllOptions{SkipEmpty: true}) {
+			if result.Err != nil {
+				b.Fatalf("unable to parse record %d: %v", result.Index, result.Err)
+			}
+		}
+	}
+}
+
+func TestParseAllContext(t *testing.T) {
+	input := bytes.Repeat(mfttest.SampleMftRecord(), 1000)
+	ctx, cancel := context.WithCancel(context.Background())
+	results := mft.ParseAllContext(ctx, bytes.NewReader(input), mft.ParseAllOptions{})
+	<-results
+	cancel()
+	count := 1
+	for range results {
+		count++
+	}
+	assert.Less(t, count, 1000)
+	assert.Equal(t, context.Canceled, ctx.Err())
+
+	all := collect(mft.ParseAllBytesContext(context.Background(), input, mft.ParseAllOptions{}))
+	assert.Len(t, all, 1000)
+}
+
+func TestParseAllContext_Finished(t *testing.T) {
+	input := bytes.Repeat(mfttest.SampleMftRecord(), 10)
+	// Neither the context nor Cancel is closed while parsing
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	before := runtime.NumGoroutine()
+	for i := 0; i < 10; i++ {
+		results := mft.ParseAllBytesContext(ctx, input, mft.ParseAllOptions{Cancel: make(chan struct{})})
+		assert.Len(t, collect(results), 10)
+	}
+	for i := 0; i < 100 && runtime.NumGoroutine() > before; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine(), before)
+}
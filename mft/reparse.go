@@ -0,0 +1,273 @@
+package mft
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/t9t/gomft/binutil"
+	"github.com/t9t/gomft/utf16"
+)
+
+// ReparseTag identifies the type of a reparse point, and so the file system filter which handles it and the format of
+// its data.
+type ReparseTag uint32
+
+// Known reparse tags, as defined in the Windows Driver Kit (ntifs.h). The Cloud Files tags of OneDrive and other sync
+// engines exist in 16 variants, ReparseTagCloud and ReparseTagCloud1 up to ReparseTagCloudF, which only differ in bits
+// 12 to 15; use ReparseTag.IsCloud() to check for any of them.
+const (
+	ReparseTagMountPoint        ReparseTag = 0xA0000003
+	ReparseTagHsm               ReparseTag = 0xC0000004
+	ReparseTagDriveExtender     ReparseTag = 0x80000005
+	ReparseTagHsm2              ReparseTag = 0x80000006
+	ReparseTagSis               ReparseTag = 0x80000007
+	ReparseTagWim               ReparseTag = 0x80000008
+	ReparseTagCsv               ReparseTag = 0x80000009
+	ReparseTagDfs               ReparseTag = 0x8000000A
+	ReparseTagFilterManager     ReparseTag = 0x8000000B
+	ReparseTagSymlink           ReparseTag = 0xA000000C
+	ReparseTagIisCache          ReparseTag = 0xA0000010
+	ReparseTagDfsr              ReparseTag = 0x80000012
+	ReparseTagDedup             ReparseTag = 0x80000013
+	ReparseTagAppxStream        ReparseTag = 0xC0000014
+	ReparseTagNfs               ReparseTag = 0x80000014
+	ReparseTagFilePlaceholder   ReparseTag = 0x80000015
+	ReparseTagDfm               ReparseTag = 0x80000016
+	ReparseTagWof               ReparseTag = 0x80000017
+	ReparseTagWci               ReparseTag = 0x80000018
+	ReparseTagWci1              ReparseTag = 0x90001018
+	ReparseTagGlobalReparse     ReparseTag = 0xA0000019
+	ReparseTagCloud             ReparseTag = 0x9000001A
+	ReparseTagAppExecLink       ReparseTag = 0x8000001B
+	ReparseTagProjFs            ReparseTag = 0x9000001C
+	ReparseTagLxSymlink         ReparseTag = 0xA000001D
+	ReparseTagStorageSync       ReparseTag = 0x8000001E
+	ReparseTagWciTombstone      ReparseTag = 0xA000001F
+	ReparseTagUnhandled         ReparseTag = 0x80000020
+	ReparseTagOneDrive          ReparseTag = 0x80000021
+	ReparseTagProjFsTombstone   ReparseTag = 0xA0000022
+	ReparseTagAfUnix            ReparseTag = 0x80000023
+	ReparseTagLxFifo            ReparseTag = 0x80000024
+	ReparseTagLxChr             ReparseTag = 0x80000025
+	ReparseTagLxBlk             ReparseTag = 0x80000026
+	ReparseTagStorageSyncFolder ReparseTag = 0x90000027
+	ReparseTagWciLink           ReparseTag = 0xA0000027
+	ReparseTagWciLink1          ReparseTag = 0xA0001027
+	ReparseTagDatalessCim       ReparseTag = 0xA0000028
+
+	ReparseTagCloud1 ReparseTag = 0x9000101A
+	ReparseTagCloud2 ReparseTag = 0x9000201A
+	ReparseTagCloud3 ReparseTag = 0x9000301A
+	ReparseTagCloud4 ReparseTag = 0x9000401A
+	ReparseTagCloud5 ReparseTag = 0x9000501A
+	ReparseTagCloud6 ReparseTag = 0x9000601A
+	ReparseTagCloud7 ReparseTag = 0x9000701A
+	ReparseTagCloud8 ReparseTag = 0x9000801A
+	ReparseTagCloud9 ReparseTag = 0x9000901A
+	ReparseTagCloudA ReparseTag = 0x9000A01A
+	ReparseTagCloudB ReparseTag = 0x9000B01A
+	ReparseTagCloudC ReparseTag = 0x9000C01A
+	ReparseTagCloudD ReparseTag = 0x9000D01A
+	ReparseTagCloudE ReparseTag = 0x9000E01A
+	ReparseTagCloudF ReparseTag = 0x9000F01A
+)
+
+// Bits of a ReparseTag with a special meaning.
+const (
+	reparseTagMicrosoft     = 0x80000000
+	reparseTagNameSurrogate = 0x20000000
+	reparseTagDirectory     = 0x10000000
+	reparseTagCloudMask     = 0x0000F000
+)
+
+// reparseTagNames contains the names of the known reparse tags, except for the Cloud Files variants.
+var reparseTagNames = map[ReparseTag]string{
+	ReparseTagMountPoint:        "MountPoint",
+	ReparseTagHsm:               "Hsm",
+	ReparseTagDriveExtender:     "DriveExtender",
+	ReparseTagHsm2:              "Hsm2",
+	ReparseTagSis:               "Sis",
+	ReparseTagWim:               "Wim",
+	ReparseTagCsv:               "Csv",
+	ReparseTagDfs:               "Dfs",
+	ReparseTagFilterManager:     "FilterManager",
+	ReparseTagSymlink:           "Symlink",
+	ReparseTagIisCache:          "IisCache",
+	ReparseTagDfsr:              "Dfsr",
+	ReparseTagDedup:             "Dedup",
+	ReparseTagAppxStream:        "AppxStream",
+	ReparseTagNfs:               "Nfs",
+	ReparseTagFilePlaceholder:   "FilePlaceholder",
+	ReparseTagDfm:               "Dfm",
+	ReparseTagWof:               "Wof",
+	ReparseTagWci:               "Wci",
+	ReparseTagWci1:              "Wci1",
+	ReparseTagGlobalReparse:     "GlobalReparse",
+	ReparseTagCloud:             "Cloud",
+	ReparseTagAppExecLink:       "AppExecLink",
+	ReparseTagProjFs:            "ProjFs",
+	ReparseTagLxSymlink:         "LxSymlink",
+	ReparseTagStorageSync:       "StorageSync",
+	ReparseTagWciTombstone:      "WciTombstone",
+	ReparseTagUnhandled:         "Unhandled",
+	ReparseTagOneDrive:          "OneDrive",
+	ReparseTagProjFsTombstone:   "ProjFsTombstone",
+	ReparseTagAfUnix:            "AfUnix",
+	ReparseTagLxFifo:            "LxFifo",
+	ReparseTagLxChr:             "LxChr",
+	ReparseTagLxBlk:             "LxBlk",
+	ReparseTagStorageSyncFolder: "StorageSyncFolder",
+	ReparseTagWciLink:           "WciLink",
+	ReparseTagWciLink1:          "WciLink1",
+	ReparseTagDatalessCim:       "DatalessCim",
+}
+
+// Name returns a string representation of the reparse tag, for example "Symlink" or "Wof". All variants of the Cloud
+// Files tag are named "Cloud". For any reparse tag which is unknown, Name will return "unknown".
+func (t ReparseTag) Name() string {
+	if t.IsCloud() {
+		return "Cloud"
+	}
+	if name, ok := reparseTagNames[t]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// IsMicrosoft returns true if the reparse tag is owned by Microsoft. Reparse points with other tags contain a GUID
+// identifying their owner.
+func (t ReparseTag) IsMicrosoft() bool {
+	return t&reparseTagMicrosoft != 0
+}
+
+// IsNameSurrogate returns true if the reparse point represents another named entity in the file system, such as a
+// symbolic link or mount point, as opposed to for example a file whose data is stored elsewhere.
+func (t ReparseTag) IsNameSurrogate() bool {
+	return t&reparseTagNameSurrogate != 0
+}
+
+// IsDirectory returns true if the reparse tag is allowed on directories with children.
+func (t ReparseTag) IsDirectory() bool {
+	return t&reparseTagDirectory != 0
+}
+
+// IsCloud returns true if the reparse tag is any of the Cloud Files tags, ReparseTagCloud to ReparseTagCloudF.
+func (t ReparseTag) IsCloud() bool {
+	return t&^reparseTagCloudMask == ReparseTagCloud
+}
+
+// symlinkFlagRelative is set in the flags of a symbolic link reparse point when its target is a relative path.
+const symlinkFlagRelative = 0x00000001
+
+// ReparsePoint represents the data of a $REPARSE_POINT attribute. The GUID identifies the owner of a reparse point whose
+// tag is not a Microsoft tag; it is nil for Microsoft tags. The Data is specific to the tag.
+type ReparsePoint struct {
+	Tag  ReparseTag
+	GUID []byte
+	Data []byte
+}
+
+// ParseReparsePoint parses the data of a $REPARSE_POINT attribute's data (type AttributeTypeReparsePoint) into
+// ReparsePoint. The Data of the result aliases the input.
+func ParseReparsePoint(b []byte) (ReparsePoint, error) {
+	if len(b) < 8 {
+		return ReparsePoint{}, fmt.Errorf("expected at least %d bytes but got %d", 8, len(b))
+	}
+	r := binutil.NewLittleEndianReader(b)
+	tag := ReparseTag(r.Uint32(0x00))
+	dataLength := int(r.Uint16(0x04))
+	dataOffset := 8
+	var guid []byte
+	if !tag.IsMicrosoft() {
+		dataOffset += 16
+		if len(b) < dataOffset {
+			return ReparsePoint{}, fmt.Errorf("expected at least %d bytes but got %d", dataOffset, len(b))
+		}
+		guid = r.Read(0x08, 16)
+	}
+	if len(b) < dataOffset+dataLength {
+		return ReparsePoint{}, fmt.Errorf("expected at least %d bytes but got %d", dataOffset+dataLength, len(b))
+	}
+	return ReparsePoint{Tag: tag, GUID: guid, Data: r.Read(dataOffset, dataLength)}, nil
+}
+
+// LinkTarget represents the target of a symbolic link or mount point (also known as junction). The SubstituteName is
+// the target as used by Windows, which is usually a path in the NT object namespace such as \??\C:\Windows, while the
+// PrintName is meant for display to users. Relative is true for symbolic links whose target is relative to the
+// directory containing the link; mount points are always absolute.
+type LinkTarget struct {
+	SubstituteName string
+	PrintName      string
+	Relative       bool
+}
+
+// ParseLinkTarget parses the data of a symbolic link or mount point reparse point into a LinkTarget. An error is
+// returned for reparse points with other tags.
+func ParseLinkTarget(rp ReparsePoint) (LinkTarget, error) {
+	pathOffset := 8
+	switch rp.Tag {
+	case ReparseTagMountPoint:
+	case ReparseTagSymlink:
+		pathOffset = 12
+	default:
+		return LinkTarget{}, fmt.Errorf("reparse tag 0x%08x is not a symbolic link or mount point", uint32(rp.Tag))
+	}
+	if len(rp.Data) < pathOffset {
+		return LinkTarget{}, fmt.Errorf("expected at least %d bytes but got %d", pathOffset, len(rp.Data))
+	}
+
+	r := binutil.NewLittleEndianReader(rp.Data)
+	substituteName, err := readLinkName(rp.Data, pathOffset, int(r.Uint16(0x00)), int(r.Uint16(0x02)))
+	if err != nil {
+		return LinkTarget{}, fmt.Errorf("unable to read substitute name: %v", err)
+	}
+	printName, err := readLinkName(rp.Data, pathOffset, int(r.Uint16(0x04)), int(r.Uint16(0x06)))
+	if err != nil {
+		return LinkTarget{}, fmt.Errorf("unable to read print name: %v", err)
+	}
+	relative := rp.Tag == ReparseTagSymlink && r.Uint32(0x08)&symlinkFlagRelative != 0
+	return LinkTarget{SubstituteName: substituteName, PrintName: printName, Relative: relative}, nil
+}
+
+func readLinkName(b []byte, pathOffset, offset, length int) (string, error) {
+	start := pathOffset + offset
+	if length%2 != 0 || start+length > len(b) {
+		return "", fmt.Errorf("name at offset %d with length %d does not fit in %d bytes", offset, length, len(b)-pathOffset)
+	}
+	return utf16.DecodeString(b[start:start+length], binary.LittleEndian), nil
+}
+
+// Target returns the target of the link as a Win32 path, by normalizing the SubstituteName using NormalizeNtPath. The
+// target of a relative symbolic link is returned as is.
+func (t LinkTarget) Target() string {
+	if t.Relative {
+		return t.SubstituteName
+	}
+	return NormalizeNtPath(t.SubstituteName)
+}
+
+// ntPathPrefixes are the prefixes of NT object namespace paths which refer to the DOS device namespace, in which drive
+// letters, volume names and UNC shares can be found.
+var ntPathPrefixes = []string{`\??\`, `\\?\`, `\DosDevices\`, `\GLOBAL??\`}
+
+// NormalizeNtPath converts a path in the NT object namespace, as found in the SubstituteName of a LinkTarget, to a
+// Win32 path. For example \??\C:\Windows becomes C:\Windows, \??\UNC\server\share becomes \\server\share and
+// \??\Volume{GUID}\Windows becomes \\?\Volume{GUID}\Windows (volumes without a drive letter can only be accessed
+// through such a path). Paths without a known prefix are returned unchanged.
+func NormalizeNtPath(p string) string {
+	for _, prefix := range ntPathPrefixes {
+		if len(p) < len(prefix) || !strings.EqualFold(p[:len(prefix)], prefix) {
+			continue
+		}
+		rest := p[len(prefix):]
+		switch {
+		case len(rest) >= 4 && strings.EqualFold(rest[:4], `UNC\`):
+			return `\\` + rest[4:]
+		case len(rest) >= 7 && strings.EqualFold(rest[:7], "Volume{"):
+			return `\\?\` + rest
+		}
+		return rest
+	}
+	return p
+}
@@ -0,0 +1,147 @@
+package mft_test
+
+import (
+	"encoding/binary"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/mft"
+)
+
+func TestReparseTag(t *testing.T) {
+	tests := []struct {
+		tag           mft.ReparseTag
+		name          string
+		microsoft     bool
+		nameSurrogate bool
+		directory     bool
+	}{
+		{mft.ReparseTagSymlink, "Symlink", true, true, false},
+		{mft.ReparseTagMountPoint, "MountPoint", true, true, false},
+		{mft.ReparseTagWof, "Wof", true, false, false},
+		{mft.ReparseTagAppExecLink, "AppExecLink", true, false, false},
+		{mft.ReparseTagCloud, "Cloud", true, false, true},
+		{mft.ReparseTagCloud7, "Cloud", true, false, true},
+		{mft.ReparseTagCloudF, "Cloud", true, false, true},
+		{mft.ReparseTagWci1, "Wci1", true, false, true},
+		{mft.ReparseTagProjFs, "ProjFs", true, false, true},
+		{mft.ReparseTag(0x0000001d), "unknown", false, false, false},
+		{mft.ReparseTag(0x9000101B), "unknown", true, false, true},
+	}
+	for _, test := range tests {
+		assert.Equalf(t, test.name, test.tag.Name(), "tag 0x%08x", uint32(test.tag))
+		assert.Equalf(t, test.microsoft, test.tag.IsMicrosoft(), "tag 0x%08x", uint32(test.tag))
+		assert.Equalf(t, test.nameSurrogate, test.tag.IsNameSurrogate(), "tag 0x%08x", uint32(test.tag))
+		assert.Equalf(t, test.directory, test.tag.IsDirectory(), "tag 0x%08x", uint32(test.tag))
+	}
+	assert.True(t, mft.ReparseTagCloudA.IsCloud())
+	assert.False(t, mft.ReparseTagProjFs.IsCloud())
+}
+
+func TestParseReparsePoint(t *testing.T) {
+	rp, err := mft.ParseReparsePoint(decodeHex(t, "0300008004000000deadbeefff"))
+	require.Nilf(t, err, "could not parse reparse point: %v", err)
+	assert.Equal(t, mft.ReparsePoint{Tag: 0x80000003, Data: []byte{0xde, 0xad, 0xbe, 0xef}}, rp)
+
+	rp, err = mft.ParseReparsePoint(decodeHex(t, "1d00000002000000000102030405060708090a0b0c0d0e0fcafe"))
+	require.Nilf(t, err, "could not parse reparse point: %v", err)
+	expected := mft.ReparsePoint{
+		Tag:  0x0000001d,
+		GUID: decodeHex(t, "000102030405060708090a0b0c0d0e0f"),
+		Data: []byte{0xca, 0xfe},
+	}
+	assert.Equal(t, expected, rp)
+}
+
+func TestParseReparsePoint_Invalid(t *testing.T) {
+	_, err := mft.ParseReparsePoint(decodeHex(t, "03000080"))
+	assert.EqualError(t, err, "expected at least 8 bytes but got 4")
+
+	_, err = mft.ParseReparsePoint(decodeHex(t, "0300008004000000dead"))
+	assert.EqualError(t, err, "expected at least 12 bytes but got 10")
+
+	_, err = mft.ParseReparsePoint(decodeHex(t, "1d000000000000000001"))
+	assert.EqualError(t, err, "expected at least 24 bytes but got 10")
+}
+
+func TestParseLinkTarget(t *testing.T) {
+	target, err := mft.ParseLinkTarget(linkReparsePoint(mft.ReparseTagSymlink, `\??\C:\Windows\System32`, `C:\Windows\System32`, false))
+	require.Nilf(t, err, "could not parse link target: %v", err)
+	assert.Equal(t, mft.LinkTarget{SubstituteName: `\??\C:\Windows\System32`, PrintName: `C:\Windows\System32`}, target)
+	assert.Equal(t, `C:\Windows\System32`, target.Target())
+
+	target, err = mft.ParseLinkTarget(linkReparsePoint(mft.ReparseTagSymlink, `..\shared`, `..\shared`, true))
+	require.Nilf(t, err, "could not parse link target: %v", err)
+	assert.Equal(t, mft.LinkTarget{SubstituteName: `..\shared`, PrintName: `..\shared`, Relative: true}, target)
+	assert.Equal(t, `..\shared`, target.Target())
+
+	target, err = mft.ParseLinkTarget(linkReparsePoint(mft.ReparseTagMountPoint, `\??\Volume{0b8e5a3c-1a2b-4c5d-8e9f-001122334455}\`, "", false))
+	require.Nilf(t, err, "could not parse link target: %v", err)
+	assert.Equal(t, `\\?\Volume{0b8e5a3c-1a2b-4c5d-8e9f-001122334455}\`, target.Target())
+	assert.False(t, target.Relative)
+}
+
+func TestParseLinkTarget_Invalid(t *testing.T) {
+	_, err := mft.ParseLinkTarget(mft.ReparsePoint{Tag: 0x80000013})
+	assert.EqualError(t, err, "reparse tag 0x80000013 is not a symbolic link or mount point")
+
+	_, err = mft.ParseLinkTarget(mft.ReparsePoint{Tag: mft.ReparseTagSymlink, Data: make([]byte, 8)})
+	assert.EqualError(t, err, "expected at least 12 bytes but got 8")
+
+	rp := linkReparsePoint(mft.ReparseTagMountPoint, `\??\C:\`, `C:\`, false)
+	rp.Data = rp.Data[:len(rp.Data)-2]
+	_, err = mft.ParseLinkTarget(rp)
+	assert.EqualError(t, err, "unable to read print name: name at offset 14 with length 6 does not fit in 18 bytes")
+}
+
+func TestNormalizeNtPath(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`\??\C:\Windows`, `C:\Windows`},
+		{`\DosDevices\D:\data`, `D:\data`},
+		{`\GLOBAL??\E:\`, `E:\`},
+		{`\\?\C:\Users`, `C:\Users`},
+		{`\??\UNC\server\share\dir`, `\\server\share\dir`},
+		{`\??\unc\server\share`, `\\server\share`},
+		{`\??\Volume{0b8e5a3c-1a2b-4c5d-8e9f-001122334455}\dir`, `\\?\Volume{0b8e5a3c-1a2b-4c5d-8e9f-001122334455}\dir`},
+		{`\Device\HarddiskVolume2\dir`, `\Device\HarddiskVolume2\dir`},
+		{`relative\path`, `relative\path`},
+		{``, ``},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.expected, mft.NormalizeNtPath(test.input), test.input)
+	}
+}
+
+// linkReparsePoint creates a symbolic link or mount point ReparsePoint, with the print name following the substitute
+// name in the path buffer.
+func linkReparsePoint(tag mft.ReparseTag, substituteName, printName string, relative bool) mft.ReparsePoint {
+	substitute, print := encodeUtf16(substituteName), encodeUtf16(printName)
+	pathOffset := 8
+	if tag == mft.ReparseTagSymlink {
+		pathOffset = 12
+	}
+	data := make([]byte, pathOffset, pathOffset+len(substitute)+len(print))
+	binary.LittleEndian.PutUint16(data[0x00:], 0)
+	binary.LittleEndian.PutUint16(data[0x02:], uint16(len(substitute)))
+	binary.LittleEndian.PutUint16(data[0x04:], uint16(len(substitute)))
+	binary.LittleEndian.PutUint16(data[0x06:], uint16(len(print)))
+	if relative {
+		binary.LittleEndian.PutUint32(data[0x08:], 1)
+	}
+	data = append(append(data, substitute...), print...)
+	return mft.ReparsePoint{Tag: tag, Data: data}
+}
+
+func encodeUtf16(s string) []byte {
+	encoded := utf16.Encode([]rune(s))
+	b := make([]byte, len(encoded)*2)
+	for i, c := range encoded {
+		binary.LittleEndian.PutUint16(b[i*2:], c)
+	}
+	return b
+}
@@ -0,0 +1,474 @@
+package mft
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/t9t/gomft/binutil"
+)
+
+// SecureRecordNumber is the number of the MFT record of the $Secure metafile, which contains the security descriptors
+// of all files in its $SDS stream.
+const SecureRecordNumber = 9
+
+// SecureDescriptorStreamName is the name of the $DATA attribute of $Secure containing the security descriptors.
+const SecureDescriptorStreamName = "$SDS"
+
+// sdsBlockSize is the size of the blocks of the $SDS stream; each block is followed by a mirror copy of it.
+const sdsBlockSize = 0x40000
+
+// SecurityDescriptorControl represents the control flags of a SecurityDescriptor.
+type SecurityDescriptorControl uint16
+
+// Bit values for the SecurityDescriptorControl.
+const (
+	SecurityDescriptorControlOwnerDefaulted     SecurityDescriptorControl = 0x0001
+	SecurityDescriptorControlGroupDefaulted     SecurityDescriptorControl = 0x0002
+	SecurityDescriptorControlDaclPresent        SecurityDescriptorControl = 0x0004
+	SecurityDescriptorControlDaclDefaulted      SecurityDescriptorControl = 0x0008
+	SecurityDescriptorControlSaclPresent        SecurityDescriptorControl = 0x0010
+	SecurityDescriptorControlSaclDefaulted      SecurityDescriptorControl = 0x0020
+	SecurityDescriptorControlDaclAutoInheritReq SecurityDescriptorControl = 0x0100
+	SecurityDescriptorControlSaclAutoInheritReq SecurityDescriptorControl = 0x0200
+	SecurityDescriptorControlDaclAutoInherited  SecurityDescriptorControl = 0x0400
+	SecurityDescriptorControlSaclAutoInherited  SecurityDescriptorControl = 0x0800
+	SecurityDescriptorControlDaclProtected      SecurityDescriptorControl = 0x1000
+	SecurityDescriptorControlSaclProtected      SecurityDescriptorControl = 0x2000
+	SecurityDescriptorControlSelfRelative       SecurityDescriptorControl = 0x8000
+)
+
+// Is checks if this SecurityDescriptorControl's bit mask contains the specified flag.
+func (c *SecurityDescriptorControl) Is(f SecurityDescriptorControl) bool {
+	return *c&f == f
+}
+
+// AceType indicates the kind of an ACE.
+type AceType byte
+
+const (
+	AceTypeAccessAllowed               AceType = 0x00
+	AceTypeAccessDenied                AceType = 0x01
+	AceTypeSystemAudit                 AceType = 0x02
+	AceTypeSystemAlarm                 AceType = 0x03
+	AceTypeAccessAllowedCompound       AceType = 0x04
+	AceTypeAccessAllowedObject         AceType = 0x05
+	AceTypeAccessDeniedObject          AceType = 0x06
+	AceTypeSystemAuditObject           AceType = 0x07
+	AceTypeSystemAlarmObject           AceType = 0x08
+	AceTypeAccessAllowedCallback       AceType = 0x09
+	AceTypeAccessDeniedCallback        AceType = 0x0A
+	AceTypeAccessAllowedCallbackObject AceType = 0x0B
+	AceTypeAccessDeniedCallbackObject  AceType = 0x0C
+	AceTypeSystemAuditCallback         AceType = 0x0D
+	AceTypeSystemAlarmCallback         AceType = 0x0E
+	AceTypeSystemAuditCallbackObject   AceType = 0x0F
+	AceTypeSystemAlarmCallbackObject   AceType = 0x10
+	AceTypeSystemMandatoryLabel        AceType = 0x11
+)
+
+// isObject indicates whether ACEs of this type contain object type GUIDs before their SID.
+func (t AceType) isObject() bool {
+	switch t {
+	case AceTypeAccessAllowedObject, AceTypeAccessDeniedObject, AceTypeSystemAuditObject, AceTypeSystemAlarmObject,
+		AceTypeAccessAllowedCallbackObject, AceTypeAccessDeniedCallbackObject, AceTypeSystemAuditCallbackObject,
+		AceTypeSystemAlarmCallbackObject:
+		return true
+	}
+	return false
+}
+
+// AceFlags represents the inheritance and audit flags of an ACE.
+type AceFlags byte
+
+// Bit values for the AceFlags.
+const (
+	AceFlagsObjectInherit      AceFlags = 0x01
+	AceFlagsContainerInherit   AceFlags = 0x02
+	AceFlagsNoPropagateInherit AceFlags = 0x04
+	AceFlagsInheritOnly        AceFlags = 0x08
+	AceFlagsInherited          AceFlags = 0x10
+	AceFlagsSuccessfulAccess   AceFlags = 0x40
+	AceFlagsFailedAccess       AceFlags = 0x80
+)
+
+// Is checks if this AceFlags's bit mask contains the specified flag.
+func (f *AceFlags) Is(c AceFlags) bool {
+	return *f&c == c
+}
+
+// SecurityDescriptor represents a Windows security descriptor in self-relative form, as stored in the $SDS stream of
+// $Secure or (on NTFS versions before 3.0) in the $SECURITY_DESCRIPTOR attribute of a record. Owner, Group, Dacl and
+// Sacl are nil when they are not present. A nil Dacl while SecurityDescriptorControlDaclPresent is set is a "NULL DACL",
+// which grants full access to everyone.
+type SecurityDescriptor struct {
+	Revision byte
+	Control  SecurityDescriptorControl
+	Owner    *SID
+	Group    *SID
+	Dacl     *ACL
+	Sacl     *ACL
+}
+
+// ACL represents an access control list.
+type ACL struct {
+	Revision byte
+	Entries  []ACE
+}
+
+// ACE represents an access control entry. SID is nil for ACEs of an unknown type, for which only the header and the
+// mask are parsed. ObjectType and InheritedObjectType are only set for object ACEs containing them, formatted like
+// "bf967aba-0de6-11d0-a285-00aa003049e2".
+type ACE struct {
+	Type                AceType
+	Flags               AceFlags
+	Mask                uint32
+	ObjectType          string
+	InheritedObjectType string
+	SID                 *SID
+}
+
+// SecurityDescriptorEntry is an entry of the $SDS stream of $Secure. Files refer to it by its SecurityId, which is
+// stored in their $STANDARD_INFORMATION attribute. The Data contains the security descriptor, which can be parsed using
+// ParseSecurityDescriptor.
+type SecurityDescriptorEntry struct {
+	Hash       uint32
+	SecurityId uint32
+	Offset     uint64
+	Data       []byte
+}
+
+// ParseSecurityDescriptorStream parses the data of the $SDS stream of $Secure into its entries. The stream consists of
+// blocks of 256KiB, each followed by a mirror copy of it; only the entries in the first copy are returned. Padding and
+// space after the last entry of a block are skipped. The Data of each entry is a copy, it does not alias the input.
+func ParseSecurityDescriptorStream(b []byte) ([]SecurityDescriptorEntry, error) {
+	entries := make([]SecurityDescriptorEntry, 0)
+	position := 0
+	for position+0x14 <= len(b) {
+		if (position/sdsBlockSize)%2 == 1 {
+			position = (position/sdsBlockSize + 1) * sdsBlockSize
+			continue
+		}
+		r := binutil.NewLittleEndianReader(b[position:])
+		length := int(r.Uint32(0x10))
+		blockEnd := (position/sdsBlockSize + 1) * sdsBlockSize
+		if r.Uint32(0x04) == 0 || length < 0x14 || r.Uint64(0x08) != uint64(position) {
+			// No more entries in this block
+			position = blockEnd
+			continue
+		}
+		if position+length > len(b) || position+length > blockEnd {
+			return nil, fmt.Errorf("entry at offset %d with length %d exceeds its block", position, length)
+		}
+		entries = append(entries, SecurityDescriptorEntry{
+			Hash:       r.Uint32(0x00),
+			SecurityId: r.Uint32(0x04),
+			Offset:     r.Uint64(0x08),
+			Data:       binutil.Duplicate(b[position+0x14 : position+length]),
+		})
+		position += (length + 15) &^ 15
+	}
+	return entries, nil
+}
+
+// ParseSecurityDescriptor parses a security descriptor in self-relative form.
+func ParseSecurityDescriptor(b []byte) (SecurityDescriptor, error) {
+	if len(b) < 0x14 {
+		return SecurityDescriptor{}, fmt.Errorf("expected at least %d bytes but got %d", 0x14, len(b))
+	}
+	r := binutil.NewLittleEndianReader(b)
+	sd := SecurityDescriptor{Revision: r.Byte(0x00), Control: SecurityDescriptorControl(r.Uint16(0x02))}
+
+	var err error
+	if sd.Owner, err = parseOptionalSID(b, int(r.Uint32(0x04))); err != nil {
+		return SecurityDescriptor{}, fmt.Errorf("unable to parse owner: %v", err)
+	}
+	if sd.Group, err = parseOptionalSID(b, int(r.Uint32(0x08))); err != nil {
+		return SecurityDescriptor{}, fmt.Errorf("unable to parse group: %v", err)
+	}
+	if sd.Control.Is(SecurityDescriptorControlSaclPresent) {
+		if sd.Sacl, err = parseOptionalACL(b, int(r.Uint32(0x0C))); err != nil {
+			return SecurityDescriptor{}, fmt.Errorf("unable to parse SACL: %v", err)
+		}
+	}
+	if sd.Control.Is(SecurityDescriptorControlDaclPresent) {
+		if sd.Dacl, err = parseOptionalACL(b, int(r.Uint32(0x10))); err != nil {
+			return SecurityDescriptor{}, fmt.Errorf("unable to parse DACL: %v", err)
+		}
+	}
+	return sd, nil
+}
+
+// parseOptionalSID parses the SID at the specified offset. An offset of zero means the SID is not present.
+func parseOptionalSID(b []byte, offset int) (*SID, error) {
+	if offset == 0 {
+		return nil, nil
+	}
+	if offset < 0 || offset >= len(b) {
+		return nil, fmt.Errorf("offset %d exceeds data length %d", offset, len(b))
+	}
+	sid, _, err := ParseSID(b[offset:])
+	if err != nil {
+		return nil, err
+	}
+	return &sid, nil
+}
+
+// parseOptionalACL parses the ACL at the specified offset. An offset of zero means the ACL is not present.
+func parseOptionalACL(b []byte, offset int) (*ACL, error) {
+	if offset == 0 {
+		return nil, nil
+	}
+	if offset < 0 || offset+8 > len(b) {
+		return nil, fmt.Errorf("offset %d exceeds data length %d", offset, len(b))
+	}
+	r := binutil.NewLittleEndianReader(b[offset:])
+	size := int(r.Uint16(0x02))
+	if size < 8 || offset+size > len(b) {
+		return nil, fmt.Errorf("ACL size %d at offset %d exceeds data length %d", size, offset, len(b))
+	}
+	count := int(r.Uint16(0x04))
+	acl := &ACL{Revision: r.Byte(0x00), Entries: make([]ACE, 0, count)}
+	data := b[offset : offset+size]
+	position := 8
+	for i := 0; i < count; i++ {
+		if position+8 > len(data) {
+			return nil, fmt.Errorf("ACE %d at offset %d exceeds ACL size %d", i, position, size)
+		}
+		aceSize := int(binary.LittleEndian.Uint16(data[position+2:]))
+		if aceSize < 8 || position+aceSize > len(data) {
+			return nil, fmt.Errorf("ACE %d at offset %d with size %d exceeds ACL size %d", i, position, aceSize, size)
+		}
+		ace, err := parseACE(data[position : position+aceSize])
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse ACE %d: %v", i, err)
+		}
+		acl.Entries = append(acl.Entries, ace)
+		position += aceSize
+	}
+	return acl, nil
+}
+
+func parseACE(b []byte) (ACE, error) {
+	r := binutil.NewLittleEndianReader(b)
+	ace := ACE{Type: AceType(r.Byte(0x00)), Flags: AceFlags(r.Byte(0x01)), Mask: r.Uint32(0x04)}
+	if ace.Type > AceTypeSystemMandatoryLabel || ace.Type == AceTypeAccessAllowedCompound {
+		return ace, nil
+	}
+
+	position := 8
+	if ace.Type.isObject() {
+		if len(b) < 12 {
+			return ACE{}, fmt.Errorf("expected at least %d bytes but got %d", 12, len(b))
+		}
+		objectFlags := r.Uint32(0x08)
+		position = 12
+		for _, field := range []struct {
+			flag uint32
+			dst  *string
+		}{{0x1, &ace.ObjectType}, {0x2, &ace.InheritedObjectType}} {
+			if objectFlags&field.flag == 0 {
+				continue
+			}
+			if position+16 > len(b) {
+				return ACE{}, fmt.Errorf("object type at offset %d exceeds ACE size %d", position, len(b))
+			}
+			*field.dst = formatGUID(b[position : position+16])
+			position += 16
+		}
+	}
+	sid, _, err := ParseSID(b[position:])
+	if err != nil {
+		return ACE{}, fmt.Errorf("unable to parse SID: %v", err)
+	}
+	ace.SID = &sid
+	return ace, nil
+}
+
+// formatGUID formats a GUID in its mixed endian binary form as a string.
+func formatGUID(b []byte) string {
+	return fmt.Sprintf("%08x-%04x-%04x-%x-%x", binary.LittleEndian.Uint32(b), binary.LittleEndian.Uint16(b[4:]),
+		binary.LittleEndian.Uint16(b[6:]), b[8:10], b[10:16])
+}
+
+// sddlSIDAliases contains the SDDL aliases of well-known SIDs.
+var sddlSIDAliases = map[string]string{
+	"S-1-1-0":      "WD",
+	"S-1-3-0":      "CO",
+	"S-1-3-1":      "CG",
+	"S-1-3-4":      "OW",
+	"S-1-5-2":      "NU",
+	"S-1-5-4":      "IU",
+	"S-1-5-6":      "SU",
+	"S-1-5-7":      "AN",
+	"S-1-5-9":      "ED",
+	"S-1-5-10":     "PS",
+	"S-1-5-11":     "AU",
+	"S-1-5-12":     "RC",
+	"S-1-5-18":     "SY",
+	"S-1-5-19":     "LS",
+	"S-1-5-20":     "NS",
+	"S-1-5-32-544": "BA",
+	"S-1-5-32-545": "BU",
+	"S-1-5-32-546": "BG",
+	"S-1-5-32-547": "PU",
+	"S-1-5-32-548": "AO",
+	"S-1-5-32-549": "SO",
+	"S-1-5-32-550": "PO",
+	"S-1-5-32-551": "BO",
+	"S-1-5-32-555": "RD",
+	"S-1-15-2-1":   "AC",
+	"S-1-16-4096":  "LW",
+	"S-1-16-8192":  "ME",
+	"S-1-16-12288": "HI",
+	"S-1-16-16384": "SI",
+}
+
+// sddlAceTypes contains the SDDL strings of ACE types.
+var sddlAceTypes = map[AceType]string{
+	AceTypeAccessAllowed:               "A",
+	AceTypeAccessDenied:                "D",
+	AceTypeSystemAudit:                 "AU",
+	AceTypeSystemAlarm:                 "AL",
+	AceTypeAccessAllowedObject:         "OA",
+	AceTypeAccessDeniedObject:          "OD",
+	AceTypeSystemAuditObject:           "OU",
+	AceTypeSystemAlarmObject:           "OL",
+	AceTypeAccessAllowedCallback:       "XA",
+	AceTypeAccessDeniedCallback:        "XD",
+	AceTypeAccessAllowedCallbackObject: "ZA",
+	AceTypeSystemAuditCallback:         "XU",
+	AceTypeSystemMandatoryLabel:        "ML",
+}
+
+// sddlAceFlags contains the SDDL strings of ACE flags, in the order Windows writes them.
+var sddlAceFlags = []struct {
+	flag AceFlags
+	name string
+}{
+	{AceFlagsObjectInherit, "OI"},
+	{AceFlagsContainerInherit, "CI"},
+	{AceFlagsNoPropagateInherit, "NP"},
+	{AceFlagsInheritOnly, "IO"},
+	{AceFlagsInherited, "ID"},
+	{AceFlagsSuccessfulAccess, "SA"},
+	{AceFlagsFailedAccess, "FA"},
+}
+
+// sddlFileRights contains the SDDL aliases of complete file access masks.
+var sddlFileRights = map[uint32]string{
+	0x001F01FF: "FA",
+	0x00120089: "FR",
+	0x00120116: "FW",
+	0x001200A0: "FX",
+}
+
+// sddlRights contains the SDDL strings of single access rights, in the order Windows writes them.
+var sddlRights = []struct {
+	mask uint32
+	name string
+}{
+	{0x10000000, "GA"},
+	{0x80000000, "GR"},
+	{0x40000000, "GW"},
+	{0x20000000, "GX"},
+	{0x00020000, "RC"},
+	{0x00010000, "SD"},
+	{0x00040000, "WD"},
+	{0x00080000, "WO"},
+	{0x00000010, "RP"},
+	{0x00000020, "WP"},
+	{0x00000001, "CC"},
+	{0x00000002, "DC"},
+	{0x00000004, "LC"},
+	{0x00000008, "SW"},
+	{0x00000080, "LO"},
+	{0x00000040, "DT"},
+	{0x00000100, "CR"},
+}
+
+// SDDL returns the security descriptor in the Security Descriptor Definition Language, for example
+// "O:BAG:SYD:PAI(A;OICIID;FA;;;SY)". Well-known SIDs and access masks are written using their aliases, like Windows
+// does; other access masks are written in hexadecimal. Aliases which depend on the domain of the computer (such as DA
+// for Domain Admins) are not used, since the domain is not known.
+func (sd SecurityDescriptor) SDDL() string {
+	var sb strings.Builder
+	if sd.Owner != nil {
+		sb.WriteString("O:" + sddlSID(*sd.Owner))
+	}
+	if sd.Group != nil {
+		sb.WriteString("G:" + sddlSID(*sd.Group))
+	}
+	if sd.Control.Is(SecurityDescriptorControlDaclPresent) {
+		sb.WriteString("D:")
+		writeSDDLACL(&sb, sd.Dacl, sd.Control.Is(SecurityDescriptorControlDaclProtected),
+			sd.Control.Is(SecurityDescriptorControlDaclAutoInheritReq), sd.Control.Is(SecurityDescriptorControlDaclAutoInherited))
+	}
+	if sd.Control.Is(SecurityDescriptorControlSaclPresent) {
+		sb.WriteString("S:")
+		writeSDDLACL(&sb, sd.Sacl, sd.Control.Is(SecurityDescriptorControlSaclProtected),
+			sd.Control.Is(SecurityDescriptorControlSaclAutoInheritReq), sd.Control.Is(SecurityDescriptorControlSaclAutoInherited))
+	}
+	return sb.String()
+}
+
+func writeSDDLACL(sb *strings.Builder, acl *ACL, protected bool, autoInheritReq bool, autoInherited bool) {
+	if protected {
+		sb.WriteString("P")
+	}
+	if autoInheritReq {
+		sb.WriteString("AR")
+	}
+	if autoInherited {
+		sb.WriteString("AI")
+	}
+	if acl == nil {
+		sb.WriteString("NO_ACCESS_CONTROL")
+		return
+	}
+	for _, ace := range acl.Entries {
+		sb.WriteString("(")
+		if t, ok := sddlAceTypes[ace.Type]; ok {
+			sb.WriteString(t)
+		} else {
+			fmt.Fprintf(sb, "0x%X", byte(ace.Type))
+		}
+		sb.WriteString(";")
+		for _, f := range sddlAceFlags {
+			if ace.Flags.Is(f.flag) {
+				sb.WriteString(f.name)
+			}
+		}
+		sb.WriteString(";" + sddlMask(ace.Mask) + ";" + ace.ObjectType + ";" + ace.InheritedObjectType + ";")
+		if ace.SID != nil {
+			sb.WriteString(sddlSID(*ace.SID))
+		}
+		sb.WriteString(")")
+	}
+}
+
+func sddlSID(sid SID) string {
+	s := sid.String()
+	if alias, ok := sddlSIDAliases[s]; ok {
+		return alias
+	}
+	return s
+}
+
+func sddlMask(mask uint32) string {
+	if alias, ok := sddlFileRights[mask]; ok {
+		return alias
+	}
+	var sb strings.Builder
+	remaining := mask
+	for _, r := range sddlRights {
+		if mask&r.mask != 0 {
+			sb.WriteString(r.name)
+			remaining &^= r.mask
+		}
+	}
+	if remaining != 0 || mask == 0 {
+		return fmt.Sprintf("0x%x", mask)
+	}
+	return sb.String()
+}
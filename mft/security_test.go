@@ -0,0 +1,171 @@
+package mft_test
+
+import (
+	"encoding/binary"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/mft"
+)
+
+func sidBytes(authority byte, subAuthorities ...uint32) []byte {
+	b := make([]byte, 8+4*len(subAuthorities))
+	b[0], b[1], b[7] = 1, byte(len(subAuthorities)), authority
+	for i, a := range subAuthorities {
+		binary.LittleEndian.PutUint32(b[8+4*i:], a)
+	}
+	return b
+}
+
+func aceBytes(aceType byte, flags byte, mask uint32, sid []byte) []byte {
+	b := make([]byte, 8, 8+len(sid))
+	b[0], b[1] = aceType, flags
+	binary.LittleEndian.PutUint16(b[2:], uint16(8+len(sid)))
+	binary.LittleEndian.PutUint32(b[4:], mask)
+	return append(b, sid...)
+}
+
+func aclBytes(aces ...[]byte) []byte {
+	b := make([]byte, 8)
+	b[0] = 2
+	for _, ace := range aces {
+		b = append(b, ace...)
+	}
+	binary.LittleEndian.PutUint16(b[2:], uint16(len(b)))
+	binary.LittleEndian.PutUint16(b[4:], uint16(len(aces)))
+	return b
+}
+
+func securityDescriptorBytes(control uint16, owner []byte, group []byte, dacl []byte) []byte {
+	b := make([]byte, 0x14)
+	b[0] = 1
+	binary.LittleEndian.PutUint16(b[2:], control)
+	binary.LittleEndian.PutUint32(b[0x04:], uint32(len(b)))
+	b = append(b, owner...)
+	binary.LittleEndian.PutUint32(b[0x08:], uint32(len(b)))
+	b = append(b, group...)
+	if dacl != nil {
+		binary.LittleEndian.PutUint32(b[0x10:], uint32(len(b)))
+		b = append(b, dacl...)
+	}
+	return b
+}
+
+var (
+	sidSystem         = sidBytes(5, 18)
+	sidAdministrators = sidBytes(5, 32, 544)
+	sidUsers          = sidBytes(5, 32, 545)
+	sidUser           = sidBytes(5, 21, 1004405980, 1185630339, 682021762, 1001)
+)
+
+func testSecurityDescriptor() []byte {
+	return securityDescriptorBytes(0x9404, sidAdministrators, sidSystem, aclBytes(
+		aceBytes(0x00, 0x13, 0x001F01FF, sidSystem),
+		aceBytes(0x00, 0x13, 0x001F01FF, sidAdministrators),
+		aceBytes(0x00, 0x00, 0x001200A9, sidUsers),
+		aceBytes(0x01, 0x00, 0x00010000, sidUser),
+	))
+}
+
+func TestParseSecurityDescriptor(t *testing.T) {
+	sd, err := mft.ParseSecurityDescriptor(testSecurityDescriptor())
+	require.Nilf(t, err, "could not parse security descriptor: %v", err)
+	assert.Equal(t, byte(1), sd.Revision)
+	assert.Equal(t, mft.SecurityDescriptorControl(0x9404), sd.Control)
+	require.NotNil(t, sd.Owner)
+	assert.Equal(t, "S-1-5-32-544", sd.Owner.String())
+	require.NotNil(t, sd.Group)
+	assert.Equal(t, "S-1-5-18", sd.Group.String())
+	assert.Nil(t, sd.Sacl)
+	require.NotNil(t, sd.Dacl)
+	require.Len(t, sd.Dacl.Entries, 4)
+	ace := sd.Dacl.Entries[0]
+	assert.Equal(t, mft.AceTypeAccessAllowed, ace.Type)
+	assert.Equal(t, mft.AceFlagsObjectInherit|mft.AceFlagsContainerInherit|mft.AceFlagsInherited, ace.Flags)
+	assert.Equal(t, uint32(0x001F01FF), ace.Mask)
+	assert.Equal(t, "S-1-5-18", ace.SID.String())
+	assert.Equal(t, mft.AceTypeAccessDenied, sd.Dacl.Entries[3].Type)
+
+	assert.Equal(t, "O:BAG:SYD:PAI(A;OICIID;FA;;;SY)(A;OICIID;FA;;;BA)(A;;0x1200a9;;;BU)"+
+		"(D;;SD;;;S-1-5-21-1004405980-1185630339-682021762-1001)", sd.SDDL())
+}
+
+func TestParseSecurityDescriptorNullDacl(t *testing.T) {
+	sd, err := mft.ParseSecurityDescriptor(securityDescriptorBytes(0x8004, sidUser, sidUser, nil))
+	require.Nilf(t, err, "could not parse security descriptor: %v", err)
+	assert.Nil(t, sd.Dacl)
+	assert.True(t, sd.Control.Is(mft.SecurityDescriptorControlDaclPresent))
+	assert.Equal(t, "O:S-1-5-21-1004405980-1185630339-682021762-1001G:S-1-5-21-1004405980-1185630339-682021762-1001"+
+		"D:NO_ACCESS_CONTROL", sd.SDDL())
+}
+
+func TestParseSecurityDescriptorObjectAce(t *testing.T) {
+	guid := decodeHex(t, "ba7a96bfe60dd011a28500aa003049e2")
+	ace := aceBytes(0x05, 0x00, 0x00000030, append(append([]byte{1, 0, 0, 0}, guid...), sidUsers...))
+	sd, err := mft.ParseSecurityDescriptor(securityDescriptorBytes(0x8004, sidSystem, sidSystem, aclBytes(ace)))
+	require.Nilf(t, err, "could not parse security descriptor: %v", err)
+	assert.Equal(t, "bf967aba-0de6-11d0-a285-00aa003049e2", sd.Dacl.Entries[0].ObjectType)
+	assert.Equal(t, "", sd.Dacl.Entries[0].InheritedObjectType)
+	assert.Equal(t, "O:SYG:SYD:(OA;;RPWP;bf967aba-0de6-11d0-a285-00aa003049e2;;BU)", sd.SDDL())
+}
+
+func TestParseSecurityDescriptorInvalid(t *testing.T) {
+	_, err := mft.ParseSecurityDescriptor(make([]byte, 0x13))
+	assert.EqualError(t, err, "expected at least 20 bytes but got 19")
+
+	b := testSecurityDescriptor()
+	binary.LittleEndian.PutUint32(b[0x10:], uint32(len(b)-4))
+	_, err = mft.ParseSecurityDescriptor(b)
+	assert.EqualError(t, err, "unable to parse DACL: offset "+strconv.Itoa(len(b)-4)+" exceeds data length "+strconv.Itoa(len(b)))
+
+	b = testSecurityDescriptor()
+	dacl := int(binary.LittleEndian.Uint32(b[0x10:]))
+	binary.LittleEndian.PutUint16(b[dacl+4:], 5)
+	_, err = mft.ParseSecurityDescriptor(b)
+	assert.EqualError(t, err, "unable to parse DACL: ACE 4 at offset "+strconv.Itoa(len(b)-dacl)+" exceeds ACL size "+strconv.Itoa(len(b)-dacl))
+
+	b = testSecurityDescriptor()
+	binary.LittleEndian.PutUint32(b[0x04:], 0x1000)
+	_, err = mft.ParseSecurityDescriptor(b)
+	assert.EqualError(t, err, "unable to parse owner: offset 4096 exceeds data length "+strconv.Itoa(len(b)))
+}
+
+func sdsEntry(id uint32, offset int, sd []byte) []byte {
+	b := make([]byte, 0x14, 0x14+len(sd)+15)
+	binary.LittleEndian.PutUint32(b[0x00:], id*7)
+	binary.LittleEndian.PutUint32(b[0x04:], id)
+	binary.LittleEndian.PutUint64(b[0x08:], uint64(offset))
+	binary.LittleEndian.PutUint32(b[0x10:], uint32(0x14+len(sd)))
+	b = append(b, sd...)
+	for len(b)%16 != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+func TestParseSecurityDescriptorStream(t *testing.T) {
+	sd := testSecurityDescriptor()
+	sds := make([]byte, 0x40000*3+0x100)
+	first := sdsEntry(0x100, 0, sd)
+	copy(sds, first)
+	copy(sds[len(first):], sdsEntry(0x101, len(first), sd[:0x20]))
+	copy(sds[0x40000:], sds[:0x40000]) // mirror copy
+	copy(sds[0x80000:], sdsEntry(0x102, 0x80000, sd))
+
+	entries, err := mft.ParseSecurityDescriptorStream(sds)
+	require.Nilf(t, err, "could not parse $SDS: %v", err)
+	require.Len(t, entries, 3)
+	assert.Equal(t, mft.SecurityDescriptorEntry{Hash: 0x700, SecurityId: 0x100, Offset: 0, Data: sd}, entries[0])
+	assert.Equal(t, uint32(0x101), entries[1].SecurityId)
+	assert.Equal(t, sd[:0x20], entries[1].Data)
+	assert.Equal(t, uint32(0x102), entries[2].SecurityId)
+	assert.Equal(t, uint64(0x80000), entries[2].Offset)
+}
+
+func TestParseSecurityDescriptorStreamInvalid(t *testing.T) {
+	entry := sdsEntry(0x100, 0, testSecurityDescriptor())
+	_, err := mft.ParseSecurityDescriptorStream(entry[:0x30])
+	assert.EqualError(t, err, "entry at offset 0 with length "+strconv.Itoa(0x14+len(testSecurityDescriptor()))+" exceeds its block")
+}
@@ -0,0 +1,57 @@
+package mft
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SID represents a Windows security identifier, which identifies a user, group or computer account.
+type SID struct {
+	Revision            byte
+	IdentifierAuthority uint64
+	SubAuthorities      []uint32
+}
+
+// ParseSID parses a SID in its binary form: a revision, the number of sub authorities, a 6-byte Big Endian
+// identifier authority and the sub authorities as 4-byte Little Endian values. The returned int is the length of the
+// SID in bytes.
+func ParseSID(b []byte) (SID, int, error) {
+	if len(b) < 8 {
+		return SID{}, 0, fmt.Errorf("expected at least %d bytes but got %d", 8, len(b))
+	}
+	count := int(b[1])
+	length := 8 + count*4
+	if len(b) < length {
+		return SID{}, 0, fmt.Errorf("expected at least %d bytes for %d sub authorities but got %d", length, count, len(b))
+	}
+	authority := uint64(0)
+	for _, v := range b[2:8] {
+		authority = authority<<8 | uint64(v)
+	}
+	subAuthorities := make([]uint32, count)
+	for i := range subAuthorities {
+		subAuthorities[i] = binary.LittleEndian.Uint32(b[8+i*4:])
+	}
+	return SID{Revision: b[0], IdentifierAuthority: authority, SubAuthorities: subAuthorities}, length, nil
+}
+
+// String returns the SID in its usual string form, for example "S-1-5-21-1004336348-1177238915-682003330-512". Like
+// Windows does, an identifier authority of 2^32 or more is formatted in hexadecimal.
+func (s SID) String() string {
+	var sb strings.Builder
+	sb.WriteString("S-")
+	sb.WriteString(strconv.Itoa(int(s.Revision)))
+	if s.IdentifierAuthority >= 1<<32 {
+		fmt.Fprintf(&sb, "-0x%012X", s.IdentifierAuthority)
+	} else {
+		sb.WriteByte('-')
+		sb.WriteString(strconv.FormatUint(s.IdentifierAuthority, 10))
+	}
+	for _, a := range s.SubAuthorities {
+		sb.WriteByte('-')
+		sb.WriteString(strconv.FormatUint(uint64(a), 10))
+	}
+	return sb.String()
+}
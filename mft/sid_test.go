@@ -0,0 +1,39 @@
+package mft_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/mft"
+)
+
+func TestParseSID(t *testing.T) {
+	input := decodeHex(t, "010500000000000515000000dc04de3b8348ab4682d3a6280002000000ff")
+	sid, length, err := mft.ParseSID(input)
+	require.Nilf(t, err, "could not parse SID: %v", err)
+	assert.Equal(t, 28, length)
+	expected := mft.SID{Revision: 1, IdentifierAuthority: 5, SubAuthorities: []uint32{21, 1004405980, 1185630339, 682021762, 512}}
+	assert.Equal(t, expected, sid)
+	assert.Equal(t, "S-1-5-21-1004405980-1185630339-682021762-512", sid.String())
+}
+
+func TestParseSIDWithoutSubAuthorities(t *testing.T) {
+	sid, length, err := mft.ParseSID(decodeHex(t, "010000000000000000"))
+	require.Nilf(t, err, "could not parse SID: %v", err)
+	assert.Equal(t, 8, length)
+	assert.Equal(t, "S-1-0", sid.String())
+}
+
+func TestParseSIDTooShort(t *testing.T) {
+	_, _, err := mft.ParseSID(decodeHex(t, "01010000000000"))
+	assert.EqualError(t, err, "expected at least 8 bytes but got 7")
+
+	_, _, err = mft.ParseSID(decodeHex(t, "010200000000000515000000"))
+	assert.EqualError(t, err, "expected at least 16 bytes for 2 sub authorities but got 12")
+}
+
+func TestSIDStringLargeAuthority(t *testing.T) {
+	sid := mft.SID{Revision: 1, IdentifierAuthority: 0x123456789ABC, SubAuthorities: []uint32{1}}
+	assert.Equal(t, "S-1-0x123456789ABC-1", sid.String())
+}
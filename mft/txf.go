@@ -0,0 +1,51 @@
+package mft
+
+import (
+	"fmt"
+
+	"github.com/t9t/gomft/binutil"
+)
+
+// TxfDataStreamName is the name of the $LOGGED_UTILITY_STREAM attribute which Transactional NTFS (TxF) adds to files
+// that have been part of a transaction.
+const TxfDataStreamName = "$TXF_DATA"
+
+// TxfData represents the contents of the $TXF_DATA logged utility stream. The structure is not documented by Microsoft;
+// the layout follows the libfsntfs documentation. The ResourceManagerRoot refers to the root directory of the TxF
+// resource manager the file belongs to (normally the root directory of the volume, whose resource manager keeps its
+// logs in $Extend\$RmMetadata\$TxfLog). The TransactionID (also called the TxF file ID) and the LSNs correlate the
+// file with records in the $TxfLog and $Tops streams.
+type TxfData struct {
+	ResourceManagerRoot FileReference
+	UsnIndex            uint64
+	TransactionID       uint64
+	DataLsn             uint64
+	MetadataLsn         uint64
+	DirectoryIndexLsn   uint64
+	Flags               uint16
+}
+
+// txfDataLength is the length of the $TXF_DATA stream.
+const txfDataLength = 0x38
+
+// ParseTxfData parses the data of the $TXF_DATA logged utility stream (an attribute with type
+// AttributeTypeLoggedUtilityStream and name TxfDataStreamName) into TxfData.
+func ParseTxfData(b []byte) (TxfData, error) {
+	if len(b) < txfDataLength {
+		return TxfData{}, fmt.Errorf("expected at least %d bytes but got %d", txfDataLength, len(b))
+	}
+	r := binutil.NewLittleEndianReader(b)
+	root, err := ParseFileReference(r.Read(0x06, 8))
+	if err != nil {
+		return TxfData{}, fmt.Errorf("unable to parse resource manager root reference: %v", err)
+	}
+	return TxfData{
+		ResourceManagerRoot: root,
+		UsnIndex:            r.Uint64(0x0E),
+		TransactionID:       r.Uint64(0x16),
+		DataLsn:             r.Uint64(0x1E),
+		MetadataLsn:         r.Uint64(0x26),
+		DirectoryIndexLsn:   r.Uint64(0x2E),
+		Flags:               r.Uint16(0x36),
+	}, nil
+}
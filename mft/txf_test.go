@@ -0,0 +1,31 @@
+package mft_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/mft"
+)
+
+func TestParseTxfData(t *testing.T) {
+	input := decodeHex(t, "010000000000"+"050000000000"+"0500"+"1000000000000000"+"2a00000000000000"+
+		"0010000000000000"+"0020000000000000"+"0030000000000000"+"0100")
+	data, err := mft.ParseTxfData(input)
+	require.Nilf(t, err, "could not parse $TXF_DATA: %v", err)
+	expected := mft.TxfData{
+		ResourceManagerRoot: mft.FileReference{RecordNumber: 5, SequenceNumber: 5},
+		UsnIndex:            0x10,
+		TransactionID:       0x2A,
+		DataLsn:             0x1000,
+		MetadataLsn:         0x2000,
+		DirectoryIndexLsn:   0x3000,
+		Flags:               1,
+	}
+	assert.Equal(t, expected, data)
+}
+
+func TestParseTxfDataTooShort(t *testing.T) {
+	_, err := mft.ParseTxfData(make([]byte, 0x37))
+	assert.EqualError(t, err, "expected at least 56 bytes but got 55")
+}
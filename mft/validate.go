@@ -0,0 +1,87 @@
+package mft
+
+import "fmt"
+
+// MaxClusters is the highest number of clusters of an NTFS volume supported by Windows. Data runs longer than this are
+// considered absurd when validating without knowing the size of the volume.
+const MaxClusters = 1<<32 - 1
+
+// DataRunProblem is the kind of problem found in a DataRun by ValidateDataRuns.
+type DataRunProblem int
+
+// Problems found by ValidateDataRuns.
+const (
+	DataRunNegativeOffset DataRunProblem = iota // the absolute offset is before the start of the volume
+	DataRunZeroLength                           // the length is zero, which is never written by NTFS
+	DataRunTooLong                              // the length exceeds the size of the volume, or MaxClusters
+	DataRunBeyondVolume                         // the run ends after the end of the volume
+)
+
+func (p DataRunProblem) String() string {
+	switch p {
+	case DataRunNegativeOffset:
+		return "negative offset"
+	case DataRunZeroLength:
+		return "zero length"
+	case DataRunTooLong:
+		return "too long"
+	case DataRunBeyondVolume:
+		return "beyond volume"
+	}
+	return "unknown"
+}
+
+// DataRunWarning describes a problem with a DataRun in a list of DataRuns. Index is the position of the DataRun in the
+// list and Cluster its absolute offset in clusters.
+type DataRunWarning struct {
+	Index   int
+	Problem DataRunProblem
+	Cluster int64
+	Length  uint64
+}
+
+func (w DataRunWarning) String() string {
+	return fmt.Sprintf("data run %d (cluster %d, length %d): %v", w.Index, w.Cluster, w.Length, w.Problem)
+}
+
+// ValidateDataRuns checks a list of DataRuns, as returned by ParseDataRuns, for runs which cannot be correct on a
+// volume of totalClusters clusters: runs starting before the volume, runs ending beyond it and runs with a zero or
+// absurd length. When totalClusters is 0 (unknown), only runs with a negative offset or a length of zero or more than
+// MaxClusters are reported. Sparse runs have no clusters on disk, so they are only checked for a length of zero.
+//
+// Such runs are typically the result of corruption, and reading them would either fail or return data of other files.
+// Since the runs are not modified, the caller decides whether to skip them, stop or continue anyway.
+func ValidateDataRuns(runs []DataRun, totalClusters uint64) []DataRunWarning {
+	maxLength := uint64(MaxClusters)
+	if totalClusters > 0 {
+		maxLength = totalClusters
+	}
+
+	var warnings []DataRunWarning
+	cluster := int64(0)
+	for i, run := range runs {
+		warn := func(p DataRunProblem) {
+			warnings = append(warnings, DataRunWarning{Index: i, Problem: p, Cluster: cluster, Length: run.LengthInClusters})
+		}
+		if run.Sparse {
+			// A sparse run has no clusters on disk, so only its length can be wrong; the next run is relative to the
+			// run before it
+			if run.LengthInClusters == 0 {
+				warn(DataRunZeroLength)
+			}
+			continue
+		}
+		cluster += run.OffsetCluster
+		switch {
+		case cluster < 0:
+			warn(DataRunNegativeOffset)
+		case run.LengthInClusters == 0:
+			warn(DataRunZeroLength)
+		case run.LengthInClusters > maxLength:
+			warn(DataRunTooLong)
+		case totalClusters > 0 && uint64(cluster)+run.LengthInClusters > totalClusters:
+			warn(DataRunBeyondVolume)
+		}
+	}
+	return warnings
+}
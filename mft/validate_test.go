@@ -0,0 +1,56 @@
+package mft_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/t9t/gomft/mft"
+)
+
+func TestValidateDataRuns(t *testing.T) {
+	runs := []mft.DataRun{
+		{OffsetCluster: 100, LengthInClusters: 10},
+		{OffsetCluster: -50, LengthInClusters: 10},
+		{OffsetCluster: -60, LengthInClusters: 10},  // cluster -10
+		{OffsetCluster: 20, LengthInClusters: 0},    // cluster 10
+		{OffsetCluster: 10, LengthInClusters: 5000}, // cluster 20
+		{OffsetCluster: 970, LengthInClusters: 20},  // cluster 990
+		{OffsetCluster: 0, LengthInClusters: 10},    // cluster 990
+	}
+
+	assert.Equal(t, []mft.DataRunWarning{
+		{Index: 2, Problem: mft.DataRunNegativeOffset, Cluster: -10, Length: 10},
+		{Index: 3, Problem: mft.DataRunZeroLength, Cluster: 10, Length: 0},
+		{Index: 4, Problem: mft.DataRunTooLong, Cluster: 20, Length: 5000},
+		{Index: 5, Problem: mft.DataRunBeyondVolume, Cluster: 990, Length: 20},
+	}, mft.ValidateDataRuns(runs, 1000))
+
+	assert.Equal(t, []mft.DataRunWarning{
+		{Index: 2, Problem: mft.DataRunNegativeOffset, Cluster: -10, Length: 10},
+		{Index: 3, Problem: mft.DataRunZeroLength, Cluster: 10, Length: 0},
+	}, mft.ValidateDataRuns(runs, 0), "without volume size")
+
+	assert.Nil(t, mft.ValidateDataRuns(runs[:2], 1000))
+	assert.Equal(t, mft.DataRunTooLong, mft.ValidateDataRuns([]mft.DataRun{{LengthInClusters: 1 << 33}}, 0)[0].Problem)
+}
+
+func TestValidateDataRuns_Sparse(t *testing.T) {
+	runs := []mft.DataRun{
+		{OffsetCluster: 900, LengthInClusters: 10},
+		{LengthInClusters: 5000, Sparse: true},     // larger than the volume
+		{OffsetCluster: 50, LengthInClusters: 50},  // cluster 950
+		{LengthInClusters: 100, Sparse: true},      // reaches past the end, counting from cluster 950
+		{OffsetCluster: -900, LengthInClusters: 0}, // cluster 50
+		{LengthInClusters: 0, Sparse: true},
+	}
+	assert.Equal(t, []mft.DataRunWarning{
+		{Index: 4, Problem: mft.DataRunZeroLength, Cluster: 50, Length: 0},
+		{Index: 5, Problem: mft.DataRunZeroLength, Cluster: 50, Length: 0},
+	}, mft.ValidateDataRuns(runs, 1000))
+	assert.Nil(t, mft.ValidateDataRuns(runs[:4], 1000))
+}
+
+func TestDataRunWarning_String(t *testing.T) {
+	w := mft.DataRunWarning{Index: 3, Problem: mft.DataRunBeyondVolume, Cluster: 990, Length: 20}
+	assert.Equal(t, "data run 3 (cluster 990, length 20): beyond volume", w.String())
+}
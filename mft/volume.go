@@ -0,0 +1,115 @@
+package mft
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/t9t/gomft/binutil"
+	"github.com/t9t/gomft/utf16"
+)
+
+// VolumeRecordNumber is the number of the MFT record of the $Volume metafile, which contains the $VOLUME_NAME and
+// $VOLUME_INFORMATION attributes.
+const VolumeRecordNumber = 3
+
+// VolumeFlag represents a bit mask flag indicating the state of an NTFS volume.
+type VolumeFlag uint16
+
+// Bit values for the VolumeFlag.
+const (
+	VolumeFlagDirty             VolumeFlag = 0x0001
+	VolumeFlagResizeLogFile     VolumeFlag = 0x0002
+	VolumeFlagUpgradeOnMount    VolumeFlag = 0x0004
+	VolumeFlagMountedOnNT4      VolumeFlag = 0x0008
+	VolumeFlagDeleteUSNUnderway VolumeFlag = 0x0010
+	VolumeFlagRepairObjectIds   VolumeFlag = 0x0020
+	VolumeFlagChkdskUnderway    VolumeFlag = 0x4000
+	VolumeFlagModifiedByChkdsk  VolumeFlag = 0x8000
+)
+
+// volumeFlagNames contains the names of the VolumeFlag bits, in order of their value.
+var volumeFlagNames = []bitName{
+	{uint64(VolumeFlagDirty), "Dirty"},
+	{uint64(VolumeFlagResizeLogFile), "ResizeLogFile"},
+	{uint64(VolumeFlagUpgradeOnMount), "UpgradeOnMount"},
+	{uint64(VolumeFlagMountedOnNT4), "MountedOnNT4"},
+	{uint64(VolumeFlagDeleteUSNUnderway), "DeleteUSNUnderway"},
+	{uint64(VolumeFlagRepairObjectIds), "RepairObjectIds"},
+	{uint64(VolumeFlagChkdskUnderway), "ChkdskUnderway"},
+	{uint64(VolumeFlagModifiedByChkdsk), "ModifiedByChkdsk"},
+}
+
+// Is checks if this VolumeFlag's bit mask contains the specified flag.
+func (f *VolumeFlag) Is(c VolumeFlag) bool {
+	return *f&c == c
+}
+
+// String returns the names of the flags which are set, separated by "|", for example "Dirty|ModifiedByChkdsk".
+func (f VolumeFlag) String() string {
+	return formatBits(uint64(f), volumeFlagNames)
+}
+
+// VolumeInformation represents the data contained in a $VOLUME_INFORMATION attribute.
+type VolumeInformation struct {
+	MajorVersion byte
+	MinorVersion byte
+	Flags        VolumeFlag
+}
+
+// ParseVolumeInformation parses the data of a $VOLUME_INFORMATION attribute's data (type
+// AttributeTypeVolumeInformation) into VolumeInformation.
+func ParseVolumeInformation(b []byte) (VolumeInformation, error) {
+	if len(b) < 12 {
+		return VolumeInformation{}, fmt.Errorf("expected at least %d bytes but got %d", 12, len(b))
+	}
+	r := binutil.NewLittleEndianReader(b)
+	return VolumeInformation{
+		MajorVersion: r.Byte(0x08),
+		MinorVersion: r.Byte(0x09),
+		Flags:        VolumeFlag(r.Uint16(0x0A)),
+	}, nil
+}
+
+// VolumeInfo contains the label, NTFS version and state of a volume, as stored in its $Volume record.
+type VolumeInfo struct {
+	Label        string
+	MajorVersion byte
+	MinorVersion byte
+	Flags        VolumeFlag
+}
+
+// ParseVolumeRecord returns the VolumeInfo from the $VOLUME_NAME and $VOLUME_INFORMATION attributes of the $Volume
+// record (record number VolumeRecordNumber). The label is empty when the record has no $VOLUME_NAME attribute, but
+// the $VOLUME_INFORMATION attribute is required.
+func ParseVolumeRecord(r Record) (VolumeInfo, error) {
+	a, ok := r.FindFirstAttribute(AttributeTypeVolumeInformation)
+	if !ok {
+		return VolumeInfo{}, fmt.Errorf("no $VOLUME_INFORMATION attribute found")
+	}
+	vi, err := ParseVolumeInformation(a.Data)
+	if err != nil {
+		return VolumeInfo{}, fmt.Errorf("unable to parse $VOLUME_INFORMATION: %v", err)
+	}
+	info := VolumeInfo{MajorVersion: vi.MajorVersion, MinorVersion: vi.MinorVersion, Flags: vi.Flags}
+	if a, ok := r.FindFirstAttribute(AttributeTypeVolumeName); ok {
+		info.Label = utf16.DecodeString(a.Data, binary.LittleEndian)
+	}
+	return info, nil
+}
+
+// Version returns the NTFS version in the usual notation, for example "3.1".
+func (v VolumeInfo) Version() string {
+	return fmt.Sprintf("%d.%d", v.MajorVersion, v.MinorVersion)
+}
+
+// IsDirty indicates whether the volume is marked dirty, meaning it was not cleanly unmounted or chkdsk should run on
+// it; either way its metadata may not be consistent.
+func (v VolumeInfo) IsDirty() bool {
+	return v.Flags.Is(VolumeFlagDirty)
+}
+
+// NeedsChkdsk indicates whether chkdsk was interrupted or should be run on the volume, which is the case when it is
+// dirty or when a chkdsk run did not complete.
+func (v VolumeInfo) NeedsChkdsk() bool {
+	return v.Flags.Is(VolumeFlagDirty) || v.Flags.Is(VolumeFlagChkdskUnderway)
+}
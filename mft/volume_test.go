@@ -0,0 +1,56 @@
+package mft_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/mft"
+)
+
+func TestParseVolumeInformation(t *testing.T) {
+	vi, err := mft.ParseVolumeInformation(decodeHex(t, "0000000000000000030180000000000000"))
+	require.Nilf(t, err, "could not parse $VOLUME_INFORMATION: %v", err)
+	assert.Equal(t, mft.VolumeInformation{MajorVersion: 3, MinorVersion: 1, Flags: mft.VolumeFlag(0x80)}, vi)
+
+	_, err = mft.ParseVolumeInformation(make([]byte, 11))
+	assert.EqualError(t, err, "expected at least 12 bytes but got 11")
+}
+
+func TestParseVolumeRecord(t *testing.T) {
+	record := mft.Record{Attributes: []mft.Attribute{
+		{Type: mft.AttributeTypeVolumeName, Data: encodeUtf16("Data")},
+		{Type: mft.AttributeTypeVolumeInformation, Data: decodeHex(t, "000000000000000003010180")},
+	}}
+	info, err := mft.ParseVolumeRecord(record)
+	require.Nilf(t, err, "could not parse $Volume record: %v", err)
+	assert.Equal(t, mft.VolumeInfo{Label: "Data", MajorVersion: 3, MinorVersion: 1, Flags: mft.VolumeFlagDirty | mft.VolumeFlagModifiedByChkdsk}, info)
+	assert.Equal(t, "3.1", info.Version())
+	assert.True(t, info.IsDirty())
+	assert.True(t, info.NeedsChkdsk())
+	assert.Equal(t, "Dirty|ModifiedByChkdsk", info.Flags.String())
+}
+
+func TestParseVolumeRecordWithoutLabel(t *testing.T) {
+	record := mft.Record{Attributes: []mft.Attribute{
+		{Type: mft.AttributeTypeVolumeInformation, Data: decodeHex(t, "000000000000000003010040")},
+	}}
+	info, err := mft.ParseVolumeRecord(record)
+	require.Nilf(t, err, "could not parse $Volume record: %v", err)
+	assert.Equal(t, "", info.Label)
+	assert.False(t, info.IsDirty())
+	assert.True(t, info.NeedsChkdsk())
+}
+
+func TestParseVolumeRecordInvalid(t *testing.T) {
+	_, err := mft.ParseVolumeRecord(mft.Record{})
+	assert.EqualError(t, err, "no $VOLUME_INFORMATION attribute found")
+
+	_, err = mft.ParseVolumeRecord(mft.Record{Attributes: []mft.Attribute{{Type: mft.AttributeTypeVolumeInformation}}})
+	assert.EqualError(t, err, "unable to parse $VOLUME_INFORMATION: expected at least 12 bytes but got 0")
+}
+
+func TestVolumeFlagString(t *testing.T) {
+	assert.Equal(t, "0", mft.VolumeFlag(0).String())
+	assert.Equal(t, "UpgradeOnMount|ChkdskUnderway|0x100", (mft.VolumeFlagUpgradeOnMount | mft.VolumeFlagChkdskUnderway | 0x100).String())
+}
@@ -0,0 +1,147 @@
+package mft
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+)
+
+// Names of the extended attributes in which WSL stores the Linux metadata of files it creates.
+const (
+	WSLExtendedAttributeMode   = "$LXMOD"
+	WSLExtendedAttributeUID    = "$LXUID"
+	WSLExtendedAttributeGID    = "$LXGID"
+	WSLExtendedAttributeDevice = "$LXDEV"
+	// WSLExtendedAttributeCaseSensitive holds the case sensitivity of a directory, as set by WSL for directories it
+	// creates (or using fsutil file setCaseSensitiveInfo). Its value contains the flags of
+	// FILE_CASE_SENSITIVE_INFORMATION.
+	WSLExtendedAttributeCaseSensitive = "$LXCASE"
+)
+
+// CaseSensitiveDirectoryFlag is the flag (FILE_CS_FLAG_CASE_SENSITIVE_DIR) in the value of the
+// WSLExtendedAttributeCaseSensitive extended attribute indicating that the names in the directory are case sensitive.
+const CaseSensitiveDirectoryFlag = 0x00000001
+
+// WSLMetadata contains the Linux metadata that WSL stores in the extended attributes of a file. The Has fields
+// indicate which values were present; files not created or modified through WSL typically have none of them.
+type WSLMetadata struct {
+	Mode        os.FileMode
+	UnixMode    uint32 // the st_mode value as stored
+	UID         uint32
+	GID         uint32
+	DeviceMajor uint32
+	DeviceMinor uint32
+	// CaseSensitive indicates that the names in the directory are case sensitive, so it may contain names which only
+	// differ in case.
+	CaseSensitive bool
+	HasMode       bool
+	HasUID        bool
+	HasGID        bool
+	HasDevice     bool
+}
+
+// IsCaseSensitiveDirectory indicates whether the record is a directory whose names are case sensitive, according to the
+// WSLExtendedAttributeCaseSensitive extended attribute in its $EA attribute. Directories are case insensitive by
+// default, which is also assumed when the $EA attribute cannot be parsed.
+func IsCaseSensitiveDirectory(r Record) bool {
+	if !r.IsDirectory() {
+		return false
+	}
+	a, ok := r.FindFirstAttribute(AttributeTypeEA)
+	if !ok || !a.Resident {
+		return false
+	}
+	attributes, err := ParseExtendedAttributes(a.Data)
+	if err != nil {
+		return false
+	}
+	m, err := ParseWSLMetadata(attributes)
+	return err == nil && m.CaseSensitive
+}
+
+// ParseWSLMetadata extracts the WSL metadata from the extended attributes of a file, as returned by
+// ParseExtendedAttributes. Other extended attributes are ignored.
+func ParseWSLMetadata(attributes []ExtendedAttribute) (WSLMetadata, error) {
+	m := WSLMetadata{}
+	for _, a := range attributes {
+		var length int
+		switch a.Name {
+		case WSLExtendedAttributeMode, WSLExtendedAttributeUID, WSLExtendedAttributeGID, WSLExtendedAttributeCaseSensitive:
+			length = 4
+		case WSLExtendedAttributeDevice:
+			length = 8
+		default:
+			continue
+		}
+		if len(a.Value) < length {
+			return WSLMetadata{}, fmt.Errorf("expected at least %d bytes for %s but got %d", length, a.Name, len(a.Value))
+		}
+
+		v := binary.LittleEndian.Uint32(a.Value)
+		switch a.Name {
+		case WSLExtendedAttributeMode:
+			m.UnixMode = v
+			m.Mode = UnixModeToFileMode(v)
+			m.HasMode = true
+		case WSLExtendedAttributeUID:
+			m.UID = v
+			m.HasUID = true
+		case WSLExtendedAttributeGID:
+			m.GID = v
+			m.HasGID = true
+		case WSLExtendedAttributeDevice:
+			m.DeviceMajor = v
+			m.DeviceMinor = binary.LittleEndian.Uint32(a.Value[4:])
+			m.HasDevice = true
+		case WSLExtendedAttributeCaseSensitive:
+			m.CaseSensitive = v&CaseSensitiveDirectoryFlag != 0
+		}
+	}
+	return m, nil
+}
+
+// Bits of a Unix st_mode value.
+const (
+	unixModeTypeMask   = 0170000
+	unixModeSocket     = 0140000
+	unixModeSymlink    = 0120000
+	unixModeRegular    = 0100000
+	unixModeBlock      = 0060000
+	unixModeDirectory  = 0040000
+	unixModeCharacter  = 0020000
+	unixModeNamedPipe  = 0010000
+	unixModeSetuid     = 0004000
+	unixModeSetgid     = 0002000
+	unixModeSticky     = 0001000
+	unixModePermission = 0000777
+)
+
+// UnixModeToFileMode converts a Unix st_mode value, as stored by WSL in the $LXMOD extended attribute, into an
+// os.FileMode with the corresponding type and permission bits.
+func UnixModeToFileMode(mode uint32) os.FileMode {
+	m := os.FileMode(mode & unixModePermission)
+	switch mode & unixModeTypeMask {
+	case unixModeSocket:
+		m |= os.ModeSocket
+	case unixModeSymlink:
+		m |= os.ModeSymlink
+	case unixModeBlock:
+		m |= os.ModeDevice
+	case unixModeDirectory:
+		m |= os.ModeDir
+	case unixModeCharacter:
+		m |= os.ModeDevice | os.ModeCharDevice
+	case unixModeNamedPipe:
+		m |= os.ModeNamedPipe
+	}
+	if mode&unixModeSetuid != 0 {
+		m |= os.ModeSetuid
+	}
+	if mode&unixModeSetgid != 0 {
+		m |= os.ModeSetgid
+	}
+	if mode&unixModeSticky != 0 {
+		m |= os.ModeSticky
+	}
+	return m
+}
@@ -0,0 +1,81 @@
+package mft_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/mfttest"
+)
+
+func TestParseWSLMetadata(t *testing.T) {
+	attributes := []mft.ExtendedAttribute{
+		{Name: "$KERNEL.PURGE.ESBCACHE", Value: []byte{1}},
+		{Name: "$LXUID", Value: []byte{0xe8, 0x03, 0x00, 0x00}},
+		{Name: "$LXGID", Value: []byte{0xe9, 0x03, 0x00, 0x00}},
+		{Name: "$LXMOD", Value: []byte{0xed, 0x41, 0x00, 0x00}},
+	}
+	m, err := mft.ParseWSLMetadata(attributes)
+	require.Nilf(t, err, "could not parse WSL metadata: %v", err)
+	expected := mft.WSLMetadata{
+		Mode:     os.ModeDir | 0755,
+		UnixMode: 040755,
+		UID:      1000,
+		GID:      1001,
+		HasMode:  true,
+		HasUID:   true,
+		HasGID:   true,
+	}
+	assert.Equal(t, expected, m)
+}
+
+func TestParseWSLMetadataDevice(t *testing.T) {
+	m, err := mft.ParseWSLMetadata([]mft.ExtendedAttribute{{Name: "$LXDEV", Value: []byte{8, 0, 0, 0, 1, 0, 0, 0}}})
+	require.Nilf(t, err, "could not parse WSL metadata: %v", err)
+	assert.Equal(t, mft.WSLMetadata{DeviceMajor: 8, DeviceMinor: 1, HasDevice: true}, m)
+}
+
+func TestParseWSLMetadataInvalid(t *testing.T) {
+	_, err := mft.ParseWSLMetadata([]mft.ExtendedAttribute{{Name: "$LXDEV", Value: []byte{8, 0, 0, 0}}})
+	assert.EqualError(t, err, "expected at least 8 bytes for $LXDEV but got 4")
+}
+
+func TestUnixModeToFileMode(t *testing.T) {
+	tests := []struct {
+		mode     uint32
+		expected os.FileMode
+	}{
+		{0100644, 0644},
+		{040755, os.ModeDir | 0755},
+		{0120777, os.ModeSymlink | 0777},
+		{0140755, os.ModeSocket | 0755},
+		{010600, os.ModeNamedPipe | 0600},
+		{020620, os.ModeDevice | os.ModeCharDevice | 0620},
+		{060660, os.ModeDevice | 0660},
+		{0104755, os.ModeSetuid | 0755},
+		{0102755, os.ModeSetgid | 0755},
+		{041777, os.ModeDir | os.ModeSticky | 0777},
+	}
+	for _, tt := range tests {
+		assert.Equalf(t, tt.expected, mft.UnixModeToFileMode(tt.mode), "mode %o", tt.mode)
+	}
+}
+
+func TestParseWSLMetadataCaseSensitive(t *testing.T) {
+	m, err := mft.ParseWSLMetadata([]mft.ExtendedAttribute{{Name: "$LXCASE", Value: []byte{1, 0, 0, 0}}})
+	require.Nilf(t, err, "could not parse WSL metadata: %v", err)
+	assert.Equal(t, mft.WSLMetadata{CaseSensitive: true}, m)
+}
+
+func TestIsCaseSensitiveDirectory(t *testing.T) {
+	ea := func(value byte) mft.Attribute {
+		return mft.Attribute{Type: mft.AttributeTypeEA, Resident: true, Data: mfttest.EncodeExtendedAttributes(
+			[]mft.ExtendedAttribute{{Name: "$LXCASE", Value: []byte{value, 0, 0, 0}}})}
+	}
+	assert.True(t, mft.IsCaseSensitiveDirectory(mfttest.NewRecord().WithDirectory().WithAttribute(ea(1)).Record()))
+	assert.False(t, mft.IsCaseSensitiveDirectory(mfttest.NewRecord().WithDirectory().WithAttribute(ea(0)).Record()))
+	assert.False(t, mft.IsCaseSensitiveDirectory(mfttest.NewRecord().WithAttribute(ea(1)).Record()))
+	assert.False(t, mft.IsCaseSensitiveDirectory(mfttest.NewRecord().WithDirectory().Record()))
+}
@@ -0,0 +1,132 @@
+package mft
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/t9t/gomft/utf16"
+)
+
+// ZoneIdentifierStream is the name of the alternate data stream in which Windows marks files downloaded from the
+// internet (the "Mark of the Web").
+const ZoneIdentifierStream = "Zone.Identifier"
+
+// Zone is a URL security zone, as used in a ZoneIdentifier.
+type Zone int
+
+// Known values for Zone.
+const (
+	ZoneLocalMachine Zone = 0
+	ZoneIntranet     Zone = 1
+	ZoneTrusted      Zone = 2
+	ZoneInternet     Zone = 3
+	ZoneUntrusted    Zone = 4
+)
+
+func (z Zone) String() string {
+	switch z {
+	case ZoneLocalMachine:
+		return "LocalMachine"
+	case ZoneIntranet:
+		return "Intranet"
+	case ZoneTrusted:
+		return "Trusted"
+	case ZoneInternet:
+		return "Internet"
+	case ZoneUntrusted:
+		return "Untrusted"
+	}
+	return fmt.Sprintf("Zone(%d)", int(z))
+}
+
+// ZoneIdentifier is the content of a Zone.Identifier stream, recording where a file was downloaded from. Older versions
+// of Windows only write the ZoneId; browsers usually add the ReferrerUrl (the page linking to the download) and the
+// HostUrl (the URL of the download itself). HasZoneId indicates whether the ZoneId was present.
+type ZoneIdentifier struct {
+	ZoneId                      Zone
+	HasZoneId                   bool
+	ReferrerUrl                 string
+	HostUrl                     string
+	HostIpAddress               string
+	LastWriterPackageFamilyName string
+	AppZoneId                   string
+}
+
+// ParseZoneIdentifier parses the content of a Zone.Identifier stream: the [ZoneTransfer] section of an INI file,
+// encoded as UTF-8 (or ANSI) or as UTF-16 with a byte order mark. Keys are matched case insensitively and unknown keys
+// are ignored.
+func ParseZoneIdentifier(b []byte) (ZoneIdentifier, error) {
+	var text string
+	switch {
+	case bytes.HasPrefix(b, []byte{0xFF, 0xFE}):
+		text = utf16.DecodeString(b[2:len(b)&^1], binary.LittleEndian)
+	case bytes.HasPrefix(b, []byte{0xFE, 0xFF}):
+		text = utf16.DecodeString(b[2:len(b)&^1], binary.BigEndian)
+	default:
+		text = string(bytes.TrimPrefix(b, []byte{0xEF, 0xBB, 0xBF}))
+	}
+
+	z := ZoneIdentifier{}
+	found, inSection := false, false
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == ';' {
+			continue
+		}
+		if line[0] == '[' {
+			inSection = strings.EqualFold(line, "[ZoneTransfer]")
+			found = found || inSection
+			continue
+		}
+		if !inSection {
+			continue
+		}
+		i := strings.IndexByte(line, '=')
+		if i < 0 {
+			continue
+		}
+		key, value := strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+		switch strings.ToLower(key) {
+		case "zoneid":
+			zone, err := strconv.Atoi(value)
+			if err != nil {
+				return ZoneIdentifier{}, fmt.Errorf("invalid ZoneId %q: %v", value, err)
+			}
+			z.ZoneId, z.HasZoneId = Zone(zone), true
+		case "referrerurl":
+			z.ReferrerUrl = value
+		case "hosturl":
+			z.HostUrl = value
+		case "hostipaddress":
+			z.HostIpAddress = value
+		case "lastwriterpackagefamilyname":
+			z.LastWriterPackageFamilyName = value
+		case "appzoneid":
+			z.AppZoneId = value
+		}
+	}
+	if !found {
+		return ZoneIdentifier{}, fmt.Errorf("no [ZoneTransfer] section found")
+	}
+	return z, nil
+}
+
+// ZoneIdentifier parses the Zone.Identifier stream of the record, if the record has one. It returns false when the
+// record has no such stream, or when the stream is non-resident (which it practically never is, being just a few
+// lines of text) and its data can thus not be read from the record.
+func (r *Record) ZoneIdentifier() (ZoneIdentifier, bool, error) {
+	for _, a := range r.FindAttributes(AttributeTypeData) {
+		if !strings.EqualFold(a.Name, ZoneIdentifierStream) || !a.Resident {
+			continue
+		}
+		z, err := ParseZoneIdentifier(a.Data)
+		if err != nil {
+			return ZoneIdentifier{}, true, fmt.Errorf("unable to parse %s stream: %v", ZoneIdentifierStream, err)
+		}
+		return z, true, nil
+	}
+	return ZoneIdentifier{}, false, nil
+}
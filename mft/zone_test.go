@@ -0,0 +1,82 @@
+package mft_test
+
+import (
+	"encoding/binary"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/mfttest"
+)
+
+const zoneIdentifier = "[ZoneTransfer]\r\n" +
+	"ZoneId=3\r\n" +
+	"ReferrerUrl=https://example.com/downloads\r\n" +
+	"HostUrl=https://example.com/downloads/setup.exe?x=1\r\n"
+
+func TestParseZoneIdentifier(t *testing.T) {
+	z, err := mft.ParseZoneIdentifier([]byte(zoneIdentifier))
+	require.Nilf(t, err, "could not parse zone identifier: %v", err)
+	expected := mft.ZoneIdentifier{
+		ZoneId:      mft.ZoneInternet,
+		HasZoneId:   true,
+		ReferrerUrl: "https://example.com/downloads",
+		HostUrl:     "https://example.com/downloads/setup.exe?x=1",
+	}
+	assert.Equal(t, expected, z)
+	assert.Equal(t, "Internet", z.ZoneId.String())
+
+	// UTF-16 with a byte order mark, as written by some applications
+	encoded := utf16.Encode([]rune(zoneIdentifier))
+	b := []byte{0xFF, 0xFE}
+	for _, c := range encoded {
+		b = append(b, 0, 0)
+		binary.LittleEndian.PutUint16(b[len(b)-2:], c)
+	}
+	z, err = mft.ParseZoneIdentifier(b)
+	require.Nilf(t, err, "could not parse zone identifier: %v", err)
+	assert.Equal(t, expected, z)
+}
+
+func TestParseZoneIdentifier_Other(t *testing.T) {
+	z, err := mft.ParseZoneIdentifier([]byte("\xEF\xBB\xBF[Other]\nZoneId=1\n; comment\n[zonetransfer]\nzoneid = 4\n" +
+		"LastWriterPackageFamilyName=Microsoft.MicrosoftEdge_8wekyb3d8bbwe\nAppZoneId=4\nHostIpAddress=192.0.2.1\nUnknown=1\nnot a key"))
+	require.Nilf(t, err, "could not parse zone identifier: %v", err)
+	assert.Equal(t, mft.ZoneIdentifier{
+		ZoneId:                      mft.ZoneUntrusted,
+		HasZoneId:                   true,
+		HostIpAddress:               "192.0.2.1",
+		LastWriterPackageFamilyName: "Microsoft.MicrosoftEdge_8wekyb3d8bbwe",
+		AppZoneId:                   "4",
+	}, z)
+	assert.Equal(t, "Zone(7)", mft.Zone(7).String())
+}
+
+func TestParseZoneIdentifier_Invalid(t *testing.T) {
+	_, err := mft.ParseZoneIdentifier([]byte("ZoneId=3\r\n"))
+	assert.EqualError(t, err, "no [ZoneTransfer] section found")
+	_, err = mft.ParseZoneIdentifier([]byte("[ZoneTransfer]\r\nZoneId=x\r\n"))
+	assert.NotNil(t, err)
+	_, err = mft.ParseZoneIdentifier(nil)
+	assert.NotNil(t, err)
+}
+
+func TestRecord_ZoneIdentifier(t *testing.T) {
+	r := mfttest.NewRecord().WithFileName("setup.exe").WithStream("zone.identifier", []byte(zoneIdentifier)).Record()
+	z, ok, err := r.ZoneIdentifier()
+	require.Nilf(t, err, "could not parse zone identifier: %v", err)
+	assert.True(t, ok)
+	assert.Equal(t, "https://example.com/downloads/setup.exe?x=1", z.HostUrl)
+
+	r = mfttest.NewRecord().WithFileName("notes.txt").WithResidentData([]byte(zoneIdentifier)).Record()
+	_, ok, err = r.ZoneIdentifier()
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	r = mfttest.NewRecord().WithFileName("broken.exe").WithStream(mft.ZoneIdentifierStream, []byte("garbage")).Record()
+	_, ok, err = r.ZoneIdentifier()
+	assert.True(t, ok)
+	assert.EqualError(t, err, "unable to parse Zone.Identifier stream: no [ZoneTransfer] section found")
+}
@@ -0,0 +1,273 @@
+/*
+	Package mftdiff compares two dumps of the same MFT, for example taken before and after running a program, and
+	reports which records were created, deleted, reused or modified in between.
+
+	Basic usage
+
+	Pass both dumps to Compare, which calls a function for each record that changed. Use PathResolvers to include
+	the paths of the files, and Ignore to skip differences which are not of interest.
+			// Error handling left out for brevity
+			opts := mftdiff.Options{
+				OldPaths: pipeline.NewPathResolver(before, 1024, 0),
+				NewPaths: pipeline.NewPathResolver(after, 1024, 0),
+				Ignore:   mftdiff.IgnoreAccessTimes,
+			}
+			stats, err := mftdiff.Compare(before, after, opts, func(c mftdiff.Change) error {
+				fmt.Println(c.Kind, c.RecordNumber, c.New.Path)
+				return nil
+			})
+
+	A record is identified by its record number and sequence number. Compared to the old dump, a record is:
+		- created when it is in use in the new dump only;
+		- deleted when it is in use in the old dump only;
+		- reused when it is in use in both, but with a different sequence number (ie. it belongs to another file);
+		- modified when it is in use in both, with the same sequence number, and mft.DiffRecords reports differences.
+	Records which are not in use in either dump, and records which could not be parsed, are not reported. A dump which
+	is shorter than the other is treated as if the missing records are not in use.
+
+	To find the files which were destroyed because their record was reused, use FindReused instead. It uses the
+	sequence numbers to also find reused records which Compare reports as created or deleted, or not at all, such as
+	the record of a deleted file which was overwritten by a new file.
+
+	Implementation notes
+
+	Both dumps are read and parsed by mft.ParseAll, and the records are compared in order of their record number. Only
+	the records read ahead by ParseAll and the directories cached by the PathResolvers are kept in memory, so dumps of
+	any size are compared in bounded memory.
+*/
+package mftdiff
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/t9t/gomft/export"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/pipeline"
+)
+
+// ChangeKind classifies how a record changed between two dumps.
+type ChangeKind int
+
+// Kinds of changes reported by Compare.
+const (
+	Created  ChangeKind = iota // the record is only in use in the new dump
+	Deleted                    // the record is only in use in the old dump
+	Reused                     // the record is in use in both dumps, but with a different sequence number
+	Modified                   // the record is in use in both dumps with the same sequence number, but has changed
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case Created:
+		return "created"
+	case Deleted:
+		return "deleted"
+	case Reused:
+		return "reused"
+	case Modified:
+		return "modified"
+	}
+	return "unknown"
+}
+
+// Change describes a record which changed between the old and the new dump. Old and New are created from the records
+// by export.FromRecord, with their Offset and (when a PathResolver was set in the Options) Path set. When a dump does
+// not contain a parsable record with the RecordNumber, its Entry is empty; a record which is not in use is still
+// included, since a deleted record usually still contains its name. Differences is only set for Modified records.
+type Change struct {
+	Kind         ChangeKind
+	RecordNumber uint64
+	Old          export.Entry
+	New          export.Entry
+	Differences  []mft.Difference
+}
+
+// Options configures Compare.
+type Options struct {
+	// RecordSize is the size of a single MFT record in bytes. When zero, 1024 is used.
+	RecordSize int
+	// OldPaths and NewPaths, when not nil, resolve the paths of the records of the old and new dump respectively.
+	OldPaths *pipeline.PathResolver
+	NewPaths *pipeline.PathResolver
+	// Ignore, when not nil, is called for each difference between two records with the same sequence number. The
+	// differences for which it returns true are left out, so records which only have such differences are not
+	// reported as Modified.
+	Ignore func(d mft.Difference) bool
+}
+
+// Stats contains the counts of a completed comparison.
+type Stats struct {
+	Records  int // number of record numbers compared, which are those parsed successfully in either dump
+	Created  int
+	Deleted  int
+	Reused   int
+	Modified int
+	Errors   int // number of records which could not be parsed, in either dump
+}
+
+// IgnoreAccessTimes can be used as Options.Ignore to leave out changes of the last access times, which are updated by
+// merely reading files and so are rarely of interest.
+func IgnoreAccessTimes(d mft.Difference) bool {
+	return strings.HasSuffix(d.Field, ".LastAccess")
+}
+
+// Compare reads the records of the old dump a and the new dump b and calls fn for each record which changed between
+// them, in order of the record number. It returns when all records have been compared, when fn returns an error, or
+// when reading from either dump fails.
+func Compare(a, b io.Reader, opts Options, fn func(c Change) error) (Stats, error) {
+	return CompareContext(context.Background(), a, b, opts, fn)
+}
+
+// CompareContext works like Compare, but also stops when ctx is done, returning ctx.Err() and the counts of the records
+// compared until then.
+func CompareContext(ctx context.Context, a, b io.Reader, opts Options, fn func(c Change) error) (Stats, error) {
+	c := &comparison{opts: opts, fn: fn}
+	err := walk(ctx, a, b, opts, &c.stats, c.compare)
+	return c.stats, err
+}
+
+// walk reads the records of both dumps and calls fn for each record number which was parsed successfully in either
+// dump, in order of the record number, with nil for the dump in which it was not.
+func walk(ctx context.Context, a, b io.Reader, opts Options, stats *Stats, fn func(index int, a *mft.RecordResult, b *mft.RecordResult) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	parseOpts := mft.ParseAllOptions{RecordSize: opts.RecordSize, SkipEmpty: true}
+	before := &stream{results: mft.ParseAllContext(ctx, a, parseOpts), stats: stats}
+	after := &stream{results: mft.ParseAllContext(ctx, b, parseOpts), stats: stats}
+	if err := before.next(); err != nil {
+		return err
+	}
+	if err := after.next(); err != nil {
+		return err
+	}
+
+	for before.current != nil || after.current != nil {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var err error
+		switch {
+		case after.current == nil || (before.current != nil && before.current.Index < after.current.Index):
+			err = fn(before.current.Index, before.current, nil)
+			if err == nil {
+				err = before.next()
+			}
+		case before.current == nil || after.current.Index < before.current.Index:
+			err = fn(after.current.Index, nil, after.current)
+			if err == nil {
+				err = after.next()
+			}
+		default:
+			err = fn(before.current.Index, before.current, after.current)
+			if err == nil {
+				err = before.next()
+			}
+			if err == nil {
+				err = after.next()
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+// stream is one of the dumps being compared. The current result is nil when all records have been read.
+type stream struct {
+	results <-chan mft.RecordResult
+	current *mft.RecordResult
+	stats   *Stats
+}
+
+// next advances to the next record that was parsed successfully.
+func (s *stream) next() error {
+	for result := range s.results {
+		if readErr, ok := result.Err.(*mft.ReadError); ok {
+			return readErr
+		}
+		if result.Err != nil {
+			s.stats.Errors++
+			continue
+		}
+		r := result
+		s.current = &r
+		return nil
+	}
+	s.current = nil
+	return nil
+}
+
+type comparison struct {
+	opts  Options
+	fn    func(c Change) error
+	stats Stats
+}
+
+func (c *comparison) compare(index int, a *mft.RecordResult, b *mft.RecordResult) error {
+	c.stats.Records++
+	oldInUse := a != nil && a.Record.IsInUse()
+	newInUse := b != nil && b.Record.IsInUse()
+	change := Change{RecordNumber: uint64(index)}
+	switch {
+	case !oldInUse && !newInUse:
+		return nil
+	case !oldInUse:
+		change.Kind = Created
+	case !newInUse:
+		change.Kind = Deleted
+	case a.Record.FileReference.SequenceNumber != b.Record.FileReference.SequenceNumber:
+		change.Kind = Reused
+	default:
+		change.Kind = Modified
+		for _, d := range mft.DiffRecords(a.Record, b.Record) {
+			if c.opts.Ignore == nil || !c.opts.Ignore(d) {
+				change.Differences = append(change.Differences, d)
+			}
+		}
+		if len(change.Differences) == 0 {
+			return nil
+		}
+	}
+
+	var err error
+	if change.Old, err = entry(a, c.opts.OldPaths); err != nil {
+		return err
+	}
+	if change.New, err = entry(b, c.opts.NewPaths); err != nil {
+		return err
+	}
+	switch change.Kind {
+	case Created:
+		c.stats.Created++
+	case Deleted:
+		c.stats.Deleted++
+	case Reused:
+		c.stats.Reused++
+	case Modified:
+		c.stats.Modified++
+	}
+	if err := c.fn(change); err != nil {
+		return fmt.Errorf("unable to process record %d: %v", index, err)
+	}
+	return nil
+}
+
+func entry(result *mft.RecordResult, paths *pipeline.PathResolver) (export.Entry, error) {
+	if result == nil {
+		return export.Entry{}, nil
+	}
+	e := export.FromRecord(result.Record)
+	e.Offset = result.Offset
+	if paths != nil {
+		path, err := paths.Path(e)
+		if err != nil {
+			return export.Entry{}, fmt.Errorf("unable to resolve path of record %d: %v", result.Index, err)
+		}
+		e.Path = path
+	}
+	return e, nil
+}
@@ -0,0 +1,217 @@
+package mftdiff_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/mftdiff"
+	"github.com/t9t/gomft/mfttest"
+	"github.com/t9t/gomft/pipeline"
+)
+
+func file(number uint64, sequenceNumber uint16, name string, data string) *mfttest.RecordBuilder {
+	return mfttest.NewRecord().
+		WithRecordNumber(number).
+		WithSequenceNumber(sequenceNumber).
+		WithTime(mfttest.SampleTime).
+		WithStandardInformation(mft.FileAttributeArchive).
+		WithFileName(name).
+		WithResidentData([]byte(data))
+}
+
+// dump creates the data of an MFT containing the records at their record number, and empty records in between.
+func dump(records ...*mfttest.RecordBuilder) []byte {
+	data := make([]byte, 0)
+	for _, r := range records {
+		b := r.Bytes()
+		number, err := mft.ParseRecord(b)
+		if err != nil {
+			panic(err)
+		}
+		offset := int(number.FileReference.RecordNumber) * 1024
+		if len(data) < offset+1024 {
+			data = append(data, make([]byte, offset+1024-len(data))...)
+		}
+		copy(data[offset:], b)
+	}
+	return data
+}
+
+func testDumps() ([]byte, []byte) {
+	root := mfttest.NewRecord().WithRecordNumber(5).WithSequenceNumber(5).WithDirectory().WithFileName(".")
+	accessed := mfttest.NewRecord().
+		WithRecordNumber(69).
+		WithTime(mfttest.SampleTime).
+		WithStandardInformationData(mft.StandardInformation{
+			Creation: mfttest.SampleTime, FileLastModified: mfttest.SampleTime, MftLastModified: mfttest.SampleTime, LastAccess: mfttest.SampleTime.Add(time.Hour),
+			FileAttributes: mft.FileAttributeArchive,
+		}).
+		WithFileName("accessed.txt").
+		WithResidentData(nil)
+	before := dump(
+		root,
+		file(64, 1, "same.txt", "same"),
+		file(65, 1, "modified.txt", "hello"),
+		file(66, 1, "deleted.txt", ""),
+		file(67, 2, "was-deleted.txt", "").WithFlags(0),
+		file(68, 1, "old.txt", ""),
+		file(69, 1, "accessed.txt", ""),
+	)
+	after := dump(
+		root,
+		file(64, 1, "same.txt", "same"),
+		file(65, 1, "modified.txt", "hello, world"),
+		file(66, 1, "deleted.txt", "").WithFlags(0),
+		file(67, 3, "created.txt", ""),
+		file(68, 2, "new.txt", ""),
+		accessed,
+		file(70, 1, "appended.txt", ""),
+	)
+	return before, after
+}
+
+func TestCompare(t *testing.T) {
+	before, after := testDumps()
+	opts := mftdiff.Options{
+		OldPaths: pipeline.NewPathResolver(bytes.NewReader(before), 1024, 0),
+		NewPaths: pipeline.NewPathResolver(bytes.NewReader(after), 1024, 0),
+	}
+	changes := make([]mftdiff.Change, 0)
+	stats, err := mftdiff.Compare(bytes.NewReader(before), bytes.NewReader(after), opts, func(c mftdiff.Change) error {
+		changes = append(changes, c)
+		return nil
+	})
+	require.Nilf(t, err, "unable to compare: %v", err)
+	assert.Equal(t, mftdiff.Stats{Records: 8, Created: 2, Deleted: 1, Reused: 1, Modified: 2}, stats)
+
+	require.Equal(t, 6, len(changes))
+	modified := changes[0]
+	assert.Equal(t, mftdiff.Modified, modified.Kind)
+	assert.Equal(t, uint64(65), modified.RecordNumber)
+	assert.Equal(t, "/modified.txt", modified.Old.Path)
+	assert.Equal(t, "/modified.txt", modified.New.Path)
+	assert.Equal(t, []mft.Difference{
+		{Kind: mft.DifferenceChanged, Field: "ActualSize", Old: uint32(312), New: uint32(320)},
+		{Kind: mft.DifferenceChanged, Field: "$DATA.Data", Old: []byte("hello"), New: []byte("hello, world")},
+	}, modified.Differences)
+
+	deleted := changes[1]
+	assert.Equal(t, mftdiff.Deleted, deleted.Kind)
+	assert.Equal(t, "/deleted.txt", deleted.Old.Path)
+	assert.True(t, deleted.Old.InUse)
+	assert.False(t, deleted.New.InUse)
+	assert.Empty(t, deleted.Differences)
+
+	created := changes[2]
+	assert.Equal(t, mftdiff.Created, created.Kind)
+	assert.Equal(t, "was-deleted.txt", created.Old.Name)
+	assert.Equal(t, "/created.txt", created.New.Path)
+	assert.Equal(t, uint16(3), created.New.SequenceNumber)
+
+	reused := changes[3]
+	assert.Equal(t, mftdiff.Reused, reused.Kind)
+	assert.Equal(t, "/old.txt", reused.Old.Path)
+	assert.Equal(t, "/new.txt", reused.New.Path)
+
+	assert.Equal(t, mftdiff.Modified, changes[4].Kind)
+	assert.Equal(t, "$STANDARD_INFORMATION.LastAccess", changes[4].Differences[0].Field)
+
+	appended := changes[5]
+	assert.Equal(t, mftdiff.Created, appended.Kind)
+	assert.Equal(t, uint64(70), appended.RecordNumber)
+	assert.Equal(t, "", appended.Old.Name)
+	assert.Equal(t, "/appended.txt", appended.New.Path)
+}
+
+func TestCompare_Ignore(t *testing.T) {
+	before, after := testDumps()
+	opts := mftdiff.Options{Ignore: mftdiff.IgnoreAccessTimes}
+	numbers := make([]uint64, 0)
+	stats, err := mftdiff.Compare(bytes.NewReader(before), bytes.NewReader(after), opts, func(c mftdiff.Change) error {
+		numbers = append(numbers, c.RecordNumber)
+		return nil
+	})
+	require.Nilf(t, err, "unable to compare: %v", err)
+	assert.Equal(t, []uint64{65, 66, 67, 68, 70}, numbers)
+	assert.Equal(t, 1, stats.Modified)
+}
+
+func TestCompare_Errors(t *testing.T) {
+	before, after := testDumps()
+	after[64*1024+510] ^= 0xFF // breaks the fixup
+	stats, err := mftdiff.Compare(bytes.NewReader(before), bytes.NewReader(after), mftdiff.Options{}, func(c mftdiff.Change) error {
+		return nil
+	})
+	require.Nilf(t, err, "unable to compare: %v", err)
+	assert.Equal(t, 1, stats.Errors)
+	assert.Equal(t, 2, stats.Deleted, "a record which cannot be parsed is treated as not in use")
+
+	expected := errors.New("stop")
+	_, err = mftdiff.Compare(bytes.NewReader(before), bytes.NewReader(after), mftdiff.Options{}, func(c mftdiff.Change) error {
+		return expected
+	})
+	assert.EqualError(t, err, "unable to process record 64: stop")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = mftdiff.CompareContext(ctx, bytes.NewReader(before), bytes.NewReader(after), mftdiff.Options{}, func(c mftdiff.Change) error {
+		return nil
+	})
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestFindReused(t *testing.T) {
+	before, after := testDumps()
+	before = append(before, make([]byte, 71*1024-len(before))...)
+	before = append(before, dump(
+		file(71, 1, "replaced.txt", ""),
+		file(72, 2, "gone.txt", "").WithFlags(0),
+		file(73, 4, "deleted-twice.txt", "").WithFlags(0),
+		file(74, 1, "just-deleted.txt", ""),
+	)[71*1024:]...)
+	after = append(after, dump(
+		file(71, 3, "temporary.txt", "").WithFlags(0),
+		file(72, 2, "gone.txt", "").WithFlags(0),
+		file(73, 6, "also-deleted.txt", "").WithFlags(0),
+		file(74, 2, "just-deleted.txt", "").WithFlags(0),
+	)[71*1024:]...)
+	opts := mftdiff.Options{
+		OldPaths: pipeline.NewPathResolver(bytes.NewReader(before), 1024, 0),
+		NewPaths: pipeline.NewPathResolver(bytes.NewReader(after), 1024, 0),
+	}
+	reuses := make([]mftdiff.Reuse, 0)
+	stats, err := mftdiff.FindReused(bytes.NewReader(before), bytes.NewReader(after), opts, func(r mftdiff.Reuse) error {
+		reuses = append(reuses, r)
+		return nil
+	})
+	require.Nilf(t, err, "unable to find reused records: %v", err)
+	assert.Equal(t, mftdiff.Stats{Records: 12, Reused: 4}, stats)
+
+	numbers := make([]uint64, 0)
+	for _, r := range reuses {
+		numbers = append(numbers, r.RecordNumber)
+	}
+	assert.Equal(t, []uint64{67, 68, 71, 73}, numbers)
+	assert.Equal(t, "was-deleted.txt", reuses[0].Old.Name)
+	assert.Equal(t, "/created.txt", reuses[0].New.Path)
+	assert.Equal(t, "/old.txt", reuses[1].Old.Path)
+	assert.Equal(t, "/new.txt", reuses[1].New.Path)
+	assert.Equal(t, "/replaced.txt", reuses[2].Old.Path)
+	assert.Equal(t, uint16(1), reuses[2].Old.SequenceNumber)
+	assert.Equal(t, "temporary.txt", reuses[2].New.Name)
+	assert.Equal(t, uint16(3), reuses[2].New.SequenceNumber)
+	assert.Equal(t, "deleted-twice.txt", reuses[3].Old.Name)
+	assert.Equal(t, "also-deleted.txt", reuses[3].New.Name)
+
+	expected := errors.New("stop")
+	_, err = mftdiff.FindReused(bytes.NewReader(before), bytes.NewReader(after), mftdiff.Options{}, func(r mftdiff.Reuse) error {
+		return expected
+	})
+	assert.EqualError(t, err, "unable to process record 67: stop")
+}
@@ -0,0 +1,86 @@
+package mftdiff
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/t9t/gomft/export"
+	"github.com/t9t/gomft/mft"
+)
+
+// Reuse describes a record slot which was reused between the old and the new dump: the file in the old dump was
+// destroyed, and another file was created in its place. Old and New are created like those of a Change, so they
+// contain the names (and paths, when resolved) of both files as far as they are still present in the records.
+type Reuse struct {
+	RecordNumber uint64
+	Old          export.Entry
+	New          export.Entry
+}
+
+// FindReused reads the records of the old dump a and the new dump b, compares the sequence numbers of each record
+// slot, and calls fn for each slot which was reused in between, in order of the record number. Of the Stats, only
+// Records, Reused and Errors are counted.
+//
+// Since NTFS increments the sequence number of a record when the file it represents is deleted, the sequence numbers
+// reveal reuse which Compare does not report as such. A slot which is in use in both dumps is reused when the sequence
+// numbers differ, as reported by Compare. A slot which is in use in the old dump only is reused when the sequence
+// number in the new dump was not merely incremented by deleting the old file; the new dump then contains the remains
+// of another, deleted file. A slot which is in use in the new dump only is reused when the old dump still contains the
+// name of a deleted file, since its remains were overwritten. A slot which is not in use in either dump is reused when
+// the sequence numbers differ, since another file was created and deleted in between.
+// A slot which is only present in one of the dumps is not reported.
+func FindReused(a, b io.Reader, opts Options, fn func(r Reuse) error) (Stats, error) {
+	return FindReusedContext(context.Background(), a, b, opts, fn)
+}
+
+// FindReusedContext works like FindReused, but also stops when ctx is done, returning ctx.Err() and the counts of the
+// records compared until then.
+func FindReusedContext(ctx context.Context, a, b io.Reader, opts Options, fn func(r Reuse) error) (Stats, error) {
+	stats := Stats{}
+	err := walk(ctx, a, b, opts, &stats, func(index int, a *mft.RecordResult, b *mft.RecordResult) error {
+		stats.Records++
+		if a == nil || b == nil || !reused(&a.Record, &b.Record) {
+			return nil
+		}
+		r := Reuse{RecordNumber: uint64(index)}
+		var err error
+		if r.Old, err = entry(a, opts.OldPaths); err != nil {
+			return err
+		}
+		if r.New, err = entry(b, opts.NewPaths); err != nil {
+			return err
+		}
+		stats.Reused++
+		if err := fn(r); err != nil {
+			return fmt.Errorf("unable to process record %d: %v", index, err)
+		}
+		return nil
+	})
+	return stats, err
+}
+
+// reused returns true if the record slot is in use by another file in the new record b than in the old record a.
+func reused(a, b *mft.Record) bool {
+	oldSequence, newSequence := a.FileReference.SequenceNumber, b.FileReference.SequenceNumber
+	switch {
+	case a.IsInUse() && b.IsInUse():
+		return oldSequence != newSequence
+	case a.IsInUse():
+		return newSequence != oldSequence && newSequence != nextSequenceNumber(oldSequence)
+	case b.IsInUse():
+		_, named := a.FindFirstAttribute(mft.AttributeTypeFileName)
+		return named
+	default:
+		return oldSequence != newSequence
+	}
+}
+
+// nextSequenceNumber returns the sequence number following s, which skips 0 when it wraps around.
+func nextSequenceNumber(s uint16) uint16 {
+	s++
+	if s == 0 {
+		s = 1
+	}
+	return s
+}
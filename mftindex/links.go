@@ -0,0 +1,113 @@
+package mftindex
+
+import (
+	"errors"
+	"strings"
+)
+
+// LinkMode determines how LookupWithOptions treats symbolic links and mount points (junctions) in a path.
+type LinkMode int
+
+const (
+	// LinksExposed treats links as entries of their own, like Lookup: a link at the end of the path is found, but the
+	// path cannot continue through it. This suits consumers such as backups, which store the links themselves.
+	LinksExposed LinkMode = iota
+	// LinksFollowed follows links transparently, like LookupFollowingLinks.
+	LinksFollowed
+	// LinksBlocked refuses any path containing a link, including a link at the end of the path, for consumers which
+	// must not be redirected, such as forensic tools resolving paths given by untrusted input.
+	LinksBlocked
+)
+
+// String returns the name of the LinkMode, for example "followed".
+func (m LinkMode) String() string {
+	switch m {
+	case LinksExposed:
+		return "exposed"
+	case LinksFollowed:
+		return "followed"
+	case LinksBlocked:
+		return "blocked"
+	}
+	return "unknown"
+}
+
+// LookupOptions configures LookupWithOptions.
+type LookupOptions struct {
+	// Links determines how symbolic links and mount points in the path are treated.
+	Links LinkMode
+}
+
+// Errors returned by LookupWithOptions.
+var (
+	ErrNotFound    = errors.New("no such file or directory")
+	ErrLinkBlocked = errors.New("path contains a symbolic link or mount point")
+	ErrLinkLoop    = errors.New("too many levels of symbolic links")
+	ErrLinkTarget  = errors.New("link target is not on the indexed volume")
+)
+
+// LookupWithOptions finds the record number of the file or directory at the specified path like Lookup does, treating
+// symbolic links and mount points as configured by the options. In all modes, "." and ".." elements are resolved
+// along the way. When links are followed, a link which is encountered again with the same remaining path (so
+// resolving would never end), or a chain of more than 63 links, results in ErrLinkLoop.
+func (idx *Index) LookupWithOptions(path string, opts LookupOptions) (uint64, error) {
+	type visit struct {
+		dir, link uint64
+		rest      string
+	}
+	visited := make(map[visit]bool)
+	dirs := []uint64{RootRecordNumber}
+	names := splitPath(path)
+	hops := 0
+	for len(names) > 0 {
+		name := names[0]
+		names = names[1:]
+		switch name {
+		case ".":
+			continue
+		case "..":
+			if len(dirs) > 1 {
+				dirs = dirs[:len(dirs)-1]
+			}
+			continue
+		}
+
+		dir := dirs[len(dirs)-1]
+		next, ok := idx.lookupChild(dir, name)
+		if !ok {
+			return 0, ErrNotFound
+		}
+		r, _ := idx.Record(next)
+		if r.LinkTarget == nil || (opts.Links == LinksExposed && len(names) == 0) {
+			dirs = append(dirs, next)
+			continue
+		}
+		switch opts.Links {
+		case LinksBlocked:
+			return 0, ErrLinkBlocked
+		case LinksExposed:
+			// A link has no children in the index
+			return 0, ErrNotFound
+		}
+
+		v := visit{dir: dir, link: next, rest: strings.Join(names, "/")}
+		hops++
+		if visited[v] || hops > maxLinkHops {
+			return 0, ErrLinkLoop
+		}
+		visited[v] = true
+		targetNames, absolute, ok := linkTargetNames(*r.LinkTarget)
+		if !ok {
+			return 0, ErrLinkTarget
+		}
+		if absolute {
+			dirs = dirs[:1]
+		}
+		names = append(targetNames, names...)
+	}
+	current := dirs[len(dirs)-1]
+	if _, ok := idx.Record(current); !ok {
+		return 0, ErrNotFound
+	}
+	return current, nil
+}
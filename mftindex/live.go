@@ -0,0 +1,236 @@
+package mftindex
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/usn"
+)
+
+// Live is an Index which is kept up to date by applying the records of the USN journal, so a long running program can
+// keep a near real time view of the files of a volume without reading the MFT again. Unlike Index, a Live is safe for
+// concurrent use by a goroutine applying changes and any number of goroutines reading it.
+//
+// The USN journal does not contain everything that is in the MFT: the names, parents, creations, deletions and whether
+// a record is a directory are kept up to date, but the sizes and link targets of records are not updated.
+type Live struct {
+	mu  sync.RWMutex
+	idx *Index
+	usn int64
+}
+
+// NewLive creates a Live from an Index, for example built from a dump of the MFT taken just before reading the USN
+// journal. The Live takes ownership of the Index, so the Index should not be used anymore afterwards.
+func NewLive(idx *Index) *Live {
+	return &Live{idx: idx}
+}
+
+// Apply updates the index with the changes described by the USN records, which must be in the order of the journal.
+// For each record:
+//   - when ReasonFileCreate is set and the index does not contain the record with the same sequence number, a new
+//     record is added, replacing any previous record with the same record number;
+//   - when ReasonRenameOldName is set, the name is removed from the parent directory;
+//   - when ReasonHardLinkChange and ReasonClose are set, the name is removed when it exists, and added otherwise;
+//   - in all other cases, the name is added to the parent directory if it is not present yet, and when ReasonFileDelete
+//     is set the record is marked as not in use.
+//
+// Changes to records which are not in the index (or have another sequence number) add such a record, so the index
+// catches up with files created after the MFT was read.
+func (l *Live) Apply(records ...usn.Record) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, r := range records {
+		l.idx.apply(r)
+		if r.Usn > l.usn {
+			l.usn = r.Usn
+		}
+	}
+}
+
+// Usn returns the highest USN of the records applied so far, or 0 if none were applied. Reading the journal can be
+// resumed from the next USN.
+func (l *Live) Usn() int64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.usn
+}
+
+// Len returns the number of records in the index, like Index.Len.
+func (l *Live) Len() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.idx.Len()
+}
+
+// Record returns the Record with the specified record number, like Index.Record.
+func (l *Live) Record(number uint64) (Record, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	r, ok := l.idx.Record(number)
+	// Copy the links, since Apply modifies them in place
+	r.Links = append([]Link(nil), r.Links...)
+	return r, ok
+}
+
+// Children returns the entries of a directory, like Index.Children.
+func (l *Live) Children(number uint64) []Child {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.idx.Children(number)
+}
+
+// Path resolves the full path of a record, like Index.Path.
+func (l *Live) Path(number uint64) (string, bool, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.idx.Path(number)
+}
+
+// Lookup finds the record number of the file or directory at the specified path, like Index.Lookup.
+func (l *Live) Lookup(path string) (uint64, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.idx.Lookup(path)
+}
+
+// LookupFollowingLinks finds the record number of the file or directory at the specified path, following symbolic
+// links and mount points, like Index.LookupFollowingLinks.
+func (l *Live) LookupFollowingLinks(path string) (uint64, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.idx.LookupFollowingLinks(path)
+}
+
+// LookupWithOptions finds the record number of the file or directory at the specified path, treating symbolic links
+// and mount points as configured, like Index.LookupWithOptions.
+func (l *Live) LookupWithOptions(path string, opts LookupOptions) (uint64, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.idx.LookupWithOptions(path, opts)
+}
+
+func (idx *Index) apply(r usn.Record) {
+	number := r.FileReference.RecordNumber
+	link := Link{Parent: r.ParentFileReference, Name: r.FileName}
+	if existing, ok := idx.Record(number); !ok || existing.Reference.SequenceNumber != r.FileReference.SequenceNumber {
+		if r.Reason.Is(usn.ReasonRenameOldName) || r.Reason.Is(usn.ReasonHardLinkChange) {
+			// Without the record, there is nothing to remove and it is unknown whether the link is added or removed
+			return
+		}
+		// New directories inherit the case sensitivity of their parent
+		parent, _ := idx.Record(r.ParentFileReference.RecordNumber)
+		idx.replace(number, Record{Reference: r.FileReference, InUse: true, Directory: r.IsDirectory(),
+			CaseSensitive: r.IsDirectory() && parent.CaseSensitive})
+	}
+
+	record := &idx.records[number]
+	switch {
+	case r.Reason.Is(usn.ReasonRenameOldName):
+		idx.removeLink(number, link)
+	case r.Reason.Is(usn.ReasonHardLinkChange):
+		if r.Reason.Is(usn.ReasonClose) {
+			if !idx.removeLink(number, link) {
+				idx.addLink(number, link)
+			}
+		}
+	default:
+		idx.addLink(number, link)
+		if r.Reason.Is(usn.ReasonFileDelete) && record.InUse {
+			record.InUse = false
+			for _, l := range record.Links {
+				// Re-add the children, so they are sorted after entries with the same name which are in use
+				if idx.removeChild(l.Parent.RecordNumber, number, l.Name) {
+					idx.addChild(l.Parent, number, l.Name, false)
+				}
+			}
+		}
+	}
+}
+
+// replace sets the record with the specified number, removing the names of the record it replaces from their
+// directories, as well as the entries of the replaced record if it was a directory.
+func (idx *Index) replace(number uint64, r Record) {
+	if number >= uint64(len(idx.records)) {
+		size := number + 1
+		if grown := uint64(len(idx.records)) * 2; grown > size {
+			size = grown
+		}
+		records := make([]Record, size)
+		copy(records, idx.records)
+		present := make([]bool, size)
+		copy(present, idx.present)
+		idx.records, idx.present = records, present
+	}
+	if idx.present[number] {
+		for _, l := range idx.records[number].Links {
+			idx.removeChild(l.Parent.RecordNumber, number, l.Name)
+		}
+		delete(idx.children, number)
+	}
+	idx.records[number] = r
+	idx.present[number] = true
+}
+
+// addLink adds the name to the record and its directory, unless the record already has that name.
+func (idx *Index) addLink(number uint64, link Link) {
+	record := &idx.records[number]
+	for _, l := range record.Links {
+		if l == link {
+			return
+		}
+	}
+	record.Links = append(record.Links, link)
+	idx.addChild(link.Parent, number, link.Name, record.InUse)
+}
+
+// removeLink removes the name from the record and its directory. It returns false if the record does not have the
+// name.
+func (idx *Index) removeLink(number uint64, link Link) bool {
+	record := &idx.records[number]
+	for i, l := range record.Links {
+		if l == link {
+			record.Links = append(record.Links[:i:i], record.Links[i+1:]...)
+			idx.removeChild(link.Parent.RecordNumber, number, link.Name)
+			return true
+		}
+	}
+	return false
+}
+
+// addChild adds an entry to the directory, in the same order as Build sorts them. Like in Build, nothing is added when
+// the directory does not exist (anymore).
+func (idx *Index) addChild(parent mft.FileReference, number uint64, name string, inUse bool) {
+	if number == RootRecordNumber && parent.RecordNumber == RootRecordNumber {
+		return
+	}
+	if pr, ok := idx.Record(parent.RecordNumber); !ok || pr.Reference.SequenceNumber != parent.SequenceNumber {
+		return
+	}
+	c := child{Child: Child{RecordNumber: number, Name: name}, upper: strings.ToUpper(name), inUse: inUse}
+	children := idx.children[parent.RecordNumber]
+	i := sort.Search(len(children), func(i int) bool {
+		if children[i].upper != c.upper {
+			return children[i].upper > c.upper
+		}
+		return !children[i].inUse && c.inUse
+	})
+	children = append(children, child{})
+	copy(children[i+1:], children[i:])
+	children[i] = c
+	idx.children[parent.RecordNumber] = children
+}
+
+// removeChild removes the entry of the record with the name from the directory. It returns false if there is no such
+// entry.
+func (idx *Index) removeChild(parent uint64, number uint64, name string) bool {
+	children := idx.children[parent]
+	for i, c := range children {
+		if c.RecordNumber == number && c.Name == name {
+			idx.children[parent] = append(children[:i], children[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+package mftindex_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/mftindex"
+	"github.com/t9t/gomft/mfttest"
+	"github.com/t9t/gomft/usn"
+)
+
+func change(number uint64, sequenceNumber uint16, parent uint64, parentSequenceNumber uint16, name string, reason usn.Reason) usn.Record {
+	return usn.Record{
+		FileReference:       mft.FileReference{RecordNumber: number, SequenceNumber: sequenceNumber},
+		ParentFileReference: mft.FileReference{RecordNumber: parent, SequenceNumber: parentSequenceNumber},
+		FileName:            name,
+		Reason:              reason,
+	}
+}
+
+func TestLive_Create(t *testing.T) {
+	live := mftindex.NewLive(testIndex())
+	dir := change(50, 1, 5, 5, "Users", usn.ReasonFileCreate)
+	dir.FileAttributes = 0x10
+	dir.Usn = 100
+	file := change(51, 4, 50, 1, "notes.txt", usn.ReasonFileCreate|usn.ReasonClose)
+	file.Usn = 101
+	live.Apply(dir, change(50, 1, 5, 5, "Users", usn.ReasonFileCreate|usn.ReasonClose), file)
+
+	assert.Equal(t, int64(101), live.Usn())
+	assert.Equal(t, 9, live.Len())
+	r, ok := live.Record(50)
+	require.True(t, ok)
+	assert.True(t, r.Directory)
+	assert.True(t, r.InUse)
+	assert.Equal(t, []mftindex.Link{{Parent: mft.FileReference{RecordNumber: 5, SequenceNumber: 5}, Name: "Users"}}, r.Links)
+
+	number, ok := live.Lookup("/users/NOTES.TXT")
+	assert.True(t, ok)
+	assert.Equal(t, uint64(51), number)
+	path, complete, err := live.Path(51)
+	require.Nilf(t, err, "unable to resolve path: %v", err)
+	assert.True(t, complete)
+	assert.Equal(t, "/Users/notes.txt", path)
+	assert.Equal(t, []mftindex.Child{
+		{RecordNumber: 41, Name: "Program Files"},
+		{RecordNumber: 50, Name: "Users"},
+		{RecordNumber: 30, Name: "Windows"},
+	}, live.Children(mftindex.RootRecordNumber))
+}
+
+func TestLive_Rename(t *testing.T) {
+	live := mftindex.NewLive(testIndex())
+	live.Apply(
+		change(41, 1, 5, 5, "Program Files", usn.ReasonRenameOldName),
+		change(41, 1, 30, 1, "Programs", usn.ReasonRenameNewName),
+		change(41, 1, 30, 1, "Programs", usn.ReasonRenameNewName|usn.ReasonClose),
+	)
+
+	_, ok := live.Lookup("/Program Files")
+	assert.False(t, ok)
+	number, ok := live.Lookup("/Windows/Programs")
+	assert.True(t, ok)
+	assert.Equal(t, uint64(41), number)
+	r, _ := live.Record(41)
+	assert.Equal(t, 1, len(r.Links))
+	assert.Equal(t, []mftindex.Child{{RecordNumber: 30, Name: "Windows"}}, live.Children(mftindex.RootRecordNumber))
+}
+
+func TestLive_Delete(t *testing.T) {
+	live := mftindex.NewLive(testIndex())
+	live.Apply(
+		change(40, 2, 30, 1, "write.exe", usn.ReasonHardLinkChange),
+		change(40, 2, 30, 1, "write.exe", usn.ReasonHardLinkChange|usn.ReasonClose),
+		change(40, 2, 30, 1, "notepad.exe", usn.ReasonFileDelete|usn.ReasonClose),
+	)
+
+	r, ok := live.Record(40)
+	require.True(t, ok)
+	assert.False(t, r.InUse)
+	assert.Equal(t, "notepad.exe", r.Name())
+	assert.Equal(t, 1, len(r.Links), "hard link should be removed")
+	assert.Equal(t, []mftindex.Child{
+		{RecordNumber: 40, Name: "notepad.exe"},
+		{RecordNumber: 42, Name: "old.txt"},
+	}, live.Children(30))
+
+	// A new hard link
+	live.Apply(change(41, 1, 30, 1, "link", usn.ReasonHardLinkChange|usn.ReasonClose))
+	number, ok := live.Lookup("/Windows/link")
+	assert.True(t, ok)
+	assert.Equal(t, uint64(41), number)
+}
+
+func TestLive_Reuse(t *testing.T) {
+	live := mftindex.NewLive(testIndex())
+	// The Windows directory is deleted and its record reused for a file in the root directory
+	live.Apply(change(30, 2, 5, 5, "new.txt", usn.ReasonFileCreate))
+
+	r, ok := live.Record(30)
+	require.True(t, ok)
+	assert.Equal(t, mft.FileReference{RecordNumber: 30, SequenceNumber: 2}, r.Reference)
+	assert.False(t, r.Directory)
+	assert.Equal(t, "new.txt", r.Name())
+	assert.Empty(t, live.Children(30))
+	_, ok = live.Lookup("/Windows")
+	assert.False(t, ok)
+	number, _ := live.Lookup("/new.txt")
+	assert.Equal(t, uint64(30), number)
+	path, complete, _ := live.Path(40)
+	assert.False(t, complete)
+	assert.Equal(t, "/$Orphan/notepad.exe", path)
+
+	// Changes of a record which is not in the index yet add it
+	live.Apply(change(60, 1, 5, 5, "missed.txt", usn.ReasonDataExtend|usn.ReasonClose))
+	number, ok = live.Lookup("/missed.txt")
+	assert.True(t, ok)
+	assert.Equal(t, uint64(60), number)
+
+	// Unless it is unknown what the change means
+	live.Apply(change(61, 1, 5, 5, "gone.txt", usn.ReasonRenameOldName))
+	_, ok = live.Record(61)
+	assert.False(t, ok)
+}
+
+func TestLive_CreateInCaseSensitiveDirectory(t *testing.T) {
+	caseSensitive := mft.Attribute{Type: mft.AttributeTypeEA, Resident: true, Data: mfttest.EncodeExtendedAttributes(
+		[]mft.ExtendedAttribute{{Name: mft.WSLExtendedAttributeCaseSensitive, Value: []byte{1, 0, 0, 0}}})}
+	b := mftindex.NewBuilder()
+	b.Add(5, record(5, true, link(5, 5, mft.FileNameNamespaceWin32Dos, ".")))
+	b.Add(30, record(1, true, link(5, 5, mft.FileNameNamespacePosix, "src"), caseSensitive))
+	live := mftindex.NewLive(b.Build())
+
+	dir := change(50, 1, 30, 1, "lib", usn.ReasonFileCreate)
+	dir.FileAttributes = 0x10
+	live.Apply(dir, change(51, 1, 5, 5, "docs", usn.ReasonFileCreate), change(52, 1, 30, 1, "file", usn.ReasonFileCreate))
+	r, _ := live.Record(50)
+	assert.True(t, r.CaseSensitive)
+	r, _ = live.Record(51)
+	assert.False(t, r.CaseSensitive)
+	r, _ = live.Record(52)
+	assert.False(t, r.CaseSensitive, "files are not case sensitive directories")
+}
@@ -0,0 +1,481 @@
+/*
+	Package mftindex provides an in-memory index over the records of an MFT, to quickly look up records by record number,
+	list the contents of directories and resolve paths, without scanning all records over and over again.
+
+	Basic usage
+
+	Add all records of an MFT to a Builder, then call Build() to obtain an Index. The Index is immutable, so it is safe
+	for concurrent use.
+			// Error handling left out for brevity
+			b := mftindex.NewBuilder()
+			for result := range mft.ParseAll(in, mft.ParseAllOptions{}) {
+				if result.Err == nil {
+					b.Add(uint64(result.Index), result.Record)
+				}
+			}
+			idx := b.Build()
+			path, _, err := idx.Path(1234)                   // eg. "/Windows/notepad.exe"
+			number, ok := idx.Lookup("/windows/NOTEPAD.EXE") // 1234, true
+
+	Implementation notes
+
+	Records are stored in a slice by record number, so looking up a record is O(1). The children of each directory are
+	kept sorted by their upper case name, so listing a directory needs no sorting and looking up a name in a directory is
+	O(log n). Resolving a path is done by following parent references up to the root directory (record 5).
+
+	Only the names from $FILE_NAME attributes are indexed. When a record has both a long name and a DOS (8.3) name, the
+	DOS name is left out. A record with hard links has multiple names and appears in multiple directories. A name's
+	parent reference also contains the sequence number of the parent; when that does not match the sequence number of
+	the parent record (because the directory was deleted and its record reused), the name is considered orphaned.
+
+	For symbolic links and mount points, the target from the $REPARSE_POINT attribute is kept in the Record.
+	LookupFollowingLinks follows them, resolving relative targets from the directory containing the link. Since the
+	drive letter of the indexed volume is unknown, targets with any drive letter are resolved on the indexed volume.
+	LookupWithOptions lets the caller choose whether links are exposed as entries of their own, followed, or refused
+	altogether, and reports why a lookup failed, including loops of links.
+
+	Directories are case insensitive, unless they are marked as case sensitive, as WSL does for the directories it
+	creates. Lookups in such directories only match names of the same case; they may contain multiple names which only
+	differ in case.
+
+	DiskUsage aggregates the sizes of all files per directory, like du, using the sizes of the $DATA attributes kept for
+	each record. It does not read any file contents, so it only needs the MFT.
+
+	A long running program can keep an Index up to date without reading the MFT again by wrapping it in a Live and
+	applying the records of the USN journal (see package usn) to it. Live updates the records, names and directory
+	listings in place under a lock, so it can be read while changes are applied.
+
+	To search for files by name anywhere in the MFT, create a NameIndex using Index.NameIndex(). It keeps all names in
+	upper case in one sorted slice, so exact names are found using a binary search. Patterns such as "*.exe" are
+	matched against the already upper cased names, and only against names with the pattern's literal prefix, if any.
+*/
+package mftindex
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/t9t/gomft/mft"
+)
+
+// RootRecordNumber is the record number of the root directory of an NTFS volume.
+const RootRecordNumber = 5
+
+// OrphanPrefix is the prefix of paths of records whose parent directory cannot be found anymore.
+const OrphanPrefix = "/$Orphan"
+
+// ExtendRecordNumber is the record number of the $Extend directory, which contains the metadata files added in NTFS 3.0.
+const ExtendRecordNumber = 11
+
+// DeletedDirectoryName is the name of the directory in $Extend to which Windows 10 and later move files which are
+// deleted using POSIX semantics while they are still open. Such files are pending deletion: they are not visible in
+// their original directory anymore, but their record stays in use until the last handle to them is closed.
+const DeletedDirectoryName = "$Deleted"
+
+// maxDepth limits the number of parents followed when resolving a path, which protects against corrupt data
+// containing cycles.
+const maxDepth = 1024
+
+// maxLinkHops limits the number of symbolic links and mount points followed by LookupFollowingLinks, which protects
+// against links pointing to themselves. Windows uses the same limit.
+const maxLinkHops = 63
+
+// Record contains the details of an MFT record kept by the Index. For symbolic links and mount points (junctions),
+// LinkTarget contains the target of the link; it is nil for all other records. The Size and AllocatedSize are the
+// total of all $DATA attributes (the unnamed stream and any alternate data streams) of the record. CaseSensitive is
+// set for directories whose names are case sensitive (see mft.IsCaseSensitiveDirectory).
+type Record struct {
+	Reference     mft.FileReference
+	InUse         bool
+	Directory     bool
+	CaseSensitive bool
+	Links         []Link
+	LinkTarget    *mft.LinkTarget
+	Size          uint64
+	AllocatedSize uint64
+}
+
+// Link is a name of a record in a directory, as found in a $FILE_NAME attribute of the record.
+type Link struct {
+	Parent mft.FileReference
+	Name   string
+}
+
+// Name returns the name of the first Link of the Record, or an empty string if the Record has no names.
+func (r Record) Name() string {
+	if len(r.Links) == 0 {
+		return ""
+	}
+	return r.Links[0].Name
+}
+
+// Child is an entry in a directory listing.
+type Child struct {
+	RecordNumber uint64
+	Name         string
+}
+
+type child struct {
+	Child
+	upper string
+	inUse bool
+}
+
+// Builder collects records to create an Index from. A Builder is not safe for concurrent use.
+type Builder struct {
+	records []Record
+	present []bool
+}
+
+// NewBuilder creates an empty Builder.
+func NewBuilder() *Builder {
+	return &Builder{}
+}
+
+// Add adds a record to the Builder. The number is the record number, the position of the record in the MFT (which is
+// also stored in the record header since NTFS 3.1). When the record is an extension record, its names are added to
+// its base record instead.
+func (b *Builder) Add(number uint64, r mft.Record) {
+	links := recordLinks(r)
+	linkTarget := recordLinkTarget(r)
+	size, allocatedSize := recordSizes(r)
+	if base := r.BaseRecordReference.RecordNumber; base != 0 && base != number {
+		if len(links) > 0 || linkTarget != nil || allocatedSize > 0 {
+			target := b.record(base)
+			target.Links = append(target.Links, links...)
+			if linkTarget != nil {
+				target.LinkTarget = linkTarget
+			}
+			target.Size += size
+			target.AllocatedSize += allocatedSize
+		}
+		return
+	}
+
+	target := b.record(number)
+	target.Reference = mft.FileReference{RecordNumber: number, SequenceNumber: r.FileReference.SequenceNumber}
+	target.InUse = r.IsInUse()
+	target.Directory = r.IsDirectory()
+	target.CaseSensitive = mft.IsCaseSensitiveDirectory(r)
+	target.Links = append(target.Links, links...)
+	if linkTarget != nil {
+		target.LinkTarget = linkTarget
+	}
+	target.Size += size
+	target.AllocatedSize += allocatedSize
+	b.present[number] = true
+}
+
+func (b *Builder) record(number uint64) *Record {
+	if number >= uint64(len(b.records)) {
+		size := number + 1
+		if grown := uint64(len(b.records)) * 2; grown > size {
+			size = grown
+		}
+		records := make([]Record, size)
+		copy(records, b.records)
+		present := make([]bool, size)
+		copy(present, b.present)
+		b.records, b.present = records, present
+	}
+	return &b.records[number]
+}
+
+// recordLinks returns the names of the record, leaving out DOS names if the record has other names as well.
+func recordLinks(r mft.Record) []Link {
+	var links, dosLinks []Link
+	for _, fn := range r.AllNames() {
+		link := Link{Parent: fn.ParentFileReference, Name: fn.Name}
+		if fn.Namespace == mft.FileNameNamespaceDos {
+			dosLinks = append(dosLinks, link)
+		} else {
+			links = append(links, link)
+		}
+	}
+	if len(links) == 0 {
+		return dosLinks
+	}
+	return links
+}
+
+// recordSizes returns the total size and allocated size of the $DATA attributes of the record. Only the first part of
+// a non-resident attribute spread over multiple records contains the sizes; the other parts have sizes of 0.
+func recordSizes(r mft.Record) (uint64, uint64) {
+	size, allocatedSize := uint64(0), uint64(0)
+	for _, a := range r.FindAttributes(mft.AttributeTypeData) {
+		if a.Resident {
+			size += uint64(len(a.Data))
+			allocatedSize += uint64(len(a.Data))
+		} else {
+			size += a.ActualSize
+			allocatedSize += a.AllocatedSize
+		}
+	}
+	return size, allocatedSize
+}
+
+// recordLinkTarget returns the target of the record when it is a symbolic link or mount point, or nil otherwise.
+func recordLinkTarget(r mft.Record) *mft.LinkTarget {
+	a, ok := r.FindFirstAttribute(mft.AttributeTypeReparsePoint)
+	if !ok {
+		return nil
+	}
+	rp, err := mft.ParseReparsePoint(a.Data)
+	if err != nil {
+		return nil
+	}
+	target, err := mft.ParseLinkTarget(rp)
+	if err != nil {
+		return nil
+	}
+	return &target
+}
+
+// Build creates the Index from all records added so far. The Builder should not be used anymore afterwards.
+func (b *Builder) Build() *Index {
+	idx := &Index{records: b.records, present: b.present, children: make(map[uint64][]child)}
+	for number := range b.records {
+		if !b.present[number] {
+			continue
+		}
+		r := b.records[number]
+		for _, link := range r.Links {
+			parent := link.Parent.RecordNumber
+			if uint64(number) == RootRecordNumber && parent == RootRecordNumber {
+				continue
+			}
+			if pr, ok := idx.Record(parent); !ok || pr.Reference.SequenceNumber != link.Parent.SequenceNumber {
+				// Orphaned: the parent does not exist (anymore)
+				continue
+			}
+			idx.children[parent] = append(idx.children[parent], child{
+				Child: Child{RecordNumber: uint64(number), Name: link.Name},
+				upper: strings.ToUpper(link.Name),
+				inUse: r.InUse,
+			})
+		}
+	}
+	for _, children := range idx.children {
+		sort.Slice(children, func(i, j int) bool {
+			if children[i].upper != children[j].upper {
+				return children[i].upper < children[j].upper
+			}
+			return children[i].inUse && !children[j].inUse
+		})
+	}
+	b.records, b.present = nil, nil
+	return idx
+}
+
+// Index is an immutable index over the records of an MFT. It is safe for concurrent use.
+type Index struct {
+	records  []Record
+	present  []bool
+	children map[uint64][]child
+}
+
+// Len returns the number of records in the Index.
+func (idx *Index) Len() int {
+	count := 0
+	for _, p := range idx.present {
+		if p {
+			count++
+		}
+	}
+	return count
+}
+
+// Record returns the Record with the specified record number. The boolean is false when no such record was added.
+func (idx *Index) Record(number uint64) (Record, bool) {
+	if number >= uint64(len(idx.records)) || !idx.present[number] {
+		return Record{}, false
+	}
+	return idx.records[number], true
+}
+
+// Children returns the entries of the directory with the specified record number, sorted by name (case insensitive).
+// Entries whose parent reference has a sequence number different from the directory's (ie. entries of a previous,
+// deleted directory in the same record) are left out. A file with hard links may appear multiple times.
+func (idx *Index) Children(number uint64) []Child {
+	children := idx.children[number]
+	ret := make([]Child, len(children))
+	for i, c := range children {
+		ret[i] = c.Child
+	}
+	return ret
+}
+
+// Path resolves the full path of the record with the specified record number, using its first name. Path elements are
+// separated by a forward slash and the root directory is "/". When the chain of parents is broken (because a parent
+// does not exist anymore or its record was reused), the path starts with OrphanPrefix followed by the part of the
+// path that could be resolved, and the boolean is false. An error is returned when the record does not exist or has
+// no name.
+func (idx *Index) Path(number uint64) (string, bool, error) {
+	if number == RootRecordNumber {
+		return "/", true, nil
+	}
+	r, ok := idx.Record(number)
+	if !ok {
+		return "", false, fmt.Errorf("record %d not found", number)
+	}
+	if len(r.Links) == 0 {
+		return "", false, fmt.Errorf("record %d has no name", number)
+	}
+
+	elements := make([]string, 0, 8)
+	link := r.Links[0]
+	for depth := 0; ; depth++ {
+		elements = append(elements, link.Name)
+		parent := link.Parent
+		if parent.RecordNumber == RootRecordNumber {
+			return joinReversed("", elements), true, nil
+		}
+		pr, ok := idx.Record(parent.RecordNumber)
+		if !ok || pr.Reference.SequenceNumber != parent.SequenceNumber || len(pr.Links) == 0 || depth >= maxDepth {
+			return joinReversed(OrphanPrefix, elements), false, nil
+		}
+		link = pr.Links[0]
+	}
+}
+
+// IsPendingDelete indicates whether the record with the specified record number is a file pending deletion: a record in
+// use with a name in $Extend\$Deleted (see DeletedDirectoryName).
+func (idx *Index) IsPendingDelete(number uint64) bool {
+	r, ok := idx.Record(number)
+	if !ok || !r.InUse {
+		return false
+	}
+	for _, l := range r.Links {
+		if idx.isDeletedDirectory(l.Parent) {
+			return true
+		}
+	}
+	return false
+}
+
+func (idx *Index) isDeletedDirectory(ref mft.FileReference) bool {
+	d, ok := idx.Record(ref.RecordNumber)
+	if !ok || !d.Directory || d.Reference.SequenceNumber != ref.SequenceNumber {
+		return false
+	}
+	for _, l := range d.Links {
+		if l.Name == DeletedDirectoryName && l.Parent.RecordNumber == ExtendRecordNumber {
+			return true
+		}
+	}
+	return false
+}
+
+func joinReversed(prefix string, elements []string) string {
+	var sb strings.Builder
+	sb.WriteString(prefix)
+	for i := len(elements) - 1; i >= 0; i-- {
+		sb.WriteByte('/')
+		sb.WriteString(elements[i])
+	}
+	return sb.String()
+}
+
+// Lookup finds the record number of the file or directory at the specified path, starting at the root directory. Both
+// forward slashes and backslashes are accepted as separators and names are compared case insensitively, except in
+// case sensitive directories (see Record.CaseSensitive), where names must match exactly. When there are multiple
+// entries with the same name (for example a deleted and an existing file), the one in use is returned.
+func (idx *Index) Lookup(path string) (uint64, bool) {
+	current := uint64(RootRecordNumber)
+	for _, name := range splitPath(path) {
+		next, ok := idx.lookupChild(current, name)
+		if !ok {
+			return 0, false
+		}
+		current = next
+	}
+	_, ok := idx.Record(current)
+	return current, ok
+}
+
+// LookupFollowingLinks finds the record number of the file or directory at the specified path like Lookup does, but
+// follows symbolic links and mount points (junctions) encountered along the way, including the final element of the
+// path. Relative link targets are resolved from the directory containing the link. Absolute targets with a drive
+// letter (such as C:\Windows) are assumed to be on the indexed volume, since the drive letter of the volume is not
+// known; links to other volumes or network shares cannot be followed and make the lookup fail.
+func (idx *Index) LookupFollowingLinks(path string) (uint64, bool) {
+	dirs := []uint64{RootRecordNumber}
+	names := splitPath(path)
+	hops := 0
+	for len(names) > 0 {
+		name := names[0]
+		names = names[1:]
+		switch name {
+		case ".":
+			continue
+		case "..":
+			if len(dirs) > 1 {
+				dirs = dirs[:len(dirs)-1]
+			}
+			continue
+		}
+
+		next, ok := idx.lookupChild(dirs[len(dirs)-1], name)
+		if !ok {
+			return 0, false
+		}
+		r, _ := idx.Record(next)
+		if r.LinkTarget == nil {
+			dirs = append(dirs, next)
+			continue
+		}
+
+		hops++
+		if hops > maxLinkHops {
+			return 0, false
+		}
+		targetNames, absolute, ok := linkTargetNames(*r.LinkTarget)
+		if !ok {
+			return 0, false
+		}
+		if absolute {
+			dirs = dirs[:1]
+		}
+		names = append(targetNames, names...)
+	}
+	current := dirs[len(dirs)-1]
+	_, ok := idx.Record(current)
+	return current, ok
+}
+
+// linkTargetNames splits the target of a link into path elements. The boolean absolute is true when the elements start
+// at the root directory; ok is false when the target is not on the same volume.
+func linkTargetNames(t mft.LinkTarget) (names []string, absolute bool, ok bool) {
+	target := t.Target()
+	if t.Relative {
+		return splitPath(target), strings.HasPrefix(target, `\`), true
+	}
+	if len(target) < 2 || target[1] != ':' {
+		return nil, false, false
+	}
+	return splitPath(target[2:]), true, true
+}
+
+func splitPath(path string) []string {
+	return strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == '\\' })
+}
+
+func (idx *Index) lookupChild(dir uint64, name string) (uint64, bool) {
+	upper := strings.ToUpper(name)
+	children := idx.children[dir]
+	i := sort.Search(len(children), func(i int) bool { return children[i].upper >= upper })
+	if i >= len(children) || children[i].upper != upper {
+		return 0, false
+	}
+	if d, _ := idx.Record(dir); !d.CaseSensitive {
+		return children[i].RecordNumber, true
+	}
+	// Names which only differ in case are sorted next to each other
+	for ; i < len(children) && children[i].upper == upper; i++ {
+		if children[i].Name == name {
+			return children[i].RecordNumber, true
+		}
+	}
+	return 0, false
+}
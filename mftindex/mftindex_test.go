@@ -0,0 +1,329 @@
+package mftindex_test
+
+import (
+	"encoding/binary"
+	"fmt"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/mftindex"
+	"github.com/t9t/gomft/mfttest"
+)
+
+func TestIndex(t *testing.T) {
+	idx := testIndex()
+
+	assert.Equal(t, 7, idx.Len())
+
+	r, ok := idx.Record(40)
+	require.True(t, ok)
+	assert.Equal(t, "notepad.exe", r.Name())
+	assert.True(t, r.InUse)
+	assert.False(t, r.Directory)
+	assert.Len(t, r.Links, 2, "DOS name should be left out, hard link should be included")
+
+	_, ok = idx.Record(6)
+	assert.False(t, ok)
+	_, ok = idx.Record(1000)
+	assert.False(t, ok)
+}
+
+func TestIndex_Children(t *testing.T) {
+	idx := testIndex()
+
+	assert.Equal(t, []mftindex.Child{
+		{RecordNumber: 41, Name: "Program Files"},
+		{RecordNumber: 30, Name: "Windows"},
+	}, idx.Children(mftindex.RootRecordNumber))
+
+	assert.Equal(t, []mftindex.Child{
+		{RecordNumber: 40, Name: "notepad.exe"},
+		{RecordNumber: 42, Name: "old.txt"},
+		{RecordNumber: 40, Name: "write.exe"},
+	}, idx.Children(30))
+
+	assert.Empty(t, idx.Children(40))
+}
+
+func TestIndex_Path(t *testing.T) {
+	idx := testIndex()
+
+	tests := []struct {
+		number   uint64
+		expected string
+		complete bool
+	}{
+		{mftindex.RootRecordNumber, "/", true},
+		{30, "/Windows", true},
+		{40, "/Windows/notepad.exe", true},
+		{43, "/$Orphan/lost.txt", false},
+		{44, "/$Orphan/stale.txt", false},
+	}
+	for _, tt := range tests {
+		path, complete, err := idx.Path(tt.number)
+		require.Nilf(t, err, "unable to resolve path of %d: %v", tt.number, err)
+		assert.Equal(t, tt.expected, path)
+		assert.Equal(t, tt.complete, complete)
+	}
+
+	_, _, err := idx.Path(999)
+	assert.NotNil(t, err)
+}
+
+func TestIndex_IsPendingDelete(t *testing.T) {
+	b := mftindex.NewBuilder()
+	b.Add(5, record(5, true, link(5, 5, mft.FileNameNamespaceWin32Dos, ".")))
+	b.Add(11, record(11, true, link(5, 5, mft.FileNameNamespaceWin32Dos, "$Extend")))
+	b.Add(30, record(2, true, link(11, 11, mft.FileNameNamespaceWin32Dos, "$Deleted")))
+	b.Add(31, record(1, true, link(5, 5, mft.FileNameNamespaceWin32Dos, "$Deleted")))
+	b.Add(40, record(1, false, link(30, 2, mft.FileNameNamespaceWin32Dos, "0000000000000028A4C1F2E3")))
+	deleted := record(1, false, link(30, 2, mft.FileNameNamespaceWin32Dos, "0000000000000029A4C1F2E3"))
+	deleted.Flags = 0
+	b.Add(41, deleted)
+	// Not in $Extend, or in a previous directory in the same record
+	b.Add(42, record(1, false, link(31, 1, mft.FileNameNamespaceWin32Dos, "a.txt")))
+	b.Add(43, record(1, false, link(30, 1, mft.FileNameNamespaceWin32Dos, "b.txt")))
+	idx := b.Build()
+
+	assert.True(t, idx.IsPendingDelete(40))
+	assert.False(t, idx.IsPendingDelete(41))
+	assert.False(t, idx.IsPendingDelete(42))
+	assert.False(t, idx.IsPendingDelete(43))
+	assert.False(t, idx.IsPendingDelete(99))
+}
+
+func TestIndex_Lookup(t *testing.T) {
+	idx := testIndex()
+
+	tests := []struct {
+		path     string
+		expected uint64
+		found    bool
+	}{
+		{"/", mftindex.RootRecordNumber, true},
+		{"/windows/NOTEPAD.EXE", 40, true},
+		{`\Windows\write.exe`, 40, true},
+		{"Windows/old.txt", 42, true},
+		{"/Windows/missing.txt", 0, false},
+		{"/Windows/notepad.exe/child", 0, false},
+	}
+	for _, tt := range tests {
+		number, found := idx.Lookup(tt.path)
+		assert.Equal(t, tt.expected, number, tt.path)
+		assert.Equal(t, tt.found, found, tt.path)
+	}
+}
+
+func TestIndex_LookupCaseSensitive(t *testing.T) {
+	caseSensitive := mft.Attribute{Type: mft.AttributeTypeEA, Resident: true, Data: mfttest.EncodeExtendedAttributes(
+		[]mft.ExtendedAttribute{{Name: mft.WSLExtendedAttributeCaseSensitive, Value: []byte{1, 0, 0, 0}}})}
+	b := mftindex.NewBuilder()
+	b.Add(5, record(5, true, link(5, 5, mft.FileNameNamespaceWin32Dos, ".")))
+	b.Add(30, record(1, true, link(5, 5, mft.FileNameNamespacePosix, "src"), caseSensitive))
+	b.Add(40, record(1, false, link(30, 1, mft.FileNameNamespacePosix, "Makefile")))
+	b.Add(41, record(1, false, link(30, 1, mft.FileNameNamespacePosix, "makefile")))
+	idx := b.Build()
+
+	r, _ := idx.Record(30)
+	assert.True(t, r.CaseSensitive)
+	r, _ = idx.Record(mftindex.RootRecordNumber)
+	assert.False(t, r.CaseSensitive)
+
+	tests := []struct {
+		path     string
+		expected uint64
+		found    bool
+	}{
+		{"/SRC/Makefile", 40, true},
+		{"/src/makefile", 41, true},
+		{"/src/MAKEFILE", 0, false},
+	}
+	for _, tt := range tests {
+		number, found := idx.Lookup(tt.path)
+		assert.Equal(t, tt.expected, number, tt.path)
+		assert.Equal(t, tt.found, found, tt.path)
+	}
+}
+
+func TestIndex_LookupFollowingLinks(t *testing.T) {
+	b := mftindex.NewBuilder()
+	b.Add(5, record(5, true, link(5, 5, mft.FileNameNamespaceWin32Dos, ".")))
+	b.Add(30, record(1, true, link(5, 5, mft.FileNameNamespaceWin32Dos, "Users")))
+	b.Add(31, record(1, true, link(30, 1, mft.FileNameNamespaceWin32Dos, "alice")))
+	b.Add(32, record(1, false, link(31, 1, mft.FileNameNamespaceWin32Dos, "notes.txt")))
+	b.Add(40, record(1, true, link(5, 5, mft.FileNameNamespaceWin32Dos, "Home"), reparse(mft.ReparseTagMountPoint, `\??\C:\Users`, false)))
+	b.Add(41, record(1, false, link(31, 1, mft.FileNameNamespaceWin32Dos, "latest.txt"), reparse(mft.ReparseTagSymlink, `notes.txt`, true)))
+	b.Add(42, record(1, true, link(31, 1, mft.FileNameNamespaceWin32Dos, "up"), reparse(mft.ReparseTagSymlink, `..\..`, true)))
+	b.Add(43, record(1, true, link(5, 5, mft.FileNameNamespaceWin32Dos, "share"), reparse(mft.ReparseTagSymlink, `\??\UNC\server\share`, false)))
+	b.Add(44, record(1, true, link(5, 5, mft.FileNameNamespaceWin32Dos, "loop"), reparse(mft.ReparseTagSymlink, `loop`, true)))
+	idx := b.Build()
+
+	r, ok := idx.Record(40)
+	require.True(t, ok)
+	require.NotNil(t, r.LinkTarget)
+	assert.Equal(t, `\??\C:\Users`, r.LinkTarget.SubstituteName)
+
+	tests := []struct {
+		path     string
+		expected uint64
+		found    bool
+	}{
+		{"/Users/alice/notes.txt", 32, true},
+		{"/Home/alice/notes.txt", 32, true},
+		{"/home/alice/latest.txt", 32, true},
+		{"/Home/alice/up/Users/alice/./notes.txt", 32, true},
+		{"/Home", 30, true},
+		{"/share/file.txt", 0, false},
+		{"/loop", 0, false},
+		{"/Home/bob", 0, false},
+	}
+	for _, tt := range tests {
+		number, found := idx.LookupFollowingLinks(tt.path)
+		assert.Equal(t, tt.expected, number, tt.path)
+		assert.Equal(t, tt.found, found, tt.path)
+	}
+
+	number, found := idx.Lookup("/Home/alice/latest.txt")
+	assert.False(t, found)
+	assert.Equal(t, uint64(0), number)
+	number, found = idx.Lookup("/Users/alice/latest.txt")
+	assert.True(t, found)
+	assert.Equal(t, uint64(41), number)
+}
+
+func TestIndex_LookupWithOptions(t *testing.T) {
+	b := mftindex.NewBuilder()
+	b.Add(5, record(5, true, link(5, 5, mft.FileNameNamespaceWin32Dos, ".")))
+	b.Add(30, record(1, true, link(5, 5, mft.FileNameNamespaceWin32Dos, "Users")))
+	b.Add(31, record(1, true, link(30, 1, mft.FileNameNamespaceWin32Dos, "alice")))
+	b.Add(32, record(1, false, link(31, 1, mft.FileNameNamespaceWin32Dos, "notes.txt")))
+	b.Add(40, record(1, true, link(5, 5, mft.FileNameNamespaceWin32Dos, "Home"), reparse(mft.ReparseTagMountPoint, `\??\C:\Users`, false)))
+	b.Add(41, record(1, false, link(31, 1, mft.FileNameNamespaceWin32Dos, "latest.txt"), reparse(mft.ReparseTagSymlink, `notes.txt`, true)))
+	b.Add(43, record(1, true, link(5, 5, mft.FileNameNamespaceWin32Dos, "share"), reparse(mft.ReparseTagSymlink, `\??\UNC\server\share`, false)))
+	b.Add(44, record(1, true, link(5, 5, mft.FileNameNamespaceWin32Dos, "loop"), reparse(mft.ReparseTagSymlink, `loop`, true)))
+	b.Add(45, record(1, true, link(5, 5, mft.FileNameNamespaceWin32Dos, "ping"), reparse(mft.ReparseTagSymlink, `pong\x`, true)))
+	b.Add(46, record(1, true, link(5, 5, mft.FileNameNamespaceWin32Dos, "pong"), reparse(mft.ReparseTagSymlink, `ping`, true)))
+	idx := b.Build()
+
+	tests := []struct {
+		path     string
+		mode     mftindex.LinkMode
+		expected uint64
+		err      error
+	}{
+		{"/Users/alice/notes.txt", mftindex.LinksExposed, 32, nil},
+		{"/Users/alice/latest.txt", mftindex.LinksExposed, 41, nil},
+		{"/Users/./alice/../alice/notes.txt", mftindex.LinksExposed, 32, nil},
+		{"/Home", mftindex.LinksExposed, 40, nil},
+		{"/Home/alice", mftindex.LinksExposed, 0, mftindex.ErrNotFound},
+		{"/Users/bob", mftindex.LinksExposed, 0, mftindex.ErrNotFound},
+
+		{"/Home/alice/latest.txt", mftindex.LinksFollowed, 32, nil},
+		{"/Home", mftindex.LinksFollowed, 30, nil},
+		{"/share/file.txt", mftindex.LinksFollowed, 0, mftindex.ErrLinkTarget},
+		{"/loop", mftindex.LinksFollowed, 0, mftindex.ErrLinkLoop},
+		{"/ping", mftindex.LinksFollowed, 0, mftindex.ErrLinkLoop},
+		{"/Home/bob", mftindex.LinksFollowed, 0, mftindex.ErrNotFound},
+
+		{"/Users/alice/notes.txt", mftindex.LinksBlocked, 32, nil},
+		{"/Users/alice/latest.txt", mftindex.LinksBlocked, 0, mftindex.ErrLinkBlocked},
+		{"/Home/alice", mftindex.LinksBlocked, 0, mftindex.ErrLinkBlocked},
+	}
+	for _, tt := range tests {
+		number, err := idx.LookupWithOptions(tt.path, mftindex.LookupOptions{Links: tt.mode})
+		assert.Equal(t, tt.err, err, "%s (%v)", tt.path, tt.mode)
+		assert.Equal(t, tt.expected, number, "%s (%v)", tt.path, tt.mode)
+	}
+}
+
+func BenchmarkIndex_Lookup(b *testing.B) {
+	builder := mftindex.NewBuilder()
+	builder.Add(5, record(5, true, link(5, 5, mft.FileNameNamespaceWin32Dos, ".")))
+	for i := uint64(16); i < 100016; i++ {
+		builder.Add(i, record(1, false, link(5, 5, mft.FileNameNamespaceWin32, fmt.Sprintf("file%d.txt", i))))
+	}
+	idx := builder.Build()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, ok := idx.Lookup("/FILE50000.TXT"); !ok {
+			b.Fatal("not found")
+		}
+	}
+}
+
+func testIndex() *mftindex.Index {
+	b := mftindex.NewBuilder()
+	b.Add(5, record(5, true, link(5, 5, mft.FileNameNamespaceWin32Dos, ".")))
+	b.Add(30, record(1, true, link(5, 5, mft.FileNameNamespaceWin32Dos, "Windows")))
+	b.Add(40, record(2, false,
+		link(30, 1, mft.FileNameNamespaceDos, "NOTEPAD.EXE"),
+		link(30, 1, mft.FileNameNamespaceWin32, "notepad.exe"),
+		link(30, 1, mft.FileNameNamespaceWin32Dos, "write.exe")))
+	b.Add(41, record(1, true,
+		link(5, 5, mft.FileNameNamespaceDos, "PROGRA~1"),
+		link(5, 5, mft.FileNameNamespaceWin32, "Program Files")))
+
+	deleted := record(3, false, link(30, 1, mft.FileNameNamespaceWin32Dos, "old.txt"))
+	deleted.Flags = 0
+	b.Add(42, deleted)
+
+	// Parent does not exist
+	b.Add(43, record(1, false, link(99, 1, mft.FileNameNamespaceWin32Dos, "lost.txt")))
+	// Parent record has been reused (sequence number mismatch)
+	b.Add(44, record(1, false, link(30, 0, mft.FileNameNamespaceWin32Dos, "stale.txt")))
+
+	// Extension record of 41 which has no names, should not show up
+	ext := record(1, false)
+	ext.BaseRecordReference = mft.FileReference{RecordNumber: 41, SequenceNumber: 1}
+	b.Add(45, ext)
+	return b.Build()
+}
+
+func record(sequenceNumber uint16, directory bool, links ...mft.Attribute) mft.Record {
+	flags := mft.RecordFlagInUse
+	if directory {
+		flags |= mft.RecordFlagIsDirectory
+	}
+	return mft.Record{
+		FileReference: mft.FileReference{SequenceNumber: sequenceNumber},
+		Flags:         flags,
+		Attributes:    links,
+	}
+}
+
+// reparse creates a $REPARSE_POINT attribute of a symbolic link or mount point, with an empty print name.
+func reparse(tag mft.ReparseTag, target string, relative bool) mft.Attribute {
+	encoded := utf16.Encode([]rune(target))
+	pathOffset := 8
+	if tag == mft.ReparseTagSymlink {
+		pathOffset = 12
+	}
+	data := make([]byte, 8+pathOffset+len(encoded)*2)
+	binary.LittleEndian.PutUint32(data, uint32(tag))
+	binary.LittleEndian.PutUint16(data[0x04:], uint16(len(data)-8))
+	binary.LittleEndian.PutUint16(data[0x0A:], uint16(len(encoded)*2))
+	binary.LittleEndian.PutUint16(data[0x0C:], uint16(len(encoded)*2))
+	if relative {
+		binary.LittleEndian.PutUint32(data[0x10:], 1)
+	}
+	for i, c := range encoded {
+		binary.LittleEndian.PutUint16(data[8+pathOffset+i*2:], c)
+	}
+	return mft.Attribute{Type: mft.AttributeTypeReparsePoint, Resident: true, Data: data}
+}
+
+func link(parent uint64, parentSequenceNumber uint16, namespace mft.FileNameNamespace, name string) mft.Attribute {
+	encoded := utf16.Encode([]rune(name))
+	data := make([]byte, 0x42+len(encoded)*2)
+	binary.LittleEndian.PutUint64(data, parent|uint64(parentSequenceNumber)<<48)
+	data[0x40] = byte(len(encoded))
+	data[0x41] = byte(namespace)
+	for i, c := range encoded {
+		binary.LittleEndian.PutUint16(data[0x42+i*2:], c)
+	}
+	return mft.Attribute{Type: mft.AttributeTypeFileName, Resident: true, Data: data}
+}
@@ -0,0 +1,138 @@
+package mftindex
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// NameIndex finds records by name, without walking directories or decoding names. It contains all names of all
+// records in an Index, including deleted and orphaned records. A NameIndex is immutable, so it is safe for concurrent
+// use.
+type NameIndex struct {
+	names []nameEntry
+}
+
+type nameEntry struct {
+	upper  string
+	number uint64
+}
+
+// NameIndex creates a NameIndex over all names in the Index. Creating it takes time and memory proportional to the
+// number of names, so it is only worth it when searching for names more than once.
+func (idx *Index) NameIndex() *NameIndex {
+	names := make([]nameEntry, 0, len(idx.records))
+	for number, r := range idx.records {
+		if !idx.present[number] {
+			continue
+		}
+		first := len(names)
+		for _, link := range r.Links {
+			upper := strings.ToUpper(link.Name)
+			if containsName(names[first:], upper) {
+				// A hard link with the same name in another directory
+				continue
+			}
+			names = append(names, nameEntry{upper: upper, number: uint64(number)})
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if names[i].upper != names[j].upper {
+			return names[i].upper < names[j].upper
+		}
+		return names[i].number < names[j].number
+	})
+	return &NameIndex{names: names}
+}
+
+func containsName(names []nameEntry, upper string) bool {
+	for _, n := range names {
+		if n.upper == upper {
+			return true
+		}
+	}
+	return false
+}
+
+// Len returns the number of names in the NameIndex.
+func (n *NameIndex) Len() int {
+	return len(n.names)
+}
+
+// Find returns the numbers of the records with the specified name, compared case insensitively, in ascending order.
+func (n *NameIndex) Find(name string) []uint64 {
+	upper := strings.ToUpper(name)
+	start := n.search(upper)
+	var numbers []uint64
+	for i := start; i < len(n.names) && n.names[i].upper == upper; i++ {
+		numbers = append(numbers, n.names[i].number)
+	}
+	return numbers
+}
+
+// Glob returns the numbers of the records with a name matching the pattern, in which * matches any sequence of
+// characters and ? matches any single character. Names are compared case insensitively. The numbers are returned in
+// ascending order, and a record with multiple matching names is returned only once.
+//
+// When the pattern starts with a literal prefix (such as "report*.doc"), only the names with that prefix are checked;
+// otherwise all names are checked.
+func (n *NameIndex) Glob(pattern string) []uint64 {
+	upper := strings.ToUpper(pattern)
+	prefix := upper
+	if i := strings.IndexAny(upper, "*?"); i >= 0 {
+		prefix = upper[:i]
+	}
+
+	var numbers []uint64
+	seen := make(map[uint64]bool)
+	for i := n.search(prefix); i < len(n.names) && strings.HasPrefix(n.names[i].upper, prefix); i++ {
+		e := n.names[i]
+		if !seen[e.number] && matchGlob(upper, e.upper) {
+			seen[e.number] = true
+			numbers = append(numbers, e.number)
+		}
+	}
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+	return numbers
+}
+
+// search returns the position of the first name that is greater than or equal to upper.
+func (n *NameIndex) search(upper string) int {
+	return sort.Search(len(n.names), func(i int) bool { return n.names[i].upper >= upper })
+}
+
+// matchGlob matches name against a pattern where * matches any sequence of characters and ? matches any single
+// character. When a * fails to match, matching backtracks to let the most recent * consume one more character.
+func matchGlob(pattern, name string) bool {
+	p, s := 0, 0
+	starP, starS := -1, 0
+	for s < len(name) {
+		if p < len(pattern) {
+			switch c, size := utf8.DecodeRuneInString(pattern[p:]); c {
+			case '*':
+				starP, starS = p, s
+				p += size
+				continue
+			case '?':
+				_, nameSize := utf8.DecodeRuneInString(name[s:])
+				p, s = p+size, s+nameSize
+				continue
+			default:
+				if strings.HasPrefix(name[s:], pattern[p:p+size]) {
+					p, s = p+size, s+size
+					continue
+				}
+			}
+		}
+		if starP < 0 {
+			return false
+		}
+		_, nameSize := utf8.DecodeRuneInString(name[starS:])
+		starS += nameSize
+		p, s = starP+1, starS
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
@@ -0,0 +1,85 @@
+package mftindex_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/mftindex"
+)
+
+func TestNameIndex_Find(t *testing.T) {
+	names := testIndex().NameIndex()
+
+	assert.Equal(t, 8, names.Len())
+	assert.Equal(t, []uint64{40}, names.Find("NOTEPAD.exe"))
+	assert.Equal(t, []uint64{40}, names.Find("write.exe"))
+	assert.Equal(t, []uint64{43}, names.Find("lost.txt"), "orphaned records should be included")
+	assert.Equal(t, []uint64{42}, names.Find("old.txt"), "deleted records should be included")
+	assert.Nil(t, names.Find("NOTEPAD"))
+	assert.Nil(t, names.Find("PROGRA~1"), "DOS names are left out by the Index")
+}
+
+func TestNameIndex_Find_Duplicates(t *testing.T) {
+	b := mftindex.NewBuilder()
+	b.Add(5, record(5, true, link(5, 5, mft.FileNameNamespaceWin32Dos, ".")))
+	b.Add(30, record(1, true, link(5, 5, mft.FileNameNamespaceWin32Dos, "a")))
+	b.Add(31, record(1, false, link(5, 5, mft.FileNameNamespaceWin32Dos, "x.txt")))
+	b.Add(32, record(1, false,
+		link(5, 5, mft.FileNameNamespaceWin32Dos, "X.TXT"),
+		link(30, 1, mft.FileNameNamespaceWin32Dos, "x.txt")))
+	names := b.Build().NameIndex()
+
+	assert.Equal(t, []uint64{31, 32}, names.Find("x.txt"))
+	assert.Equal(t, 4, names.Len())
+}
+
+func TestNameIndex_Glob(t *testing.T) {
+	names := testIndex().NameIndex()
+
+	tests := []struct {
+		pattern  string
+		expected []uint64
+	}{
+		{"*.exe", []uint64{40}},
+		{"*.TXT", []uint64{42, 43, 44}},
+		{"?indows", []uint64{30}},
+		{"w*", []uint64{30, 40}},
+		{"*o*s*", []uint64{30, 41, 43}},
+		{"program files", []uint64{41}},
+		{"*", []uint64{5, 30, 40, 41, 42, 43, 44}},
+		{"notepad", nil},
+		{"x*", nil},
+		{"*.exe?", nil},
+	}
+	for _, test := range tests {
+		assert.Equalf(t, test.expected, names.Glob(test.pattern), "pattern: %s", test.pattern)
+	}
+}
+
+func TestNameIndex_Glob_Unicode(t *testing.T) {
+	b := mftindex.NewBuilder()
+	b.Add(5, record(5, true, link(5, 5, mft.FileNameNamespaceWin32Dos, ".")))
+	b.Add(30, record(1, false, link(5, 5, mft.FileNameNamespaceWin32, "überprüfung.doc")))
+	names := b.Build().NameIndex()
+
+	assert.Equal(t, []uint64{30}, names.Glob("?BERPR?FUNG.*"))
+	assert.Equal(t, []uint64{30}, names.Glob("ÜBER*"))
+}
+
+func BenchmarkNameIndex_Glob(b *testing.B) {
+	builder := mftindex.NewBuilder()
+	builder.Add(5, record(5, true, link(5, 5, mft.FileNameNamespaceWin32Dos, ".")))
+	for i := uint64(16); i < 100016; i++ {
+		builder.Add(i, record(1, false, link(5, 5, mft.FileNameNamespaceWin32, fmt.Sprintf("file%d.txt", i))))
+	}
+	names := builder.Build().NameIndex()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if len(names.Glob("file5000?.txt")) != 10 {
+			b.Fatal("not found")
+		}
+	}
+}
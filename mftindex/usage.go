@@ -0,0 +1,57 @@
+package mftindex
+
+// DirectoryUsage contains the sizes of all files in a directory and its subdirectories, like du reports them. Files
+// and Directories count the entries in the subtree, not including the directory itself. Depth is the number of
+// directories between the directory and the directory the aggregation started at (which has depth 0).
+type DirectoryUsage struct {
+	RecordNumber  uint64
+	Depth         int
+	Size          uint64
+	AllocatedSize uint64
+	Files         int
+	Directories   int
+}
+
+// DiskUsage aggregates the sizes of files per directory in the subtree of the directory with the specified record
+// number, using only the names and sizes kept in the Index. The result contains an entry for each directory in the
+// subtree, with the subdirectories of a directory before the directory itself (like du lists them), so the last
+// entry is the total of the whole subtree. It is empty when the record does not exist.
+//
+// Only records which are in use are included. A file with multiple hard links in the subtree is counted once, in the
+// first directory in which it is found. Directories nested deeper than the maximum depth of paths are left out.
+func (idx *Index) DiskUsage(number uint64) []DirectoryUsage {
+	var usages []DirectoryUsage
+	if _, ok := idx.Record(number); !ok {
+		return usages
+	}
+	seen := map[uint64]bool{number: true}
+	idx.diskUsage(number, 0, seen, &usages)
+	return usages
+}
+
+func (idx *Index) diskUsage(number uint64, depth int, seen map[uint64]bool, usages *[]DirectoryUsage) DirectoryUsage {
+	usage := DirectoryUsage{RecordNumber: number, Depth: depth}
+	for _, c := range idx.children[number] {
+		r := idx.records[c.RecordNumber]
+		if !r.InUse || seen[c.RecordNumber] {
+			continue
+		}
+		seen[c.RecordNumber] = true
+		if !r.Directory {
+			usage.Files++
+			usage.Size += r.Size
+			usage.AllocatedSize += r.AllocatedSize
+			continue
+		}
+		if depth+1 >= maxDepth {
+			continue
+		}
+		sub := idx.diskUsage(c.RecordNumber, depth+1, seen, usages)
+		usage.Directories += sub.Directories + 1
+		usage.Files += sub.Files
+		usage.Size += sub.Size
+		usage.AllocatedSize += sub.AllocatedSize
+	}
+	*usages = append(*usages, usage)
+	return usage
+}
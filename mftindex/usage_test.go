@@ -0,0 +1,52 @@
+package mftindex_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/mftindex"
+)
+
+func TestIndex_DiskUsage(t *testing.T) {
+	b := mftindex.NewBuilder()
+	b.Add(5, record(5, true, link(5, 5, mft.FileNameNamespaceWin32Dos, ".")))
+	b.Add(30, record(1, true, link(5, 5, mft.FileNameNamespaceWin32Dos, "Windows")))
+	b.Add(31, record(1, true, link(30, 1, mft.FileNameNamespaceWin32Dos, "System32")))
+	b.Add(40, record(1, false,
+		link(30, 1, mft.FileNameNamespaceWin32Dos, "notepad.exe"),
+		link(31, 1, mft.FileNameNamespaceWin32Dos, "notepad.exe"),
+		data("", 100, 4096)))
+	b.Add(50, record(1, false, link(31, 1, mft.FileNameNamespaceWin32Dos, "kernel32.dll"), data("", 5000, 8192), data("ads", 10, 4096)))
+	b.Add(60, record(1, false, link(5, 5, mft.FileNameNamespaceWin32Dos, "small.txt"),
+		mft.Attribute{Type: mft.AttributeTypeData, Resident: true, Data: make([]byte, 10)}))
+
+	deleted := record(1, false, link(31, 1, mft.FileNameNamespaceWin32Dos, "old.txt"), data("", 1000, 4096))
+	deleted.Flags = 0
+	b.Add(70, deleted)
+
+	// Extension record of 50 containing the first part of a large alternate data stream
+	ext := record(1, false, data("big", 1<<20, 1<<20))
+	ext.BaseRecordReference = mft.FileReference{RecordNumber: 50, SequenceNumber: 1}
+	b.Add(51, ext)
+	idx := b.Build()
+
+	r, _ := idx.Record(50)
+	assert.Equal(t, uint64(5010+1<<20), r.Size)
+	assert.Equal(t, uint64(12288+1<<20), r.AllocatedSize)
+
+	expected := []mftindex.DirectoryUsage{
+		{RecordNumber: 31, Depth: 2, Size: 5010 + 1<<20, AllocatedSize: 12288 + 1<<20, Files: 1},
+		{RecordNumber: 30, Depth: 1, Size: 5110 + 1<<20, AllocatedSize: 16384 + 1<<20, Files: 2, Directories: 1},
+		{RecordNumber: 5, Depth: 0, Size: 5120 + 1<<20, AllocatedSize: 16394 + 1<<20, Files: 3, Directories: 2},
+	}
+	assert.Equal(t, expected, idx.DiskUsage(mftindex.RootRecordNumber))
+
+	// notepad.exe is found in System32 now, as it is not reached through Windows first
+	assert.Equal(t, []mftindex.DirectoryUsage{{RecordNumber: 31, Size: 5110 + 1<<20, AllocatedSize: 16384 + 1<<20, Files: 2}}, idx.DiskUsage(31))
+	assert.Empty(t, idx.DiskUsage(999))
+}
+
+func data(name string, size, allocatedSize uint64) mft.Attribute {
+	return mft.Attribute{Type: mft.AttributeTypeData, Name: name, ActualSize: size, AllocatedSize: allocatedSize}
+}
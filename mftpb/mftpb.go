@@ -0,0 +1,274 @@
+/*
+	Package mftpb encodes parsed MFT records and their attributes as Protocol Buffers messages, so parse results can be
+	exchanged between services and programming languages in a compact, versioned binary form. The schema is in
+	mft.proto, next to this file; use it to generate code for other languages.
+
+	Basic usage
+
+	Use MarshalRecord to encode a parsed record and UnmarshalRecord to decode it again.
+			// Error handling left out for brevity
+			record, err := mft.ParseRecord(b)
+			encoded := mftpb.MarshalRecord(record)
+			decoded, err := mftpb.UnmarshalRecord(encoded)
+
+	MarshalUsnRecord and UnmarshalUsnRecord do the same for records of the USN change journal, as parsed by the usn
+	package.
+
+	To send multiple records over a stream, use WriteDelimited and ReadDelimited, which prefix each message with its
+	length like the writeDelimitedTo and parseDelimitedFrom methods of the Java and C++ implementations.
+
+	Implementation notes
+
+	The encoding is implemented directly on top of the wire format rather than using generated code, so gomft does not
+	depend on the protobuf runtime. It follows proto3: fields with a zero value are left out, and unknown fields are
+	skipped when decoding, so data written by a newer version with additional fields can still be read.
+*/
+package mftpb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/t9t/gomft/binutil"
+	"github.com/t9t/gomft/mft"
+)
+
+// MarshalRecord encodes the record as a Record message.
+func MarshalRecord(r mft.Record) []byte {
+	b := make([]byte, 0, 64+int(r.ActualSize))
+	b = appendBytes(b, 1, r.Signature)
+	b = appendMessage(b, 2, marshalFileReference(r.FileReference))
+	b = appendMessage(b, 3, marshalFileReference(r.BaseRecordReference))
+	b = appendUint(b, 4, r.LogFileSequenceNumber)
+	b = appendUint(b, 5, uint64(r.HardLinkCount))
+	b = appendUint(b, 6, uint64(r.Flags))
+	b = appendUint(b, 7, uint64(r.ActualSize))
+	b = appendUint(b, 8, uint64(r.AllocatedSize))
+	b = appendUint(b, 9, uint64(r.NextAttributeId))
+	b = appendBool(b, 10, r.LegacyHeader)
+	for _, a := range r.Attributes {
+		b = appendMessage(b, 11, MarshalAttribute(a))
+	}
+	return b
+}
+
+// MarshalAttribute encodes the attribute as an Attribute message.
+func MarshalAttribute(a mft.Attribute) []byte {
+	b := make([]byte, 0, 32+len(a.Name)+len(a.Data))
+	b = appendUint(b, 1, uint64(a.Type))
+	b = appendBool(b, 2, a.Resident)
+	b = appendBytes(b, 3, []byte(a.Name))
+	b = appendUint(b, 4, uint64(a.Flags))
+	b = appendUint(b, 5, uint64(a.AttributeId))
+	b = appendUint(b, 6, a.AllocatedSize)
+	b = appendUint(b, 7, a.ActualSize)
+	b = appendBytes(b, 8, a.Data)
+	b = appendUint(b, 9, a.InitializedSize)
+	b = appendUint(b, 10, uint64(a.CompressionUnit))
+	b = appendUint(b, 11, a.TotalAllocatedSize)
+	return b
+}
+
+func marshalFileReference(f mft.FileReference) []byte {
+	b := make([]byte, 0, 16)
+	b = appendUint(b, 1, f.RecordNumber)
+	b = appendUint(b, 2, uint64(f.SequenceNumber))
+	return b
+}
+
+// UnmarshalRecord decodes a Record message. Byte slices in the result are copies, they do not alias the input.
+func UnmarshalRecord(b []byte) (mft.Record, error) {
+	r := mft.Record{Attributes: make([]mft.Attribute, 0)}
+	d := decoder{b: b}
+	for {
+		field, wireType, ok, err := d.next()
+		if err != nil {
+			return mft.Record{}, err
+		}
+		if !ok {
+			return r, nil
+		}
+		switch field {
+		case 1:
+			var v []byte
+			if v, err = bytesField(&d, field, wireType); err == nil {
+				r.Signature = binutil.Duplicate(v)
+			}
+		case 2, 3:
+			var v []byte
+			if v, err = bytesField(&d, field, wireType); err == nil {
+				var ref mft.FileReference
+				if ref, err = unmarshalFileReference(v); err != nil {
+					err = fmt.Errorf("unable to decode field %d: %v", field, err)
+				} else if field == 2 {
+					r.FileReference = ref
+				} else {
+					r.BaseRecordReference = ref
+				}
+			}
+		case 4, 5, 6, 7, 8, 9, 10:
+			var v uint64
+			if v, err = uintField(&d, field, wireType); err == nil {
+				switch field {
+				case 4:
+					r.LogFileSequenceNumber = v
+				case 5:
+					r.HardLinkCount = int(v)
+				case 6:
+					r.Flags = mft.RecordFlag(v)
+				case 7:
+					r.ActualSize = uint32(v)
+				case 8:
+					r.AllocatedSize = uint32(v)
+				case 9:
+					r.NextAttributeId = int(v)
+				case 10:
+					r.LegacyHeader = v != 0
+				}
+			}
+		case 11:
+			var v []byte
+			if v, err = bytesField(&d, field, wireType); err == nil {
+				var a mft.Attribute
+				if a, err = UnmarshalAttribute(v); err != nil {
+					err = fmt.Errorf("unable to decode attribute %d: %v", len(r.Attributes), err)
+				} else {
+					r.Attributes = append(r.Attributes, a)
+				}
+			}
+		default:
+			err = d.skip(wireType)
+		}
+		if err != nil {
+			return mft.Record{}, err
+		}
+	}
+}
+
+// UnmarshalAttribute decodes an Attribute message. The Data of the result is a copy, it does not alias the input.
+func UnmarshalAttribute(b []byte) (mft.Attribute, error) {
+	a := mft.Attribute{}
+	d := decoder{b: b}
+	for {
+		field, wireType, ok, err := d.next()
+		if err != nil {
+			return mft.Attribute{}, err
+		}
+		if !ok {
+			return a, nil
+		}
+		switch field {
+		case 3, 8:
+			var v []byte
+			if v, err = bytesField(&d, field, wireType); err == nil {
+				if field == 3 {
+					a.Name = string(v)
+				} else {
+					a.Data = binutil.Duplicate(v)
+				}
+			}
+		case 1, 2, 4, 5, 6, 7, 9, 10, 11:
+			var v uint64
+			if v, err = uintField(&d, field, wireType); err == nil {
+				switch field {
+				case 1:
+					a.Type = mft.AttributeType(v)
+				case 2:
+					a.Resident = v != 0
+				case 4:
+					a.Flags = mft.AttributeFlags(v)
+				case 5:
+					a.AttributeId = int(v)
+				case 6:
+					a.AllocatedSize = v
+				case 7:
+					a.ActualSize = v
+				case 9:
+					a.InitializedSize = v
+				case 10:
+					a.CompressionUnit = int(v)
+				case 11:
+					a.TotalAllocatedSize = v
+				}
+			}
+		default:
+			err = d.skip(wireType)
+		}
+		if err != nil {
+			return mft.Attribute{}, err
+		}
+	}
+}
+
+func unmarshalFileReference(b []byte) (mft.FileReference, error) {
+	f := mft.FileReference{}
+	d := decoder{b: b}
+	for {
+		field, wireType, ok, err := d.next()
+		if err != nil {
+			return mft.FileReference{}, err
+		}
+		if !ok {
+			return f, nil
+		}
+		switch field {
+		case 1, 2:
+			var v uint64
+			if v, err = uintField(&d, field, wireType); err == nil {
+				if field == 1 {
+					f.RecordNumber = v
+				} else {
+					f.SequenceNumber = uint16(v)
+				}
+			}
+		default:
+			err = d.skip(wireType)
+		}
+		if err != nil {
+			return mft.FileReference{}, err
+		}
+	}
+}
+
+func uintField(d *decoder, field int, wireType int) (uint64, error) {
+	if err := expect(field, wireType, wireVarint); err != nil {
+		return 0, err
+	}
+	return d.uvarint()
+}
+
+func bytesField(d *decoder, field int, wireType int) ([]byte, error) {
+	if err := expect(field, wireType, wireBytes); err != nil {
+		return nil, err
+	}
+	return d.bytes()
+}
+
+// WriteDelimited writes the message to w, preceded by its length as a varint.
+func WriteDelimited(w io.Writer, message []byte) error {
+	b := appendUvarint(make([]byte, 0, binary.MaxVarintLen64+len(message)), uint64(len(message)))
+	_, err := w.Write(append(b, message...))
+	return err
+}
+
+// ReadDelimited reads a message written by WriteDelimited from r. It returns io.EOF when there are no more messages.
+// Messages larger than maxSize bytes are rejected, to protect against corrupt input.
+func ReadDelimited(r *bufio.Reader, maxSize int) ([]byte, error) {
+	length, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if length > uint64(maxSize) {
+		return nil, fmt.Errorf("message size %d exceeds maximum of %d", length, maxSize)
+	}
+	message := make([]byte, length)
+	if _, err := io.ReadFull(r, message); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return message, nil
+}
@@ -0,0 +1,109 @@
+package mftpb_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/mftpb"
+	"github.com/t9t/gomft/mfttest"
+)
+
+func TestMarshalAttribute(t *testing.T) {
+	a := mft.Attribute{
+		Type:        mft.AttributeTypeData,
+		Resident:    true,
+		Name:        "Zone.Identifier",
+		AttributeId: 3,
+		ActualSize:  2,
+		Data:        []byte{0xAB, 0xCD},
+	}
+	encoded := mftpb.MarshalAttribute(a)
+	expected := mfttest.DecodeHex(t, "0880011001"+"1a0f"+hex.EncodeToString([]byte("Zone.Identifier"))+"2803"+"3802"+"4202abcd")
+	assert.Equal(t, expected, encoded)
+
+	decoded, err := mftpb.UnmarshalAttribute(encoded)
+	require.Nilf(t, err, "unable to decode attribute: %v", err)
+	assert.Equal(t, a, decoded)
+}
+
+func TestRecordRoundTrip(t *testing.T) {
+	record, err := mft.ParseRecord(mfttest.SampleMftRecord())
+	require.Nilf(t, err, "unable to parse record: %v", err)
+
+	encoded := mftpb.MarshalRecord(record)
+	decoded, err := mftpb.UnmarshalRecord(encoded)
+	require.Nilf(t, err, "unable to decode record: %v", err)
+	assert.Equal(t, record, decoded)
+	assert.True(t, len(encoded) < 1024, "encoded record should be smaller than the original, but is %d bytes", len(encoded))
+}
+
+func TestUnmarshalRecordEmpty(t *testing.T) {
+	decoded, err := mftpb.UnmarshalRecord(mftpb.MarshalRecord(mft.Record{}))
+	require.Nilf(t, err, "unable to decode record: %v", err)
+	assert.Equal(t, mft.Record{Attributes: []mft.Attribute{}}, decoded)
+}
+
+func TestUnmarshalRecordSkipsUnknownFields(t *testing.T) {
+	encoded := mftpb.MarshalRecord(mft.Record{HardLinkCount: 2, Attributes: []mft.Attribute{{Type: mft.AttributeTypeFileName}}})
+	// A varint, fixed64, length delimited and fixed32 field, as could be added in later versions
+	unknown := mfttest.DecodeHex(t, "a00601"+"a906"+"0102030405060708"+"b20603616263"+"bd0601020304")
+	decoded, err := mftpb.UnmarshalRecord(append(unknown, encoded...))
+	require.Nilf(t, err, "unable to decode record: %v", err)
+	assert.Equal(t, 2, decoded.HardLinkCount)
+	require.Len(t, decoded.Attributes, 1)
+	assert.Equal(t, mft.AttributeTypeFileName, decoded.Attributes[0].Type)
+}
+
+func TestUnmarshalRecordInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		err   string
+	}{
+		{"truncated varint", "2880", "unexpected end of data"},
+		{"truncated bytes", "0a05ab", "unexpected end of data"},
+		{"field number 0", "0001", "invalid field number 0"},
+		{"wrong wire type", "2a00", "field 5 has wire type 2 but expected 0"},
+		{"invalid attribute", "5a020880", "unable to decode attribute 0: unexpected end of data"},
+		{"invalid file reference", "12020880", "unable to decode field 2: unexpected end of data"},
+		{"unsupported wire type", "a306", "unsupported wire type 3"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := mftpb.UnmarshalRecord(mfttest.DecodeHex(t, test.input))
+			require.NotNil(t, err)
+			assert.Equal(t, test.err, err.Error())
+		})
+	}
+}
+
+func TestDelimited(t *testing.T) {
+	out := &bytes.Buffer{}
+	first := mftpb.MarshalRecord(mft.Record{HardLinkCount: 1})
+	second := mftpb.MarshalRecord(mft.Record{HardLinkCount: 2})
+	require.Nil(t, mftpb.WriteDelimited(out, first))
+	require.Nil(t, mftpb.WriteDelimited(out, second))
+
+	r := bufio.NewReader(bytes.NewReader(out.Bytes()))
+	message, err := mftpb.ReadDelimited(r, 1024)
+	require.Nilf(t, err, "unable to read message: %v", err)
+	assert.Equal(t, first, message)
+	message, err = mftpb.ReadDelimited(r, 1024)
+	require.Nilf(t, err, "unable to read message: %v", err)
+	assert.Equal(t, second, message)
+	_, err = mftpb.ReadDelimited(r, 1024)
+	assert.Equal(t, io.EOF, err)
+
+	_, err = mftpb.ReadDelimited(bufio.NewReader(bytes.NewReader(out.Bytes())), 4)
+	require.NotNil(t, err)
+	assert.Equal(t, "message size 6 exceeds maximum of 4", err.Error())
+
+	_, err = mftpb.ReadDelimited(bufio.NewReader(bytes.NewReader(out.Bytes()[:3])), 1024)
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
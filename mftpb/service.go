@@ -0,0 +1,198 @@
+package mftpb
+
+import (
+	"fmt"
+
+	"github.com/t9t/gomft/binutil"
+	"github.com/t9t/gomft/mft"
+)
+
+// ServiceName is the full name of the MFTService defined in mft.proto, as used in the paths of its methods (for
+// example "/gomft.mft.v1.MFTService/GetRecord").
+const ServiceName = "gomft.mft.v1.MFTService"
+
+// ListRecordsRequest is the request of MFTService.ListRecords.
+type ListRecordsRequest struct {
+	// Filter is a filter expression as accepted by filter.Compile; when empty, all records are listed.
+	Filter    string
+	InUseOnly bool
+	// ResolvePaths includes the path of each record in the responses.
+	ResolvePaths bool
+}
+
+// ListRecordsResponse is a single response in the stream of MFTService.ListRecords.
+type ListRecordsResponse struct {
+	Record mft.Record
+	Path   string
+}
+
+// RecordRequest is the request of MFTService.GetRecord and MFTService.ResolvePath.
+type RecordRequest struct {
+	RecordNumber uint64
+}
+
+// ResolvePathResponse is the response of MFTService.ResolvePath.
+type ResolvePathResponse struct {
+	Path string
+}
+
+// ReadStreamRequest is the request of MFTService.ReadStream. An empty StreamName refers to the unnamed $DATA
+// attribute; when ChunkSize is zero, the server chooses a size.
+type ReadStreamRequest struct {
+	RecordNumber uint64
+	StreamName   string
+	ChunkSize    uint32
+}
+
+// Chunk is a single response in the stream of MFTService.ReadStream, containing the data at Offset.
+type Chunk struct {
+	Offset uint64
+	Data   []byte
+}
+
+// Marshal encodes the ListRecordsRequest.
+func (m ListRecordsRequest) Marshal() []byte {
+	b := appendBytes(nil, 1, []byte(m.Filter))
+	b = appendBool(b, 2, m.InUseOnly)
+	return appendBool(b, 3, m.ResolvePaths)
+}
+
+// Unmarshal decodes an encoded ListRecordsRequest into m.
+func (m *ListRecordsRequest) Unmarshal(b []byte) error {
+	*m = ListRecordsRequest{}
+	return decodeMessage(b, func(d *decoder, field int, wireType int) (bool, error) {
+		switch field {
+		case 1:
+			v, err := bytesField(d, field, wireType)
+			m.Filter = string(v)
+			return true, err
+		case 2, 3:
+			v, err := uintField(d, field, wireType)
+			if field == 2 {
+				m.InUseOnly = v != 0
+			} else {
+				m.ResolvePaths = v != 0
+			}
+			return true, err
+		}
+		return false, nil
+	})
+}
+
+// Marshal encodes the ListRecordsResponse.
+func (m ListRecordsResponse) Marshal() []byte {
+	b := appendMessage(nil, 1, MarshalRecord(m.Record))
+	return appendBytes(b, 2, []byte(m.Path))
+}
+
+// Unmarshal decodes an encoded ListRecordsResponse into m.
+func (m *ListRecordsResponse) Unmarshal(b []byte) error {
+	*m = ListRecordsResponse{}
+	return decodeMessage(b, func(d *decoder, field int, wireType int) (bool, error) {
+		switch field {
+		case 1:
+			v, err := bytesField(d, field, wireType)
+			if err != nil {
+				return true, err
+			}
+			if m.Record, err = UnmarshalRecord(v); err != nil {
+				return true, fmt.Errorf("unable to decode record: %v", err)
+			}
+			return true, nil
+		case 2:
+			v, err := bytesField(d, field, wireType)
+			m.Path = string(v)
+			return true, err
+		}
+		return false, nil
+	})
+}
+
+// Marshal encodes the RecordRequest.
+func (m RecordRequest) Marshal() []byte {
+	return appendUint(nil, 1, m.RecordNumber)
+}
+
+// Unmarshal decodes an encoded RecordRequest into m.
+func (m *RecordRequest) Unmarshal(b []byte) error {
+	*m = RecordRequest{}
+	return decodeMessage(b, func(d *decoder, field int, wireType int) (bool, error) {
+		if field != 1 {
+			return false, nil
+		}
+		v, err := uintField(d, field, wireType)
+		m.RecordNumber = v
+		return true, err
+	})
+}
+
+// Marshal encodes the ResolvePathResponse.
+func (m ResolvePathResponse) Marshal() []byte {
+	return appendBytes(nil, 1, []byte(m.Path))
+}
+
+// Unmarshal decodes an encoded ResolvePathResponse into m.
+func (m *ResolvePathResponse) Unmarshal(b []byte) error {
+	*m = ResolvePathResponse{}
+	return decodeMessage(b, func(d *decoder, field int, wireType int) (bool, error) {
+		if field != 1 {
+			return false, nil
+		}
+		v, err := bytesField(d, field, wireType)
+		m.Path = string(v)
+		return true, err
+	})
+}
+
+// Marshal encodes the ReadStreamRequest.
+func (m ReadStreamRequest) Marshal() []byte {
+	b := appendUint(nil, 1, m.RecordNumber)
+	b = appendBytes(b, 2, []byte(m.StreamName))
+	return appendUint(b, 3, uint64(m.ChunkSize))
+}
+
+// Unmarshal decodes an encoded ReadStreamRequest into m.
+func (m *ReadStreamRequest) Unmarshal(b []byte) error {
+	*m = ReadStreamRequest{}
+	return decodeMessage(b, func(d *decoder, field int, wireType int) (bool, error) {
+		switch field {
+		case 1, 3:
+			v, err := uintField(d, field, wireType)
+			if field == 1 {
+				m.RecordNumber = v
+			} else {
+				m.ChunkSize = uint32(v)
+			}
+			return true, err
+		case 2:
+			v, err := bytesField(d, field, wireType)
+			m.StreamName = string(v)
+			return true, err
+		}
+		return false, nil
+	})
+}
+
+// Marshal encodes the Chunk.
+func (m Chunk) Marshal() []byte {
+	b := appendUint(make([]byte, 0, 16+len(m.Data)), 1, m.Offset)
+	return appendBytes(b, 2, m.Data)
+}
+
+// Unmarshal decodes an encoded Chunk into m. The Data is a copy, it does not alias b.
+func (m *Chunk) Unmarshal(b []byte) error {
+	*m = Chunk{}
+	return decodeMessage(b, func(d *decoder, field int, wireType int) (bool, error) {
+		switch field {
+		case 1:
+			v, err := uintField(d, field, wireType)
+			m.Offset = v
+			return true, err
+		case 2:
+			v, err := bytesField(d, field, wireType)
+			m.Data = binutil.Duplicate(v)
+			return true, err
+		}
+		return false, nil
+	})
+}
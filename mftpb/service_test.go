@@ -0,0 +1,60 @@
+package mftpb_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/mftpb"
+	"github.com/t9t/gomft/mfttest"
+)
+
+func TestServiceMessages(t *testing.T) {
+	listRecords := mftpb.ListRecordsRequest{Filter: "name like '*.exe'", InUseOnly: true, ResolvePaths: true}
+	var decodedListRecords mftpb.ListRecordsRequest
+	require.Nil(t, decodedListRecords.Unmarshal(listRecords.Marshal()))
+	assert.Equal(t, listRecords, decodedListRecords)
+
+	response := mftpb.ListRecordsResponse{Record: mft.Record{HardLinkCount: 1, Attributes: []mft.Attribute{}}, Path: "/a.exe"}
+	var decodedResponse mftpb.ListRecordsResponse
+	require.Nil(t, decodedResponse.Unmarshal(response.Marshal()))
+	assert.Equal(t, response, decodedResponse)
+
+	record := mftpb.RecordRequest{RecordNumber: 437343}
+	var decodedRecord mftpb.RecordRequest
+	require.Nil(t, decodedRecord.Unmarshal(record.Marshal()))
+	assert.Equal(t, record, decodedRecord)
+	assert.Equal(t, mfttest.DecodeHex(t, "08dfd81a"), record.Marshal())
+
+	path := mftpb.ResolvePathResponse{Path: "/Windows"}
+	var decodedPath mftpb.ResolvePathResponse
+	require.Nil(t, decodedPath.Unmarshal(path.Marshal()))
+	assert.Equal(t, path, decodedPath)
+
+	readStream := mftpb.ReadStreamRequest{RecordNumber: 8, StreamName: "Zone.Identifier", ChunkSize: 4096}
+	var decodedReadStream mftpb.ReadStreamRequest
+	require.Nil(t, decodedReadStream.Unmarshal(readStream.Marshal()))
+	assert.Equal(t, readStream, decodedReadStream)
+
+	chunk := mftpb.Chunk{Offset: 65536, Data: []byte{1, 2, 3}}
+	var decodedChunk mftpb.Chunk
+	require.Nil(t, decodedChunk.Unmarshal(chunk.Marshal()))
+	assert.Equal(t, chunk, decodedChunk)
+}
+
+func TestServiceMessagesInvalid(t *testing.T) {
+	var record mftpb.RecordRequest
+	err := record.Unmarshal(mfttest.DecodeHex(t, "0a00"))
+	require.NotNil(t, err)
+	assert.Equal(t, "field 1 has wire type 2 but expected 0", err.Error())
+
+	// Unknown fields are skipped, but the known ones still decoded
+	require.Nil(t, record.Unmarshal(mfttest.DecodeHex(t, "10070805")))
+	assert.Equal(t, uint64(5), record.RecordNumber)
+
+	var response mftpb.ListRecordsResponse
+	err = response.Unmarshal(mfttest.DecodeHex(t, "0a022880"))
+	require.NotNil(t, err)
+	assert.Equal(t, "unable to decode record: unexpected end of data", err.Error())
+}
@@ -0,0 +1,87 @@
+package mftpb
+
+import (
+	"fmt"
+
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/usn"
+)
+
+// MarshalUsnRecord encodes the USN record as a UsnRecord message. The TimeStamp is encoded as a Windows "file time",
+// so it keeps a precision of 100 nanoseconds.
+func MarshalUsnRecord(r usn.Record) []byte {
+	b := make([]byte, 0, 64+len(r.FileName))
+	b = appendUint(b, 1, uint64(r.MajorVersion))
+	b = appendUint(b, 2, uint64(r.MinorVersion))
+	b = appendMessage(b, 3, marshalFileReference(r.FileReference))
+	b = appendMessage(b, 4, marshalFileReference(r.ParentFileReference))
+	b = appendUint(b, 5, uint64(r.Usn))
+	b = appendUint(b, 6, mft.ConvertToFileTime(r.TimeStamp))
+	b = appendUint(b, 7, uint64(r.Reason))
+	b = appendUint(b, 8, uint64(r.SourceInfo))
+	b = appendUint(b, 9, uint64(r.SecurityId))
+	b = appendUint(b, 10, uint64(r.FileAttributes))
+	b = appendBytes(b, 11, []byte(r.FileName))
+	return b
+}
+
+// UnmarshalUsnRecord decodes a UsnRecord message. Like usn.ParseRecord, a TimeStamp of 0 is decoded as January 1, 1601.
+func UnmarshalUsnRecord(b []byte) (usn.Record, error) {
+	r := usn.Record{TimeStamp: mft.ConvertFileTime(0)}
+	d := decoder{b: b}
+	for {
+		field, wireType, ok, err := d.next()
+		if err != nil {
+			return usn.Record{}, err
+		}
+		if !ok {
+			return r, nil
+		}
+		switch field {
+		case 3, 4:
+			var v []byte
+			if v, err = bytesField(&d, field, wireType); err == nil {
+				var ref mft.FileReference
+				if ref, err = unmarshalFileReference(v); err != nil {
+					err = fmt.Errorf("unable to decode field %d: %v", field, err)
+				} else if field == 3 {
+					r.FileReference = ref
+				} else {
+					r.ParentFileReference = ref
+				}
+			}
+		case 11:
+			var v []byte
+			if v, err = bytesField(&d, field, wireType); err == nil {
+				r.FileName = string(v)
+			}
+		case 1, 2, 5, 6, 7, 8, 9, 10:
+			var v uint64
+			if v, err = uintField(&d, field, wireType); err == nil {
+				switch field {
+				case 1:
+					r.MajorVersion = int(v)
+				case 2:
+					r.MinorVersion = int(v)
+				case 5:
+					r.Usn = int64(v)
+				case 6:
+					r.TimeStamp = mft.ConvertFileTime(v)
+				case 7:
+					r.Reason = usn.Reason(v)
+				case 8:
+					r.SourceInfo = uint32(v)
+				case 9:
+					r.SecurityId = uint32(v)
+				case 10:
+					r.FileAttributes = mft.FileAttribute(v)
+				}
+			}
+		default:
+			err = d.skip(wireType)
+		}
+		if err != nil {
+			return usn.Record{}, err
+		}
+	}
+}
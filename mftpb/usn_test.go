@@ -0,0 +1,45 @@
+package mftpb_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/mftpb"
+	"github.com/t9t/gomft/mfttest"
+	"github.com/t9t/gomft/usn"
+)
+
+func TestUsnRecordRoundTrip(t *testing.T) {
+	r := usn.Record{
+		MajorVersion:        3,
+		MinorVersion:        0,
+		FileReference:       mft.FileReference{RecordNumber: 437343, SequenceNumber: 6},
+		ParentFileReference: mft.FileReference{RecordNumber: 5, SequenceNumber: 5},
+		Usn:                 0x7FFFFFFF12345678,
+		TimeStamp:           mfttest.SampleTime,
+		Reason:              usn.ReasonFileCreate | usn.ReasonClose,
+		SourceInfo:          4,
+		SecurityId:          265,
+		FileAttributes:      mft.FileAttributeArchive | mft.FileAttributeHidden,
+		FileName:            "notes.txt",
+	}
+	decoded, err := mftpb.UnmarshalUsnRecord(mftpb.MarshalUsnRecord(r))
+	require.Nilf(t, err, "unable to decode USN record: %v", err)
+	assert.Equal(t, r, decoded)
+
+	r = usn.Record{MajorVersion: 2, TimeStamp: mft.ConvertFileTime(0)}
+	decoded, err = mftpb.UnmarshalUsnRecord(mftpb.MarshalUsnRecord(r))
+	require.Nilf(t, err, "unable to decode USN record: %v", err)
+	assert.Equal(t, r, decoded)
+}
+
+func TestUnmarshalUsnRecordInvalid(t *testing.T) {
+	// file_reference of 2 bytes, but only 1 present
+	_, err := mftpb.UnmarshalUsnRecord([]byte{0x1A, 0x02, 0x08})
+	assert.NotNil(t, err)
+	// file_name with a varint wire type
+	_, err = mftpb.UnmarshalUsnRecord([]byte{0x58, 0x01})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,155 @@
+package mftpb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Wire types of the Protocol Buffers encoding.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errTruncated = errors.New("unexpected end of data")
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	return append(b, buf[:n]...)
+}
+
+func appendTag(b []byte, field int, wireType int) []byte {
+	return appendUvarint(b, uint64(field)<<3|uint64(wireType))
+}
+
+// appendUint appends a varint field, leaving it out when it has the default value of 0 like proto3 does.
+func appendUint(b []byte, field int, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = appendTag(b, field, wireVarint)
+	return appendUvarint(b, v)
+}
+
+func appendBool(b []byte, field int, v bool) []byte {
+	if !v {
+		return b
+	}
+	return appendUint(b, field, 1)
+}
+
+// appendBytes appends a length delimited field, leaving it out when it is empty.
+func appendBytes(b []byte, field int, v []byte) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	b = appendTag(b, field, wireBytes)
+	b = appendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+// appendMessage appends an embedded message field. Unlike scalar fields, it is always present, even when empty.
+func appendMessage(b []byte, field int, message []byte) []byte {
+	b = appendTag(b, field, wireBytes)
+	b = appendUvarint(b, uint64(len(message)))
+	return append(b, message...)
+}
+
+// A decoder reads the fields of an encoded message one by one.
+type decoder struct {
+	b []byte
+}
+
+// next reads the tag of the next field. It returns false when there are no more fields.
+func (d *decoder) next() (field int, wireType int, ok bool, err error) {
+	if len(d.b) == 0 {
+		return 0, 0, false, nil
+	}
+	tag, err := d.uvarint()
+	if err != nil {
+		return 0, 0, false, err
+	}
+	if tag>>3 == 0 {
+		return 0, 0, false, fmt.Errorf("invalid field number 0")
+	}
+	return int(tag >> 3), int(tag & 7), true, nil
+}
+
+func (d *decoder) uvarint() (uint64, error) {
+	v, n := binary.Uvarint(d.b)
+	if n <= 0 {
+		return 0, errTruncated
+	}
+	d.b = d.b[n:]
+	return v, nil
+}
+
+func (d *decoder) bytes() ([]byte, error) {
+	length, err := d.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	if length > uint64(len(d.b)) {
+		return nil, errTruncated
+	}
+	v := d.b[:length]
+	d.b = d.b[length:]
+	return v, nil
+}
+
+// skip skips the value of a field that is not known, for example because it was added in a later version.
+func (d *decoder) skip(wireType int) error {
+	switch wireType {
+	case wireVarint:
+		_, err := d.uvarint()
+		return err
+	case wireBytes:
+		_, err := d.bytes()
+		return err
+	case wireFixed64, wireFixed32:
+		size := 8
+		if wireType == wireFixed32 {
+			size = 4
+		}
+		if len(d.b) < size {
+			return errTruncated
+		}
+		d.b = d.b[size:]
+		return nil
+	}
+	return fmt.Errorf("unsupported wire type %d", wireType)
+}
+
+// expect checks that the wire type of a known field is what it should be.
+func expect(field int, wireType int, expected int) error {
+	if wireType != expected {
+		return fmt.Errorf("field %d has wire type %d but expected %d", field, wireType, expected)
+	}
+	return nil
+}
+
+// decodeMessage calls fn for each field of the encoded message. When fn does not handle a field (because it is not
+// known), the field is skipped.
+func decodeMessage(b []byte, fn func(d *decoder, field int, wireType int) (handled bool, err error)) error {
+	d := decoder{b: b}
+	for {
+		field, wireType, ok, err := d.next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+		handled, err := fn(&d, field, wireType)
+		if err == nil && !handled {
+			err = d.skip(wireType)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,512 @@
+/*
+	Package mfttest builds the on-disk data of MFT records, including the fixup, for use in tests and fixtures. This
+	makes it possible to write readable, table-driven tests of code parsing records, instead of checking in opaque hex
+	dumps.
+
+	Basic usage
+
+	Create a RecordBuilder using NewRecord, add attributes using its With... methods and get the data using Bytes. The
+	methods can be chained.
+			b := mfttest.NewRecord().
+				WithRecordNumber(42).
+				WithFileName("a.txt").
+				WithResidentData([]byte("hello")).
+				Bytes()
+			record, err := mft.ParseRecord(b)
+
+	Records of a directory or other attributes can be built the same way; use WithAttribute to add an attribute with
+	data encoded by hand, or by one of the Encode... functions.
+			b := mfttest.NewRecord().
+				WithDirectory().
+				WithParent(mft.FileReference{RecordNumber: 5, SequenceNumber: 5}).
+				WithFileName("Windows").
+				WithAttribute(mft.Attribute{Type: mft.AttributeTypeReparsePoint, Resident: true, Data: reparse}).
+				Bytes()
+
+	Tests which need a record as written by Windows can use SampleMftRecord, which returns the $MFT record of an actual
+	volume.
+
+	Implementation notes
+
+	Attributes are written in the order in which they are added, without sorting them by type like NTFS does, so tests
+	can build unusual records too. Each attribute added by a With... method gets the next attribute ID; attributes added
+	by WithAttribute keep their AttributeId.
+
+	Since the builder is meant for tests, it panics when the attributes do not fit in the record, instead of returning
+	an error.
+*/
+package mfttest
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+	"unicode/utf16"
+
+	"github.com/t9t/gomft/mft"
+)
+
+const (
+	// DefaultRecordSize is the size of the records built by a RecordBuilder, unless changed using WithSize.
+	DefaultRecordSize = 1024
+	// DefaultSectorSize is the size of the sectors protected by the fixup, unless changed using WithSectorSize.
+	DefaultSectorSize = 512
+	// DefaultClusterSize is the cluster size used to calculate the allocated size of non-resident attributes, unless
+	// changed using WithClusterSize.
+	DefaultClusterSize = 4096
+)
+
+// RootReference is the reference to the root directory, which is used as parent of the $FILE_NAME attributes added by
+// WithFileName, unless changed using WithParent.
+var RootReference = mft.FileReference{RecordNumber: 5, SequenceNumber: 5}
+
+// A RecordBuilder builds the data of an MFT record. The zero value is not usable; create one using NewRecord.
+type RecordBuilder struct {
+	size                  int
+	sectorSize            int
+	clusterSize           int
+	updateSequenceNumber  uint16
+	reference             mft.FileReference
+	baseRecord            mft.FileReference
+	logFileSequenceNumber uint64
+	hardLinkCount         int
+	flags                 mft.RecordFlag
+	parent                mft.FileReference
+	time                  time.Time
+	nextAttributeId       int
+	attributes            []mft.Attribute
+}
+
+// NewRecord creates a RecordBuilder for a base record of a file which is in use, with record number 0 and sequence
+// number 1, and no attributes.
+func NewRecord() *RecordBuilder {
+	return &RecordBuilder{
+		size:                 DefaultRecordSize,
+		sectorSize:           DefaultSectorSize,
+		clusterSize:          DefaultClusterSize,
+		updateSequenceNumber: 1,
+		reference:            mft.FileReference{SequenceNumber: 1},
+		hardLinkCount:        1,
+		flags:                mft.RecordFlagInUse,
+		parent:               RootReference,
+	}
+}
+
+// WithRecordNumber sets the record number.
+func (b *RecordBuilder) WithRecordNumber(number uint64) *RecordBuilder {
+	b.reference.RecordNumber = number
+	return b
+}
+
+// WithSequenceNumber sets the sequence number.
+func (b *RecordBuilder) WithSequenceNumber(sequenceNumber uint16) *RecordBuilder {
+	b.reference.SequenceNumber = sequenceNumber
+	return b
+}
+
+// WithFlags replaces the record flags, which are mft.RecordFlagInUse by default. Use it with 0 to build a record that
+// is not in use (ie. of a deleted file).
+func (b *RecordBuilder) WithFlags(flags mft.RecordFlag) *RecordBuilder {
+	b.flags = flags
+	return b
+}
+
+// WithDirectory adds mft.RecordFlagIsDirectory to the record flags.
+func (b *RecordBuilder) WithDirectory() *RecordBuilder {
+	b.flags |= mft.RecordFlagIsDirectory
+	return b
+}
+
+// WithBaseRecord makes the record an extension record of the base record.
+func (b *RecordBuilder) WithBaseRecord(base mft.FileReference) *RecordBuilder {
+	b.baseRecord = base
+	return b
+}
+
+// WithLogFileSequenceNumber sets the $LogFile sequence number.
+func (b *RecordBuilder) WithLogFileSequenceNumber(lsn uint64) *RecordBuilder {
+	b.logFileSequenceNumber = lsn
+	return b
+}
+
+// WithHardLinkCount sets the hard link count, which is 1 by default.
+func (b *RecordBuilder) WithHardLinkCount(count int) *RecordBuilder {
+	b.hardLinkCount = count
+	return b
+}
+
+// WithSize sets the size of the record in bytes, which must be a multiple of the sector size.
+func (b *RecordBuilder) WithSize(size int) *RecordBuilder {
+	b.size = size
+	return b
+}
+
+// WithSectorSize sets the size of the sectors protected by the fixup.
+func (b *RecordBuilder) WithSectorSize(sectorSize int) *RecordBuilder {
+	b.sectorSize = sectorSize
+	return b
+}
+
+// WithClusterSize sets the cluster size used to calculate the allocated size of attributes added by
+// WithNonResidentData.
+func (b *RecordBuilder) WithClusterSize(clusterSize int) *RecordBuilder {
+	b.clusterSize = clusterSize
+	return b
+}
+
+// WithUpdateSequenceNumber sets the update sequence number, which is written at the end of each sector by the fixup.
+func (b *RecordBuilder) WithUpdateSequenceNumber(usn uint16) *RecordBuilder {
+	b.updateSequenceNumber = usn
+	return b
+}
+
+// WithParent sets the parent directory of the $FILE_NAME attributes added by WithFileName after it.
+func (b *RecordBuilder) WithParent(parent mft.FileReference) *RecordBuilder {
+	b.parent = parent
+	return b
+}
+
+// WithTime sets all times of the $STANDARD_INFORMATION and $FILE_NAME attributes added by WithStandardInformation and
+// WithFileName after it. By default, times are not set (ie. 0).
+func (b *RecordBuilder) WithTime(t time.Time) *RecordBuilder {
+	b.time = t
+	return b
+}
+
+// WithStandardInformation adds a $STANDARD_INFORMATION attribute with the file attributes, using the time set by
+// WithTime for all times.
+func (b *RecordBuilder) WithStandardInformation(attributes mft.FileAttribute) *RecordBuilder {
+	return b.WithStandardInformationData(mft.StandardInformation{
+		Creation:         b.time,
+		FileLastModified: b.time,
+		MftLastModified:  b.time,
+		LastAccess:       b.time,
+		FileAttributes:   attributes,
+	})
+}
+
+// WithStandardInformationData adds a $STANDARD_INFORMATION attribute with the data encoded by
+// EncodeStandardInformation.
+func (b *RecordBuilder) WithStandardInformationData(si mft.StandardInformation) *RecordBuilder {
+	return b.withResident(mft.AttributeTypeStandardInformation, "", EncodeStandardInformation(si))
+}
+
+// WithFileName adds a $FILE_NAME attribute with the name in the Win32 & DOS namespace, in the parent set by WithParent
+// (the root directory by default), using the time set by WithTime for all times.
+func (b *RecordBuilder) WithFileName(name string) *RecordBuilder {
+	return b.WithFileNameData(mft.FileName{
+		ParentFileReference: b.parent,
+		Creation:            b.time,
+		FileLastModified:    b.time,
+		MftLastModified:     b.time,
+		LastAccess:          b.time,
+		Namespace:           mft.FileNameNamespaceWin32Dos,
+		Name:                name,
+	})
+}
+
+// WithFileNameData adds a $FILE_NAME attribute with the data encoded by EncodeFileName.
+func (b *RecordBuilder) WithFileNameData(fn mft.FileName) *RecordBuilder {
+	return b.withResident(mft.AttributeTypeFileName, "", EncodeFileName(fn))
+}
+
+// WithResidentData adds an unnamed, resident $DATA attribute containing the data.
+func (b *RecordBuilder) WithResidentData(data []byte) *RecordBuilder {
+	return b.WithStream("", data)
+}
+
+// WithStream adds a resident $DATA attribute with the name (an alternate data stream, unless the name is empty)
+// containing the data.
+func (b *RecordBuilder) WithStream(name string, data []byte) *RecordBuilder {
+	return b.withResident(mft.AttributeTypeData, name, data)
+}
+
+// WithNonResidentData adds an unnamed, non-resident $DATA attribute of size bytes, stored in the data runs. The
+// allocated size is the total length of the runs in clusters of the cluster size set by WithClusterSize.
+func (b *RecordBuilder) WithNonResidentData(size uint64, runs ...mft.DataRun) *RecordBuilder {
+	clusters := uint64(0)
+	for _, run := range runs {
+		clusters += run.LengthInClusters
+	}
+	return b.WithAttribute(mft.Attribute{
+		Type:            mft.AttributeTypeData,
+		AttributeId:     b.nextAttributeId,
+		AllocatedSize:   clusters * uint64(b.clusterSize),
+		ActualSize:      size,
+		InitializedSize: size,
+		Data:            EncodeDataRuns(runs),
+	})
+}
+
+func (b *RecordBuilder) withResident(attrType mft.AttributeType, name string, data []byte) *RecordBuilder {
+	return b.WithAttribute(mft.Attribute{Type: attrType, Resident: true, Name: name, AttributeId: b.nextAttributeId, Data: data})
+}
+
+// WithAttribute adds the attribute as is. For a non-resident attribute, the Data must contain the encoded data runs
+// (see EncodeDataRuns).
+func (b *RecordBuilder) WithAttribute(a mft.Attribute) *RecordBuilder {
+	b.attributes = append(b.attributes, a)
+	if a.AttributeId >= b.nextAttributeId {
+		b.nextAttributeId = a.AttributeId + 1
+	}
+	return b
+}
+
+// Record returns the record as parsed by mft.ParseRecord from the data returned by Bytes. It panics when the data
+// cannot be parsed, which indicates the builder was used to create an invalid record.
+func (b *RecordBuilder) Record() mft.Record {
+	record, err := mft.ParseRecord(b.Bytes())
+	if err != nil {
+		panic(fmt.Sprintf("mfttest: unable to parse built record: %v", err))
+	}
+	return record
+}
+
+// Bytes returns the data of the record, with the fixup applied like on disk. It panics when the record size is not a
+// multiple of the sector size or the attributes do not fit in the record.
+func (b *RecordBuilder) Bytes() []byte {
+	if b.sectorSize < 4 || b.size < b.sectorSize || b.size%b.sectorSize != 0 {
+		panic(fmt.Sprintf("mfttest: record size %d is not a multiple of sector size %d", b.size, b.sectorSize))
+	}
+	sectors := b.size / b.sectorSize
+	updateSequenceOffset := 0x30
+	firstAttributeOffset := align8(updateSequenceOffset + (sectors+1)*2)
+
+	attributes := make([]byte, 0)
+	for _, a := range b.attributes {
+		attributes = append(attributes, EncodeAttribute(a)...)
+	}
+	attributes = append(attributes, 0xFF, 0xFF, 0xFF, 0xFF, 0, 0, 0, 0)
+	actualSize := firstAttributeOffset + len(attributes)
+	if actualSize > b.size {
+		panic(fmt.Sprintf("mfttest: attributes of %d bytes do not fit in record of %d bytes", len(attributes), b.size))
+	}
+
+	data := make([]byte, b.size)
+	copy(data, "FILE")
+	binary.LittleEndian.PutUint16(data[0x04:], uint16(updateSequenceOffset))
+	binary.LittleEndian.PutUint16(data[0x06:], uint16(sectors+1))
+	binary.LittleEndian.PutUint64(data[0x08:], b.logFileSequenceNumber)
+	binary.LittleEndian.PutUint16(data[0x10:], b.reference.SequenceNumber)
+	binary.LittleEndian.PutUint16(data[0x12:], uint16(b.hardLinkCount))
+	binary.LittleEndian.PutUint16(data[0x14:], uint16(firstAttributeOffset))
+	binary.LittleEndian.PutUint16(data[0x16:], uint16(b.flags))
+	binary.LittleEndian.PutUint32(data[0x18:], uint32(actualSize))
+	binary.LittleEndian.PutUint32(data[0x1C:], uint32(b.size))
+	putFileReference(data[0x20:], b.baseRecord)
+	binary.LittleEndian.PutUint16(data[0x28:], uint16(b.nextAttributeId))
+	binary.LittleEndian.PutUint32(data[0x2C:], uint32(b.reference.RecordNumber))
+	copy(data[firstAttributeOffset:], attributes)
+
+	// Fixup: move the last 2 bytes of each sector to the update sequence array and replace them by the update sequence
+	// number
+	binary.LittleEndian.PutUint16(data[updateSequenceOffset:], b.updateSequenceNumber)
+	for i := 1; i <= sectors; i++ {
+		end := i*b.sectorSize - 2
+		copy(data[updateSequenceOffset+i*2:], data[end:end+2])
+		binary.LittleEndian.PutUint16(data[end:], b.updateSequenceNumber)
+	}
+	return data
+}
+
+// EncodeAttribute encodes the attribute header, name and data, padded to a multiple of 8 bytes like NTFS does. For a
+// non-resident attribute, the Data must contain the encoded data runs and the last VCN is derived from them.
+func EncodeAttribute(a mft.Attribute) []byte {
+	name := utf16.Encode([]rune(a.Name))
+	headerSize := 0x18
+	if !a.Resident {
+		headerSize = 0x40
+		if a.Flags&(mft.AttributeFlagsCompressed|mft.AttributeFlagsSparse) != 0 {
+			headerSize = 0x48
+		}
+	}
+	dataOffset := align8(headerSize + len(name)*2)
+	length := align8(dataOffset + len(a.Data))
+
+	b := make([]byte, length)
+	binary.LittleEndian.PutUint32(b[0x00:], uint32(a.Type))
+	binary.LittleEndian.PutUint32(b[0x04:], uint32(length))
+	if !a.Resident {
+		b[0x08] = 1
+	}
+	b[0x09] = byte(len(name))
+	binary.LittleEndian.PutUint16(b[0x0A:], uint16(headerSize))
+	binary.LittleEndian.PutUint16(b[0x0C:], uint16(a.Flags))
+	binary.LittleEndian.PutUint16(b[0x0E:], uint16(a.AttributeId))
+	for i, c := range name {
+		binary.LittleEndian.PutUint16(b[headerSize+i*2:], c)
+	}
+	if a.Resident {
+		binary.LittleEndian.PutUint32(b[0x10:], uint32(len(a.Data)))
+		binary.LittleEndian.PutUint16(b[0x14:], uint16(dataOffset))
+	} else {
+		clusters := uint64(0)
+		if runs, err := mft.ParseDataRuns(a.Data); err == nil {
+			for _, run := range runs {
+				clusters += run.LengthInClusters
+			}
+		}
+		lastVCN := int64(clusters) - 1
+		binary.LittleEndian.PutUint64(b[0x18:], uint64(lastVCN))
+		binary.LittleEndian.PutUint16(b[0x20:], uint16(dataOffset))
+		b[0x22] = byte(a.CompressionUnit)
+		binary.LittleEndian.PutUint64(b[0x28:], a.AllocatedSize)
+		binary.LittleEndian.PutUint64(b[0x30:], a.ActualSize)
+		binary.LittleEndian.PutUint64(b[0x38:], a.InitializedSize)
+		if headerSize == 0x48 {
+			binary.LittleEndian.PutUint64(b[0x40:], a.TotalAllocatedSize)
+		}
+	}
+	copy(b[dataOffset:], a.Data)
+	return b
+}
+
+// EncodeStandardInformation encodes the data of a $STANDARD_INFORMATION attribute in the 72 byte format of NTFS 3.x.
+// Zero times are encoded as 0 and the RawTimes are ignored.
+func EncodeStandardInformation(si mft.StandardInformation) []byte {
+	b := make([]byte, 72)
+	putTimes(b[0x00:], si.Creation, si.FileLastModified, si.MftLastModified, si.LastAccess)
+	binary.LittleEndian.PutUint32(b[0x20:], uint32(si.FileAttributes))
+	binary.LittleEndian.PutUint32(b[0x24:], si.MaximumNumberOfVersions)
+	binary.LittleEndian.PutUint32(b[0x28:], si.VersionNumber)
+	binary.LittleEndian.PutUint32(b[0x2C:], si.ClassId)
+	binary.LittleEndian.PutUint32(b[0x30:], si.OwnerId)
+	binary.LittleEndian.PutUint32(b[0x34:], si.SecurityId)
+	binary.LittleEndian.PutUint64(b[0x38:], si.QuotaCharged)
+	binary.LittleEndian.PutUint64(b[0x40:], si.UpdateSequenceNumber)
+	return b
+}
+
+// EncodeFileName encodes the data of a $FILE_NAME attribute. Zero times are encoded as 0 and the RawTimes are ignored.
+func EncodeFileName(fn mft.FileName) []byte {
+	name := utf16.Encode([]rune(fn.Name))
+	b := make([]byte, 0x42+len(name)*2)
+	putFileReference(b[0x00:], fn.ParentFileReference)
+	putTimes(b[0x08:], fn.Creation, fn.FileLastModified, fn.MftLastModified, fn.LastAccess)
+	binary.LittleEndian.PutUint64(b[0x28:], fn.AllocatedSize)
+	binary.LittleEndian.PutUint64(b[0x30:], fn.ActualSize)
+	binary.LittleEndian.PutUint32(b[0x38:], uint32(fn.Flags))
+	binary.LittleEndian.PutUint32(b[0x3C:], fn.ExtendedData)
+	b[0x40] = byte(len(name))
+	b[0x41] = byte(fn.Namespace)
+	for i, c := range name {
+		binary.LittleEndian.PutUint16(b[0x42+i*2:], c)
+	}
+	return b
+}
+
+// EncodeAttributeList encodes the data of an $ATTRIBUTE_LIST attribute, with each entry padded to a multiple of 8 bytes.
+func EncodeAttributeList(entries []mft.AttributeListEntry) []byte {
+	b := make([]byte, 0)
+	for _, e := range entries {
+		name := utf16.Encode([]rune(e.Name))
+		entry := make([]byte, align8(0x1A+len(name)*2))
+		binary.LittleEndian.PutUint32(entry[0x00:], uint32(e.Type))
+		binary.LittleEndian.PutUint16(entry[0x04:], uint16(len(entry)))
+		entry[0x06] = byte(len(name))
+		entry[0x07] = 0x1A
+		binary.LittleEndian.PutUint64(entry[0x08:], e.StartingVCN)
+		putFileReference(entry[0x10:], e.BaseRecordReference)
+		binary.LittleEndian.PutUint16(entry[0x18:], e.AttributeId)
+		for i, c := range name {
+			binary.LittleEndian.PutUint16(entry[0x1A+i*2:], c)
+		}
+		b = append(b, entry...)
+	}
+	return b
+}
+
+// EncodeExtendedAttributes encodes the data of an $EA attribute, with each entry padded to a multiple of 4 bytes like
+// the FILE_FULL_EA_INFORMATION structure.
+func EncodeExtendedAttributes(attributes []mft.ExtendedAttribute) []byte {
+	b := make([]byte, 0)
+	for i, a := range attributes {
+		length := 8 + len(a.Name) + 1 + len(a.Value)
+		entry := make([]byte, (length+3)&^3)
+		if i < len(attributes)-1 {
+			binary.LittleEndian.PutUint32(entry[0x00:], uint32(len(entry)))
+		} else {
+			entry = entry[:length]
+		}
+		entry[0x04] = a.Flags
+		entry[0x05] = byte(len(a.Name))
+		binary.LittleEndian.PutUint16(entry[0x06:], uint16(len(a.Value)))
+		copy(entry[0x08:], a.Name)
+		copy(entry[0x08+len(a.Name)+1:], a.Value)
+		b = append(b, entry...)
+	}
+	return b
+}
+
+// EncodeDataRuns encodes the data runs, including the terminating 0 byte, using as few bytes as possible for each
+// length and offset. A Sparse run is encoded without offset.
+func EncodeDataRuns(runs []mft.DataRun) []byte {
+	b := make([]byte, 0)
+	for _, run := range runs {
+		length := encodeSigned(int64(run.LengthInClusters))
+		var offset []byte
+		if !run.Sparse {
+			offset = encodeSigned(run.OffsetCluster)
+		}
+		b = append(b, byte(len(offset)<<4|len(length)))
+		b = append(b, length...)
+		b = append(b, offset...)
+	}
+	return append(b, 0)
+}
+
+// encodeSigned encodes v as little endian two's complement number using the least number of bytes, since
+// mft.ParseDataRuns sign extends both the length and offset of a run.
+// File creates a RecordBuilder for a file named "file", of which the unnamed $DATA attribute of size bytes is stored in
+// the data runs, for tests of code working with the clusters occupied by files.
+func File(size uint64, runs ...mft.DataRun) *RecordBuilder {
+	return NewRecord().WithFileName("file").WithNonResidentData(size, runs...)
+}
+
+// Run returns a DataRun of length clusters, starting offset clusters after the start of the previous run that is not
+// sparse.
+func Run(offset int64, length uint64) mft.DataRun {
+	return mft.DataRun{OffsetCluster: offset, LengthInClusters: length}
+}
+
+// SparseRun returns a sparse DataRun of length clusters.
+func SparseRun(length uint64) mft.DataRun {
+	return mft.DataRun{LengthInClusters: length, Sparse: true}
+}
+
+// Reference returns a reference to the record with the number and sequence number 1, which is the sequence number of
+// records built by NewRecord.
+func Reference(number uint64) mft.FileReference {
+	return mft.FileReference{RecordNumber: number, SequenceNumber: 1}
+}
+
+func encodeSigned(v int64) []byte {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, uint64(v))
+	n := 8
+	for n > 1 {
+		top, next := b[n-1], b[n-2]
+		if (top == 0x00 && next&0x80 == 0) || (top == 0xFF && next&0x80 != 0) {
+			n--
+			continue
+		}
+		break
+	}
+	return b[:n]
+}
+
+func putTimes(b []byte, times ...time.Time) {
+	for i, t := range times {
+		if !t.IsZero() {
+			binary.LittleEndian.PutUint64(b[i*8:], mft.ConvertToFileTime(t))
+		}
+	}
+}
+
+func putFileReference(b []byte, ref mft.FileReference) {
+	binary.LittleEndian.PutUint64(b, ref.RecordNumber&0xFFFFFFFFFFFF|uint64(ref.SequenceNumber)<<48)
+}
+
+func align8(n int) int {
+	return (n + 7) &^ 7
+}
@@ -0,0 +1,162 @@
+package mfttest_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/mfttest"
+)
+
+func TestRecordBuilder(t *testing.T) {
+	b := mfttest.NewRecord().
+		WithRecordNumber(42).
+		WithSequenceNumber(3).
+		WithLogFileSequenceNumber(1086584).
+		WithTime(mfttest.SampleTime).
+		WithStandardInformation(mft.FileAttributeArchive).
+		WithFileName("a.txt").
+		WithResidentData([]byte("hello")).
+		WithStream("Zone.Identifier", []byte("[ZoneTransfer]")).
+		Bytes()
+	require.Equal(t, 1024, len(b))
+
+	record, err := mft.ParseRecordWithOptions(b, mft.ParseOptions{Strict: true})
+	require.Nilf(t, err, "unable to parse record: %v", err)
+	assert.Equal(t, mft.FileReference{RecordNumber: 42, SequenceNumber: 3}, record.FileReference)
+	assert.Equal(t, mft.FileReference{}, record.BaseRecordReference)
+	assert.Equal(t, uint64(1086584), record.LogFileSequenceNumber)
+	assert.Equal(t, 1, record.HardLinkCount)
+	assert.Equal(t, mft.RecordFlagInUse, record.Flags)
+	assert.Equal(t, uint32(1024), record.AllocatedSize)
+	assert.Equal(t, 4, record.NextAttributeId)
+	require.Equal(t, 4, len(record.Attributes))
+
+	si, err := mft.ParseStandardInformation(record.Attributes[0].Data)
+	require.Nilf(t, err, "unable to parse $STANDARD_INFORMATION: %v", err)
+	assert.Equal(t, mfttest.SampleTime, si.Creation)
+	assert.Equal(t, mfttest.SampleTime, si.LastAccess)
+	assert.Equal(t, mft.FileAttributeArchive, si.FileAttributes)
+
+	fn, err := mft.ParseFileName(record.Attributes[1].Data)
+	require.Nilf(t, err, "unable to parse $FILE_NAME: %v", err)
+	assert.Equal(t, "a.txt", fn.Name)
+	assert.Equal(t, mfttest.RootReference, fn.ParentFileReference)
+	assert.Equal(t, mft.FileNameNamespaceWin32Dos, fn.Namespace)
+	assert.Equal(t, mfttest.SampleTime, fn.MftLastModified)
+
+	expected := []mft.Attribute{
+		{Type: mft.AttributeTypeData, Resident: true, AttributeId: 2, Data: []byte("hello")},
+		{Type: mft.AttributeTypeData, Resident: true, Name: "Zone.Identifier", AttributeId: 3, Data: []byte("[ZoneTransfer]")},
+	}
+	assert.Equal(t, expected, record.Attributes[2:])
+}
+
+func TestRecordBuilder_Header(t *testing.T) {
+	base := mft.FileReference{RecordNumber: 1234, SequenceNumber: 7}
+	record := mfttest.NewRecord().
+		WithFlags(0).
+		WithDirectory().
+		WithBaseRecord(base).
+		WithHardLinkCount(2).
+		WithSize(4096).
+		WithSectorSize(4096).
+		WithUpdateSequenceNumber(0xABCD).
+		Record()
+	assert.Equal(t, mft.RecordFlagIsDirectory, record.Flags)
+	assert.Equal(t, base, record.BaseRecordReference)
+	assert.Equal(t, 2, record.HardLinkCount)
+	assert.Equal(t, uint32(4096), record.AllocatedSize)
+	assert.Equal(t, 0, len(record.Attributes))
+}
+
+func TestRecordBuilder_Fixup(t *testing.T) {
+	// The data crosses the end of the first sector, so it is only intact when the fixup is applied correctly
+	data := bytes.Repeat([]byte{0x5A}, 700)
+	b := mfttest.NewRecord().WithUpdateSequenceNumber(0x1234).WithResidentData(data).Bytes()
+	assert.Equal(t, []byte{0x34, 0x12}, b[510:512])
+	assert.Equal(t, []byte{0x34, 0x12}, b[1022:1024])
+
+	record, err := mft.ParseRecord(b)
+	require.Nilf(t, err, "unable to parse record: %v", err)
+	assert.Equal(t, data, record.Attributes[0].Data)
+}
+
+func TestRecordBuilder_NonResidentData(t *testing.T) {
+	runs := []mft.DataRun{
+		{OffsetCluster: 4158, LengthInClusters: 9},
+		{LengthInClusters: 2, Sparse: true},
+		{OffsetCluster: -4000, LengthInClusters: 0x80},
+	}
+	record := mfttest.NewRecord().WithClusterSize(512).WithNonResidentData(12345, runs...).Record()
+	require.Equal(t, 1, len(record.Attributes))
+
+	a := record.Attributes[0]
+	assert.False(t, a.Resident)
+	assert.Equal(t, uint64(12345), a.ActualSize)
+	assert.Equal(t, uint64(139*512), a.AllocatedSize)
+	parsed, err := mft.ParseDataRuns(a.Data)
+	require.Nilf(t, err, "unable to parse data runs: %v", err)
+	assert.Equal(t, runs, parsed)
+}
+
+func TestRecordBuilder_Attribute(t *testing.T) {
+	a := mft.Attribute{Type: mft.AttributeTypeReparsePoint, Resident: true, Flags: mft.AttributeFlagsCompressed, AttributeId: 7, Data: []byte{1, 2, 3}}
+	record := mfttest.NewRecord().WithAttribute(a).WithResidentData(nil).Record()
+	assert.Equal(t, 9, record.NextAttributeId)
+	assert.Equal(t, a, record.Attributes[0])
+	assert.Equal(t, 8, record.Attributes[1].AttributeId)
+}
+
+func TestRecordBuilder_Invalid(t *testing.T) {
+	assert.Panics(t, func() { mfttest.NewRecord().WithResidentData(make([]byte, 1024)).Bytes() }, "attributes too large")
+	assert.Panics(t, func() { mfttest.NewRecord().WithSize(1000).Bytes() }, "size not a multiple of the sector size")
+}
+
+func TestEncodeDataRuns(t *testing.T) {
+	runs := []mft.DataRun{
+		{OffsetCluster: 4158, LengthInClusters: 9},
+		{LengthInClusters: 2, Sparse: true},
+		{OffsetCluster: 42, LengthInClusters: 1},
+	}
+	expected := []byte{0x21, 0x09, 0x3e, 0x10, 0x01, 0x02, 0x11, 0x01, 0x2a, 0x00}
+	assert.Equal(t, expected, mfttest.EncodeDataRuns(runs))
+	assert.Equal(t, []byte{0x00}, mfttest.EncodeDataRuns(nil))
+}
+
+func TestFile(t *testing.T) {
+	r := mfttest.File(5000, mfttest.Run(100, 1), mfttest.SparseRun(1)).WithBaseRecord(mfttest.Reference(43)).Record()
+	assert.Equal(t, mft.FileReference{RecordNumber: 43, SequenceNumber: 1}, r.BaseRecordReference)
+	a, ok := r.FindFirstAttribute(mft.AttributeTypeData)
+	require.True(t, ok)
+	assert.Equal(t, uint64(5000), a.ActualSize)
+	runs, err := mft.ParseDataRuns(a.Data)
+	require.Nilf(t, err, "unable to parse data runs: %v", err)
+	assert.Equal(t, []mft.DataRun{{OffsetCluster: 100, LengthInClusters: 1}, {LengthInClusters: 1, Sparse: true}}, runs)
+}
+
+func TestEncodeAttributeList(t *testing.T) {
+	entries := []mft.AttributeListEntry{
+		{Type: mft.AttributeTypeStandardInformation, BaseRecordReference: mft.FileReference{RecordNumber: 40, SequenceNumber: 2}},
+		{Type: mft.AttributeTypeData, Name: "ads", StartingVCN: 16, BaseRecordReference: mft.FileReference{RecordNumber: 41, SequenceNumber: 1}, AttributeId: 3},
+	}
+	b := mfttest.EncodeAttributeList(entries)
+	assert.Equal(t, 64, len(b))
+	parsed, err := mft.ParseAttributeList(b)
+	require.Nil(t, err)
+	assert.Equal(t, entries, parsed)
+}
+
+func TestEncodeExtendedAttributes(t *testing.T) {
+	attributes := []mft.ExtendedAttribute{
+		{Name: "$LXUID", Value: []byte{0xe8, 0x03, 0x00, 0x00}},
+		{Flags: mft.ExtendedAttributeFlagNeedEA, Name: "$LXMOD", Value: []byte{0xa4, 0x81, 0x00, 0x00}},
+	}
+	b := mfttest.EncodeExtendedAttributes(attributes)
+	assert.Equal(t, 20+19, len(b))
+	parsed, err := mft.ParseExtendedAttributes(b)
+	require.Nil(t, err)
+	assert.Equal(t, attributes, parsed)
+}
@@ -0,0 +1,84 @@
+package mfttest
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/t9t/gomft/mft"
+)
+
+// SampleTime is the time used for the times of SampleFileRecord. It has a precision of 100 nanoseconds, like the times
+// stored by NTFS.
+var SampleTime = time.Date(2020, time.February, 5, 14, 59, 38, 116886200, time.UTC)
+
+// sampleMftRecordHex is the $MFT record of an actual NTFS volume, with 1920466944 bytes of MFT data.
+const sampleMftRecordHex = "46494c453000030034a999fb050000009100010038000100e001000000040000a0b0c0d0e0f010900800000000000000900600000000000010000000600000000000180000000000480000001800000094f048965b2fcc0194f048965b2fcc0194f048965b2fcc0194f048965b2fcc0106000000000000000000000000000000000000000001000000000000000000000000000000000000300000006800000000001800000003004a00000018000100050000000000050094f048965b2fcc0194f048965b2fcc0194f048965b2fcc0194f048965b2fcc010000bc39000000000000bc39000000000600000000000000040324004d00460054000000000000008000000090000000010040000000010000000000000000007f2707000000000040000000000000000000787200000000000078720000000000007872000000003320c80000000c4322b500ba055c034381de0065cf47044384b3005d8bef0943b0e10090b4b5184300c800f4ea13014306c8009a3a5afe4312c800f4074dfe330fc80023d4c042621654029503000000b000000048000000010040000000070000000000000000003900000000000000400000000000000000a0030000000000e09d030000000000e09d030000000000413abe8483000000ffffffff00000000ffffffff00000000ffffffff00000000ffffffff00000000ffffffff00009006ffffffff00000000ffffffff00000000ffffffff00000000ffffffff00000000ffffffff0000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000009006"
+
+// SampleMftRecord returns the data of the $MFT record (record 0) of an actual NTFS volume, as read from disk (so before
+// applying the fixup), for tests which need a record written by Windows rather than by a RecordBuilder. Each call
+// returns a new slice, which may be modified.
+func SampleMftRecord() []byte {
+	b, err := hex.DecodeString(sampleMftRecordHex)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// DecodeHex decodes the hexadecimal string s, failing the test when s is not valid hexadecimal.
+func DecodeHex(t testing.TB, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("unable to convert input hex to []byte: %v", err)
+	}
+	return b
+}
+
+// SampleFileRecord returns a RecordBuilder for record 64 (sequence number 2) of a hidden file named test.txt in the root
+// directory, for tests of code printing or formatting records. All of its times are SampleTime, except the last access
+// time of its $STANDARD_INFORMATION, which is not set. It has these attributes:
+//   - $STANDARD_INFORMATION (ID 0) with security ID 265 and update sequence number 4711
+//   - $FILE_NAME (ID 2) in the Win32 namespace
+//   - an unnamed, sparse $DATA attribute (ID 3) of 12345 bytes, of which 10000 are initialized, in the data runs of 9
+//     clusters at 4158, 2 sparse clusters and 1 cluster at 4200
+//   - a resident $DATA attribute named Zone.Identifier (ID 4)
+func SampleFileRecord() *RecordBuilder {
+	runs := []mft.DataRun{{OffsetCluster: 4158, LengthInClusters: 9}, {LengthInClusters: 2, Sparse: true}, {OffsetCluster: 42, LengthInClusters: 1}}
+	return NewRecord().
+		WithRecordNumber(64).
+		WithSequenceNumber(2).
+		WithLogFileSequenceNumber(1086584).
+		WithStandardInformationData(mft.StandardInformation{
+			Creation:             SampleTime,
+			FileLastModified:     SampleTime,
+			MftLastModified:      SampleTime,
+			FileAttributes:       mft.FileAttributeHidden | mft.FileAttributeArchive,
+			SecurityId:           265,
+			UpdateSequenceNumber: 4711,
+		}).
+		WithAttribute(mft.Attribute{Type: mft.AttributeTypeFileName, Resident: true, AttributeId: 2, Data: EncodeFileName(mft.FileName{
+			ParentFileReference: RootReference,
+			Creation:            SampleTime,
+			FileLastModified:    SampleTime,
+			MftLastModified:     SampleTime,
+			LastAccess:          SampleTime,
+			AllocatedSize:       16384,
+			ActualSize:          12345,
+			Flags:               mft.FileAttributeArchive,
+			Namespace:           mft.FileNameNamespaceWin32,
+			Name:                "test.txt",
+		})}).
+		WithAttribute(mft.Attribute{
+			Type:               mft.AttributeTypeData,
+			AttributeId:        3,
+			Flags:              mft.AttributeFlagsSparse,
+			AllocatedSize:      49152,
+			ActualSize:         12345,
+			InitializedSize:    10000,
+			TotalAllocatedSize: 40960,
+			Data:               EncodeDataRuns(runs),
+		}).
+		WithStream("Zone.Identifier", []byte("[ZoneTransfer]\r\nZoneId=3"))
+}
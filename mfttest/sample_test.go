@@ -0,0 +1,42 @@
+package mfttest_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/mfttest"
+)
+
+func TestSampleMftRecord(t *testing.T) {
+	b := mfttest.SampleMftRecord()
+	record, err := mft.ParseRecord(b)
+	require.Nilf(t, err, "unable to parse record: %v", err)
+	assert.Equal(t, uint64(0), record.FileReference.RecordNumber)
+	assert.Equal(t, uint16(145), record.FileReference.SequenceNumber)
+
+	// Each call returns a new slice
+	b[0] = 'X'
+	assert.Equal(t, byte('F'), mfttest.SampleMftRecord()[0])
+}
+
+func TestDecodeHex(t *testing.T) {
+	assert.Equal(t, []byte{0x46, 0x49, 0x4c, 0x45}, mfttest.DecodeHex(t, "46494c45"))
+}
+
+func TestSampleFileRecord(t *testing.T) {
+	record := mfttest.SampleFileRecord().Record()
+	assert.Equal(t, mft.FileReference{RecordNumber: 64, SequenceNumber: 2}, record.FileReference)
+	require.Len(t, record.Attributes, 4)
+
+	si, err := mft.ParseStandardInformation(record.Attributes[0].Data)
+	require.Nilf(t, err, "unable to parse $STANDARD_INFORMATION: %v", err)
+	assert.Equal(t, mfttest.SampleTime, si.Creation)
+	assert.Equal(t, uint64(0), si.RawTimes.LastAccess)
+
+	runs, err := mft.ParseDataRuns(record.Attributes[2].Data)
+	require.Nilf(t, err, "unable to parse data runs: %v", err)
+	assert.Equal(t, []mft.DataRun{{OffsetCluster: 4158, LengthInClusters: 9}, {LengthInClusters: 2, Sparse: true}, {OffsetCluster: 42, LengthInClusters: 1}}, runs)
+	assert.Equal(t, "Zone.Identifier", record.Attributes[3].Name)
+}
@@ -0,0 +1,469 @@
+package mfttest
+
+import (
+	"encoding/binary"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/t9t/gomft/mft"
+)
+
+// The geometry of the volumes built by a VolumeBuilder.
+const (
+	VolumeSectorSize  = 512
+	VolumeClusterSize = 4096
+	VolumeRecordSize  = 1024
+	VolumeIndexSize   = 4096
+)
+
+// FirstUserRecord is the record number of the first file or directory added to a VolumeBuilder. Like on NTFS, the
+// records before it are reserved for the metafiles.
+const FirstUserRecord = 64
+
+// MaxResidentSize is the maximum size of a file which is stored resident in its record by a VolumeBuilder. The data of
+// larger files is stored in clusters.
+const MaxResidentSize = 512
+
+const (
+	bootClusters      = 2
+	mirrorCluster     = 2
+	mftCluster        = 4
+	mirrorRecords     = 4
+	recordsPerCluster = VolumeClusterSize / VolumeRecordSize
+
+	// maxRootEntries is the number of bytes of index entries which are stored in an $INDEX_ROOT; directories with
+	// more entries get an $INDEX_ALLOCATION of a single index block instead.
+	maxRootEntries = 384
+)
+
+// metafiles are the names of the metafiles in records 0 up to 11.
+var metafiles = []string{"$MFT", "$MFTMirr", "$LogFile", "$Volume", "$AttrDef", ".", "$Bitmap", "$Boot", "$BadClus", "$Secure", "$UpCase", "$Extend"}
+
+// A VolumeBuilder builds a tiny, but structurally valid, image of an NTFS volume in memory, containing files and
+// directories. The zero value is not usable; create one using NewVolume.
+//
+// The image contains a boot sector (and a backup of it in the last sector), an MFT with the metafiles in records 0 up
+// to 11 and the added files and directories from record FirstUserRecord on, the $MFTMirr and the $Bitmap of the
+// clusters in use. Each directory has a $I30 index of its entries, sorted by name; when the entries do not fit in the
+// $INDEX_ROOT, they are stored in a single index block in an $INDEX_ALLOCATION. The $LogFile, $AttrDef, $BadClus,
+// $Secure and $UpCase metafiles are empty.
+//
+// Since the builder is meant for tests, it panics when the image cannot be built, for example when a directory has too
+// many entries to fit in a single index block.
+type VolumeBuilder struct {
+	label   string
+	time    time.Time
+	serial  uint64
+	entries []*volumeEntry
+	byPath  map[string]*volumeEntry
+}
+
+type volumeEntry struct {
+	path      string
+	directory bool
+	data      []byte
+	record    uint64
+	parent    *volumeEntry
+	children  []*volumeEntry
+	runs      []mft.DataRun
+	index     []byte
+}
+
+// NewVolume creates a VolumeBuilder for an empty volume.
+func NewVolume() *VolumeBuilder {
+	root := &volumeEntry{path: "/", directory: true, record: 5}
+	root.parent = root
+	return &VolumeBuilder{serial: 0x1234ABCD5678EF90, entries: []*volumeEntry{root}, byPath: map[string]*volumeEntry{"/": root}}
+}
+
+// WithLabel sets the volume label, which is stored in the $VOLUME_NAME attribute of $Volume.
+func (v *VolumeBuilder) WithLabel(label string) *VolumeBuilder {
+	v.label = label
+	return v
+}
+
+// WithTime sets all times of all files and directories. By default, times are not set (ie. 0).
+func (v *VolumeBuilder) WithTime(t time.Time) *VolumeBuilder {
+	v.time = t
+	return v
+}
+
+// WithDirectory adds a directory with the path, such as "/Users/bob", and any missing parent directories.
+func (v *VolumeBuilder) WithDirectory(p string) *VolumeBuilder {
+	v.add(p, true, nil)
+	return v
+}
+
+// WithFile adds a file with the path, such as "/Users/bob/notes.txt", containing the data. Missing parent directories
+// are added too.
+func (v *VolumeBuilder) WithFile(p string, data []byte) *VolumeBuilder {
+	v.add(p, false, data)
+	return v
+}
+
+func (v *VolumeBuilder) add(p string, directory bool, data []byte) *volumeEntry {
+	p = path.Clean("/" + p)
+	if e, ok := v.byPath[p]; ok {
+		if !directory || !e.directory {
+			panic(fmt.Sprintf("mfttest: %s was already added", p))
+		}
+		return e
+	}
+	parent := v.add(path.Dir(p), true, nil)
+	e := &volumeEntry{path: p, directory: directory, data: data, parent: parent}
+	parent.children = append(parent.children, e)
+	v.entries = append(v.entries, e)
+	v.byPath[p] = e
+	return e
+}
+
+// Volume is an image of an NTFS volume built by a VolumeBuilder.
+type Volume struct {
+	// Data is the image of the volume.
+	Data []byte
+	// MftOffset is the position of the MFT in Data.
+	MftOffset int64
+	// MftLength is the size of the MFT in bytes.
+	MftLength int64
+	// Records contains the record number of each metafile (by its name, such as "$MFT") and each added file and
+	// directory (by its cleaned path, such as "/Users/bob"). The root directory has path "/".
+	Records map[string]uint64
+}
+
+// Mft returns the MFT data of the volume, like a dump of the MFT.
+func (v *Volume) Mft() []byte {
+	return v.Data[v.MftOffset : v.MftOffset+v.MftLength]
+}
+
+// Build builds the image of the volume.
+func (v *VolumeBuilder) Build() *Volume {
+	records := map[string]uint64{}
+	for i, name := range metafiles {
+		records[name] = uint64(i)
+	}
+	number := uint64(FirstUserRecord)
+	for _, e := range v.entries[1:] {
+		e.record = number
+		number++
+		records[e.path] = e.record
+	}
+	records["/"] = 5
+	mftRecords := int(number)
+	if mftRecords%recordsPerCluster != 0 {
+		mftRecords += recordsPerCluster - mftRecords%recordsPerCluster
+	}
+	mftClusters := mftRecords / recordsPerCluster
+
+	// Allocate the clusters: boot sector, mirror, (unused), MFT, bitmap, file data and index blocks, backup boot sector
+	bitmapCluster := mftCluster + mftClusters
+	next := bitmapCluster + 1
+	allocate := func(length int) []mft.DataRun {
+		clusters := (length + VolumeClusterSize - 1) / VolumeClusterSize
+		run := mft.DataRun{OffsetCluster: int64(next), LengthInClusters: uint64(clusters)}
+		next += clusters
+		return []mft.DataRun{run}
+	}
+	for _, e := range v.entries {
+		if e.directory {
+			e.index = v.indexEntries(e)
+			if len(e.index) > maxRootEntries {
+				e.runs = allocate(VolumeIndexSize)
+			}
+		} else if len(e.data) > MaxResidentSize {
+			e.runs = allocate(len(e.data))
+		}
+	}
+	totalClusters := next + 1
+	if totalClusters > VolumeClusterSize*8 {
+		panic(fmt.Sprintf("mfttest: volume of %d clusters exceeds the maximum of %d", totalClusters, VolumeClusterSize*8))
+	}
+
+	data := make([]byte, totalClusters*VolumeClusterSize)
+	cluster := func(n int64) []byte {
+		return data[n*VolumeClusterSize:]
+	}
+
+	// Metafiles
+	mftData := cluster(mftCluster)[:mftRecords*VolumeRecordSize]
+	putRecord := func(n uint64, b []byte) {
+		copy(mftData[n*VolumeRecordSize:], b)
+	}
+	recordBitmap := make([]byte, align8((mftRecords+7)/8))
+	markRecord := func(n uint64) {
+		recordBitmap[n/8] |= 1 << (n % 8)
+	}
+	for n := range metafiles {
+		markRecord(uint64(n))
+	}
+	for _, e := range v.entries[1:] {
+		markRecord(e.record)
+	}
+	clusterBitmap := make([]byte, align8((totalClusters+7)/8))
+	for c := 0; c < totalClusters; c++ {
+		if c != 3 {
+			clusterBitmap[c/8] |= 1 << (c % 8)
+		}
+	}
+
+	mftRecord := v.metafile(0).
+		WithNonResidentData(uint64(len(mftData)), mft.DataRun{OffsetCluster: mftCluster, LengthInClusters: uint64(mftClusters)}).
+		WithAttribute(mft.Attribute{Type: mft.AttributeTypeBitmap, Resident: true, AttributeId: 3, Data: recordBitmap})
+	putRecord(0, mftRecord.Bytes())
+	putRecord(1, v.metafile(1).WithNonResidentData(VolumeClusterSize, mft.DataRun{OffsetCluster: mirrorCluster, LengthInClusters: 1}).Bytes())
+	putRecord(2, v.metafile(2).WithResidentData(nil).Bytes())
+	volumeName := utf16.Encode([]rune(v.label))
+	volumeNameData := make([]byte, len(volumeName)*2)
+	for i, c := range volumeName {
+		binary.LittleEndian.PutUint16(volumeNameData[i*2:], c)
+	}
+	volumeInformation := make([]byte, 12)
+	volumeInformation[0x08] = 3
+	volumeInformation[0x09] = 1
+	putRecord(3, v.metafile(3).
+		WithAttribute(mft.Attribute{Type: mft.AttributeTypeVolumeName, Resident: true, AttributeId: 2, Data: volumeNameData}).
+		WithAttribute(mft.Attribute{Type: mft.AttributeTypeVolumeInformation, Resident: true, AttributeId: 3, Data: volumeInformation}).
+		WithResidentData(nil).
+		Bytes())
+	putRecord(4, v.metafile(4).WithResidentData(nil).Bytes())
+	putRecord(6, v.metafile(6).WithNonResidentData(uint64(len(clusterBitmap)), mft.DataRun{OffsetCluster: int64(bitmapCluster), LengthInClusters: 1}).Bytes())
+	putRecord(7, v.metafile(7).WithNonResidentData(bootClusters*VolumeClusterSize, mft.DataRun{OffsetCluster: 0, LengthInClusters: bootClusters}).Bytes())
+	putRecord(8, v.metafile(8).WithResidentData(nil).WithStream("$Bad", nil).Bytes())
+	putRecord(9, v.metafile(9).Bytes())
+	putRecord(10, v.metafile(10).WithResidentData(nil).Bytes())
+	putRecord(11, v.metafile(11).WithDirectory().withIndex(nil, nil).Bytes())
+	copy(cluster(int64(bitmapCluster)), clusterBitmap)
+
+	// Files and directories
+	for _, e := range v.entries {
+		b := v.entryRecord(e)
+		if e.directory {
+			b.withIndex(e.index, e.runs)
+			if e.runs != nil {
+				copy(cluster(e.runs[0].OffsetCluster), indexBlock(e.index))
+			}
+		} else if e.runs != nil {
+			b.WithNonResidentData(uint64(len(e.data)), e.runs...)
+			copy(cluster(e.runs[0].OffsetCluster), e.data)
+		} else {
+			b.WithResidentData(e.data)
+		}
+		putRecord(e.record, b.Bytes())
+	}
+
+	copy(cluster(mirrorCluster), mftData[:mirrorRecords*VolumeRecordSize])
+	boot := bootSector(uint64(totalClusters), mftCluster, mirrorCluster, v.serial)
+	copy(data, boot)
+	copy(data[len(data)-VolumeSectorSize:], boot)
+
+	return &Volume{
+		Data:      data,
+		MftOffset: mftCluster * VolumeClusterSize,
+		MftLength: int64(len(mftData)),
+		Records:   records,
+	}
+}
+
+// metafile creates a RecordBuilder for the metafile with the number, with its $STANDARD_INFORMATION and $FILE_NAME.
+func (v *VolumeBuilder) metafile(n uint64) *RecordBuilder {
+	attributes := mft.FileAttributeHidden | mft.FileAttributeSystem
+	return NewRecord().
+		WithRecordNumber(n).
+		WithSequenceNumber(uint16(n)).
+		WithTime(v.time).
+		WithStandardInformation(attributes).
+		WithFileNameData(v.fileName(metafiles[n], RootReference, attributes, 0))
+}
+
+// entryRecord creates a RecordBuilder for a file or directory, with its $STANDARD_INFORMATION and $FILE_NAME.
+func (v *VolumeBuilder) entryRecord(e *volumeEntry) *RecordBuilder {
+	b := NewRecord().WithRecordNumber(e.record).WithTime(v.time)
+	if e.record == 5 {
+		b.WithSequenceNumber(5)
+	}
+	if e.directory {
+		b.WithDirectory()
+	}
+	attributes := mft.FileAttributeArchive
+	if e.record == 5 {
+		attributes = mft.FileAttributeHidden | mft.FileAttributeSystem
+	}
+	b.WithStandardInformation(attributes)
+	return b.WithFileNameData(v.entryFileName(e))
+}
+
+func (v *VolumeBuilder) entryFileName(e *volumeEntry) mft.FileName {
+	name := path.Base(e.path)
+	if e.record == 5 {
+		name = "."
+	}
+	flags := mft.FileAttributeArchive
+	if e.record == 5 {
+		flags = mft.FileAttributeHidden | mft.FileAttributeSystem
+	}
+	if e.directory {
+		flags |= mft.FileAttributeDirectory
+	}
+	fn := v.fileName(name, reference(e.parent), flags, uint64(len(e.data)))
+	if e.runs != nil && !e.directory {
+		fn.AllocatedSize = e.runs[0].LengthInClusters * VolumeClusterSize
+	}
+	return fn
+}
+
+func (v *VolumeBuilder) fileName(name string, parent mft.FileReference, flags mft.FileAttribute, size uint64) mft.FileName {
+	return mft.FileName{
+		ParentFileReference: parent,
+		Creation:            v.time,
+		FileLastModified:    v.time,
+		MftLastModified:     v.time,
+		LastAccess:          v.time,
+		AllocatedSize:       uint64(align8(int(size))),
+		ActualSize:          size,
+		Flags:               flags,
+		Namespace:           mft.FileNameNamespaceWin32Dos,
+		Name:                name,
+	}
+}
+
+func reference(e *volumeEntry) mft.FileReference {
+	if e.record == 5 {
+		return RootReference
+	}
+	return mft.FileReference{RecordNumber: e.record, SequenceNumber: 1}
+}
+
+// indexEntries encodes the $I30 index entries of the directory, sorted by name, without the last entry. The entries of
+// the root directory include the metafiles.
+func (v *VolumeBuilder) indexEntries(dir *volumeEntry) []byte {
+	type indexed struct {
+		ref mft.FileReference
+		fn  mft.FileName
+	}
+	entries := make([]indexed, 0)
+	if dir.record == 5 {
+		for i, name := range metafiles {
+			ref := mft.FileReference{RecordNumber: uint64(i), SequenceNumber: uint16(i)}
+			entries = append(entries, indexed{ref, v.fileName(name, RootReference, mft.FileAttributeHidden|mft.FileAttributeSystem, 0)})
+		}
+	}
+	for _, c := range dir.children {
+		entries = append(entries, indexed{reference(c), v.entryFileName(c)})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return strings.ToUpper(entries[i].fn.Name) < strings.ToUpper(entries[j].fn.Name)
+	})
+
+	b := make([]byte, 0)
+	for _, e := range entries {
+		content := EncodeFileName(e.fn)
+		entry := make([]byte, align8(0x10+len(content)))
+		putFileReference(entry[0x00:], e.ref)
+		binary.LittleEndian.PutUint16(entry[0x08:], uint16(len(entry)))
+		binary.LittleEndian.PutUint16(entry[0x0A:], uint16(len(content)))
+		copy(entry[0x10:], content)
+		b = append(b, entry...)
+	}
+	return b
+}
+
+// withIndex adds the $I30 index of a directory with the entries. When runs is not nil, the entries are stored in an
+// index block in those clusters and the $INDEX_ROOT only refers to it.
+func (b *RecordBuilder) withIndex(entries []byte, runs []mft.DataRun) *RecordBuilder {
+	var flags uint32
+	var node []byte
+	if runs == nil {
+		node = append(entries, lastIndexEntry(false)...)
+	} else {
+		flags = 1
+		node = lastIndexEntry(true)
+	}
+	root := make([]byte, 0x20+len(node))
+	binary.LittleEndian.PutUint32(root[0x00:], uint32(mft.AttributeTypeFileName))
+	binary.LittleEndian.PutUint32(root[0x04:], uint32(mft.CollationTypeFileName))
+	binary.LittleEndian.PutUint32(root[0x08:], VolumeIndexSize)
+	binary.LittleEndian.PutUint32(root[0x0C:], VolumeIndexSize/VolumeClusterSize)
+	binary.LittleEndian.PutUint32(root[0x10:], 0x10)
+	binary.LittleEndian.PutUint32(root[0x14:], uint32(0x10+len(node)))
+	binary.LittleEndian.PutUint32(root[0x18:], uint32(0x10+len(node)))
+	binary.LittleEndian.PutUint32(root[0x1C:], flags)
+	copy(root[0x20:], node)
+	b.WithAttribute(mft.Attribute{Type: mft.AttributeTypeIndexRoot, Resident: true, Name: "$I30", AttributeId: b.nextAttributeId, Data: root})
+	if runs != nil {
+		b.WithAttribute(mft.Attribute{
+			Type:            mft.AttributeTypeIndexAllocation,
+			Name:            "$I30",
+			AttributeId:     b.nextAttributeId,
+			AllocatedSize:   VolumeIndexSize,
+			ActualSize:      VolumeIndexSize,
+			InitializedSize: VolumeIndexSize,
+			Data:            EncodeDataRuns(runs),
+		})
+		b.WithAttribute(mft.Attribute{Type: mft.AttributeTypeBitmap, Resident: true, Name: "$I30", AttributeId: b.nextAttributeId, Data: []byte{1, 0, 0, 0, 0, 0, 0, 0}})
+	}
+	return b
+}
+
+func lastIndexEntry(subNode bool) []byte {
+	if !subNode {
+		b := make([]byte, 0x10)
+		binary.LittleEndian.PutUint16(b[0x08:], 0x10)
+		binary.LittleEndian.PutUint32(b[0x0C:], 0x02)
+		return b
+	}
+	// The sub node is the index block at VCN 0
+	b := make([]byte, 0x18)
+	binary.LittleEndian.PutUint16(b[0x08:], 0x18)
+	binary.LittleEndian.PutUint32(b[0x0C:], 0x03)
+	return b
+}
+
+// indexBlock creates an index block ("INDX") at VCN 0 containing the entries, including the fixup.
+func indexBlock(entries []byte) []byte {
+	sectors := VolumeIndexSize / VolumeSectorSize
+	updateSequenceOffset := 0x28
+	entriesOffset := align8(updateSequenceOffset + (sectors+1)*2)
+	node := append(entries, lastIndexEntry(false)...)
+	if entriesOffset+len(node) > VolumeIndexSize {
+		panic(fmt.Sprintf("mfttest: index entries of %d bytes do not fit in an index block of %d bytes", len(node), VolumeIndexSize))
+	}
+
+	b := make([]byte, VolumeIndexSize)
+	copy(b, "INDX")
+	binary.LittleEndian.PutUint16(b[0x04:], uint16(updateSequenceOffset))
+	binary.LittleEndian.PutUint16(b[0x06:], uint16(sectors+1))
+	binary.LittleEndian.PutUint32(b[0x18:], uint32(entriesOffset-0x18))
+	binary.LittleEndian.PutUint32(b[0x1C:], uint32(entriesOffset-0x18+len(node)))
+	binary.LittleEndian.PutUint32(b[0x20:], uint32(VolumeIndexSize-0x18))
+	copy(b[entriesOffset:], node)
+
+	binary.LittleEndian.PutUint16(b[updateSequenceOffset:], 1)
+	for i := 1; i <= sectors; i++ {
+		end := i*VolumeSectorSize - 2
+		copy(b[updateSequenceOffset+i*2:], b[end:end+2])
+		binary.LittleEndian.PutUint16(b[end:], 1)
+	}
+	return b
+}
+
+// bootSector creates an NTFS boot sector for a volume of the number of clusters.
+func bootSector(clusters uint64, mftCluster uint64, mirrorCluster uint64, serial uint64) []byte {
+	b := make([]byte, VolumeSectorSize)
+	copy(b, []byte{0xEB, 0x52, 0x90})
+	copy(b[0x03:], "NTFS    ")
+	binary.LittleEndian.PutUint16(b[0x0B:], VolumeSectorSize)
+	b[0x0D] = VolumeClusterSize / VolumeSectorSize
+	b[0x15] = 0xF8
+	binary.LittleEndian.PutUint64(b[0x28:], clusters*VolumeClusterSize/VolumeSectorSize-1)
+	binary.LittleEndian.PutUint64(b[0x30:], mftCluster)
+	binary.LittleEndian.PutUint64(b[0x38:], mirrorCluster)
+	b[0x40] = 0xF6 // 2^10 = 1024 bytes per record
+	b[0x44] = VolumeIndexSize / VolumeClusterSize
+	binary.LittleEndian.PutUint64(b[0x48:], serial)
+	b[0x1FE] = 0x55
+	b[0x1FF] = 0xAA
+	return b
+}
@@ -0,0 +1,187 @@
+package mfttest_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/archive"
+	"github.com/t9t/gomft/bootsect"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/mfttest"
+	"github.com/t9t/gomft/pipeline"
+)
+
+func testVolume() *mfttest.Volume {
+	v := mfttest.NewVolume().
+		WithLabel("TEST").
+		WithTime(mfttest.SampleTime).
+		WithFile("/hello.txt", []byte("hello")).
+		WithFile("/Users/bob/big.bin", bigData()).
+		WithDirectory("/empty")
+	for i := 0; i < 20; i++ {
+		v.WithFile(fmt.Sprintf("/many/file-%02d.txt", i), []byte{byte(i)})
+	}
+	return v.Build()
+}
+
+func bigData() []byte {
+	b := make([]byte, 10000)
+	for i := range b {
+		b[i] = byte(i % 251)
+	}
+	return b
+}
+
+func TestVolume_BootSector(t *testing.T) {
+	v := testVolume()
+	bs, err := bootsect.Parse(v.Data[:512])
+	require.Nilf(t, err, "unable to parse boot sector: %v", err)
+	assert.Equal(t, "NTFS    ", bs.OemId)
+	assert.Equal(t, 512, bs.BytesPerSector)
+	assert.Equal(t, 8, bs.SectorsPerCluster)
+	assert.Equal(t, 1024, bs.FileRecordSegmentSizeInBytes)
+	assert.Equal(t, 4096, bs.IndexBufferSizeInBytes)
+	assert.Equal(t, uint64(len(v.Data)/512-1), bs.TotalSectors)
+	assert.Equal(t, v.Data[:512], v.Data[len(v.Data)-512:], "backup boot sector")
+
+	// Locate the MFT like a reader of the volume would
+	mftOffset := int64(bs.MftClusterNumber) * 4096
+	assert.Equal(t, v.MftOffset, mftOffset)
+	record, err := mft.ParseRecordWithOptions(v.Data[mftOffset:mftOffset+1024], mft.ParseOptions{Strict: true})
+	require.Nilf(t, err, "unable to parse $MFT record: %v", err)
+	data, ok := record.FindFirstAttribute(mft.AttributeTypeData)
+	require.True(t, ok)
+	runs, err := mft.ParseDataRuns(data.Data)
+	require.Nilf(t, err, "unable to parse $MFT data runs: %v", err)
+	fragments := mft.DataRunsToFragments(runs, 4096)
+	require.Equal(t, 1, len(fragments))
+	assert.Equal(t, v.MftOffset, fragments[0].Offset)
+	assert.Equal(t, v.MftLength, fragments[0].Length)
+	assert.Equal(t, uint64(v.MftLength), data.ActualSize)
+
+	mirror := int64(bs.MftMirrorClusterNumber) * 4096
+	assert.Equal(t, v.Mft()[:4096], v.Data[mirror:mirror+4096], "$MFTMirr")
+}
+
+func TestVolume_Volume(t *testing.T) {
+	v := testVolume()
+	record, err := mft.ParseRecord(v.Mft()[mft.VolumeRecordNumber*1024:][:1024])
+	require.Nilf(t, err, "unable to parse $Volume record: %v", err)
+	info, err := mft.ParseVolumeRecord(record)
+	require.Nilf(t, err, "unable to parse $Volume: %v", err)
+	assert.Equal(t, "TEST", info.Label)
+	assert.Equal(t, "3.1", info.Version())
+}
+
+func TestVolume_Paths(t *testing.T) {
+	v := testVolume()
+	mftData := bytes.NewReader(v.Mft())
+	paths := make(map[string]uint64)
+	_, err := pipeline.Run(mftData, pipeline.Options{}, pipeline.Paths(pipeline.NewPathResolver(mftData, 1024, 0)),
+		pipeline.StageFunc(func(item *pipeline.Item) (bool, error) {
+			paths[item.Path] = item.Record.FileReference.RecordNumber
+			return true, nil
+		}))
+	require.Nilf(t, err, "unable to run pipeline: %v", err)
+
+	for _, p := range []string{"/hello.txt", "/Users", "/Users/bob", "/Users/bob/big.bin", "/empty", "/many/file-19.txt"} {
+		assert.Equalf(t, v.Records[p], paths[p], "record number of %s", p)
+	}
+	assert.Equal(t, uint64(0), paths["/$MFT"])
+	assert.Equal(t, 12+6+20, len(paths))
+}
+
+func TestVolume_Contents(t *testing.T) {
+	v := testVolume()
+	mftData := bytes.NewReader(v.Mft())
+	out := &bytes.Buffer{}
+	w := archive.NewTarWriter(out)
+	_, err := pipeline.Run(mftData, pipeline.Options{},
+		pipeline.Filter(func(item *pipeline.Item) bool {
+			return item.Record.FileReference.RecordNumber >= mfttest.FirstUserRecord
+		}),
+		pipeline.Paths(pipeline.NewPathResolver(mftData, 1024, 0)),
+		archive.Stage(w, archive.Options{Volume: bytes.NewReader(v.Data), BytesPerCluster: 4096}))
+	require.Nilf(t, err, "unable to run pipeline: %v", err)
+	require.Nil(t, w.Close())
+
+	contents := make(map[string][]byte)
+	r := tar.NewReader(out)
+	for {
+		h, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		require.Nilf(t, err, "unable to read archive: %v", err)
+		b, err := ioutil.ReadAll(r)
+		require.Nilf(t, err, "unable to read archive: %v", err)
+		contents[h.Name] = b
+	}
+	assert.Equal(t, []byte("hello"), contents["hello.txt"])
+	assert.Equal(t, bigData(), contents["Users/bob/big.bin"])
+	assert.Equal(t, []byte{7}, contents["many/file-07.txt"])
+}
+
+func TestVolume_Index(t *testing.T) {
+	v := testVolume()
+	names := func(entries []mft.IndexEntry) []string {
+		ret := make([]string, 0)
+		for _, e := range entries {
+			if e.Flags&0x02 == 0 {
+				ret = append(ret, e.FileName.Name)
+			}
+		}
+		return ret
+	}
+
+	// A small directory has its entries in the $INDEX_ROOT
+	users := record(t, v, "/Users")
+	rootAttribute, ok := users.FindFirstAttribute(mft.AttributeTypeIndexRoot)
+	require.True(t, ok)
+	assert.Equal(t, "$I30", rootAttribute.Name)
+	root, err := mft.ParseIndexRoot(rootAttribute.Data)
+	require.Nilf(t, err, "unable to parse $INDEX_ROOT: %v", err)
+	assert.Equal(t, mft.CollationTypeFileName, root.CollationType)
+	assert.Equal(t, []string{"bob"}, names(root.Entries))
+	assert.Equal(t, v.Records["/Users/bob"], root.Entries[0].FileReference.RecordNumber)
+
+	// A larger directory has its entries in an index block in the $INDEX_ALLOCATION
+	many := record(t, v, "/many")
+	rootAttribute, _ = many.FindFirstAttribute(mft.AttributeTypeIndexRoot)
+	root, err = mft.ParseIndexRoot(rootAttribute.Data)
+	require.Nilf(t, err, "unable to parse $INDEX_ROOT: %v", err)
+	require.Equal(t, 1, len(root.Entries))
+	assert.Equal(t, uint32(0x03), root.Entries[0].Flags)
+
+	allocation, ok := many.FindFirstAttribute(mft.AttributeTypeIndexAllocation)
+	require.True(t, ok)
+	runs, err := mft.ParseDataRuns(allocation.Data)
+	require.Nilf(t, err, "unable to parse data runs: %v", err)
+	offset := runs[0].OffsetCluster * 4096
+	data, err := mft.ApplyFixup(append([]byte(nil), v.Data[offset:offset+4096]...))
+	require.Nilf(t, err, "unable to apply fixup: %v", err)
+	block, err := mft.ParseIndexBlock(data)
+	require.Nilf(t, err, "unable to parse index block: %v", err)
+	assert.Equal(t, "INDX", block.Signature)
+	entries, err := mft.ParseIndexEntries(data[0x18+block.EntryOffset : 0x18+block.TotalEntrySize])
+	require.Nilf(t, err, "unable to parse index entries: %v", err)
+	found := names(entries)
+	assert.Equal(t, 20, len(found))
+	assert.True(t, sort.StringsAreSorted(found))
+	assert.Equal(t, "file-00.txt", found[0])
+}
+
+func record(t *testing.T, v *mfttest.Volume, p string) mft.Record {
+	number, ok := v.Records[p]
+	require.Truef(t, ok, "no record for %s", p)
+	r, err := mft.ParseRecord(v.Mft()[number*1024:][:1024])
+	require.Nilf(t, err, "unable to parse record of %s: %v", p, err)
+	return r
+}
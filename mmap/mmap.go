@@ -0,0 +1,106 @@
+/*
+	Package mmap provides read-only memory mapped access to files, such as MFT dumps and volume images. Parsing records
+	directly from mapped pages avoids a read system call and a copy for every record, which speeds up repeated analyses
+	of large files considerably.
+
+	Basic usage
+
+	Open a file using mmap.Open(), then either use its data directly or read it using the io.ReaderAt or io.ReadSeeker
+	interfaces. The data must not be used anymore after the File is closed.
+			// Error handling left out for brevity
+			f, err := mmap.Open("/path/to/mft.dump")
+			defer f.Close()
+			results := mft.ParseAllBytes(f.Bytes(), mft.ParseAllOptions{})
+
+	Implementation notes
+
+	Memory mapping is supported on Windows and Unix-like systems; on other platforms Open returns an error. Because the
+	whole file is mapped at once, files larger than the address space (for example images of several GB on 32-bit
+	systems) cannot be mapped.
+*/
+package mmap
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+const maxInt = int64(^uint(0) >> 1)
+
+// File is a read-only memory mapped file.
+type File struct {
+	data  []byte
+	unmap func() error
+}
+
+// Open maps the file at path into memory. Besides regular files, block devices can be mapped on platforms that
+// support it.
+func Open(path string) (*File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// Seek instead of Stat, because Stat reports a size of 0 for block devices
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, fmt.Errorf("unable to determine size of %s: %v", path, err)
+	}
+	if size > maxInt {
+		return nil, fmt.Errorf("file size %d exceeds the maximum mappable size %d", size, maxInt)
+	}
+	if size == 0 {
+		return &File{data: []byte{}, unmap: func() error { return nil }}, nil
+	}
+
+	data, unmap, err := mmap(f, int(size))
+	if err != nil {
+		return nil, fmt.Errorf("unable to map %s into memory: %v", path, err)
+	}
+	return &File{data: data, unmap: unmap}, nil
+}
+
+// Bytes returns the mapped data of the file. The returned slice is read-only: writing to it will crash the program.
+// It must not be used after the File is closed.
+func (f *File) Bytes() []byte {
+	return f.data
+}
+
+// Len returns the size of the mapped file in bytes.
+func (f *File) Len() int {
+	return len(f.data)
+}
+
+// ReadAt implements io.ReaderAt by copying from the mapped data.
+func (f *File) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset %d", off)
+	}
+	if off >= int64(len(f.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, f.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Reader returns a new io.ReadSeeker over the mapped data. Reads do not make any system calls.
+func (f *File) Reader() *bytes.Reader {
+	return bytes.NewReader(f.data)
+}
+
+// Close unmaps the file. Any slices obtained from Bytes() are invalid afterwards.
+func (f *File) Close() error {
+	if f.unmap == nil {
+		return nil
+	}
+	err := f.unmap()
+	f.data = nil
+	f.unmap = nil
+	return err
+}
@@ -0,0 +1,66 @@
+package mmap_test
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/mmap"
+)
+
+func TestOpen(t *testing.T) {
+	path := writeTempFile(t, []byte("FILE0123456789"))
+	defer os.Remove(path)
+
+	f, err := mmap.Open(path)
+	require.Nilf(t, err, "unable to map file: %v", err)
+	defer f.Close()
+
+	assert.Equal(t, []byte("FILE0123456789"), f.Bytes())
+	assert.Equal(t, 14, f.Len())
+
+	buf := make([]byte, 4)
+	n, err := f.ReadAt(buf, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, n)
+	assert.Equal(t, []byte("6789"), buf)
+
+	n, err = f.ReadAt(buf, 12)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, 2, n)
+
+	data, err := ioutil.ReadAll(f.Reader())
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("FILE0123456789"), data)
+
+	assert.Nil(t, f.Close())
+	assert.Nil(t, f.Bytes())
+}
+
+func TestOpen_Empty(t *testing.T) {
+	path := writeTempFile(t, []byte{})
+	defer os.Remove(path)
+
+	f, err := mmap.Open(path)
+	require.Nilf(t, err, "unable to map file: %v", err)
+	assert.Equal(t, 0, f.Len())
+	assert.Nil(t, f.Close())
+}
+
+func TestOpen_NotExists(t *testing.T) {
+	_, err := mmap.Open(filepath.Join(os.TempDir(), "gomft-mmap-does-not-exist"))
+	assert.NotNil(t, err)
+}
+
+func writeTempFile(t *testing.T, data []byte) string {
+	f, err := ioutil.TempFile("", "gomft-mmap-test")
+	require.Nilf(t, err, "unable to create temp file: %v", err)
+	_, err = f.Write(data)
+	require.Nilf(t, err, "unable to write temp file: %v", err)
+	require.Nil(t, f.Close())
+	return f.Name()
+}
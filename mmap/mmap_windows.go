@@ -0,0 +1,41 @@
+package mmap
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+func mmap(f *os.File, size int) ([]byte, func() error, error) {
+	h, err := syscall.CreateFileMapping(syscall.Handle(f.Fd()), nil, syscall.PAGE_READONLY, 0, 0, nil)
+	if err != nil {
+		return nil, nil, os.NewSyscallError("CreateFileMapping", err)
+	}
+	addr, err := syscall.MapViewOfFile(h, syscall.FILE_MAP_READ, 0, 0, uintptr(size))
+	if err != nil {
+		syscall.CloseHandle(h)
+		return nil, nil, os.NewSyscallError("MapViewOfFile", err)
+	}
+
+	var data []byte
+	header := (*sliceHeader)(unsafe.Pointer(&data))
+	header.data = addr
+	header.len = size
+	header.cap = size
+
+	unmap := func() error {
+		err := syscall.UnmapViewOfFile(addr)
+		if closeErr := syscall.CloseHandle(h); err == nil {
+			err = closeErr
+		}
+		return err
+	}
+	return data, unmap, nil
+}
+
+// sliceHeader mirrors the runtime representation of a slice, see reflect.SliceHeader.
+type sliceHeader struct {
+	data uintptr
+	len  int
+	cap  int
+}
@@ -0,0 +1,24 @@
+package outfile
+
+import (
+	"os"
+	"syscall"
+)
+
+// directFile sets O_DIRECT on the file. Setting it afterwards, instead of opening the file with it, fails cleanly on
+// file systems which do not support it, before anything has been created.
+func directFile(f *os.File) (*os.File, error) {
+	fd := f.Fd()
+	flags, _, errno := syscall.Syscall(syscall.SYS_FCNTL, fd, syscall.F_GETFL, 0)
+	if errno != 0 {
+		return nil, os.NewSyscallError("fcntl", errno)
+	}
+	_, _, errno = syscall.Syscall(syscall.SYS_FCNTL, fd, syscall.F_SETFL, flags|syscall.O_DIRECT)
+	if errno == syscall.EINVAL {
+		return nil, errDirectUnsupported
+	}
+	if errno != 0 {
+		return nil, os.NewSyscallError("fcntl", errno)
+	}
+	return f, nil
+}
@@ -0,0 +1,9 @@
+// +build !linux,!windows
+
+package outfile
+
+import "os"
+
+func directFile(f *os.File) (*os.File, error) {
+	return nil, errDirectUnsupported
+}
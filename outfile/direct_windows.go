@@ -0,0 +1,28 @@
+package outfile
+
+import (
+	"os"
+	"syscall"
+)
+
+const (
+	fileFlagWriteThrough = 0x80000000
+	fileFlagNoBuffering  = 0x20000000
+)
+
+// directFile opens the file again with FILE_FLAG_NO_BUFFERING, which can only be set when opening a file, and closes
+// the original.
+func directFile(f *os.File) (*os.File, error) {
+	name, err := syscall.UTF16PtrFromString(f.Name())
+	if err != nil {
+		return nil, err
+	}
+	h, err := syscall.CreateFile(name, syscall.GENERIC_READ|syscall.GENERIC_WRITE,
+		syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE, nil, syscall.OPEN_EXISTING,
+		fileFlagNoBuffering|fileFlagWriteThrough, 0)
+	if err != nil {
+		return nil, os.NewSyscallError("CreateFile", err)
+	}
+	f.Close()
+	return os.NewFile(uintptr(h), f.Name()), nil
+}
@@ -0,0 +1,45 @@
+package outfile
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+func mapFile(f *os.File, size int) ([]byte, func() error, error) {
+	h, err := syscall.CreateFileMapping(syscall.Handle(f.Fd()), nil, syscall.PAGE_READWRITE,
+		uint32(uint64(size)>>32), uint32(size), nil)
+	if err != nil {
+		return nil, nil, os.NewSyscallError("CreateFileMapping", err)
+	}
+	addr, err := syscall.MapViewOfFile(h, syscall.FILE_MAP_WRITE, 0, 0, uintptr(size))
+	if err != nil {
+		syscall.CloseHandle(h)
+		return nil, nil, os.NewSyscallError("MapViewOfFile", err)
+	}
+
+	var data []byte
+	header := (*sliceHeader)(unsafe.Pointer(&data))
+	header.data = addr
+	header.len = size
+	header.cap = size
+
+	unmap := func() error {
+		err := syscall.FlushViewOfFile(addr, uintptr(size))
+		if unmapErr := syscall.UnmapViewOfFile(addr); err == nil {
+			err = unmapErr
+		}
+		if closeErr := syscall.CloseHandle(h); err == nil {
+			err = closeErr
+		}
+		return err
+	}
+	return data, unmap, nil
+}
+
+// sliceHeader mirrors the runtime representation of a slice, see reflect.SliceHeader.
+type sliceHeader struct {
+	data uintptr
+	len  int
+	cap  int
+}
@@ -0,0 +1,239 @@
+/*
+	Package outfile writes large files of which the size is known in advance, such as MFT dumps. The file is
+	preallocated to its full size before writing, so the file system can reserve the space in one go instead of
+	extending (and fragmenting) the file with every write. Besides regular writes, the data can be copied into a memory
+	mapping of the file, or written with large unbuffered writes which bypass the page cache.
+
+	Basic usage
+
+	Create a file with the expected size and the Mode to write with, write the data to it, and close it. When fewer
+	bytes are written than expected, Close truncates the file to the data that was written.
+			// Error handling left out for brevity
+			f, err := outfile.Create("/path/to/mft.dump", outfile.Options{Size: size, Mode: outfile.Direct})
+			_, err = io.Copy(f, src)
+			err = f.Close()
+
+	Implementation notes
+
+	On Linux, the file is preallocated using fallocate, which reserves the blocks without writing them; when the file
+	system does not support it, and on other platforms, the size of the file is set using ftruncate (SetEndOfFile on
+	Windows, which allocates the clusters on NTFS).
+
+	Direct mode uses O_DIRECT on Linux and FILE_FLAG_NO_BUFFERING on Windows, and writes the data in aligned blocks of
+	4 MB. It is not available on other platforms, nor on file systems which do not support it (such as tmpfs); Create
+	then falls back to regular writes, which Mode() reports. Mapped mode maps the whole file at once, so files larger
+	than the address space cannot be written that way.
+*/
+package outfile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"unsafe"
+)
+
+const (
+	maxInt = int64(^uint(0) >> 1)
+
+	// alignment is the alignment of the buffer, offsets and sizes of unbuffered writes, which is a multiple of the
+	// sector size of all common devices.
+	alignment = 4096
+	// directBufferSize is the size of the unbuffered writes.
+	directBufferSize = 4 * 1024 * 1024
+)
+
+// errDirectUnsupported is returned by directFile when unbuffered writes are not supported for the file.
+var errDirectUnsupported = errors.New("unbuffered writes are not supported")
+
+// Mode determines how the data is written to the file.
+type Mode int
+
+// Modes of writing.
+const (
+	Buffered Mode = iota // regular writes through the page cache
+	Mapped               // copies into a shared memory mapping of the file
+	Direct               // large aligned writes which bypass the page cache
+)
+
+// String returns the name of the Mode, as accepted by ParseMode.
+func (m Mode) String() string {
+	switch m {
+	case Buffered:
+		return "buffered"
+	case Mapped:
+		return "mmap"
+	case Direct:
+		return "direct"
+	}
+	return "unknown"
+}
+
+// ParseMode returns the Mode with the name, which is "buffered", "mmap" or "direct".
+func ParseMode(name string) (Mode, error) {
+	for _, m := range []Mode{Buffered, Mapped, Direct} {
+		if m.String() == name {
+			return m, nil
+		}
+	}
+	return Buffered, fmt.Errorf("unknown write mode %q (expected buffered, mmap or direct)", name)
+}
+
+// Options configures Create.
+type Options struct {
+	// Size is the number of bytes which will be written; the file is preallocated to this size.
+	Size int64
+	// Overwrite allows an existing file to be overwritten; otherwise Create fails when the file exists.
+	Overwrite bool
+	// Mode determines how the data is written.
+	Mode Mode
+}
+
+// File is an output file being written. It implements io.WriteCloser, and is not safe for concurrent use.
+type File struct {
+	f       *os.File
+	mode    Mode
+	size    int64
+	written int64
+
+	data  []byte       // the mapped file in Mapped mode
+	unmap func() error // unmaps the data
+	buf   []byte       // the aligned buffer in Direct mode
+	n     int          // the number of bytes in buf
+}
+
+// Create creates the file at path and preallocates it to the size of the Options.
+func Create(path string, opts Options) (*File, error) {
+	if opts.Size < 0 {
+		return nil, fmt.Errorf("negative size %d", opts.Size)
+	}
+	if opts.Mode == Mapped && opts.Size > maxInt {
+		return nil, fmt.Errorf("size %d exceeds the maximum mappable size %d", opts.Size, maxInt)
+	}
+	flag := os.O_RDWR | os.O_CREATE | os.O_EXCL
+	if opts.Overwrite {
+		flag = os.O_RDWR | os.O_CREATE | os.O_TRUNC
+	}
+	f, err := os.OpenFile(path, flag, 0666)
+	if err != nil {
+		return nil, err
+	}
+	if err := preallocate(f, opts.Size); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("unable to preallocate %d bytes for %s: %v", opts.Size, path, err)
+	}
+
+	out := &File{f: f, mode: opts.Mode, size: opts.Size}
+	switch opts.Mode {
+	case Mapped:
+		if opts.Size == 0 {
+			out.data, out.unmap = []byte{}, func() error { return nil }
+			break
+		}
+		if out.data, out.unmap, err = mapFile(f, int(opts.Size)); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("unable to map %s into memory: %v", path, err)
+		}
+	case Direct:
+		direct, err := directFile(f)
+		if err == errDirectUnsupported {
+			out.mode = Buffered
+			break
+		}
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("unable to open %s for unbuffered writes: %v", path, err)
+		}
+		out.f = direct
+		out.buf = alignedBuffer(directBufferSize)
+	}
+	return out, nil
+}
+
+// Mode returns the Mode the file is written with, which is Buffered when Direct was requested but is not supported.
+func (f *File) Mode() Mode {
+	return f.mode
+}
+
+// Write writes the data at the end of the data written so far. In Mapped mode, no more than the size of the Options
+// can be written.
+func (f *File) Write(p []byte) (int, error) {
+	switch f.mode {
+	case Mapped:
+		n := copy(f.data[f.written:], p)
+		f.written += int64(n)
+		if n < len(p) {
+			return n, fmt.Errorf("unable to write beyond the preallocated size of %d bytes", f.size)
+		}
+		return n, nil
+	case Direct:
+		total := 0
+		for total < len(p) {
+			n := copy(f.buf[f.n:], p[total:])
+			f.n += n
+			total += n
+			f.written += int64(n)
+			if f.n == len(f.buf) {
+				if err := f.flush(); err != nil {
+					return total, err
+				}
+			}
+		}
+		return total, nil
+	}
+	n, err := f.f.Write(p)
+	f.written += int64(n)
+	return n, err
+}
+
+// flush writes the buffered data of Direct mode, padded with zeroes to the alignment.
+func (f *File) flush() error {
+	size := f.n
+	if rem := size % alignment; rem != 0 {
+		size += alignment - rem
+		for i := f.n; i < size; i++ {
+			f.buf[i] = 0
+		}
+	}
+	_, err := f.f.Write(f.buf[:size])
+	f.n = 0
+	return err
+}
+
+// Close writes any remaining data, truncates the file to the number of bytes written when it differs from the
+// preallocated size, and closes it.
+func (f *File) Close() error {
+	if f.f == nil {
+		return nil
+	}
+	var err error
+	keep := func(e error) {
+		if err == nil {
+			err = e
+		}
+	}
+	if f.unmap != nil {
+		keep(f.unmap())
+		f.data, f.unmap = nil, nil
+	}
+	if f.n > 0 {
+		keep(f.flush())
+	}
+	if f.written != f.size || f.mode == Direct {
+		// In Direct mode, the last write may have been padded beyond the data
+		keep(f.f.Truncate(f.written))
+	}
+	keep(f.f.Close())
+	f.f = nil
+	return err
+}
+
+// alignedBuffer returns a buffer of the size which starts at an address aligned to the alignment.
+func alignedBuffer(size int) []byte {
+	b := make([]byte, size+alignment)
+	offset := int(uintptr(unsafe.Pointer(&b[0])) & (alignment - 1))
+	if offset != 0 {
+		offset = alignment - offset
+	}
+	return b[offset : offset+size]
+}
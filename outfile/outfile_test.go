@@ -0,0 +1,94 @@
+package outfile_test
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/outfile"
+)
+
+func TestCreate(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	data := make([]byte, 5*1024*1024+123)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	for _, mode := range []outfile.Mode{outfile.Buffered, outfile.Mapped, outfile.Direct} {
+		path := filepath.Join(dir, mode.String())
+		f, err := outfile.Create(path, outfile.Options{Size: int64(len(data)), Mode: mode})
+		require.Nilf(t, err, "unable to create %v file: %v", mode, err)
+		t.Logf("mode %v, writing with %v", mode, f.Mode())
+
+		// Write in chunks which are not aligned
+		for i := 0; i < len(data); i += 1000 * 1000 {
+			end := i + 1000*1000
+			if end > len(data) {
+				end = len(data)
+			}
+			n, err := f.Write(data[i:end])
+			require.Nilf(t, err, "unable to write in %v mode: %v", mode, err)
+			require.Equal(t, end-i, n)
+		}
+		require.Nil(t, f.Close())
+		assert.Nil(t, f.Close(), "closing again does nothing")
+
+		written, err := ioutil.ReadFile(path)
+		require.Nil(t, err)
+		assert.Equalf(t, data, written, "data written in %v mode", mode)
+
+		// Existing files are only overwritten when allowed
+		_, err = outfile.Create(path, outfile.Options{Mode: mode})
+		assert.NotNil(t, err)
+	}
+}
+
+func TestCreate_ShortWrite(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "out")
+	require.Nil(t, ioutil.WriteFile(path, []byte("existing"), 0666))
+
+	for _, mode := range []outfile.Mode{outfile.Buffered, outfile.Mapped, outfile.Direct} {
+		f, err := outfile.Create(path, outfile.Options{Size: 10000, Overwrite: true, Mode: mode})
+		require.Nilf(t, err, "unable to create %v file: %v", mode, err)
+		_, err = f.Write([]byte("hello"))
+		require.Nil(t, err)
+		require.Nil(t, f.Close())
+
+		written, err := ioutil.ReadFile(path)
+		require.Nil(t, err)
+		assert.Equalf(t, []byte("hello"), written, "the file is truncated to the data written in %v mode", mode)
+	}
+}
+
+func TestCreate_MappedBeyondSize(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	f, err := outfile.Create(filepath.Join(dir, "out"), outfile.Options{Size: 4, Mode: outfile.Mapped})
+	require.Nil(t, err)
+	defer f.Close()
+	n, err := f.Write([]byte("hello"))
+	assert.Equal(t, 4, n)
+	assert.EqualError(t, err, "unable to write beyond the preallocated size of 4 bytes")
+}
+
+func TestParseMode(t *testing.T) {
+	for _, mode := range []outfile.Mode{outfile.Buffered, outfile.Mapped, outfile.Direct} {
+		parsed, err := outfile.ParseMode(mode.String())
+		require.Nil(t, err)
+		assert.Equal(t, mode, parsed)
+	}
+	_, err := outfile.ParseMode("fast")
+	assert.EqualError(t, err, `unknown write mode "fast" (expected buffered, mmap or direct)`)
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "outfile")
+	require.Nil(t, err)
+	return dir
+}
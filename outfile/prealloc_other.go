@@ -0,0 +1,9 @@
+// +build !linux
+
+package outfile
+
+import "os"
+
+func preallocate(f *os.File, size int64) error {
+	return f.Truncate(size)
+}
@@ -0,0 +1,298 @@
+/*
+	Package permissions reports the owner and access control list of each file on a volume, by combining the security
+	descriptors in the $SDS stream of $Secure with the records of the MFT streaming through a pipeline. The report can
+	be written as CSV or JSON for permission audits, such as finding files writable by everyone.
+
+	Basic usage
+
+	Load the security descriptors from the data of the $SDS stream, add the Stage to a pipeline after the Paths stage,
+	and write each Entry.
+			// Error handling left out for brevity
+			descriptors, err := permissions.LoadDescriptors(sds)
+			w := permissions.NewCSVWriter(os.Stdout)
+			stats, err := pipeline.Run(in, pipeline.Options{},
+				pipeline.Paths(pipeline.NewPathResolver(in, 1024, 0)),
+				permissions.Stage(descriptors, w.Write))
+			err = w.Flush()
+
+	Implementation notes
+
+	Files refer to their security descriptor by the security ID in their $STANDARD_INFORMATION attribute. Records which
+	contain a $SECURITY_DESCRIPTOR attribute (as on volumes formatted before NTFS 3.0) use that descriptor instead.
+
+	The Summary of an Entry lists the rights granted to each trustee by the DACL, in a notation similar to that of
+	icacls: F (full control), M (modify), or a combination of R (read), W (write) and X (execute), with a hexadecimal
+	access mask for other combinations. Rights denied to a trustee are subtracted from the rights allowed to the same
+	trustee and listed separately. ACEs which are only inherited by children (inherit only) are ignored. The
+	membership of groups is not known, so the effective rights of a user can include those of the groups it is a member
+	of. Well-known SIDs are shown by name; other SIDs are shown as a string, since the names of accounts are not stored on
+	the volume.
+*/
+package permissions
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/pipeline"
+)
+
+// Entry describes the permissions of a single file.
+type Entry struct {
+	RecordNumber   uint64
+	SequenceNumber uint16
+	InUse          bool
+	Directory      bool
+	Path           string // the Path of the pipeline.Item, empty unless set by a previous stage
+	SecurityId     uint32 // the security ID in $STANDARD_INFORMATION, or 0 when the record has its own descriptor
+	Owner          string
+	Group          string
+	Summary        string // the rights granted by the DACL, see the package documentation
+	SDDL           string // the complete security descriptor in the Security Descriptor Definition Language
+	Error          string // the reason the descriptor could not be found or parsed, in which case the other fields are empty
+}
+
+// Descriptors contains the security descriptors of a volume by their security ID.
+type Descriptors struct {
+	descriptors map[uint32]descriptor
+}
+
+type descriptor struct {
+	sd  mft.SecurityDescriptor
+	err error
+}
+
+// LoadDescriptors parses the data of the $SDS stream of $Secure. Entries containing a descriptor which cannot be
+// parsed are kept, so their error is reported for each file referring to them.
+func LoadDescriptors(sds []byte) (*Descriptors, error) {
+	entries, err := mft.ParseSecurityDescriptorStream(sds)
+	if err != nil {
+		return nil, err
+	}
+	d := &Descriptors{descriptors: make(map[uint32]descriptor, len(entries))}
+	for _, e := range entries {
+		sd, err := mft.ParseSecurityDescriptor(e.Data)
+		d.descriptors[e.SecurityId] = descriptor{sd: sd, err: err}
+	}
+	return d, nil
+}
+
+// Len returns the number of security descriptors.
+func (d *Descriptors) Len() int {
+	return len(d.descriptors)
+}
+
+// Get returns the security descriptor with the security ID, or an error when it cannot be found or parsed.
+func (d *Descriptors) Get(securityId uint32) (mft.SecurityDescriptor, error) {
+	desc, ok := d.descriptors[securityId]
+	if !ok {
+		return mft.SecurityDescriptor{}, fmt.Errorf("security ID %d not found in $Secure", securityId)
+	}
+	if desc.err != nil {
+		return mft.SecurityDescriptor{}, fmt.Errorf("unable to parse security descriptor %d: %v", securityId, desc.err)
+	}
+	return desc.sd, nil
+}
+
+// Stage creates a pipeline.Stage that calls report with the Entry of each Item. It never drops Items; an error
+// returned by report stops the pipeline. Descriptors may be nil, in which case only records with their own
+// $SECURITY_DESCRIPTOR attribute can be reported without an error.
+func Stage(descriptors *Descriptors, report func(Entry) error) pipeline.Stage {
+	return pipeline.StageFunc(func(item *pipeline.Item) (bool, error) {
+		if err := report(NewEntry(descriptors, item)); err != nil {
+			return false, fmt.Errorf("unable to report permissions: %v", err)
+		}
+		return true, nil
+	})
+}
+
+// NewEntry creates the Entry of the Item, looking up its security descriptor in the Descriptors.
+func NewEntry(descriptors *Descriptors, item *pipeline.Item) Entry {
+	e := Entry{
+		RecordNumber:   item.Entry.RecordNumber,
+		SequenceNumber: item.Entry.SequenceNumber,
+		InUse:          item.Entry.InUse,
+		Directory:      item.Entry.Directory,
+		Path:           item.Path,
+	}
+	sd, err := itemDescriptor(descriptors, item, &e)
+	if err != nil {
+		e.Error = err.Error()
+		return e
+	}
+	if sd.Owner != nil {
+		e.Owner = TrusteeName(*sd.Owner)
+	}
+	if sd.Group != nil {
+		e.Group = TrusteeName(*sd.Group)
+	}
+	e.Summary = Summary(sd)
+	e.SDDL = sd.SDDL()
+	return e
+}
+
+func itemDescriptor(descriptors *Descriptors, item *pipeline.Item, e *Entry) (mft.SecurityDescriptor, error) {
+	if attrs := item.Record.FindAttributes(mft.AttributeTypeSecurityDescriptor); len(attrs) > 0 {
+		if !attrs[0].Resident {
+			return mft.SecurityDescriptor{}, fmt.Errorf("non-resident $SECURITY_DESCRIPTOR attributes are not supported")
+		}
+		sd, err := mft.ParseSecurityDescriptor(attrs[0].Data)
+		if err != nil {
+			return mft.SecurityDescriptor{}, fmt.Errorf("unable to parse $SECURITY_DESCRIPTOR: %v", err)
+		}
+		return sd, nil
+	}
+	if len(item.Record.FindAttributes(mft.AttributeTypeStandardInformation)) == 0 {
+		return mft.SecurityDescriptor{}, fmt.Errorf("the record has no $STANDARD_INFORMATION attribute")
+	}
+	e.SecurityId = item.Entry.SecurityId
+	if descriptors == nil {
+		return mft.SecurityDescriptor{}, fmt.Errorf("no descriptors available for security ID %d", e.SecurityId)
+	}
+	return descriptors.Get(e.SecurityId)
+}
+
+// wellKnownNames contains the names of well-known SIDs, as shown by Windows.
+var wellKnownNames = map[string]string{
+	"S-1-1-0":      `Everyone`,
+	"S-1-3-0":      `CREATOR OWNER`,
+	"S-1-3-1":      `CREATOR GROUP`,
+	"S-1-3-4":      `OWNER RIGHTS`,
+	"S-1-5-2":      `NT AUTHORITY\NETWORK`,
+	"S-1-5-4":      `NT AUTHORITY\INTERACTIVE`,
+	"S-1-5-6":      `NT AUTHORITY\SERVICE`,
+	"S-1-5-7":      `NT AUTHORITY\ANONYMOUS LOGON`,
+	"S-1-5-11":     `NT AUTHORITY\Authenticated Users`,
+	"S-1-5-18":     `NT AUTHORITY\SYSTEM`,
+	"S-1-5-19":     `NT AUTHORITY\LOCAL SERVICE`,
+	"S-1-5-20":     `NT AUTHORITY\NETWORK SERVICE`,
+	"S-1-5-32-544": `BUILTIN\Administrators`,
+	"S-1-5-32-545": `BUILTIN\Users`,
+	"S-1-5-32-546": `BUILTIN\Guests`,
+	"S-1-5-32-547": `BUILTIN\Power Users`,
+	"S-1-5-32-551": `BUILTIN\Backup Operators`,
+	"S-1-15-2-1":   `APPLICATION PACKAGE AUTHORITY\ALL APPLICATION PACKAGES`,
+	"S-1-15-2-2":   `APPLICATION PACKAGE AUTHORITY\ALL RESTRICTED APPLICATION PACKAGES`,
+	"S-1-5-80-956008885-3418522649-1831038044-1853292631-2271478464": `NT SERVICE\TrustedInstaller`,
+}
+
+// TrusteeName returns the name of a well-known SID, or the string form of other SIDs.
+func TrusteeName(sid mft.SID) string {
+	s := sid.String()
+	if name, ok := wellKnownNames[s]; ok {
+		return name
+	}
+	return s
+}
+
+// Access masks of the rights used in a Summary.
+const (
+	rightsFull    = 0x001F01FF
+	rightsModify  = 0x001301BF
+	rightsRead    = fileGenericRead
+	rightsWrite   = 0x00100116
+	rightsExecute = 0x001000A0
+
+	fileGenericRead    = 0x00120089
+	fileGenericWrite   = 0x00120116
+	fileGenericExecute = 0x001200A0
+
+	genericAll     = 0x10000000
+	genericExecute = 0x20000000
+	genericWrite   = 0x40000000
+	genericRead    = 0x80000000
+)
+
+// trustee contains the rights allowed and denied to a trustee.
+type trustee struct {
+	name    string
+	allowed uint32
+	denied  uint32
+}
+
+// Summary returns the rights granted by the DACL of the security descriptor, like "NT AUTHORITY\SYSTEM:F;
+// BUILTIN\Users:RX". See the package documentation for the notation.
+func Summary(sd mft.SecurityDescriptor) string {
+	if !sd.Control.Is(mft.SecurityDescriptorControlDaclPresent) || sd.Dacl == nil {
+		return "Everyone:F (no DACL)"
+	}
+	if len(sd.Dacl.Entries) == 0 {
+		return "(empty DACL, no access)"
+	}
+
+	trustees := make([]*trustee, 0)
+	bySID := make(map[string]*trustee)
+	for _, ace := range sd.Dacl.Entries {
+		if ace.SID == nil || ace.Flags.Is(mft.AceFlagsInheritOnly) {
+			continue
+		}
+		if ace.Type != mft.AceTypeAccessAllowed && ace.Type != mft.AceTypeAccessDenied {
+			continue
+		}
+		sid := ace.SID.String()
+		t, ok := bySID[sid]
+		if !ok {
+			t = &trustee{name: TrusteeName(*ace.SID)}
+			bySID[sid] = t
+			trustees = append(trustees, t)
+		}
+		if ace.Type == mft.AceTypeAccessAllowed {
+			t.allowed |= mapGeneric(ace.Mask)
+		} else {
+			t.denied |= mapGeneric(ace.Mask)
+		}
+	}
+
+	parts := make([]string, 0, len(trustees))
+	for _, t := range trustees {
+		if allowed := t.allowed &^ t.denied; allowed != 0 {
+			parts = append(parts, t.name+":"+formatRights(allowed))
+		}
+		if t.denied != 0 {
+			parts = append(parts, t.name+":DENY "+formatRights(t.denied))
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+// mapGeneric maps generic rights to the file rights they represent.
+func mapGeneric(mask uint32) uint32 {
+	mapped := mask &^ (genericAll | genericExecute | genericWrite | genericRead)
+	if mask&genericAll != 0 {
+		mapped |= rightsFull
+	}
+	if mask&genericRead != 0 {
+		mapped |= fileGenericRead
+	}
+	if mask&genericWrite != 0 {
+		mapped |= fileGenericWrite
+	}
+	if mask&genericExecute != 0 {
+		mapped |= fileGenericExecute
+	}
+	return mapped
+}
+
+func formatRights(mask uint32) string {
+	if mask&rightsFull == rightsFull {
+		return "F"
+	}
+	if mask&rightsModify == rightsModify {
+		return "M"
+	}
+	s := ""
+	if mask&rightsRead == rightsRead {
+		s += "R"
+	}
+	if mask&rightsWrite == rightsWrite {
+		s += "W"
+	}
+	if mask&rightsExecute == rightsExecute {
+		s += "X"
+	}
+	if s == "" {
+		return fmt.Sprintf("0x%x", mask)
+	}
+	return s
+}
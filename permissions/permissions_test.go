@@ -0,0 +1,169 @@
+package permissions_test
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/export"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/permissions"
+	"github.com/t9t/gomft/pipeline"
+)
+
+func sidBytes(authority byte, subAuthorities ...uint32) []byte {
+	b := make([]byte, 8+4*len(subAuthorities))
+	b[0], b[1], b[7] = 1, byte(len(subAuthorities)), authority
+	for i, a := range subAuthorities {
+		binary.LittleEndian.PutUint32(b[8+4*i:], a)
+	}
+	return b
+}
+
+func aceBytes(aceType byte, flags byte, mask uint32, sid []byte) []byte {
+	b := make([]byte, 8, 8+len(sid))
+	b[0], b[1] = aceType, flags
+	binary.LittleEndian.PutUint16(b[2:], uint16(8+len(sid)))
+	binary.LittleEndian.PutUint32(b[4:], mask)
+	return append(b, sid...)
+}
+
+// securityDescriptorBytes creates a security descriptor with the owner, group and a DACL with the ACEs.
+func securityDescriptorBytes(owner []byte, group []byte, aces ...[]byte) []byte {
+	b := make([]byte, 0x14)
+	b[0] = 1
+	binary.LittleEndian.PutUint16(b[2:], 0x8404)
+	binary.LittleEndian.PutUint32(b[0x04:], uint32(len(b)))
+	b = append(b, owner...)
+	binary.LittleEndian.PutUint32(b[0x08:], uint32(len(b)))
+	b = append(b, group...)
+	binary.LittleEndian.PutUint32(b[0x10:], uint32(len(b)))
+	acl := make([]byte, 8)
+	acl[0] = 2
+	for _, ace := range aces {
+		acl = append(acl, ace...)
+	}
+	binary.LittleEndian.PutUint16(acl[2:], uint16(len(acl)))
+	binary.LittleEndian.PutUint16(acl[4:], uint16(len(aces)))
+	return append(b, acl...)
+}
+
+// sdsBytes creates $SDS stream data with the security descriptors, using security IDs starting at 0x100.
+func sdsBytes(descriptors ...[]byte) []byte {
+	b := make([]byte, 0)
+	for i, sd := range descriptors {
+		header := make([]byte, 0x14)
+		binary.LittleEndian.PutUint32(header[0x04:], uint32(0x100+i))
+		binary.LittleEndian.PutUint64(header[0x08:], uint64(len(b)))
+		binary.LittleEndian.PutUint32(header[0x10:], uint32(0x14+len(sd)))
+		b = append(append(b, header...), sd...)
+		for len(b)%16 != 0 {
+			b = append(b, 0)
+		}
+	}
+	return b
+}
+
+var (
+	sidEveryone       = sidBytes(1, 0)
+	sidSystem         = sidBytes(5, 18)
+	sidAdministrators = sidBytes(5, 32, 544)
+	sidUsers          = sidBytes(5, 32, 545)
+	sidUser           = sidBytes(5, 21, 1004405980, 1185630339, 682021762, 1001)
+)
+
+func testDescriptors(t *testing.T) *permissions.Descriptors {
+	sds := sdsBytes(
+		securityDescriptorBytes(sidAdministrators, sidSystem,
+			aceBytes(0x00, 0x13, 0x001F01FF, sidSystem),
+			aceBytes(0x00, 0x0B, 0x10000000, sidUser), // inherit only
+			aceBytes(0x00, 0x00, 0x001200A9, sidUsers),
+		),
+		securityDescriptorBytes(sidUser, sidUser,
+			aceBytes(0x00, 0x00, 0x001F01FF, sidUser),
+			aceBytes(0x01, 0x00, 0x00010040, sidUser), // delete and delete child
+			aceBytes(0x00, 0x00, 0xC0000000, sidEveryone),
+		),
+		[]byte{1, 2, 3},
+	)
+	descriptors, err := permissions.LoadDescriptors(sds)
+	require.Nilf(t, err, "unable to load descriptors: %v", err)
+	assert.Equal(t, 3, descriptors.Len())
+	return descriptors
+}
+
+func testItem(securityId uint32) *pipeline.Item {
+	return &pipeline.Item{
+		Path:  "/Users/bob/file.txt",
+		Entry: export.Entry{RecordNumber: 42, SequenceNumber: 3, InUse: true, SecurityId: securityId},
+		Record: mft.Record{Attributes: []mft.Attribute{
+			{Type: mft.AttributeTypeStandardInformation, Resident: true, Data: make([]byte, 72)},
+		}},
+	}
+}
+
+func TestStage(t *testing.T) {
+	descriptors := testDescriptors(t)
+	var entries []permissions.Entry
+	stage := permissions.Stage(descriptors, func(e permissions.Entry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	for _, id := range []uint32{0x100, 0x101, 0x102, 0x200} {
+		keep, err := stage.Process(testItem(id))
+		require.Nil(t, err)
+		assert.True(t, keep)
+	}
+
+	require.Len(t, entries, 4)
+	assert.Equal(t, permissions.Entry{
+		RecordNumber:   42,
+		SequenceNumber: 3,
+		InUse:          true,
+		Path:           "/Users/bob/file.txt",
+		SecurityId:     0x100,
+		Owner:          `BUILTIN\Administrators`,
+		Group:          `NT AUTHORITY\SYSTEM`,
+		Summary:        `NT AUTHORITY\SYSTEM:F; BUILTIN\Users:RX`,
+		SDDL: "O:BAG:SYD:AI(A;OICIID;FA;;;SY)(A;OICIIO;GA;;;S-1-5-21-1004405980-1185630339-682021762-1001)" +
+			"(A;;0x1200a9;;;BU)",
+	}, entries[0])
+	assert.Equal(t, "S-1-5-21-1004405980-1185630339-682021762-1001:RWX; "+
+		"S-1-5-21-1004405980-1185630339-682021762-1001:DENY 0x10040; Everyone:RW", entries[1].Summary)
+	assert.Equal(t, "unable to parse security descriptor 258: expected at least 20 bytes but got 3", entries[2].Error)
+	assert.Equal(t, "", entries[2].SDDL)
+	assert.Equal(t, "security ID 512 not found in $Secure", entries[3].Error)
+}
+
+func TestNewEntry_OwnDescriptor(t *testing.T) {
+	item := testItem(0x100)
+	item.Record.Attributes = append(item.Record.Attributes, mft.Attribute{
+		Type: mft.AttributeTypeSecurityDescriptor, Resident: true,
+		Data: securityDescriptorBytes(sidUser, sidUser, aceBytes(0x00, 0x00, 0x001301BF, sidUser)),
+	})
+	e := permissions.NewEntry(nil, item)
+	assert.Equal(t, "", e.Error)
+	assert.Equal(t, uint32(0), e.SecurityId)
+	assert.Equal(t, "S-1-5-21-1004405980-1185630339-682021762-1001:M", e.Summary)
+
+	e = permissions.NewEntry(nil, testItem(0x100))
+	assert.Equal(t, "no descriptors available for security ID 256", e.Error)
+
+	e = permissions.NewEntry(nil, &pipeline.Item{})
+	assert.Equal(t, "the record has no $STANDARD_INFORMATION attribute", e.Error)
+}
+
+func TestSummary(t *testing.T) {
+	assert.Equal(t, "Everyone:F (no DACL)", permissions.Summary(mft.SecurityDescriptor{}))
+	sd := mft.SecurityDescriptor{Control: mft.SecurityDescriptorControlDaclPresent, Dacl: &mft.ACL{}}
+	assert.Equal(t, "(empty DACL, no access)", permissions.Summary(sd))
+}
+
+func TestStage_ReportError(t *testing.T) {
+	_, err := permissions.Stage(nil, func(e permissions.Entry) error {
+		return errors.New("disk full")
+	}).Process(testItem(0x100))
+	assert.EqualError(t, err, "unable to report permissions: disk full")
+}
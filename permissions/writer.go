@@ -0,0 +1,90 @@
+package permissions
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+)
+
+var csvHeader = []string{
+	"record_number", "sequence_number", "in_use", "directory", "path", "security_id", "owner", "group", "summary",
+	"sddl", "error",
+}
+
+// CSVWriter writes Entries as CSV, one line per Entry, preceded by a header line.
+type CSVWriter struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+// NewCSVWriter creates a CSVWriter which writes to w.
+func NewCSVWriter(w io.Writer) *CSVWriter {
+	return &CSVWriter{w: csv.NewWriter(w)}
+}
+
+// Write writes the Entry as a single CSV line, writing the header line first if that has not been done yet.
+func (w *CSVWriter) Write(e Entry) error {
+	if !w.headerWritten {
+		if err := w.w.Write(csvHeader); err != nil {
+			return err
+		}
+		w.headerWritten = true
+	}
+	return w.w.Write([]string{
+		strconv.FormatUint(e.RecordNumber, 10),
+		strconv.FormatUint(uint64(e.SequenceNumber), 10),
+		strconv.FormatBool(e.InUse),
+		strconv.FormatBool(e.Directory),
+		e.Path,
+		strconv.FormatUint(uint64(e.SecurityId), 10),
+		e.Owner,
+		e.Group,
+		e.Summary,
+		e.SDDL,
+		e.Error,
+	})
+}
+
+// Flush writes any buffered data to the underlying io.Writer.
+func (w *CSVWriter) Flush() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+// JSONWriter writes Entries as JSON Lines: one JSON object per line. Empty strings are omitted.
+type JSONWriter struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+type jsonEntry struct {
+	RecordNumber   uint64 `json:"record_number"`
+	SequenceNumber uint16 `json:"sequence_number"`
+	InUse          bool   `json:"in_use"`
+	Directory      bool   `json:"directory"`
+	Path           string `json:"path,omitempty"`
+	SecurityId     uint32 `json:"security_id"`
+	Owner          string `json:"owner,omitempty"`
+	Group          string `json:"group,omitempty"`
+	Summary        string `json:"summary,omitempty"`
+	SDDL           string `json:"sddl,omitempty"`
+	Error          string `json:"error,omitempty"`
+}
+
+// NewJSONWriter creates a JSONWriter which writes to w.
+func NewJSONWriter(w io.Writer) *JSONWriter {
+	bw := bufio.NewWriter(w)
+	return &JSONWriter{w: bw, enc: json.NewEncoder(bw)}
+}
+
+// Write writes the Entry as a single line containing a JSON object.
+func (w *JSONWriter) Write(e Entry) error {
+	return w.enc.Encode(jsonEntry(e))
+}
+
+// Flush writes any buffered data to the underlying io.Writer.
+func (w *JSONWriter) Flush() error {
+	return w.w.Flush()
+}
@@ -0,0 +1,46 @@
+package permissions_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/permissions"
+)
+
+var testEntry = permissions.Entry{
+	RecordNumber:   42,
+	SequenceNumber: 3,
+	InUse:          true,
+	Path:           "/Users/bob/file.txt",
+	SecurityId:     256,
+	Owner:          `BUILTIN\Administrators`,
+	Group:          `NT AUTHORITY\SYSTEM`,
+	Summary:        `NT AUTHORITY\SYSTEM:F; BUILTIN\Users:RX`,
+	SDDL:           "O:BAG:SYD:AI(A;OICIID;FA;;;SY)(A;;0x1200a9;;;BU)",
+}
+
+func TestCSVWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := permissions.NewCSVWriter(buf)
+	require.Nil(t, w.Write(testEntry))
+	require.Nil(t, w.Write(permissions.Entry{RecordNumber: 43, Error: "security ID 512 not found in $Secure"}))
+	require.Nil(t, w.Flush())
+	assert.Equal(t, "record_number,sequence_number,in_use,directory,path,security_id,owner,group,summary,sddl,error\n"+
+		`42,3,true,false,/Users/bob/file.txt,256,BUILTIN\Administrators,NT AUTHORITY\SYSTEM,NT AUTHORITY\SYSTEM:F; BUILTIN\Users:RX,O:BAG:SYD:AI(A;OICIID;FA;;;SY)(A;;0x1200a9;;;BU),`+"\n"+
+		"43,0,false,false,,0,,,,,security ID 512 not found in $Secure\n", buf.String())
+}
+
+func TestJSONWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := permissions.NewJSONWriter(buf)
+	require.Nil(t, w.Write(testEntry))
+	require.Nil(t, w.Write(permissions.Entry{RecordNumber: 43, Error: "security ID 512 not found in $Secure"}))
+	require.Nil(t, w.Flush())
+	assert.Equal(t, `{"record_number":42,"sequence_number":3,"in_use":true,"directory":false,"path":"/Users/bob/file.txt",`+
+		`"security_id":256,"owner":"BUILTIN\\Administrators","group":"NT AUTHORITY\\SYSTEM",`+
+		`"summary":"NT AUTHORITY\\SYSTEM:F; BUILTIN\\Users:RX","sddl":"O:BAG:SYD:AI(A;OICIID;FA;;;SY)(A;;0x1200a9;;;BU)"}`+"\n"+
+		`{"record_number":43,"sequence_number":0,"in_use":false,"directory":false,"security_id":0,`+
+		`"error":"security ID 512 not found in $Secure"}`+"\n", buf.String())
+}
@@ -0,0 +1,157 @@
+package pipeline
+
+import (
+	"io"
+	"sync"
+
+	"github.com/t9t/gomft/metrics"
+	"github.com/t9t/gomft/mft"
+)
+
+// An Event is published to the handlers subscribed to an Observer. It is one of RecordParsed, RecordCorrupt,
+// FileExtracted or BadSector; handlers use a type switch to tell them apart.
+type Event interface {
+	event()
+}
+
+// RecordParsed is published by Run for each record that was parsed successfully, before it is passed to the stages.
+// The Record is only valid while the handler runs; a handler that keeps it must Clone() it.
+type RecordParsed struct {
+	Index  int
+	Offset int64
+	Record *mft.Record
+}
+
+// RecordCorrupt is published by Run for each record that could not be parsed, such as a record with an invalid
+// signature or fixup.
+type RecordCorrupt struct {
+	Index  int
+	Offset int64
+	Err    error
+}
+
+// FileExtracted is published by stages that extract the contents of files, such as archive.Stage, after a stream has
+// been written. The Stream is empty for the unnamed $DATA stream. HasEntropy is set when the stage computed the
+// Entropy of the data written (in bits per byte, see the entropy package).
+type FileExtracted struct {
+	RecordNumber uint64
+	Path         string
+	Stream       string
+	Size         int64
+	Entropy      float64
+	HasEntropy   bool
+}
+
+// BadSector is published when reading fails: by Run when reading the MFT fails, and by an io.ReaderAt returned from
+// ObserveReaderAt when reading a volume fails. The Length is the number of bytes that were requested, or zero when
+// unknown.
+type BadSector struct {
+	Offset int64
+	Length int64
+	Err    error
+}
+
+func (RecordParsed) event()  {}
+func (RecordCorrupt) event() {}
+func (FileExtracted) event() {}
+func (BadSector) event()     {}
+
+// A Handler receives the Events published to an Observer.
+type Handler func(e Event)
+
+// An Observer passes Events to the Handlers subscribed to it. Events are published synchronously, from the goroutine
+// running the pipeline, so handlers should return quickly; a handler that needs to do more work (like updating a user
+// interface) should hand the Event off to another goroutine. An Observer is safe for concurrent use, and its zero value
+// has no subscribers.
+type Observer struct {
+	mu       sync.RWMutex
+	handlers map[int]Handler
+	order    []int
+	next     int
+}
+
+// Subscribe registers the handler to receive all Events published from now on, after the handlers subscribed earlier.
+// It returns a function that unsubscribes the handler again.
+func (o *Observer) Subscribe(h Handler) (unsubscribe func()) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.handlers == nil {
+		o.handlers = make(map[int]Handler)
+	}
+	id := o.next
+	o.next++
+	o.handlers[id] = h
+	o.order = append(o.order, id)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			o.mu.Lock()
+			defer o.mu.Unlock()
+			delete(o.handlers, id)
+			for i, oid := range o.order {
+				if oid == id {
+					o.order = append(o.order[:i:i], o.order[i+1:]...)
+					break
+				}
+			}
+		})
+	}
+}
+
+// Publish passes the Event to all subscribed handlers, in the order in which they subscribed. Publishing to a nil
+// Observer does nothing, so code emitting Events does not need to check whether an Observer was configured.
+func (o *Observer) Publish(e Event) {
+	if o == nil {
+		return
+	}
+	o.mu.RLock()
+	handlers := make([]Handler, 0, len(o.order))
+	for _, id := range o.order {
+		handlers = append(handlers, o.handlers[id])
+	}
+	o.mu.RUnlock()
+	for _, h := range handlers {
+		h(e)
+	}
+}
+
+// RecordMetrics returns a Handler reporting the Events to the Recorder: RecordParsed as metrics.RecordsParsed,
+// RecordCorrupt as metrics.ParseErrors, FileExtracted as metrics.FilesExtracted and metrics.BytesExtracted, and
+// BadSector as metrics.ReadErrors. Subscribe it to the Observer of the pipeline; leave the Metrics of the
+// mft.ParseAllOptions unset then, to avoid counting records twice.
+func RecordMetrics(rec metrics.Recorder) Handler {
+	return func(e Event) {
+		switch e := e.(type) {
+		case RecordParsed:
+			rec.Add(metrics.RecordsParsed, 1)
+		case RecordCorrupt:
+			rec.Add(metrics.ParseErrors, 1)
+		case FileExtracted:
+			rec.Add(metrics.FilesExtracted, 1)
+			rec.Add(metrics.BytesExtracted, e.Size)
+		case BadSector:
+			rec.Add(metrics.ReadErrors, 1)
+		}
+	}
+}
+
+type observedReaderAt struct {
+	r io.ReaderAt
+	o *Observer
+}
+
+// ObserveReaderAt returns an io.ReaderAt reading from r that publishes a BadSector Event to the Observer whenever a read
+// fails with an error other than io.EOF. Wrap the volume passed to stages reading file contents with it to be notified
+// of unreadable sectors.
+func ObserveReaderAt(r io.ReaderAt, o *Observer) io.ReaderAt {
+	return observedReaderAt{r: r, o: o}
+}
+
+func (r observedReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := r.r.ReadAt(p, off)
+	if err != nil && err != io.EOF {
+		r.o.Publish(BadSector{Offset: off + int64(n), Length: int64(len(p) - n), Err: err})
+	}
+	return n, err
+}
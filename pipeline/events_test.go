@@ -0,0 +1,131 @@
+package pipeline_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/metrics"
+	"github.com/t9t/gomft/pipeline"
+)
+
+func TestRun_Observer(t *testing.T) {
+	observer := &pipeline.Observer{}
+	var parsed []int
+	var corrupt []pipeline.RecordCorrupt
+	observer.Subscribe(func(e pipeline.Event) {
+		switch e := e.(type) {
+		case pipeline.RecordParsed:
+			parsed = append(parsed, e.Index)
+			assert.Equal(t, int64(e.Index*1024), e.Offset)
+			assert.Equal(t, uint64(e.Index), e.Record.FileReference.RecordNumber)
+		case pipeline.RecordCorrupt:
+			corrupt = append(corrupt, e)
+		default:
+			t.Errorf("unexpected event %#v", e)
+		}
+	})
+
+	stats, err := pipeline.Run(bytes.NewReader(testDump()), pipeline.Options{Observer: observer})
+	require.Nil(t, err)
+	assert.Equal(t, 5, stats.Records)
+	assert.Equal(t, []int{5, 7, 8, 9, 10}, parsed)
+	require.Len(t, corrupt, 1)
+	assert.Equal(t, 11, corrupt[0].Index)
+	assert.Equal(t, int64(11*1024), corrupt[0].Offset)
+	assert.NotNil(t, corrupt[0].Err)
+}
+
+func TestRun_ObserverReadError(t *testing.T) {
+	observer := &pipeline.Observer{}
+	var events []pipeline.Event
+	observer.Subscribe(func(e pipeline.Event) { events = append(events, e) })
+
+	_, err := pipeline.Run(&failingReader{}, pipeline.Options{Observer: observer})
+	require.NotNil(t, err)
+	assert.Equal(t, []pipeline.Event{pipeline.BadSector{Offset: 0, Err: errors.New("read failed")}}, events)
+}
+
+func TestRecordMetrics(t *testing.T) {
+	observer := &pipeline.Observer{}
+	c := &metrics.Counters{}
+	observer.Subscribe(pipeline.RecordMetrics(c))
+
+	_, err := pipeline.Run(bytes.NewReader(testDump()), pipeline.Options{Observer: observer})
+	require.Nil(t, err)
+	observer.Publish(pipeline.FileExtracted{Path: "/file.txt", Size: 42})
+	observer.Publish(pipeline.BadSector{Offset: 4096, Length: 512})
+	assert.Equal(t, int64(5), c.Get(metrics.RecordsParsed))
+	assert.Equal(t, int64(1), c.Get(metrics.ParseErrors))
+	assert.Equal(t, int64(1), c.Get(metrics.FilesExtracted))
+	assert.Equal(t, int64(42), c.Get(metrics.BytesExtracted))
+	assert.Equal(t, int64(1), c.Get(metrics.ReadErrors))
+}
+
+func TestObserver_Subscribe(t *testing.T) {
+	observer := &pipeline.Observer{}
+	var calls []string
+	unsubscribeA := observer.Subscribe(func(e pipeline.Event) { calls = append(calls, "a") })
+	observer.Subscribe(func(e pipeline.Event) { calls = append(calls, "b") })
+
+	observer.Publish(pipeline.FileExtracted{Path: "/file.txt"})
+	assert.Equal(t, []string{"a", "b"}, calls)
+
+	unsubscribeA()
+	unsubscribeA()
+	calls = nil
+	observer.Publish(pipeline.FileExtracted{Path: "/file.txt"})
+	assert.Equal(t, []string{"b"}, calls)
+}
+
+func TestObserver_PublishNil(t *testing.T) {
+	var observer *pipeline.Observer
+	assert.NotPanics(t, func() { observer.Publish(pipeline.BadSector{}) })
+}
+
+func TestObserveReaderAt(t *testing.T) {
+	observer := &pipeline.Observer{}
+	var events []pipeline.Event
+	observer.Subscribe(func(e pipeline.Event) { events = append(events, e) })
+
+	r := pipeline.ObserveReaderAt(badSectorReader{data: []byte("0123456789"), bad: 6}, observer)
+	p := make([]byte, 4)
+	n, err := r.ReadAt(p, 0)
+	require.Nil(t, err)
+	assert.Equal(t, 4, n)
+	assert.Empty(t, events)
+
+	n, err = r.ReadAt(p, 4)
+	assert.Equal(t, 2, n)
+	assert.EqualError(t, err, "bad sector")
+	assert.Equal(t, []pipeline.Event{pipeline.BadSector{Offset: 6, Length: 2, Err: errors.New("bad sector")}}, events)
+
+	events = nil
+	_, err = r.ReadAt(p, 10)
+	assert.Equal(t, io.EOF, err)
+	assert.Empty(t, events)
+}
+
+// badSectorReader fails to read any data from offset bad onwards, unless the read starts at the end of the data.
+type badSectorReader struct {
+	data []byte
+	bad  int64
+}
+
+func (r badSectorReader) ReadAt(p []byte, off int64) (int, error) {
+	if off >= int64(len(r.data)) {
+		return 0, io.EOF
+	}
+	end := off + int64(len(p))
+	if end <= r.bad {
+		return copy(p, r.data[off:end]), nil
+	}
+	n := 0
+	if off < r.bad {
+		n = copy(p, r.data[off:r.bad])
+	}
+	return n, errors.New("bad sector")
+}
@@ -0,0 +1,98 @@
+package pipeline
+
+import (
+	"strings"
+
+	"github.com/t9t/gomft/export"
+	"github.com/t9t/gomft/mftindex"
+)
+
+// MountedVolume returns the name of the volume a mount point refers to, when the reparse target (as in the
+// ReparseTarget of an export.Entry) is the root of a volume: the GUID for a volume GUID path such as
+// \\?\Volume{0b8e5a3c-1a2b-4c5d-8e9f-001122334455}\, or the drive letter and colon for a path such as D:\. Names are
+// returned in lower case, as used by VolumeRegistry. For other targets, such as junctions to a directory within a
+// volume, it returns an empty string.
+func MountedVolume(target string) string {
+	t := strings.TrimSuffix(target, `\`)
+	if len(t) == 2 && t[1] == ':' {
+		return strings.ToLower(t)
+	}
+	const prefix, suffix = `\\?\Volume{`, "}"
+	if len(t) > len(prefix) && strings.EqualFold(t[:len(prefix)], prefix) && strings.HasSuffix(t, suffix) {
+		guid := t[len(prefix) : len(t)-len(suffix)]
+		if !strings.ContainsAny(guid, `\{}`) {
+			return strings.ToLower(guid)
+		}
+	}
+	return ""
+}
+
+// VolumeRegistry keeps track of multiple volumes (each with its own PathResolver) and of the mount points through
+// which they are mounted into each other, so that the volumes of a system can be analysed as one tree. Volumes are
+// registered by name: a volume GUID, a drive letter and colon (such as "C:"), or both; names are not case sensitive.
+// A VolumeRegistry is not safe for concurrent use.
+type VolumeRegistry struct {
+	resolvers map[string]*PathResolver
+	mounts    map[*PathResolver]mountPoint
+}
+
+// mountPoint is a directory on a volume at which another volume is mounted.
+type mountPoint struct {
+	resolver *PathResolver
+	entry    export.Entry
+}
+
+// NewVolumeRegistry creates an empty VolumeRegistry.
+func NewVolumeRegistry() *VolumeRegistry {
+	return &VolumeRegistry{resolvers: make(map[string]*PathResolver), mounts: make(map[*PathResolver]mountPoint)}
+}
+
+// Add registers the PathResolver of a volume under one or more names, and makes the resolver follow mount points
+// using the registry (see PathResolver.FollowMountPoints).
+func (r *VolumeRegistry) Add(resolver *PathResolver, names ...string) {
+	for _, name := range names {
+		r.resolvers[strings.ToLower(strings.Trim(name, "{}"))] = resolver
+	}
+	resolver.FollowMountPoints(r)
+}
+
+// AddMountPoint registers a directory Entry of the volume with the name as a mount point, when its ReparseTarget refers
+// to the root of another registered volume (see MountedVolume). It returns whether the Entry was registered. When a
+// volume is mounted at multiple directories, the last one is used.
+func (r *VolumeRegistry) AddMountPoint(name string, e export.Entry) bool {
+	resolver, ok := r.resolvers[strings.ToLower(strings.Trim(name, "{}"))]
+	if !ok || !e.Directory {
+		return false
+	}
+	mounted, ok := r.resolvers[MountedVolume(e.ReparseTarget)]
+	if !ok || mounted == resolver {
+		return false
+	}
+	r.mounts[mounted] = mountPoint{resolver: resolver, entry: e}
+	return true
+}
+
+// MountPoints creates a Stage that registers the mount points among the Items of the volume with the name, for
+// example while the MFT of that volume is read. It never drops Items.
+func (r *VolumeRegistry) MountPoints(name string) Stage {
+	return StageFunc(func(item *Item) (bool, error) {
+		if item.Entry.InUse && item.Entry.ReparseTarget != "" {
+			r.AddMountPoint(name, item.Entry)
+		}
+		return true, nil
+	})
+}
+
+// mountPath returns the path of the mount point of the volume read by the resolver, or an empty string when it is not
+// mounted in another registered volume.
+func (r *VolumeRegistry) mountPath(resolver *PathResolver, depth int) (string, error) {
+	mount, ok := r.mounts[resolver]
+	if !ok || depth >= maxDepth {
+		return "", nil
+	}
+	path, err := mount.resolver.path(mount.entry, depth+1)
+	if err != nil || strings.HasPrefix(path, mftindex.OrphanPrefix) {
+		return "", err
+	}
+	return path, nil
+}
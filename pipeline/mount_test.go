@@ -0,0 +1,86 @@
+package pipeline_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/export"
+	"github.com/t9t/gomft/pipeline"
+)
+
+func TestMountedVolume(t *testing.T) {
+	tests := []struct {
+		target   string
+		expected string
+	}{
+		{`\\?\Volume{0B8E5A3C-1a2b-4c5d-8e9f-001122334455}\`, "0b8e5a3c-1a2b-4c5d-8e9f-001122334455"},
+		{`\\?\Volume{0b8e5a3c-1a2b-4c5d-8e9f-001122334455}`, "0b8e5a3c-1a2b-4c5d-8e9f-001122334455"},
+		{`\\?\Volume{0b8e5a3c-1a2b-4c5d-8e9f-001122334455}\dir`, ""},
+		{`D:\`, "d:"},
+		{`D:\dir`, ""},
+		{`\\server\share`, ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		assert.Equalf(t, tt.expected, pipeline.MountedVolume(tt.target), "target: %s", tt.target)
+	}
+}
+
+func TestVolumeRegistry(t *testing.T) {
+	// Volume c has /mnt (7) and /mnt/d (8), at which volume d is mounted; volume d has /Windows/notepad.exe
+	c := make([]byte, 9*1024)
+	copy(c[5*1024:], testRecord(5, 5, 5, 5, ".").WithDirectory().Bytes())
+	copy(c[7*1024:], testRecord(7, 1, 5, 5, "mnt").WithDirectory().Bytes())
+	copy(c[8*1024:], testRecord(8, 1, 7, 1, "d").WithDirectory().Bytes())
+	registry := pipeline.NewVolumeRegistry()
+	cResolver := pipeline.NewPathResolver(bytes.NewReader(c), 1024, 0)
+	dResolver := pipeline.NewPathResolver(bytes.NewReader(testDump()), 1024, 0)
+	registry.Add(cResolver, "{A1B2C3D4-0000-0000-0000-000000000001}", "C:")
+	registry.Add(dResolver, "a1b2c3d4-0000-0000-0000-000000000002")
+
+	notepad := export.Entry{RecordNumber: 8, Name: "notepad.exe", ParentRecordNumber: 7, ParentSequenceNumber: 2}
+	path, err := dResolver.Path(notepad)
+	require.Nil(t, err)
+	assert.Equal(t, "/Windows/notepad.exe", path)
+
+	mount := export.Entry{RecordNumber: 8, SequenceNumber: 1, InUse: true, Directory: true, Name: "d", ParentRecordNumber: 7,
+		ParentSequenceNumber: 1, ReparseTarget: `\\?\Volume{a1b2c3d4-0000-0000-0000-000000000002}\`}
+	keep, err := registry.MountPoints("c:").Process(&pipeline.Item{Entry: mount})
+	require.Nil(t, err)
+	assert.True(t, keep)
+
+	path, err = dResolver.Path(notepad)
+	require.Nil(t, err)
+	assert.Equal(t, "/mnt/d/Windows/notepad.exe", path)
+	path, err = dResolver.Path(export.Entry{RecordNumber: 5, Name: "."})
+	require.Nil(t, err)
+	assert.Equal(t, "/mnt/d", path)
+	path, err = dResolver.Path(export.Entry{RecordNumber: 9, Name: "lost.txt", ParentRecordNumber: 6, ParentSequenceNumber: 1})
+	require.Nil(t, err)
+	assert.Equal(t, "/$Orphan/lost.txt", path)
+	path, err = cResolver.Path(export.Entry{RecordNumber: 9, Name: "file.txt", ParentRecordNumber: 7, ParentSequenceNumber: 1})
+	require.Nil(t, err)
+	assert.Equal(t, "/mnt/file.txt", path)
+
+	// Not mount points of registered volumes
+	assert.False(t, registry.AddMountPoint("c:", export.Entry{Directory: true, ReparseTarget: `E:\`}))
+	assert.False(t, registry.AddMountPoint("e:", mount))
+	assert.False(t, registry.AddMountPoint("c:", export.Entry{Directory: true, ReparseTarget: `C:\`}))
+	file := mount
+	file.Directory = false
+	assert.False(t, registry.AddMountPoint("c:", file))
+
+	// Volumes mounted into each other do not loop forever
+	assert.True(t, registry.AddMountPoint("a1b2c3d4-0000-0000-0000-000000000002", export.Entry{RecordNumber: 7, Directory: true,
+		Name: "Windows", ParentRecordNumber: 5, ParentSequenceNumber: 5, ReparseTarget: `C:\`}))
+	_, err = dResolver.Path(notepad)
+	require.Nil(t, err)
+
+	cResolver.FollowMountPoints(nil)
+	dResolver.FollowMountPoints(nil)
+	path, err = dResolver.Path(notepad)
+	require.Nil(t, err)
+	assert.Equal(t, "/Windows/notepad.exe", path)
+}
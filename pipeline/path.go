@@ -0,0 +1,161 @@
+package pipeline
+
+import (
+	"container/list"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/t9t/gomft/export"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/mftindex"
+)
+
+// maxDepth limits the number of parents followed when resolving a path, which protects against corrupt data
+// containing cycles.
+const maxDepth = 1024
+
+// DefaultPathCacheSize is the number of directories kept in memory by a PathResolver when no other size is specified.
+const DefaultPathCacheSize = 16384
+
+// PathResolver resolves the paths of Entries by reading their parent directory records from the MFT data on demand.
+// The most recently used directories are kept in a cache of a fixed size. A PathResolver is not safe for concurrent
+// use.
+type PathResolver struct {
+	r          io.ReaderAt
+	recordSize int
+	cacheSize  int
+	buf        []byte
+	cache      map[uint64]*list.Element
+	lru        *list.List
+	volumes    *VolumeRegistry
+}
+
+type directory struct {
+	number uint64
+	export.Entry
+	valid bool
+}
+
+// NewPathResolver creates a PathResolver reading records of recordSize bytes from r, which contains the MFT data
+// (such as an MFT dump file). When cacheSize is zero, DefaultPathCacheSize is used.
+func NewPathResolver(r io.ReaderAt, recordSize int, cacheSize int) *PathResolver {
+	if cacheSize <= 0 {
+		cacheSize = DefaultPathCacheSize
+	}
+	return &PathResolver{
+		r:          r,
+		recordSize: recordSize,
+		cacheSize:  cacheSize,
+		buf:        make([]byte, recordSize),
+		cache:      make(map[uint64]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+// FollowMountPoints makes the PathResolver follow mount points using the VolumeRegistry: when the volume of the
+// PathResolver is mounted at a directory of another volume in the registry, the paths of its Entries start with the
+// path of that directory, so the paths of all volumes form a single tree. VolumeRegistry.Add calls it for each volume
+// added; passing nil stops following mount points.
+func (p *PathResolver) FollowMountPoints(volumes *VolumeRegistry) {
+	p.volumes = volumes
+}
+
+// Path resolves the full path of the Entry, in the same format as mftindex.Index.Path: elements are separated by
+// forward slashes and, when the chain of parents is broken, the path starts with mftindex.OrphanPrefix. An error is
+// only returned when reading from the MFT data fails.
+func (p *PathResolver) Path(e export.Entry) (string, error) {
+	return p.path(e, 0)
+}
+
+func (p *PathResolver) path(e export.Entry, depth int) (string, error) {
+	if e.Name == "" && e.RecordNumber != mftindex.RootRecordNumber {
+		return "", nil
+	}
+	path, err := p.volumePath(e)
+	if err != nil || p.volumes == nil || strings.HasPrefix(path, mftindex.OrphanPrefix) {
+		return path, err
+	}
+	mount, err := p.volumes.mountPath(p, depth)
+	if err != nil || mount == "" {
+		return path, err
+	}
+	if path == "/" {
+		return mount, nil
+	}
+	return mount + path, nil
+}
+
+// volumePath resolves the path of the Entry within its own volume.
+func (p *PathResolver) volumePath(e export.Entry) (string, error) {
+	if e.RecordNumber == mftindex.RootRecordNumber {
+		return "/", nil
+	}
+
+	elements := []string{e.Name}
+	parent, parentSequence := e.ParentRecordNumber, e.ParentSequenceNumber
+	for depth := 0; parent != mftindex.RootRecordNumber; depth++ {
+		dir, err := p.directory(parent)
+		if err != nil {
+			return "", err
+		}
+		if !dir.valid || dir.SequenceNumber != parentSequence || dir.Name == "" || depth >= maxDepth {
+			return joinReversed(mftindex.OrphanPrefix, elements), nil
+		}
+		elements = append(elements, dir.Name)
+		parent, parentSequence = dir.ParentRecordNumber, dir.ParentSequenceNumber
+	}
+	return joinReversed("", elements), nil
+}
+
+// IsPendingDelete indicates whether the Entry is a file pending deletion: an Entry in use whose parent directory is
+// $Extend\$Deleted (see mftindex.DeletedDirectoryName). An error is only returned when reading from the MFT data fails.
+func (p *PathResolver) IsPendingDelete(e export.Entry) (bool, error) {
+	if !e.InUse {
+		return false, nil
+	}
+	dir, err := p.directory(e.ParentRecordNumber)
+	if err != nil {
+		return false, err
+	}
+	return dir.valid && dir.SequenceNumber == e.ParentSequenceNumber && dir.Name == mftindex.DeletedDirectoryName &&
+		dir.ParentRecordNumber == mftindex.ExtendRecordNumber, nil
+}
+
+func joinReversed(prefix string, elements []string) string {
+	var sb strings.Builder
+	sb.WriteString(prefix)
+	for i := len(elements) - 1; i >= 0; i-- {
+		sb.WriteByte('/')
+		sb.WriteString(elements[i])
+	}
+	return sb.String()
+}
+
+func (p *PathResolver) directory(number uint64) (*directory, error) {
+	if elem, ok := p.cache[number]; ok {
+		p.lru.MoveToFront(elem)
+		return elem.Value.(*directory), nil
+	}
+
+	dir := &directory{number: number}
+	n, err := p.r.ReadAt(p.buf, int64(number)*int64(p.recordSize))
+	if n < len(p.buf) && err != io.EOF {
+		return nil, fmt.Errorf("unable to read record %d: %v", number, err)
+	}
+	if n == len(p.buf) {
+		// Parsing makes a copy, so the buffer can be reused
+		if r, err := mft.ParseRecord(p.buf); err == nil && r.IsDirectory() {
+			dir.Entry = export.FromRecord(r)
+			dir.valid = true
+		}
+	}
+
+	if p.lru.Len() >= p.cacheSize {
+		oldest := p.lru.Back()
+		p.lru.Remove(oldest)
+		delete(p.cache, oldest.Value.(*directory).number)
+	}
+	p.cache[number] = p.lru.PushFront(dir)
+	return dir, nil
+}
@@ -0,0 +1,59 @@
+package pipeline_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/export"
+	"github.com/t9t/gomft/pipeline"
+)
+
+func TestPathResolver_SmallCache(t *testing.T) {
+	dump := testDump()
+	resolver := pipeline.NewPathResolver(bytes.NewReader(dump), 1024, 1)
+
+	for i := 0; i < 2; i++ {
+		path, err := resolver.Path(export.Entry{RecordNumber: 8, Name: "notepad.exe", ParentRecordNumber: 7, ParentSequenceNumber: 2})
+		require.Nil(t, err)
+		assert.Equal(t, "/Windows/notepad.exe", path)
+
+		path, err = resolver.Path(export.Entry{RecordNumber: 9, Name: "lost.txt", ParentRecordNumber: 6, ParentSequenceNumber: 1})
+		require.Nil(t, err)
+		assert.Equal(t, "/$Orphan/lost.txt", path)
+	}
+
+	path, err := resolver.Path(export.Entry{RecordNumber: 5, Name: "."})
+	require.Nil(t, err)
+	assert.Equal(t, "/", path)
+
+	path, err = resolver.Path(export.Entry{Name: "beyond.txt", ParentRecordNumber: 1000, ParentSequenceNumber: 1})
+	require.Nil(t, err)
+	assert.Equal(t, "/$Orphan/beyond.txt", path)
+}
+
+func TestPathResolver_IsPendingDelete(t *testing.T) {
+	dump := make([]byte, 14*1024)
+	copy(dump[5*1024:], testRecord(5, 5, 5, 5, ".").WithDirectory().Bytes())
+	copy(dump[11*1024:], testRecord(11, 11, 5, 5, "$Extend").WithDirectory().Bytes())
+	copy(dump[12*1024:], testRecord(12, 3, 11, 11, "$Deleted").WithDirectory().Bytes())
+	copy(dump[13*1024:], testRecord(13, 1, 5, 5, "$Deleted").WithDirectory().Bytes())
+	resolver := pipeline.NewPathResolver(bytes.NewReader(dump), 1024, 0)
+
+	tests := []struct {
+		entry    export.Entry
+		expected bool
+	}{
+		{export.Entry{InUse: true, ParentRecordNumber: 12, ParentSequenceNumber: 3}, true},
+		{export.Entry{InUse: false, ParentRecordNumber: 12, ParentSequenceNumber: 3}, false},
+		{export.Entry{InUse: true, ParentRecordNumber: 12, ParentSequenceNumber: 2}, false},
+		{export.Entry{InUse: true, ParentRecordNumber: 13, ParentSequenceNumber: 1}, false},
+		{export.Entry{InUse: true, ParentRecordNumber: 5, ParentSequenceNumber: 5}, false},
+	}
+	for _, tt := range tests {
+		pending, err := resolver.IsPendingDelete(tt.entry)
+		require.Nil(t, err)
+		assert.Equalf(t, tt.expected, pending, "entry: %+v", tt.entry)
+	}
+}
@@ -0,0 +1,222 @@
+/*
+	Package pipeline streams the records of an MFT through a sequence of stages, such as filtering, resolving paths and
+	exporting, without ever keeping all records in memory. This makes it possible to process MFT dumps of any size in
+	constant memory.
+
+	Basic usage
+
+	Pass the MFT data and the stages to Run. Each parsed record is handed to the stages in order; a stage can drop a
+	record, so the stages after it do not see it.
+			// Error handling left out for brevity
+			w := export.NewCSVWriter(os.Stdout)
+			f, _ := filter.Compile("name like '*.exe' and not deleted")
+			stats, err := pipeline.Run(in, pipeline.Options{},
+				pipeline.Filter(func(item *pipeline.Item) bool { return f.Match(item.Entry) }),
+				pipeline.Export(w))
+			err = w.Flush()
+
+	To use the records programmatically instead, Query streams the Items matching a predicate, with the value returned
+	by a projection. The predicate is evaluated concurrently; stop early by calling Close.
+			results := pipeline.Query(in, pipeline.QueryOptions{}, predicate, projection)
+			defer results.Close()
+			for results.Next() {
+				fmt.Println(results.Value())
+			}
+			err := results.Err()
+
+	To follow the progress of a pipeline, for example to show a progress bar, collect metrics or raise alerts, subscribe
+	a Handler to an Observer and set it in the Options. Run publishes typed Events such as RecordParsed and
+	RecordCorrupt; stages like archive.Stage publish FileExtracted when given the same Observer.
+			observer := &pipeline.Observer{}
+			observer.Subscribe(func(e pipeline.Event) {
+				if c, ok := e.(pipeline.RecordCorrupt); ok {
+					log.Printf("corrupt record at offset %d: %v", c.Offset, c.Err)
+				}
+			})
+			stats, err := pipeline.Run(in, pipeline.Options{Observer: observer}, stages...)
+
+	Implementation notes
+
+	Records are parsed concurrently by mft.ParseAll, which reads ahead a limited number of batches of records. The
+	stages are run on a single goroutine, in the order of the records in the input, so stages need no synchronization.
+	Since an Item is only valid while the stages run, a stage that keeps an Item's Record must Clone() it.
+
+	Query evaluates its predicate on batches of records using a pool of workers, and keeps the batches in a queue so the
+	matches are returned in the original order. Paths are resolved and projections called from the goroutine calling
+	Next, so the (not concurrency safe) PathResolver can be used.
+
+	Resolving paths normally requires an index over all directories (see the mftindex package). To stay within
+	constant memory, a PathResolver instead reads parent directory records on demand from an io.ReaderAt and keeps
+	only a limited number of them in a cache.
+*/
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/t9t/gomft/export"
+	"github.com/t9t/gomft/mft"
+)
+
+// Item is a record passed through the stages of a pipeline. The Entry is created from the Record by
+// export.FromRecord, with its Offset set. The Path is empty unless set by a stage, such as Paths.
+type Item struct {
+	Index  int
+	Offset int64
+	Record mft.Record
+	Entry  export.Entry
+	Path   string
+}
+
+// A Stage processes an Item. When it returns false, the Item is dropped and not passed to the next stages. When it
+// returns an error, the pipeline stops.
+type Stage interface {
+	Process(item *Item) (bool, error)
+}
+
+// StageFunc is an adapter to use a function as a Stage.
+type StageFunc func(item *Item) (bool, error)
+
+// Process calls f(item).
+func (f StageFunc) Process(item *Item) (bool, error) {
+	return f(item)
+}
+
+// Options configures Run.
+type Options struct {
+	// ParseAll are the options used to read and parse the records. SkipEmpty is always enabled and Cancel can be used
+	// to stop the pipeline early.
+	ParseAll mft.ParseAllOptions
+	// ErrorHandler, when not nil, is called for each record that could not be parsed. Such records are not passed to
+	// the stages either way.
+	ErrorHandler func(index int, offset int64, err error)
+	// Observer, when not nil, receives a RecordParsed or RecordCorrupt Event for each record, and a BadSector Event
+	// when reading r fails.
+	Observer *Observer
+}
+
+// Stats contains the counts of a completed pipeline run.
+type Stats struct {
+	Records int // number of records parsed successfully and passed to the first stage
+	Passed  int // number of records passed by all stages
+	Errors  int // number of records which could not be parsed
+}
+
+// Run reads and parses the records in r and passes each of them through the stages. It returns when all records have
+// been processed, when a stage returns an error, or when reading from r fails.
+func Run(r io.Reader, opts Options, stages ...Stage) (Stats, error) {
+	return RunContext(context.Background(), r, opts, stages...)
+}
+
+// RunContext works like Run, but also stops when ctx is done, returning ctx.Err() and the counts of the records
+// processed until then. The pipeline stops before the next record, unless a stage (such as archive.StageContext) is
+// interrupted by ctx itself.
+func RunContext(ctx context.Context, r io.Reader, opts Options, stages ...Stage) (Stats, error) {
+	cancel := make(chan struct{})
+	defer close(cancel)
+	parseOpts := opts.ParseAll
+	parseOpts.SkipEmpty = true
+	parseOpts.Cancel = mergeCancel(opts.ParseAll.Cancel, ctx.Done(), cancel)
+
+	stats := Stats{}
+	for result := range mft.ParseAll(r, parseOpts) {
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+		if readErr, ok := result.Err.(*mft.ReadError); ok {
+			opts.Observer.Publish(BadSector{Offset: result.Offset, Err: readErr.Err})
+			return stats, readErr
+		}
+		if result.Err != nil {
+			stats.Errors++
+			opts.Observer.Publish(RecordCorrupt{Index: result.Index, Offset: result.Offset, Err: result.Err})
+			if opts.ErrorHandler != nil {
+				opts.ErrorHandler(result.Index, result.Offset, result.Err)
+			}
+			continue
+		}
+
+		stats.Records++
+		opts.Observer.Publish(RecordParsed{Index: result.Index, Offset: result.Offset, Record: &result.Record})
+		item := Item{Index: result.Index, Offset: result.Offset, Record: result.Record}
+		item.Entry = export.FromRecord(result.Record)
+		item.Entry.Offset = result.Offset
+		passed, err := process(&item, stages)
+		if err != nil {
+			if ctx.Err() != nil {
+				// The stage most likely failed because it was interrupted
+				return stats, ctx.Err()
+			}
+			return stats, fmt.Errorf("unable to process record at offset %d: %v", result.Offset, err)
+		}
+		if passed {
+			stats.Passed++
+		}
+	}
+	return stats, ctx.Err()
+}
+
+func process(item *Item, stages []Stage) (bool, error) {
+	for _, s := range stages {
+		keep, err := s.Process(item)
+		if err != nil || !keep {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// mergeCancel returns a channel that is closed when a, b (both of which may be nil) or cancel is closed. The caller
+// must close cancel eventually, so the goroutine merging the channels finishes.
+func mergeCancel(a, b <-chan struct{}, cancel chan struct{}) <-chan struct{} {
+	if a == nil && b == nil {
+		return cancel
+	}
+	merged := make(chan struct{})
+	go func() {
+		defer close(merged)
+		select {
+		case <-a:
+		case <-b:
+		case <-cancel:
+		}
+	}()
+	return merged
+}
+
+// Filter creates a Stage that drops all Items for which keep returns false.
+func Filter(keep func(item *Item) bool) Stage {
+	return StageFunc(func(item *Item) (bool, error) {
+		return keep(item), nil
+	})
+}
+
+// Export creates a Stage that writes the Entry of each Item to w. Note that w is not flushed by the pipeline.
+func Export(w export.Writer) Stage {
+	return StageFunc(func(item *Item) (bool, error) {
+		if err := w.Write(item.Entry); err != nil {
+			return false, fmt.Errorf("unable to write entry: %v", err)
+		}
+		return true, nil
+	})
+}
+
+// Paths creates a Stage that sets the Path of each Item using the PathResolver. It also sets the PendingDelete of the
+// Entry, since that requires the parent directory as well.
+func Paths(resolver *PathResolver) Stage {
+	return StageFunc(func(item *Item) (bool, error) {
+		path, err := resolver.Path(item.Entry)
+		if err != nil {
+			return false, err
+		}
+		pending, err := resolver.IsPendingDelete(item.Entry)
+		if err != nil {
+			return false, err
+		}
+		item.Path = path
+		item.Entry.PendingDelete = pending
+		return true, nil
+	})
+}
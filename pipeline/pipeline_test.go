@@ -0,0 +1,139 @@
+package pipeline_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/export"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/mfttest"
+	"github.com/t9t/gomft/pipeline"
+)
+
+func TestRun(t *testing.T) {
+	dump := testDump()
+	var items []pipeline.Item
+	var errorIndexes []int
+	opts := pipeline.Options{ErrorHandler: func(index int, offset int64, err error) {
+		errorIndexes = append(errorIndexes, index)
+	}}
+
+	stats, err := pipeline.Run(bytes.NewReader(dump), opts,
+		pipeline.Filter(func(item *pipeline.Item) bool { return !item.Entry.Directory }),
+		pipeline.Paths(pipeline.NewPathResolver(bytes.NewReader(dump), 1024, 0)),
+		pipeline.StageFunc(func(item *pipeline.Item) (bool, error) {
+			items = append(items, *item)
+			return true, nil
+		}))
+	require.Nil(t, err)
+	assert.Equal(t, pipeline.Stats{Records: 5, Passed: 3, Errors: 1}, stats)
+	assert.Equal(t, []int{11}, errorIndexes)
+
+	require.Len(t, items, 3)
+	assert.Equal(t, 8, items[0].Index)
+	assert.Equal(t, int64(8*1024), items[0].Offset)
+	assert.Equal(t, int64(8*1024), items[0].Entry.Offset)
+	assert.Equal(t, "notepad.exe", items[0].Entry.Name)
+	assert.Equal(t, "/Windows/notepad.exe", items[0].Path)
+	assert.Equal(t, "/$Orphan/lost.txt", items[1].Path)
+	assert.Equal(t, "/$Orphan/stale.txt", items[2].Path)
+}
+
+func TestRun_Export(t *testing.T) {
+	w := &entryWriter{}
+	stats, err := pipeline.Run(bytes.NewReader(testDump()), pipeline.Options{}, pipeline.Export(w))
+	require.Nil(t, err)
+	assert.Equal(t, 5, stats.Passed)
+	require.Len(t, w.entries, 5)
+	assert.Equal(t, ".", w.entries[0].Name)
+	assert.Equal(t, int64(5*1024), w.entries[0].Offset)
+}
+
+func TestRun_StageError(t *testing.T) {
+	count := 0
+	stats, err := pipeline.Run(bytes.NewReader(testDump()), pipeline.Options{},
+		pipeline.StageFunc(func(item *pipeline.Item) (bool, error) {
+			count++
+			return false, errors.New("stage failed")
+		}))
+	assert.EqualError(t, err, "unable to process record at offset 5120: stage failed")
+	assert.Equal(t, 1, count)
+	assert.Equal(t, 1, stats.Records)
+	assert.Equal(t, 0, stats.Passed)
+}
+
+func TestRun_ReadError(t *testing.T) {
+	_, err := pipeline.Run(&failingReader{}, pipeline.Options{})
+	assert.EqualError(t, err, "unable to read record data: read failed")
+}
+
+type entryWriter struct {
+	entries []export.Entry
+}
+
+func (w *entryWriter) Write(e export.Entry) error {
+	w.entries = append(w.entries, e)
+	return nil
+}
+
+func (w *entryWriter) Flush() error {
+	return nil
+}
+
+type failingReader struct{}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	return 0, errors.New("read failed")
+}
+
+// testDump creates an MFT dump of 12 records of 1024 bytes. Records 5 (the root directory), 7 (a directory) and 8-10
+// (files) are valid, record 11 has an invalid fixup and all others are empty.
+func testDump() []byte {
+	dump := make([]byte, 12*1024)
+	copy(dump[5*1024:], testRecord(5, 5, 5, 5, ".").WithDirectory().Bytes())
+	copy(dump[7*1024:], testRecord(7, 2, 5, 5, "Windows").WithDirectory().Bytes())
+	copy(dump[8*1024:], testRecord(8, 1, 7, 2, "notepad.exe").Bytes())
+	copy(dump[9*1024:], testRecord(9, 1, 6, 1, "lost.txt").Bytes())
+	copy(dump[10*1024:], testRecord(10, 1, 7, 1, "stale.txt").Bytes())
+	corrupt := testRecord(11, 1, 5, 5, "corrupt.txt").Bytes()
+	corrupt[510] = 0xFF
+	copy(dump[11*1024:], corrupt)
+	return dump
+}
+
+// testRecord creates a builder of an in use record with a $FILE_NAME attribute with the name in the parent directory.
+func testRecord(number uint64, sequenceNumber uint16, parent uint64, parentSequenceNumber uint16, name string) *mfttest.RecordBuilder {
+	return mfttest.NewRecord().
+		WithRecordNumber(number).
+		WithSequenceNumber(sequenceNumber).
+		WithParent(mft.FileReference{RecordNumber: parent, SequenceNumber: parentSequenceNumber}).
+		WithFileName(name)
+}
+
+func TestRunContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	count := 0
+	stats, err := pipeline.RunContext(ctx, bytes.NewReader(testDump()), pipeline.Options{},
+		pipeline.StageFunc(func(item *pipeline.Item) (bool, error) {
+			count++
+			cancel()
+			return true, nil
+		}))
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, 1, stats.Passed)
+}
+
+func TestRunContext_InterruptedStage(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	_, err := pipeline.RunContext(ctx, bytes.NewReader(testDump()), pipeline.Options{},
+		pipeline.StageFunc(func(item *pipeline.Item) (bool, error) {
+			cancel()
+			return false, errors.New("interrupted")
+		}))
+	assert.Equal(t, context.Canceled, err)
+}
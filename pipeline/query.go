@@ -0,0 +1,259 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+
+	"github.com/t9t/gomft/export"
+	"github.com/t9t/gomft/mft"
+)
+
+const queryBatchSize = 64
+
+// A Predicate decides whether an Item matches a query. It is called concurrently from multiple goroutines, before the
+// Path of the Item is resolved.
+type Predicate func(item *Item) bool
+
+// A Projection turns a matching Item into the value returned by a query, for example a single field or a struct with
+// only the details needed. It is called in the order of the records, from the goroutine calling Results.Next.
+type Projection func(item *Item) (interface{}, error)
+
+// QueryOptions configures Query.
+type QueryOptions struct {
+	// ParseAll are the options used to read and parse the records. SkipEmpty is always enabled and Cancel can be used
+	// to stop the query early, like Results.Close does.
+	ParseAll mft.ParseAllOptions
+	// Workers is the number of goroutines evaluating the predicate concurrently. When zero, runtime.GOMAXPROCS(0) is
+	// used.
+	Workers int
+	// Paths, when not nil, is used to resolve the Path of each matching Item before the projection is called.
+	Paths *PathResolver
+	// ErrorHandler, when not nil, is called for each record that could not be parsed, from the goroutine calling
+	// Results.Next.
+	ErrorHandler func(index int, offset int64, err error)
+}
+
+// Results streams the matches of a query, in the order of the records in the input. Like sql.Rows, call Next to
+// advance to the next match before calling Item or Value, and check Err afterwards. Results is not safe for concurrent
+// use.
+type Results struct {
+	ctx        context.Context
+	opts       QueryOptions
+	projection Projection
+	cancel     chan struct{}
+	queue      <-chan chan queryBatch
+	closed     bool
+
+	batch queryBatch
+	pos   int
+	item  Item
+	value interface{}
+	err   error
+	stats Stats
+}
+
+type queryJob struct {
+	results []mft.RecordResult
+	out     chan queryBatch
+}
+
+// queryBatch contains the outcome of evaluating the predicate on a batch of records: the matching Items, the results
+// of records which could not be parsed and, when reading the input failed, the error.
+type queryBatch struct {
+	records int
+	items   []Item
+	errors  []mft.RecordResult
+	readErr error
+}
+
+// Query reads and parses the records in r and streams the Items for which the predicate returns true (all Items when
+// it is nil) as Results. The predicate is evaluated concurrently using a pool of workers. The value of each match is
+// obtained using the projection; when it is nil, the value is the Entry of the Item.
+//
+// Reading and evaluating is done ahead of the caller a limited number of batches at a time. Stopping early by calling
+// Results.Close stops reading the input and releases all goroutines.
+func Query(r io.Reader, opts QueryOptions, predicate Predicate, projection Projection) *Results {
+	return QueryContext(context.Background(), r, opts, predicate, projection)
+}
+
+// QueryContext works like Query, but also stops the query when ctx is done. Results.Next then returns false once the
+// matches of the current batch have been returned, and Results.Err returns ctx.Err().
+func QueryContext(ctx context.Context, r io.Reader, opts QueryOptions, predicate Predicate, projection Projection) *Results {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	cancel := make(chan struct{})
+	merged := mergeCancel(opts.ParseAll.Cancel, ctx.Done(), cancel)
+	parseOpts := opts.ParseAll
+	parseOpts.SkipEmpty = true
+	parseOpts.Cancel = merged
+
+	jobs := make(chan queryJob)
+	queue := make(chan chan queryBatch, workers*2)
+	for i := 0; i < workers; i++ {
+		go func() {
+			for job := range jobs {
+				job.out <- evaluateBatch(job.results, predicate)
+			}
+		}()
+	}
+	go feedQuery(mft.ParseAll(r, parseOpts), jobs, queue, merged)
+
+	return &Results{ctx: ctx, opts: opts, projection: projection, cancel: cancel, queue: queue}
+}
+
+// feedQuery hands batches of parse results to the workers. Each batch's output channel is also put on the queue, so
+// Results can consume the batches in the original order.
+func feedQuery(results <-chan mft.RecordResult, jobs chan<- queryJob, queue chan<- chan queryBatch, cancel <-chan struct{}) {
+	defer close(queue)
+	defer close(jobs)
+
+	dispatch := func(batch []mft.RecordResult) bool {
+		job := queryJob{results: batch, out: make(chan queryBatch, 1)}
+		select {
+		case jobs <- job:
+		case <-cancel:
+			return false
+		}
+		select {
+		case queue <- job.out:
+			return true
+		case <-cancel:
+			return false
+		}
+	}
+
+	batch := make([]mft.RecordResult, 0, queryBatchSize)
+	for result := range results {
+		batch = append(batch, result)
+		if len(batch) == queryBatchSize {
+			if !dispatch(batch) {
+				return
+			}
+			batch = make([]mft.RecordResult, 0, queryBatchSize)
+		}
+	}
+	if len(batch) > 0 {
+		dispatch(batch)
+	}
+}
+
+func evaluateBatch(results []mft.RecordResult, predicate Predicate) queryBatch {
+	batch := queryBatch{}
+	for _, result := range results {
+		if readErr, ok := result.Err.(*mft.ReadError); ok {
+			batch.readErr = readErr
+			break
+		}
+		if result.Err != nil {
+			batch.errors = append(batch.errors, result)
+			continue
+		}
+		batch.records++
+		item := Item{Index: result.Index, Offset: result.Offset, Record: result.Record}
+		item.Entry = export.FromRecord(result.Record)
+		item.Entry.Offset = result.Offset
+		if predicate == nil || predicate(&item) {
+			batch.items = append(batch.items, item)
+		}
+	}
+	return batch
+}
+
+// Next advances to the next match, which is then available through Item and Value. It returns false when there are
+// no more matches or an error occurred, after which the Results are closed.
+func (r *Results) Next() bool {
+	if r.closed {
+		return false
+	}
+	for r.pos >= len(r.batch.items) {
+		if err := r.ctx.Err(); err != nil {
+			r.err = err
+			r.Close()
+			return false
+		}
+		if r.batch.readErr != nil {
+			r.err = r.batch.readErr
+			r.Close()
+			return false
+		}
+		out, ok := <-r.queue
+		if !ok {
+			// The queue is also closed early when ctx is done
+			r.err = r.ctx.Err()
+			r.Close()
+			return false
+		}
+		r.batch = <-out
+		r.pos = 0
+		r.stats.Records += r.batch.records
+		r.stats.Errors += len(r.batch.errors)
+		if r.opts.ErrorHandler != nil {
+			for _, result := range r.batch.errors {
+				r.opts.ErrorHandler(result.Index, result.Offset, result.Err)
+			}
+		}
+	}
+
+	r.item = r.batch.items[r.pos]
+	r.batch.items[r.pos] = Item{}
+	r.pos++
+	r.stats.Passed++
+	if r.opts.Paths != nil {
+		path, err := r.opts.Paths.Path(r.item.Entry)
+		if err != nil {
+			r.err = fmt.Errorf("unable to resolve path of record at offset %d: %v", r.item.Offset, err)
+			r.Close()
+			return false
+		}
+		r.item.Path = path
+	}
+	if r.projection == nil {
+		r.value = r.item.Entry
+		return true
+	}
+	value, err := r.projection(&r.item)
+	if err != nil {
+		r.err = fmt.Errorf("unable to project record at offset %d: %v", r.item.Offset, err)
+		r.Close()
+		return false
+	}
+	r.value = value
+	return true
+}
+
+// Item returns the current match.
+func (r *Results) Item() Item {
+	return r.item
+}
+
+// Value returns the value of the current match, as returned by the projection.
+func (r *Results) Value() interface{} {
+	return r.value
+}
+
+// Err returns the error that stopped the query, if any. It should be checked after Next returns false.
+func (r *Results) Err() error {
+	return r.err
+}
+
+// Stats returns the counts of the records processed so far. Records and Errors include records evaluated ahead of the
+// last call to Next; Passed is the number of matches returned by Next.
+func (r *Results) Stats() Stats {
+	return r.stats
+}
+
+// Close stops the query, releasing all goroutines. It is safe to call Close more than once.
+func (r *Results) Close() {
+	if r.closed {
+		return
+	}
+	r.closed = true
+	close(r.cancel)
+	for range r.queue {
+		// Drain the remaining batches, so the feeding goroutine can finish
+	}
+}
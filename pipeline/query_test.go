@@ -0,0 +1,116 @@
+package pipeline_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/export"
+	"github.com/t9t/gomft/pipeline"
+)
+
+func TestQuery(t *testing.T) {
+	dump := testDump()
+	var errorIndexes []int
+	opts := pipeline.QueryOptions{
+		Paths: pipeline.NewPathResolver(bytes.NewReader(dump), 1024, 0),
+		ErrorHandler: func(index int, offset int64, err error) {
+			errorIndexes = append(errorIndexes, index)
+		},
+	}
+	results := pipeline.Query(bytes.NewReader(dump), opts,
+		func(item *pipeline.Item) bool { return !item.Entry.Directory },
+		func(item *pipeline.Item) (interface{}, error) { return item.Path, nil })
+	defer results.Close()
+
+	var paths []interface{}
+	for results.Next() {
+		paths = append(paths, results.Value())
+	}
+	require.Nil(t, results.Err())
+	assert.Equal(t, []interface{}{"/Windows/notepad.exe", "/$Orphan/lost.txt", "/$Orphan/stale.txt"}, paths)
+	assert.Equal(t, "stale.txt", results.Item().Entry.Name)
+	assert.Equal(t, 10, results.Item().Index)
+	assert.Equal(t, pipeline.Stats{Records: 5, Passed: 3, Errors: 1}, results.Stats())
+	assert.Equal(t, []int{11}, errorIndexes)
+	assert.False(t, results.Next())
+}
+
+func TestQuery_Defaults(t *testing.T) {
+	results := pipeline.Query(bytes.NewReader(testDump()), pipeline.QueryOptions{}, nil, nil)
+	var names []string
+	for results.Next() {
+		names = append(names, results.Value().(export.Entry).Name)
+		assert.Equal(t, "", results.Item().Path)
+	}
+	require.Nil(t, results.Err())
+	assert.Equal(t, []string{".", "Windows", "notepad.exe", "lost.txt", "stale.txt"}, names)
+}
+
+func TestQuery_ParallelKeepsOrder(t *testing.T) {
+	dump := make([]byte, 0, 1000*1024)
+	for i := 0; i < 1000; i++ {
+		dump = append(dump, testRecord(uint64(i), 1, 5, 5, fmt.Sprintf("file%d.txt", i)).Bytes()...)
+	}
+	results := pipeline.Query(bytes.NewReader(dump), pipeline.QueryOptions{Workers: 4},
+		func(item *pipeline.Item) bool { return item.Index%3 == 0 },
+		func(item *pipeline.Item) (interface{}, error) { return item.Index, nil })
+	expected := 0
+	for results.Next() {
+		require.Equal(t, expected, results.Value())
+		expected += 3
+	}
+	require.Nil(t, results.Err())
+	assert.Equal(t, 1002, expected)
+	assert.Equal(t, pipeline.Stats{Records: 1000, Passed: 334}, results.Stats())
+}
+
+func TestQuery_EarlyTermination(t *testing.T) {
+	dump := make([]byte, 0, 1000*1024)
+	for i := 0; i < 1000; i++ {
+		dump = append(dump, testRecord(uint64(i), 1, 5, 5, "file.txt").Bytes()...)
+	}
+	results := pipeline.Query(bytes.NewReader(dump), pipeline.QueryOptions{Workers: 2}, nil, nil)
+	require.True(t, results.Next())
+	require.True(t, results.Next())
+	results.Close()
+	assert.False(t, results.Next())
+	assert.Nil(t, results.Err())
+	assert.Equal(t, 2, results.Stats().Passed)
+	results.Close()
+}
+
+func TestQuery_ProjectionError(t *testing.T) {
+	results := pipeline.Query(bytes.NewReader(testDump()), pipeline.QueryOptions{}, nil,
+		func(item *pipeline.Item) (interface{}, error) { return nil, errors.New("projection failed") })
+	assert.False(t, results.Next())
+	assert.EqualError(t, results.Err(), "unable to project record at offset 5120: projection failed")
+}
+
+func TestQuery_ReadError(t *testing.T) {
+	results := pipeline.Query(&failingReader{}, pipeline.QueryOptions{}, nil, nil)
+	assert.False(t, results.Next())
+	assert.EqualError(t, results.Err(), "unable to read record data: read failed")
+}
+
+func TestQueryContext(t *testing.T) {
+	dump := make([]byte, 0, 1000*1024)
+	for i := 0; i < 1000; i++ {
+		dump = append(dump, testRecord(uint64(i), 1, 5, 5, "file.txt").Bytes()...)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	results := pipeline.QueryContext(ctx, bytes.NewReader(dump), pipeline.QueryOptions{Workers: 2}, nil, nil)
+	defer results.Close()
+	require.True(t, results.Next())
+	cancel()
+	count := 1
+	for results.Next() {
+		count++
+	}
+	assert.Less(t, count, 1000)
+	assert.Equal(t, context.Canceled, results.Err())
+}
@@ -0,0 +1,356 @@
+/*
+	Package pretty formats an MFT record and all its attributes as an indented, human readable block of text, with the
+	names of flags, formatted times and sizes, and decoded run lists. It is meant for debugging and for quickly
+	inspecting a record, instead of printing the structs of the mft package using %+v.
+
+	Basic usage
+
+	Pass a parsed record to Write, or use Format to get the text as a string.
+			// Error handling left out for brevity
+			record, err := mft.ParseRecord(b)
+			err = pretty.Write(os.Stdout, record, pretty.Options{})
+			log.Printf("unexpected record:\n%s", pretty.Format(record, pretty.Options{}))
+
+	Implementation notes
+
+	The output starts with the record header, followed by a block per attribute, in the order in which the attributes
+	appear in the record. The data of the $STANDARD_INFORMATION, $ATTRIBUTE_LIST, $FILE_NAME, $VOLUME_NAME,
+	$VOLUME_INFORMATION, $INDEX_ROOT and $REPARSE_POINT attributes is decoded; the data of other resident attributes is
+	printed as a hex dump, limited to DataBytes bytes. The data runs of non-resident attributes are listed with the
+	virtual and logical cluster numbers of each run. Attribute data which cannot be decoded is printed as a hex dump
+	too, together with the error, so a damaged record can still be inspected.
+
+	The output is meant for humans and may change between versions; use the export package to process records
+	programmatically, or the istat package for output that matches The Sleuth Kit.
+*/
+package pretty
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/utf16"
+)
+
+// DefaultDataBytes is the number of bytes of resident data printed as a hex dump when Options.DataBytes is zero.
+const DefaultDataBytes = 256
+
+// Options control the output of Write and Format.
+type Options struct {
+	// Location is the time zone in which times are printed. When nil, UTC is used.
+	Location *time.Location
+	// DataBytes is the maximum number of bytes of resident data printed as a hex dump. When zero, DefaultDataBytes is
+	// used; when negative, no data is printed.
+	DataBytes int
+}
+
+// printer writes the lines of the output, indented by the current level.
+type printer struct {
+	w      *bufio.Writer
+	opts   Options
+	parse  mft.ParseOptions
+	indent int
+}
+
+// Write writes the record and all its attributes to w as human readable text.
+func Write(w io.Writer, r mft.Record, opts Options) error {
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+	if opts.DataBytes == 0 {
+		opts.DataBytes = DefaultDataBytes
+	}
+	p := &printer{w: bufio.NewWriter(w), opts: opts, parse: mft.ParseOptions{Location: opts.Location}}
+	p.writeHeader(r)
+	for _, a := range r.Attributes {
+		p.line("")
+		p.writeAttribute(a)
+	}
+	return p.w.Flush()
+}
+
+// Format returns the record and all its attributes as human readable text.
+func Format(r mft.Record, opts Options) string {
+	b := &bytes.Buffer{}
+	Write(b, r, opts) // writing to a bytes.Buffer cannot fail
+	return b.String()
+}
+
+func (p *printer) line(format string, v ...interface{}) {
+	if format != "" {
+		p.w.WriteString(strings.Repeat("  ", p.indent))
+		fmt.Fprintf(p.w, format, v...)
+	}
+	p.w.WriteString("\n")
+}
+
+// field writes a line with a label and a value, aligning the values.
+func (p *printer) field(label string, format string, v ...interface{}) {
+	p.line("%-24s %s", label+":", fmt.Sprintf(format, v...))
+}
+
+func (p *printer) writeHeader(r mft.Record) {
+	kind := "file"
+	if r.Flags.Is(mft.RecordFlagIsDirectory) {
+		kind = "directory"
+	}
+	status := "in use"
+	if !r.Flags.Is(mft.RecordFlagInUse) {
+		status = "not in use"
+	}
+	p.line("Record %d (sequence %d), %s %s", r.FileReference.RecordNumber, r.FileReference.SequenceNumber, kind, status)
+	p.indent++
+	defer func() { p.indent-- }()
+	p.field("Signature", "%q", string(r.Signature))
+	p.field("Flags", "%s", r.Flags)
+	if r.BaseRecordReference == (mft.FileReference{}) {
+		p.field("Base record", "none (this is a base record)")
+	} else {
+		p.field("Base record", "%s", formatReference(r.BaseRecordReference))
+	}
+	p.field("$LogFile sequence", "%d", r.LogFileSequenceNumber)
+	p.field("Hard links", "%d", r.HardLinkCount)
+	p.field("Size", "%d of %d bytes used", r.ActualSize, r.AllocatedSize)
+	p.field("Next attribute ID", "%d", r.NextAttributeId)
+	if r.LegacyHeader {
+		p.field("Header", "NTFS 1.2 (record number derived from position)")
+	}
+	p.field("Attributes", "%d", len(r.Attributes))
+}
+
+func (p *printer) writeAttribute(a mft.Attribute) {
+	title := fmt.Sprintf("%s (0x%x)", a.Type.Name(), uint32(a.Type))
+	if a.Name != "" {
+		title += fmt.Sprintf(" %q", a.Name)
+	}
+	title += fmt.Sprintf(", id %d", a.AttributeId)
+	if a.Resident {
+		title += fmt.Sprintf(", resident, %s", formatSize(uint64(len(a.Data))))
+	} else {
+		title += fmt.Sprintf(", non-resident, %s, %s allocated", formatSize(a.ActualSize), formatSize(a.AllocatedSize))
+	}
+	if a.Flags != 0 {
+		title += ", " + a.Flags.String()
+	}
+	p.line("%s", title)
+	p.indent++
+	defer func() { p.indent-- }()
+
+	if !a.Resident {
+		p.writeRuns(a.Data)
+		return
+	}
+	var err error
+	switch a.Type {
+	case mft.AttributeTypeStandardInformation:
+		err = p.writeStandardInformation(a.Data)
+	case mft.AttributeTypeAttributeList:
+		err = p.writeAttributeList(a.Data)
+	case mft.AttributeTypeFileName:
+		err = p.writeFileName(a.Data)
+	case mft.AttributeTypeVolumeName:
+		p.field("Volume name", "%q", utf16.DecodeString(a.Data, binary.LittleEndian))
+	case mft.AttributeTypeVolumeInformation:
+		err = p.writeVolumeInformation(a.Data)
+	case mft.AttributeTypeIndexRoot:
+		err = p.writeIndexRoot(a.Data)
+	case mft.AttributeTypeReparsePoint:
+		err = p.writeReparsePoint(a.Data)
+	default:
+		p.writeData(a.Data)
+	}
+	if err != nil {
+		p.field("Error", "%v", err)
+		p.writeData(a.Data)
+	}
+}
+
+func (p *printer) writeStandardInformation(b []byte) error {
+	si, err := p.parse.ParseStandardInformation(b)
+	if err != nil {
+		return err
+	}
+	p.writeTimes(si.Creation, si.FileLastModified, si.MftLastModified, si.LastAccess)
+	p.field("File attributes", "%s", si.FileAttributes)
+	if len(b) > 0x30 {
+		// Fields added in NTFS 3.0
+		p.field("Owner ID", "%d", si.OwnerId)
+		p.field("Security ID", "%d", si.SecurityId)
+		p.field("Quota charged", "%d", si.QuotaCharged)
+		p.field("Update sequence number", "%d", si.UpdateSequenceNumber)
+	}
+	return nil
+}
+
+func (p *printer) writeAttributeList(b []byte) error {
+	entries, err := p.parse.ParseAttributeList(b)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		name := ""
+		if e.Name != "" {
+			name = fmt.Sprintf(" %q", e.Name)
+		}
+		p.line("%s%s, id %d, starting VCN %d, in record %s", e.Type.Name(), name, e.AttributeId, e.StartingVCN, formatReference(e.BaseRecordReference))
+	}
+	return nil
+}
+
+func (p *printer) writeFileName(b []byte) error {
+	fn, err := p.parse.ParseFileName(b)
+	if err != nil {
+		return err
+	}
+	p.field("Name", "%q (%s)", fn.Name, fn.Namespace)
+	p.field("Parent", "%s", formatReference(fn.ParentFileReference))
+	p.writeTimes(fn.Creation, fn.FileLastModified, fn.MftLastModified, fn.LastAccess)
+	p.field("File attributes", "%s", fn.Flags)
+	p.field("Size", "%s, %s allocated", formatSize(fn.ActualSize), formatSize(fn.AllocatedSize))
+	return nil
+}
+
+func (p *printer) writeVolumeInformation(b []byte) error {
+	vi, err := mft.ParseVolumeInformation(b)
+	if err != nil {
+		return err
+	}
+	p.field("NTFS version", "%d.%d", vi.MajorVersion, vi.MinorVersion)
+	p.field("Volume flags", "%s", vi.Flags)
+	return nil
+}
+
+func (p *printer) writeIndexRoot(b []byte) error {
+	root, err := p.parse.ParseIndexRoot(b)
+	if err != nil {
+		return err
+	}
+	p.field("Indexed attribute", "%s", root.AttributeType.Name())
+	p.field("Collation", "%s", root.CollationType)
+	p.field("Index block size", "%d bytes", root.BytesPerRecord)
+	for _, e := range root.Entries {
+		if e.FileName.Name == "" {
+			continue
+		}
+		p.line("%q -> record %s", e.FileName.Name, formatReference(e.FileReference))
+	}
+	return nil
+}
+
+func (p *printer) writeReparsePoint(b []byte) error {
+	rp, err := mft.ParseReparsePoint(b)
+	if err != nil {
+		return err
+	}
+	p.field("Reparse tag", "%s (0x%08x)", rp.Tag.Name(), uint32(rp.Tag))
+	if target, err := mft.ParseLinkTarget(rp); err == nil {
+		p.field("Target", "%q", target.PrintName)
+		if target.SubstituteName != target.PrintName {
+			p.field("Substitute name", "%q", target.SubstituteName)
+		}
+		if target.Relative {
+			p.field("Relative", "yes")
+		}
+		return nil
+	}
+	p.writeData(rp.Data)
+	return nil
+}
+
+func (p *printer) writeTimes(creation, modified, mftModified, accessed time.Time) {
+	p.field("Created", "%s", p.formatTime(creation))
+	p.field("Modified", "%s", p.formatTime(modified))
+	p.field("MFT modified", "%s", p.formatTime(mftModified))
+	p.field("Accessed", "%s", p.formatTime(accessed))
+}
+
+// writeRuns lists the data runs of a non-resident attribute.
+func (p *printer) writeRuns(b []byte) {
+	runs, err := mft.ParseDataRuns(b)
+	if err != nil {
+		p.field("Error", "unable to parse data runs: %v", err)
+		p.writeData(b)
+		return
+	}
+	p.field("Data runs", "%d", len(runs))
+	vcn := uint64(0)
+	lcn := int64(0)
+	for _, run := range runs {
+		vcns := formatRange(int64(vcn), run.LengthInClusters)
+		if run.Sparse {
+			p.line("VCN %s: sparse (%s)", vcns, formatClusters(run.LengthInClusters))
+		} else {
+			lcn += run.OffsetCluster
+			p.line("VCN %s: LCN %s (%s)", vcns, formatRange(lcn, run.LengthInClusters), formatClusters(run.LengthInClusters))
+		}
+		vcn += run.LengthInClusters
+	}
+}
+
+// writeData writes resident data as a hex dump, limited to the DataBytes of the Options.
+func (p *printer) writeData(b []byte) {
+	if len(b) == 0 || p.opts.DataBytes < 0 {
+		return
+	}
+	data := b
+	if len(data) > p.opts.DataBytes {
+		data = data[:p.opts.DataBytes]
+	}
+	for _, l := range strings.Split(strings.TrimSuffix(hex.Dump(data), "\n"), "\n") {
+		p.line("%s", l)
+	}
+	if len(data) < len(b) {
+		p.line("... %d more bytes", len(b)-len(data))
+	}
+}
+
+// formatTime formats a time with the full precision of a "file time" of 100 nanoseconds.
+func (p *printer) formatTime(t time.Time) string {
+	if t.Equal(mft.ConvertFileTime(0)) {
+		return "not set"
+	}
+	return t.In(p.opts.Location).Format("2006-01-02 15:04:05.0000000 MST")
+}
+
+func formatReference(ref mft.FileReference) string {
+	return fmt.Sprintf("%d (sequence %d)", ref.RecordNumber, ref.SequenceNumber)
+}
+
+func formatClusters(n uint64) string {
+	if n == 1 {
+		return "1 cluster"
+	}
+	return fmt.Sprintf("%d clusters", n)
+}
+
+func formatRange(start int64, length uint64) string {
+	if length <= 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d-%d", start, start+int64(length)-1)
+}
+
+// formatSize formats a number of bytes, followed by the size in KiB, MiB or GiB when it is at least 1 KiB.
+func formatSize(n uint64) string {
+	units := []string{"KiB", "MiB", "GiB", "TiB"}
+	if n < 1024 {
+		return fmt.Sprintf("%d bytes", n)
+	}
+	size := float64(n)
+	unit := ""
+	for _, u := range units {
+		if size < 1024 {
+			break
+		}
+		size /= 1024
+		unit = u
+	}
+	return fmt.Sprintf("%d bytes (%.2f %s)", n, size, unit)
+}
@@ -0,0 +1,79 @@
+package pretty_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/mfttest"
+	"github.com/t9t/gomft/pretty"
+)
+
+func TestWrite(t *testing.T) {
+	buf := &bytes.Buffer{}
+	require.Nil(t, pretty.Write(buf, mfttest.SampleFileRecord().Record(), pretty.Options{}))
+	out := buf.String()
+
+	expected := []string{
+		"Record 64 (sequence 2), file in use\n",
+		"  Flags:                   InUse\n",
+		"  Base record:             none (this is a base record)\n",
+		"  Size:                    440 of 1024 bytes used\n",
+		"\n$STANDARD_INFORMATION (0x10), id 0, resident, 72 bytes\n",
+		"  Created:                 2020-02-05 14:59:38.1168862 UTC\n",
+		"  Accessed:                not set\n",
+		"  File attributes:         Hidden|Archive\n",
+		"  Update sequence number:  4711\n",
+		"\n$FILE_NAME (0x30), id 2, resident, 82 bytes\n",
+		"  Name:                    \"test.txt\" (Win32)\n",
+		"  Parent:                  5 (sequence 5)\n",
+		"\n$DATA (0x80), id 3, non-resident, 12345 bytes (12.06 KiB), 49152 bytes (48.00 KiB) allocated, Sparse\n",
+		"  Data runs:               3\n",
+		"  VCN 0-8: LCN 4158-4166 (9 clusters)\n",
+		"  VCN 9-10: sparse (2 clusters)\n",
+		"  VCN 11: LCN 4200 (1 cluster)\n",
+		"\n$DATA (0x80) \"Zone.Identifier\", id 4, resident, 24 bytes\n",
+		"  00000000  5b 5a 6f 6e 65 54 72 61  6e 73 66 65 72 5d 0d 0a  |[ZoneTransfer]..|\n",
+	}
+	for _, e := range expected {
+		assert.Contains(t, out, e)
+	}
+}
+
+func TestWrite_LeadingSparse(t *testing.T) {
+	record := mfttest.SampleFileRecord().Record()
+	// 2 sparse clusters, 1 cluster at 4200
+	record.Attributes[2].Data = []byte{0x01, 0x02, 0x21, 0x01, 0x68, 0x10, 0x00}
+	out := pretty.Format(record, pretty.Options{})
+	assert.Contains(t, out, "  VCN 0-1: sparse (2 clusters)\n  VCN 2: LCN 4200 (1 cluster)\n")
+}
+
+func TestWrite_Location(t *testing.T) {
+	loc := time.FixedZone("CET", 3600)
+	out := pretty.Format(mfttest.SampleFileRecord().Record(), pretty.Options{Location: loc})
+	assert.Contains(t, out, "  Created:                 2020-02-05 15:59:38.1168862 CET\n")
+}
+
+func TestWrite_DataBytes(t *testing.T) {
+	out := pretty.Format(mfttest.SampleFileRecord().Record(), pretty.Options{DataBytes: 4})
+	assert.Contains(t, out, "  00000000  5b 5a 6f 6e")
+	assert.Contains(t, out, "  ... 20 more bytes\n")
+
+	out = pretty.Format(mfttest.SampleFileRecord().Record(), pretty.Options{DataBytes: -1})
+	assert.NotContains(t, out, "00000000")
+}
+
+func TestWrite_Invalid(t *testing.T) {
+	record := mft.Record{
+		Signature:     []byte("FILE"),
+		FileReference: mft.FileReference{RecordNumber: 7, SequenceNumber: 1},
+		Attributes:    []mft.Attribute{{Type: mft.AttributeTypeStandardInformation, Resident: true, Data: []byte{0x01, 0x02}}},
+	}
+	out := pretty.Format(record, pretty.Options{})
+	assert.Contains(t, out, "Record 7 (sequence 1), file not in use\n")
+	assert.Contains(t, out, "  Error:")
+	assert.Contains(t, out, "  00000000  01 02")
+}
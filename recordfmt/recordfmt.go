@@ -0,0 +1,178 @@
+/*
+	Package recordfmt renders MFT records using a text/template, to create custom reports about records without writing
+	any code to decode their attributes.
+
+	Basic usage
+
+	Parse a template with New and Execute it for each record. The template is executed with a *mft.Record, so all its
+	fields and methods are available, along with helper functions that decode attributes and format their values.
+			// Error handling left out for brevity
+			t, err := recordfmt.New(`{{.FileReference.RecordNumber}} {{name .}} {{with stdinfo .}}{{filetime .Creation}}{{end}}`, recordfmt.Options{})
+			record, err := mft.ParseRecord(b)
+			err = t.Execute(os.Stdout, record)
+
+	See Funcs for a description of all helper functions. To use them in a template of your own, for example one which is
+	part of a larger report, add them using template.Funcs(recordfmt.Funcs(opts)).
+
+	Implementation notes
+
+	The helper functions extend those of export.TemplateFuncs, which are used for templates of export entries, so both
+	kinds of templates work alike. Functions which decode attributes return an error when an attribute cannot be parsed,
+	which stops executing the template; use the with action to skip a part of the template when a record has no such
+	attribute.
+*/
+package recordfmt
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/t9t/gomft/export"
+	"github.com/t9t/gomft/mft"
+)
+
+// Options configures a Template. The zero value formats times in UTC.
+type Options struct {
+	// Location is the time zone in which times are decoded and formatted. When nil, UTC is used.
+	Location *time.Location
+}
+
+// Template renders records using a text/template.
+type Template struct {
+	tmpl *template.Template
+}
+
+// New parses the text as template with the helper functions returned by Funcs. An error is returned if the template
+// cannot be parsed.
+func New(text string, opts Options) (*Template, error) {
+	tmpl, err := template.New("record").Funcs(Funcs(opts)).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse template: %v", err)
+	}
+	return &Template{tmpl: tmpl}, nil
+}
+
+// Execute executes the template for the record and writes the result to w. Unlike export.TemplateWriter, no newline
+// is written after the result.
+func (t *Template) Execute(w io.Writer, r mft.Record) error {
+	return t.tmpl.Execute(w, &r)
+}
+
+// Funcs returns the helper functions available in templates created by New. Besides all functions of
+// export.TemplateFuncs (such as time, size and quote), these are:
+//
+//	filetime TIME            formats the time.Time, or a raw uint64 file time, with the full precision of 100ns in the
+//	                         Location of the Options; unset times yield ""
+//	flags FLAGS              formats mft.RecordFlag, mft.FileAttribute, mft.AttributeFlags or mft.VolumeFlag bits as names
+//	                         separated by "|", eg. InUse|Directory; when no bits are set, "" is returned
+//	hexdump BYTES            formats the []byte as a hex dump like `hexdump -C`
+//	name RECORD              returns the preferred file name of the *mft.Record (see Record.PreferredFileName), or ""
+//	stdinfo RECORD           returns the decoded $STANDARD_INFORMATION of the *mft.Record, or nil when there is none
+//	filenames RECORD         returns all decoded $FILE_NAME attributes of the *mft.Record
+//	attributes TYPE RECORD   returns the attributes of the *mft.Record with the type name, such as "$DATA"
+//	runs ATTRIBUTE           returns the decoded data runs of the non-resident mft.Attribute
+func Funcs(opts Options) template.FuncMap {
+	loc := opts.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	parse := mft.ParseOptions{Location: loc}
+
+	funcs := export.TemplateFuncs()
+	funcs["filetime"] = func(v interface{}) (string, error) {
+		var t time.Time
+		switch v := v.(type) {
+		case time.Time:
+			t = v
+		case uint64:
+			if v == 0 {
+				return "", nil
+			}
+			t = mft.ConvertFileTime(v)
+		default:
+			return "", fmt.Errorf("filetime: unsupported type %T", v)
+		}
+		if t.IsZero() || t.Equal(mft.ConvertFileTime(0)) {
+			return "", nil
+		}
+		return t.In(loc).Format("2006-01-02 15:04:05.0000000 MST"), nil
+	}
+	funcs["flags"] = formatFlags
+	funcs["hexdump"] = func(b []byte) string {
+		return strings.TrimSuffix(hex.Dump(b), "\n")
+	}
+	funcs["name"] = func(r *mft.Record) string {
+		fn, ok := r.PreferredFileName()
+		if !ok {
+			return ""
+		}
+		return fn.Name
+	}
+	funcs["stdinfo"] = func(r *mft.Record) (*mft.StandardInformation, error) {
+		a, ok := r.FindFirstAttribute(mft.AttributeTypeStandardInformation)
+		if !ok {
+			return nil, nil
+		}
+		si, err := parse.ParseStandardInformation(a.Data)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse $STANDARD_INFORMATION: %v", err)
+		}
+		return &si, nil
+	}
+	funcs["filenames"] = func(r *mft.Record) ([]mft.FileName, error) {
+		names := make([]mft.FileName, 0)
+		for _, a := range r.FindAttributes(mft.AttributeTypeFileName) {
+			fn, err := parse.ParseFileName(a.Data)
+			if err != nil {
+				return nil, fmt.Errorf("unable to parse $FILE_NAME: %v", err)
+			}
+			names = append(names, fn)
+		}
+		return names, nil
+	}
+	funcs["attributes"] = func(typeName string, r *mft.Record) []mft.Attribute {
+		ret := make([]mft.Attribute, 0)
+		for _, a := range r.Attributes {
+			if a.Type.Name() == typeName {
+				ret = append(ret, a)
+			}
+		}
+		return ret
+	}
+	funcs["runs"] = func(a mft.Attribute) ([]mft.DataRun, error) {
+		if a.Resident {
+			return nil, fmt.Errorf("runs: attribute %s is resident", a.Type.Name())
+		}
+		runs, err := mft.ParseDataRuns(a.Data)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse data runs: %v", err)
+		}
+		return runs, nil
+	}
+	return funcs
+}
+
+func formatFlags(v interface{}) (string, error) {
+	var s fmt.Stringer
+	zero := false
+	switch v := v.(type) {
+	case mft.RecordFlag:
+		s, zero = v, v == 0
+	case mft.FileAttribute:
+		s, zero = v, v == 0
+	case mft.AttributeFlags:
+		s, zero = v, v == 0
+	case mft.VolumeFlag:
+		s, zero = v, v == 0
+	default:
+		return "", fmt.Errorf("flags: unsupported type %T", v)
+	}
+	if zero {
+		return "", nil
+	}
+	return s.String(), nil
+}
@@ -0,0 +1,62 @@
+package recordfmt_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/mfttest"
+	"github.com/t9t/gomft/recordfmt"
+)
+
+func execute(t *testing.T, text string, opts recordfmt.Options, r mft.Record) string {
+	tmpl, err := recordfmt.New(text, opts)
+	require.Nilf(t, err, "unable to parse template: %v", err)
+	buf := &bytes.Buffer{}
+	require.Nil(t, tmpl.Execute(buf, r))
+	return buf.String()
+}
+
+func TestTemplate(t *testing.T) {
+	text := `{{.FileReference.RecordNumber}} {{name .}} {{flags .Flags}} {{.IsDirectory}}` +
+		`{{with stdinfo .}} [{{filetime .Creation}}] [{{filetime .LastAccess}}] {{flags .FileAttributes}}{{end}}` +
+		`{{range filenames .}} {{.Namespace}}:{{quote .Name}} {{size .ActualSize}}{{end}}` +
+		`{{range attributes "$DATA" .}} {{.Name | printf "%q"}}:{{flags .Flags}}{{if not .Resident}}{{range runs .}} {{.OffsetCluster}}+{{.LengthInClusters}}{{end}}{{end}}{{end}}`
+	out := execute(t, text, recordfmt.Options{}, mfttest.SampleFileRecord().Record())
+	expected := `64 test.txt InUse false [2020-02-05 14:59:38.1168862 UTC] [] Hidden|Archive Win32:"test.txt" 12.06KiB` +
+		` "":Sparse 4158+9 0+2 42+1 "Zone.Identifier":`
+	assert.Equal(t, expected, out)
+}
+
+func TestTemplate_Location(t *testing.T) {
+	opts := recordfmt.Options{Location: time.FixedZone("CET", 3600)}
+	out := execute(t, `{{with stdinfo .}}{{filetime .Creation}} {{filetime .RawTimes.FileLastModified}}{{end}}`, opts, mfttest.SampleFileRecord().Record())
+	assert.Equal(t, "2020-02-05 15:59:38.1168862 CET 2020-02-05 15:59:38.1168862 CET", out)
+}
+
+func TestTemplate_Hexdump(t *testing.T) {
+	out := execute(t, `{{range attributes "$DATA" .}}{{if .Resident}}{{hexdump .Data}}{{end}}{{end}}`, recordfmt.Options{}, mfttest.SampleFileRecord().Record())
+	expected := "00000000  5b 5a 6f 6e 65 54 72 61  6e 73 66 65 72 5d 0d 0a  |[ZoneTransfer]..|\n" +
+		"00000010  5a 6f 6e 65 49 64 3d 33                           |ZoneId=3|"
+	assert.Equal(t, expected, out)
+}
+
+func TestTemplate_MissingAttributes(t *testing.T) {
+	out := execute(t, `[{{name .}}]{{with stdinfo .}}{{.Creation}}{{end}}{{range filenames .}}x{{end}}`, recordfmt.Options{}, mft.Record{})
+	assert.Equal(t, "[]", out)
+}
+
+func TestTemplate_Errors(t *testing.T) {
+	_, err := recordfmt.New("{{.Flags", recordfmt.Options{})
+	assert.NotNil(t, err, "invalid template")
+
+	invalid := mft.Record{Attributes: []mft.Attribute{{Type: mft.AttributeTypeStandardInformation, Resident: true, Data: []byte{0x01}}}}
+	for _, text := range []string{`{{stdinfo .}}`, `{{flags .Attributes}}`, `{{filetime "now"}}`, `{{range .Attributes}}{{runs .}}{{end}}`} {
+		tmpl, err := recordfmt.New(text, recordfmt.Options{})
+		require.Nilf(t, err, "unable to parse template: %v", err)
+		assert.NotNilf(t, tmpl.Execute(&bytes.Buffer{}, invalid), "expected error executing %s", text)
+	}
+}
@@ -0,0 +1,255 @@
+/*
+	Package recovery estimates how well the data of deleted files can be recovered. When a file is deleted, its record
+	keeps its data runs, but its clusters are released and may be reused by other files. By cross-checking the clusters
+	of each deleted record against the $Bitmap of the volume and against the extents of the files in use, each deleted
+	file is given a Confidence: intact, partially overwritten or reallocated.
+
+	Basic usage
+
+	Read the $Bitmap of the volume, add all records of the MFT to an Assessor, then call Assess() to obtain an
+	Assessment of each deleted record.
+			// Error handling left out for brevity
+			a := recovery.NewAssessor(bitmap)
+			for result := range mft.ParseAll(in, mft.ParseAllOptions{SkipEmpty: true}) {
+				if result.Err == nil {
+					err = a.Add(uint64(result.Index), result.Record)
+				}
+			}
+			for _, s := range a.Assess() {
+				fmt.Printf("record %d: %v (%.0f%% recoverable)\n", s.Reference.RecordNumber, s.Confidence,
+					s.Recoverable()*100)
+			}
+
+	Implementation notes
+
+	Like in the crosslink package, each non-resident attribute is converted into extents (ranges of clusters) using
+	mft.DataRunExtents, which leaves out sparse runs. Attributes in extension records are attributed to
+	their base record. A cluster of a deleted file counts as Overwritten when it is allocated according to the $Bitmap,
+	or when it is claimed by a record in use; both are checked since the $Bitmap may be out of date (for example when
+	the volume was not cleanly unmounted) and the MFT may not be complete. Clusters beyond the end of the $Bitmap (which
+	lie beyond the end of the volume) count as Overwritten as well, since they cannot be read.
+
+	An overwritten cluster has certainly been reused, but a free cluster may have been reused by a file which has since
+	been deleted as well, so Intact is the best case rather than a guarantee. The data of resident attributes is stored
+	in the record itself, so a deleted file without non-resident attributes is always Intact.
+
+	The extents of the records in use are kept in memory, as are the extents of the deleted records, and every cluster
+	of a deleted file is checked against the $Bitmap, so the time taken is proportional to the total size of the
+	deleted files.
+*/
+package recovery
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/t9t/gomft/mft"
+)
+
+// Confidence indicates how much of the data of a deleted file is still present on the volume.
+type Confidence int
+
+// Confidence values.
+const (
+	// Unknown means the data runs of the file could not be parsed.
+	Unknown Confidence = iota
+	// Intact means none of the clusters of the file have been reused.
+	Intact
+	// PartiallyOverwritten means some, but not all, of the clusters of the file have been reused.
+	PartiallyOverwritten
+	// Reallocated means all clusters of the file have been reused.
+	Reallocated
+)
+
+func (c Confidence) String() string {
+	switch c {
+	case Intact:
+		return "intact"
+	case PartiallyOverwritten:
+		return "partially overwritten"
+	case Reallocated:
+		return "reallocated"
+	default:
+		return "unknown"
+	}
+}
+
+// Assessment describes the recoverability of the data of a deleted file.
+type Assessment struct {
+	Reference   mft.FileReference // the reference of the deleted (base) record
+	Clusters    uint64            // the number of clusters occupied by the non-resident attributes of the file
+	Overwritten uint64            // the number of those clusters which are allocated or claimed by a file in use
+	ClaimedBy   []uint64          // the record numbers of the files in use claiming clusters of the file, in order
+	Confidence  Confidence
+	Error       string // the reason the data runs of an attribute could not be parsed; that attribute is left out
+}
+
+// Recoverable returns the fraction (from 0 to 1) of the clusters of the file which have not been overwritten. It is 1
+// for a file without clusters.
+func (a Assessment) Recoverable() float64 {
+	if a.Clusters == 0 {
+		return 1
+	}
+	return float64(a.Clusters-a.Overwritten) / float64(a.Clusters)
+}
+
+// extent is a contiguous range of clusters of a record.
+type extent struct {
+	record  uint64 // the base record number
+	cluster uint64
+	length  uint64
+}
+
+func (e extent) end() uint64 {
+	return e.cluster + e.length
+}
+
+// Assessor collects the extents of records to assess the deleted ones. An Assessor is not safe for concurrent use.
+type Assessor struct {
+	bitmap  mft.Bitmap
+	inUse   []extent
+	deleted []extent
+	files   map[uint64]*Assessment
+}
+
+// NewAssessor creates an empty Assessor using the $Bitmap of the volume. When the bitmap is nil, the clusters of
+// deleted files are only checked against the files in use.
+func NewAssessor(bitmap mft.Bitmap) *Assessor {
+	return &Assessor{bitmap: bitmap, files: make(map[uint64]*Assessment)}
+}
+
+// Add adds a record to the Assessor. The clusters of a record in use count as reused; those of a deleted record are
+// assessed by Assess. The number is the record number, the position of the record in the MFT. When the data runs of an
+// attribute cannot be parsed, the other attributes are still added and an error is returned, which for a deleted
+// file is also kept in its Assessment.
+func (a *Assessor) Add(number uint64, r mft.Record) error {
+	reference := mft.FileReference{RecordNumber: number, SequenceNumber: r.FileReference.SequenceNumber}
+	if r.IsExtension() {
+		reference = r.BaseRecordReference
+	}
+	inUse := r.IsInUse()
+	var file *Assessment
+	if !inUse {
+		file = a.files[reference.RecordNumber]
+		if file == nil {
+			file = &Assessment{Reference: reference}
+			a.files[reference.RecordNumber] = file
+		} else if !r.IsExtension() {
+			file.Reference = reference
+		}
+	}
+
+	var firstErr error
+	for _, attr := range r.Attributes {
+		if attr.Resident {
+			continue
+		}
+		runs, err := mft.ParseDataRuns(attr.Data)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("unable to parse data runs of %s attribute %q of record %d: %v", attr.Type.Name(), attr.Name, number, err)
+				if file != nil && file.Error == "" {
+					file.Error = firstErr.Error()
+				}
+			}
+			continue
+		}
+		for _, x := range mft.DataRunExtents(runs) {
+			e := extent{record: reference.RecordNumber, cluster: x.Cluster, length: x.Length}
+			if inUse {
+				a.inUse = append(a.inUse, e)
+			} else {
+				a.deleted = append(a.deleted, e)
+				file.Clusters += e.length
+			}
+		}
+	}
+	return firstErr
+}
+
+// Assess returns the Assessment of each deleted record, ordered by record number.
+func (a *Assessor) Assess() []Assessment {
+	inUse := append([]extent(nil), a.inUse...)
+	sort.SliceStable(inUse, func(i, j int) bool { return inUse[i].cluster < inUse[j].cluster })
+	deleted := append([]extent(nil), a.deleted...)
+	sort.SliceStable(deleted, func(i, j int) bool { return deleted[i].cluster < deleted[j].cluster })
+
+	claimedBy := make(map[uint64]map[uint64]bool)
+	overwritten := make(map[uint64]uint64)
+	next := 0
+	active := make([]extent, 0)
+	for _, d := range deleted {
+		// Drop the extents in use which ended before this one starts, and add those starting before it ends
+		n := 0
+		for _, e := range active {
+			if e.end() > d.cluster {
+				active[n] = e
+				n++
+			}
+		}
+		active = active[:n]
+		for ; next < len(inUse) && inUse[next].cluster < d.end(); next++ {
+			if inUse[next].end() > d.cluster {
+				active = append(active, inUse[next])
+			}
+		}
+
+		claimed := make([]extent, 0)
+		for _, e := range active {
+			if e.cluster >= d.end() || e.record == d.record {
+				continue
+			}
+			if claimedBy[d.record] == nil {
+				claimedBy[d.record] = make(map[uint64]bool)
+			}
+			claimedBy[d.record][e.record] = true
+			claimed = append(claimed, e)
+		}
+		overwritten[d.record] += a.overwritten(d, claimed)
+	}
+
+	assessments := make([]Assessment, 0, len(a.files))
+	for number, file := range a.files {
+		s := *file
+		s.Overwritten = overwritten[number]
+		s.ClaimedBy = make([]uint64, 0, len(claimedBy[number]))
+		for n := range claimedBy[number] {
+			s.ClaimedBy = append(s.ClaimedBy, n)
+		}
+		sort.Slice(s.ClaimedBy, func(i, j int) bool { return s.ClaimedBy[i] < s.ClaimedBy[j] })
+		switch {
+		case s.Overwritten == 0 && s.Error != "" && s.Clusters == 0:
+			s.Confidence = Unknown
+		case s.Overwritten == 0:
+			s.Confidence = Intact
+		case s.Overwritten < s.Clusters:
+			s.Confidence = PartiallyOverwritten
+		default:
+			s.Confidence = Reallocated
+		}
+		assessments = append(assessments, s)
+	}
+	sort.Slice(assessments, func(i, j int) bool {
+		return assessments[i].Reference.RecordNumber < assessments[j].Reference.RecordNumber
+	})
+	return assessments
+}
+
+// overwritten returns the number of clusters of the deleted extent which are allocated in the bitmap or lie within any
+// of the claimed extents.
+func (a *Assessor) overwritten(d extent, claimed []extent) uint64 {
+	count := uint64(0)
+	for c := d.cluster; c < d.end(); c++ {
+		if a.bitmap != nil && (c >= a.bitmap.Len() || a.bitmap.IsSet(c)) {
+			count++
+			continue
+		}
+		for _, e := range claimed {
+			if c >= e.cluster && c < e.end() {
+				count++
+				break
+			}
+		}
+	}
+	return count
+}
@@ -0,0 +1,97 @@
+package recovery_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/mfttest"
+	"github.com/t9t/gomft/recovery"
+)
+
+func deleted(runs ...mft.DataRun) *mfttest.RecordBuilder {
+	return mfttest.File(4096, runs...).WithFlags(0)
+}
+
+func TestAssessor(t *testing.T) {
+	// Clusters 0-199 free, except for 150-159
+	bitmap := make(mft.Bitmap, 25)
+	bitmap[150/8] = 0xc0
+	bitmap[152/8] = 0xff
+	a := recovery.NewAssessor(bitmap)
+	add := func(number uint64, b *mfttest.RecordBuilder) {
+		require.Nil(t, a.Add(number, b.Record()))
+	}
+	// In use, 100-109 (not marked in the bitmap)
+	add(40, mfttest.File(4096, mfttest.Run(100, 10)))
+	// Deleted, 10-19 and sparse
+	add(41, deleted(mfttest.Run(10, 10), mfttest.SparseRun(10)))
+	// Deleted, 95-104, partially claimed by 40
+	add(42, deleted(mfttest.Run(95, 10)))
+	// Deleted, 150-159, allocated in the bitmap; and in an extension record 105-106, claimed by 40
+	add(43, deleted(mfttest.Run(150, 10)))
+	add(44, deleted(mfttest.Run(105, 2)).WithBaseRecord(mfttest.Reference(43)))
+	// Deleted, resident data only
+	add(45, mfttest.NewRecord().WithResidentData([]byte("hello")).WithFlags(0))
+	// Deleted, 190-209, partially beyond the end of the bitmap
+	add(46, deleted(mfttest.Run(190, 20)))
+
+	assessments := a.Assess()
+	require.Equal(t, 5, len(assessments))
+	for i, expected := range []struct {
+		number      uint64
+		clusters    uint64
+		overwritten uint64
+		claimedBy   []uint64
+		confidence  recovery.Confidence
+	}{
+		{41, 10, 0, []uint64{}, recovery.Intact},
+		{42, 10, 5, []uint64{40}, recovery.PartiallyOverwritten},
+		{43, 12, 12, []uint64{40}, recovery.Reallocated},
+		{45, 0, 0, []uint64{}, recovery.Intact},
+		{46, 20, 10, []uint64{}, recovery.PartiallyOverwritten},
+	} {
+		s := assessments[i]
+		assert.Equal(t, expected.number, s.Reference.RecordNumber, "record number of %d", i)
+		assert.Equal(t, expected.clusters, s.Clusters, "clusters of %d", expected.number)
+		assert.Equal(t, expected.overwritten, s.Overwritten, "overwritten of %d", expected.number)
+		assert.Equal(t, expected.claimedBy, s.ClaimedBy, "claimed by of %d", expected.number)
+		assert.Equal(t, expected.confidence, s.Confidence, "confidence of %d", expected.number)
+	}
+	assert.Equal(t, 0.5, assessments[1].Recoverable())
+	assert.Equal(t, 1.0, assessments[3].Recoverable())
+	assert.Equal(t, "partially overwritten", assessments[1].Confidence.String())
+}
+
+func TestAssessor_NoBitmap(t *testing.T) {
+	a := recovery.NewAssessor(nil)
+	require.Nil(t, a.Add(40, mfttest.File(4096, mfttest.Run(100, 10)).Record()))
+	require.Nil(t, a.Add(41, deleted(mfttest.Run(100, 10)).Record()))
+	require.Nil(t, a.Add(42, deleted(mfttest.Run(5000, 10)).Record()))
+	assessments := a.Assess()
+	require.Equal(t, 2, len(assessments))
+	assert.Equal(t, recovery.Reallocated, assessments[0].Confidence)
+	assert.Equal(t, recovery.Intact, assessments[1].Confidence)
+}
+
+func TestAssessor_LeadingSparse(t *testing.T) {
+	a := recovery.NewAssessor(nil)
+	// $Boot in use at cluster 0, and a deleted file starting with a hole, which does not overlap it
+	require.Nil(t, a.Add(7, mfttest.File(4096, mfttest.Run(0, 2)).Record()))
+	require.Nil(t, a.Add(41, deleted(mfttest.SparseRun(16), mfttest.Run(100, 10)).Record()))
+	assessments := a.Assess()
+	require.Equal(t, 1, len(assessments))
+	assert.Equal(t, uint64(10), assessments[0].Clusters)
+	assert.Equal(t, recovery.Intact, assessments[0].Confidence)
+}
+
+func TestAssessor_InvalidDataRuns(t *testing.T) {
+	a := recovery.NewAssessor(nil)
+	err := a.Add(41, mfttest.NewRecord().WithAttribute(mft.Attribute{Type: mft.AttributeTypeData, Data: []byte{0x88, 0x01}}).WithFlags(0).Record())
+	assert.EqualError(t, err, `unable to parse data runs of $DATA attribute "" of record 41: expected at least 17 bytes of datarun data but is 8`)
+	assessments := a.Assess()
+	require.Equal(t, 1, len(assessments))
+	assert.Equal(t, recovery.Unknown, assessments[0].Confidence)
+	assert.Equal(t, err.Error(), assessments[0].Error)
+}
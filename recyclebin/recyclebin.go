@@ -0,0 +1,213 @@
+/*
+	Package recyclebin parses the metadata of files in the Windows Recycle Bin. Since Windows Vista, a file deleted to
+	the Recycle Bin is renamed to $R followed by six random characters and its original extension, in the
+	$Recycle.Bin\<SID> directory of the user who deleted it. Next to it, a $I file with the same suffix records the
+	original path, the size and the time of deletion.
+
+	Basic usage
+
+	Parse the contents of a $I file using Parse, or read all $I files in a directory of extracted files (such as the
+	$Recycle.Bin\<SID> directory of an archive created by the archive package) using ReadDir, which pairs each with
+	its $R file.
+			// Error handling left out for brevity
+			items, err := recyclebin.ReadDir("extracted/$Recycle.Bin/S-1-5-21-1004336348-1177238915-682003330-1000")
+			for _, item := range items {
+				fmt.Printf("%s deleted at %v: %s\n", item.Info.Path, item.Info.Deleted, item.DataName)
+			}
+
+	Implementation notes
+
+	There are two versions of the $I format, both starting with the version, the size of the deleted file and the time
+	of deletion as a FILETIME. Version 1 (Windows Vista up to 8.1) is followed by the path as a NUL terminated UTF-16
+	string in a fixed field of 260 characters (MAX_PATH). Version 2 (Windows 10 and later) is followed by the length of
+	the path in characters, including the terminating NUL, and the path itself, so it supports longer paths.
+
+	A $I file is at most a few hundred bytes, so its data is practically always resident in its MFT record.
+
+	Deleting a directory moves the directory as a whole: the $R name is that of a directory containing the original
+	files, and the Size in the $I file is the total size of its contents.
+*/
+package recyclebin
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/t9t/gomft/binutil"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/utf16"
+)
+
+// Prefixes of the names of the files in the Recycle Bin.
+const (
+	InfoPrefix = "$I" // the metadata of a deleted file
+	DataPrefix = "$R" // the deleted file itself
+)
+
+// Versions of the $I format.
+const (
+	Version1 = 1 // Windows Vista up to 8.1
+	Version2 = 2 // Windows 10 and later
+)
+
+// version1PathLength is the size of the path field in version 1, in UTF-16 characters.
+const version1PathLength = 260
+
+// Info is the metadata of a deleted file, as stored in a $I file.
+type Info struct {
+	Version uint64
+	Size    uint64
+	Deleted time.Time
+	Path    string // the original path, such as C:\Users\bob\Documents\report.docx
+}
+
+// Parse parses the contents of a $I file.
+func Parse(b []byte) (Info, error) {
+	if len(b) < 0x18 {
+		return Info{}, fmt.Errorf("expected at least %d bytes but got %d", 0x18, len(b))
+	}
+	r := binutil.NewLittleEndianReader(b)
+	info := Info{
+		Version: r.Uint64(0x00),
+		Size:    r.Uint64(0x08),
+		Deleted: mft.ConvertFileTime(r.Uint64(0x10)),
+	}
+	var path []byte
+	switch info.Version {
+	case Version1:
+		length := 0x18 + version1PathLength*2
+		if len(b) < length {
+			return Info{}, fmt.Errorf("expected at least %d bytes for version %d but got %d", length, info.Version, len(b))
+		}
+		path = r.Read(0x18, version1PathLength*2)
+	case Version2:
+		if len(b) < 0x1C {
+			return Info{}, fmt.Errorf("expected at least %d bytes for version %d but got %d", 0x1C, info.Version, len(b))
+		}
+		characters := int(r.Uint32(0x18))
+		if length := 0x1C + characters*2; len(b) < length {
+			return Info{}, fmt.Errorf("expected at least %d bytes for path of %d characters but got %d", length, characters, len(b))
+		}
+		path = r.Read(0x1C, characters*2)
+	default:
+		return Info{}, fmt.Errorf("unknown version %d", info.Version)
+	}
+	info.Path = utf16.DecodeString(trimNul(path), binary.LittleEndian)
+	return info, nil
+}
+
+// trimNul returns the UTF-16 data up to the first NUL character.
+func trimNul(b []byte) []byte {
+	for i := 0; i+1 < len(b); i += 2 {
+		if b[i] == 0 && b[i+1] == 0 {
+			return b[:i]
+		}
+	}
+	return b
+}
+
+// DataName returns the name of the $R file belonging to the $I file with the name, or an empty string when the name
+// is not that of a $I file.
+func DataName(infoName string) string {
+	if !isRecycled(infoName, InfoPrefix) {
+		return ""
+	}
+	return DataPrefix + infoName[len(InfoPrefix):]
+}
+
+// InfoName returns the name of the $I file belonging to the $R file with the name, or an empty string when the name is
+// not that of a $R file.
+func InfoName(dataName string) string {
+	if !isRecycled(dataName, DataPrefix) {
+		return ""
+	}
+	return InfoPrefix + dataName[len(DataPrefix):]
+}
+
+func isRecycled(name string, prefix string) bool {
+	return len(name) > len(prefix) && strings.EqualFold(name[:len(prefix)], prefix)
+}
+
+// Pair is a $I file and the $R file with the same suffix. Either name is empty when only the other file was found.
+type Pair struct {
+	InfoName string
+	DataName string
+}
+
+// Pairs pairs the names of the $I and $R files among the names of the files in a directory. Other names are ignored.
+// Names are compared case insensitively, and the pairs are ordered by the suffix of their names.
+func Pairs(names []string) []Pair {
+	bySuffix := make(map[string]*Pair)
+	suffixes := make([]string, 0)
+	pair := func(name string) *Pair {
+		suffix := strings.ToUpper(name[len(InfoPrefix):])
+		p, ok := bySuffix[suffix]
+		if !ok {
+			p = &Pair{}
+			bySuffix[suffix] = p
+			suffixes = append(suffixes, suffix)
+		}
+		return p
+	}
+	for _, name := range names {
+		if InfoName(name) != "" {
+			pair(name).DataName = name
+		} else if DataName(name) != "" {
+			pair(name).InfoName = name
+		}
+	}
+
+	sort.Strings(suffixes)
+	pairs := make([]Pair, len(suffixes))
+	for i, suffix := range suffixes {
+		pairs[i] = *bySuffix[suffix]
+	}
+	return pairs
+}
+
+// Item is a file in the Recycle Bin, as read by ReadDir. The DataName is empty when the $R file was not found, for
+// example because the Recycle Bin was emptied while the $I file could not be removed.
+type Item struct {
+	InfoName string
+	DataName string
+	Info     Info
+}
+
+// ReadDir reads and parses the $I files in the directory, such as a $Recycle.Bin\<SID> directory that was extracted
+// from a volume, and pairs each with its $R file. The items are ordered by the names of the $I files; $R files
+// without $I file are left out. An error is returned when the directory cannot be read, or a $I file cannot be read
+// or parsed.
+func ReadDir(dir string) ([]Item, error) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	names, err := f.Readdirnames(-1)
+	f.Close()
+	if err != nil {
+		return nil, fmt.Errorf("unable to list %s: %v", dir, err)
+	}
+
+	items := make([]Item, 0)
+	for _, p := range Pairs(names) {
+		if p.InfoName == "" {
+			continue
+		}
+		b, err := ioutil.ReadFile(filepath.Join(dir, p.InfoName))
+		if err != nil {
+			return nil, err
+		}
+		info, err := Parse(b)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse %s: %v", p.InfoName, err)
+		}
+		items = append(items, Item{InfoName: p.InfoName, DataName: p.DataName, Info: info})
+	}
+	return items, nil
+}
@@ -0,0 +1,146 @@
+package recyclebin_test
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/recyclebin"
+)
+
+const deletedFileTime = 132223104000000000 // 2020-01-01 00:00:00 UTC
+
+func encodePath(path string) []byte {
+	chars := utf16.Encode([]rune(path))
+	b := make([]byte, len(chars)*2)
+	for i, c := range chars {
+		binary.LittleEndian.PutUint16(b[i*2:], c)
+	}
+	return b
+}
+
+func header(version uint64, size uint64) []byte {
+	b := make([]byte, 0x18)
+	binary.LittleEndian.PutUint64(b[0x00:], version)
+	binary.LittleEndian.PutUint64(b[0x08:], size)
+	binary.LittleEndian.PutUint64(b[0x10:], deletedFileTime)
+	return b
+}
+
+func version1(size uint64, path string) []byte {
+	b := append(header(recyclebin.Version1, size), make([]byte, 520)...)
+	copy(b[0x18:], encodePath(path))
+	return b
+}
+
+func version2(size uint64, path string) []byte {
+	b := append(header(recyclebin.Version2, size), 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(b[0x18:], uint32(len([]rune(path))+1))
+	b = append(b, encodePath(path)...)
+	return append(b, 0, 0)
+}
+
+func TestParseVersion1(t *testing.T) {
+	info, err := recyclebin.Parse(version1(1234, `C:\Users\bob\Documents\report.docx`))
+	require.Nilf(t, err, "error parsing: %v", err)
+	expected := recyclebin.Info{
+		Version: recyclebin.Version1,
+		Size:    1234,
+		Deleted: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		Path:    `C:\Users\bob\Documents\report.docx`,
+	}
+	assert.Equal(t, expected, info)
+}
+
+func TestParseVersion2(t *testing.T) {
+	info, err := recyclebin.Parse(version2(42, `D:\Fotos\ëxample.jpg`))
+	require.Nilf(t, err, "error parsing: %v", err)
+	expected := recyclebin.Info{
+		Version: recyclebin.Version2,
+		Size:    42,
+		Deleted: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		Path:    `D:\Fotos\ëxample.jpg`,
+	}
+	assert.Equal(t, expected, info)
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"too short", make([]byte, 0x10)},
+		{"unknown version", header(3, 0)},
+		{"version 1 truncated", version1(0, `C:\a`)[:0x100]},
+		{"version 2 without length", header(recyclebin.Version2, 0)},
+		{"version 2 truncated", version2(0, `C:\a`)[:0x1E]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := recyclebin.Parse(tt.data)
+			assert.NotNil(t, err)
+		})
+	}
+}
+
+func TestNames(t *testing.T) {
+	assert.Equal(t, "$RA1B2C3.txt", recyclebin.DataName("$IA1B2C3.txt"))
+	assert.Equal(t, "$RA1B2C3", recyclebin.DataName("$iA1B2C3"))
+	assert.Equal(t, "", recyclebin.DataName("$RA1B2C3.txt"))
+	assert.Equal(t, "", recyclebin.DataName("$I"))
+	assert.Equal(t, "$IA1B2C3.txt", recyclebin.InfoName("$RA1B2C3.txt"))
+	assert.Equal(t, "", recyclebin.InfoName("desktop.ini"))
+}
+
+func TestPairs(t *testing.T) {
+	pairs := recyclebin.Pairs([]string{"desktop.ini", "$RBBBBBB.doc", "$IAAAAAA.txt", "$Iccccc", "$raaaaaa.TXT"})
+	expected := []recyclebin.Pair{
+		{InfoName: "$IAAAAAA.txt", DataName: "$raaaaaa.TXT"},
+		{InfoName: "", DataName: "$RBBBBBB.doc"},
+		{InfoName: "$Iccccc", DataName: ""},
+	}
+	assert.Equal(t, expected, pairs)
+}
+
+func TestReadDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "recyclebin")
+	require.Nilf(t, err, "error creating directory: %v", err)
+	defer os.RemoveAll(dir)
+	files := map[string][]byte{
+		"$IAAAAAA.txt": version2(3, `C:\a.txt`),
+		"$RAAAAAA.txt": []byte("abc"),
+		"$IBBBBBB":     version1(0, `C:\b`),
+		"$RCCCCCC":     []byte("orphan"),
+		"desktop.ini":  []byte("[.ShellClassInfo]"),
+	}
+	for name, data := range files {
+		require.Nil(t, ioutil.WriteFile(filepath.Join(dir, name), data, 0600))
+	}
+
+	items, err := recyclebin.ReadDir(dir)
+	require.Nilf(t, err, "error reading directory: %v", err)
+	require.Len(t, items, 2)
+	assert.Equal(t, "$IAAAAAA.txt", items[0].InfoName)
+	assert.Equal(t, "$RAAAAAA.txt", items[0].DataName)
+	assert.Equal(t, `C:\a.txt`, items[0].Info.Path)
+	assert.Equal(t, uint64(3), items[0].Info.Size)
+	assert.Equal(t, "$IBBBBBB", items[1].InfoName)
+	assert.Equal(t, "", items[1].DataName)
+	assert.Equal(t, `C:\b`, items[1].Info.Path)
+}
+
+func TestReadDirInvalidInfo(t *testing.T) {
+	dir, err := ioutil.TempDir("", "recyclebin")
+	require.Nilf(t, err, "error creating directory: %v", err)
+	defer os.RemoveAll(dir)
+	require.Nil(t, ioutil.WriteFile(filepath.Join(dir, "$IAAAAAA"), []byte("garbage"), 0600))
+
+	_, err = recyclebin.ReadDir(dir)
+	assert.NotNil(t, err)
+}
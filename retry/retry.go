@@ -0,0 +1,196 @@
+/*
+	Package retry contains a ReaderAt which retries failed reads of an underlying io.ReaderAt, for sources which
+	exhibit transient errors, such as disks behind USB bridges and network block devices.
+
+	Basic usage
+
+	Wrap the volume (or any other io.ReaderAt) and read from the ReaderAt instead.
+			// Error handling left out for brevity
+			f, err := os.Open("/dev/sdb1")
+			r := retry.NewReaderAt(f, retry.Options{Attempts: 5, Timeout: 10 * time.Second})
+			v, err := volume.Open(r, volume.Options{})
+
+	Implementation notes
+
+	A failed read is retried after a delay which starts at InitialDelay and is multiplied by Multiplier after each
+	failure, up to MaxDelay. When a read fails after returning part of the data, only the remainder is retried, and the
+	failures are counted from the start again. io.EOF is never retried, since reading beyond the end of the source will
+	not succeed later.
+
+	An io.ReaderAt cannot be interrupted, so when a Timeout is set, each read runs in a goroutine of its own reading
+	into a separate buffer. When it takes too long, the read is abandoned and counts as a failure; the goroutine keeps
+	waiting for the underlying read to return, but its result is discarded. Many reads which hang forever therefore
+	leave as many goroutines behind.
+
+	A ReaderAt is safe for concurrent use when the underlying io.ReaderAt is; each read is retried on its own.
+*/
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ErrTimeout is returned (wrapped in a *ReadError) when a read does not complete within the Timeout.
+var ErrTimeout = errors.New("read timed out")
+
+// Default values of the Options.
+const (
+	DefaultAttempts     = 5
+	DefaultInitialDelay = 100 * time.Millisecond
+	DefaultMaxDelay     = 10 * time.Second
+	DefaultMultiplier   = 2
+)
+
+// Options configures a ReaderAt. Zero values are replaced by their defaults.
+type Options struct {
+	// Attempts is the number of times a read is tried before giving up, including the first attempt.
+	Attempts int
+	// InitialDelay is the delay before the first retry.
+	InitialDelay time.Duration
+	// MaxDelay is the maximum delay between retries.
+	MaxDelay time.Duration
+	// Multiplier is the factor by which the delay grows after each retry.
+	Multiplier float64
+	// Timeout is the maximum duration of a single read of the underlying io.ReaderAt. When zero, reads do not time out.
+	Timeout time.Duration
+	// Retryable decides if a read which failed with the error should be retried. When nil, all errors except io.EOF
+	// are retried.
+	Retryable func(err error) bool
+	// OnRetry, when not nil, is called before each retry, with the attempt which failed (starting at 1) and its error.
+	OnRetry func(off int64, attempt int, err error)
+}
+
+// ReadError is returned by a ReaderAt when a read still fails after all attempts, or fails with an error which is not
+// retryable.
+type ReadError struct {
+	// Offset is the position of the read which failed.
+	Offset int64
+	// Attempts is the number of attempts made.
+	Attempts int
+	// Err is the error of the last attempt.
+	Err error
+}
+
+func (e *ReadError) Error() string {
+	return fmt.Sprintf("unable to read at offset %d after %d attempts: %v", e.Offset, e.Attempts, e.Err)
+}
+
+// ReaderAt retries failed reads of an underlying io.ReaderAt.
+type ReaderAt struct {
+	src  io.ReaderAt
+	opts Options
+}
+
+// NewReaderAt creates a ReaderAt reading from src.
+func NewReaderAt(src io.ReaderAt, opts Options) *ReaderAt {
+	if opts.Attempts <= 0 {
+		opts.Attempts = DefaultAttempts
+	}
+	if opts.InitialDelay <= 0 {
+		opts.InitialDelay = DefaultInitialDelay
+	}
+	if opts.MaxDelay <= 0 {
+		opts.MaxDelay = DefaultMaxDelay
+	}
+	if opts.Multiplier < 1 {
+		opts.Multiplier = DefaultMultiplier
+	}
+	if opts.Retryable == nil {
+		opts.Retryable = func(err error) bool { return err != io.EOF }
+	}
+	return &ReaderAt{src: src, opts: opts}
+}
+
+// ReadAt reads len(p) bytes starting at position off, retrying failed reads as configured by the Options. When fewer
+// than len(p) bytes are available, it returns the number of bytes read and io.EOF.
+func (r *ReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	return r.ReadAtContext(context.Background(), p, off)
+}
+
+// ReadAtContext is like ReadAt, but stops retrying (and waiting for a read which is in progress) when the context is
+// done, in which case the error of the context is returned.
+func (r *ReaderAt) ReadAtContext(ctx context.Context, p []byte, off int64) (int, error) {
+	total := 0
+	attempt := 0
+	delay := r.opts.InitialDelay
+	for total < len(p) {
+		attempt++
+		n, err := r.read(ctx, p[total:], off+int64(total))
+		total += n
+		if err == nil {
+			if n == 0 {
+				// A reader which returns nothing without an error would otherwise be read forever
+				err = io.ErrNoProgress
+			} else {
+				continue
+			}
+		}
+		if err == io.EOF {
+			return total, err
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return total, ctxErr
+		}
+		if n > 0 {
+			attempt = 1
+			delay = r.opts.InitialDelay
+		}
+		if attempt >= r.opts.Attempts || !r.opts.Retryable(err) {
+			return total, &ReadError{Offset: off + int64(total), Attempts: attempt, Err: err}
+		}
+		if r.opts.OnRetry != nil {
+			r.opts.OnRetry(off+int64(total), attempt, err)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return total, ctx.Err()
+		}
+		delay = time.Duration(float64(delay) * r.opts.Multiplier)
+		if delay > r.opts.MaxDelay {
+			delay = r.opts.MaxDelay
+		}
+	}
+	return total, nil
+}
+
+// read does a single read of the underlying io.ReaderAt, which is abandoned when it takes longer than the Timeout or
+// when the context is done.
+func (r *ReaderAt) read(ctx context.Context, p []byte, off int64) (int, error) {
+	if r.opts.Timeout <= 0 && ctx.Done() == nil {
+		return r.src.ReadAt(p, off)
+	}
+
+	type result struct {
+		n   int
+		err error
+	}
+	buf := make([]byte, len(p))
+	done := make(chan result, 1)
+	go func() {
+		n, err := r.src.ReadAt(buf, off)
+		done <- result{n, err}
+	}()
+
+	var timeout <-chan time.Time
+	if r.opts.Timeout > 0 {
+		timer := time.NewTimer(r.opts.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case res := <-done:
+		return copy(p, buf[:res.n]), res.err
+	case <-timeout:
+		return 0, ErrTimeout
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
@@ -0,0 +1,150 @@
+package retry_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/retry"
+)
+
+var errFlaky = errors.New("device not ready")
+
+// flakyReaderAt fails the first failures reads, and returns at most limit bytes per read when limit is positive.
+type flakyReaderAt struct {
+	mu       sync.Mutex
+	data     []byte
+	failures int
+	limit    int
+	calls    int
+}
+
+func (r *flakyReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	r.mu.Lock()
+	r.calls++
+	fail := r.calls <= r.failures
+	r.mu.Unlock()
+	if fail {
+		return 0, errFlaky
+	}
+	if r.limit > 0 && len(p) > r.limit {
+		n, _ := bytes.NewReader(r.data).ReadAt(p[:r.limit], off)
+		return n, errFlaky
+	}
+	return bytes.NewReader(r.data).ReadAt(p, off)
+}
+
+func fast(opts retry.Options) retry.Options {
+	opts.InitialDelay = time.Millisecond
+	opts.MaxDelay = 2 * time.Millisecond
+	return opts
+}
+
+func TestReaderAt_Retries(t *testing.T) {
+	src := &flakyReaderAt{data: []byte("0123456789"), failures: 2}
+	retries := 0
+	r := retry.NewReaderAt(src, fast(retry.Options{Attempts: 3, OnRetry: func(off int64, attempt int, err error) {
+		retries++
+		assert.Equal(t, int64(2), off)
+		assert.Equal(t, retries, attempt)
+		assert.Equal(t, errFlaky, err)
+	}}))
+	buf := make([]byte, 4)
+	n, err := r.ReadAt(buf, 2)
+	require.Nil(t, err)
+	assert.Equal(t, 4, n)
+	assert.Equal(t, "2345", string(buf))
+	assert.Equal(t, 3, src.calls)
+	assert.Equal(t, 2, retries)
+}
+
+func TestReaderAt_GivesUp(t *testing.T) {
+	src := &flakyReaderAt{data: []byte("0123456789"), failures: 5}
+	r := retry.NewReaderAt(src, fast(retry.Options{Attempts: 3}))
+	_, err := r.ReadAt(make([]byte, 4), 2)
+	assert.EqualError(t, err, "unable to read at offset 2 after 3 attempts: device not ready")
+	readErr, ok := err.(*retry.ReadError)
+	require.True(t, ok)
+	assert.Equal(t, errFlaky, readErr.Err)
+	assert.Equal(t, 3, src.calls)
+}
+
+func TestReaderAt_NotRetryable(t *testing.T) {
+	src := &flakyReaderAt{data: []byte("0123456789"), failures: 1}
+	r := retry.NewReaderAt(src, fast(retry.Options{Retryable: func(err error) bool { return err != errFlaky }}))
+	_, err := r.ReadAt(make([]byte, 4), 0)
+	assert.EqualError(t, err, "unable to read at offset 0 after 1 attempts: device not ready")
+	assert.Equal(t, 1, src.calls)
+}
+
+func TestReaderAt_PartialReads(t *testing.T) {
+	// Each read returns 3 bytes and an error; progress resets the attempts, so 2 attempts are enough
+	src := &flakyReaderAt{data: []byte("0123456789"), limit: 3}
+	r := retry.NewReaderAt(src, fast(retry.Options{Attempts: 2}))
+	buf := make([]byte, 8)
+	n, err := r.ReadAt(buf, 1)
+	require.Nil(t, err)
+	assert.Equal(t, 8, n)
+	assert.Equal(t, "12345678", string(buf))
+}
+
+func TestReaderAt_EOF(t *testing.T) {
+	src := &flakyReaderAt{data: []byte("0123456789")}
+	r := retry.NewReaderAt(src, fast(retry.Options{}))
+	buf := make([]byte, 4)
+	n, err := r.ReadAt(buf, 8)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, "89", string(buf[:n]))
+	assert.Equal(t, 1, src.calls)
+}
+
+// blockingReaderAt blocks the first read until release is closed.
+type blockingReaderAt struct {
+	once    sync.Once
+	release chan struct{}
+}
+
+func (r *blockingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	blocked := false
+	r.once.Do(func() { blocked = true })
+	if blocked {
+		<-r.release
+	}
+	for i := range p {
+		p[i] = 'x'
+	}
+	return len(p), nil
+}
+
+func TestReaderAt_Timeout(t *testing.T) {
+	src := &blockingReaderAt{release: make(chan struct{})}
+	defer close(src.release)
+	var retried error
+	r := retry.NewReaderAt(src, fast(retry.Options{Timeout: 10 * time.Millisecond, OnRetry: func(off int64, attempt int, err error) {
+		retried = err
+	}}))
+	buf := make([]byte, 4)
+	n, err := r.ReadAt(buf, 0)
+	require.Nil(t, err)
+	assert.Equal(t, 4, n)
+	assert.Equal(t, "xxxx", string(buf))
+	assert.Equal(t, retry.ErrTimeout, retried)
+}
+
+func TestReaderAt_Context(t *testing.T) {
+	src := &flakyReaderAt{data: []byte("0123456789"), failures: 100}
+	ctx, cancel := context.WithCancel(context.Background())
+	r := retry.NewReaderAt(src, retry.Options{Attempts: 100, InitialDelay: time.Hour, OnRetry: func(off int64, attempt int, err error) {
+		cancel()
+	}})
+	_, err := r.ReadAtContext(ctx, make([]byte, 4), 0)
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 1, src.calls)
+}
@@ -0,0 +1,246 @@
+/*
+	Package scan feeds the contents of files through user supplied scanners, such as compiled YARA rules, while the
+	records of an MFT stream through a pipeline. This turns a walk over the MFT into a triage scan, reporting each match
+	with the record number and path of the file.
+
+	Basic usage
+
+	Wrap the scanner in a Scanner and add the Stage to a pipeline, after the Paths stage to report full paths. For
+	example, using the go-yara bindings:
+			// Error handling left out for brevity
+			scanner := scan.ScannerFunc(func(data []byte) ([]string, error) {
+				var matches yara.MatchRules
+				err := rules.ScanMem(data, 0, 10*time.Second, &matches)
+				names := make([]string, len(matches))
+				for i, m := range matches {
+					names[i] = m.Rule
+				}
+				return names, err
+			})
+			opts := scan.Options{Volume: volume, BytesPerCluster: 4096}
+			stats, err := pipeline.Run(in, pipeline.Options{},
+				pipeline.Paths(pipeline.NewPathResolver(in, 1024, 0)),
+				scan.Stage(scanner, opts, func(m scan.Match) error {
+					fmt.Println(m.Path, m.Stream, m.Rules)
+					return nil
+				}))
+
+	Implementation notes
+
+	Each $DATA attribute of a record (the unnamed stream as well as alternate data streams) is scanned separately.
+	Resident data is scanned straight from the record; non-resident data is read from the Volume using the data runs of
+	the attribute (see volume.NewAttributeReader), so without a Volume only resident data is scanned. Sparse streams
+	read as zeroes and compressed streams are decompressed. Only the attributes in the record itself are considered,
+	not those in extension records.
+
+	Streams are read into memory in full before scanning them, because scanners such as YARA need all data at once.
+	Streams larger than the MaxSize of the Options are not scanned. Errors reading or scanning a stream are passed to
+	the ErrorHandler and do not stop the pipeline.
+
+	ContentTypes is a Stage of its own, which reads only the first bytes of each non-resident stream to detect its type
+	(see the magic package), so it is cheap enough to run over all records. Add it before stages that use the
+	ContentType of the Entry, such as a pipeline.Filter.
+
+	Entropy is another Stage of its own, which computes the entropy of each stream (see the entropy package) to
+	highlight encrypted, compressed or packed content. Only some blocks of large streams are read, as configured by the
+	entropy.Options, so like ContentTypes it can be run over all records.
+
+	Records which are not in use are scanned too, since the resident data of deleted files often survives in the MFT.
+	Be aware that the clusters of deleted files may have been reused, so matches in their non-resident data may belong
+	to other files. Use a pipeline.Filter stage before the Stage to skip such records.
+*/
+package scan
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/t9t/gomft/entropy"
+	"github.com/t9t/gomft/magic"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/pipeline"
+	"github.com/t9t/gomft/volume"
+)
+
+// DefaultMaxSize is the size of the largest stream that is scanned when the Options do not specify a MaxSize.
+const DefaultMaxSize = 64 * 1024 * 1024
+
+// A Scanner scans the contents of a stream and returns the names of the rules that matched, if any.
+type Scanner interface {
+	Scan(data []byte) ([]string, error)
+}
+
+// ScannerFunc is an adapter to use a function as a Scanner.
+type ScannerFunc func(data []byte) ([]string, error)
+
+// Scan calls f(data).
+func (f ScannerFunc) Scan(data []byte) ([]string, error) {
+	return f(data)
+}
+
+// Match describes a stream that matched one or more rules.
+type Match struct {
+	RecordNumber   uint64
+	SequenceNumber uint16
+	Path           string   // the Path of the pipeline.Item, empty unless set by a previous stage
+	Stream         string   // the name of the $DATA attribute, empty for the unnamed stream
+	Size           int64    // the size of the stream in bytes
+	Rules          []string // the names of the matching rules, as returned by the Scanner
+}
+
+// Options configures the streams scanned by a Stage.
+type Options struct {
+	// Volume, when not nil, is the volume which the MFT belongs to. It is needed to scan non-resident data.
+	Volume io.ReaderAt
+	// BytesPerCluster is the cluster size of the Volume.
+	BytesPerCluster int
+	// MaxSize is the size in bytes of the largest stream to scan. When zero, DefaultMaxSize is used.
+	MaxSize int64
+	// ErrorHandler, when not nil, is called for each stream that could not be read or scanned, including streams that
+	// are larger than MaxSize or that are non-resident while there is no Volume.
+	ErrorHandler func(item *pipeline.Item, stream string, err error)
+}
+
+// Stage creates a pipeline.Stage that scans each $DATA attribute of each Item using the scanner, and calls report for
+// each stream matching one or more rules. It never drops Items; an error returned by report stops the pipeline.
+func Stage(scanner Scanner, opts Options, report func(Match) error) pipeline.Stage {
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = DefaultMaxSize
+	}
+	return pipeline.StageFunc(func(item *pipeline.Item) (bool, error) {
+		for _, a := range item.Record.FindAttributes(mft.AttributeTypeData) {
+			size, rules, err := scanStream(scanner, opts, a)
+			if err != nil {
+				if opts.ErrorHandler != nil {
+					opts.ErrorHandler(item, a.Name, err)
+				}
+				continue
+			}
+			if len(rules) == 0 {
+				continue
+			}
+			match := Match{
+				RecordNumber:   item.Record.FileReference.RecordNumber,
+				SequenceNumber: item.Record.FileReference.SequenceNumber,
+				Path:           item.Path,
+				Stream:         a.Name,
+				Size:           size,
+				Rules:          rules,
+			}
+			if err := report(match); err != nil {
+				return false, fmt.Errorf("unable to report match: %v", err)
+			}
+		}
+		return true, nil
+	})
+}
+
+// ContentTypes creates a pipeline.Stage that sets the ContentType of the Entry of each Item, and of its
+// AlternateDataStreams, for non-resident data: only the first bytes of each stream are read from the Volume and passed
+// to magic.Detect. The ContentType of resident data is already set by export.FromRecord. The MaxSize of the Options is
+// not used. It never drops Items; streams that cannot be read are passed to the ErrorHandler.
+func ContentTypes(opts Options) pipeline.Stage {
+	return pipeline.StageFunc(func(item *pipeline.Item) (bool, error) {
+		haveData := false
+		for _, a := range item.Record.FindAttributes(mft.AttributeTypeData) {
+			if a.Name == "" {
+				if haveData {
+					continue
+				}
+				haveData = true
+			}
+			if a.Resident {
+				continue
+			}
+			contentType, err := detectStream(opts, a)
+			if err != nil {
+				if opts.ErrorHandler != nil {
+					opts.ErrorHandler(item, a.Name, err)
+				}
+				continue
+			}
+			if a.Name == "" {
+				item.Entry.ContentType = contentType
+				continue
+			}
+			for i := range item.Entry.AlternateDataStreams {
+				if item.Entry.AlternateDataStreams[i].Name == a.Name {
+					item.Entry.AlternateDataStreams[i].ContentType = contentType
+				}
+			}
+		}
+		return true, nil
+	})
+}
+
+// Entropy creates a pipeline.Stage that sets the Entropy (and HasEntropy) of the Entry of each Item, and of its
+// AlternateDataStreams, using entropy.Sample with the sampling options. Both resident and non-resident streams are
+// read; empty streams are skipped. The MaxSize of the Options is not used. It never drops Items; streams that cannot
+// be read are passed to the ErrorHandler.
+func Entropy(opts Options, sampling entropy.Options) pipeline.Stage {
+	return pipeline.StageFunc(func(item *pipeline.Item) (bool, error) {
+		haveData := false
+		for _, a := range item.Record.FindAttributes(mft.AttributeTypeData) {
+			if a.Name == "" {
+				if haveData {
+					continue
+				}
+				haveData = true
+			}
+			size := uint64(len(a.Data))
+			if !a.Resident {
+				size = a.ActualSize
+			}
+			if size == 0 {
+				continue
+			}
+			r, err := volume.NewAttributeReader(opts.Volume, opts.BytesPerCluster, a)
+			if err == nil {
+				var e float64
+				if e, err = entropy.Sample(r, int64(size), sampling); err == nil {
+					item.Entry.SetEntropy(a.Name, e)
+				}
+			}
+			if err != nil && opts.ErrorHandler != nil {
+				opts.ErrorHandler(item, a.Name, err)
+			}
+		}
+		return true, nil
+	})
+}
+
+func detectStream(opts Options, a mft.Attribute) (string, error) {
+	r, err := volume.NewAttributeReader(opts.Volume, opts.BytesPerCluster, a)
+	if err != nil {
+		return "", err
+	}
+	header, err := magic.ReadHeader(r, int64(a.ActualSize))
+	if err != nil {
+		return "", err
+	}
+	t, _ := magic.Detect(header)
+	return t.MIME, nil
+}
+
+func scanStream(scanner Scanner, opts Options, a mft.Attribute) (int64, []string, error) {
+	size := uint64(len(a.Data))
+	if !a.Resident {
+		size = a.ActualSize
+	}
+	if size > uint64(opts.MaxSize) {
+		return 0, nil, fmt.Errorf("stream size %d exceeds maximum of %d", size, opts.MaxSize)
+	}
+	r, err := volume.NewAttributeReader(opts.Volume, opts.BytesPerCluster, a)
+	if err != nil {
+		return 0, nil, err
+	}
+	data := make([]byte, size)
+	if n, err := r.ReadAt(data, 0); n < len(data) {
+		return 0, nil, fmt.Errorf("unable to read stream: %v", err)
+	}
+	rules, err := scanner.Scan(data)
+	if err != nil {
+		return 0, nil, fmt.Errorf("unable to scan stream: %v", err)
+	}
+	return int64(size), rules, nil
+}
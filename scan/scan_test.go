@@ -0,0 +1,159 @@
+package scan_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/entropy"
+	"github.com/t9t/gomft/export"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/pipeline"
+	"github.com/t9t/gomft/scan"
+)
+
+// containsScanner reports rule "evil" for data containing "evil".
+var containsScanner = scan.ScannerFunc(func(data []byte) ([]string, error) {
+	if bytes.Contains(data, []byte("evil")) {
+		return []string{"evil"}, nil
+	}
+	return nil, nil
+})
+
+func TestStage(t *testing.T) {
+	volume := make([]byte, 4*512)
+	copy(volume[2*512:], "some evil payload")
+	item := &pipeline.Item{
+		Path: "/Users/bob/payload.exe",
+		Record: mft.Record{
+			FileReference: mft.FileReference{RecordNumber: 42, SequenceNumber: 3},
+			Attributes: []mft.Attribute{
+				{Type: mft.AttributeTypeFileName, Resident: true, Data: []byte("evil")},
+				{Type: mft.AttributeTypeData, Resident: false, Data: []byte{0x11, 0x01, 0x02, 0x00}, ActualSize: 17},
+				{Type: mft.AttributeTypeData, Name: "Zone.Identifier", Resident: true, Data: []byte("[ZoneTransfer]")},
+				{Type: mft.AttributeTypeData, Name: "hidden", Resident: true, Data: []byte("evil too")},
+			},
+		},
+	}
+
+	var matches []scan.Match
+	stage := scan.Stage(containsScanner, scan.Options{Volume: bytes.NewReader(volume), BytesPerCluster: 512}, func(m scan.Match) error {
+		matches = append(matches, m)
+		return nil
+	})
+	keep, err := stage.Process(item)
+	require.Nil(t, err)
+	assert.True(t, keep)
+	assert.Equal(t, []scan.Match{
+		{RecordNumber: 42, SequenceNumber: 3, Path: "/Users/bob/payload.exe", Size: 17, Rules: []string{"evil"}},
+		{RecordNumber: 42, SequenceNumber: 3, Path: "/Users/bob/payload.exe", Stream: "hidden", Size: 8, Rules: []string{"evil"}},
+	}, matches)
+}
+
+func TestStage_Errors(t *testing.T) {
+	item := &pipeline.Item{Record: mft.Record{Attributes: []mft.Attribute{
+		{Type: mft.AttributeTypeData, Resident: false, Data: []byte{0x11, 0x01, 0x02, 0x00}, ActualSize: 8},
+		{Type: mft.AttributeTypeData, Name: "big", Resident: true, Data: make([]byte, 11)},
+		{Type: mft.AttributeTypeData, Name: "fail", Resident: true, Data: []byte("fail")},
+		{Type: mft.AttributeTypeData, Name: "empty", Resident: true},
+	}}}
+	scanner := scan.ScannerFunc(func(data []byte) ([]string, error) {
+		if string(data) == "fail" {
+			return nil, errors.New("scanner failed")
+		}
+		return []string{"any"}, nil
+	})
+
+	var errs []string
+	var matches []scan.Match
+	opts := scan.Options{MaxSize: 10, ErrorHandler: func(item *pipeline.Item, stream string, err error) {
+		errs = append(errs, stream+": "+err.Error())
+	}}
+	keep, err := scan.Stage(scanner, opts, func(m scan.Match) error {
+		matches = append(matches, m)
+		return nil
+	}).Process(item)
+	require.Nil(t, err)
+	assert.True(t, keep)
+	assert.Equal(t, []string{
+		": the attribute is non-resident, but no volume is available",
+		"big: stream size 11 exceeds maximum of 10",
+		"fail: unable to scan stream: scanner failed",
+	}, errs)
+	require.Len(t, matches, 1)
+	assert.Equal(t, "empty", matches[0].Stream)
+}
+
+func TestStage_ReportError(t *testing.T) {
+	item := &pipeline.Item{Record: mft.Record{Attributes: []mft.Attribute{
+		{Type: mft.AttributeTypeData, Resident: true, Data: []byte("evil")},
+	}}}
+	_, err := scan.Stage(containsScanner, scan.Options{}, func(m scan.Match) error {
+		return errors.New("disk full")
+	}).Process(item)
+	assert.EqualError(t, err, "unable to report match: disk full")
+}
+
+func TestContentTypes(t *testing.T) {
+	volume := make([]byte, 4*512)
+	copy(volume[2*512:], "%PDF-1.7")
+	copy(volume[3*512:], "MZ\x90\x00")
+	r := mft.Record{Attributes: []mft.Attribute{
+		{Type: mft.AttributeTypeData, Resident: false, Data: []byte{0x11, 0x01, 0x02, 0x00}, ActualSize: 100},
+		{Type: mft.AttributeTypeData, Name: "payload", Resident: false, Data: []byte{0x11, 0x01, 0x03, 0x00}, ActualSize: 4},
+		{Type: mft.AttributeTypeData, Name: "note", Resident: true, Data: []byte("GIF89a")},
+		// A sparse cluster, which reads as zeroes
+		{Type: mft.AttributeTypeData, Name: "sparse", Resident: false, Flags: mft.AttributeFlagsSparse, Data: []byte{0x01, 0x01, 0x00}, ActualSize: 10},
+	}}
+	item := &pipeline.Item{Record: r, Entry: export.FromRecord(r)}
+
+	var errs []string
+	opts := scan.Options{Volume: bytes.NewReader(volume), BytesPerCluster: 512,
+		ErrorHandler: func(item *pipeline.Item, stream string, err error) {
+			errs = append(errs, stream+": "+err.Error())
+		}}
+	keep, err := scan.ContentTypes(opts).Process(item)
+	require.Nil(t, err)
+	assert.True(t, keep)
+	assert.Equal(t, "application/pdf", item.Entry.ContentType)
+	assert.Equal(t, []export.Stream{
+		{Name: "payload", Size: 4, ContentType: "application/vnd.microsoft.portable-executable"},
+		{Name: "note", Size: 6, ContentType: "image/gif"},
+		{Name: "sparse", Size: 10},
+	}, item.Entry.AlternateDataStreams)
+	assert.Empty(t, errs)
+}
+
+func TestEntropy(t *testing.T) {
+	volume := make([]byte, 3*512)
+	for i := 0; i < 512; i++ {
+		volume[2*512+i] = byte(i)
+	}
+	r := mft.Record{Attributes: []mft.Attribute{
+		{Type: mft.AttributeTypeData, Resident: false, Data: []byte{0x11, 0x01, 0x02, 0x00}, ActualSize: 512},
+		{Type: mft.AttributeTypeData, Name: "text", Resident: true, Data: []byte("abab")},
+		{Type: mft.AttributeTypeData, Name: "empty", Resident: true},
+		// A sparse cluster, which reads as zeroes
+		{Type: mft.AttributeTypeData, Name: "sparse", Resident: false, Flags: mft.AttributeFlagsSparse, Data: []byte{0x01, 0x01, 0x00}, ActualSize: 10},
+	}}
+	item := &pipeline.Item{Record: r, Entry: export.FromRecord(r)}
+
+	var errs []string
+	opts := scan.Options{Volume: bytes.NewReader(volume), BytesPerCluster: 512,
+		ErrorHandler: func(item *pipeline.Item, stream string, err error) {
+			errs = append(errs, stream+": "+err.Error())
+		}}
+	keep, err := scan.Entropy(opts, entropy.Options{}).Process(item)
+	require.Nil(t, err)
+	assert.True(t, keep)
+	assert.True(t, item.Entry.HasEntropy)
+	assert.Equal(t, 8.0, item.Entry.Entropy)
+	assert.Equal(t, []export.Stream{
+		{Name: "text", Size: 4, Entropy: 1, HasEntropy: true},
+		{Name: "empty", Size: 0},
+		{Name: "sparse", Size: 10, Entropy: 0, HasEntropy: true},
+	}, item.Entry.AlternateDataStreams)
+	assert.Empty(t, errs)
+}
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/t9t/gomft/mftpb"
+)
+
+// maxRequestSize is the maximum size of a request message. All requests of the MFTService are tiny, so anything
+// larger is rejected.
+const maxRequestSize = 64 * 1024
+
+// ServeHTTP handles gRPC calls of the MFTService. It expects HTTP/2 requests as sent by gRPC clients; other requests
+// are rejected with an HTTP error.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ProtoMajor != 2 {
+		http.Error(w, "gRPC requires HTTP/2 POST requests", http.StatusHTTPVersionNotSupported)
+		return
+	}
+	if ct := r.Header.Get("Content-Type"); ct != "application/grpc" && !strings.HasPrefix(ct, "application/grpc+proto") {
+		http.Error(w, fmt.Sprintf("unsupported content type %q", ct), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/grpc")
+	w.Header().Add("Trailer", "Grpc-Status")
+	w.Header().Add("Trailer", "Grpc-Message")
+	err := s.serveGRPC(w, r)
+	code, message := CodeOK, ""
+	if err != nil {
+		code, message = CodeInternal, err.Error()
+		if e, ok := err.(*Error); ok {
+			code = e.Code
+		}
+	}
+	w.Header().Set("Grpc-Status", strconv.Itoa(int(code)))
+	if message != "" {
+		w.Header().Set("Grpc-Message", encodeGRPCMessage(message))
+	}
+}
+
+func (s *Server) serveGRPC(w http.ResponseWriter, r *http.Request) error {
+	method := strings.TrimPrefix(r.URL.Path, "/"+mftpb.ServiceName+"/")
+	if method == r.URL.Path {
+		return errorf(CodeUnimplemented, "unknown service %s", r.URL.Path)
+	}
+	if r.Header.Get("Grpc-Encoding") != "" && r.Header.Get("Grpc-Encoding") != "identity" {
+		return errorf(CodeUnimplemented, "compression %s is not supported", r.Header.Get("Grpc-Encoding"))
+	}
+	request, err := readMessage(r.Body)
+	if err != nil {
+		return err
+	}
+	send := func(message []byte) error {
+		if err := writeMessage(w, message); err != nil {
+			return errorf(CodeCanceled, "unable to send response: %v", err)
+		}
+		return nil
+	}
+
+	switch method {
+	case "ListRecords":
+		var req mftpb.ListRecordsRequest
+		if err := req.Unmarshal(request); err != nil {
+			return errorf(CodeInvalidArgument, "invalid request: %v", err)
+		}
+		return s.ListRecords(r.Context(), req, func(resp mftpb.ListRecordsResponse) error {
+			return send(resp.Marshal())
+		})
+	case "GetRecord":
+		var req mftpb.RecordRequest
+		if err := req.Unmarshal(request); err != nil {
+			return errorf(CodeInvalidArgument, "invalid request: %v", err)
+		}
+		record, err := s.GetRecord(req.RecordNumber)
+		if err != nil {
+			return err
+		}
+		return send(mftpb.MarshalRecord(record))
+	case "ResolvePath":
+		var req mftpb.RecordRequest
+		if err := req.Unmarshal(request); err != nil {
+			return errorf(CodeInvalidArgument, "invalid request: %v", err)
+		}
+		path, err := s.ResolvePath(req.RecordNumber)
+		if err != nil {
+			return err
+		}
+		return send(mftpb.ResolvePathResponse{Path: path}.Marshal())
+	case "ReadStream":
+		var req mftpb.ReadStreamRequest
+		if err := req.Unmarshal(request); err != nil {
+			return errorf(CodeInvalidArgument, "invalid request: %v", err)
+		}
+		return s.ReadStream(r.Context(), req, func(chunk mftpb.Chunk) error {
+			return send(chunk.Marshal())
+		})
+	}
+	return errorf(CodeUnimplemented, "unknown method %s", method)
+}
+
+// readMessage reads the single request message of a unary or server streaming call. Each message is preceded by a
+// byte indicating whether it is compressed and its length as a 4 byte big endian number.
+func readMessage(r io.Reader) ([]byte, error) {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, errorf(CodeInvalidArgument, "unable to read request: %v", err)
+	}
+	if header[0] != 0 {
+		return nil, errorf(CodeUnimplemented, "compressed messages are not supported")
+	}
+	length := binary.BigEndian.Uint32(header[1:])
+	if length > maxRequestSize {
+		return nil, errorf(CodeInvalidArgument, "request size %d exceeds maximum of %d", length, maxRequestSize)
+	}
+	message := make([]byte, length)
+	if _, err := io.ReadFull(r, message); err != nil {
+		return nil, errorf(CodeInvalidArgument, "unable to read request: %v", err)
+	}
+	return message, nil
+}
+
+// writeMessage writes a response message and flushes it, so streamed messages reach the client right away.
+func writeMessage(w http.ResponseWriter, message []byte) error {
+	b := make([]byte, 5, 5+len(message))
+	binary.BigEndian.PutUint32(b[1:], uint32(len(message)))
+	if _, err := w.Write(append(b, message...)); err != nil {
+		return err
+	}
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+// encodeGRPCMessage percent-encodes the status message as required for the grpc-message trailer.
+func encodeGRPCMessage(message string) string {
+	var sb strings.Builder
+	for i := 0; i < len(message); i++ {
+		c := message[i]
+		if c >= 0x20 && c <= 0x7E && c != '%' {
+			sb.WriteByte(c)
+		} else {
+			fmt.Fprintf(&sb, "%%%02X", c)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,141 @@
+package server_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/mftpb"
+)
+
+func TestServeHTTP_GetRecord(t *testing.T) {
+	resp := call(t, "GetRecord", mftpb.RecordRequest{RecordNumber: 8}.Marshal())
+	assert.Equal(t, "application/grpc", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "0", resp.Trailer.Get("Grpc-Status"))
+
+	messages := readMessages(t, resp.Body)
+	require.Len(t, messages, 1)
+	record, err := mftpb.UnmarshalRecord(messages[0])
+	require.Nilf(t, err, "unable to decode record: %v", err)
+	assert.Equal(t, uint64(8), record.FileReference.RecordNumber)
+}
+
+func TestServeHTTP_ResolvePath(t *testing.T) {
+	resp := call(t, "ResolvePath", mftpb.RecordRequest{RecordNumber: 8}.Marshal())
+	assert.Equal(t, "0", resp.Trailer.Get("Grpc-Status"))
+	messages := readMessages(t, resp.Body)
+	require.Len(t, messages, 1)
+	var path mftpb.ResolvePathResponse
+	require.Nil(t, path.Unmarshal(messages[0]))
+	assert.Equal(t, "/Windows/notepad.exe", path.Path)
+}
+
+func TestServeHTTP_ListRecords(t *testing.T) {
+	resp := call(t, "ListRecords", mftpb.ListRecordsRequest{Filter: "not directory", ResolvePaths: true}.Marshal())
+	assert.Equal(t, "0", resp.Trailer.Get("Grpc-Status"))
+	messages := readMessages(t, resp.Body)
+	require.Len(t, messages, 3)
+	paths := make([]string, len(messages))
+	for i, m := range messages {
+		var r mftpb.ListRecordsResponse
+		require.Nil(t, r.Unmarshal(m))
+		paths[i] = r.Path
+	}
+	assert.Equal(t, []string{"/Windows/notepad.exe", "/big.bin", "/empty.txt"}, paths)
+}
+
+func TestServeHTTP_ReadStream(t *testing.T) {
+	resp := call(t, "ReadStream", mftpb.ReadStreamRequest{RecordNumber: 8, ChunkSize: 8}.Marshal())
+	assert.Equal(t, "0", resp.Trailer.Get("Grpc-Status"))
+	data := &bytes.Buffer{}
+	for _, m := range readMessages(t, resp.Body) {
+		var c mftpb.Chunk
+		require.Nil(t, c.Unmarshal(m))
+		assert.Equal(t, uint64(data.Len()), c.Offset)
+		data.Write(c.Data)
+	}
+	assert.Equal(t, "hello world", data.String())
+}
+
+func TestServeHTTP_Errors(t *testing.T) {
+	resp := call(t, "GetRecord", mftpb.RecordRequest{RecordNumber: 6}.Marshal())
+	assert.Equal(t, "5", resp.Trailer.Get("Grpc-Status"))
+	assert.Equal(t, "record 6 is empty", resp.Trailer.Get("Grpc-Message"))
+	assert.Len(t, readMessages(t, resp.Body), 0)
+
+	resp = call(t, "DeleteRecord", nil)
+	assert.Equal(t, "12", resp.Trailer.Get("Grpc-Status"))
+	assert.Equal(t, "unknown method DeleteRecord", resp.Trailer.Get("Grpc-Message"))
+
+	resp = call(t, "ReadStream", mftpb.ReadStreamRequest{RecordNumber: 8, StreamName: "100%"}.Marshal())
+	assert.Equal(t, "5", resp.Trailer.Get("Grpc-Status"))
+	assert.Equal(t, `record 8 has no $DATA attribute named "100%25"`, resp.Trailer.Get("Grpc-Message"))
+
+	resp = serve(t, "/gomft.mft.v1.MFTService/GetRecord", frame([]byte{0x08}))
+	assert.Equal(t, "3", resp.Trailer.Get("Grpc-Status"))
+
+	compressed := frame(nil)
+	compressed[0] = 1
+	resp = serve(t, "/gomft.mft.v1.MFTService/GetRecord", compressed)
+	assert.Equal(t, "12", resp.Trailer.Get("Grpc-Status"))
+
+	resp = serve(t, "/other.Service/GetRecord", frame(nil))
+	assert.Equal(t, "12", resp.Trailer.Get("Grpc-Status"))
+}
+
+func TestServeHTTP_NotGRPC(t *testing.T) {
+	rec := httptest.NewRecorder()
+	testServer().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusHTTPVersionNotSupported, rec.Code)
+
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/gomft.mft.v1.MFTService/GetRecord", nil)
+	req.ProtoMajor = 2
+	req.Header.Set("Content-Type", "application/json")
+	testServer().ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+}
+
+func call(t *testing.T, method string, request []byte) *http.Response {
+	return serve(t, "/"+mftpb.ServiceName+"/"+method, frame(request))
+}
+
+func serve(t *testing.T, path string, body []byte) *http.Response {
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+	req.ProtoMajor, req.ProtoMinor = 2, 0
+	req.Header.Set("Content-Type", "application/grpc")
+	rec := httptest.NewRecorder()
+	testServer().ServeHTTP(rec, req)
+	resp := rec.Result()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	return resp
+}
+
+// frame prefixes a message with the uncompressed flag and its length, as done by gRPC.
+func frame(message []byte) []byte {
+	b := make([]byte, 5+len(message))
+	binary.BigEndian.PutUint32(b[1:], uint32(len(message)))
+	copy(b[5:], message)
+	return b
+}
+
+func readMessages(t *testing.T, body io.Reader) [][]byte {
+	data := &bytes.Buffer{}
+	_, err := data.ReadFrom(body)
+	require.Nil(t, err)
+	b := data.Bytes()
+	messages := make([][]byte, 0)
+	for len(b) > 0 {
+		require.True(t, len(b) >= 5, "truncated message header")
+		length := int(binary.BigEndian.Uint32(b[1:]))
+		require.True(t, len(b) >= 5+length, "truncated message")
+		messages = append(messages, b[5:5+length])
+		b = b[5+length:]
+	}
+	return messages
+}
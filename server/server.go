@@ -0,0 +1,246 @@
+/*
+	Package server exposes the MFT of a volume or dump as a gRPC service (MFTService, as defined in mftpb/mft.proto),
+	so remote agents can query records, resolve paths and read file contents over the network with only gomft on the
+	server side.
+
+	Basic usage
+
+	Create a Server for the MFT data and serve it using net/http over TLS, which provides the HTTP/2 transport required
+	by gRPC. Any gRPC client can then call the methods, for example grpcurl with the mft.proto schema.
+			// Error handling left out for brevity
+			dump, err := os.Open("sdb1.mft")
+			s := server.New(server.Config{MFT: dump})
+			err = http.ListenAndServeTLS(":8443", "server.crt", "server.key", s)
+
+	The methods can also be called directly from Go, without any network in between.
+			record, err := s.GetRecord(0)
+
+	Implementation notes
+
+	The gRPC protocol is implemented on top of net/http, rather than using grpc-go, to keep gomft free of dependencies.
+	Only what is needed for the MFTService is supported: unary and server streaming calls, without compression. net/http
+	only uses HTTP/2 over TLS, so the server must be served using TLS (plain text "h2c" connections are not supported).
+
+	To read file contents using ReadStream, the Config must include the Volume and the BytesPerCluster; without them only
+	resident data can be read. Sparse streams read as zeroes and compressed streams are decompressed.
+*/
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+
+	"github.com/t9t/gomft/export"
+	"github.com/t9t/gomft/filter"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/mftpb"
+	"github.com/t9t/gomft/pipeline"
+	"github.com/t9t/gomft/volume"
+)
+
+const (
+	defaultRecordSize = 1024
+	// DefaultChunkSize is the size of the chunks sent by ReadStream when the request does not specify one.
+	DefaultChunkSize = 64 * 1024
+	// MaxChunkSize is the maximum size of the chunks sent by ReadStream, which keeps messages well below the default
+	// maximum message size of 4 MB of gRPC clients.
+	MaxChunkSize = 1024 * 1024
+)
+
+// Config specifies the data served by a Server.
+type Config struct {
+	// MFT contains the MFT data, such as an MFT dump file or a fragment.ReaderAt over the MFT of a volume.
+	MFT io.ReaderAt
+	// RecordSize is the size of a record in bytes. When zero, 1024 is used.
+	RecordSize int
+	// Volume, when not nil, is the volume which the MFT belongs to. It is needed to read non-resident data.
+	Volume io.ReaderAt
+	// BytesPerCluster is the cluster size of the Volume.
+	BytesPerCluster int
+	// Workers is the number of goroutines used to parse records by ListRecords. When zero, runtime.GOMAXPROCS(0) is
+	// used.
+	Workers int
+}
+
+// Code is a gRPC status code.
+type Code uint32
+
+// The gRPC status codes used by the Server.
+const (
+	CodeOK                 Code = 0
+	CodeCanceled           Code = 1
+	CodeInvalidArgument    Code = 3
+	CodeNotFound           Code = 5
+	CodeFailedPrecondition Code = 9
+	CodeUnimplemented      Code = 12
+	CodeInternal           Code = 13
+	CodeDataLoss           Code = 15
+)
+
+// Error is an error with a gRPC status code. Errors returned by the methods of a Server are of this type, except for
+// errors returned by the send functions passed to them, which are returned as is.
+type Error struct {
+	Code    Code
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func errorf(code Code, format string, a ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, a...)}
+}
+
+// A Server gives access to the records of an MFT. It is safe for concurrent use.
+type Server struct {
+	config Config
+	mu     sync.Mutex
+	paths  *pipeline.PathResolver
+}
+
+// New creates a Server for the data in the config.
+func New(config Config) *Server {
+	if config.RecordSize <= 0 {
+		config.RecordSize = defaultRecordSize
+	}
+	return &Server{config: config, paths: pipeline.NewPathResolver(config.MFT, config.RecordSize, 0)}
+}
+
+// GetRecord reads and parses a single record. It returns an error with CodeNotFound when the record does not exist or
+// is empty, and with CodeDataLoss when it cannot be parsed.
+func (s *Server) GetRecord(number uint64) (mft.Record, error) {
+	if number > math.MaxInt64/uint64(s.config.RecordSize) {
+		return mft.Record{}, errorf(CodeNotFound, "record %d does not exist", number)
+	}
+	b := make([]byte, s.config.RecordSize)
+	n, err := s.config.MFT.ReadAt(b, int64(number)*int64(s.config.RecordSize))
+	if n < len(b) {
+		if err == io.EOF || err == nil {
+			return mft.Record{}, errorf(CodeNotFound, "record %d does not exist", number)
+		}
+		return mft.Record{}, errorf(CodeInternal, "unable to read record %d: %v", number, err)
+	}
+	if string(b[:4]) != "FILE" {
+		return mft.Record{}, errorf(CodeNotFound, "record %d is empty", number)
+	}
+	record, err := mft.ParseRecord(b)
+	if err != nil {
+		return mft.Record{}, errorf(CodeDataLoss, "unable to parse record %d: %v", number, err)
+	}
+	return record, nil
+}
+
+// ResolvePath returns the full path of a record, in the same format as pipeline.PathResolver.
+func (s *Server) ResolvePath(number uint64) (string, error) {
+	record, err := s.GetRecord(number)
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	path, err := s.paths.Path(export.FromRecord(record))
+	if err != nil {
+		return "", errorf(CodeInternal, "unable to resolve path of record %d: %v", number, err)
+	}
+	return path, nil
+}
+
+// ListRecords calls send for each record matching the request, in the order of the records. Records that cannot be
+// parsed are skipped. It stops when ctx is done, returning an error with CodeCanceled.
+func (s *Server) ListRecords(ctx context.Context, req mftpb.ListRecordsRequest, send func(mftpb.ListRecordsResponse) error) error {
+	var where *filter.Filter
+	if req.Filter != "" {
+		f, err := filter.Compile(req.Filter)
+		if err != nil {
+			return errorf(CodeInvalidArgument, "invalid filter expression: %v", err)
+		}
+		where = f
+	}
+	opts := pipeline.QueryOptions{
+		ParseAll: mft.ParseAllOptions{RecordSize: s.config.RecordSize, Workers: s.config.Workers},
+		Workers:  s.config.Workers,
+	}
+	if req.ResolvePaths {
+		// A PathResolver is not safe for concurrent use, so each call gets its own
+		opts.Paths = pipeline.NewPathResolver(s.config.MFT, s.config.RecordSize, 0)
+	}
+	predicate := func(item *pipeline.Item) bool {
+		return (!req.InUseOnly || item.Entry.InUse) && (where == nil || where.Match(item.Entry))
+	}
+
+	results := pipeline.QueryContext(ctx, io.NewSectionReader(s.config.MFT, 0, math.MaxInt64), opts, predicate, nil)
+	defer results.Close()
+	for results.Next() {
+		item := results.Item()
+		if err := send(mftpb.ListRecordsResponse{Record: item.Record, Path: item.Path}); err != nil {
+			return err
+		}
+	}
+	if ctx.Err() != nil {
+		return errorf(CodeCanceled, "listing records canceled: %v", ctx.Err())
+	}
+	if err := results.Err(); err != nil {
+		return errorf(CodeInternal, "unable to list records: %v", err)
+	}
+	return nil
+}
+
+// ReadStream calls send with consecutive chunks of the contents of a $DATA attribute of a record. Only the attributes
+// in the record itself are considered, not those in extension records. An empty stream results in a single, empty
+// chunk.
+func (s *Server) ReadStream(ctx context.Context, req mftpb.ReadStreamRequest, send func(mftpb.Chunk) error) error {
+	chunkSize := int(req.ChunkSize)
+	if chunkSize == 0 {
+		chunkSize = DefaultChunkSize
+	}
+	if chunkSize > MaxChunkSize {
+		return errorf(CodeInvalidArgument, "chunk size %d exceeds maximum of %d", chunkSize, MaxChunkSize)
+	}
+
+	record, err := s.GetRecord(req.RecordNumber)
+	if err != nil {
+		return err
+	}
+	var attribute *mft.Attribute
+	for _, a := range record.FindAttributes(mft.AttributeTypeData) {
+		if a.Name == req.StreamName {
+			attribute = &a
+			break
+		}
+	}
+	if attribute == nil {
+		return errorf(CodeNotFound, "record %d has no $DATA attribute named %q", req.RecordNumber, req.StreamName)
+	}
+
+	data, err := volume.NewAttributeReader(s.config.Volume, s.config.BytesPerCluster, *attribute)
+	if err == volume.ErrNoVolume {
+		return errorf(CodeFailedPrecondition, "the data of record %d is non-resident, but no volume is available", req.RecordNumber)
+	} else if err != nil {
+		return errorf(CodeDataLoss, "unable to open stream of record %d: %v", req.RecordNumber, err)
+	}
+	size := data.Size()
+	if size == 0 {
+		return send(mftpb.Chunk{})
+	}
+	buf := make([]byte, chunkSize)
+	for offset := int64(0); offset < size; offset += int64(chunkSize) {
+		if ctx.Err() != nil {
+			return errorf(CodeCanceled, "reading stream canceled: %v", ctx.Err())
+		}
+		chunk := buf
+		if remaining := size - offset; remaining < int64(len(chunk)) {
+			chunk = chunk[:remaining]
+		}
+		if _, err := data.ReadAt(chunk, offset); err != nil {
+			return errorf(CodeInternal, "unable to read stream of record %d at offset %d: %v", req.RecordNumber, offset, err)
+		}
+		if err := send(mftpb.Chunk{Offset: uint64(offset), Data: chunk}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
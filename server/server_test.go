@@ -0,0 +1,191 @@
+package server_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/mftpb"
+	"github.com/t9t/gomft/mfttest"
+	"github.com/t9t/gomft/server"
+)
+
+func TestGetRecord(t *testing.T) {
+	s := testServer()
+	record, err := s.GetRecord(8)
+	require.Nilf(t, err, "unable to get record: %v", err)
+	assert.Equal(t, uint64(8), record.FileReference.RecordNumber)
+	assert.Len(t, record.FindAttributes(mft.AttributeTypeData), 2)
+
+	_, err = s.GetRecord(6)
+	assertCode(t, server.CodeNotFound, "record 6 is empty", err)
+	_, err = s.GetRecord(12)
+	assertCode(t, server.CodeNotFound, "record 12 does not exist", err)
+	_, err = s.GetRecord(1 << 63)
+	assertCode(t, server.CodeNotFound, "record 9223372036854775808 does not exist", err)
+	_, err = s.GetRecord(11)
+	require.NotNil(t, err)
+	assert.Equal(t, server.CodeDataLoss, err.(*server.Error).Code)
+}
+
+func TestResolvePath(t *testing.T) {
+	s := testServer()
+	path, err := s.ResolvePath(8)
+	require.Nilf(t, err, "unable to resolve path: %v", err)
+	assert.Equal(t, "/Windows/notepad.exe", path)
+
+	path, err = s.ResolvePath(5)
+	require.Nilf(t, err, "unable to resolve path: %v", err)
+	assert.Equal(t, "/", path)
+
+	_, err = s.ResolvePath(6)
+	assertCode(t, server.CodeNotFound, "record 6 is empty", err)
+}
+
+func TestListRecords(t *testing.T) {
+	s := testServer()
+	var responses []mftpb.ListRecordsResponse
+	send := func(resp mftpb.ListRecordsResponse) error {
+		responses = append(responses, resp)
+		return nil
+	}
+
+	require.Nil(t, s.ListRecords(context.Background(), mftpb.ListRecordsRequest{}, send))
+	require.Len(t, responses, 5)
+	assert.Equal(t, uint64(5), responses[0].Record.FileReference.RecordNumber)
+	assert.Equal(t, "", responses[0].Path)
+
+	responses = nil
+	req := mftpb.ListRecordsRequest{Filter: "name like '*.exe' or name like '*.bin'", ResolvePaths: true}
+	require.Nil(t, s.ListRecords(context.Background(), req, send))
+	require.Len(t, responses, 2)
+	assert.Equal(t, "/Windows/notepad.exe", responses[0].Path)
+	assert.Equal(t, "/big.bin", responses[1].Path)
+
+	err := s.ListRecords(context.Background(), mftpb.ListRecordsRequest{Filter: "name like"}, send)
+	require.NotNil(t, err)
+	assert.Equal(t, server.CodeInvalidArgument, err.(*server.Error).Code)
+
+	sendErr := errors.New("connection lost")
+	err = s.ListRecords(context.Background(), mftpb.ListRecordsRequest{}, func(mftpb.ListRecordsResponse) error { return sendErr })
+	assert.Equal(t, sendErr, err)
+}
+
+func TestListRecords_Canceled(t *testing.T) {
+	s := testServer()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := s.ListRecords(ctx, mftpb.ListRecordsRequest{}, func(mftpb.ListRecordsResponse) error { return nil })
+	require.NotNil(t, err)
+	assert.Equal(t, server.CodeCanceled, err.(*server.Error).Code)
+}
+
+func TestReadStream(t *testing.T) {
+	s := testServer()
+	read := func(req mftpb.ReadStreamRequest) ([]mftpb.Chunk, error) {
+		var chunks []mftpb.Chunk
+		err := s.ReadStream(context.Background(), req, func(c mftpb.Chunk) error {
+			c.Data = append([]byte(nil), c.Data...)
+			chunks = append(chunks, c)
+			return nil
+		})
+		return chunks, err
+	}
+
+	chunks, err := read(mftpb.ReadStreamRequest{RecordNumber: 8, ChunkSize: 4})
+	require.Nilf(t, err, "unable to read stream: %v", err)
+	assert.Equal(t, []mftpb.Chunk{{Offset: 0, Data: []byte("hell")}, {Offset: 4, Data: []byte("o wo")}, {Offset: 8, Data: []byte("rld")}}, chunks)
+
+	chunks, err = read(mftpb.ReadStreamRequest{RecordNumber: 8, StreamName: "Zone.Identifier"})
+	require.Nilf(t, err, "unable to read stream: %v", err)
+	assert.Equal(t, []mftpb.Chunk{{Data: []byte("[ZoneTransfer]")}}, chunks)
+
+	chunks, err = read(mftpb.ReadStreamRequest{RecordNumber: 9, ChunkSize: 16})
+	require.Nilf(t, err, "unable to read stream: %v", err)
+	assert.Equal(t, []mftpb.Chunk{{Offset: 0, Data: bytes.Repeat([]byte{2}, 16)}, {Offset: 16, Data: []byte{3, 3, 3, 3}}}, chunks)
+
+	chunks, err = read(mftpb.ReadStreamRequest{RecordNumber: 10})
+	require.Nilf(t, err, "unable to read stream: %v", err)
+	assert.Equal(t, []mftpb.Chunk{{}}, chunks)
+
+	_, err = read(mftpb.ReadStreamRequest{RecordNumber: 8, StreamName: "nope"})
+	assertCode(t, server.CodeNotFound, `record 8 has no $DATA attribute named "nope"`, err)
+	_, err = read(mftpb.ReadStreamRequest{RecordNumber: 8, ChunkSize: server.MaxChunkSize + 1})
+	assertCode(t, server.CodeInvalidArgument, "chunk size 1048577 exceeds maximum of 1048576", err)
+
+	noVolume := server.New(server.Config{MFT: bytes.NewReader(testDump())})
+	err = noVolume.ReadStream(context.Background(), mftpb.ReadStreamRequest{RecordNumber: 9}, func(mftpb.Chunk) error { return nil })
+	assertCode(t, server.CodeFailedPrecondition, "the data of record 9 is non-resident, but no volume is available", err)
+}
+
+func TestReadStream_Sparse(t *testing.T) {
+	// 16 sparse bytes followed by 8 bytes of the third cluster
+	dump := mfttest.NewRecord().WithAttribute(mft.Attribute{
+		Type:       mft.AttributeTypeData,
+		Flags:      mft.AttributeFlagsSparse,
+		ActualSize: 24,
+		Data:       mfttest.EncodeDataRuns([]mft.DataRun{{LengthInClusters: 1, Sparse: true}, {OffsetCluster: 2, LengthInClusters: 1}}),
+	}).Bytes()
+	volume := append(make([]byte, 32), bytes.Repeat([]byte{2}, 16)...)
+	s := server.New(server.Config{MFT: bytes.NewReader(dump), Volume: bytes.NewReader(volume), BytesPerCluster: 16})
+
+	var data []byte
+	err := s.ReadStream(context.Background(), mftpb.ReadStreamRequest{RecordNumber: 0}, func(c mftpb.Chunk) error {
+		data = append(data, c.Data...)
+		return nil
+	})
+	require.Nilf(t, err, "unable to read stream: %v", err)
+	assert.Equal(t, append(make([]byte, 16), bytes.Repeat([]byte{2}, 8)...), data)
+}
+
+func assertCode(t *testing.T, code server.Code, message string, err error) {
+	t.Helper()
+	require.NotNil(t, err)
+	e, ok := err.(*server.Error)
+	require.True(t, ok, "expected a *server.Error but got %T", err)
+	assert.Equal(t, code, e.Code)
+	assert.Equal(t, message, e.Message)
+}
+
+func testServer() *server.Server {
+	volume := make([]byte, 64)
+	for i := range volume {
+		volume[i] = byte(i / 16)
+	}
+	return server.New(server.Config{MFT: bytes.NewReader(testDump()), Volume: bytes.NewReader(volume), BytesPerCluster: 16})
+}
+
+// testDump creates an MFT dump of 12 records of 1024 bytes. Records 5 (the root directory), 7 (a directory) and 8-10
+// (files) are valid, record 11 has an invalid fixup and all others are empty. Record 8 has resident data and a named
+// stream, record 9 has 20 bytes of non-resident data in clusters 2 and 3 and record 10 has no data.
+func testDump() []byte {
+	dump := make([]byte, 12*1024)
+	copy(dump[5*1024:], testRecord(5, 5, 5, 5, ".").WithDirectory().Bytes())
+	copy(dump[7*1024:], testRecord(7, 2, 5, 5, "Windows").WithDirectory().Bytes())
+	copy(dump[8*1024:], testRecord(8, 1, 7, 2, "notepad.exe").
+		WithResidentData([]byte("hello world")).
+		WithStream("Zone.Identifier", []byte("[ZoneTransfer]")).
+		Bytes())
+	copy(dump[9*1024:], testRecord(9, 1, 5, 5, "big.bin").
+		WithClusterSize(16).
+		WithNonResidentData(20, mft.DataRun{OffsetCluster: 2, LengthInClusters: 2}).
+		Bytes())
+	copy(dump[10*1024:], testRecord(10, 1, 5, 5, "empty.txt").WithResidentData(nil).Bytes())
+	corrupt := testRecord(11, 1, 5, 5, "corrupt.txt").Bytes()
+	corrupt[510] = 0xFF
+	copy(dump[11*1024:], corrupt)
+	return dump
+}
+
+// testRecord creates a builder of an in use record with a $FILE_NAME attribute with the name in the parent directory.
+func testRecord(number uint64, sequenceNumber uint16, parent uint64, parentSequenceNumber uint16, name string) *mfttest.RecordBuilder {
+	return mfttest.NewRecord().
+		WithRecordNumber(number).
+		WithSequenceNumber(sequenceNumber).
+		WithParent(mft.FileReference{RecordNumber: parent, SequenceNumber: parentSequenceNumber}).
+		WithFileName(name)
+}
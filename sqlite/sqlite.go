@@ -0,0 +1,284 @@
+/*
+	Package sqlite persists export.Entry values in an SQLite database, so tools embedding gomft can store parse results
+	and query them using SQL later on.
+
+	Basic usage
+
+	Open the database using database/sql with any SQLite driver (this package does not import one), then use Export to
+	parse an MFT and store all of its records with a single call.
+			// Error handling left out for brevity
+			db, err := sql.Open("sqlite3", "sdb1.db") // using github.com/mattn/go-sqlite3
+			in, err := os.Open("sdb1.mft")
+			count, err := sqlite.Export(db, in, sqlite.Options{})
+
+	Or create a Writer to store Entries one by one; it implements export.Writer, so it can be used as a pipeline stage.
+			w, err := sqlite.NewWriter(db, sqlite.Options{})
+			err = w.Write(export.FromRecord(record))
+			err = w.Flush()
+
+	Implementation notes
+
+	The table is created if it does not exist yet, and when WAL is enabled the journal mode of the database is set to
+	write-ahead logging. Entries are inserted using a prepared statement, in transactions of Options.BatchSize entries,
+	which is orders of magnitude faster than a transaction per entry. The indexes are created by Flush, after the bulk
+	of the data has been inserted, which is faster than maintaining them during the inserts.
+
+	Times are stored as RFC 3339 text with nanosecond precision, which the SQLite date and time functions understand;
+	zero times are stored as NULL. Sizes and numbers are stored as INTEGER, booleans as 0 or 1.
+*/
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/t9t/gomft/export"
+	"github.com/t9t/gomft/mft"
+)
+
+// DefaultTable is the name of the table used when Options.Table is empty.
+const DefaultTable = "entries"
+
+// DefaultBatchSize is the number of entries inserted per transaction when Options.BatchSize is zero.
+const DefaultBatchSize = 10000
+
+var columns = []string{
+	"source TEXT NOT NULL",
+	`"offset" INTEGER NOT NULL`, // OFFSET is a keyword in SQLite
+	"record_number INTEGER NOT NULL",
+	"sequence_number INTEGER NOT NULL",
+	"in_use INTEGER NOT NULL",
+	"directory INTEGER NOT NULL",
+	"parent_record_number INTEGER NOT NULL",
+	"parent_sequence_number INTEGER NOT NULL",
+	"path TEXT",
+	"name TEXT NOT NULL",
+	"namespace TEXT NOT NULL",
+	"size INTEGER NOT NULL",
+	"allocated_size INTEGER NOT NULL",
+	"attributes INTEGER NOT NULL",
+	"hard_link_count INTEGER NOT NULL",
+	"lsn INTEGER NOT NULL",
+	"usn INTEGER NOT NULL",
+	"security_id INTEGER NOT NULL",
+	"has_ads INTEGER NOT NULL",
+	"reparse_target TEXT",
+	"si_created TEXT",
+	"si_modified TEXT",
+	"si_mft_modified TEXT",
+	"si_accessed TEXT",
+	"fn_created TEXT",
+	"fn_modified TEXT",
+	"fn_mft_modified TEXT",
+	"fn_accessed TEXT",
+}
+
+var indexedColumns = []string{"record_number", "parent_record_number", "name"}
+
+// Options configures a Writer.
+type Options struct {
+	// Table is the name of the table to store the entries in. When empty, DefaultTable is used. The name is quoted in
+	// the SQL statements, so it is used as is.
+	Table string
+	// BatchSize is the number of entries to insert per transaction. When zero, DefaultBatchSize is used.
+	BatchSize int
+	// WAL enables write-ahead logging (PRAGMA journal_mode=WAL) on the database.
+	WAL bool
+	// NoIndexes disables creating the indexes on the record number, parent record number and name.
+	NoIndexes bool
+}
+
+// Schema returns the SQL statements creating the table and indexes used for the entries, for the specified table
+// name.
+func Schema(table string) []string {
+	statements := []string{fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)", quoteIdentifier(table), strings.Join(columns, ", "))}
+	return append(statements, indexStatements(table)...)
+}
+
+func indexStatements(table string) []string {
+	statements := make([]string, 0, len(indexedColumns))
+	for _, c := range indexedColumns {
+		index := quoteIdentifier(table + "_" + c)
+		statements = append(statements, fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (%s)", index, quoteIdentifier(table), c))
+	}
+	return statements
+}
+
+// quoteIdentifier quotes name for use as an identifier in an SQL statement, doubling any double quotes in it.
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// A Writer inserts Entries into a table of an SQLite database, in batches. It implements export.Writer; Flush must be
+// called after the last Entry to commit the last batch and create the indexes. A Writer is not safe for concurrent use.
+type Writer struct {
+	db      *sql.DB
+	opts    Options
+	insert  string
+	tx      *sql.Tx
+	stmt    *sql.Stmt
+	pending int
+}
+
+// NewWriter creates the table (if it does not exist yet) and returns a Writer which inserts into it.
+func NewWriter(db *sql.DB, opts Options) (*Writer, error) {
+	if opts.Table == "" {
+		opts.Table = DefaultTable
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	if opts.WAL {
+		if _, err := db.Exec("PRAGMA journal_mode=WAL"); err != nil {
+			return nil, fmt.Errorf("unable to enable write-ahead logging: %v", err)
+		}
+	}
+	if _, err := db.Exec(Schema(opts.Table)[0]); err != nil {
+		return nil, fmt.Errorf("unable to create table %s: %v", opts.Table, err)
+	}
+
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = c[:strings.IndexByte(c, ' ')]
+	}
+	insert := fmt.Sprintf("INSERT INTO %s (%s) VALUES (?%s)", quoteIdentifier(opts.Table), strings.Join(names, ", "), strings.Repeat(", ?", len(names)-1))
+	return &Writer{db: db, opts: opts, insert: insert}, nil
+}
+
+// Write inserts the Entry, committing the current transaction when it contains Options.BatchSize entries.
+func (w *Writer) Write(e export.Entry) error {
+	if w.tx == nil {
+		tx, err := w.db.Begin()
+		if err != nil {
+			return fmt.Errorf("unable to begin transaction: %v", err)
+		}
+		stmt, err := tx.Prepare(w.insert)
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("unable to prepare insert statement: %v", err)
+		}
+		w.tx, w.stmt = tx, stmt
+	}
+
+	_, err := w.stmt.Exec(
+		e.Source,
+		e.Offset,
+		int64(e.RecordNumber),
+		int64(e.SequenceNumber),
+		e.InUse,
+		e.Directory,
+		int64(e.ParentRecordNumber),
+		int64(e.ParentSequenceNumber),
+		nullString(e.Path),
+		e.Name,
+		e.Namespace.String(),
+		int64(e.Size),
+		int64(e.AllocatedSize),
+		int64(e.FileAttributes),
+		int64(e.HardLinkCount),
+		int64(e.LogFileSequenceNumber),
+		int64(e.UpdateSequenceNumber),
+		int64(e.SecurityId),
+		e.HasAlternateDataStreams,
+		nullString(e.ReparseTarget),
+		nullTime(e.Creation),
+		nullTime(e.FileLastModified),
+		nullTime(e.MftLastModified),
+		nullTime(e.LastAccess),
+		nullTime(e.FileNameCreation),
+		nullTime(e.FileNameFileLastModified),
+		nullTime(e.FileNameMftLastModified),
+		nullTime(e.FileNameLastAccess),
+	)
+	if err != nil {
+		return fmt.Errorf("unable to insert record %d: %v", e.RecordNumber, err)
+	}
+
+	w.pending++
+	if w.pending >= w.opts.BatchSize {
+		return w.commit()
+	}
+	return nil
+}
+
+// Flush commits the current transaction and creates the indexes, unless disabled using Options.NoIndexes. The Writer
+// can still be used afterwards.
+func (w *Writer) Flush() error {
+	if err := w.commit(); err != nil {
+		return err
+	}
+	if w.opts.NoIndexes {
+		return nil
+	}
+	for _, statement := range indexStatements(w.opts.Table) {
+		if _, err := w.db.Exec(statement); err != nil {
+			return fmt.Errorf("unable to create index: %v", err)
+		}
+	}
+	return nil
+}
+
+func (w *Writer) commit() error {
+	if w.tx == nil {
+		return nil
+	}
+	tx, stmt := w.tx, w.stmt
+	w.tx, w.stmt, w.pending = nil, nil, 0
+	stmt.Close()
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("unable to commit transaction: %v", err)
+	}
+	return nil
+}
+
+// Export parses all records in r (an MFT dump with 1024 byte records, or MFT data as read from a volume) and stores
+// them in the database using a Writer, skipping records that cannot be parsed. It returns the number of records stored.
+func Export(db *sql.DB, r io.Reader, opts Options) (int, error) {
+	w, err := NewWriter(db, opts)
+	if err != nil {
+		return 0, err
+	}
+
+	cancel := make(chan struct{})
+	defer close(cancel)
+	count := 0
+	for result := range mft.ParseAll(r, mft.ParseAllOptions{SkipEmpty: true, Cancel: cancel}) {
+		if readErr, ok := result.Err.(*mft.ReadError); ok {
+			w.rollback()
+			return count, fmt.Errorf("unable to read record at offset %d: %v", result.Offset, readErr.Err)
+		}
+		if result.Err != nil {
+			continue
+		}
+		e := export.FromRecord(result.Record)
+		e.Offset = result.Offset
+		if err := w.Write(e); err != nil {
+			w.rollback()
+			return count, err
+		}
+		count++
+	}
+	return count, w.Flush()
+}
+
+func (w *Writer) rollback() {
+	if w.tx != nil {
+		w.stmt.Close()
+		w.tx.Rollback()
+		w.tx, w.stmt, w.pending = nil, nil, 0
+	}
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func nullTime(t time.Time) sql.NullString {
+	if t.IsZero() {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: t.Format(time.RFC3339Nano), Valid: true}
+}
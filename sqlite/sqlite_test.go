@@ -0,0 +1,192 @@
+package sqlite_test
+
+import (
+	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/export"
+	"github.com/t9t/gomft/mfttest"
+	"github.com/t9t/gomft/sqlite"
+)
+
+// recorder is a database/sql driver which records the statements executed, instead of executing them.
+type recorder struct {
+	mu         sync.Mutex
+	statements []string
+	inserts    [][]driver.Value
+	commits    int
+	rollbacks  int
+	failInsert bool
+}
+
+var recorders = map[string]*recorder{}
+
+func init() {
+	sql.Register("gomft-recorder", recorderDriver{})
+}
+
+type recorderDriver struct{}
+
+func (recorderDriver) Open(name string) (driver.Conn, error) {
+	return &recorderConn{r: recorders[name]}, nil
+}
+
+type recorderConn struct {
+	r *recorder
+}
+
+func (c *recorderConn) Prepare(query string) (driver.Stmt, error) {
+	return &recorderStmt{r: c.r, query: query}, nil
+}
+
+func (c *recorderConn) Close() error { return nil }
+
+func (c *recorderConn) Begin() (driver.Tx, error) {
+	return recorderTx{r: c.r}, nil
+}
+
+type recorderTx struct {
+	r *recorder
+}
+
+func (t recorderTx) Commit() error {
+	t.r.mu.Lock()
+	defer t.r.mu.Unlock()
+	t.r.commits++
+	return nil
+}
+
+func (t recorderTx) Rollback() error {
+	t.r.mu.Lock()
+	defer t.r.mu.Unlock()
+	t.r.rollbacks++
+	return nil
+}
+
+type recorderStmt struct {
+	r     *recorder
+	query string
+}
+
+func (s *recorderStmt) Close() error  { return nil }
+func (s *recorderStmt) NumInput() int { return -1 }
+
+func (s *recorderStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.r.mu.Lock()
+	defer s.r.mu.Unlock()
+	if strings.HasPrefix(s.query, "INSERT") {
+		if s.r.failInsert {
+			return nil, errors.New("disk full")
+		}
+		s.r.inserts = append(s.r.inserts, args)
+	} else {
+		s.r.statements = append(s.r.statements, s.query)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *recorderStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+func openRecorder(t *testing.T, name string) (*sql.DB, *recorder) {
+	r := &recorder{}
+	recorders[name] = r
+	db, err := sql.Open("gomft-recorder", name)
+	require.Nilf(t, err, "unable to open database: %v", err)
+	db.SetMaxOpenConns(1)
+	return db, r
+}
+
+func TestSchema(t *testing.T) {
+	schema := sqlite.Schema("files")
+	require.Len(t, schema, 4)
+	assert.True(t, strings.HasPrefix(schema[0], `CREATE TABLE IF NOT EXISTS "files" (source TEXT NOT NULL, "offset" INTEGER NOT NULL, record_number INTEGER NOT NULL,`))
+	assert.Equal(t, `CREATE INDEX IF NOT EXISTS "files_record_number" ON "files" (record_number)`, schema[1])
+	assert.Equal(t, `CREATE INDEX IF NOT EXISTS "files_parent_record_number" ON "files" (parent_record_number)`, schema[2])
+	assert.Equal(t, `CREATE INDEX IF NOT EXISTS "files_name" ON "files" (name)`, schema[3])
+
+	schema = sqlite.Schema(`x"; DROP TABLE y; --`)
+	assert.True(t, strings.HasPrefix(schema[0], `CREATE TABLE IF NOT EXISTS "x""; DROP TABLE y; --" (source`))
+	assert.Equal(t, `CREATE INDEX IF NOT EXISTS "x""; DROP TABLE y; --_name" ON "x""; DROP TABLE y; --" (name)`, schema[3])
+}
+
+func TestWriter(t *testing.T) {
+	db, r := openRecorder(t, "writer")
+	defer db.Close()
+
+	w, err := sqlite.NewWriter(db, sqlite.Options{BatchSize: 2, WAL: true})
+	require.Nilf(t, err, "unable to create writer: %v", err)
+	created := time.Date(2020, time.February, 5, 14, 59, 38, 116886200, time.UTC)
+	for i := 0; i < 3; i++ {
+		require.Nil(t, w.Write(export.Entry{Source: export.SourceRecord, RecordNumber: uint64(i), Name: "a.txt", Creation: created, InUse: true}))
+	}
+	assert.Equal(t, 1, r.commits)
+	require.Nil(t, w.Flush())
+
+	assert.Equal(t, 2, r.commits)
+	assert.Equal(t, 0, r.rollbacks)
+	require.Len(t, r.statements, 5)
+	assert.Equal(t, "PRAGMA journal_mode=WAL", r.statements[0])
+	assert.Equal(t, sqlite.Schema(sqlite.DefaultTable), r.statements[1:])
+
+	require.Len(t, r.inserts, 3)
+	values := r.inserts[2]
+	require.Len(t, values, 28)
+	assert.Equal(t, "record", values[0])
+	assert.Equal(t, int64(2), values[2])
+	assert.Equal(t, true, values[4])
+	assert.Nil(t, values[8], "path")
+	assert.Equal(t, "a.txt", values[9])
+	assert.Equal(t, "Posix", values[10])
+	assert.Equal(t, "2020-02-05T14:59:38.1168862Z", values[20])
+	assert.Nil(t, values[21], "si_modified")
+}
+
+func TestWriterNoIndexes(t *testing.T) {
+	db, r := openRecorder(t, "noindexes")
+	defer db.Close()
+
+	w, err := sqlite.NewWriter(db, sqlite.Options{Table: "files", NoIndexes: true})
+	require.Nilf(t, err, "unable to create writer: %v", err)
+	require.Nil(t, w.Flush())
+	assert.Equal(t, []string{sqlite.Schema("files")[0]}, r.statements)
+	assert.Equal(t, 0, r.commits)
+}
+
+func TestWriterInsertError(t *testing.T) {
+	db, r := openRecorder(t, "inserterror")
+	defer db.Close()
+	r.failInsert = true
+
+	w, err := sqlite.NewWriter(db, sqlite.Options{})
+	require.Nilf(t, err, "unable to create writer: %v", err)
+	err = w.Write(export.Entry{RecordNumber: 42})
+	require.NotNil(t, err)
+	assert.Equal(t, "unable to insert record 42: disk full", err.Error())
+}
+
+func TestExport(t *testing.T) {
+	db, r := openRecorder(t, "export")
+	defer db.Close()
+
+	record := mfttest.SampleMftRecord()
+	in := bytes.Repeat(record, 3)
+	in = append(in, make([]byte, 1024)...) // an empty record, which is skipped
+
+	count, err := sqlite.Export(db, bytes.NewReader(in), sqlite.Options{})
+	require.Nilf(t, err, "unable to export: %v", err)
+	assert.Equal(t, 3, count)
+	require.Len(t, r.inserts, 3)
+	assert.Equal(t, "$MFT", r.inserts[0][9])
+	assert.Equal(t, int64(1024), r.inserts[1][1])
+	assert.Equal(t, 1, r.commits)
+}
@@ -0,0 +1,280 @@
+/*
+	Package subset copies a selection of the records of an MFT, such as a range of record numbers or the records of the
+	files in use under a certain directory, into a reduced dump. The reduced dump contains less information about
+	unrelated files than a complete dump, so it is a more privacy friendly artifact to share, and a mapping of the
+	original record numbers is kept alongside it.
+
+	Basic usage
+
+	Copy the selected records from the complete MFT (for example read from a volume) to the reduced dump, and write the
+	mapping of each copied record to a sidecar file using a MappingWriter.
+			// Error handling left out for brevity
+			where, err := filter.Compile("inuse and path like '/Users/*'")
+			opts := subset.Options{
+				Match: where.Match,
+				Paths: pipeline.NewPathResolver(mftReaderAt, 1024, 0),
+			}
+			mw := subset.NewMappingWriter(sidecar)
+			stats, err := subset.Copy(out, mftReader, opts, mw.Write)
+			err = mw.Flush()
+
+	The reduced dump can be parsed like any other MFT dump, for example using mft.ParseAll. Since the records are not at
+	the position of their record number anymore, use the record numbers in the record headers (or the mapping, for
+	records with a LegacyHeader) instead of their position. Paths cannot be resolved when the parent directories of a
+	record are not included.
+
+	Implementation notes
+
+	The records are read one after another from the input, so an MFT of any size is copied in bounded memory. Records
+	are copied as they are stored, including the update sequence array; they are only parsed when a Match function is
+	set, in which case records which cannot be parsed (including unused records without a signature) are left out.
+*/
+package subset
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/t9t/gomft/export"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/pipeline"
+)
+
+// Range is an inclusive range of record numbers.
+type Range struct {
+	First uint64
+	Last  uint64
+}
+
+// Contains returns true if the record number is in the Range.
+func (r Range) Contains(number uint64) bool {
+	return number >= r.First && number <= r.Last
+}
+
+// String returns the Range in the format accepted by ParseRange.
+func (r Range) String() string {
+	switch {
+	case r.First == r.Last:
+		return strconv.FormatUint(r.First, 10)
+	case r.Last == math.MaxUint64:
+		return fmt.Sprintf("%d-", r.First)
+	}
+	return fmt.Sprintf("%d-%d", r.First, r.Last)
+}
+
+// ParseRange parses a range of record numbers, which is either a single number (such as "42"), two numbers separated
+// by a dash (such as "100-200", including both) or a number followed by a dash (such as "100-", up to the end).
+func ParseRange(s string) (Range, error) {
+	dash := strings.Index(s, "-")
+	if dash < 0 {
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return Range{}, fmt.Errorf("invalid record number %q", s)
+		}
+		return Range{First: n, Last: n}, nil
+	}
+	first, err := strconv.ParseUint(s[:dash], 10, 64)
+	if err != nil {
+		return Range{}, fmt.Errorf("invalid first record number %q", s[:dash])
+	}
+	r := Range{First: first, Last: math.MaxUint64}
+	if s[dash+1:] != "" {
+		if r.Last, err = strconv.ParseUint(s[dash+1:], 10, 64); err != nil {
+			return Range{}, fmt.Errorf("invalid last record number %q", s[dash+1:])
+		}
+	}
+	if r.Last < r.First {
+		return Range{}, fmt.Errorf("last record number %d is before first record number %d", r.Last, r.First)
+	}
+	return r, nil
+}
+
+// Options configures Copy.
+type Options struct {
+	// RecordSize is the size of a single MFT record in bytes. When zero, 1024 is used.
+	RecordSize int
+	// Ranges, when not empty, limits the records to those with a record number (their position in the input) in
+	// any of the Ranges.
+	Ranges []Range
+	// Match, when not nil, limits the records to those for which it returns true. It is called with the Entry created
+	// from the record by export.FromRecord, with its Offset and (when Paths is set) its Path and PendingDelete set.
+	Match func(e export.Entry) bool
+	// Paths, when not nil, resolves the paths of the entries passed to Match. It must read the complete MFT.
+	Paths *pipeline.PathResolver
+	// Parse are the options used to parse the records. ZeroCopy is ignored, since records are copied unmodified.
+	Parse mft.ParseOptions
+}
+
+// Mapping relates a record in the reduced dump to the record in the original MFT.
+type Mapping struct {
+	// Index is the position of the record in the reduced dump.
+	Index int
+	// RecordNumber is the record number, which is the position of the record in the original MFT.
+	RecordNumber uint64
+	// SequenceNumber is the sequence number of the record, or 0 when it could not be parsed.
+	SequenceNumber uint16
+	// Offset is the offset of the record in the original MFT, in bytes.
+	Offset int64
+}
+
+// Stats contains the counts of a completed Copy.
+type Stats struct {
+	Records int // number of records read from the input
+	Copied  int // number of records copied to the reduced dump
+	Errors  int // number of records with the FILE signature which could not be parsed for Match, and were left out
+}
+
+var fileSignature = []byte("FILE")
+
+// Copy reads the records from src, which contains the complete MFT data (such as an MFT dump file), and writes those
+// selected by the Options to dst. When mapping is not nil, it is called for each copied record, after it has been
+// written. An incomplete record at the end of the input is ignored.
+func Copy(dst io.Writer, src io.Reader, opts Options, mapping func(m Mapping) error) (Stats, error) {
+	recordSize := opts.RecordSize
+	if recordSize == 0 {
+		recordSize = 1024
+	}
+	parseOpts := opts.Parse
+	parseOpts.ZeroCopy = false // the records are copied as they are stored
+	stats := Stats{}
+	buf := make([]byte, recordSize)
+	for number := uint64(0); ; number++ {
+		if _, err := io.ReadFull(src, buf); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return stats, nil
+			}
+			return stats, fmt.Errorf("unable to read record %d: %v", number, err)
+		}
+		stats.Records++
+		if !inRanges(opts.Ranges, number) {
+			continue
+		}
+		m := Mapping{Index: stats.Copied, RecordNumber: number, Offset: int64(number) * int64(recordSize)}
+		r, err := mft.ParseRecordWithOptions(buf, parseOpts)
+		if err == nil {
+			if r.LegacyHeader {
+				r.FileReference.RecordNumber = number
+			}
+			m.SequenceNumber = r.FileReference.SequenceNumber
+		}
+		if opts.Match != nil {
+			if err != nil {
+				// Unused records which do not even have a signature are not worth reporting
+				if bytes.HasPrefix(buf, fileSignature) {
+					stats.Errors++
+				}
+				continue
+			}
+			e := export.FromRecord(r)
+			e.Offset = m.Offset
+			if opts.Paths != nil {
+				if e.PendingDelete, err = opts.Paths.IsPendingDelete(e); err == nil {
+					e.Path, err = opts.Paths.Path(e)
+				}
+				if err != nil {
+					return stats, fmt.Errorf("unable to resolve path of record %d: %v", number, err)
+				}
+			}
+			if !opts.Match(e) {
+				continue
+			}
+		}
+
+		if _, err := dst.Write(buf); err != nil {
+			return stats, fmt.Errorf("unable to write record %d: %v", number, err)
+		}
+		stats.Copied++
+		if mapping != nil {
+			if err := mapping(m); err != nil {
+				return stats, fmt.Errorf("unable to process record %d: %v", number, err)
+			}
+		}
+	}
+}
+
+func inRanges(ranges []Range, number uint64) bool {
+	if len(ranges) == 0 {
+		return true
+	}
+	for _, r := range ranges {
+		if r.Contains(number) {
+			return true
+		}
+	}
+	return false
+}
+
+// mappingHeader is the header of the CSV written by MappingWriter.
+var mappingHeader = []string{"index", "record_number", "sequence_number", "offset"}
+
+// MappingWriter writes Mappings as CSV, with a header row followed by a row per Mapping, for example:
+//
+//	index,record_number,sequence_number,offset
+//	0,5,5,5120
+//	1,64,2,65536
+type MappingWriter struct {
+	w      *csv.Writer
+	header bool
+}
+
+// NewMappingWriter creates a MappingWriter writing to w.
+func NewMappingWriter(w io.Writer) *MappingWriter {
+	return &MappingWriter{w: csv.NewWriter(w)}
+}
+
+// Write writes a single Mapping, preceded by the header when it is the first.
+func (w *MappingWriter) Write(m Mapping) error {
+	if !w.header {
+		if err := w.w.Write(mappingHeader); err != nil {
+			return err
+		}
+		w.header = true
+	}
+	return w.w.Write([]string{
+		strconv.Itoa(m.Index),
+		strconv.FormatUint(m.RecordNumber, 10),
+		strconv.FormatUint(uint64(m.SequenceNumber), 10),
+		strconv.FormatInt(m.Offset, 10),
+	})
+}
+
+// Flush writes the header when no Mappings have been written, and flushes any buffered data to the underlying
+// io.Writer.
+func (w *MappingWriter) Flush() error {
+	if !w.header {
+		if err := w.w.Write(mappingHeader); err != nil {
+			return err
+		}
+		w.header = true
+	}
+	w.w.Flush()
+	return w.w.Error()
+}
+
+// ReadMappings reads the Mappings written by a MappingWriter.
+func ReadMappings(r io.Reader) ([]Mapping, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 || strings.Join(rows[0], ",") != strings.Join(mappingHeader, ",") {
+		return nil, fmt.Errorf("expected header %q", strings.Join(mappingHeader, ","))
+	}
+	mappings := make([]Mapping, 0, len(rows)-1)
+	for i, row := range rows[1:] {
+		index, err1 := strconv.Atoi(row[0])
+		number, err2 := strconv.ParseUint(row[1], 10, 64)
+		sequence, err3 := strconv.ParseUint(row[2], 10, 16)
+		offset, err4 := strconv.ParseInt(row[3], 10, 64)
+		if err1 != nil || err2 != nil || err3 != nil || err4 != nil {
+			return nil, fmt.Errorf("invalid mapping on line %d", i+2)
+		}
+		mappings = append(mappings, Mapping{Index: index, RecordNumber: number, SequenceNumber: uint16(sequence), Offset: offset})
+	}
+	return mappings, nil
+}
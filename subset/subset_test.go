@@ -0,0 +1,126 @@
+package subset_test
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/export"
+	"github.com/t9t/gomft/filter"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/mfttest"
+	"github.com/t9t/gomft/pipeline"
+	"github.com/t9t/gomft/subset"
+)
+
+func testMft() ([]byte, map[string]uint64) {
+	v := mfttest.NewVolume().
+		WithDirectory("/Users/bob").
+		WithFile("/Users/bob/notes.txt", []byte("notes")).
+		WithFile("/Windows/win.ini", []byte("[fonts]")).
+		Build()
+	return v.Mft(), v.Records
+}
+
+func TestCopy_Match(t *testing.T) {
+	data, records := testMft()
+	where, err := filter.Compile("inuse and path like '/Users/*'")
+	require.Nil(t, err)
+	opts := subset.Options{Match: where.Match, Paths: pipeline.NewPathResolver(bytes.NewReader(data), 1024, 0)}
+
+	out := &bytes.Buffer{}
+	mappings := make([]subset.Mapping, 0)
+	stats, err := subset.Copy(out, bytes.NewReader(data), opts, func(m subset.Mapping) error {
+		mappings = append(mappings, m)
+		return nil
+	})
+	require.Nilf(t, err, "unable to copy: %v", err)
+	assert.Equal(t, len(data)/1024, stats.Records)
+	assert.Equal(t, 2, stats.Copied)
+
+	bob, notes := records["/Users/bob"], records["/Users/bob/notes.txt"]
+	require.Equal(t, 2, len(mappings))
+	assert.Equal(t, subset.Mapping{Index: 0, RecordNumber: bob, SequenceNumber: 1, Offset: int64(bob) * 1024}, mappings[0])
+	assert.Equal(t, subset.Mapping{Index: 1, RecordNumber: notes, SequenceNumber: 1, Offset: int64(notes) * 1024}, mappings[1])
+	assert.Equal(t, data[bob*1024:(bob+1)*1024], out.Bytes()[:1024], "records are copied as they are stored")
+	assert.Equal(t, data[notes*1024:(notes+1)*1024], out.Bytes()[1024:])
+
+	r, err := mft.ParseRecord(out.Bytes()[1024:])
+	require.Nil(t, err)
+	assert.Equal(t, "notes.txt", export.FromRecord(r).Name)
+}
+
+func TestCopy_Ranges(t *testing.T) {
+	data, _ := testMft()
+	data = append(data, make([]byte, 1024)...) // an empty record, which is never matched
+	copy(data[len(data)-2048:], "FILE")        // and a broken record which cannot be parsed
+	last := uint64(len(data)/1024 - 1)
+	opts := subset.Options{Ranges: []subset.Range{{First: 0, Last: 1}, {First: 5, Last: 5}, {First: last - 1, Last: math.MaxUint64}}}
+
+	out := &bytes.Buffer{}
+	numbers := make([]uint64, 0)
+	stats, err := subset.Copy(out, bytes.NewReader(data), opts, func(m subset.Mapping) error {
+		numbers = append(numbers, m.RecordNumber)
+		return nil
+	})
+	require.Nilf(t, err, "unable to copy: %v", err)
+	assert.Equal(t, subset.Stats{Records: int(last) + 1, Copied: 5}, stats)
+	assert.Equal(t, []uint64{0, 1, 5, last - 1, last}, numbers)
+	assert.Equal(t, 5*1024, out.Len())
+
+	// Records which cannot be parsed are left out when matching, but only reported when they have a signature
+	opts.Match = func(e export.Entry) bool { return true }
+	stats, err = subset.Copy(&bytes.Buffer{}, bytes.NewReader(data), opts, nil)
+	require.Nil(t, err)
+	assert.Equal(t, subset.Stats{Records: int(last) + 1, Copied: 3, Errors: 1}, stats)
+
+	_, err = subset.Copy(&bytes.Buffer{}, bytes.NewReader(data), opts, func(m subset.Mapping) error {
+		return errors.New("stop")
+	})
+	assert.EqualError(t, err, "unable to process record 0: stop")
+}
+
+func TestParseRange(t *testing.T) {
+	for s, expected := range map[string]subset.Range{
+		"42":      {First: 42, Last: 42},
+		"100-200": {First: 100, Last: 200},
+		"100-":    {First: 100, Last: math.MaxUint64},
+	} {
+		r, err := subset.ParseRange(s)
+		require.Nilf(t, err, "unable to parse %q: %v", s, err)
+		assert.Equal(t, expected, r)
+		assert.Equal(t, s, r.String())
+	}
+	for _, s := range []string{"", "a", "-5", "5-a", "200-100"} {
+		_, err := subset.ParseRange(s)
+		assert.NotNilf(t, err, "expected error for %q", s)
+	}
+}
+
+func TestMappingWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := subset.NewMappingWriter(buf)
+	mappings := []subset.Mapping{{Index: 0, RecordNumber: 5, SequenceNumber: 5, Offset: 5120}, {Index: 1, RecordNumber: 64, SequenceNumber: 2, Offset: 65536}}
+	for _, m := range mappings {
+		require.Nil(t, w.Write(m))
+	}
+	require.Nil(t, w.Flush())
+	assert.Equal(t, "index,record_number,sequence_number,offset\n0,5,5,5120\n1,64,2,65536\n", buf.String())
+
+	read, err := subset.ReadMappings(buf)
+	require.Nil(t, err)
+	assert.Equal(t, mappings, read)
+
+	// Without mappings, only the header is written
+	buf.Reset()
+	require.Nil(t, subset.NewMappingWriter(buf).Flush())
+	read, err = subset.ReadMappings(buf)
+	require.Nil(t, err)
+	assert.Empty(t, read)
+
+	_, err = subset.ReadMappings(bytes.NewReader([]byte("a,b\n")))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,295 @@
+/*
+	Package summary computes the statistics of an MFT that a triage report usually starts with, such as the number of
+	files and directories in use and deleted, the attributes by type and residency, the file extensions, the alternate
+	data streams and the distribution of file sizes, in a single pass over the records.
+
+	Basic usage
+
+	Use Analyze to read all records of an MFT and summarize them.
+			// Error handling left out for brevity
+			s, err := summary.Analyze(in, mft.ParseAllOptions{RecordSize: 1024})
+			fmt.Printf("%d files in use, %d deleted\n", s.Files, s.DeletedFiles)
+			fmt.Printf("%.0f%% of $DATA attributes resident\n", s.Attributes[mft.AttributeTypeData].ResidentRatio()*100)
+
+	When the records are already parsed for something else, add them to an Analyzer instead, to avoid parsing them
+	again.
+			a := summary.NewAnalyzer()
+			for result := range mft.ParseAll(in, mft.ParseAllOptions{}) {
+				if result.Err == nil {
+					a.Add(result.Record)
+				}
+			}
+			s := a.Summary()
+
+	To find the records with the highest value of a Metric, such as the largest or most fragmented files, add a Top to
+	a pipeline (after pipeline.Paths when ranking by MetricDepth).
+			top := summary.NewTop(summary.MetricExtents, 10)
+			stats, err := pipeline.Run(in, pipeline.Options{}, top)
+			for _, r := range top.Results() {
+				fmt.Println(r.Value, r.Entry.RecordNumber, r.Entry.Name)
+			}
+
+	Implementation notes
+
+	Only the running totals are kept, so memory use does not depend on the size of the MFT (except for the number of
+	distinct file extensions and of directories containing files). Because records are not kept, a record is summarized
+	using only the attributes in that record: the attributes of extension records are counted as attributes, but not
+	attributed to their base record.
+
+	Files are the base records which are not directories. Their extension is taken from the preferred name (see
+	mft.Record.PreferredFileName), in lower case and without the dot; names without a dot (or only a leading dot) have
+	an empty extension. The size of a file is the size of its unnamed $DATA attribute. When that attribute was moved to
+	an extension record, the extension record containing its first part (the only part with a size) counts as the file
+	in the size distribution instead. Alternate data streams are the named $DATA attributes.
+
+	Files in $Extend\$Deleted are pending deletion (see mftindex.DeletedDirectoryName). Their record is still in use,
+	so they are included in the extensions, streams and sizes of files in use, but they are counted as deleted files.
+	The record number of $Deleted differs per volume and its record may come after the files in it, so a file cannot
+	be recognized as pending deletion when it is added. Instead, the files in use are counted by parent directory and
+	the count of $Deleted is taken once all records have been added. This count per directory is the only part of
+	the Analyzer which grows with the MFT; it takes a few dozen bytes per directory containing files, which is far
+	less than keeping the records or an mftindex.Index.
+
+	Top keeps the N highest ranked records in a heap, so ranking takes O(log N) per record and memory is bounded by N.
+	Like the Summary, the extents are counted only over the data runs in the record itself.
+*/
+package summary
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/mftindex"
+)
+
+// DefaultSizeBuckets are the upper bounds of the buckets of the size distribution used by NewAnalyzer: empty files,
+// up to 1 KiB, 64 KiB, 1 MiB, 16 MiB, 256 MiB, 4 GiB and anything larger.
+var DefaultSizeBuckets = []uint64{0, 1 << 10, 64 << 10, 1 << 20, 16 << 20, 256 << 20, 4 << 30, math.MaxUint64}
+
+// Summary contains the statistics of the records of an MFT.
+type Summary struct {
+	// Records is the number of records summarized, including extension records.
+	Records int
+	// Errors is the number of records which could not be parsed (only counted by Analyze).
+	Errors int
+	// Files and Directories are the number of base records in use; DeletedFiles and DeletedDirectories the number of
+	// base records not in use. Files pending deletion are counted as deleted.
+	Files              int
+	Directories        int
+	DeletedFiles       int
+	DeletedDirectories int
+	// PendingDeletes is the number of files in use in $Extend\$Deleted: files deleted while still open, which are
+	// removed when the last handle to them is closed (see mftindex.DeletedDirectoryName).
+	PendingDeletes int
+	// ExtensionRecords is the number of records containing attributes of another (base) record.
+	ExtensionRecords int
+	// Attributes contains the number of attributes by type, over all records.
+	Attributes map[mft.AttributeType]AttributeStats
+	// Extensions contains the number of files in use by (lower case) file extension.
+	Extensions map[string]int
+	// AlternateDataStreams is the number of named $DATA attributes of files in use, and FilesWithAlternateDataStreams
+	// the number of such files having any.
+	AlternateDataStreams          int
+	FilesWithAlternateDataStreams int
+	// Size and AllocatedSize are the total sizes of the unnamed $DATA attributes of the files in use.
+	Size          uint64
+	AllocatedSize uint64
+	// Sizes is the distribution of the sizes of the files in use.
+	Sizes []SizeBucket
+}
+
+// AttributeStats contains the number of attributes of a single type.
+type AttributeStats struct {
+	Resident    int
+	NonResident int
+}
+
+// Count returns the total number of attributes.
+func (s AttributeStats) Count() int {
+	return s.Resident + s.NonResident
+}
+
+// ResidentRatio returns the fraction of attributes which are resident, between 0 and 1. It is 0 when there are no
+// attributes.
+func (s AttributeStats) ResidentRatio() float64 {
+	if s.Count() == 0 {
+		return 0
+	}
+	return float64(s.Resident) / float64(s.Count())
+}
+
+// SizeBucket is a bucket of the size distribution, counting the files with a size greater than the Max of the
+// previous bucket and at most Max.
+type SizeBucket struct {
+	Max   uint64
+	Files int
+	Size  uint64
+}
+
+// Analyzer computes a Summary from records added one by one. An Analyzer is not safe for concurrent use.
+type Analyzer struct {
+	s Summary
+	// filesByParent counts the files in use by parent directory, and deletedDirectories are the references of the
+	// $Extend\$Deleted directories found, to count the PendingDeletes when the parent follows the files in the MFT.
+	filesByParent      map[mft.FileReference]int
+	deletedDirectories []mft.FileReference
+}
+
+// NewAnalyzer creates an Analyzer using the DefaultSizeBuckets.
+func NewAnalyzer() *Analyzer {
+	return NewAnalyzerWithSizeBuckets(DefaultSizeBuckets)
+}
+
+// NewAnalyzerWithSizeBuckets creates an Analyzer with a size distribution using the specified upper bounds, which must
+// be in ascending order. Sizes larger than the last bound are not included in the distribution, so use math.MaxUint64
+// as the last bound to include all files.
+func NewAnalyzerWithSizeBuckets(bounds []uint64) *Analyzer {
+	sizes := make([]SizeBucket, len(bounds))
+	for i, max := range bounds {
+		sizes[i].Max = max
+	}
+	return &Analyzer{s: Summary{
+		Attributes: make(map[mft.AttributeType]AttributeStats),
+		Extensions: make(map[string]int),
+		Sizes:      sizes,
+	}, filesByParent: make(map[mft.FileReference]int)}
+}
+
+// Add adds a record to the Summary.
+func (a *Analyzer) Add(r mft.Record) {
+	s := &a.s
+	s.Records++
+	for _, attr := range r.Attributes {
+		stats := s.Attributes[attr.Type]
+		if attr.Resident {
+			stats.Resident++
+		} else {
+			stats.NonResident++
+		}
+		s.Attributes[attr.Type] = stats
+	}
+
+	if r.IsExtension() {
+		s.ExtensionRecords++
+		if r.IsInUse() {
+			// The first part of a non-resident unnamed $DATA attribute moved out of the base record
+			if data, ok := unnamedData(r); ok && !data.Resident && data.AllocatedSize > 0 {
+				a.addSize(data)
+			}
+		}
+		return
+	}
+
+	directory := r.IsDirectory()
+	if !r.IsInUse() {
+		if directory {
+			s.DeletedDirectories++
+		} else {
+			s.DeletedFiles++
+		}
+		return
+	}
+	fn, hasName := r.PreferredFileName()
+	if directory {
+		s.Directories++
+		if hasName && fn.Name == mftindex.DeletedDirectoryName &&
+			fn.ParentFileReference.RecordNumber == mftindex.ExtendRecordNumber {
+			a.deletedDirectories = append(a.deletedDirectories, r.FileReference)
+		}
+		return
+	}
+	s.Files++
+	if hasName {
+		s.Extensions[extension(fn.Name)]++
+		a.filesByParent[fn.ParentFileReference]++
+	}
+	streams := 0
+	for _, attr := range r.FindAttributes(mft.AttributeTypeData) {
+		if attr.Name != "" {
+			streams++
+		}
+	}
+	if streams > 0 {
+		s.AlternateDataStreams += streams
+		s.FilesWithAlternateDataStreams++
+	}
+	if data, ok := unnamedData(r); ok {
+		a.addSize(data)
+	}
+}
+
+func (a *Analyzer) addSize(data mft.Attribute) {
+	size, allocatedSize := data.ActualSize, data.AllocatedSize
+	if data.Resident {
+		size, allocatedSize = uint64(len(data.Data)), uint64(len(data.Data))
+	}
+	a.s.Size += size
+	a.s.AllocatedSize += allocatedSize
+	for i := range a.s.Sizes {
+		if size <= a.s.Sizes[i].Max {
+			a.s.Sizes[i].Files++
+			a.s.Sizes[i].Size += size
+			break
+		}
+	}
+}
+
+// Summary returns the Summary of the records added so far. The Analyzer can still be used afterwards; the returned
+// Summary is not affected by records added later.
+func (a *Analyzer) Summary() Summary {
+	s := a.s
+	s.Attributes = make(map[mft.AttributeType]AttributeStats, len(a.s.Attributes))
+	for k, v := range a.s.Attributes {
+		s.Attributes[k] = v
+	}
+	s.Extensions = make(map[string]int, len(a.s.Extensions))
+	for k, v := range a.s.Extensions {
+		s.Extensions[k] = v
+	}
+	s.Sizes = append([]SizeBucket(nil), a.s.Sizes...)
+	for _, ref := range a.deletedDirectories {
+		s.PendingDeletes += a.filesByParent[ref]
+	}
+	s.Files -= s.PendingDeletes
+	s.DeletedFiles += s.PendingDeletes
+	return s
+}
+
+// Analyze reads and parses all records from r using mft.ParseAll and returns their Summary. Records which cannot be
+// parsed are counted as Errors; empty records are skipped. An error is returned only when reading fails.
+func Analyze(r io.Reader, opts mft.ParseAllOptions) (Summary, error) {
+	opts.SkipEmpty = true
+	a := NewAnalyzer()
+	for result := range mft.ParseAll(r, opts) {
+		if result.Err != nil {
+			if readErr, ok := result.Err.(*mft.ReadError); ok {
+				return a.Summary(), fmt.Errorf("unable to read record %d: %v", result.Index, readErr.Err)
+			}
+			a.s.Errors++
+			continue
+		}
+		a.Add(result.Record)
+	}
+	return a.Summary(), nil
+}
+
+func unnamedData(r mft.Record) (mft.Attribute, bool) {
+	for _, attr := range r.FindAttributes(mft.AttributeTypeData) {
+		if attr.Name == "" {
+			return attr, true
+		}
+	}
+	return mft.Attribute{}, false
+}
+
+// extension returns the lower case extension of the name without the dot, or an empty string if it has none.
+func extension(name string) string {
+	i := strings.LastIndexByte(name, '.')
+	if i <= 0 {
+		return ""
+	}
+	return strings.ToLower(name[i+1:])
+}
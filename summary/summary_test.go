@@ -0,0 +1,142 @@
+package summary_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/mfttest"
+	"github.com/t9t/gomft/summary"
+)
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("broken disk")
+}
+
+func testRecords() []*mfttest.RecordBuilder {
+	return []*mfttest.RecordBuilder{
+		mfttest.NewRecord().WithRecordNumber(5).WithDirectory().WithStandardInformation(0).WithFileName("."),
+		mfttest.NewRecord().WithRecordNumber(30).WithDirectory().WithStandardInformation(0).WithFileName("Windows"),
+		mfttest.NewRecord().WithRecordNumber(40).WithStandardInformation(0).WithFileName("notepad.EXE").
+			WithNonResidentData(200000, mft.DataRun{OffsetCluster: 100, LengthInClusters: 49}),
+		mfttest.NewRecord().WithRecordNumber(41).WithStandardInformation(0).WithFileName("readme.txt").
+			WithResidentData(make([]byte, 100)).WithStream("Zone.Identifier", []byte("[ZoneTransfer]")).WithStream("x", nil),
+		mfttest.NewRecord().WithRecordNumber(42).WithStandardInformation(0).WithFileName(".profile").WithResidentData(nil),
+		mfttest.NewRecord().WithRecordNumber(43).WithFlags(0).WithStandardInformation(0).WithFileName("old.txt").
+			WithResidentData([]byte("old")),
+		mfttest.NewRecord().WithRecordNumber(44).WithFlags(0).WithDirectory().WithStandardInformation(0).WithFileName("Temp"),
+		// Base record of a file of which the $DATA attribute was moved to extension record 46
+		mfttest.NewRecord().WithRecordNumber(45).WithStandardInformation(0).WithFileName("big.iso").
+			WithAttribute(mft.Attribute{Type: mft.AttributeTypeAttributeList, Resident: true, Data: make([]byte, 32)}),
+		mfttest.NewRecord().WithRecordNumber(46).WithBaseRecord(mft.FileReference{RecordNumber: 45, SequenceNumber: 1}).
+			WithNonResidentData(5<<30, mft.DataRun{OffsetCluster: 1000, LengthInClusters: 5 << 18}),
+	}
+}
+
+func TestAnalyzer(t *testing.T) {
+	a := summary.NewAnalyzer()
+	for _, b := range testRecords() {
+		a.Add(b.Record())
+	}
+	s := a.Summary()
+
+	assert.Equal(t, 9, s.Records)
+	assert.Equal(t, 0, s.Errors)
+	assert.Equal(t, 4, s.Files)
+	assert.Equal(t, 2, s.Directories)
+	assert.Equal(t, 1, s.DeletedFiles)
+	assert.Equal(t, 1, s.DeletedDirectories)
+	assert.Equal(t, 1, s.ExtensionRecords)
+	assert.Equal(t, map[mft.AttributeType]summary.AttributeStats{
+		mft.AttributeTypeStandardInformation: {Resident: 8},
+		mft.AttributeTypeFileName:            {Resident: 8},
+		mft.AttributeTypeAttributeList:       {Resident: 1},
+		mft.AttributeTypeData:                {Resident: 5, NonResident: 2},
+	}, s.Attributes)
+	assert.Equal(t, map[string]int{"exe": 1, "txt": 1, "": 1, "iso": 1}, s.Extensions)
+	assert.Equal(t, 2, s.AlternateDataStreams)
+	assert.Equal(t, 1, s.FilesWithAlternateDataStreams)
+	assert.Equal(t, uint64(200000+100+5<<30), s.Size)
+	assert.Equal(t, uint64(49*4096+100+5<<30), s.AllocatedSize)
+	assert.Equal(t, []summary.SizeBucket{
+		{Max: 0, Files: 1},
+		{Max: 1 << 10, Files: 1, Size: 100},
+		{Max: 64 << 10},
+		{Max: 1 << 20, Files: 1, Size: 200000},
+		{Max: 16 << 20},
+		{Max: 256 << 20},
+		{Max: 4 << 30},
+		{Max: 1<<64 - 1, Files: 1, Size: 5 << 30},
+	}, s.Sizes)
+}
+
+func TestAttributeStats_ResidentRatio(t *testing.T) {
+	assert.Equal(t, 0.25, summary.AttributeStats{Resident: 1, NonResident: 3}.ResidentRatio())
+	assert.Equal(t, 4, summary.AttributeStats{Resident: 1, NonResident: 3}.Count())
+	assert.Equal(t, 0.0, summary.AttributeStats{}.ResidentRatio())
+}
+
+func TestAnalyzer_Summary(t *testing.T) {
+	a := summary.NewAnalyzerWithSizeBuckets([]uint64{10})
+	a.Add(mfttest.NewRecord().WithFileName("a.txt").WithResidentData([]byte("hello")).Record())
+	s := a.Summary()
+	a.Add(mfttest.NewRecord().WithFileName("b.txt").WithResidentData([]byte("hello world")).Record())
+
+	assert.Equal(t, 1, s.Files)
+	assert.Equal(t, map[string]int{"txt": 1}, s.Extensions)
+	assert.Equal(t, []summary.SizeBucket{{Max: 10, Files: 1, Size: 5}}, s.Sizes)
+
+	s = a.Summary()
+	assert.Equal(t, 2, s.Files)
+	assert.Equal(t, uint64(16), s.Size)
+	assert.Equal(t, []summary.SizeBucket{{Max: 10, Files: 1, Size: 5}}, s.Sizes, "sizes beyond the last bucket are left out")
+}
+
+func TestAnalyzer_PendingDeletes(t *testing.T) {
+	deleted := mft.FileReference{RecordNumber: 50, SequenceNumber: 2}
+	a := summary.NewAnalyzer()
+	// Files come before their parent directory
+	a.Add(mfttest.NewRecord().WithRecordNumber(60).WithParent(deleted).WithFileName("0000000000000028A4C1F2E3").Record())
+	a.Add(mfttest.NewRecord().WithRecordNumber(61).WithParent(mft.FileReference{RecordNumber: 50, SequenceNumber: 1}).
+		WithFileName("stale.txt").Record())
+	a.Add(mfttest.NewRecord().WithRecordNumber(62).WithFileName("a.txt").Record())
+	a.Add(mfttest.NewRecord().WithRecordNumber(50).WithSequenceNumber(2).WithDirectory().
+		WithParent(mft.FileReference{RecordNumber: 11, SequenceNumber: 11}).WithFileName("$Deleted").Record())
+
+	s := a.Summary()
+	assert.Equal(t, 1, s.PendingDeletes)
+	assert.Equal(t, 2, s.Files)
+	assert.Equal(t, 1, s.DeletedFiles)
+	assert.Equal(t, 1, s.Directories)
+}
+
+func TestAnalyze(t *testing.T) {
+	var data []byte
+	for _, b := range testRecords()[:3] {
+		data = append(data, b.Bytes()...)
+	}
+	data = append(data, make([]byte, 1024)...)
+	broken := mfttest.NewRecord().WithFileName("broken").Bytes()
+	broken[510] ^= 0xFF
+	data = append(data, broken...)
+
+	s, err := summary.Analyze(bytes.NewReader(data), mft.ParseAllOptions{})
+	require.Nilf(t, err, "unable to analyze: %v", err)
+	assert.Equal(t, 3, s.Records)
+	assert.Equal(t, 1, s.Errors)
+	assert.Equal(t, 1, s.Files)
+	assert.Equal(t, 2, s.Directories)
+
+	s, err = summary.Analyze(bytes.NewReader(append(data, make([]byte, 10)...)), mft.ParseAllOptions{})
+	require.Nilf(t, err, "unable to analyze: %v", err)
+	assert.Equal(t, 2, s.Errors, "incomplete record")
+
+	_, err = summary.Analyze(io.MultiReader(bytes.NewReader(data[:1024]), failingReader{}), mft.ParseAllOptions{})
+	assert.EqualError(t, err, "unable to read record 1: broken disk")
+}
@@ -0,0 +1,150 @@
+package summary
+
+import (
+	"container/heap"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/t9t/gomft/export"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/mftindex"
+	"github.com/t9t/gomft/pipeline"
+)
+
+// Metric is the value by which Top ranks records.
+type Metric int
+
+// Metrics supported by Top.
+const (
+	MetricAllocatedSize Metric = iota // the allocated size of the file (see export.Entry)
+	MetricExtents                     // the number of extents of all $DATA attributes in the record (see mft.RecordFragmentation)
+	MetricStreams                     // the number of alternate data streams (named $DATA attributes)
+	MetricDepth                       // the number of directories in the path; requires paths (see pipeline.Paths)
+)
+
+var metricNames = []string{"allocated", "extents", "streams", "depth"}
+
+func (m Metric) String() string {
+	if m < 0 || int(m) >= len(metricNames) {
+		return fmt.Sprintf("Metric(%d)", int(m))
+	}
+	return metricNames[m]
+}
+
+// ParseMetric returns the Metric with the specified name, as returned by Metric.String (such as "extents").
+func ParseMetric(name string) (Metric, error) {
+	for i, n := range metricNames {
+		if n == name {
+			return Metric(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown metric %q, expected one of: %s", name, strings.Join(metricNames, ", "))
+}
+
+// Value returns the value of the Metric for the Item.
+func (m Metric) Value(item *pipeline.Item) uint64 {
+	switch m {
+	case MetricAllocatedSize:
+		return item.Entry.AllocatedSize
+	case MetricExtents:
+		// Records of which the data runs cannot be parsed are ranked by the streams that could be parsed
+		streams, _ := mft.RecordFragmentation(item.Record)
+		extents := 0
+		for _, s := range streams {
+			if s.Type == mft.AttributeTypeData {
+				extents += s.Stats.Extents
+			}
+		}
+		return uint64(extents)
+	case MetricStreams:
+		return uint64(len(item.Entry.AlternateDataStreams))
+	case MetricDepth:
+		if item.Path == "" || item.Path == "/" {
+			return 0
+		}
+		return uint64(strings.Count(strings.TrimPrefix(item.Path, mftindex.OrphanPrefix), "/"))
+	}
+	return 0
+}
+
+// Ranked is an entry in the result of Top, with the value of the Metric it was ranked by. The Path of the Entry is
+// set to the Path of the Item.
+type Ranked struct {
+	Value uint64
+	Entry export.Entry
+}
+
+// Top keeps the N records with the highest value of a Metric, such as the largest or most fragmented files. Records
+// with a value of 0 are not ranked; records with equal values are ranked by record number. Since it only keeps N
+// entries, any number of records can be added. A Top is not safe for concurrent use.
+//
+// Top is a pipeline.Stage which passes all Items, so it can be added to a pipeline to rank the records passed by the
+// stages before it.
+type Top struct {
+	metric Metric
+	n      int
+	ranked rankedHeap
+}
+
+// NewTop creates a Top keeping the n records with the highest value of the metric.
+func NewTop(metric Metric, n int) *Top {
+	return &Top{metric: metric, n: n}
+}
+
+// Add ranks the record of the Item.
+func (t *Top) Add(item *pipeline.Item) {
+	if t.n <= 0 {
+		return
+	}
+	value := t.metric.Value(item)
+	if value == 0 {
+		return
+	}
+	if len(t.ranked) == t.n {
+		lowest := t.ranked[0]
+		if value < lowest.Value || (value == lowest.Value && item.Entry.RecordNumber > lowest.Entry.RecordNumber) {
+			return
+		}
+		heap.Pop(&t.ranked)
+	}
+	e := item.Entry
+	e.Path = item.Path
+	heap.Push(&t.ranked, Ranked{Value: value, Entry: e})
+}
+
+// Process adds the Item and passes it on.
+func (t *Top) Process(item *pipeline.Item) (bool, error) {
+	t.Add(item)
+	return true, nil
+}
+
+// Results returns the ranked records, with the highest value first.
+func (t *Top) Results() []Ranked {
+	results := append([]Ranked(nil), t.ranked...)
+	sort.Slice(results, func(i, j int) bool {
+		return rankedBefore(results[i], results[j])
+	})
+	return results
+}
+
+func rankedBefore(a, b Ranked) bool {
+	if a.Value != b.Value {
+		return a.Value > b.Value
+	}
+	return a.Entry.RecordNumber < b.Entry.RecordNumber
+}
+
+// rankedHeap is a heap with the lowest ranked entry first.
+type rankedHeap []Ranked
+
+func (h rankedHeap) Len() int            { return len(h) }
+func (h rankedHeap) Less(i, j int) bool  { return rankedBefore(h[j], h[i]) }
+func (h rankedHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *rankedHeap) Push(x interface{}) { *h = append(*h, x.(Ranked)) }
+func (h *rankedHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
@@ -0,0 +1,85 @@
+package summary_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/export"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/mfttest"
+	"github.com/t9t/gomft/pipeline"
+	"github.com/t9t/gomft/summary"
+)
+
+func item(number uint64, path string, b *mfttest.RecordBuilder) *pipeline.Item {
+	r := b.WithRecordNumber(number).Record()
+	return &pipeline.Item{Record: r, Entry: export.FromRecord(r), Path: path}
+}
+
+func rankedNumbers(ranked []summary.Ranked) []uint64 {
+	numbers := make([]uint64, 0)
+	for _, r := range ranked {
+		numbers = append(numbers, r.Entry.RecordNumber)
+	}
+	return numbers
+}
+
+func TestTop(t *testing.T) {
+	items := []*pipeline.Item{
+		item(40, "/a.txt", mfttest.NewRecord().WithFileName("a.txt").
+			WithNonResidentData(10000, mft.DataRun{OffsetCluster: 100, LengthInClusters: 3})),
+		item(41, "/Windows/b.txt", mfttest.NewRecord().WithFileName("b.txt").
+			WithNonResidentData(20000, mft.DataRun{OffsetCluster: 200, LengthInClusters: 2}, mft.DataRun{OffsetCluster: 10, LengthInClusters: 3})),
+		item(42, "/Windows/System32/c.txt", mfttest.NewRecord().WithFileName("c.txt").
+			WithNonResidentData(1000, mft.DataRun{OffsetCluster: 300, LengthInClusters: 1}, mft.DataRun{OffsetCluster: 10, LengthInClusters: 1},
+				mft.DataRun{OffsetCluster: 10, LengthInClusters: 1}).
+			WithStream("one", nil).WithStream("two", nil)),
+		item(43, "/$Orphan/d.txt", mfttest.NewRecord().WithFileName("d.txt").WithResidentData([]byte("d")).WithStream("one", nil)),
+		item(44, "/Windows/e.txt", mfttest.NewRecord().WithFileName("e.txt").
+			WithNonResidentData(10000, mft.DataRun{OffsetCluster: 400, LengthInClusters: 4})),
+	}
+	rank := func(metric summary.Metric, n int) []summary.Ranked {
+		top := summary.NewTop(metric, n)
+		for _, i := range items {
+			ok, err := top.Process(i)
+			require.Nil(t, err)
+			require.True(t, ok)
+		}
+		return top.Results()
+	}
+
+	ranked := rank(summary.MetricAllocatedSize, 3)
+	assert.Equal(t, []uint64{41, 44, 40}, rankedNumbers(ranked), "equal values are ranked by record number")
+	assert.Equal(t, uint64(3*4096), ranked[2].Value)
+	assert.Equal(t, "/Windows/e.txt", ranked[1].Entry.Path)
+
+	assert.Equal(t, []uint64{42, 41}, rankedNumbers(rank(summary.MetricExtents, 2)))
+	assert.Equal(t, []uint64{42, 43}, rankedNumbers(rank(summary.MetricStreams, 5)), "files without streams are not ranked")
+	ranked = rank(summary.MetricDepth, 3)
+	assert.Equal(t, []uint64{42, 41, 44}, rankedNumbers(ranked))
+	assert.Equal(t, []uint64{3, 2, 2}, []uint64{ranked[0].Value, ranked[1].Value, ranked[2].Value})
+	assert.Empty(t, rank(summary.MetricDepth, 0))
+}
+
+func TestTop_Pipeline(t *testing.T) {
+	var data []byte
+	for _, b := range testRecords() {
+		data = append(data, b.Bytes()...)
+	}
+	top := summary.NewTop(summary.MetricAllocatedSize, 2)
+	_, err := pipeline.Run(bytes.NewReader(data), pipeline.Options{}, top)
+	require.Nilf(t, err, "unable to run pipeline: %v", err)
+	assert.Equal(t, []uint64{46, 40}, rankedNumbers(top.Results()))
+}
+
+func TestParseMetric(t *testing.T) {
+	for _, m := range []summary.Metric{summary.MetricAllocatedSize, summary.MetricExtents, summary.MetricStreams, summary.MetricDepth} {
+		parsed, err := summary.ParseMetric(m.String())
+		require.Nilf(t, err, "unable to parse metric %v: %v", m, err)
+		assert.Equal(t, m, parsed)
+	}
+	_, err := summary.ParseMetric("size")
+	assert.EqualError(t, err, `unknown metric "size", expected one of: allocated, extents, streams, depth`)
+}
@@ -0,0 +1,112 @@
+package timeline
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/t9t/gomft/export"
+)
+
+var l2tcsvHeader = []string{
+	"date", "time", "timezone", "MACB", "source", "sourcetype", "type", "user", "host", "short", "desc", "version",
+	"filename", "inode", "notes", "format", "extra",
+}
+
+var l2tcsvSanitizer = strings.NewReplacer(",", " ", "\r", " ", "\n", " ")
+
+// L2TCSVWriter writes Events in the l2tcsv format of log2timeline and plaso (psort -o l2tcsv), so they can be merged
+// with a plaso super-timeline. Like plaso, fields are not quoted; commas and line breaks in values are replaced by
+// spaces. Times are written in UTC with second precision; the full time is part of the desc column. Missing values
+// are written as "-".
+//
+// The short and filename columns contain the Path of the Entry, or its Name when the Path is not known.
+type L2TCSVWriter struct {
+	w             *bufio.Writer
+	host          string
+	headerWritten bool
+}
+
+// NewL2TCSVWriter creates an L2TCSVWriter which writes to w. The host is written in the host column of each line,
+// which can be empty when unknown.
+func NewL2TCSVWriter(w io.Writer, host string) *L2TCSVWriter {
+	return &L2TCSVWriter{w: bufio.NewWriter(w), host: host}
+}
+
+// Write writes the Event as a single line, writing the header line first if that has not been done yet.
+func (w *L2TCSVWriter) Write(e Event) error {
+	if !w.headerWritten {
+		if err := w.writeLine(l2tcsvHeader); err != nil {
+			return err
+		}
+		w.headerWritten = true
+	}
+	t := e.Time.UTC()
+	name := e.Entry.Path
+	if name == "" {
+		name = e.Entry.Name
+	}
+	return w.writeLine([]string{
+		t.Format("01/02/2006"),
+		t.Format("15:04:05"),
+		"UTC",
+		e.MACB.String(),
+		"FILE",
+		l2tcsvSourceType(e.Entry.Source),
+		e.MACB.Description(),
+		"-",
+		w.host,
+		name,
+		l2tcsvDescription(e, name),
+		"2",
+		name,
+		strconv.FormatUint(e.Entry.RecordNumber, 10),
+		"-",
+		"gomft",
+		l2tcsvExtra(e.Entry),
+	})
+}
+
+// Flush writes any buffered data to the underlying io.Writer.
+func (w *L2TCSVWriter) Flush() error {
+	return w.w.Flush()
+}
+
+func (w *L2TCSVWriter) writeLine(fields []string) error {
+	for i, f := range fields {
+		if i > 0 {
+			if err := w.w.WriteByte(','); err != nil {
+				return err
+			}
+		}
+		if f == "" {
+			f = "-"
+		}
+		if _, err := w.w.WriteString(l2tcsvSanitizer.Replace(f)); err != nil {
+			return err
+		}
+	}
+	return w.w.WriteByte('\n')
+}
+
+func l2tcsvSourceType(source string) string {
+	switch source {
+	case export.SourceIndexEntry:
+		return "NTFS $I30"
+	case export.SourceIndexSlack:
+		return "NTFS $I30 slack"
+	}
+	return "NTFS MFT"
+}
+
+func l2tcsvDescription(e Event, name string) string {
+	return fmt.Sprintf("%s: %s File reference: %d-%d Attribute name: %s Parent file reference: %d-%d Time: %s",
+		name, e.MACB.Description(), e.Entry.RecordNumber, e.Entry.SequenceNumber, e.Attribute,
+		e.Entry.ParentRecordNumber, e.Entry.ParentSequenceNumber, e.Time.UTC().Format("2006-01-02T15:04:05.0000000Z"))
+}
+
+func l2tcsvExtra(e export.Entry) string {
+	return fmt.Sprintf("in_use: %t; directory: %t; size: %d; offset: %d", e.InUse, e.Directory, e.Size, e.Offset)
+}
@@ -0,0 +1,51 @@
+package timeline_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/export"
+	"github.com/t9t/gomft/timeline"
+)
+
+func TestL2TCSVWriter(t *testing.T) {
+	out := &bytes.Buffer{}
+	w := timeline.NewL2TCSVWriter(out, "WKS01")
+	require.Nil(t, w.Write(timeline.Event{
+		Time:      time.Date(2020, time.February, 5, 14, 59, 38, 116886200, time.FixedZone("CET", 3600)),
+		MACB:      timeline.Modified | timeline.Changed,
+		Attribute: timeline.AttributeStandardInformation,
+		Entry: export.Entry{
+			Source:               export.SourceRecord,
+			Offset:               2048,
+			RecordNumber:         437343,
+			SequenceNumber:       6,
+			InUse:                true,
+			ParentRecordNumber:   429113,
+			ParentSequenceNumber: 59,
+			Name:                 "test, 1.txt",
+			Path:                 "/Users/test/test, 1.txt",
+			Size:                 13,
+		},
+	}))
+	require.Nil(t, w.Write(timeline.Event{
+		Time:      time.Date(2019, time.December, 31, 23, 0, 0, 0, time.UTC),
+		MACB:      timeline.Born,
+		Attribute: timeline.AttributeFileName,
+		Entry:     export.Entry{Source: export.SourceIndexSlack, RecordNumber: 12, Name: "gone\n.txt"},
+	}))
+	require.Nil(t, w.Flush())
+
+	expected := "date,time,timezone,MACB,source,sourcetype,type,user,host,short,desc,version,filename,inode,notes,format,extra\n" +
+		"02/05/2020,13:59:38,UTC,M.C.,FILE,NTFS MFT,Content Modification Time; Metadata Modification Time,-,WKS01," +
+		"/Users/test/test  1.txt," +
+		"/Users/test/test  1.txt: Content Modification Time; Metadata Modification Time File reference: 437343-6 Attribute name: $STANDARD_INFORMATION Parent file reference: 429113-59 Time: 2020-02-05T13:59:38.1168862Z," +
+		"2,/Users/test/test  1.txt,437343,-,gomft,in_use: true; directory: false; size: 13; offset: 2048\n" +
+		"12/31/2019,23:00:00,UTC,...B,FILE,NTFS $I30 slack,Creation Time,-,WKS01,gone .txt," +
+		"gone .txt: Creation Time File reference: 12-0 Attribute name: $FILE_NAME Parent file reference: 0-0 Time: 2019-12-31T23:00:00.0000000Z," +
+		"2,gone .txt,12,-,gomft,in_use: false; directory: false; size: 0; offset: 0\n"
+	assert.Equal(t, expected, out.String())
+}
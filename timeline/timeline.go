@@ -0,0 +1,167 @@
+/*
+	Package timeline turns export.Entry values into timeline events: one event for each distinct time of an attribute,
+	with the MACB flags indicating which of the times (Modified, Accessed, Changed, Born) it represents.
+
+	Basic usage
+
+	Use Events() to get the events of an Entry and write them using a Writer, such as an L2TCSVWriter. Or wrap a Writer
+	using NewEntryWriter to write the events of Entries through the export.Writer interface.
+			// Error handling left out for brevity
+			w := timeline.NewL2TCSVWriter(os.Stdout, "")
+			record, err := mft.ParseRecord(b)
+			for _, event := range timeline.Events(export.FromRecord(record)) {
+				err = w.Write(event)
+			}
+			err = w.Flush()
+
+	Implementation notes
+
+	Events are produced separately for the $STANDARD_INFORMATION and $FILE_NAME times. Within an attribute, times that
+	are equal are combined into a single event, like the "MACB" notation of mactime and log2timeline. Zero times are
+	skipped.
+
+	The $FILE_NAME times of Entries created from index entries (see export.FromIndexEntry and export.FromIndexRoot)
+	are copies kept in the $I30 index of the parent directory, which are only updated when the index entry is. Their
+	events have AttributeIndexEntry instead of AttributeFileName, so they can be told apart from (and compared with)
+	the times in the records themselves.
+
+	Events are written in the order of the Entries, not sorted by time. Tools that process timelines, such as psort,
+	mactime or a spreadsheet, sort them as needed.
+*/
+package timeline
+
+import (
+	"strings"
+	"time"
+
+	"github.com/t9t/gomft/export"
+)
+
+// Attribute names indicating which attribute the time of an Event was taken from.
+const (
+	AttributeStandardInformation = "$STANDARD_INFORMATION"
+	AttributeFileName            = "$FILE_NAME"
+	AttributeIndexEntry          = "$I30" // the $FILE_NAME in an index entry of the parent directory
+)
+
+// MACB indicates which times of an attribute an Event represents.
+type MACB byte
+
+// Bit values of MACB.
+const (
+	Modified MACB = 1 << iota // content last modified
+	Accessed                  // last accessed
+	Changed                   // metadata (MFT record) last modified
+	Born                      // created
+)
+
+var macbDescriptions = []string{"Content Modification Time", "Last Access Time", "Metadata Modification Time", "Creation Time"}
+
+// Is checks if all bits of c are set in m.
+func (m MACB) Is(c MACB) bool {
+	return m&c == c
+}
+
+// String formats the MACB in the usual four character notation, using a dot for each time not represented, for
+// example "M.C." for a modified and changed time.
+func (m MACB) String() string {
+	b := []byte("....")
+	for i, c := range "MACB" {
+		if m&(1<<uint(i)) != 0 {
+			b[i] = byte(c)
+		}
+	}
+	return string(b)
+}
+
+// Description describes the times represented by the MACB in the terms used by plaso, separated by "; ", for example
+// "Content Modification Time; Metadata Modification Time".
+func (m MACB) Description() string {
+	descriptions := make([]string, 0, len(macbDescriptions))
+	for i, d := range macbDescriptions {
+		if m&(1<<uint(i)) != 0 {
+			descriptions = append(descriptions, d)
+		}
+	}
+	return strings.Join(descriptions, "; ")
+}
+
+// An Event is a point in time at which something happened to a file, according to one or more of the times of an
+// attribute in the Entry.
+type Event struct {
+	Time      time.Time
+	MACB      MACB
+	Attribute string
+	Entry     export.Entry
+}
+
+// Events creates the events of an Entry: first those of the $STANDARD_INFORMATION times, then those of the $FILE_NAME
+// times, each in chronological order. For Entries created from index entries, the $FILE_NAME times are attributed to
+// AttributeIndexEntry.
+func Events(e export.Entry) []Event {
+	fileName := AttributeFileName
+	if e.Source == export.SourceIndexEntry || e.Source == export.SourceIndexSlack {
+		fileName = AttributeIndexEntry
+	}
+	events := make([]Event, 0, 8)
+	events = appendEvents(events, e, AttributeStandardInformation, e.FileLastModified, e.LastAccess, e.MftLastModified, e.Creation)
+	events = appendEvents(events, e, fileName, e.FileNameFileLastModified, e.FileNameLastAccess, e.FileNameMftLastModified, e.FileNameCreation)
+	return events
+}
+
+// appendEvents appends an event for each distinct non-zero time of an attribute, ordered by time. The times must be
+// passed in MACB order.
+func appendEvents(events []Event, e export.Entry, attribute string, times ...time.Time) []Event {
+	start := len(events)
+	for i, t := range times {
+		if t.IsZero() {
+			continue
+		}
+		macb := MACB(1 << uint(i))
+		merged := false
+		for j := start; j < len(events); j++ {
+			if events[j].Time.Equal(t) {
+				events[j].MACB |= macb
+				merged = true
+				break
+			}
+		}
+		if merged {
+			continue
+		}
+		events = append(events, Event{Time: t, MACB: macb, Attribute: attribute, Entry: e})
+		for j := len(events) - 1; j > start && events[j].Time.Before(events[j-1].Time); j-- {
+			events[j], events[j-1] = events[j-1], events[j]
+		}
+	}
+	return events
+}
+
+// A Writer writes Events in a certain output format. Flush must be called after the last Event was written to ensure
+// all data is written to the underlying io.Writer.
+type Writer interface {
+	Write(e Event) error
+	Flush() error
+}
+
+// NewEntryWriter creates an export.Writer which writes the Events of each Entry to w.
+func NewEntryWriter(w Writer) export.Writer {
+	return entryWriter{w: w}
+}
+
+type entryWriter struct {
+	w Writer
+}
+
+func (w entryWriter) Write(e export.Entry) error {
+	for _, event := range Events(e) {
+		if err := w.w.Write(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w entryWriter) Flush() error {
+	return w.w.Flush()
+}
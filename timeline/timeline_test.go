@@ -0,0 +1,129 @@
+package timeline_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/export"
+	"github.com/t9t/gomft/timeline"
+)
+
+func TestMACB(t *testing.T) {
+	assert.Equal(t, "....", timeline.MACB(0).String())
+	assert.Equal(t, "MACB", (timeline.Modified | timeline.Accessed | timeline.Changed | timeline.Born).String())
+	assert.Equal(t, "M.C.", (timeline.Modified | timeline.Changed).String())
+	assert.Equal(t, "...B", timeline.Born.String())
+
+	assert.Equal(t, "", timeline.MACB(0).Description())
+	assert.Equal(t, "Content Modification Time; Metadata Modification Time", (timeline.Modified | timeline.Changed).Description())
+	assert.Equal(t, "Last Access Time; Creation Time", (timeline.Accessed | timeline.Born).Description())
+
+	assert.True(t, (timeline.Modified | timeline.Born).Is(timeline.Born))
+	assert.False(t, timeline.Modified.Is(timeline.Modified|timeline.Born))
+}
+
+func TestEvents(t *testing.T) {
+	t1 := time.Date(2020, time.February, 5, 14, 59, 38, 116886200, time.UTC)
+	t2 := time.Date(2020, time.February, 5, 14, 59, 39, 595445600, time.UTC)
+	t3 := time.Date(2020, time.February, 6, 8, 0, 0, 0, time.UTC)
+	e := export.Entry{
+		Name:                     "test.txt",
+		Creation:                 t2,
+		FileLastModified:         t3,
+		MftLastModified:          t3,
+		LastAccess:               t1,
+		FileNameCreation:         t1,
+		FileNameFileLastModified: t1,
+		FileNameMftLastModified:  t2,
+		FileNameLastAccess:       t1,
+	}
+
+	events := timeline.Events(e)
+	require.Len(t, events, 5)
+
+	expected := []struct {
+		time      time.Time
+		macb      string
+		attribute string
+	}{
+		{t1, ".A..", timeline.AttributeStandardInformation},
+		{t2, "...B", timeline.AttributeStandardInformation},
+		{t3, "M.C.", timeline.AttributeStandardInformation},
+		{t1, "MA.B", timeline.AttributeFileName},
+		{t2, "..C.", timeline.AttributeFileName},
+	}
+	for i, x := range expected {
+		assert.Equal(t, x.time, events[i].Time, "time of event %d", i)
+		assert.Equal(t, x.macb, events[i].MACB.String(), "MACB of event %d", i)
+		assert.Equal(t, x.attribute, events[i].Attribute, "attribute of event %d", i)
+		assert.Equal(t, "test.txt", events[i].Entry.Name, "name of event %d", i)
+	}
+}
+
+func TestEventsZeroTimes(t *testing.T) {
+	assert.Len(t, timeline.Events(export.Entry{Name: "x"}), 0)
+
+	t1 := time.Date(2020, time.February, 5, 14, 59, 38, 0, time.UTC)
+	events := timeline.Events(export.Entry{FileNameCreation: t1, FileNameFileLastModified: t1})
+	require.Len(t, events, 1)
+	assert.Equal(t, "M..B", events[0].MACB.String())
+	assert.Equal(t, timeline.AttributeFileName, events[0].Attribute)
+}
+
+func TestEventsIndexEntry(t *testing.T) {
+	t1 := time.Date(2020, time.February, 5, 14, 59, 38, 0, time.UTC)
+	for _, source := range []string{export.SourceIndexEntry, export.SourceIndexSlack} {
+		events := timeline.Events(export.Entry{Source: source, FileNameCreation: t1})
+		require.Len(t, events, 1)
+		assert.Equal(t, timeline.AttributeIndexEntry, events[0].Attribute)
+	}
+}
+
+type recordingWriter struct {
+	events  []timeline.Event
+	flushed bool
+	err     error
+}
+
+func (w *recordingWriter) Write(e timeline.Event) error {
+	w.events = append(w.events, e)
+	return w.err
+}
+
+func (w *recordingWriter) Flush() error {
+	w.flushed = true
+	return nil
+}
+
+func TestEntryWriter(t *testing.T) {
+	t1 := time.Date(2020, time.February, 5, 14, 59, 38, 0, time.UTC)
+	t2 := time.Date(2020, time.February, 5, 14, 59, 39, 0, time.UTC)
+	r := &recordingWriter{}
+	w := timeline.NewEntryWriter(r)
+
+	require.Nil(t, w.Write(export.Entry{Name: "a", Creation: t1, FileNameCreation: t2}))
+	require.Nil(t, w.Write(export.Entry{Name: "b"}))
+	require.Nil(t, w.Flush())
+
+	require.Len(t, r.events, 2)
+	assert.Equal(t, t1, r.events[0].Time)
+	assert.Equal(t, t2, r.events[1].Time)
+	assert.True(t, r.flushed)
+
+	r = &recordingWriter{err: errors.New("oops")}
+	err := timeline.NewEntryWriter(r).Write(export.Entry{Creation: t1, FileNameCreation: t2})
+	assert.Equal(t, r.err, err)
+	assert.Len(t, r.events, 1)
+}
+
+func TestEntryWriterL2TCSV(t *testing.T) {
+	out := &bytes.Buffer{}
+	w := timeline.NewEntryWriter(timeline.NewL2TCSVWriter(out, ""))
+	require.Nil(t, w.Write(export.Entry{Name: "a", Creation: time.Date(2020, time.February, 5, 14, 59, 38, 0, time.UTC)}))
+	require.Nil(t, w.Flush())
+	assert.Contains(t, out.String(), "02/05/2020,14:59:38,UTC,...B,FILE,NTFS MFT,Creation Time,-,-,a,")
+}
@@ -0,0 +1,225 @@
+/*
+	Package txf enumerates the metadata of Transactional NTFS (TxF) kept in $Extend\$RmMetadata, and collects the
+	$TXF_DATA streams of files, so files which have been part of a transaction can be correlated with the transaction
+	logs and with each other.
+
+	Basic usage
+
+	Find the components of $RmMetadata using an mftindex.Index, and collect the $TXF_DATA streams using a Collector
+	while adding the records to the index.
+			// Error handling left out for brevity
+			b := mftindex.NewBuilder()
+			c := txf.NewCollector()
+			for result := range mft.ParseAll(in, mft.ParseAllOptions{SkipEmpty: true}) {
+				if result.Err == nil {
+					b.Add(uint64(result.Index), result.Record)
+					err = c.Add(uint64(result.Index), result.Record)
+				}
+			}
+			metadata, ok := txf.FindMetadata(b.Build())
+			for _, t := range c.Transactions() {
+				fmt.Println(t.TransactionID, len(t.Files))
+			}
+
+	Implementation notes
+
+	The resource manager of a volume keeps its metadata in the $RmMetadata directory in $Extend:
+		- $Repair, with the $Config, $Corrupt and $Verify streams used by self healing (chkdsk /spotfix) since Windows 8;
+		- $TxfLog, a directory with the Common Log File System (CLFS) log of the resource manager ($TxfLog.blf and its
+		  containers) and $Tops, whose $T stream keeps the old pages of files changed by active transactions;
+		- $Txf, a directory holding the files deleted or renamed by transactions until the transaction ends.
+	The formats of the streams of these files are not documented by Microsoft, and CLFS logs are a format of their own,
+	so they are not parsed. FindMetadata reports the record and the size of each component, so their data can be read
+	or extracted for further analysis. Finding a non-empty $Txf directory or $T stream means transactions were
+	active when the volume was last used.
+
+	The $TXF_DATA stream is parsed by mft.ParseTxfData. The TransactionID (TxF file ID) in it is shared by the files
+	changed by the same transaction, and the ResourceManagerRoot identifies the resource manager: the root directory
+	(record 5) for the default resource manager of the volume, whose metadata is in $RmMetadata.
+*/
+package txf
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/mftindex"
+)
+
+// ExtendRecordNumber is the record number of the $Extend directory.
+const ExtendRecordNumber = 11
+
+// Component is a file or directory in $RmMetadata.
+type Component struct {
+	RecordNumber  uint64
+	Reference     mft.FileReference
+	Name          string
+	Directory     bool
+	Size          uint64 // the total size of all $DATA attributes, as kept by mftindex.Index
+	AllocatedSize uint64
+}
+
+// Metadata contains the components of $Extend\$RmMetadata. The Has fields indicate whether the component was found.
+type Metadata struct {
+	RmMetadata Component
+	Repair     Component
+	HasRepair  bool
+	TxfLog     Component
+	HasTxfLog  bool
+	Tops       Component
+	HasTops    bool
+	Txf        Component
+	HasTxf     bool
+	// LogFiles are the files in $TxfLog other than $Tops, which are the CLFS log ($TxfLog.blf) and its containers.
+	LogFiles []Component
+	// Isolated are the files in $Txf, kept by transactions which had not ended yet.
+	Isolated []Component
+}
+
+// FindMetadata finds the components of $Extend\$RmMetadata in the Index. It returns false when there is no
+// $RmMetadata directory, which is the case for volumes formatted before Windows Vista which were never used by it.
+func FindMetadata(idx *mftindex.Index) (Metadata, bool) {
+	m := Metadata{}
+	number, ok := lookupChild(idx, ExtendRecordNumber, "$RmMetadata")
+	if !ok {
+		return Metadata{}, false
+	}
+	m.RmMetadata = component(idx, number, "$RmMetadata")
+
+	for _, c := range idx.Children(number) {
+		switch c.Name {
+		case "$Repair":
+			m.Repair, m.HasRepair = component(idx, c.RecordNumber, c.Name), true
+		case "$TxfLog":
+			m.TxfLog, m.HasTxfLog = component(idx, c.RecordNumber, c.Name), true
+		case "$Txf":
+			m.Txf, m.HasTxf = component(idx, c.RecordNumber, c.Name), true
+		}
+	}
+	if m.HasTxfLog {
+		m.LogFiles = make([]Component, 0)
+		for _, c := range idx.Children(m.TxfLog.RecordNumber) {
+			if c.Name == "$Tops" {
+				m.Tops, m.HasTops = component(idx, c.RecordNumber, c.Name), true
+				continue
+			}
+			m.LogFiles = append(m.LogFiles, component(idx, c.RecordNumber, c.Name))
+		}
+	}
+	if m.HasTxf {
+		m.Isolated = make([]Component, 0)
+		for _, c := range idx.Children(m.Txf.RecordNumber) {
+			m.Isolated = append(m.Isolated, component(idx, c.RecordNumber, c.Name))
+		}
+	}
+	return m, true
+}
+
+// lookupChild finds an entry in a directory by its exact name, preferring records in use.
+func lookupChild(idx *mftindex.Index, dir uint64, name string) (uint64, bool) {
+	found, ok := uint64(0), false
+	for _, c := range idx.Children(dir) {
+		if c.Name != name {
+			continue
+		}
+		if r, _ := idx.Record(c.RecordNumber); r.InUse {
+			return c.RecordNumber, true
+		}
+		if !ok {
+			found, ok = c.RecordNumber, true
+		}
+	}
+	return found, ok
+}
+
+func component(idx *mftindex.Index, number uint64, name string) Component {
+	r, _ := idx.Record(number)
+	return Component{
+		RecordNumber:  number,
+		Reference:     r.Reference,
+		Name:          name,
+		Directory:     r.Directory,
+		Size:          r.Size,
+		AllocatedSize: r.AllocatedSize,
+	}
+}
+
+// File is a record with a $TXF_DATA stream.
+type File struct {
+	RecordNumber uint64
+	Reference    mft.FileReference
+	InUse        bool
+	Data         mft.TxfData
+}
+
+// IsDefaultResourceManager indicates whether the file belongs to the default resource manager of the volume, whose
+// metadata is in $Extend\$RmMetadata.
+func (f File) IsDefaultResourceManager() bool {
+	return f.Data.ResourceManagerRoot.RecordNumber == mftindex.RootRecordNumber
+}
+
+// Transaction groups the files sharing a TransactionID.
+type Transaction struct {
+	TransactionID uint64
+	Files         []File
+}
+
+// Collector collects the $TXF_DATA streams of records. A Collector is not safe for concurrent use.
+type Collector struct {
+	files []File
+}
+
+// NewCollector creates an empty Collector.
+func NewCollector() *Collector {
+	return &Collector{}
+}
+
+// Add collects the $TXF_DATA stream of a record, if it has one. The number is the record number, the position of the
+// record in the MFT. An error is returned when the stream cannot be parsed.
+func (c *Collector) Add(number uint64, r mft.Record) error {
+	for _, a := range r.FindAttributes(mft.AttributeTypeLoggedUtilityStream) {
+		if a.Name != mft.TxfDataStreamName || !a.Resident {
+			continue
+		}
+		data, err := mft.ParseTxfData(a.Data)
+		if err != nil {
+			return fmt.Errorf("unable to parse %s of record %d: %v", mft.TxfDataStreamName, number, err)
+		}
+		reference := mft.FileReference{RecordNumber: number, SequenceNumber: r.FileReference.SequenceNumber}
+		if r.IsExtension() {
+			reference = r.BaseRecordReference
+			number = reference.RecordNumber
+		}
+		c.files = append(c.files, File{RecordNumber: number, Reference: reference, InUse: r.IsInUse(), Data: data})
+	}
+	return nil
+}
+
+// Files returns the collected files, ordered by record number.
+func (c *Collector) Files() []File {
+	files := append([]File(nil), c.files...)
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].RecordNumber < files[j].RecordNumber
+	})
+	return files
+}
+
+// Transactions returns the collected files grouped by TransactionID, ordered by TransactionID. Files without a
+// TransactionID (0) are left out.
+func (c *Collector) Transactions() []Transaction {
+	byID := make(map[uint64][]File)
+	for _, f := range c.Files() {
+		if f.Data.TransactionID != 0 {
+			byID[f.Data.TransactionID] = append(byID[f.Data.TransactionID], f)
+		}
+	}
+	transactions := make([]Transaction, 0, len(byID))
+	for id, files := range byID {
+		transactions = append(transactions, Transaction{TransactionID: id, Files: files})
+	}
+	sort.Slice(transactions, func(i, j int) bool {
+		return transactions[i].TransactionID < transactions[j].TransactionID
+	})
+	return transactions
+}
@@ -0,0 +1,117 @@
+package txf_test
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/mftindex"
+	"github.com/t9t/gomft/mfttest"
+	"github.com/t9t/gomft/txf"
+)
+
+func reference(number uint64, sequenceNumber uint16) mft.FileReference {
+	return mft.FileReference{RecordNumber: number, SequenceNumber: sequenceNumber}
+}
+
+func record(number uint64, parent uint64, name string) *mfttest.RecordBuilder {
+	return mfttest.NewRecord().WithRecordNumber(number).WithParent(reference(parent, 1)).WithFileName(name)
+}
+
+func directory(number uint64, parent uint64, name string) *mfttest.RecordBuilder {
+	return record(number, parent, name).WithDirectory()
+}
+
+// txfData encodes a $TXF_DATA stream of the default resource manager with the transaction ID.
+func txfData(transactionID uint64) mft.Attribute {
+	b := make([]byte, 0x38)
+	binary.LittleEndian.PutUint32(b[0x06:], mftindex.RootRecordNumber)
+	binary.LittleEndian.PutUint16(b[0x0C:], 1)
+	binary.LittleEndian.PutUint64(b[0x16:], transactionID)
+	return mft.Attribute{Type: mft.AttributeTypeLoggedUtilityStream, Resident: true, Name: mft.TxfDataStreamName, Data: b}
+}
+
+func testIndex() *mftindex.Index {
+	b := mftindex.NewBuilder()
+	for _, r := range []*mfttest.RecordBuilder{
+		directory(5, 5, ".").WithSequenceNumber(1),
+		directory(11, 5, "$Extend"),
+		directory(30, 11, "$RmMetadata"),
+		record(31, 30, "$Repair").WithStream("$Corrupt", make([]byte, 16)),
+		directory(32, 30, "$TxfLog"),
+		record(33, 32, "$Tops").WithStream("$T", make([]byte, 100)),
+		record(34, 32, "$TxfLog.blf").WithResidentData(make([]byte, 64)),
+		record(35, 32, "$TxfLogContainer00000000000000000001"),
+		directory(36, 30, "$Txf"),
+		record(37, 36, "0000000000000123").WithResidentData(make([]byte, 10)),
+	} {
+		r := r.Record()
+		b.Add(r.FileReference.RecordNumber, r)
+	}
+	return b.Build()
+}
+
+func component(number uint64, name string, directory bool, size uint64) txf.Component {
+	return txf.Component{RecordNumber: number, Reference: reference(number, 1), Name: name, Directory: directory, Size: size, AllocatedSize: size}
+}
+
+func TestFindMetadata(t *testing.T) {
+	m, ok := txf.FindMetadata(testIndex())
+	require.True(t, ok)
+	assert.Equal(t, txf.Metadata{
+		RmMetadata: component(30, "$RmMetadata", true, 0),
+		Repair:     component(31, "$Repair", false, 16),
+		HasRepair:  true,
+		TxfLog:     component(32, "$TxfLog", true, 0),
+		HasTxfLog:  true,
+		Tops:       component(33, "$Tops", false, 100),
+		HasTops:    true,
+		Txf:        component(36, "$Txf", true, 0),
+		HasTxf:     true,
+		LogFiles: []txf.Component{
+			component(34, "$TxfLog.blf", false, 64),
+			component(35, "$TxfLogContainer00000000000000000001", false, 0),
+		},
+		Isolated: []txf.Component{component(37, "0000000000000123", false, 10)},
+	}, m)
+}
+
+func TestFindMetadata_Missing(t *testing.T) {
+	b := mftindex.NewBuilder()
+	b.Add(5, directory(5, 5, ".").Record())
+	b.Add(11, directory(11, 5, "$Extend").Record())
+	_, ok := txf.FindMetadata(b.Build())
+	assert.False(t, ok)
+}
+
+func TestCollector(t *testing.T) {
+	c := txf.NewCollector()
+	require.Nil(t, c.Add(50, record(50, 5, "a.txt").WithAttribute(txfData(0x2A)).Record()))
+	require.Nil(t, c.Add(40, record(40, 5, "b.txt").WithAttribute(txfData(0x2A)).WithFlags(0).Record()))
+	require.Nil(t, c.Add(45, record(45, 5, "c.txt").WithAttribute(txfData(0x10)).Record()))
+	require.Nil(t, c.Add(46, record(46, 5, "d.txt").WithAttribute(txfData(0)).Record()))
+	require.Nil(t, c.Add(47, record(47, 5, "e.txt").Record()))
+	// Extension record of 48
+	require.Nil(t, c.Add(49, mfttest.NewRecord().WithRecordNumber(49).WithBaseRecord(reference(48, 3)).WithAttribute(txfData(0x10)).Record()))
+
+	files := c.Files()
+	require.Equal(t, 5, len(files))
+	assert.Equal(t, []uint64{40, 45, 46, 48, 50}, []uint64{files[0].RecordNumber, files[1].RecordNumber,
+		files[2].RecordNumber, files[3].RecordNumber, files[4].RecordNumber})
+	assert.False(t, files[0].InUse)
+	assert.Equal(t, reference(48, 3), files[3].Reference)
+	assert.True(t, files[0].IsDefaultResourceManager())
+
+	transactions := c.Transactions()
+	require.Equal(t, 2, len(transactions))
+	assert.Equal(t, uint64(0x10), transactions[0].TransactionID)
+	assert.Equal(t, []txf.File{files[1], files[3]}, transactions[0].Files)
+	assert.Equal(t, uint64(0x2A), transactions[1].TransactionID)
+	assert.Equal(t, []txf.File{files[0], files[4]}, transactions[1].Files)
+
+	err := c.Add(51, record(51, 5, "f.txt").WithAttribute(mft.Attribute{Type: mft.AttributeTypeLoggedUtilityStream,
+		Resident: true, Name: mft.TxfDataStreamName, Data: make([]byte, 10)}).Record())
+	assert.EqualError(t, err, "unable to parse $TXF_DATA of record 51: expected at least 56 bytes but got 10")
+}
@@ -0,0 +1,218 @@
+// +build linux,amd64 linux,arm64
+
+package uring
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	sysIoUringSetup = 425
+	sysIoUringEnter = 426
+
+	offSqRing = 0
+	offCqRing = 0x8000000
+	offSqes   = 0x10000000
+
+	opReadv        = 1
+	enterGetEvents = 1
+
+	submissionEntrySize = 64
+	completionEntrySize = 16
+)
+
+// params is struct io_uring_params, which is passed to io_uring_setup.
+type params struct {
+	sqEntries    uint32
+	cqEntries    uint32
+	flags        uint32
+	sqThreadCPU  uint32
+	sqThreadIdle uint32
+	features     uint32
+	wqFd         uint32
+	resv         [3]uint32
+	sqOff        sqRingOffsets
+	cqOff        cqRingOffsets
+}
+
+// sqRingOffsets is struct io_sqring_offsets.
+type sqRingOffsets struct {
+	head        uint32
+	tail        uint32
+	ringMask    uint32
+	ringEntries uint32
+	flags       uint32
+	dropped     uint32
+	array       uint32
+	resv1       uint32
+	resv2       uint64
+}
+
+// cqRingOffsets is struct io_cqring_offsets.
+type cqRingOffsets struct {
+	head        uint32
+	tail        uint32
+	ringMask    uint32
+	ringEntries uint32
+	overflow    uint32
+	cqes        uint32
+	flags       uint32
+	resv1       uint32
+	resv2       uint64
+}
+
+// submissionEntry is struct io_uring_sqe.
+type submissionEntry struct {
+	opcode      uint8
+	flags       uint8
+	ioprio      uint16
+	fd          int32
+	off         uint64
+	addr        uint64
+	len         uint32
+	rwFlags     uint32
+	userData    uint64
+	bufIndex    uint16
+	personality uint16
+	spliceFdIn  int32
+	pad         [2]uint64
+}
+
+// completionEntry is struct io_uring_cqe.
+type completionEntry struct {
+	userData uint64
+	res      int32
+	flags    uint32
+}
+
+type ring struct {
+	fd      int
+	sqRing  []byte
+	cqRing  []byte
+	sqes    []byte
+	entries uint32
+
+	sqTail  *uint32
+	sqMask  uint32
+	sqArray []byte
+	cqHead  *uint32
+	cqTail  *uint32
+	cqMask  uint32
+	cqes    []byte
+}
+
+func newRing(entries uint32) (*ring, error) {
+	var p params
+	fd, _, errno := syscall.Syscall(sysIoUringSetup, uintptr(entries), uintptr(unsafe.Pointer(&p)), 0)
+	if errno != 0 {
+		return nil, fmt.Errorf("io_uring_setup failed: %v", errno)
+	}
+	r := &ring{fd: int(fd), entries: p.sqEntries}
+
+	var err error
+	mmap := func(offset int64, size uint32) []byte {
+		if err != nil {
+			return nil
+		}
+		var b []byte
+		b, err = syscall.Mmap(r.fd, offset, int(size), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED|syscall.MAP_POPULATE)
+		return b
+	}
+	r.sqRing = mmap(offSqRing, p.sqOff.array+p.sqEntries*4)
+	r.cqRing = mmap(offCqRing, p.cqOff.cqes+p.cqEntries*completionEntrySize)
+	r.sqes = mmap(offSqes, p.sqEntries*submissionEntrySize)
+	if err != nil {
+		r.close()
+		return nil, fmt.Errorf("unable to map io_uring queues: %v", err)
+	}
+
+	r.sqTail = (*uint32)(unsafe.Pointer(&r.sqRing[p.sqOff.tail]))
+	r.sqMask = *(*uint32)(unsafe.Pointer(&r.sqRing[p.sqOff.ringMask]))
+	r.sqArray = r.sqRing[p.sqOff.array:]
+	r.cqHead = (*uint32)(unsafe.Pointer(&r.cqRing[p.cqOff.head]))
+	r.cqTail = (*uint32)(unsafe.Pointer(&r.cqRing[p.cqOff.tail]))
+	r.cqMask = *(*uint32)(unsafe.Pointer(&r.cqRing[p.cqOff.ringMask]))
+	r.cqes = r.cqRing[p.cqOff.cqes:]
+	return r, nil
+}
+
+// read submits all requests, which must not be more than the number of entries of the ring, and waits until they
+// have completed. Short reads are completed using regular reads.
+func (r *ring) read(f *os.File, requests []request) error {
+	if uint32(len(requests)) > r.entries {
+		return fmt.Errorf("%d requests exceed the queue size %d", len(requests), r.entries)
+	}
+
+	// The kernel reads the iovecs asynchronously, so they must be kept alive (and they won't move, since Go does not
+	// move heap objects) until all requests have completed
+	iovecs := make([]syscall.Iovec, len(requests))
+	tail := atomic.LoadUint32(r.sqTail)
+	for i, req := range requests {
+		iovecs[i].Base = &req.buf[0]
+		iovecs[i].SetLen(len(req.buf))
+
+		index := tail & r.sqMask
+		sqe := (*submissionEntry)(unsafe.Pointer(&r.sqes[index*submissionEntrySize]))
+		*sqe = submissionEntry{
+			opcode:   opReadv,
+			fd:       int32(f.Fd()),
+			off:      uint64(req.off),
+			addr:     uint64(uintptr(unsafe.Pointer(&iovecs[i]))),
+			len:      1,
+			userData: uint64(i),
+		}
+		*(*uint32)(unsafe.Pointer(&r.sqArray[index*4])) = index
+		tail++
+	}
+	atomic.StoreUint32(r.sqTail, tail)
+
+	results := make([]int32, len(requests))
+	toSubmit, completed := len(requests), 0
+	for completed < len(requests) {
+		n, _, errno := syscall.Syscall6(sysIoUringEnter, uintptr(r.fd), uintptr(toSubmit), uintptr(len(requests)-completed), enterGetEvents, 0, 0)
+		if errno == syscall.EINTR || errno == syscall.EAGAIN {
+			continue
+		}
+		if errno != 0 {
+			return fmt.Errorf("io_uring_enter failed: %v", errno)
+		}
+		toSubmit -= int(n)
+
+		head := atomic.LoadUint32(r.cqHead)
+		for ; head != atomic.LoadUint32(r.cqTail); head++ {
+			cqe := (*completionEntry)(unsafe.Pointer(&r.cqes[(head&r.cqMask)*completionEntrySize]))
+			results[cqe.userData] = cqe.res
+			completed++
+		}
+		atomic.StoreUint32(r.cqHead, head)
+	}
+	runtime.KeepAlive(iovecs)
+	runtime.KeepAlive(requests)
+
+	for i, req := range requests {
+		res := results[i]
+		if res < 0 {
+			return fmt.Errorf("unable to read %d bytes at offset %d: %v", len(req.buf), req.off, syscall.Errno(-res))
+		}
+		if int(res) < len(req.buf) {
+			if err := readFully(f, request{buf: req.buf[res:], off: req.off + int64(res)}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *ring) close() error {
+	for _, b := range [][]byte{r.sqRing, r.cqRing, r.sqes} {
+		if b != nil {
+			syscall.Munmap(b)
+		}
+	}
+	return syscall.Close(r.fd)
+}
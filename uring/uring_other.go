@@ -0,0 +1,22 @@
+// +build !linux !amd64,!arm64
+
+package uring
+
+import (
+	"errors"
+	"os"
+)
+
+type ring struct{}
+
+func newRing(entries uint32) (*ring, error) {
+	return nil, errors.New("io_uring is not supported on this platform")
+}
+
+func (r *ring) read(f *os.File, requests []request) error {
+	return errors.New("io_uring is not supported on this platform")
+}
+
+func (r *ring) close() error {
+	return nil
+}
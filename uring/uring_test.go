@@ -0,0 +1,95 @@
+package uring_test
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/fragment"
+	"github.com/t9t/gomft/uring"
+)
+
+func TestReader_ReadFragments(t *testing.T) {
+	data, f := createTestFile(t, 4*1024*1024)
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	for _, depth := range []int{0, 1, 3} {
+		r, err := uring.NewReader(f, depth)
+		require.Nil(t, err)
+		t.Logf("depth %d, io_uring: %v", depth, r.UsesIoUring())
+
+		fragments := []fragment.Fragment{
+			{Offset: 2 * 1024 * 1024, Length: 1024*1024 + 17}, // split into multiple requests
+			{Offset: 5, Length: 100},
+			{Offset: 1000, Length: 0},
+			{Offset: 1000, Length: 3000},
+		}
+		dst := make([]byte, 2*1024*1024)
+		n, err := r.ReadFragments(dst, fragments)
+		require.Nil(t, err)
+		assert.Equal(t, 1024*1024+17+100+3000, n)
+
+		expected := append(append(append([]byte{}, data[2*1024*1024:3*1024*1024+17]...), data[5:105]...), data[1000:4000]...)
+		assert.Equal(t, expected, dst[:n])
+		assert.Nil(t, r.Close())
+	}
+}
+
+func TestReader_ReadFragments_LimitedByDestination(t *testing.T) {
+	data, f := createTestFile(t, 10000)
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	r, err := uring.NewReader(f, 0)
+	require.Nil(t, err)
+	defer r.Close()
+
+	dst := make([]byte, 150)
+	n, err := r.ReadFragments(dst, []fragment.Fragment{{Offset: 0, Length: 100}, {Offset: 500, Length: 100}})
+	require.Nil(t, err)
+	assert.Equal(t, 150, n)
+	assert.Equal(t, append(append([]byte{}, data[:100]...), data[500:550]...), dst)
+}
+
+func TestReader_ReadFragments_BeyondEnd(t *testing.T) {
+	_, f := createTestFile(t, 10000)
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	r, err := uring.NewReader(f, 0)
+	require.Nil(t, err)
+	defer r.Close()
+
+	_, err = r.ReadFragments(make([]byte, 200), []fragment.Fragment{{Offset: 9900, Length: 200}})
+	assert.EqualError(t, err, "unable to read 100 bytes at offset 10000: unexpected EOF")
+}
+
+func TestReader_FragmentReader(t *testing.T) {
+	data, f := createTestFile(t, 100000)
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	r, err := uring.NewReader(f, 4)
+	require.Nil(t, err)
+	defer r.Close()
+
+	fragments := []fragment.Fragment{{Offset: 60000, Length: 40000}, {Offset: 0, Length: 0}, {Offset: 100, Length: 25000}}
+	read, err := ioutil.ReadAll(r.FragmentReader(fragments, 7000))
+	require.Nil(t, err)
+	assert.Equal(t, append(append([]byte{}, data[60000:]...), data[100:25100]...), read)
+	assert.Equal(t, int64(60000), fragments[0].Offset, "fragments should not be modified")
+}
+
+func createTestFile(t *testing.T, size int) ([]byte, *os.File) {
+	data := make([]byte, size)
+	_, _ = rand.Read(data)
+	f, err := ioutil.TempFile("", "gomft-uring")
+	require.Nil(t, err)
+	_, err = f.Write(data)
+	require.Nil(t, err)
+	return data, f
+}
@@ -0,0 +1,243 @@
+/*
+	Package usn parses the records of the NTFS change journal (the USN journal), which describes the changes made to
+	the files of a volume, such as creations, deletions and renames.
+
+	Basic usage
+
+	Parse the data of the $UsnJrnl:$J stream (or the output of FSCTL_READ_USN_JOURNAL, after its leading 8 byte USN)
+	using ParseRecords, or a single record using ParseRecord.
+			// Error handling left out for brevity
+			records, err := usn.ParseRecords(data)
+			for _, r := range records {
+				fmt.Println(r.Usn, r.FileReference.RecordNumber, r.FileName, r.Reason)
+			}
+
+	To keep an in-memory index of an MFT up to date using these records, see mftindex.Live.
+
+	Implementation notes
+
+	Records of version 2 (USN_RECORD_V2) and version 3 (USN_RECORD_V3) are supported. Version 3 records contain 128 bit
+	file ids; on NTFS only the lower 64 bits are used, which are the file reference. Version 4 records (range tracking)
+	do not contain a file name and are not supported.
+
+	Records are aligned on 8 bytes. The $J stream is sparse and starts with a long range of zeroes for the part of the
+	journal that was already discarded, and the end of each journal page may be padded with zeroes. ParseRecords skips
+	such zeroes.
+*/
+package usn
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/t9t/gomft/binutil"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/utf16"
+)
+
+const (
+	v2HeaderSize = 0x3C
+	v3HeaderSize = 0x4C
+)
+
+// fileAttributeDirectory is the Win32 FILE_ATTRIBUTE_DIRECTORY bit, which is set in the FileAttributes of USN records
+// of directories.
+const fileAttributeDirectory = mft.FileAttribute(0x10)
+
+// Reason is a bit mask of the changes described by a Record.
+type Reason uint32
+
+// Bit values for Reason.
+const (
+	ReasonDataOverwrite        Reason = 0x00000001
+	ReasonDataExtend           Reason = 0x00000002
+	ReasonDataTruncation       Reason = 0x00000004
+	ReasonNamedDataOverwrite   Reason = 0x00000010
+	ReasonNamedDataExtend      Reason = 0x00000020
+	ReasonNamedDataTruncation  Reason = 0x00000040
+	ReasonFileCreate           Reason = 0x00000100
+	ReasonFileDelete           Reason = 0x00000200
+	ReasonEAChange             Reason = 0x00000400
+	ReasonSecurityChange       Reason = 0x00000800
+	ReasonRenameOldName        Reason = 0x00001000
+	ReasonRenameNewName        Reason = 0x00002000
+	ReasonIndexableChange      Reason = 0x00004000
+	ReasonBasicInfoChange      Reason = 0x00008000
+	ReasonHardLinkChange       Reason = 0x00010000
+	ReasonCompressionChange    Reason = 0x00020000
+	ReasonEncryptionChange     Reason = 0x00040000
+	ReasonObjectIdChange       Reason = 0x00080000
+	ReasonReparsePointChange   Reason = 0x00100000
+	ReasonStreamChange         Reason = 0x00200000
+	ReasonTransactedChange     Reason = 0x00400000
+	ReasonIntegrityChange      Reason = 0x00800000
+	ReasonDesiredStorageChange Reason = 0x01000000
+	ReasonClose                Reason = 0x80000000
+)
+
+var reasonNames = []struct {
+	bit  Reason
+	name string
+}{
+	{ReasonDataOverwrite, "DataOverwrite"},
+	{ReasonDataExtend, "DataExtend"},
+	{ReasonDataTruncation, "DataTruncation"},
+	{ReasonNamedDataOverwrite, "NamedDataOverwrite"},
+	{ReasonNamedDataExtend, "NamedDataExtend"},
+	{ReasonNamedDataTruncation, "NamedDataTruncation"},
+	{ReasonFileCreate, "FileCreate"},
+	{ReasonFileDelete, "FileDelete"},
+	{ReasonEAChange, "EAChange"},
+	{ReasonSecurityChange, "SecurityChange"},
+	{ReasonRenameOldName, "RenameOldName"},
+	{ReasonRenameNewName, "RenameNewName"},
+	{ReasonIndexableChange, "IndexableChange"},
+	{ReasonBasicInfoChange, "BasicInfoChange"},
+	{ReasonHardLinkChange, "HardLinkChange"},
+	{ReasonCompressionChange, "CompressionChange"},
+	{ReasonEncryptionChange, "EncryptionChange"},
+	{ReasonObjectIdChange, "ObjectIdChange"},
+	{ReasonReparsePointChange, "ReparsePointChange"},
+	{ReasonStreamChange, "StreamChange"},
+	{ReasonTransactedChange, "TransactedChange"},
+	{ReasonIntegrityChange, "IntegrityChange"},
+	{ReasonDesiredStorageChange, "DesiredStorageChange"},
+	{ReasonClose, "Close"},
+}
+
+// Is checks if this Reason's bit mask contains the specified reason.
+func (r Reason) Is(c Reason) bool {
+	return r&c == c
+}
+
+// String returns the names of the reasons set in the bit mask, separated by a pipe, for example "FileCreate|Close".
+func (r Reason) String() string {
+	if r == 0 {
+		return "0"
+	}
+	parts := make([]string, 0)
+	for _, n := range reasonNames {
+		if r&n.bit == n.bit {
+			parts = append(parts, n.name)
+			r &^= n.bit
+		}
+	}
+	if r != 0 {
+		parts = append(parts, fmt.Sprintf("0x%x", uint32(r)))
+	}
+	return strings.Join(parts, "|")
+}
+
+// Record is a record of the USN journal. The FileReference identifies the MFT record of the file and the
+// ParentFileReference that of the directory containing it. FileName is the name of the file in that directory, at the
+// time of the change; for renames, a record with ReasonRenameOldName has the old name and parent, and a record with
+// ReasonRenameNewName the new ones.
+type Record struct {
+	MajorVersion        int
+	MinorVersion        int
+	FileReference       mft.FileReference
+	ParentFileReference mft.FileReference
+	Usn                 int64
+	TimeStamp           time.Time
+	Reason              Reason
+	SourceInfo          uint32
+	SecurityId          uint32
+	FileAttributes      mft.FileAttribute
+	FileName            string
+}
+
+// IsDirectory returns true when the record is of a directory.
+func (r *Record) IsDirectory() bool {
+	return r.FileAttributes&fileAttributeDirectory != 0
+}
+
+// ParseRecord parses a single USN record of version 2 or 3 at the start of b. It returns the Record and the length of
+// the record in bytes, which is where the next record starts.
+func ParseRecord(b []byte) (Record, int, error) {
+	if len(b) < 8 {
+		return Record{}, 0, fmt.Errorf("expected at least %d bytes but got %d", 8, len(b))
+	}
+	r := binutil.NewLittleEndianReader(b)
+	length := int(r.Uint32(0x00))
+	major := int(r.Uint16(0x04))
+	headerSize := 0
+	switch major {
+	case 2:
+		headerSize = v2HeaderSize
+	case 3:
+		headerSize = v3HeaderSize
+	default:
+		return Record{}, 0, fmt.Errorf("unsupported USN record version %d", major)
+	}
+	if length < headerSize {
+		return Record{}, 0, fmt.Errorf("USN record length %d is less than the header size %d", length, headerSize)
+	}
+	if len(b) < length {
+		return Record{}, 0, fmt.Errorf("expected at least %d bytes for USN record but got %d", length, len(b))
+	}
+
+	// Version 3 has 16 byte file ids instead of 8 byte file references; all following fields move by 16 bytes
+	referenceSize := 8
+	if major == 3 {
+		referenceSize = 16
+	}
+	fileReference, err := mft.ParseFileReference(r.Read(0x08, 8))
+	if err != nil {
+		return Record{}, 0, fmt.Errorf("unable to parse file reference: %v", err)
+	}
+	parentOffset := 0x08 + referenceSize
+	parentFileReference, err := mft.ParseFileReference(r.Read(parentOffset, 8))
+	if err != nil {
+		return Record{}, 0, fmt.Errorf("unable to parse parent file reference: %v", err)
+	}
+	offset := parentOffset + referenceSize
+	nameLength := int(r.Uint16(offset + 0x20))
+	nameOffset := int(r.Uint16(offset + 0x22))
+	if nameOffset+nameLength > length {
+		return Record{}, 0, fmt.Errorf("file name at offset %d with length %d exceeds USN record length %d", nameOffset, nameLength, length)
+	}
+	name := utf16.DecodeString(r.Read(nameOffset, nameLength), binary.LittleEndian)
+
+	return Record{
+		MajorVersion:        major,
+		MinorVersion:        int(r.Uint16(0x06)),
+		FileReference:       fileReference,
+		ParentFileReference: parentFileReference,
+		Usn:                 int64(r.Uint64(offset)),
+		TimeStamp:           mft.ConvertFileTime(r.Uint64(offset + 0x08)),
+		Reason:              Reason(r.Uint32(offset + 0x10)),
+		SourceInfo:          r.Uint32(offset + 0x14),
+		SecurityId:          r.Uint32(offset + 0x18),
+		FileAttributes:      mft.FileAttribute(r.Uint32(offset + 0x1C)),
+		FileName:            name,
+	}, length, nil
+}
+
+// ParseRecords parses all USN records in b, skipping the zeroes before, between and after the records. Records of
+// version 4 are skipped as well, since they do not describe a change of a name. Parsing stops at the first record
+// which cannot be parsed; the records parsed until then are returned together with the error.
+func ParseRecords(b []byte) ([]Record, error) {
+	records := make([]Record, 0)
+	offset := 0
+	for offset+8 <= len(b) {
+		r := binutil.NewLittleEndianReader(b[offset:])
+		length := int(r.Uint32(0x00))
+		if length == 0 {
+			offset += 8
+			continue
+		}
+		if r.Uint16(0x04) == 4 && length >= 8 && length%8 == 0 {
+			offset += length
+			continue
+		}
+		record, n, err := ParseRecord(b[offset:])
+		if err != nil {
+			return records, fmt.Errorf("unable to parse USN record at offset %d: %v", offset, err)
+		}
+		records = append(records, record)
+		offset += (n + 7) &^ 7
+	}
+	return records, nil
+}
@@ -0,0 +1,112 @@
+package usn_test
+
+import (
+	"encoding/binary"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/mfttest"
+	"github.com/t9t/gomft/usn"
+)
+
+// encode creates a USN record of the specified version (2 or 3), padded to a multiple of 8 bytes.
+func encode(version int, usnValue int64, reason usn.Reason, name string) []byte {
+	referenceSize := 8
+	if version == 3 {
+		referenceSize = 16
+	}
+	offset := 0x08 + 2*referenceSize
+	nameOffset := offset + 0x24
+	encoded := utf16.Encode([]rune(name))
+	length := nameOffset + len(encoded)*2
+	b := make([]byte, (length+7)&^7)
+	binary.LittleEndian.PutUint32(b, uint32(length))
+	binary.LittleEndian.PutUint16(b[0x04:], uint16(version))
+	binary.LittleEndian.PutUint64(b[0x08:], 0x0003000000000040)
+	binary.LittleEndian.PutUint64(b[0x08+referenceSize:], 0x0005000000000005)
+	binary.LittleEndian.PutUint64(b[offset:], uint64(usnValue))
+	binary.LittleEndian.PutUint64(b[offset+0x08:], 132253883781168862)
+	binary.LittleEndian.PutUint32(b[offset+0x10:], uint32(reason))
+	binary.LittleEndian.PutUint32(b[offset+0x18:], 0x105)
+	binary.LittleEndian.PutUint32(b[offset+0x1C:], 0x20)
+	binary.LittleEndian.PutUint16(b[offset+0x20:], uint16(len(encoded)*2))
+	binary.LittleEndian.PutUint16(b[offset+0x22:], uint16(nameOffset))
+	for i, c := range encoded {
+		binary.LittleEndian.PutUint16(b[nameOffset+i*2:], c)
+	}
+	return b
+}
+
+func TestParseRecord(t *testing.T) {
+	expected := usn.Record{
+		MajorVersion:        2,
+		FileReference:       mft.FileReference{RecordNumber: 0x40, SequenceNumber: 3},
+		ParentFileReference: mft.FileReference{RecordNumber: 5, SequenceNumber: 5},
+		Usn:                 1234,
+		TimeStamp:           mfttest.SampleTime,
+		Reason:              usn.ReasonFileCreate | usn.ReasonClose,
+		SecurityId:          0x105,
+		FileAttributes:      mft.FileAttributeArchive,
+		FileName:            "notes.txt",
+	}
+	b := encode(2, 1234, usn.ReasonFileCreate|usn.ReasonClose, "notes.txt")
+	r, n, err := usn.ParseRecord(b)
+	require.Nilf(t, err, "unable to parse record: %v", err)
+	assert.Equal(t, expected, r)
+	assert.Equal(t, 0x3C+18, n)
+	assert.False(t, r.IsDirectory())
+
+	expected.MajorVersion = 3
+	r, n, err = usn.ParseRecord(encode(3, 1234, usn.ReasonFileCreate|usn.ReasonClose, "notes.txt"))
+	require.Nilf(t, err, "unable to parse record: %v", err)
+	assert.Equal(t, expected, r)
+	assert.Equal(t, 0x4C+18, n)
+}
+
+func TestParseRecord_Invalid(t *testing.T) {
+	b := encode(2, 1, usn.ReasonClose, "a")
+	_, _, err := usn.ParseRecord(b[:0x3C])
+	assert.NotNil(t, err, "truncated")
+
+	binary.LittleEndian.PutUint16(b[0x04:], 4)
+	_, _, err = usn.ParseRecord(b)
+	assert.EqualError(t, err, "unsupported USN record version 4")
+
+	b = encode(2, 1, usn.ReasonClose, "a")
+	binary.LittleEndian.PutUint16(b[0x38:], 0x100)
+	_, _, err = usn.ParseRecord(b)
+	assert.NotNil(t, err, "name beyond the record")
+}
+
+func TestParseRecords(t *testing.T) {
+	data := make([]byte, 64)
+	data = append(data, encode(2, 1, usn.ReasonFileCreate, "a.txt")...)
+	data = append(data, encode(3, 2, usn.ReasonFileCreate|usn.ReasonClose, "a.txt")...)
+	data = append(data, make([]byte, 40)...)
+	// A range tracking record of version 4
+	v4 := make([]byte, 0x50)
+	binary.LittleEndian.PutUint32(v4, 0x50)
+	binary.LittleEndian.PutUint16(v4[0x04:], 4)
+	data = append(data, v4...)
+	data = append(data, encode(2, 3, usn.ReasonRenameOldName, "a.txt")...)
+
+	records, err := usn.ParseRecords(data)
+	require.Nilf(t, err, "unable to parse records: %v", err)
+	require.Equal(t, 3, len(records))
+	assert.Equal(t, []int64{1, 2, 3}, []int64{records[0].Usn, records[1].Usn, records[2].Usn})
+	assert.Equal(t, 3, records[1].MajorVersion)
+
+	records, err = usn.ParseRecords(append(data, 0xFF, 0, 0, 0, 2, 0, 0, 0))
+	assert.NotNil(t, err)
+	assert.Equal(t, 3, len(records))
+}
+
+func TestReason_String(t *testing.T) {
+	assert.Equal(t, "FileCreate|Close", (usn.ReasonFileCreate | usn.ReasonClose).String())
+	assert.Equal(t, "DataExtend|0x8", (usn.ReasonDataExtend | 0x8).String())
+	assert.Equal(t, "0", usn.Reason(0).String())
+	assert.True(t, (usn.ReasonFileCreate | usn.ReasonClose).Is(usn.ReasonClose))
+}
@@ -2,17 +2,47 @@ package utf16
 
 import (
 	"encoding/binary"
+	"sync"
 	"unicode/utf16"
+	"unicode/utf8"
 )
 
+// scratchPool holds buffers to decode into, so decoding a string only allocates the resulting string itself. File
+// names are at most 255 UTF-16 code units, so the initial capacity fits most names without growing.
+var scratchPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, 255*3)
+		return &b
+	},
+}
+
 // Decode the input data as UTF-16 using the provided byte order and convert the result to a string. The input data
 // length must be a multiple of 2. DecodeString will panic if that is not the case.
 func DecodeString(b []byte, bo binary.ByteOrder) string {
-	slen := len(b) / 2
-	shorts := make([]uint16, slen)
-	for i := 0; i < slen; i++ {
-		bi := i * 2
-		shorts[i] = bo.Uint16(b[bi : bi+2])
+	bp := scratchPool.Get().(*[]byte)
+	out := (*bp)[:0]
+
+	var enc [utf8.UTFMax]byte
+	for i := 0; i+2 <= len(b); i += 2 {
+		r := rune(bo.Uint16(b[i : i+2]))
+		if utf16.IsSurrogate(r) {
+			if i+4 <= len(b) {
+				if dec := utf16.DecodeRune(r, rune(bo.Uint16(b[i+2:i+4]))); dec != utf8.RuneError {
+					r = dec
+					i += 2
+				} else {
+					r = utf8.RuneError
+				}
+			} else {
+				r = utf8.RuneError
+			}
+		}
+		n := utf8.EncodeRune(enc[:], r)
+		out = append(out, enc[:n]...)
 	}
-	return string(utf16.Decode(shorts))
+
+	s := string(out)
+	*bp = out
+	scratchPool.Put(bp)
+	return s
 }
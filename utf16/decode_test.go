@@ -24,3 +24,19 @@ func TestDecodeString_BigEndian(t *testing.T) {
 	output := utf16.DecodeString(input, binary.BigEndian)
 	assert.Equal(t, "Hello, world 👌", output)
 }
+
+func TestDecodeString_InvalidSurrogates(t *testing.T) {
+	input, err := hex.DecodeString("41003dd842003dd8")
+	require.Nilf(t, err, "unable to convert input hex to []byte: %v", err)
+	output := utf16.DecodeString(input, binary.LittleEndian)
+	assert.Equal(t, "A�B�", output)
+}
+
+func BenchmarkDecodeString(b *testing.B) {
+	input, err := hex.DecodeString("480065006c006c006f002c00200077006f0072006c00640020003dd84cdc")
+	require.Nilf(b, err, "unable to convert input hex to []byte: %v", err)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		utf16.DecodeString(input, binary.LittleEndian)
+	}
+}
@@ -0,0 +1,113 @@
+package utf16
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Handling determines what DecodeStringWithOptions does with a character which may be a problem for consumers of the
+// decoded string.
+type Handling int
+
+const (
+	// HandleKeep keeps the character as is. Since an unpaired surrogate cannot be represented in a (valid UTF-8) Go
+	// string, it is replaced by the Unicode replacement character instead, like DecodeString does.
+	HandleKeep Handling = iota
+	// HandleReplace replaces the character by the Unicode replacement character U+FFFD.
+	HandleReplace
+	// HandleEscape replaces the character by an escape sequence of the form \uXXXX, containing the UTF-16 code unit in
+	// uppercase hexadecimal notation. When any kind of character is escaped, backslashes are escaped as well (as
+	// \u005C), so the original code units can always be recovered from the decoded string.
+	HandleEscape
+	// HandleError makes DecodeStringWithOptions return an error.
+	HandleError
+)
+
+// DecodeOptions control how DecodeStringWithOptions handles problematic characters. The zero value decodes the same
+// way DecodeString does.
+type DecodeOptions struct {
+	// Surrogates determines the handling of unpaired surrogates, which are accepted by NTFS but are not valid UTF-16.
+	Surrogates Handling
+	// Nul determines the handling of NUL (U+0000) characters embedded in a string.
+	Nul Handling
+	// Reserved determines the handling of characters which are not allowed in Windows file names: control characters
+	// (U+0001 to U+001F) and " * / : < > ? \ |. Such characters can appear in names of the POSIX namespace or in
+	// corrupt data.
+	Reserved Handling
+	// Normalize, when not nil, is applied to the decoded string. For example pass norm.NFC.String from
+	// golang.org/x/text/unicode/norm to obtain NFC normalized strings.
+	Normalize func(string) string
+}
+
+// escapes returns true if any kind of character is escaped.
+func (o DecodeOptions) escapes() bool {
+	return o.Surrogates == HandleEscape || o.Nul == HandleEscape || o.Reserved == HandleEscape
+}
+
+// DecodeStringWithOptions decodes the input data as UTF-16 like DecodeString does, handling unpaired surrogates, NUL
+// characters and reserved characters as specified in the DecodeOptions. An error is returned when a character is
+// encountered for which HandleError is specified.
+func DecodeStringWithOptions(b []byte, bo binary.ByteOrder, opts DecodeOptions) (string, error) {
+	bp := scratchPool.Get().(*[]byte)
+	out := (*bp)[:0]
+	defer func() {
+		*bp = out
+		scratchPool.Put(bp)
+	}()
+
+	escapes := opts.escapes()
+	var enc [utf8.UTFMax]byte
+	for i := 0; i+2 <= len(b); i += 2 {
+		r := rune(bo.Uint16(b[i : i+2]))
+		handling := HandleKeep
+		problem := ""
+		switch {
+		case utf16.IsSurrogate(r):
+			if i+4 <= len(b) {
+				if dec := utf16.DecodeRune(r, rune(bo.Uint16(b[i+2:i+4]))); dec != utf8.RuneError {
+					r = dec
+					i += 2
+					break
+				}
+			}
+			handling, problem = opts.Surrogates, "unpaired surrogate"
+			if handling == HandleKeep {
+				handling = HandleReplace
+			}
+		case r == 0:
+			handling, problem = opts.Nul, "NUL character"
+		case isReserved(r):
+			handling, problem = opts.Reserved, "reserved character"
+			if handling == HandleKeep && r == '\\' && escapes {
+				handling = HandleEscape
+			}
+		}
+
+		switch handling {
+		case HandleReplace:
+			r = utf8.RuneError
+		case HandleEscape:
+			out = append(out, fmt.Sprintf(`\u%04X`, r)...)
+			continue
+		case HandleError:
+			return "", fmt.Errorf("%s 0x%04x at offset %d", problem, r, i)
+		}
+		n := utf8.EncodeRune(enc[:], r)
+		out = append(out, enc[:n]...)
+	}
+
+	s := string(out)
+	if opts.Normalize != nil {
+		s = opts.Normalize(s)
+	}
+	return s, nil
+}
+
+// isReserved returns true if r is a control character or one of the characters which are not allowed in Windows file
+// names. NUL is checked separately.
+func isReserved(r rune) bool {
+	return r < 0x20 || (r < 0x80 && strings.ContainsRune(`"*/:<>?\|`, r))
+}
@@ -0,0 +1,76 @@
+package utf16_test
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/utf16"
+)
+
+func TestDecodeStringWithOptions_Default(t *testing.T) {
+	input := decodeHex(t, "41003dd8420000003a005c003dd84cdc")
+	output, err := utf16.DecodeStringWithOptions(input, binary.LittleEndian, utf16.DecodeOptions{})
+	require.Nilf(t, err, "unable to decode: %v", err)
+	assert.Equal(t, utf16.DecodeString(input, binary.LittleEndian), output)
+	assert.Equal(t, "A�B\x00:\\👌", output)
+}
+
+func TestDecodeStringWithOptions_Replace(t *testing.T) {
+	input := decodeHex(t, "41003dd8420000003a005c003dd84cdc")
+	opts := utf16.DecodeOptions{Surrogates: utf16.HandleReplace, Nul: utf16.HandleReplace, Reserved: utf16.HandleReplace}
+	output, err := utf16.DecodeStringWithOptions(input, binary.LittleEndian, opts)
+	require.Nilf(t, err, "unable to decode: %v", err)
+	assert.Equal(t, "A�B���👌", output)
+}
+
+func TestDecodeStringWithOptions_Escape(t *testing.T) {
+	input := decodeHex(t, "41003dd8420000003a005c003dd84cdc")
+	opts := utf16.DecodeOptions{Surrogates: utf16.HandleEscape, Nul: utf16.HandleEscape}
+	output, err := utf16.DecodeStringWithOptions(input, binary.LittleEndian, opts)
+	require.Nilf(t, err, "unable to decode: %v", err)
+	assert.Equal(t, `A\uD83DB\u0000:\u005C👌`, output)
+
+	opts.Reserved = utf16.HandleEscape
+	output, err = utf16.DecodeStringWithOptions(input, binary.LittleEndian, opts)
+	require.Nilf(t, err, "unable to decode: %v", err)
+	assert.Equal(t, `A\uD83DB\u0000\u003A\u005C👌`, output)
+}
+
+func TestDecodeStringWithOptions_Error(t *testing.T) {
+	tests := []struct {
+		input    string
+		opts     utf16.DecodeOptions
+		expected string
+	}{
+		{"41003dd84200", utf16.DecodeOptions{Surrogates: utf16.HandleError}, "unpaired surrogate 0xd83d at offset 2"},
+		{"41003dd8", utf16.DecodeOptions{Surrogates: utf16.HandleError}, "unpaired surrogate 0xd83d at offset 2"},
+		{"410000004200", utf16.DecodeOptions{Nul: utf16.HandleError}, "NUL character 0x0000 at offset 2"},
+		{"41002a00", utf16.DecodeOptions{Reserved: utf16.HandleError}, "reserved character 0x002a at offset 2"},
+		{"41000900", utf16.DecodeOptions{Reserved: utf16.HandleError}, "reserved character 0x0009 at offset 2"},
+	}
+	for _, test := range tests {
+		_, err := utf16.DecodeStringWithOptions(decodeHex(t, test.input), binary.LittleEndian, test.opts)
+		assert.EqualErrorf(t, err, test.expected, "input %s", test.input)
+	}
+
+	output, err := utf16.DecodeStringWithOptions(decodeHex(t, "41002a00"), binary.LittleEndian, utf16.DecodeOptions{Nul: utf16.HandleError})
+	require.Nilf(t, err, "unable to decode: %v", err)
+	assert.Equal(t, "A*", output)
+}
+
+func TestDecodeStringWithOptions_Normalize(t *testing.T) {
+	opts := utf16.DecodeOptions{Normalize: strings.ToUpper}
+	output, err := utf16.DecodeStringWithOptions(decodeHex(t, "61006200"), binary.LittleEndian, opts)
+	require.Nilf(t, err, "unable to decode: %v", err)
+	assert.Equal(t, "AB", output)
+}
+
+func decodeHex(t testing.TB, s string) []byte {
+	b, err := hex.DecodeString(s)
+	require.Nilf(t, err, "unable to convert input hex to []byte: %v", err)
+	return b
+}
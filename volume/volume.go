@@ -0,0 +1,253 @@
+/*
+	Package volume reads an NTFS volume, or an image of one, through an io.ReaderAt. A Volume locates the MFT using
+	the boot sector and the $MFT record, and gives access to the records and the data of their attributes.
+
+	Basic usage
+
+	Open the volume, then read records and attribute data from any number of goroutines.
+			// Error handling left out for brevity
+			f, err := os.Open("/dev/sdb1")
+			v, err := volume.Open(f, volume.Options{})
+			r, err := v.ReadRecord(5)
+			data, ok := r.FindFirstAttribute(mft.AttributeTypeData)
+			stream, err := v.OpenAttribute(data)
+			_, err = io.Copy(out, stream)
+
+	Implementation notes
+
+	All reads are positional ReadAt calls (pread for an *os.File), so there is no shared position which would have to
+	be moved (and locked) for each read. A single Volume can therefore serve concurrent readers, such as parallel
+	extraction or requests of a server, as long as the underlying io.ReaderAt is safe for concurrent use, which an
+	*os.File, a bytes.Reader and a memory mapped mmap.File are. Sequential readers, such as those returned by
+	MftReader and OpenAttribute, are io.SectionReaders with a position of their own.
+*/
+package volume
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/t9t/gomft/bootsect"
+	"github.com/t9t/gomft/dumpfile"
+	"github.com/t9t/gomft/fragment"
+	"github.com/t9t/gomft/mft"
+)
+
+// OemId is the OEM ID in the boot sector of NTFS volumes.
+const OemId = "NTFS    "
+
+// ErrNotNTFS is returned by Open when the boot sector does not contain the NTFS OEM ID.
+var ErrNotNTFS = errors.New("not an NTFS volume")
+
+// ErrNoVolume is returned by NewAttributeReader for a non-resident attribute when no volume is available to read its
+// data from.
+var ErrNoVolume = errors.New("the attribute is non-resident, but no volume is available")
+
+// InvalidDataRunsError is returned by Open when the data runs of the $MFT record are invalid, which means the volume
+// is corrupt (or not an NTFS volume after all) and reading the MFT would return garbage.
+type InvalidDataRunsError struct {
+	Warnings []mft.DataRunWarning
+}
+
+func (e *InvalidDataRunsError) Error() string {
+	problems := make([]string, 0, len(e.Warnings))
+	for _, w := range e.Warnings {
+		problems = append(problems, w.String())
+	}
+	return fmt.Sprintf("found %d invalid data runs in $MFT $DATA attribute: %s", len(e.Warnings), strings.Join(problems, "; "))
+}
+
+// Options configures Open.
+type Options struct {
+	// Parse are the options used to parse the $MFT record, and the records read by ReadRecord.
+	Parse mft.ParseOptions
+}
+
+// Volume is an NTFS volume read through an io.ReaderAt. It is safe for concurrent use when the io.ReaderAt is.
+type Volume struct {
+	r               io.ReaderAt
+	bootSector      bootsect.BootSector
+	bytesPerCluster int
+	mftRecord       mft.Record
+	mftFragments    []fragment.Fragment
+	mft             *fragment.ReaderAt
+	records         *dumpfile.File
+}
+
+// IsNTFS checks if r starts with an NTFS boot sector. Input which is too short to contain a boot sector is not NTFS.
+func IsNTFS(r io.ReaderAt) (bool, error) {
+	buf := make([]byte, 3+len(OemId))
+	n, err := r.ReadAt(buf, 0)
+	if n < len(buf) {
+		if err == nil || err == io.EOF {
+			return false, nil
+		}
+		return false, fmt.Errorf("unable to read boot sector: %v", err)
+	}
+	return bytes.Equal(buf[3:], []byte(OemId)), nil
+}
+
+// Open reads the boot sector and the $MFT record of the volume, and returns a Volume to read records and data from.
+func Open(r io.ReaderAt, opts Options) (*Volume, error) {
+	bootSectorData := make([]byte, 512)
+	if _, err := r.ReadAt(bootSectorData, 0); err != nil {
+		return nil, fmt.Errorf("unable to read boot sector: %v", err)
+	}
+	bootSector, err := bootsect.Parse(bootSectorData)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse boot sector: %v", err)
+	}
+	if bootSector.OemId != OemId {
+		return nil, ErrNotNTFS
+	}
+	bytesPerCluster := bootSector.BytesPerSector * bootSector.SectorsPerCluster
+	recordSize := bootSector.FileRecordSegmentSizeInBytes
+	if bytesPerCluster <= 0 || recordSize <= 0 {
+		return nil, fmt.Errorf("invalid cluster size %d or record size %d in boot sector", bytesPerCluster, recordSize)
+	}
+
+	mftOffset := int64(bootSector.MftClusterNumber) * int64(bytesPerCluster)
+	mftData := make([]byte, recordSize)
+	if _, err := r.ReadAt(mftData, mftOffset); err != nil {
+		return nil, fmt.Errorf("unable to read $MFT record at offset %d: %v", mftOffset, err)
+	}
+	record, err := mft.ParseRecordWithOptions(mftData, opts.Parse)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse $MFT record: %v", err)
+	}
+
+	dataAttributes := record.FindAttributes(mft.AttributeTypeData)
+	if len(dataAttributes) != 1 {
+		return nil, fmt.Errorf("expected 1 $DATA attribute in $MFT record but found %d", len(dataAttributes))
+	}
+	if dataAttributes[0].Resident {
+		return nil, errors.New("$DATA attribute in $MFT record is resident")
+	}
+	dataRuns, err := mft.ParseDataRuns(dataAttributes[0].Data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse data runs of $MFT $DATA attribute: %v", err)
+	}
+	if len(dataRuns) == 0 {
+		return nil, errors.New("no data runs in $MFT $DATA attribute")
+	}
+	totalClusters := bootSector.TotalSectors / uint64(bootSector.SectorsPerCluster)
+	if warnings := mft.ValidateDataRuns(dataRuns, totalClusters); len(warnings) > 0 {
+		return nil, &InvalidDataRunsError{Warnings: warnings}
+	}
+
+	fragments := mft.DataRunsToFragments(dataRuns, bytesPerCluster)
+	mftReader := fragment.NewReaderAt(r, fragments)
+	return &Volume{
+		r:               r,
+		bootSector:      bootSector,
+		bytesPerCluster: bytesPerCluster,
+		mftRecord:       record,
+		mftFragments:    fragments,
+		mft:             mftReader,
+		records:         dumpfile.New(mftReader, mftReader.Size(), dumpfile.Options{RecordSize: recordSize, Parse: opts.Parse}),
+	}, nil
+}
+
+// BootSector returns the parsed boot sector of the volume.
+func (v *Volume) BootSector() bootsect.BootSector {
+	return v.bootSector
+}
+
+// BytesPerCluster returns the cluster size of the volume.
+func (v *Volume) BytesPerCluster() int {
+	return v.bytesPerCluster
+}
+
+// RecordSize returns the size of an MFT record in bytes.
+func (v *Volume) RecordSize() int {
+	return v.records.RecordSize()
+}
+
+// RecordCount returns the number of records (in use or not) the MFT has room for.
+func (v *Volume) RecordCount() uint64 {
+	return v.records.RecordCount()
+}
+
+// MftRecord returns the parsed $MFT record, which is record 0.
+func (v *Volume) MftRecord() mft.Record {
+	return v.mftRecord
+}
+
+// MftFragments returns the fragments of the volume which contain the MFT, in order.
+func (v *Volume) MftFragments() []fragment.Fragment {
+	return v.mftFragments
+}
+
+// MftSize returns the size of the MFT in bytes.
+func (v *Volume) MftSize() int64 {
+	return v.mft.Size()
+}
+
+// MFT returns an io.ReaderAt over the MFT data, like an MFT dump file. It can be shared by concurrent readers.
+func (v *Volume) MFT() *fragment.ReaderAt {
+	return v.mft
+}
+
+// MftReader returns a new reader of the MFT data from start to end, for example for mft.ParseAll. Each reader has
+// its own position, so multiple readers can be used at the same time.
+func (v *Volume) MftReader() *io.SectionReader {
+	return io.NewSectionReader(v.mft, 0, v.mft.Size())
+}
+
+// ReaderAt returns the underlying io.ReaderAt of the volume.
+func (v *Volume) ReaderAt() io.ReaderAt {
+	return v.r
+}
+
+// ReadRecordData returns the raw data of the record with the number, without the fixup applied.
+func (v *Volume) ReadRecordData(number uint64) ([]byte, error) {
+	return v.records.ReadRecordData(number)
+}
+
+// ReadRecord reads and parses the record with the number.
+func (v *Volume) ReadRecord(number uint64) (mft.Record, error) {
+	return v.records.ReadRecord(number)
+}
+
+// OpenAttribute returns a reader of the data of the attribute, of which the size is the ActualSize (or the length of
+// the Data for resident attributes). The data of non-resident attributes is read from the volume on demand;
+// compressed attributes are decompressed. See NewAttributeReader.
+func (v *Volume) OpenAttribute(a mft.Attribute) (*io.SectionReader, error) {
+	return NewAttributeReader(v.r, v.bytesPerCluster, a)
+}
+
+// NewAttributeReader returns a reader of the data of the attribute like Volume.OpenAttribute, reading the data of a
+// non-resident attribute from r, a volume with clusters of bytesPerCluster bytes. It can be used when the records do
+// not come from a Volume, such as those of an MFT dump of which the volume is available separately. Sparse runs read as
+// zeroes and compressed attributes are decompressed. ErrNoVolume is returned for a non-resident attribute when r is
+// nil or bytesPerCluster is not positive, and an error is returned when the data runs cannot be parsed or cover less
+// than the size of the attribute.
+func NewAttributeReader(r io.ReaderAt, bytesPerCluster int, a mft.Attribute) (*io.SectionReader, error) {
+	if a.Resident {
+		return io.NewSectionReader(bytes.NewReader(a.Data), 0, int64(len(a.Data))), nil
+	}
+	if r == nil || bytesPerCluster <= 0 {
+		return nil, ErrNoVolume
+	}
+	runs, err := mft.ParseDataRuns(a.Data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse data runs: %v", err)
+	}
+	fragments := mft.DataRunsToFragments(runs, bytesPerCluster)
+	covered := int64(0)
+	for _, f := range fragments {
+		covered += f.Length
+	}
+	size := int64(a.ActualSize)
+	if size < 0 || covered < size {
+		return nil, fmt.Errorf("data runs cover %d bytes, but the attribute is %d bytes", covered, a.ActualSize)
+	}
+	if a.Flags&mft.AttributeFlagsCompressed != 0 {
+		unitSize := int64(bytesPerCluster) * fragment.DefaultCompressionUnitClusters
+		return io.NewSectionReader(fragment.NewCompressedReaderAt(r, fragments, unitSize, size), 0, size), nil
+	}
+	return io.NewSectionReader(fragment.NewReaderAt(r, fragments), 0, size), nil
+}
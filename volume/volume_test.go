@@ -0,0 +1,169 @@
+package volume_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/mfttest"
+	"github.com/t9t/gomft/volume"
+)
+
+func TestOpen(t *testing.T) {
+	large := bytes.Repeat([]byte("0123456789"), 1000)
+	v := mfttest.NewVolume().WithFile("/a.txt", []byte("hello")).WithFile("/dir/large.bin", large).Build()
+
+	vol, err := volume.Open(bytes.NewReader(v.Data), volume.Options{})
+	require.Nil(t, err)
+	assert.Equal(t, mfttest.VolumeClusterSize, vol.BytesPerCluster())
+	assert.Equal(t, mfttest.VolumeRecordSize, vol.RecordSize())
+	assert.Equal(t, v.MftLength, vol.MftSize())
+	assert.Equal(t, uint64(v.MftLength/mfttest.VolumeRecordSize), vol.RecordCount())
+	assert.Equal(t, uint64(0), vol.MftRecord().FileReference.RecordNumber)
+	require.Equal(t, 1, len(vol.MftFragments()))
+	assert.Equal(t, v.MftOffset, vol.MftFragments()[0].Offset)
+
+	mftData, err := ioutil.ReadAll(vol.MftReader())
+	require.Nil(t, err)
+	assert.Equal(t, v.Mft(), mftData)
+
+	r, err := vol.ReadRecord(v.Records["/a.txt"])
+	require.Nil(t, err)
+	assert.Equal(t, "hello", readData(t, vol, r))
+
+	r, err = vol.ReadRecord(v.Records["/dir/large.bin"])
+	require.Nil(t, err)
+	assert.Equal(t, string(large), readData(t, vol, r))
+}
+
+func TestOpen_Concurrent(t *testing.T) {
+	b := mfttest.NewVolume()
+	for i := 0; i < 20; i++ {
+		b.WithFile(fmt.Sprintf("/file%d.txt", i), bytes.Repeat([]byte{byte('a' + i)}, 1000+i))
+	}
+	v := b.Build()
+	vol, err := volume.Open(bytes.NewReader(v.Data), volume.Options{})
+	require.Nil(t, err)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				r, err := vol.ReadRecord(v.Records[fmt.Sprintf("/file%d.txt", i)])
+				if err != nil {
+					errs <- err
+					return
+				}
+				data, _ := r.FindFirstAttribute(mft.AttributeTypeData)
+				stream, err := vol.OpenAttribute(data)
+				if err != nil {
+					errs <- err
+					return
+				}
+				content, err := ioutil.ReadAll(stream)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if expected := bytes.Repeat([]byte{byte('a' + i)}, 1000+i); !bytes.Equal(expected, content) {
+					errs <- fmt.Errorf("unexpected content of file %d", i)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.Nil(t, err)
+	}
+}
+
+func TestOpen_Invalid(t *testing.T) {
+	v := mfttest.NewVolume().Build()
+
+	_, err := volume.Open(bytes.NewReader(v.Mft()), volume.Options{})
+	assert.Equal(t, volume.ErrNotNTFS, err)
+
+	_, err = volume.Open(bytes.NewReader(v.Data[:100]), volume.Options{})
+	assert.NotNil(t, err)
+}
+
+func TestIsNTFS(t *testing.T) {
+	v := mfttest.NewVolume().Build()
+	for _, c := range []struct {
+		name     string
+		data     []byte
+		expected bool
+	}{
+		{"volume", v.Data, true},
+		{"dump", v.Mft(), false},
+		{"short", v.Data[:5], false},
+		{"empty", []byte{}, false},
+	} {
+		ok, err := volume.IsNTFS(bytes.NewReader(c.data))
+		require.Nilf(t, err, "error for %s", c.name)
+		assert.Equalf(t, c.expected, ok, "result for %s", c.name)
+	}
+}
+
+func TestNewAttributeReader(t *testing.T) {
+	// 4 clusters of 512 bytes: cluster 0 contains 1s, cluster 1 a compressed unit of "abc" repeated, cluster 2 2s and
+	// cluster 3 3s
+	data := append(bytes.Repeat([]byte{1}, 512), make([]byte, 512)...)
+	copy(data[512:], []byte{0x05, 0xb0, 0x08, 'a', 'b', 'c', 0xfa, 0x27, 0x00, 0x00})
+	data = append(data, bytes.Repeat([]byte{2}, 512)...)
+	data = append(data, bytes.Repeat([]byte{3}, 512)...)
+	read := func(a mft.Attribute) (string, error) {
+		r, err := volume.NewAttributeReader(bytes.NewReader(data), 512, a)
+		if err != nil {
+			return "", err
+		}
+		b, err := ioutil.ReadAll(r)
+		require.Nilf(t, err, "unable to read: %v", err)
+		return string(b), nil
+	}
+	nonResident := func(flags mft.AttributeFlags, size uint64, runs ...mft.DataRun) mft.Attribute {
+		return mft.Attribute{Type: mft.AttributeTypeData, Flags: flags, ActualSize: size, Data: mfttest.EncodeDataRuns(runs)}
+	}
+
+	out, err := read(mft.Attribute{Type: mft.AttributeTypeData, Resident: true, Data: []byte("hello")})
+	require.Nil(t, err)
+	assert.Equal(t, "hello", out)
+
+	out, err = read(nonResident(mft.AttributeFlagsSparse, 1100,
+		mft.DataRun{OffsetCluster: 2, LengthInClusters: 1}, mft.DataRun{LengthInClusters: 1, Sparse: true}, mft.DataRun{OffsetCluster: 1, LengthInClusters: 1}))
+	require.Nil(t, err)
+	assert.Equal(t, string(bytes.Repeat([]byte{2}, 512))+string(make([]byte, 512))+string(bytes.Repeat([]byte{3}, 76)), out)
+
+	out, err = read(nonResident(mft.AttributeFlagsCompressed, 100,
+		mft.DataRun{OffsetCluster: 1, LengthInClusters: 1}, mft.DataRun{LengthInClusters: 15, Sparse: true}))
+	require.Nil(t, err)
+	assert.Equal(t, string(bytes.Repeat([]byte("abc"), 34)[:100]), out)
+
+	_, err = read(nonResident(0, 600, mft.DataRun{OffsetCluster: 0, LengthInClusters: 1}))
+	assert.EqualError(t, err, "data runs cover 512 bytes, but the attribute is 600 bytes")
+	_, err = read(mft.Attribute{Type: mft.AttributeTypeData, Data: []byte{0x11, 0x01}})
+	assert.EqualError(t, err, "unable to parse data runs: expected at least 3 bytes of datarun data but is 2")
+
+	_, err = volume.NewAttributeReader(nil, 512, nonResident(0, 10, mft.DataRun{OffsetCluster: 0, LengthInClusters: 1}))
+	assert.Equal(t, volume.ErrNoVolume, err)
+}
+
+func readData(t *testing.T, vol *volume.Volume, r mft.Record) string {
+	data, ok := r.FindFirstAttribute(mft.AttributeTypeData)
+	require.True(t, ok)
+	stream, err := vol.OpenAttribute(data)
+	require.Nil(t, err)
+	b, err := ioutil.ReadAll(stream)
+	require.Nil(t, err)
+	return string(b)
+}
@@ -0,0 +1,58 @@
+package wof
+
+import "fmt"
+
+// huffman decodes canonical Huffman codes, as used by both XPRESS and LZX: codes of the same length are consecutive
+// integers in the order of their symbols, and shorter codes precede longer codes.
+type huffman struct {
+	maxLength int
+	counts    [17]int // number of codes of each length
+	symbols   []uint16
+}
+
+// newHuffman creates a decoder for the code with the specified code length of each symbol. A length of 0 means the
+// symbol is not used. The code may be incomplete, but not over-subscribed.
+func newHuffman(lengths []byte, maxLength int) (*huffman, error) {
+	h := &huffman{maxLength: maxLength, symbols: make([]uint16, 0, len(lengths))}
+	for _, l := range lengths {
+		if int(l) > maxLength {
+			return nil, fmt.Errorf("code length %d exceeds maximum of %d", l, maxLength)
+		}
+		h.counts[l]++
+	}
+	h.counts[0] = 0
+
+	left := 1
+	for l := 1; l <= maxLength; l++ {
+		left = left<<1 - h.counts[l]
+		if left < 0 {
+			return nil, fmt.Errorf("over-subscribed code with %d codes of length %d", h.counts[l], l)
+		}
+	}
+
+	for l := 1; l <= maxLength; l++ {
+		for symbol, sl := range lengths {
+			if int(sl) == l {
+				h.symbols = append(h.symbols, uint16(symbol))
+			}
+		}
+	}
+	return h, nil
+}
+
+// decode decodes the symbol of which the code is in the most significant bits of bits. It returns the symbol and the
+// length of its code, or a length of 0 when the bits do not contain a valid code.
+func (h *huffman) decode(bits uint32) (int, int) {
+	code, first, index := 0, 0, 0
+	for l := 1; l <= h.maxLength; l++ {
+		code |= int(bits>>uint(32-l)) & 1
+		count := h.counts[l]
+		if code-first < count {
+			return int(h.symbols[index+code-first]), l
+		}
+		index += count
+		first = (first + count) << 1
+		code <<= 1
+	}
+	return 0, 0
+}
@@ -0,0 +1,337 @@
+package wof
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	lzxBlockTypeVerbatim     = 1
+	lzxBlockTypeAligned      = 2
+	lzxBlockTypeUncompressed = 3
+
+	lzxDefaultBlockSize  = 32768
+	lzxMinMatchLength    = 2
+	lzxNumChars          = 256
+	lzxNumLengthHeaders  = 8
+	lzxNumPrimaryLengths = 7
+	lzxNumLengthSymbols  = 249
+	lzxNumOffsetSlots    = 30 // for a window of 32 KiB
+	lzxNumMainSymbols    = lzxNumChars + lzxNumOffsetSlots*lzxNumLengthHeaders
+	lzxNumPrecodeSymbols = 20
+	lzxNumAlignedSymbols = 8
+	lzxMaxCodeLength     = 16
+	lzxMaxPrecodeLength  = 15
+	lzxMaxAlignedLength  = 7
+	lzxNumRecentOffsets  = 3
+	lzxOffsetAdjustment  = lzxNumRecentOffsets - 1
+
+	// lzxE8FileSize is the file size used for the x86 call instruction (E8) translation, which is fixed in the WIM
+	// variant of LZX.
+	lzxE8FileSize = 12000000
+)
+
+// lzxOffsetSlotBase and lzxExtraOffsetBits contain the base value and the number of extra bits of each offset slot.
+var (
+	lzxOffsetSlotBase = [lzxNumOffsetSlots]int{
+		0, 1, 2, 3, 4, 6, 8, 12, 16, 24, 32, 48, 64, 96, 128, 192, 256, 384, 512, 768, 1024, 1536, 2048, 3072, 4096,
+		6144, 8192, 12288, 16384, 24576,
+	}
+	lzxExtraOffsetBits = [lzxNumOffsetSlots]uint{
+		0, 0, 0, 0, 1, 1, 2, 2, 3, 3, 4, 4, 5, 5, 6, 6, 7, 7, 8, 8, 9, 9, 10, 10, 11, 11, 12, 12, 13, 13,
+	}
+)
+
+// decompressLzx decompresses data in the LZX format as used by WIM files into dst, which is at most 32 KiB. The data
+// consists of blocks, each either verbatim (Huffman coded literals and matches), aligned (like verbatim, with an
+// additional code for the lowest 3 bits of match offsets) or uncompressed. The code lengths of the main and length
+// codes are transmitted as differences to those of the previous block.
+func decompressLzx(dst, src []byte) error {
+	if len(dst) > lzxDefaultBlockSize {
+		return fmt.Errorf("decompressed size %d exceeds LZX window size of %d", len(dst), lzxDefaultBlockSize)
+	}
+	s := &lzxStream{src: src}
+	mainLengths := make([]byte, lzxNumMainSymbols)
+	lengthLengths := make([]byte, lzxNumLengthSymbols)
+	recent := [lzxNumRecentOffsets]int{1, 1, 1}
+	out := 0
+	for out < len(dst) {
+		blockType := s.bits(3)
+		blockSize := lzxDefaultBlockSize
+		if s.bits(1) == 0 {
+			blockSize = int(s.bits(16))
+		}
+		if s.err != nil {
+			return s.err
+		}
+		if blockSize == 0 || blockSize > len(dst)-out {
+			return fmt.Errorf("invalid block size %d with %d bytes remaining", blockSize, len(dst)-out)
+		}
+
+		switch blockType {
+		case lzxBlockTypeVerbatim, lzxBlockTypeAligned:
+			var aligned *huffman
+			if blockType == lzxBlockTypeAligned {
+				alignedLengths := make([]byte, lzxNumAlignedSymbols)
+				for i := range alignedLengths {
+					alignedLengths[i] = byte(s.bits(3))
+				}
+				var err error
+				if aligned, err = newHuffman(alignedLengths, lzxMaxAlignedLength); err != nil {
+					return fmt.Errorf("invalid aligned offset code: %v", err)
+				}
+			}
+			if err := s.readLengths(mainLengths[:lzxNumChars]); err != nil {
+				return fmt.Errorf("unable to read main code lengths: %v", err)
+			}
+			if err := s.readLengths(mainLengths[lzxNumChars:]); err != nil {
+				return fmt.Errorf("unable to read main code lengths: %v", err)
+			}
+			if err := s.readLengths(lengthLengths); err != nil {
+				return fmt.Errorf("unable to read length code lengths: %v", err)
+			}
+			main, err := newHuffman(mainLengths, lzxMaxCodeLength)
+			if err != nil {
+				return fmt.Errorf("invalid main code: %v", err)
+			}
+			length, err := newHuffman(lengthLengths, lzxMaxCodeLength)
+			if err != nil {
+				return fmt.Errorf("invalid length code: %v", err)
+			}
+			if err := s.decodeBlock(dst[:out+blockSize], out, main, length, aligned, &recent); err != nil {
+				return err
+			}
+		case lzxBlockTypeUncompressed:
+			s.align()
+			for i := range recent {
+				recent[i] = int(s.uint32())
+			}
+			if s.err != nil {
+				return s.err
+			}
+			if s.pos+blockSize > len(src) {
+				return fmt.Errorf("uncompressed block of %d bytes exceeds data at offset %d", blockSize, s.pos)
+			}
+			copy(dst[out:], src[s.pos:s.pos+blockSize])
+			s.pos += blockSize
+			if blockSize%2 != 0 {
+				s.pos++
+			}
+		default:
+			return fmt.Errorf("invalid block type %d", blockType)
+		}
+		out += blockSize
+	}
+	undoE8Translation(dst)
+	return nil
+}
+
+// lzxStream reads the bit stream of LZX data: 16-bit Little Endian words, of which the bits are read starting at the
+// most significant bit. Only the word currently being read is buffered, so the stream can be aligned to the next word
+// for uncompressed blocks.
+type lzxStream struct {
+	src  []byte
+	pos  int
+	word uint32
+	left uint // number of unread bits in word
+	err  error
+}
+
+// bit reads a single bit. Reading beyond the end of the data sets err and returns zeros.
+func (s *lzxStream) bit() uint32 {
+	if s.left == 0 {
+		if s.pos+2 > len(s.src) {
+			if s.err == nil {
+				s.err = fmt.Errorf("unexpected end of data at offset %d", s.pos)
+			}
+			return 0
+		}
+		s.word = uint32(binary.LittleEndian.Uint16(s.src[s.pos:]))
+		s.pos += 2
+		s.left = 16
+	}
+	s.left--
+	return (s.word >> s.left) & 1
+}
+
+// bits reads n bits (at most 32) as an unsigned integer.
+func (s *lzxStream) bits(n int) uint32 {
+	v := uint32(0)
+	for i := 0; i < n; i++ {
+		v = v<<1 | s.bit()
+	}
+	return v
+}
+
+// peek returns the next 16 bits in the most significant bits of the result, without consuming them.
+func (s *lzxStream) peek() uint32 {
+	saved := *s
+	v := s.bits(lzxMaxCodeLength)
+	*s = saved
+	return v << (32 - lzxMaxCodeLength)
+}
+
+// symbol decodes the next symbol using the Huffman code h.
+func (s *lzxStream) symbol(h *huffman) (int, error) {
+	symbol, length := h.decode(s.peek())
+	if length == 0 {
+		return 0, fmt.Errorf("invalid Huffman code at offset %d", s.pos)
+	}
+	s.bits(length)
+	return symbol, s.err
+}
+
+// align skips the rest of the current word; when no bits of it were read yet, the entire next word is skipped.
+func (s *lzxStream) align() {
+	if s.left == 0 {
+		s.bits(16)
+	}
+	s.left = 0
+}
+
+// uint32 reads a 32-bit Little Endian value from the byte stream, which must be aligned.
+func (s *lzxStream) uint32() uint32 {
+	if s.pos+4 > len(s.src) {
+		if s.err == nil {
+			s.err = fmt.Errorf("unexpected end of data at offset %d", s.pos)
+		}
+		return 0
+	}
+	v := binary.LittleEndian.Uint32(s.src[s.pos:])
+	s.pos += 4
+	return v
+}
+
+// readLengths reads code lengths, which are coded using a pretree, as differences to the previous lengths.
+func (s *lzxStream) readLengths(lengths []byte) error {
+	precodeLengths := make([]byte, lzxNumPrecodeSymbols)
+	for i := range precodeLengths {
+		precodeLengths[i] = byte(s.bits(4))
+	}
+	precode, err := newHuffman(precodeLengths, lzxMaxPrecodeLength)
+	if err != nil {
+		return fmt.Errorf("invalid pretree: %v", err)
+	}
+
+	for i := 0; i < len(lengths); {
+		symbol, err := s.symbol(precode)
+		if err != nil {
+			return err
+		}
+		run := 1
+		value := byte(0)
+		switch symbol {
+		case 17:
+			run = 4 + int(s.bits(4))
+		case 18:
+			run = 20 + int(s.bits(5))
+		case 19:
+			run = 4 + int(s.bits(1))
+			if symbol, err = s.symbol(precode); err != nil {
+				return err
+			}
+			if symbol > 16 {
+				return fmt.Errorf("invalid pretree symbol %d in run", symbol)
+			}
+			value = byte((int(lengths[i]) - symbol + 17) % 17)
+		default:
+			value = byte((int(lengths[i]) - symbol + 17) % 17)
+		}
+		if run > len(lengths)-i {
+			run = len(lengths) - i
+		}
+		for j := 0; j < run; j++ {
+			lengths[i] = value
+			i++
+		}
+	}
+	return s.err
+}
+
+// decodeBlock decodes literals and matches of a verbatim or aligned block into dst, starting at position out, until
+// dst is full.
+func (s *lzxStream) decodeBlock(dst []byte, out int, main, length, aligned *huffman, recent *[lzxNumRecentOffsets]int) error {
+	for out < len(dst) {
+		symbol, err := s.symbol(main)
+		if err != nil {
+			return err
+		}
+		if symbol < lzxNumChars {
+			dst[out] = byte(symbol)
+			out++
+			continue
+		}
+
+		symbol -= lzxNumChars
+		matchLength := symbol%lzxNumLengthHeaders + lzxMinMatchLength
+		if symbol%lzxNumLengthHeaders == lzxNumPrimaryLengths {
+			extra, err := s.symbol(length)
+			if err != nil {
+				return err
+			}
+			matchLength += extra
+		}
+
+		slot := symbol / lzxNumLengthHeaders
+		var offset int
+		if slot < lzxNumRecentOffsets {
+			offset = recent[slot]
+			recent[slot] = recent[0]
+		} else {
+			extraBits := lzxExtraOffsetBits[slot]
+			offset = lzxOffsetSlotBase[slot] - lzxOffsetAdjustment
+			if aligned != nil && extraBits >= 3 {
+				offset += int(s.bits(int(extraBits-3))) << 3
+				low, err := s.symbol(aligned)
+				if err != nil {
+					return err
+				}
+				offset += low
+			} else {
+				offset += int(s.bits(int(extraBits)))
+			}
+			recent[2] = recent[1]
+			recent[1] = recent[0]
+		}
+		recent[0] = offset
+
+		if s.err != nil {
+			return s.err
+		}
+		if offset > out {
+			return fmt.Errorf("match offset %d exceeds decompressed data of %d bytes", offset, out)
+		}
+		if matchLength > len(dst)-out {
+			return fmt.Errorf("match length %d exceeds remaining %d bytes of block", matchLength, len(dst)-out)
+		}
+		for i := 0; i < matchLength; i++ {
+			dst[out] = dst[out-offset]
+			out++
+		}
+	}
+	return nil
+}
+
+// undoE8Translation reverts the translation of the targets of x86 call instructions (opcode E8) from relative to
+// absolute addresses, which the compressor applies to make them compress better.
+func undoE8Translation(b []byte) {
+	if len(b) <= 10 {
+		return
+	}
+	for i := 0; i < len(b)-10; {
+		if b[i] != 0xE8 {
+			i++
+			continue
+		}
+		abs := int32(binary.LittleEndian.Uint32(b[i+1:]))
+		pos := int32(i)
+		if abs >= 0 {
+			if abs < lzxE8FileSize {
+				binary.LittleEndian.PutUint32(b[i+1:], uint32(abs-pos))
+			}
+		} else if abs >= -pos {
+			binary.LittleEndian.PutUint32(b[i+1:], uint32(abs+lzxE8FileSize))
+		}
+		i += 5
+	}
+}
@@ -0,0 +1,213 @@
+package wof_test
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/wof"
+)
+
+func TestDecompress_LzxVerbatim(t *testing.T) {
+	expected := []byte("abcabcabcabc" + "xyz" + "ab" + "ab" + "ab" + "abababababababababab" + "bab")
+	w := &lzxWriter{}
+	w.verbatim(len(expected), false, []token{
+		literal('a'), literal('b'), literal('c'), match(9, 3),
+		literal('x'), literal('y'), literal('z'), match(2, 15),
+		match(2, 2), repeat(2, 0), repeat(20, 0), repeat(3, 1),
+	})
+
+	out := make([]byte, len(expected))
+	err := wof.Decompress(out, w.bytes(), wof.AlgorithmLzx)
+	require.Nilf(t, err, "unable to decompress: %v", err)
+	assert.Equal(t, expected, out)
+}
+
+func TestDecompress_LzxAligned(t *testing.T) {
+	expected := make([]byte, 0, 110)
+	tokens := make([]token, 0, 101)
+	for i := 0; i < 100; i++ {
+		expected = append(expected, byte(i))
+		tokens = append(tokens, literal(byte(i)))
+	}
+	expected = append(expected, expected[:10]...)
+	tokens = append(tokens, match(10, 100))
+
+	w := &lzxWriter{}
+	w.verbatim(len(expected), true, tokens)
+
+	out := make([]byte, len(expected))
+	err := wof.Decompress(out, w.bytes(), wof.AlgorithmLzx)
+	require.Nilf(t, err, "unable to decompress: %v", err)
+	assert.Equal(t, expected, out)
+}
+
+func TestDecompress_LzxUncompressed(t *testing.T) {
+	w := &lzxWriter{}
+	w.uncompressed([]byte("hello"), [3]uint32{5, 1, 1})
+	w.verbatim(5, false, []token{repeat(5, 0)})
+	w.uncompressed([]byte("!"), [3]uint32{1, 1, 1})
+
+	out := make([]byte, 11)
+	err := wof.Decompress(out, w.bytes(), wof.AlgorithmLzx)
+	require.Nilf(t, err, "unable to decompress: %v", err)
+	assert.Equal(t, []byte("hellohello!"), out)
+}
+
+func TestDecompress_LzxE8Translation(t *testing.T) {
+	data := make([]byte, 16)
+	data[5] = 0xE8
+	binary.LittleEndian.PutUint32(data[6:], 10)
+	w := &lzxWriter{}
+	w.uncompressed(data, [3]uint32{1, 1, 1})
+
+	expected := make([]byte, 16)
+	expected[5] = 0xE8
+	binary.LittleEndian.PutUint32(expected[6:], 5)
+
+	out := make([]byte, len(data))
+	err := wof.Decompress(out, w.bytes(), wof.AlgorithmLzx)
+	require.Nilf(t, err, "unable to decompress: %v", err)
+	assert.Equal(t, expected, out)
+}
+
+func TestDecompress_LzxInvalid(t *testing.T) {
+	out := make([]byte, 10)
+	err := wof.Decompress(out, nil, wof.AlgorithmLzx)
+	assert.EqualError(t, err, "unexpected end of data at offset 0")
+
+	w := &bitWriter{}
+	w.write(0, 3)
+	w.write(0, 1)
+	w.write(5, 16)
+	err = wof.Decompress(out, w.bytes(), wof.AlgorithmLzx)
+	assert.EqualError(t, err, "invalid block type 0")
+
+	w = &bitWriter{}
+	w.write(3, 3)
+	w.write(0, 1)
+	w.write(100, 16)
+	err = wof.Decompress(out, w.bytes(), wof.AlgorithmLzx)
+	assert.EqualError(t, err, "invalid block size 100 with 10 bytes remaining")
+
+	lw := &lzxWriter{}
+	lw.verbatim(10, false, []token{literal('a'), match(9, 2)})
+	err = wof.Decompress(out, lw.bytes(), wof.AlgorithmLzx)
+	assert.EqualError(t, err, "match offset 2 exceeds decompressed data of 1 bytes")
+
+	err = wof.Decompress(make([]byte, 40000), nil, wof.AlgorithmLzx)
+	assert.EqualError(t, err, "decompressed size 40000 exceeds LZX window size of 32768")
+}
+
+// repeat returns a match token using one of the 3 recent offsets.
+func repeat(length, slot int) token {
+	return token{length: length, offset: -slot - 1}
+}
+
+var (
+	lzxTestSlotBase  = []int{0, 1, 2, 3, 4, 6, 8, 12, 16, 24, 32, 48, 64, 96, 128, 192, 256, 384, 512, 768, 1024}
+	lzxTestExtraBits = []int{0, 0, 0, 0, 1, 1, 2, 2, 3, 3, 4, 4, 5, 5, 6, 6, 7, 7, 8, 8, 9}
+)
+
+// lzxWriter writes LZX blocks for tests. Verbatim and aligned blocks use a main code in which every symbol has length
+// 9 and a length code in which every symbol has length 8, so the code of each symbol is its own value. Aligned blocks
+// use an aligned offset code of length 3.
+type lzxWriter struct {
+	bitWriter
+	written bool // whether the main and length code lengths were sent before
+}
+
+func (w *lzxWriter) header(blockType, size int) {
+	w.write(uint32(blockType), 3)
+	if size == 32768 {
+		w.write(1, 1)
+	} else {
+		w.write(0, 1)
+		w.write(uint32(size), 16)
+	}
+}
+
+func (w *lzxWriter) verbatim(size int, aligned bool, tokens []token) {
+	if aligned {
+		w.header(2, size)
+		for i := 0; i < 8; i++ {
+			w.write(3, 3)
+		}
+	} else {
+		w.header(1, size)
+	}
+
+	mainPrevious, lengthPrevious := 0, 0
+	if w.written {
+		mainPrevious, lengthPrevious = 9, 8
+	}
+	w.lengths(256, mainPrevious, 9)
+	w.lengths(240, mainPrevious, 9)
+	w.lengths(249, lengthPrevious, 8)
+	w.written = true
+
+	for _, t := range tokens {
+		if t.length == 0 {
+			w.write(uint32(t.literal), 9)
+			continue
+		}
+
+		lengthHeader := t.length - 2
+		if lengthHeader > 7 {
+			lengthHeader = 7
+		}
+		var slot, extra int
+		if t.offset < 0 {
+			slot = -t.offset - 1
+		} else {
+			formatted := t.offset + 2
+			for slot = len(lzxTestSlotBase) - 1; lzxTestSlotBase[slot] > formatted; slot-- {
+			}
+			extra = formatted - lzxTestSlotBase[slot]
+		}
+		w.write(uint32(256+slot*8+lengthHeader), 9)
+		if lengthHeader == 7 {
+			w.write(uint32(t.length-9), 8)
+		}
+		if t.offset >= 0 {
+			extraBits := lzxTestExtraBits[slot]
+			if aligned && extraBits >= 3 {
+				w.write(uint32(extra>>3), extraBits-3)
+				w.write(uint32(extra&7), 3)
+			} else {
+				w.write(uint32(extra), extraBits)
+			}
+		}
+	}
+}
+
+// lengths writes count code lengths which all change from previous to length, using a pretree in which only the
+// needed symbol has a code (of length 1).
+func (w *lzxWriter) lengths(count, previous, length int) {
+	symbol := (previous - length + 17) % 17
+	for i := 0; i < 20; i++ {
+		if i == symbol {
+			w.write(1, 4)
+		} else {
+			w.write(0, 4)
+		}
+	}
+	for i := 0; i < count; i++ {
+		w.write(0, 1)
+	}
+}
+
+func (w *lzxWriter) uncompressed(data []byte, recent [3]uint32) {
+	w.header(3, len(data))
+	w.align()
+	for _, r := range recent {
+		b := make([]byte, 4)
+		binary.LittleEndian.PutUint32(b, r)
+		w.out = append(w.out, b...)
+	}
+	w.out = append(w.out, data...)
+	if len(data)%2 != 0 {
+		w.out = append(w.out, 0)
+	}
+}
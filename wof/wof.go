@@ -0,0 +1,269 @@
+/*
+	Package wof reads files compressed by the Windows Overlay Filter (WOF), as done by "compact /exe" and by Windows
+	itself for system files (also known as "CompactOS"). The data of such files is not stored in their unnamed $DATA
+	attribute, which is empty (sparse), but compressed in an alternate data stream called WofCompressedData. The file
+	has a reparse point with tag mft.ReparseTagWof, which indicates the compression algorithm.
+
+	Basic usage
+
+	Check if a record is compressed by WOF using Detect(), then create a Reader over the data of the WofCompressedData
+	stream. The size of the uncompressed data is the size of the unnamed $DATA attribute.
+			// Error handling left out for brevity
+			algorithm, ok, err := wof.Detect(record)
+			if ok {
+				// compressed: io.ReaderAt over the WofCompressedData stream, eg. a fragment.ReaderAt
+				r, err := wof.NewReader(compressed, compressedSize, size, algorithm)
+				_, err = io.Copy(os.Stdout, io.NewSectionReader(r, 0, r.Size()))
+			}
+
+	Implementation notes
+
+	The compressed data is divided into chunks of the chunk size of the algorithm (4, 8, 16 or 32 KiB), which are
+	compressed independently of each other. The stream starts with a table containing the offset of each chunk except
+	the first, so any part of the file can be read by decompressing only the chunks containing it. A chunk which could
+	not be compressed is stored as is. The Reader keeps the most recently decompressed chunk, so reading a file
+	sequentially using small reads decompresses each chunk once.
+
+	The XPRESS algorithms use the LZ77+Huffman format described in [MS-XCA], LZX uses the LZX format as used in WIM
+	files, with a window size equal to the chunk size.
+*/
+package wof
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/t9t/gomft/binutil"
+	"github.com/t9t/gomft/mft"
+)
+
+// StreamName is the name of the $DATA attribute (alternate data stream) containing the compressed data.
+const StreamName = "WofCompressedData"
+
+// Algorithm is the compression algorithm of a file compressed by WOF.
+type Algorithm uint32
+
+// Compression algorithms used by the WOF file provider, as stored in its reparse point.
+const (
+	AlgorithmXpress4K  Algorithm = 0
+	AlgorithmLzx       Algorithm = 1
+	AlgorithmXpress8K  Algorithm = 2
+	AlgorithmXpress16K Algorithm = 3
+)
+
+// ChunkSize returns the size of the uncompressed chunks of the algorithm, or 0 when the algorithm is unknown.
+func (a Algorithm) ChunkSize() int {
+	switch a {
+	case AlgorithmXpress4K:
+		return 4096
+	case AlgorithmLzx:
+		return 32768
+	case AlgorithmXpress8K:
+		return 8192
+	case AlgorithmXpress16K:
+		return 16384
+	}
+	return 0
+}
+
+// String returns the name of the algorithm, for example "XPRESS4K" or "LZX".
+func (a Algorithm) String() string {
+	switch a {
+	case AlgorithmXpress4K:
+		return "XPRESS4K"
+	case AlgorithmLzx:
+		return "LZX"
+	case AlgorithmXpress8K:
+		return "XPRESS8K"
+	case AlgorithmXpress16K:
+		return "XPRESS16K"
+	}
+	return fmt.Sprintf("0x%x", uint32(a))
+}
+
+const (
+	// providerFile identifies the WOF provider which compresses individual files. The other provider (1) backs files
+	// by a WIM image, which is not supported.
+	providerFile = 2
+	// reparseDataLength is the length of the WOF reparse data: the WOF version and provider, followed by the file
+	// provider version and algorithm.
+	reparseDataLength = 16
+)
+
+// ParseReparseData parses the data of a WOF reparse point (the Data of an mft.ReparsePoint with tag
+// mft.ReparseTagWof) and returns the compression algorithm. An error is returned for reparse points of other WOF
+// providers than the file provider.
+func ParseReparseData(b []byte) (Algorithm, error) {
+	if len(b) < reparseDataLength {
+		return 0, fmt.Errorf("expected at least %d bytes but got %d", reparseDataLength, len(b))
+	}
+	r := binutil.NewLittleEndianReader(b)
+	if provider := r.Uint32(0x04); provider != providerFile {
+		return 0, fmt.Errorf("unsupported WOF provider %d", provider)
+	}
+	algorithm := Algorithm(r.Uint32(0x0C))
+	if algorithm.ChunkSize() == 0 {
+		return 0, fmt.Errorf("unknown compression algorithm %v", algorithm)
+	}
+	return algorithm, nil
+}
+
+// Detect checks if the record is a file compressed by WOF, by looking for a WOF reparse point. When it is, the
+// compression algorithm is returned and the boolean is true. An error is returned when the reparse point is invalid.
+func Detect(r mft.Record) (Algorithm, bool, error) {
+	a, ok := r.FindFirstAttribute(mft.AttributeTypeReparsePoint)
+	if !ok {
+		return 0, false, nil
+	}
+	rp, err := mft.ParseReparsePoint(a.Data)
+	if err != nil {
+		return 0, false, fmt.Errorf("unable to parse reparse point: %v", err)
+	}
+	if rp.Tag != mft.ReparseTagWof {
+		return 0, false, nil
+	}
+	algorithm, err := ParseReparseData(rp.Data)
+	if err != nil {
+		return 0, false, fmt.Errorf("unable to parse WOF reparse point: %v", err)
+	}
+	return algorithm, true, nil
+}
+
+// Decompress decompresses a single chunk of data compressed with the specified algorithm into dst. The length of dst
+// must be the exact size of the uncompressed chunk.
+func Decompress(dst, src []byte, algorithm Algorithm) error {
+	switch algorithm {
+	case AlgorithmXpress4K, AlgorithmXpress8K, AlgorithmXpress16K:
+		return decompressXpress(dst, src)
+	case AlgorithmLzx:
+		return decompressLzx(dst, src)
+	}
+	return fmt.Errorf("unknown compression algorithm %v", algorithm)
+}
+
+// Reader reads the uncompressed data of a file compressed by WOF. It is safe for concurrent use when the underlying
+// io.ReaderAt is.
+type Reader struct {
+	src       io.ReaderAt
+	algorithm Algorithm
+	size      int64
+	chunkSize int64
+	offsets   []int64 // start of each chunk relative to src, plus the end of the last chunk
+
+	mu         sync.Mutex
+	chunk      []byte
+	chunkIndex int64
+}
+
+// NewReader creates a Reader decompressing the data of a WofCompressedData stream, which is read from src and is
+// compressedSize bytes long. The size is the size of the uncompressed data. The chunk table is read immediately.
+func NewReader(src io.ReaderAt, compressedSize, size int64, algorithm Algorithm) (*Reader, error) {
+	chunkSize := int64(algorithm.ChunkSize())
+	if chunkSize == 0 {
+		return nil, fmt.Errorf("unknown compression algorithm %v", algorithm)
+	}
+	if size < 0 {
+		return nil, fmt.Errorf("invalid size %d", size)
+	}
+
+	chunkCount := (size + chunkSize - 1) / chunkSize
+	entrySize := int64(4)
+	if size > 0xFFFFFFFF {
+		entrySize = 8
+	}
+	tableSize := int64(0)
+	if chunkCount > 0 {
+		tableSize = (chunkCount - 1) * entrySize
+	}
+	if tableSize > compressedSize {
+		return nil, fmt.Errorf("chunk table of %d bytes exceeds compressed size %d", tableSize, compressedSize)
+	}
+
+	table := make([]byte, tableSize)
+	if _, err := src.ReadAt(table, 0); err != nil && !(err == io.EOF && tableSize == 0) {
+		return nil, fmt.Errorf("unable to read chunk table: %v", err)
+	}
+	offsets := make([]int64, 0, chunkCount+1)
+	if chunkCount > 0 {
+		offsets = append(offsets, tableSize)
+	}
+	for i := int64(0); i < chunkCount-1; i++ {
+		var offset int64
+		if entrySize == 4 {
+			offset = int64(binary.LittleEndian.Uint32(table[i*4:]))
+		} else {
+			offset = int64(binary.LittleEndian.Uint64(table[i*8:]))
+		}
+		offset += tableSize
+		if offset < offsets[len(offsets)-1] || offset > compressedSize {
+			return nil, fmt.Errorf("invalid offset %d of chunk %d", offset-tableSize, i+1)
+		}
+		offsets = append(offsets, offset)
+	}
+	if chunkCount > 0 {
+		offsets = append(offsets, compressedSize)
+	}
+	return &Reader{src: src, algorithm: algorithm, size: size, chunkSize: chunkSize, offsets: offsets, chunkIndex: -1}, nil
+}
+
+// Size returns the size of the uncompressed data.
+func (r *Reader) Size() int64 {
+	return r.size
+}
+
+// ReadAt reads len(p) bytes of uncompressed data starting at position off. When fewer than len(p) bytes are
+// available, it returns the number of bytes read and io.EOF.
+func (r *Reader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset %d", off)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := 0
+	for n < len(p) && off < r.size {
+		index := off / r.chunkSize
+		if err := r.loadChunk(index); err != nil {
+			return n, err
+		}
+		copied := copy(p[n:], r.chunk[off-index*r.chunkSize:])
+		n += copied
+		off += int64(copied)
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// loadChunk decompresses the chunk with the specified index into r.chunk, unless it is already there.
+func (r *Reader) loadChunk(index int64) error {
+	if index == r.chunkIndex {
+		return nil
+	}
+	uncompressedSize := r.chunkSize
+	if rest := r.size - index*r.chunkSize; rest < uncompressedSize {
+		uncompressedSize = rest
+	}
+	start, end := r.offsets[index], r.offsets[index+1]
+	compressed := make([]byte, end-start)
+	if _, err := r.src.ReadAt(compressed, start); err != nil && !(err == io.EOF && len(compressed) == 0) {
+		return fmt.Errorf("unable to read chunk %d: %v", index, err)
+	}
+
+	if cap(r.chunk) < int(r.chunkSize) {
+		r.chunk = make([]byte, r.chunkSize)
+	}
+	r.chunk = r.chunk[:uncompressedSize]
+	r.chunkIndex = -1
+	if int64(len(compressed)) == uncompressedSize {
+		// Chunks which cannot be made smaller are stored uncompressed
+		copy(r.chunk, compressed)
+	} else if err := Decompress(r.chunk, compressed, r.algorithm); err != nil {
+		return fmt.Errorf("unable to decompress chunk %d: %v", index, err)
+	}
+	r.chunkIndex = index
+	return nil
+}
@@ -0,0 +1,152 @@
+package wof_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/mft"
+	"github.com/t9t/gomft/wof"
+)
+
+func TestAlgorithm(t *testing.T) {
+	assert.Equal(t, 4096, wof.AlgorithmXpress4K.ChunkSize())
+	assert.Equal(t, 32768, wof.AlgorithmLzx.ChunkSize())
+	assert.Equal(t, 8192, wof.AlgorithmXpress8K.ChunkSize())
+	assert.Equal(t, 16384, wof.AlgorithmXpress16K.ChunkSize())
+	assert.Equal(t, 0, wof.Algorithm(4).ChunkSize())
+
+	assert.Equal(t, "XPRESS4K", wof.AlgorithmXpress4K.String())
+	assert.Equal(t, "LZX", wof.AlgorithmLzx.String())
+	assert.Equal(t, "0x4", wof.Algorithm(4).String())
+}
+
+func TestParseReparseData(t *testing.T) {
+	algorithm, err := wof.ParseReparseData(wofReparseData(2, wof.AlgorithmLzx))
+	require.Nilf(t, err, "unable to parse reparse data: %v", err)
+	assert.Equal(t, wof.AlgorithmLzx, algorithm)
+
+	_, err = wof.ParseReparseData(wofReparseData(1, wof.AlgorithmLzx))
+	assert.EqualError(t, err, "unsupported WOF provider 1")
+
+	_, err = wof.ParseReparseData(wofReparseData(2, 7))
+	assert.EqualError(t, err, "unknown compression algorithm 0x7")
+
+	_, err = wof.ParseReparseData(make([]byte, 8))
+	assert.EqualError(t, err, "expected at least 16 bytes but got 8")
+}
+
+func TestDetect(t *testing.T) {
+	record := mft.Record{Attributes: []mft.Attribute{wofReparsePoint(uint32(mft.ReparseTagWof), wofReparseData(2, wof.AlgorithmXpress8K))}}
+	algorithm, ok, err := wof.Detect(record)
+	require.Nilf(t, err, "unable to detect: %v", err)
+	assert.True(t, ok)
+	assert.Equal(t, wof.AlgorithmXpress8K, algorithm)
+
+	_, ok, err = wof.Detect(mft.Record{})
+	require.Nilf(t, err, "unable to detect: %v", err)
+	assert.False(t, ok)
+
+	record = mft.Record{Attributes: []mft.Attribute{wofReparsePoint(uint32(mft.ReparseTagDedup), make([]byte, 16))}}
+	_, ok, err = wof.Detect(record)
+	require.Nilf(t, err, "unable to detect: %v", err)
+	assert.False(t, ok)
+
+	record = mft.Record{Attributes: []mft.Attribute{wofReparsePoint(uint32(mft.ReparseTagWof), make([]byte, 4))}}
+	_, ok, err = wof.Detect(record)
+	assert.EqualError(t, err, "unable to parse WOF reparse point: expected at least 16 bytes but got 4")
+	assert.False(t, ok)
+}
+
+func TestReader(t *testing.T) {
+	compressed, expected := testStream()
+	r, err := wof.NewReader(bytes.NewReader(compressed), int64(len(compressed)), int64(len(expected)), wof.AlgorithmXpress4K)
+	require.Nilf(t, err, "unable to create reader: %v", err)
+	assert.Equal(t, int64(len(expected)), r.Size())
+
+	all, err := ioutil.ReadAll(io.NewSectionReader(r, 0, r.Size()))
+	require.Nilf(t, err, "unable to read: %v", err)
+	assert.Equal(t, expected, all)
+
+	p := make([]byte, 10)
+	n, err := r.ReadAt(p, 4090)
+	require.Nilf(t, err, "unable to read: %v", err)
+	assert.Equal(t, 10, n)
+	assert.Equal(t, expected[4090:4100], p)
+
+	n, err = r.ReadAt(p, int64(len(expected))-4)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, 4, n)
+	assert.Equal(t, expected[len(expected)-4:], p[:4])
+}
+
+func TestReader_Empty(t *testing.T) {
+	r, err := wof.NewReader(bytes.NewReader(nil), 0, 0, wof.AlgorithmLzx)
+	require.Nilf(t, err, "unable to create reader: %v", err)
+	n, err := r.ReadAt(make([]byte, 1), 0)
+	assert.Equal(t, 0, n)
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestNewReader_Invalid(t *testing.T) {
+	_, err := wof.NewReader(bytes.NewReader(nil), 0, 100, wof.Algorithm(9))
+	assert.EqualError(t, err, "unknown compression algorithm 0x9")
+
+	_, err = wof.NewReader(bytes.NewReader(nil), 4, 8193, wof.AlgorithmXpress4K)
+	assert.EqualError(t, err, "chunk table of 8 bytes exceeds compressed size 4")
+
+	table := []byte{0x10, 0, 0, 0, 0x08, 0, 0, 0}
+	_, err = wof.NewReader(bytes.NewReader(table), 100, 8193, wof.AlgorithmXpress4K)
+	assert.EqualError(t, err, "invalid offset 8 of chunk 2")
+
+	compressed, expected := testStream()
+	compressed[4096+8+256] = 0xff
+	r, err := wof.NewReader(bytes.NewReader(compressed), int64(len(compressed)), int64(len(expected)), wof.AlgorithmXpress4K)
+	require.Nilf(t, err, "unable to create reader: %v", err)
+	_, err = r.ReadAt(make([]byte, 10), 5000)
+	assert.EqualError(t, err, "unable to decompress chunk 1: match offset 50081 exceeds decompressed data of 1 bytes")
+}
+
+// testStream returns the WofCompressedData stream of 3 XPRESS4K chunks, of which the first and last are stored
+// uncompressed, together with the uncompressed data.
+func testStream() ([]byte, []byte) {
+	first := make([]byte, 4096)
+	for i := range first {
+		first[i] = byte(i * 7)
+	}
+	tokens := []token{literal('z')}
+	for i := 0; i < 240; i++ {
+		tokens = append(tokens, match(17, 1))
+	}
+	tokens = append(tokens, match(15, 1))
+	second := xpressCompress(tokens)
+	third := []byte("the end")
+
+	compressed := make([]byte, 8)
+	binary.LittleEndian.PutUint32(compressed, uint32(len(first)))
+	binary.LittleEndian.PutUint32(compressed[4:], uint32(len(first)+len(second)))
+	compressed = append(append(append(compressed, first...), second...), third...)
+
+	expected := append(append(append([]byte{}, first...), bytes.Repeat([]byte{'z'}, 4096)...), third...)
+	return compressed, expected
+}
+
+func wofReparseData(provider uint32, algorithm wof.Algorithm) []byte {
+	b := make([]byte, 16)
+	binary.LittleEndian.PutUint32(b, 1)
+	binary.LittleEndian.PutUint32(b[4:], provider)
+	binary.LittleEndian.PutUint32(b[8:], 1)
+	binary.LittleEndian.PutUint32(b[12:], uint32(algorithm))
+	return b
+}
+
+func wofReparsePoint(tag uint32, data []byte) mft.Attribute {
+	b := make([]byte, 8, 8+len(data))
+	binary.LittleEndian.PutUint32(b, tag)
+	binary.LittleEndian.PutUint16(b[4:], uint16(len(data)))
+	return mft.Attribute{Type: mft.AttributeTypeReparsePoint, Resident: true, Data: append(b, data...)}
+}
@@ -0,0 +1,138 @@
+package wof
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	xpressSymbols       = 512
+	xpressMaxCodeLength = 15
+	xpressTableSize     = xpressSymbols / 2
+	xpressBlockSize     = 65536
+)
+
+// decompressXpress decompresses data in the LZ77+Huffman format of [MS-XCA] into dst. The data consists of blocks
+// which each start with a table of the 4-bit code lengths of the 512 Huffman symbols, followed by a bit stream of 16-bit
+// Little Endian words from which the codes are read starting at the most significant bit. Symbols below 256 are
+// literals, the others encode the length and the number of offset bits of a match.
+func decompressXpress(dst, src []byte) error {
+	in := 0
+	out := 0
+	lengths := make([]byte, xpressSymbols)
+	for out < len(dst) {
+		if len(src)-in < xpressTableSize {
+			return fmt.Errorf("expected Huffman table at offset %d, but only %d bytes remain", in, len(src)-in)
+		}
+		for i, b := range src[in : in+xpressTableSize] {
+			lengths[i*2] = b & 0x0F
+			lengths[i*2+1] = b >> 4
+		}
+		h, err := newHuffman(lengths, xpressMaxCodeLength)
+		if err != nil {
+			return fmt.Errorf("invalid Huffman table at offset %d: %v", in, err)
+		}
+		in += xpressTableSize
+
+		s := xpressStream{src: src, pos: in}
+		s.bits = uint32(s.word())<<16 | uint32(s.word())
+		s.extra = 16
+
+		blockEnd := out + xpressBlockSize
+		if blockEnd > len(dst) {
+			blockEnd = len(dst)
+		}
+		for out < blockEnd {
+			symbol, length := h.decode(s.bits)
+			if length == 0 {
+				return fmt.Errorf("invalid Huffman code at offset %d", s.pos)
+			}
+			s.consume(length)
+			if symbol < 256 {
+				dst[out] = byte(symbol)
+				out++
+				continue
+			}
+
+			symbol -= 256
+			matchLength := symbol & 0x0F
+			offsetBits := uint(symbol >> 4)
+			if matchLength == 15 {
+				if s.pos >= len(src) {
+					return fmt.Errorf("unexpected end of data reading match length at offset %d", s.pos)
+				}
+				matchLength = int(src[s.pos])
+				s.pos++
+				if matchLength == 255 {
+					if s.pos+2 > len(src) {
+						return fmt.Errorf("unexpected end of data reading match length at offset %d", s.pos)
+					}
+					matchLength = int(binary.LittleEndian.Uint16(src[s.pos:]))
+					s.pos += 2
+					if matchLength == 0 {
+						if s.pos+4 > len(src) {
+							return fmt.Errorf("unexpected end of data reading match length at offset %d", s.pos)
+						}
+						matchLength = int(binary.LittleEndian.Uint32(src[s.pos:]))
+						s.pos += 4
+					}
+					if matchLength < 15 {
+						return fmt.Errorf("invalid match length %d at offset %d", matchLength, s.pos)
+					}
+					matchLength -= 15
+				}
+				matchLength += 15
+			}
+			matchLength += 3
+
+			offset := 1 << offsetBits
+			if offsetBits > 0 {
+				offset += int(s.bits >> (32 - offsetBits))
+			}
+			s.consume(int(offsetBits))
+
+			if offset > out {
+				return fmt.Errorf("match offset %d exceeds decompressed data of %d bytes", offset, out)
+			}
+			if matchLength > len(dst)-out {
+				return fmt.Errorf("match length %d exceeds remaining %d bytes", matchLength, len(dst)-out)
+			}
+			for i := 0; i < matchLength; i++ {
+				dst[out] = dst[out-offset]
+				out++
+			}
+		}
+		in = s.pos
+	}
+	return nil
+}
+
+// xpressStream reads the bit stream of an XPRESS block. The bits contains the next 16 to 32 bits of the stream, starting
+// at the most significant bit; extra is the number of valid bits beyond the first 16.
+type xpressStream struct {
+	src   []byte
+	pos   int
+	bits  uint32
+	extra int
+}
+
+// word reads the next 16-bit word, which is zero beyond the end of the data.
+func (s *xpressStream) word() uint16 {
+	if s.pos+2 > len(s.src) {
+		s.pos += 2
+		return 0
+	}
+	w := binary.LittleEndian.Uint16(s.src[s.pos:])
+	s.pos += 2
+	return w
+}
+
+// consume removes n bits (at most 16) from the stream.
+func (s *xpressStream) consume(n int) {
+	s.bits <<= uint(n)
+	s.extra -= n
+	if s.extra < 0 {
+		s.bits |= uint32(s.word()) << uint(-s.extra)
+		s.extra += 16
+	}
+}
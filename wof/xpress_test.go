@@ -0,0 +1,119 @@
+package wof_test
+
+import (
+	"bytes"
+	"math/bits"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t9t/gomft/wof"
+)
+
+func TestDecompress_Xpress(t *testing.T) {
+	expected := []byte("abcabcabcabcabcabcx" + "yyyyyyyyyy")
+	compressed := xpressCompress([]token{
+		literal('a'), literal('b'), literal('c'), match(15, 3), literal('x'), literal('y'), match(9, 1),
+	})
+
+	out := make([]byte, len(expected))
+	err := wof.Decompress(out, compressed, wof.AlgorithmXpress4K)
+	require.Nilf(t, err, "unable to decompress: %v", err)
+	assert.Equal(t, expected, out)
+}
+
+func TestDecompress_XpressLiterals(t *testing.T) {
+	expected := bytes.Repeat([]byte{0x00, 0x7f, 0xff, 0x10}, 1024)
+	tokens := make([]token, 0, len(expected))
+	for _, b := range expected {
+		tokens = append(tokens, literal(b))
+	}
+
+	out := make([]byte, len(expected))
+	err := wof.Decompress(out, xpressCompress(tokens), wof.AlgorithmXpress16K)
+	require.Nilf(t, err, "unable to decompress: %v", err)
+	assert.Equal(t, expected, out)
+}
+
+func TestDecompress_XpressInvalid(t *testing.T) {
+	out := make([]byte, 16)
+	err := wof.Decompress(out, make([]byte, 100), wof.AlgorithmXpress4K)
+	assert.EqualError(t, err, "expected Huffman table at offset 0, but only 100 bytes remain")
+
+	err = wof.Decompress(out, make([]byte, 260), wof.AlgorithmXpress4K)
+	assert.EqualError(t, err, "invalid Huffman code at offset 260")
+
+	err = wof.Decompress(out, bytes.Repeat([]byte{0x11}, 260), wof.AlgorithmXpress4K)
+	assert.EqualError(t, err, "invalid Huffman table at offset 0: over-subscribed code with 512 codes of length 1")
+
+	err = wof.Decompress(out, xpressCompress([]token{literal('a'), match(4, 2)}), wof.AlgorithmXpress4K)
+	assert.EqualError(t, err, "match offset 2 exceeds decompressed data of 1 bytes")
+
+	err = wof.Decompress(out, xpressCompress([]token{literal('a'), match(17, 1)}), wof.AlgorithmXpress4K)
+	assert.EqualError(t, err, "match length 17 exceeds remaining 15 bytes")
+}
+
+// token is a literal (when length is 0) or a match for the test compressors.
+type token struct {
+	literal        byte
+	length, offset int
+}
+
+func literal(b byte) token {
+	return token{literal: b}
+}
+
+func match(length, offset int) token {
+	return token{length: length, offset: offset}
+}
+
+// xpressCompress encodes the tokens in the XPRESS (LZ77+Huffman) format, using a code in which every symbol has a
+// code length of 9, so the code of each symbol is its own value. Match lengths must be between 3 and 17, so no extra
+// length bytes are needed.
+func xpressCompress(tokens []token) []byte {
+	w := &bitWriter{}
+	for _, t := range tokens {
+		if t.length == 0 {
+			w.write(uint32(t.literal), 9)
+			continue
+		}
+		offsetBits := bits.Len(uint(t.offset)) - 1
+		w.write(uint32(256+offsetBits*16+t.length-3), 9)
+		w.write(uint32(t.offset-1<<uint(offsetBits)), offsetBits)
+	}
+	return append(bytes.Repeat([]byte{0x99}, 256), w.bytes()...)
+}
+
+// bitWriter writes a bit stream of 16-bit Little Endian words, filling each word starting at the most significant bit.
+type bitWriter struct {
+	out  []byte
+	word uint16
+	used int
+}
+
+func (w *bitWriter) write(v uint32, n int) {
+	for i := n - 1; i >= 0; i-- {
+		w.word |= uint16((v>>uint(i))&1) << uint(15-w.used)
+		w.used++
+		if w.used == 16 {
+			w.flush()
+		}
+	}
+}
+
+// align pads the current word with zero bits; when the current word is empty, a word of padding is written.
+func (w *bitWriter) align() {
+	w.flush()
+}
+
+func (w *bitWriter) flush() {
+	w.out = append(w.out, byte(w.word), byte(w.word>>8))
+	w.word, w.used = 0, 0
+}
+
+func (w *bitWriter) bytes() []byte {
+	if w.used > 0 {
+		w.flush()
+	}
+	return w.out
+}